@@ -0,0 +1,241 @@
+// Package agent serves gonka-nop operations over an authenticated HTTP API,
+// so fleet tooling can manage a node without interactive SSH sessions.
+//
+// Endpoints (all JSON):
+//
+//	GET  /v1/status                  status report, as 'status --format json'
+//	GET  /v1/update/check?service=   version diffs, as 'update --check'
+//	POST /v1/update/apply?service=   apply updates; streams NDJSON progress
+//	GET  /v1/repair/diagnose         repair plan, as 'repair --check'
+//	POST /v1/mlnode/{id}/enable      enable an ML node via the Admin API
+//	POST /v1/mlnode/{id}/disable     disable an ML node via the Admin API
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inc4/gonka-nop/internal/status"
+	"github.com/inc4/gonka-nop/internal/ui"
+)
+
+// StreamContentType is the media type of streamed operations.
+const StreamContentType = "application/x-ndjson"
+
+// Ops are the node operations the agent exposes. The cmd package implements
+// them with the same code the CLI commands run. Text an operation writes to
+// out (such as the version table) is streamed as "output" lines.
+type Ops struct {
+	Status         func(ctx context.Context) (*status.NodeStatus, error)
+	UpdateCheck    func(ctx context.Context, service string) (interface{}, error)
+	UpdateApply    func(ctx context.Context, service string, out io.Writer) error
+	RepairDiagnose func(ctx context.Context) (interface{}, error)
+	MLNodeAction   func(ctx context.Context, nodeID, action string) error
+}
+
+// StreamEvent is one line of a streamed operation. Progress lines carry the
+// ui level and message; the last line has Done set, and Error on failure.
+type StreamEvent struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Done    bool      `json:"done,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Server routes API requests to Ops.
+type Server struct {
+	ops   Ops
+	token string
+
+	// busy allows one long-running operation at a time: progress is captured
+	// from the process-wide ui output, and two updates must never overlap.
+	busy sync.Mutex
+}
+
+// New creates a server. Requests must carry "Authorization: Bearer <token>"
+// unless token is empty (client certificates are then the only check).
+func New(ops Ops, token string) *Server {
+	return &Server{ops: ops, token: token}
+}
+
+// Handler returns the authenticated API handler.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/status", s.handleStatus)
+	mux.HandleFunc("GET /v1/update/check", s.handleUpdateCheck)
+	mux.HandleFunc("POST /v1/update/apply", s.handleUpdateApply)
+	mux.HandleFunc("GET /v1/repair/diagnose", s.handleRepairDiagnose)
+	mux.HandleFunc("POST /v1/mlnode/{id}/{action}", s.handleMLNodeAction)
+	return s.authenticate(mux)
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="gonka-nop"`)
+				writeError(w, http.StatusUnauthorized, "missing or invalid token")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// begin takes the operation lock, or answers 409 Conflict.
+func (s *Server) begin(w http.ResponseWriter) bool {
+	if s.busy.TryLock() {
+		return true
+	}
+	writeError(w, http.StatusConflict, "another operation is in progress")
+	return false
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	st, err := s.ops.Status(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, status.NewReport(st))
+}
+
+func (s *Server) handleUpdateCheck(w http.ResponseWriter, r *http.Request) {
+	if !s.begin(w) {
+		return
+	}
+	defer s.busy.Unlock()
+
+	plan, err := s.ops.UpdateCheck(r.Context(), r.URL.Query().Get("service"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, plan)
+}
+
+func (s *Server) handleUpdateApply(w http.ResponseWriter, r *http.Request) {
+	if !s.begin(w) {
+		return
+	}
+	defer s.busy.Unlock()
+
+	stream := newEventStream(w)
+	restore := ui.SetSink(func(e ui.Event) {
+		stream.send(StreamEvent{Level: e.Level, Message: e.Message})
+	})
+	out := &streamWriter{stream: stream}
+	// Finish the update even if the client disconnects: stopping halfway
+	// could leave the ML node disabled.
+	err := s.ops.UpdateApply(context.WithoutCancel(r.Context()), r.URL.Query().Get("service"), out)
+	out.flush()
+	restore()
+
+	done := StreamEvent{Done: true}
+	if err != nil {
+		done.Error = err.Error()
+	}
+	stream.send(done)
+}
+
+func (s *Server) handleRepairDiagnose(w http.ResponseWriter, r *http.Request) {
+	if !s.begin(w) {
+		return
+	}
+	defer s.busy.Unlock()
+
+	plan, err := s.ops.RepairDiagnose(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, plan)
+}
+
+func (s *Server) handleMLNodeAction(w http.ResponseWriter, r *http.Request) {
+	nodeID, action := r.PathValue("id"), r.PathValue("action")
+	if action != "enable" && action != "disable" {
+		writeError(w, http.StatusNotFound, "unknown action "+action)
+		return
+	}
+	// An update disables and re-enables the ML node itself
+	if !s.begin(w) {
+		return
+	}
+	defer s.busy.Unlock()
+
+	if err := s.ops.MLNodeAction(r.Context(), nodeID, action); err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"node_id": nodeID, "action": action})
+}
+
+// eventStream writes one JSON object per line and flushes each, so clients
+// see progress while the operation runs.
+type eventStream struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	flusher http.Flusher
+}
+
+func newEventStream(w http.ResponseWriter) *eventStream {
+	w.Header().Set("Content-Type", StreamContentType)
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	return &eventStream{enc: json.NewEncoder(w), flusher: flusher}
+}
+
+func (es *eventStream) send(e StreamEvent) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	e.Time = time.Now().UTC()
+	if es.enc.Encode(e) == nil && es.flusher != nil {
+		es.flusher.Flush()
+	}
+}
+
+// streamWriter sends text written to it as "output" events, one per line.
+type streamWriter struct {
+	stream *eventStream
+	buf    []byte
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	sw.buf = append(sw.buf, p...)
+	for {
+		i := bytes.IndexByte(sw.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		sw.stream.send(StreamEvent{Level: "output", Message: string(sw.buf[:i])})
+		sw.buf = sw.buf[i+1:]
+	}
+}
+
+// flush sends a trailing line without a newline.
+func (sw *streamWriter) flush() {
+	if len(sw.buf) > 0 {
+		sw.stream.send(StreamEvent{Level: "output", Message: string(sw.buf)})
+		sw.buf = nil
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
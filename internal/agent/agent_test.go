@@ -0,0 +1,183 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/inc4/gonka-nop/internal/status"
+	"github.com/inc4/gonka-nop/internal/ui"
+)
+
+const testToken = "0123456789abcdef-token"
+
+func testOps() Ops {
+	return Ops{
+		Status: func(context.Context) (*status.NodeStatus, error) {
+			return &status.NodeStatus{}, nil
+		},
+		UpdateCheck: func(_ context.Context, service string) (interface{}, error) {
+			return map[string]string{"service": service}, nil
+		},
+		UpdateApply: func(_ context.Context, service string, out io.Writer) error {
+			_, _ = fmt.Fprintf(out, "  %-12s %s\n  %-12s %s", "Service", "Status", service, "UPDATE AVAILABLE")
+			ui.Info("Pulling %s images...", service)
+			ui.Success("Update complete.")
+			return nil
+		},
+		RepairDiagnose: func(context.Context) (interface{}, error) {
+			return map[string]int{"diagnoses": 0}, nil
+		},
+		MLNodeAction: func(_ context.Context, nodeID, _ string) error {
+			if nodeID == "bad-node" {
+				return errors.New("admin API returned 500")
+			}
+			return nil
+		},
+	}
+}
+
+func do(t *testing.T, h http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthentication(t *testing.T) {
+	h := New(testOps(), testToken).Handler()
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"wrong token", "not-the-token-at-all", http.StatusUnauthorized},
+		{"valid token", testToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(t, h, http.MethodGet, "/v1/status", tt.token)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthentication_CertOnly(t *testing.T) {
+	// Without a token the TLS layer is the only check.
+	rec := do(t, New(testOps(), "").Handler(), http.MethodGet, "/v1/status", "")
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+}
+
+func TestStatus_Report(t *testing.T) {
+	rec := do(t, New(testOps(), testToken).Handler(), http.MethodGet, "/v1/status", testToken)
+	var report status.Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if report.SchemaVersion != status.ReportSchemaVersion {
+		t.Errorf("schema_version = %d, want %d", report.SchemaVersion, status.ReportSchemaVersion)
+	}
+}
+
+func TestUpdateApply_Streams(t *testing.T) {
+	rec := do(t, New(testOps(), testToken).Handler(), http.MethodPost, "/v1/update/apply?service=mlnode", testToken)
+	if ct := rec.Header().Get("Content-Type"); ct != StreamContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, StreamContentType)
+	}
+
+	var events []StreamEvent
+	sc := bufio.NewScanner(rec.Body)
+	for sc.Scan() {
+		var e StreamEvent
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != 5 {
+		t.Fatalf("got %d events, want 5: %+v", len(events), events)
+	}
+	if events[0].Level != "output" || events[0].Message != "  Service      Status" {
+		t.Errorf("first event = %+v", events[0])
+	}
+	if events[1].Level != "info" || events[1].Message != "Pulling mlnode images..." {
+		t.Errorf("second event = %+v", events[1])
+	}
+	// A trailing line without a newline is sent when the operation ends
+	if events[3].Level != "output" || events[3].Message != "  mlnode       UPDATE AVAILABLE" {
+		t.Errorf("fourth event = %+v", events[3])
+	}
+	last := events[4]
+	if !last.Done || last.Error != "" {
+		t.Errorf("last event = %+v, want done without error", last)
+	}
+}
+
+func TestUpdateApply_Error(t *testing.T) {
+	ops := testOps()
+	ops.UpdateApply = func(context.Context, string, io.Writer) error { return errors.New("pull images: timeout") }
+	rec := do(t, New(ops, testToken).Handler(), http.MethodPost, "/v1/update/apply", testToken)
+
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	var last StreamEvent
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !last.Done || last.Error != "pull images: timeout" {
+		t.Errorf("last event = %+v, want the error", last)
+	}
+}
+
+func TestOneOperationAtATime(t *testing.T) {
+	s := New(testOps(), testToken)
+	s.busy.Lock()
+	defer s.busy.Unlock()
+
+	for _, path := range []string{"/v1/update/check", "/v1/repair/diagnose"} {
+		if rec := do(t, s.Handler(), http.MethodGet, path, testToken); rec.Code != http.StatusConflict {
+			t.Errorf("GET %s = %d, want 409", path, rec.Code)
+		}
+	}
+	for _, path := range []string{"/v1/update/apply", "/v1/mlnode/node1/enable", "/v1/mlnode/node1/disable"} {
+		if rec := do(t, s.Handler(), http.MethodPost, path, testToken); rec.Code != http.StatusConflict {
+			t.Errorf("POST %s = %d, want 409", path, rec.Code)
+		}
+	}
+}
+
+func TestMLNodeAction(t *testing.T) {
+	h := New(testOps(), testToken).Handler()
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodPost, "/v1/mlnode/node1/disable", http.StatusOK},
+		{http.MethodPost, "/v1/mlnode/node1/enable", http.StatusOK},
+		{http.MethodPost, "/v1/mlnode/node1/reboot", http.StatusNotFound},
+		{http.MethodPost, "/v1/mlnode/bad-node/enable", http.StatusBadGateway},
+		{http.MethodGet, "/v1/mlnode/node1/enable", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if rec := do(t, h, tt.method, tt.path, testToken); rec.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+		}
+	}
+}
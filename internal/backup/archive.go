@@ -0,0 +1,276 @@
+// Package backup packs node identity material into a verifiable archive and
+// encrypts it for transport to another server.
+//
+// An archive is a gzip-compressed tar whose first entry is manifest.json,
+// followed by the backed-up files with their original paths (relative to
+// the output dir), modes and owners. The manifest lists the SHA-256 of every
+// regular file; Unpack rejects archives whose contents do not match it.
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ManifestName is the first entry of every archive.
+const ManifestName = "manifest.json"
+
+// FormatVersion is the manifest format this build writes and understands.
+const FormatVersion = 1
+
+// Manifest describes an archive's contents.
+type Manifest struct {
+	Version   int         `json:"version"`
+	CreatedAt time.Time   `json:"created_at"`
+	Hostname  string      `json:"hostname,omitempty"`
+	NodeType  string      `json:"node_type,omitempty"`
+	ChainID   string      `json:"chain_id,omitempty"`
+	OutputDir string      `json:"output_dir,omitempty"` // output dir on the source server
+	Files     []FileEntry `json:"files"`
+}
+
+// FileEntry is one regular file in the archive.
+type FileEntry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Mode   uint32 `json:"mode"`
+	UID    int    `json:"uid"`
+	GID    int    `json:"gid"`
+	SHA256 string `json:"sha256"`
+}
+
+// Has reports whether the manifest lists path or a file below it.
+func (m *Manifest) Has(p string) bool {
+	for _, f := range m.Files {
+		if f.Path == p || strings.HasPrefix(f.Path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Archive is a verified, unpacked archive.
+type Archive struct {
+	Manifest *Manifest
+	// Tar is a plain tar of the files (and their directories), without the
+	// manifest, ready to extract into the output dir.
+	Tar   []byte
+	files map[string][]byte
+}
+
+// File returns the content of a backed-up file.
+func (a *Archive) File(p string) ([]byte, bool) {
+	data, ok := a.files[p]
+	return data, ok
+}
+
+type tarEntry struct {
+	hdr  *tar.Header
+	data []byte
+}
+
+// Pack reads a tar stream of paths relative to the output dir (as produced
+// by `tar -cf - -C <output> ...`) and returns the compressed archive. The
+// manifest's Files list is filled in from the stream.
+func Pack(src io.Reader, m Manifest) ([]byte, *Manifest, error) {
+	entries, err := readTar(src)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.Version = FormatVersion
+	m.Files = nil
+	for _, e := range entries {
+		if e.hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		sum := sha256.Sum256(e.data)
+		m.Files = append(m.Files, FileEntry{
+			Path:   e.hdr.Name,
+			Size:   int64(len(e.data)),
+			Mode:   uint32(e.hdr.Mode & 0o7777), // #nosec G115 - masked to permission bits
+			UID:    e.hdr.Uid,
+			GID:    e.hdr.Gid,
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+	if len(m.Files) == 0 {
+		return nil, nil, errors.New("nothing to back up")
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+
+	manifest, err := json.MarshalIndent(&m, "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("encode manifest: %w", err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{
+		Name: ManifestName, Mode: 0o600, Size: int64(len(manifest)),
+		ModTime: m.CreatedAt, Typeflag: tar.TypeReg, Format: tar.FormatPAX,
+	}); err != nil {
+		return nil, nil, fmt.Errorf("write manifest: %w", err)
+	}
+	if _, err := tw.Write(manifest); err != nil {
+		return nil, nil, fmt.Errorf("write manifest: %w", err)
+	}
+	for _, e := range entries {
+		if err := writeEntry(tw, e); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, nil, fmt.Errorf("finish archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, nil, fmt.Errorf("finish archive: %w", err)
+	}
+	return buf.Bytes(), &m, nil
+}
+
+// Unpack decompresses an archive and verifies every file against the
+// manifest: each listed file must be present with the recorded checksum,
+// and nothing unlisted may be present.
+func Unpack(data []byte) (*Archive, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("not a backup archive: %w", err)
+	}
+	entries, err := readTar(gz)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 || entries[0].hdr.Name != ManifestName {
+		return nil, fmt.Errorf("archive does not start with %s", ManifestName)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(entries[0].data, &m); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	if m.Version != FormatVersion {
+		return nil, fmt.Errorf("unsupported manifest version %d (this gonka-nop supports %d)", m.Version, FormatVersion)
+	}
+
+	want := make(map[string]FileEntry, len(m.Files))
+	for _, f := range m.Files {
+		want[f.Path] = f
+	}
+
+	a := &Archive{Manifest: &m, files: make(map[string][]byte, len(m.Files))}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries[1:] {
+		if e.hdr.Typeflag == tar.TypeReg {
+			if err := verifyEntry(e, want); err != nil {
+				return nil, err
+			}
+			a.files[e.hdr.Name] = e.data
+		}
+		if err := writeEntry(tw, e); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("rebuild archive: %w", err)
+	}
+	for p := range want {
+		if _, ok := a.files[p]; !ok {
+			return nil, fmt.Errorf("manifest lists %s but the archive does not contain it", p)
+		}
+	}
+	a.Tar = buf.Bytes()
+	return a, nil
+}
+
+func verifyEntry(e tarEntry, want map[string]FileEntry) error {
+	f, ok := want[e.hdr.Name]
+	if !ok {
+		return fmt.Errorf("%s is not listed in the manifest", e.hdr.Name)
+	}
+	sum := sha256.Sum256(e.data)
+	if hex.EncodeToString(sum[:]) != f.SHA256 || int64(len(e.data)) != f.Size {
+		return fmt.Errorf("checksum mismatch for %s", e.hdr.Name)
+	}
+	return nil
+}
+
+// readTar reads every entry, rejecting unsafe paths and entry types.
+func readTar(r io.Reader) ([]tarEntry, error) {
+	tr := tar.NewReader(r)
+	var entries []tarEntry
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read archive: %w", err)
+		}
+
+		name, err := cleanName(hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+		hdr.Name = name
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			entries = append(entries, tarEntry{hdr: hdr})
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, fmt.Errorf("read %s: %w", name, err)
+			}
+			entries = append(entries, tarEntry{hdr: hdr, data: data})
+		default:
+			return nil, fmt.Errorf("%s: unsupported entry type %q (only files and directories)", name, hdr.Typeflag)
+		}
+	}
+}
+
+// cleanName normalizes a tar path and rejects anything escaping the output dir.
+func cleanName(name string) (string, error) {
+	clean := path.Clean(strings.TrimPrefix(name, "./"))
+	if clean == "." || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("unsafe path in archive: %q", name)
+	}
+	return clean, nil
+}
+
+func writeEntry(tw *tar.Writer, e tarEntry) error {
+	hdr := &tar.Header{
+		Typeflag: e.hdr.Typeflag,
+		Name:     e.hdr.Name,
+		Mode:     e.hdr.Mode,
+		Uid:      e.hdr.Uid,
+		Gid:      e.hdr.Gid,
+		ModTime:  e.hdr.ModTime,
+		Format:   tar.FormatPAX,
+	}
+	if e.hdr.Typeflag == tar.TypeDir {
+		hdr.Name += "/"
+	} else {
+		hdr.Size = int64(len(e.data))
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("write %s: %w", e.hdr.Name, err)
+	}
+	if _, err := tw.Write(e.data); err != nil {
+		return fmt.Errorf("write %s: %w", e.hdr.Name, err)
+	}
+	return nil
+}
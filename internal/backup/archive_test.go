@@ -0,0 +1,194 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hostStream builds a tar stream like `tar -cf - -C <output> ...` would.
+func hostStream(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: ".tmkms/", Mode: 0o700, Uid: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg, Name: name, Mode: 0o600, Size: int64(len(content)), Uid: 1000, Gid: 1000,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// rewrite decompresses an archive, applies fn to every entry, and recompresses it.
+func rewrite(t *testing.T, archive []byte, fn func(hdr *tar.Header, data []byte) []byte) []byte {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gw)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tr)
+		data = fn(hdr, data)
+		hdr.Size = int64(len(data))
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = tw.Write(data)
+	}
+	_ = tw.Close()
+	_ = gw.Close()
+	return out.Bytes()
+}
+
+func TestPackUnpack_RoundTrip(t *testing.T) {
+	files := map[string]string{
+		".tmkms/secrets/priv_validator_key.softsign": "consensus-key",
+		"./state.json": `{"use_sudo": true}`,
+	}
+	archive, m, err := Pack(bytes.NewReader(hostStream(t, files)), Manifest{
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		NodeType:  "network",
+	})
+	if err != nil {
+		t.Fatalf("Pack() error: %v", err)
+	}
+	if m.Version != FormatVersion || len(m.Files) != 2 {
+		t.Fatalf("manifest = %+v", m)
+	}
+	if m.Files[1].Path != "state.json" || m.Files[1].UID != 1000 || m.Files[1].Mode != 0o600 {
+		t.Errorf("state.json entry = %+v", m.Files[1])
+	}
+
+	a, err := Unpack(archive)
+	if err != nil {
+		t.Fatalf("Unpack() error: %v", err)
+	}
+	if !a.Manifest.Has(".tmkms") || a.Manifest.Has(".tmk") {
+		t.Error("Has() should match whole path components")
+	}
+	if got, ok := a.File(".tmkms/secrets/priv_validator_key.softsign"); !ok || string(got) != "consensus-key" {
+		t.Errorf("File() = %q, %v", got, ok)
+	}
+
+	// The extractable tar keeps directories but drops the manifest
+	var names []string
+	tr := tar.NewReader(bytes.NewReader(a.Tar))
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, hdr.Name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != ".tmkms/,.tmkms/secrets/priv_validator_key.softsign,state.json" {
+		t.Errorf("tar entries = %v", names)
+	}
+}
+
+func TestPack_Empty(t *testing.T) {
+	if _, _, err := Pack(bytes.NewReader(hostStream(t, nil)), Manifest{}); err == nil {
+		t.Error("expected error for an archive without files")
+	}
+}
+
+func TestUnpack_RejectsTampering(t *testing.T) {
+	archive, _, err := Pack(bytes.NewReader(hostStream(t, map[string]string{"state.json": "{}"})), Manifest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		fn      func(hdr *tar.Header, data []byte) []byte
+		wantErr string
+	}{
+		{
+			name: "modified file",
+			fn: func(hdr *tar.Header, data []byte) []byte {
+				if hdr.Name == "state.json" {
+					return []byte(`{"x":1}`)
+				}
+				return data
+			},
+			wantErr: "checksum mismatch",
+		},
+		{
+			name: "unlisted file",
+			fn: func(hdr *tar.Header, data []byte) []byte {
+				if hdr.Name == "state.json" {
+					hdr.Name = "config.env"
+				}
+				return data
+			},
+			wantErr: "not listed",
+		},
+		{
+			name: "path traversal",
+			fn: func(hdr *tar.Header, data []byte) []byte {
+				if hdr.Name == "state.json" {
+					hdr.Name = "../state.json"
+				}
+				return data
+			},
+			wantErr: "unsafe path",
+		},
+		{
+			name: "symlink",
+			fn: func(hdr *tar.Header, data []byte) []byte {
+				if hdr.Name == "state.json" {
+					hdr.Typeflag = tar.TypeSymlink
+					hdr.Linkname = "/etc/shadow"
+					return nil
+				}
+				return data
+			},
+			wantErr: "unsupported entry type",
+		},
+		{
+			name: "newer manifest",
+			fn: func(hdr *tar.Header, data []byte) []byte {
+				if hdr.Name == ManifestName {
+					return bytes.Replace(data, []byte(`"version": 1`), []byte(`"version": 2`), 1)
+				}
+				return data
+			},
+			wantErr: "unsupported manifest version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Unpack(rewrite(t, archive, tt.fn))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Unpack() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Passphrase envelope layout:
+//
+//	magic (8) | iterations (uint32 BE) | salt (16) | nonce (12) | AES-256-GCM ciphertext
+//
+// The header is authenticated as additional data.
+var passphraseMagic = []byte("GNKBAK01")
+
+const (
+	saltSize  = 16
+	nonceSize = 12
+	keySize   = 32
+	// MinPassphraseLength is enforced when creating a backup.
+	MinPassphraseLength = 12
+)
+
+// pbkdf2Iterations follows the OWASP recommendation for PBKDF2-HMAC-SHA256.
+// Tests lower it.
+var pbkdf2Iterations uint32 = 600_000
+
+// Decrypt rejects iteration counts outside this range: fewer make the
+// passphrase cheap to guess, more let a crafted file stall the restore.
+// Tests lower the minimum.
+var (
+	minPBKDF2Iterations uint32 = 100_000
+	maxPBKDF2Iterations uint32 = 10_000_000
+)
+
+// ageMagic starts every age-encrypted file.
+var ageMagic = []byte("age-encryption.org/v1")
+
+// ErrWrongPassphrase is returned when decryption fails authentication.
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted backup")
+
+// Encrypt seals data with a passphrase using PBKDF2-SHA256 and AES-256-GCM.
+func Encrypt(data []byte, passphrase string) ([]byte, error) {
+	header := make([]byte, 0, len(passphraseMagic)+4+saltSize+nonceSize)
+	header = append(header, passphraseMagic...)
+	header = binary.BigEndian.AppendUint32(header, pbkdf2Iterations)
+	saltNonce := make([]byte, saltSize+nonceSize)
+	if _, err := rand.Read(saltNonce); err != nil {
+		return nil, fmt.Errorf("generate salt: %w", err)
+	}
+	header = append(header, saltNonce...)
+
+	gcm, err := newGCM(passphrase, saltNonce[:saltSize], pbkdf2Iterations)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(bytes.Clone(header), saltNonce[saltSize:], data, header), nil
+}
+
+// Decrypt opens data sealed by Encrypt.
+func Decrypt(data []byte, passphrase string) ([]byte, error) {
+	headerLen := len(passphraseMagic) + 4 + saltSize + nonceSize
+	if len(data) < headerLen || !bytes.HasPrefix(data, passphraseMagic) {
+		return nil, errors.New("not a passphrase-encrypted gonka-nop backup")
+	}
+	header := data[:headerLen]
+	iterations := binary.BigEndian.Uint32(header[len(passphraseMagic):])
+	salt := header[len(passphraseMagic)+4 : len(passphraseMagic)+4+saltSize]
+	nonce := header[len(passphraseMagic)+4+saltSize:]
+	if iterations < minPBKDF2Iterations || iterations > maxPBKDF2Iterations {
+		return nil, fmt.Errorf("backup header asks for %d key derivation iterations, want %d-%d",
+			iterations, minPBKDF2Iterations, maxPBKDF2Iterations)
+	}
+
+	gcm, err := newGCM(passphrase, salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, nonce, data[headerLen:], header)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plain, nil
+}
+
+func newGCM(passphrase string, salt []byte, iterations uint32) (cipher.AEAD, error) {
+	if iterations == 0 {
+		return nil, errors.New("invalid key derivation parameters")
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, int(iterations), keySize)
+	if err != nil {
+		return nil, fmt.Errorf("derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("init cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// IsAge reports whether data is an age-encrypted file.
+func IsAge(data []byte) bool {
+	return bytes.HasPrefix(data, ageMagic)
+}
+
+// EncryptAge encrypts data to age recipients using the age CLI.
+func EncryptAge(ctx context.Context, data []byte, recipients []string) ([]byte, error) {
+	args := make([]string, 0, 2*len(recipients))
+	for _, r := range recipients {
+		args = append(args, "-r", r)
+	}
+	return runAge(ctx, data, args...)
+}
+
+// DecryptAge decrypts an age file with an identity file using the age CLI.
+func DecryptAge(ctx context.Context, data []byte, identityFile string) ([]byte, error) {
+	return runAge(ctx, data, "-d", "-i", identityFile)
+}
+
+func runAge(ctx context.Context, data []byte, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath("age"); err != nil {
+		return nil, errors.New("age is not installed (https://github.com/FiloSottile/age); use a passphrase instead")
+	}
+	cmd := exec.CommandContext(ctx, "age", args...) // #nosec G204 - recipients/identity from CLI flags
+	cmd.Stdin = bytes.NewReader(data)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("age: %w\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
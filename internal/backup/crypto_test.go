@@ -0,0 +1,74 @@
+package backup
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	lowerIterations(t)
+
+	plain := []byte("node identity")
+	sealed, err := Encrypt(plain, "correct horse battery")
+	if err != nil {
+		t.Fatalf("Encrypt() error: %v", err)
+	}
+	if IsAge(sealed) {
+		t.Error("passphrase envelope detected as age")
+	}
+
+	got, err := Decrypt(sealed, "correct horse battery")
+	if err != nil || string(got) != string(plain) {
+		t.Fatalf("Decrypt() = %q, %v", got, err)
+	}
+
+	if _, err := Decrypt(sealed, "wrong horse battery"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("wrong passphrase: got %v", err)
+	}
+
+	// The header is authenticated: changing the iteration count must fail
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(passphraseMagic)+3] ^= 1
+	if _, err := Decrypt(tampered, "correct horse battery"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("tampered header: got %v", err)
+	}
+
+	if _, err := Decrypt([]byte("age-encryption.org/v1\n..."), "x"); err == nil {
+		t.Error("expected error for a non-passphrase file")
+	}
+}
+
+func TestDecrypt_IterationBounds(t *testing.T) {
+	lowerIterations(t)
+	sealed, err := Encrypt([]byte("node identity"), "correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []uint32{minPBKDF2Iterations - 1, maxPBKDF2Iterations + 1} {
+		tampered := append([]byte(nil), sealed...)
+		binary.BigEndian.PutUint32(tampered[len(passphraseMagic):], n)
+		_, err := Decrypt(tampered, "correct horse battery")
+		if err == nil || errors.Is(err, ErrWrongPassphrase) {
+			t.Errorf("iterations %d: got %v, want a bounds error", n, err)
+		}
+	}
+}
+
+// lowerIterations makes key derivation cheap for the test.
+func lowerIterations(t *testing.T) {
+	t.Helper()
+	iter, minIter := pbkdf2Iterations, minPBKDF2Iterations
+	pbkdf2Iterations, minPBKDF2Iterations = 1000, 1000
+	t.Cleanup(func() { pbkdf2Iterations, minPBKDF2Iterations = iter, minIter })
+}
+
+func TestIsAge(t *testing.T) {
+	if !IsAge([]byte("age-encryption.org/v1\n-> X25519 abc\n")) {
+		t.Error("IsAge() = false for an age header")
+	}
+	if IsAge([]byte("GNKBAK01")) {
+		t.Error("IsAge() = true for a passphrase envelope")
+	}
+}
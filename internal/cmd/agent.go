@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/inc4/gonka-nop/internal/agent"
+	"github.com/inc4/gonka-nop/internal/config"
+	"github.com/inc4/gonka-nop/internal/secret"
+	"github.com/inc4/gonka-nop/internal/status"
+	"github.com/inc4/gonka-nop/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+const defaultAgentListen = "127.0.0.1:9102"
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Serve node operations over an authenticated HTTP API",
+	Long: `Run an HTTP API that lets fleet tooling manage this node without an
+interactive SSH session. It runs the same code as the CLI commands:
+
+  GET  /v1/status                  'status --format json'
+  GET  /v1/update/check?service=   'update --check'
+  POST /v1/update/apply?service=   'update -y', progress streamed as NDJSON
+  GET  /v1/repair/diagnose         'repair --check'
+  POST /v1/mlnode/{id}/enable      'ml-node enable'
+  POST /v1/mlnode/{id}/disable     'ml-node disable'
+
+Every request is authenticated with a bearer token (--token-file or
+--token-env), a client certificate (--client-ca, with --tls-cert/--tls-key),
+or both. The agent refuses to start without one of them. Only one update or
+diagnosis runs at a time; a second request gets 409 Conflict.
+
+Examples:
+  gonka-nop agent --token-file /etc/gonka-nop/agent-token
+  gonka-nop agent --listen 10.0.1.100:9102 --tls-cert agent.crt --tls-key agent.key \
+    --client-ca fleet-ca.crt`,
+	RunE: runAgent,
+}
+
+var (
+	agentListen    string
+	agentTokenFile string
+	agentTokenEnv  string
+	agentTLSCert   string
+	agentTLSKey    string
+	agentClientCA  string
+	agentAdminURL  string
+	agentRPCURL    string
+)
+
+func init() {
+	agentCmd.Flags().StringVar(&agentListen, "listen", defaultAgentListen, "Address to serve the API on")
+	agentCmd.Flags().StringVar(&agentTokenFile, "token-file", "", "Read the bearer token from a file (absolute path, mode 0400)")
+	agentCmd.Flags().StringVar(&agentTokenEnv, "token-env", "", "Read the bearer token from an environment variable")
+	agentCmd.Flags().StringVar(&agentTLSCert, "tls-cert", "", "TLS certificate (PEM)")
+	agentCmd.Flags().StringVar(&agentTLSKey, "tls-key", "", "TLS private key (PEM)")
+	agentCmd.Flags().StringVar(&agentClientCA, "client-ca", "", "Require client certificates signed by this CA (PEM)")
+	agentCmd.Flags().StringVar(&agentAdminURL, "admin-url", "", "Admin API URL (default from state or http://localhost:9200)")
+	agentCmd.Flags().StringVar(&agentRPCURL, "rpc-url", "", "Tendermint RPC URL (default from state or http://localhost:26657)")
+	agentCmd.MarkFlagsMutuallyExclusive("token-file", "token-env")
+	agentCmd.MarkFlagsRequiredTogether("tls-cert", "tls-key")
+}
+
+func runAgent(cmd *cobra.Command, _ []string) error {
+	token, err := agentToken()
+	if err != nil {
+		return err
+	}
+	if token == "" && agentClientCA == "" {
+		return fmt.Errorf("the agent needs authentication: pass --token-file, --token-env or --client-ca")
+	}
+	tlsCfg, err := agentTLSConfig(agentTLSCert, agentTLSKey, agentClientCA)
+	if err != nil {
+		return err
+	}
+	if tlsCfg == nil && !isLoopbackListen(agentListen) {
+		ui.Warn("Serving on %s without TLS: the token travels in cleartext", agentListen)
+	}
+
+	// Operations run unattended: prompts take their defaults.
+	ui.SetNonInteractive(true)
+
+	cfg, err := resolveStatusConfig(agentAdminURL, agentRPCURL, "")
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Addr:              agentListen,
+		Handler:           agent.New(agentOps(cfg), token).Handler(),
+		TLSConfig:         tlsCfg,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return serveServerUntilDone(ctx, srv, func(context.Context) {
+		ui.Info("Managing %s (Admin API %s)", outputDir, cfg.AdminURL)
+	})
+}
+
+// agentOps wires the API to the code behind status, update, repair and
+// ml-node.
+func agentOps(cfg *status.StatusConfig) agent.Ops {
+	return agent.Ops{
+		Status: func(context.Context) (*status.NodeStatus, error) {
+			return status.FetchStatusWithConfig(outputDir, cfg)
+		},
+		UpdateCheck: func(ctx context.Context, service string) (interface{}, error) {
+			return planUpdate(ctx, outputDir, service)
+		},
+		UpdateApply: func(ctx context.Context, service string, out io.Writer) error {
+			// Same lock as the update command: it saves state and rewrites
+			// the compose files.
+			lock, err := config.LockOutputDir(outputDir)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = lock.Release() }()
+
+			plan, err := planUpdate(ctx, outputDir, service)
+			if err != nil {
+				return err
+			}
+			displayVersionDiffs(out, plan.Diffs)
+			if len(plan.Updatable) == 0 {
+				ui.Success("All services are up to date.")
+				return nil
+			}
+			// The agent's --admin-url, not the update command's flag
+			return applyUpdates(ctx, plan.State, plan.Updatable, plan.Latest, cfg.AdminURL)
+		},
+		RepairDiagnose: func(ctx context.Context) (interface{}, error) {
+			state, err := config.Load(outputDir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load state: %w", err)
+			}
+			if state.OutputDir == "" {
+				return nil, fmt.Errorf("no deployment found in %s", outputDir)
+			}
+			return diagnoseNode(ctx, state), nil
+		},
+		MLNodeAction: func(_ context.Context, nodeID, action string) error {
+			return postAdminAction(cfg.AdminURL, nodeID, action)
+		},
+	}
+}
+
+// agentToken reads the bearer token from --token-file or --token-env.
+func agentToken() (string, error) {
+	var provider secret.Provider
+	switch {
+	case agentTokenFile != "":
+		path, err := filepath.Abs(agentTokenFile)
+		if err != nil {
+			return "", fmt.Errorf("resolve token file: %w", err)
+		}
+		provider = secret.File{Path: path}
+	case agentTokenEnv != "":
+		provider = secret.Env{Name: agentTokenEnv}
+	default:
+		return "", nil
+	}
+
+	token, err := provider.Secret()
+	if err != nil {
+		return "", fmt.Errorf("agent token: %w", err)
+	}
+	if len(token) < 16 {
+		return "", fmt.Errorf("agent token must be at least 16 characters")
+	}
+	return token, nil
+}
+
+// agentTLSConfig returns nil without a certificate. With clientCA, clients
+// must present a certificate signed by it (mTLS).
+func agentTLSConfig(certFile, keyFile, clientCA string) (*tls.Config, error) {
+	if certFile == "" {
+		if clientCA != "" {
+			return nil, fmt.Errorf("--client-ca needs --tls-cert and --tls-key")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load TLS certificate: %w", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCA != "" {
+		pem, err := os.ReadFile(filepath.Clean(clientCA)) // #nosec G304 - path from CLI flag
+		if err != nil {
+			return nil, fmt.Errorf("read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", clientCA)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// isLoopbackListen reports whether addr only accepts local connections.
+func isLoopbackListen(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
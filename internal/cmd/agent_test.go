@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/inc4/gonka-nop/internal/config"
+	"github.com/inc4/gonka-nop/internal/status"
+)
+
+func TestIsLoopbackListen(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1:9102", true},
+		{"localhost:9102", true},
+		{"[::1]:9102", true},
+		{":9102", false},
+		{"0.0.0.0:9102", false},
+		{"10.0.1.100:9102", false},
+		{"bad", false},
+	}
+	for _, tt := range tests {
+		if got := isLoopbackListen(tt.addr); got != tt.want {
+			t.Errorf("isLoopbackListen(%q) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestAgentTLSConfig(t *testing.T) {
+	cfg, err := agentTLSConfig("", "", "")
+	if err != nil || cfg != nil {
+		t.Errorf("no cert: got %v, %v; want nil, nil", cfg, err)
+	}
+	if _, err := agentTLSConfig("", "", "/etc/ca.pem"); err == nil {
+		t.Error("--client-ca without a server certificate should fail")
+	}
+	if _, err := agentTLSConfig("/nonexistent.crt", "/nonexistent.key", ""); err == nil {
+		t.Error("missing certificate files should fail")
+	}
+}
+
+func TestAgentToken(t *testing.T) {
+	oldFile, oldEnv := agentTokenFile, agentTokenEnv
+	t.Cleanup(func() { agentTokenFile, agentTokenEnv = oldFile, oldEnv })
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "token")
+	if err := os.WriteFile(path, []byte("0123456789abcdef-token\n"), 0400); err != nil {
+		t.Fatal(err)
+	}
+	agentTokenFile, agentTokenEnv = path, ""
+	if got, err := agentToken(); err != nil || got != "0123456789abcdef-token" {
+		t.Errorf("agentToken() = %q, %v", got, err)
+	}
+
+	loose := filepath.Join(dir, "loose")
+	if err := os.WriteFile(loose, []byte("0123456789abcdef-token\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	agentTokenFile = loose
+	if _, err := agentToken(); err == nil {
+		t.Error("a world-readable token file should be rejected")
+	}
+
+	agentTokenFile = ""
+	agentTokenEnv = "GONKA_TEST_AGENT_TOKEN"
+	t.Setenv("GONKA_TEST_AGENT_TOKEN", "short")
+	if _, err := agentToken(); err == nil {
+		t.Error("a short token should be rejected")
+	}
+}
+
+func TestAgentUpdateApply_TakesOutputLock(t *testing.T) {
+	dir := t.TempDir()
+	held, err := config.LockOutputDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = held.Release() }()
+	outputDir = dir
+
+	err = agentOps(&status.StatusConfig{}).UpdateApply(context.Background(), "", io.Discard)
+	var locked *config.LockedError
+	if !errors.As(err, &locked) {
+		t.Errorf("UpdateApply while locked = %v, want LockedError", err)
+	}
+}
@@ -0,0 +1,579 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/inc4/gonka-nop/internal/backup"
+	"github.com/inc4/gonka-nop/internal/config"
+	"github.com/inc4/gonka-nop/internal/secret"
+	"github.com/inc4/gonka-nop/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// Identity files that make a node what it is. Losing them loses the node's
+// identity; running them on two servers at once double-signs.
+var backupIdentityPaths = []string{
+	".tmkms",
+	".inference/config/node_key.json",
+	".inference/config/priv_validator_key.json",
+	".inference/data/priv_validator_state.json",
+}
+
+// Generated files restored alongside the identity.
+var backupConfigPaths = []string{
+	"state.json",
+	"config.env",
+	"node-config.json",
+	"nginx.conf",
+	"docker-compose.yml",
+	"docker-compose.mlnode.yml",
+	"docker-compose.env-override.yml",
+	"overrides", // operator files merged into the generated ones
+}
+
+// Containers that must not run while their identity is replaced.
+var identityContainers = []string{"node", "tmkms", "api"}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Write an encrypted backup of the node identity",
+	Long: `Bundle the irreplaceable node material into one encrypted archive:
+
+  .tmkms                                   consensus key and signing state
+  .inference/config/node_key.json          P2P identity
+  .inference/data/priv_validator_state.json
+  <keyring dir>/keyring-file               warm/cold keys
+  state.json, config.env, node-config.json, compose files, overrides/
+
+The archive holds a manifest with the SHA-256 of every file. It is encrypted
+with a passphrase (PBKDF2 + AES-256-GCM) or, with --age-recipient, to age
+public keys (requires the age CLI). Copy it off the server.
+
+Files owned by the containers are read with sudo when the node uses sudo.
+
+Examples:
+  gonka-nop backup                                     # Prompt for a passphrase
+  gonka-nop backup --file /mnt/usb/node.tar.gz.enc --passphrase-file /root/backup-pass
+  gonka-nop backup --age-recipient age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p`,
+	RunE: runBackup,
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <archive>",
+	Short: "Restore the node identity from a backup",
+	Long: `Decrypt a backup written by 'gonka-nop backup', verify every file against
+its manifest, and extract it into the output directory.
+
+Restore refuses to run while the node, tmkms or api containers are running,
+and refuses to overwrite an existing identity unless --force is given.
+Container-owned files keep their original numeric owners; gonka-nop's own
+files are handed to the invoking user.
+
+WARNING: never start the restored node while the old server can still sign.
+Stop it and make sure it cannot restart, or you will double-sign.
+
+Examples:
+  gonka-nop restore node.tar.gz.enc -o /opt/gonka-node
+  gonka-nop restore node.tar.gz.age --age-identity ~/.config/age/key.txt`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRestore,
+}
+
+var (
+	backupFile          string
+	backupPassFile      string
+	backupPassEnv       string
+	backupAgeRecipients []string
+	restoreAgeIdentity  string
+	restoreForce        bool
+	restoreYes          bool
+)
+
+func init() {
+	backupCmd.Flags().StringVar(&backupFile, "file", "", "Archive path (default: <output>/backups/gonka-backup-<timestamp>.tar.gz.enc)")
+	backupCmd.Flags().StringSliceVar(&backupAgeRecipients, "age-recipient", nil, "Encrypt to an age public key instead of a passphrase (repeatable)")
+	addPassphraseFlags(backupCmd)
+	backupCmd.MarkFlagsMutuallyExclusive("age-recipient", "passphrase-file")
+	backupCmd.MarkFlagsMutuallyExclusive("age-recipient", "passphrase-env")
+
+	restoreCmd.Flags().StringVar(&restoreAgeIdentity, "age-identity", "", "age identity file for age-encrypted backups")
+	restoreCmd.Flags().BoolVar(&restoreForce, "force", false, "Overwrite an existing identity in the output directory")
+	restoreCmd.Flags().BoolVarP(&restoreYes, "yes", "y", false, "Skip the confirmation prompt")
+	addPassphraseFlags(restoreCmd)
+}
+
+func addPassphraseFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&backupPassFile, "passphrase-file", "", "Read the backup passphrase from this file (mode 0400)")
+	cmd.Flags().StringVar(&backupPassEnv, "passphrase-env", "", "Read the backup passphrase from this environment variable")
+	cmd.MarkFlagsMutuallyExclusive("passphrase-file", "passphrase-env")
+}
+
+func runBackup(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+
+	state, err := config.Load(outputDir)
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	paths, err := backupPaths(state)
+	if err != nil {
+		return err
+	}
+
+	ui.Info("Collecting files from %s", state.OutputDir)
+	stream, err := hostTar(ctx, state.UseSudo, state.OutputDir, paths)
+	if err != nil {
+		return err
+	}
+	hostname, _ := os.Hostname()
+	archive, manifest, err := backup.Pack(bytes.NewReader(stream), backup.Manifest{
+		CreatedAt: time.Now().UTC(),
+		Hostname:  hostname,
+		NodeType:  state.EffectiveNodeType(),
+		ChainID:   state.ChainID,
+		OutputDir: state.OutputDir,
+	})
+	if err != nil {
+		return err
+	}
+	for _, missing := range missingIdentity(state, manifest) {
+		ui.Warn("Not in backup: %s", missing)
+	}
+
+	var sealed []byte
+	if len(backupAgeRecipients) > 0 {
+		sealed, err = backup.EncryptAge(ctx, archive, backupAgeRecipients)
+	} else {
+		var passphrase string
+		if passphrase, err = backupPassphrase(true); err == nil {
+			sealed, err = backup.Encrypt(archive, passphrase)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	dest := backupFile
+	if dest == "" {
+		ext := ".tar.gz.enc"
+		if len(backupAgeRecipients) > 0 {
+			ext = ".tar.gz.age"
+		}
+		dest = filepath.Join(state.OutputDir, resetBackupDir,
+			"gonka-backup-"+manifest.CreatedAt.Format("20060102-150405")+ext)
+	}
+	if err := writeNewFile(dest, sealed); err != nil {
+		return err
+	}
+
+	displayManifest(manifest)
+	ui.Success("Backup written: %s", dest)
+	ui.Detail("Copy it off this server; it is all that is needed to restore the node identity.")
+	return nil
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	sealed, err := os.ReadFile(filepath.Clean(args[0])) // #nosec G304 - path from CLI argument
+	if err != nil {
+		return fmt.Errorf("read backup: %w", err)
+	}
+	var plain []byte
+	if backup.IsAge(sealed) {
+		if restoreAgeIdentity == "" {
+			return fmt.Errorf("%s is age-encrypted: pass --age-identity", args[0])
+		}
+		plain, err = backup.DecryptAge(ctx, sealed, restoreAgeIdentity)
+	} else {
+		var passphrase string
+		if passphrase, err = backupPassphrase(false); err == nil {
+			plain, err = backup.Decrypt(sealed, passphrase)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	archive, err := backup.Unpack(plain)
+	if err != nil {
+		return fmt.Errorf("verify backup: %w", err)
+	}
+	displayManifest(archive.Manifest)
+	ui.Success("All %d files match the manifest", len(archive.Manifest.Files))
+
+	absOut, err := filepath.Abs(outputDir)
+	if err != nil {
+		return fmt.Errorf("resolve output dir: %w", err)
+	}
+	useSudo := os.Geteuid() != 0 && archivedUseSudo(archive)
+
+	running, err := runningContainers(ctx, useSudo)
+	if err != nil {
+		return fmt.Errorf("cannot tell whether the node is running, refusing to restore: %w", err)
+	}
+	if len(running) > 0 {
+		return fmt.Errorf("containers are running (%s) — stop the node before restoring", strings.Join(running, ", "))
+	}
+	if existing := existingIdentity(absOut, archive.Manifest); len(existing) > 0 && !restoreForce {
+		return fmt.Errorf("%s already has a node identity (%s) — use --force to overwrite",
+			absOut, strings.Join(existing, ", "))
+	}
+
+	ui.Warn("Never run this identity on two servers: stop the old node for good before starting this one.")
+	if !restoreYes {
+		proceed, confirmErr := ui.Confirm(fmt.Sprintf("Restore into %s?", absOut), false)
+		if confirmErr != nil {
+			return confirmErr
+		}
+		if !proceed {
+			ui.Info("Restore canceled.")
+			return nil
+		}
+	}
+
+	if err := extractArchive(ctx, useSudo, absOut, archive.Tar); err != nil {
+		return err
+	}
+	ui.Success("Extracted %d files into %s", len(archive.Manifest.Files), absOut)
+
+	if err := fixRestoredOwnership(ctx, useSudo, absOut, archive.Manifest); err != nil {
+		ui.Warn("Could not fix file ownership: %v", err)
+	}
+	if err := rebaseRestoredState(absOut, archive.Manifest.OutputDir); err != nil {
+		return err
+	}
+
+	ui.Success("Restore complete")
+	ui.Detail("Start the node with: cd %s && docker compose up -d", absOut)
+	if restored, err := config.Load(absOut); err == nil {
+		printKeyringEnvHint(restored)
+	}
+	return nil
+}
+
+// backupPaths returns the candidate paths, relative to the output dir.
+// Missing ones are dropped by presentPaths.
+func backupPaths(state *config.State) ([]string, error) {
+	keyringDir, err := keyringRelDir(state)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(backupIdentityPaths)+len(backupConfigPaths)+1+len(state.ComposeFiles))
+	paths = append(paths, backupIdentityPaths...)
+	paths = append(paths, filepath.ToSlash(filepath.Join(keyringDir, "keyring-file")))
+	paths = append(paths, backupConfigPaths...)
+	for _, f := range state.ComposeFiles {
+		if !slices.Contains(paths, f) && !filepath.IsAbs(f) {
+			paths = append(paths, f)
+		}
+	}
+	return paths, nil
+}
+
+// keyringRelDir returns state.KeyringDir relative to the output dir
+// (".inference" when unset). A keyring outside the output dir is an error.
+func keyringRelDir(state *config.State) (string, error) {
+	if state.KeyringDir == "" {
+		return ".inference", nil
+	}
+	absOut, err := filepath.Abs(state.OutputDir)
+	if err != nil {
+		return "", fmt.Errorf("resolve output dir: %w", err)
+	}
+	absKeyring, err := filepath.Abs(state.KeyringDir)
+	if err != nil {
+		return "", fmt.Errorf("resolve keyring dir: %w", err)
+	}
+	rel, err := filepath.Rel(absOut, absKeyring)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("keyring dir %s is outside the output dir %s", state.KeyringDir, absOut)
+	}
+	return rel, nil
+}
+
+// missingIdentity lists the identity material a node of this type should have
+// but the backup does not contain.
+func missingIdentity(state *config.State, m *backup.Manifest) []string {
+	if state.IsMLNodeOnly() {
+		return nil
+	}
+	var missing []string
+	for _, p := range []string{".tmkms", ".inference/config/node_key.json"} {
+		if !m.Has(p) {
+			missing = append(missing, p)
+		}
+	}
+	if state.KeyringDir != "" && !slices.ContainsFunc(m.Files, func(f backup.FileEntry) bool {
+		return strings.Contains(f.Path, "keyring-file/")
+	}) {
+		missing = append(missing, "keyring-file")
+	}
+	return missing
+}
+
+// backupPassphrase reads the passphrase from --passphrase-file/-env or a
+// prompt. New backups prompt twice and require MinPassphraseLength.
+func backupPassphrase(isNew bool) (string, error) {
+	var provider secret.Provider = secret.Prompt{
+		Message: "Backup passphrase:",
+		Hint:    "use --passphrase-file or --passphrase-env",
+	}
+	switch {
+	case backupPassFile != "":
+		abs, err := filepath.Abs(backupPassFile)
+		if err != nil {
+			return "", fmt.Errorf("resolve passphrase file: %w", err)
+		}
+		provider = secret.File{Path: abs}
+	case backupPassEnv != "":
+		provider = secret.Env{Name: backupPassEnv}
+	}
+
+	passphrase, err := provider.Secret()
+	if err != nil {
+		return "", fmt.Errorf("backup passphrase: %w", err)
+	}
+	if !isNew {
+		return passphrase, nil
+	}
+	if len(passphrase) < backup.MinPassphraseLength {
+		return "", fmt.Errorf("backup passphrase must be at least %d characters", backup.MinPassphraseLength)
+	}
+	if _, ok := provider.(secret.Prompt); ok {
+		again, err := secret.Prompt{Message: "Repeat passphrase:"}.Secret()
+		if err != nil {
+			return "", fmt.Errorf("backup passphrase: %w", err)
+		}
+		if again != passphrase {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
+
+// hostTar streams the given paths through tar on the host (with sudo if
+// needed, since container files are root-owned). Paths that do not exist
+// are skipped; a path that exists but cannot be read fails the backup.
+func hostTar(ctx context.Context, useSudo bool, dir string, paths []string) ([]byte, error) {
+	paths, err := presentPaths(ctx, useSudo, dir, paths)
+	if err != nil {
+		return nil, err
+	}
+	args := append([]string{"-cf", "-", "-C", dir}, paths...)
+	name := "tar"
+	if useSudo {
+		args = append([]string{"tar"}, args...)
+		name = "sudo"
+	}
+	cmd := exec.CommandContext(ctx, name, args...) // #nosec G204 - args are constructed internally
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("tar: %w\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// presentPaths drops the paths that do not exist under dir. With sudo, a
+// permission error is rechecked as root, since container dirs are root-only.
+func presentPaths(ctx context.Context, useSudo bool, dir string, paths []string) ([]string, error) {
+	var out []string
+	for _, p := range paths {
+		full := filepath.Join(dir, p)
+		_, err := os.Lstat(full)
+		switch {
+		case err == nil:
+			out = append(out, p)
+		case errors.Is(err, fs.ErrNotExist):
+		case useSudo && errors.Is(err, fs.ErrPermission):
+			// #nosec G204 - path is constructed internally
+			if exec.CommandContext(ctx, "sudo", "test", "-e", full).Run() == nil {
+				out = append(out, p)
+			}
+		default:
+			return nil, fmt.Errorf("check %s: %w", p, err)
+		}
+	}
+	return out, nil
+}
+
+// extractArchive writes the verified tar next to the output dir and unpacks
+// it with the host tar, preserving modes and numeric owners.
+func extractArchive(ctx context.Context, useSudo bool, dir string, tarData []byte) error {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("create output dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".restore-*.tar")
+	if err != nil {
+		return fmt.Errorf("stage archive: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(tarData); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("stage archive: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("stage archive: %w", err)
+	}
+
+	owner := "--no-same-owner"
+	if useSudo || os.Geteuid() == 0 {
+		owner = "--same-owner"
+	}
+	return runHostCmd(ctx, useSudo, dir, fmt.Sprintf("tar -xpf %s --numeric-owner %s -C %s",
+		shellQuote(tmp.Name()), owner, shellQuote(dir)))
+}
+
+// fixRestoredOwnership hands gonka-nop's own files to the invoking user
+// (SUDO_UID when run via sudo); container-owned files keep the archived
+// numeric owners.
+func fixRestoredOwnership(ctx context.Context, useSudo bool, dir string, m *backup.Manifest) error {
+	if !useSudo && os.Geteuid() != 0 {
+		return nil // extracted as the invoking user already
+	}
+	uid, gid := os.Getuid(), os.Getgid()
+	if sudoUID, err := strconv.Atoi(os.Getenv("SUDO_UID")); err == nil && os.Geteuid() == 0 {
+		uid = sudoUID
+		if sudoGID, gidErr := strconv.Atoi(os.Getenv("SUDO_GID")); gidErr == nil {
+			gid = sudoGID
+		}
+	}
+
+	var quoted []string
+	for _, p := range backupConfigPaths {
+		if m.Has(p) {
+			quoted = append(quoted, shellQuote(p))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	return runHostCmd(ctx, useSudo, dir, fmt.Sprintf("chown %d:%d %s", uid, gid, strings.Join(quoted, " ")))
+}
+
+// rebaseRestoredState rewrites paths in the restored state.json that pointed
+// into the source server's output dir.
+func rebaseRestoredState(dir, oldDir string) error {
+	state, err := config.Load(dir)
+	if err != nil {
+		return fmt.Errorf("load restored state: %w", err)
+	}
+	if oldDir == "" {
+		return nil
+	}
+	// An archive from an older version may still hold the keyring password.
+	if err := state.MigrateKeyringPassword(); err != nil {
+		return err
+	}
+	rebase := func(p string) string {
+		if p == oldDir || strings.HasPrefix(p, oldDir+string(filepath.Separator)) {
+			return filepath.Join(dir, strings.TrimPrefix(p, oldDir))
+		}
+		return p
+	}
+
+	// The keyring password file lives outside the output dir and is not in
+	// the archive; the source is kept as is.
+	state.KeyringDir = rebase(state.KeyringDir)
+	return state.Save()
+}
+
+// archivedUseSudo reads UseSudo from the backed-up state.json.
+func archivedUseSudo(a *backup.Archive) bool {
+	data, ok := a.File("state.json")
+	if !ok {
+		return false
+	}
+	var s struct {
+		UseSudo bool `json:"use_sudo"`
+	}
+	return json.Unmarshal(data, &s) == nil && s.UseSudo
+}
+
+// existingIdentity lists identity paths from the manifest already present in dir.
+func existingIdentity(dir string, m *backup.Manifest) []string {
+	var existing []string
+	for _, p := range backupIdentityPaths {
+		if !m.Has(p) {
+			continue
+		}
+		if _, err := os.Lstat(filepath.Join(dir, p)); err == nil {
+			existing = append(existing, p)
+		}
+	}
+	return existing
+}
+
+// runningContainers returns the identity containers currently running. A
+// host without docker has none.
+func runningContainers(ctx context.Context, useSudo bool) ([]string, error) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return nil, nil
+	}
+	args := []string{"ps", "--format", "{{.Names}}"}
+	name := "docker"
+	if useSudo {
+		args = append([]string{"docker"}, args...)
+		name = "sudo"
+	}
+	out, err := exec.CommandContext(ctx, name, args...).Output() // #nosec G204 - args are constructed internally
+	if err != nil {
+		return nil, fmt.Errorf("docker ps: %w", err)
+	}
+	var running []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if slices.Contains(identityContainers, strings.TrimSpace(line)) {
+			running = append(running, strings.TrimSpace(line))
+		}
+	}
+	return running, nil
+}
+
+// writeNewFile writes data with mode 0600, refusing to overwrite.
+func writeNewFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("create %s: %w", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(filepath.Clean(path), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// displayManifest prints a summary of an archive.
+func displayManifest(m *backup.Manifest) {
+	boldC := color.New(color.Bold)
+	_, _ = boldC.Println("\nBackup Manifest")
+	fmt.Println(strings.Repeat("─", 60))
+	fmt.Printf("  %-12s %s\n", "Created:", m.CreatedAt.Format(time.RFC3339))
+	if m.Hostname != "" {
+		fmt.Printf("  %-12s %s\n", "Host:", m.Hostname)
+	}
+	if m.ChainID != "" {
+		fmt.Printf("  %-12s %s\n", "Chain:", m.ChainID)
+	}
+	fmt.Printf("  %-12s %s\n", "Node type:", m.NodeType)
+	fmt.Printf("  %-12s %d\n", "Files:", len(m.Files))
+	fmt.Println()
+}
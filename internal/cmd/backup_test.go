@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/inc4/gonka-nop/internal/backup"
+	"github.com/inc4/gonka-nop/internal/config"
+)
+
+func TestBackupPaths(t *testing.T) {
+	dir := t.TempDir()
+	state := config.NewState(dir)
+	state.KeyringDir = filepath.Join(dir, "keys")
+	state.ComposeFiles = []string{"docker-compose.yml", "docker-compose.custom.yml"}
+
+	paths, err := backupPaths(state)
+	if err != nil {
+		t.Fatalf("backupPaths() error: %v", err)
+	}
+	for _, want := range []string{".tmkms", "keys/keyring-file", "state.json", "docker-compose.custom.yml"} {
+		if !slices.Contains(paths, want) {
+			t.Errorf("missing %s in %v", want, paths)
+		}
+	}
+	seen := map[string]bool{}
+	for _, p := range paths {
+		if seen[p] {
+			t.Errorf("duplicate path %s", p)
+		}
+		seen[p] = true
+	}
+
+	state.KeyringDir = "/var/lib/elsewhere"
+	if _, err := backupPaths(state); err == nil {
+		t.Error("expected error for a keyring dir outside the output dir")
+	}
+}
+
+func TestBackupRestore_RoundTrip(t *testing.T) {
+	src := t.TempDir()
+	for name, content := range map[string]string{
+		".tmkms/secrets/priv_validator_key.softsign": "consensus-key",
+		".inference/config/node_key.json":            `{"priv_key":"p2p"}`,
+		".inference/keyring-file/warm.info":          "warm",
+		"config.env":                                 "KEY_NAME=warm\n",
+	} {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	state := config.NewState(src)
+	state.KeyringDir = filepath.Join(src, ".inference")
+	state.KeyringPasswordSource = "file:/etc/gonka/keyring-password"
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := backupPaths(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := hostTar(context.Background(), false, src, paths)
+	if err != nil {
+		t.Fatalf("hostTar() error: %v", err)
+	}
+	packed, manifest, err := backup.Pack(bytes.NewReader(stream), backup.Manifest{OutputDir: src})
+	if err != nil {
+		t.Fatalf("Pack() error: %v", err)
+	}
+	if missing := missingIdentity(state, manifest); len(missing) != 0 {
+		t.Errorf("missingIdentity() = %v", missing)
+	}
+	archive, err := backup.Unpack(packed)
+	if err != nil {
+		t.Fatalf("Unpack() error: %v", err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "restored")
+	if err := extractArchive(context.Background(), false, dst, archive.Tar); err != nil {
+		t.Fatalf("extractArchive() error: %v", err)
+	}
+	if got := existingIdentity(dst, archive.Manifest); len(got) != 2 {
+		t.Errorf("existingIdentity() = %v, want .tmkms and node_key.json", got)
+	}
+	if err := rebaseRestoredState(dst, src); err != nil {
+		t.Fatalf("rebaseRestoredState() error: %v", err)
+	}
+
+	restored, err := config.Load(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.KeyringDir != filepath.Join(dst, ".inference") {
+		t.Errorf("KeyringDir = %q", restored.KeyringDir)
+	}
+	if restored.KeyringPasswordSource != "file:/etc/gonka/keyring-password" {
+		t.Errorf("KeyringPasswordSource = %q", restored.KeyringPasswordSource)
+	}
+	data, err := os.ReadFile(filepath.Join(dst, ".tmkms/secrets/priv_validator_key.softsign")) // #nosec G304 - test temp dir
+	if err != nil || string(data) != "consensus-key" {
+		t.Errorf("consensus key = %q, %v", data, err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dst, ".restore-*")); len(matches) != 0 {
+		t.Errorf("staged tar left behind: %v", matches)
+	}
+}
+
+func TestHostTar_UnreadablePathFails(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can read any file")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "state.json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.env"), nil, 0o000); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := hostTar(context.Background(), false, dir, []string{"state.json", "missing.json"}); err != nil {
+		t.Errorf("missing path should be skipped: %v", err)
+	}
+	if _, err := hostTar(context.Background(), false, dir, []string{"state.json", "config.env"}); err == nil {
+		t.Error("expected an error for an unreadable path")
+	}
+}
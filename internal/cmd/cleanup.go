@@ -0,0 +1,640 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/inc4/gonka-nop/internal/config"
+	"github.com/inc4/gonka-nop/internal/phases"
+	"github.com/inc4/gonka-nop/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+const (
+	cleanupImageRepo      = "ghcr.io/product-science/"
+	cleanupKeepUpgrades   = 2 // newest Cosmovisor upgrades kept besides current
+	cleanupKeepSnapshots  = 2 // newest state-sync snapshots kept
+	cleanupCmdTimeout     = 2 * time.Minute
+	cleanupKindImage      = "image"
+	cleanupKindPath       = "path"
+	cleanupBytesPerGB     = 1 << 30
+	cleanupDanglingLabel  = "Dangling images"
+	cleanupOldImagesLabel = "Unused product-science images"
+	cleanupUpgradesLabel  = "Stale Cosmovisor upgrades"
+	cleanupSnapshotsLabel = "Old chain snapshots"
+	cleanupHFCacheLabel   = "Unused HuggingFace models"
+	cleanupCosmovisorDir  = "cosmovisor"
+	cleanupCurrentLink    = "current"
+)
+
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Recover disk space",
+	Long: `Clean up old data to recover disk space.
+
+Categories:
+  - Dangling Docker images
+  - ghcr.io/product-science images no longer referenced by the compose files
+  - Stale Cosmovisor upgrades (keeps current + latest 2) in .inference and .dapi
+  - Old state-sync snapshots in .inference/data/snapshots (keeps latest 2)
+  - HuggingFace cache entries for models other than the selected model
+
+A per-category report of reclaimable space is shown before anything is deleted.
+
+Examples:
+  gonka-nop cleanup              # Interactive cleanup
+  gonka-nop cleanup --dry-run    # Show what would be cleaned
+  gonka-nop cleanup --yes        # Clean without confirmation`,
+	RunE: runCleanup,
+}
+
+var (
+	cleanupDryRun bool
+	cleanupYes    bool
+)
+
+func init() {
+	cleanupCmd.Flags().BoolVar(&cleanupDryRun, "dry-run", false, "Only report reclaimable space, don't delete anything")
+	cleanupCmd.Flags().BoolVarP(&cleanupYes, "yes", "y", false, "Skip confirmation prompt")
+}
+
+// CleanupItem is a single reclaimable object: a Docker image or a host path.
+type CleanupItem struct {
+	Kind   string // "image" or "path"
+	Target string // image ID/reference or absolute path
+	Label  string // short display name
+	Bytes  int64
+}
+
+// CleanupCategory groups reclaimable items for the report.
+type CleanupCategory struct {
+	Name  string
+	Items []CleanupItem
+	Err   error // collection error (category is reported but skipped)
+}
+
+// TotalBytes returns the reclaimable size of the category.
+func (c *CleanupCategory) TotalBytes() int64 {
+	var total int64
+	for _, it := range c.Items {
+		total += it.Bytes
+	}
+	return total
+}
+
+func runCleanup(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+
+	state, err := config.Load(outputDir)
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	ui.Info("Measuring reclaimable disk space...")
+	categories := collectCleanupCategories(ctx, state)
+	displayCleanupReport(categories)
+
+	total := cleanupTotalBytes(categories)
+	if total == 0 {
+		ui.Success("Nothing to clean up.")
+		return nil
+	}
+
+	if cleanupDryRun {
+		ui.Info("Dry run — nothing deleted.")
+		return nil
+	}
+
+	if !cleanupYes {
+		confirm, confirmErr := ui.Confirm(fmt.Sprintf("Delete items and reclaim ~%s GB?", formatGB(total)), false)
+		if confirmErr != nil {
+			return confirmErr
+		}
+		if !confirm {
+			ui.Info("Cleanup canceled.")
+			return nil
+		}
+	}
+
+	freeBefore := diskFreeGB(ctx, state.OutputDir)
+	failed := executeCleanup(ctx, state, categories)
+	freeAfter := diskFreeGB(ctx, state.OutputDir)
+
+	if freeBefore > 0 && freeAfter > 0 {
+		ui.Success("Disk space: %d GB free (was %d GB)", freeAfter, freeBefore)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d item(s) could not be removed", failed)
+	}
+	ui.Success("Cleanup complete")
+	return nil
+}
+
+// collectCleanupCategories gathers all reclaimable items. Collection errors are
+// recorded on the category rather than aborting the whole report.
+func collectCleanupCategories(ctx context.Context, state *config.State) []CleanupCategory {
+	dangling, danglingErr := collectDanglingImages(ctx, state.UseSudo)
+	oldImages, oldErr := collectUnusedImages(ctx, state)
+	upgrades := collectStaleUpgrades(ctx, state)
+	snapshots := collectOldSnapshots(ctx, state)
+	models := collectUnusedModels(ctx, state)
+
+	return []CleanupCategory{
+		{Name: cleanupDanglingLabel, Items: dangling, Err: danglingErr},
+		{Name: cleanupOldImagesLabel, Items: oldImages, Err: oldErr},
+		{Name: cleanupUpgradesLabel, Items: upgrades},
+		{Name: cleanupSnapshotsLabel, Items: snapshots},
+		{Name: cleanupHFCacheLabel, Items: models},
+	}
+}
+
+// displayCleanupReport prints the per-category reclaimable space table.
+func displayCleanupReport(categories []CleanupCategory) {
+	boldC := color.New(color.Bold)
+	dimC := color.New(color.Faint)
+	yellowC := color.New(color.FgYellow)
+
+	_, _ = boldC.Println("\nReclaimable Disk Space")
+	fmt.Println(strings.Repeat("─", 60))
+	fmt.Printf("  %-32s %-8s %s\n", "Category", "Items", "Size (GB)")
+	fmt.Println(strings.Repeat("─", 60))
+
+	for i := range categories {
+		c := &categories[i]
+		if c.Err != nil {
+			fmt.Printf("  %-32s ", c.Name)
+			_, _ = yellowC.Printf("skipped: %v\n", c.Err)
+			continue
+		}
+		fmt.Printf("  %-32s %-8d %s\n", c.Name, len(c.Items), formatGB(c.TotalBytes()))
+		for _, it := range c.Items {
+			_, _ = dimC.Printf("      %-40s %s\n", it.Label, formatGB(it.Bytes))
+		}
+	}
+	fmt.Println(strings.Repeat("─", 60))
+	fmt.Printf("  %-32s %-8s %s\n", "Total", "", formatGB(cleanupTotalBytes(categories)))
+	fmt.Println()
+	ui.Detail("Image sizes include shared layers; actual savings may be lower.")
+}
+
+// executeCleanup removes every item and returns the number of failures.
+func executeCleanup(ctx context.Context, state *config.State, categories []CleanupCategory) int {
+	failed := 0
+	for i := range categories {
+		c := &categories[i]
+		if c.Err != nil || len(c.Items) == 0 {
+			continue
+		}
+		removed := 0
+		for _, it := range c.Items {
+			if err := removeCleanupItem(ctx, state, it); err != nil {
+				ui.Warn("Could not remove %s: %v", it.Label, err)
+				failed++
+				continue
+			}
+			removed++
+		}
+		ui.Success("%s: removed %d of %d item(s)", c.Name, removed, len(c.Items))
+	}
+	return failed
+}
+
+// removeCleanupItem deletes one image or path.
+func removeCleanupItem(ctx context.Context, state *config.State, it CleanupItem) error {
+	rmCtx, cancel := context.WithTimeout(ctx, cleanupCmdTimeout)
+	defer cancel()
+
+	switch it.Kind {
+	case cleanupKindImage:
+		_, err := dockerOutput(rmCtx, state.UseSudo, "image", "rm", it.Target)
+		return err
+	case cleanupKindPath:
+		return runHostCmd(rmCtx, state.UseSudo, state.OutputDir, fmt.Sprintf("rm -rf %s", shellQuote(it.Target)))
+	default:
+		return fmt.Errorf("unknown cleanup item kind %q", it.Kind)
+	}
+}
+
+func cleanupTotalBytes(categories []CleanupCategory) int64 {
+	var total int64
+	for i := range categories {
+		total += categories[i].TotalBytes()
+	}
+	return total
+}
+
+// --- Docker images ---
+
+// dockerImage is one row of `docker image ls`.
+type dockerImage struct {
+	Ref   string // repository:tag
+	ID    string
+	Bytes int64
+}
+
+// collectDanglingImages lists untagged images.
+func collectDanglingImages(ctx context.Context, useSudo bool) ([]CleanupItem, error) {
+	out, err := dockerOutput(ctx, useSudo, "image", "ls", "--filter", "dangling=true",
+		"--format", "{{.Repository}}:{{.Tag}}\t{{.ID}}\t{{.Size}}")
+	if err != nil {
+		return nil, err
+	}
+	images := parseDockerImageList(out)
+	items := make([]CleanupItem, 0, len(images))
+	for _, img := range images {
+		items = append(items, CleanupItem{Kind: cleanupKindImage, Target: img.ID, Label: img.ID, Bytes: img.Bytes})
+	}
+	return items, nil
+}
+
+// collectUnusedImages lists product-science images not referenced by any compose file.
+func collectUnusedImages(ctx context.Context, state *config.State) ([]CleanupItem, error) {
+	out, err := dockerOutput(ctx, state.UseSudo, "image", "ls", "--filter", "reference="+cleanupImageRepo+"*",
+		"--format", "{{.Repository}}:{{.Tag}}\t{{.ID}}\t{{.Size}}")
+	if err != nil {
+		return nil, err
+	}
+
+	used, err := composeImageRefs(state)
+	if err != nil {
+		return nil, err
+	}
+
+	unused := selectUnusedImages(parseDockerImageList(out), used)
+	items := make([]CleanupItem, 0, len(unused))
+	for _, img := range unused {
+		items = append(items, CleanupItem{Kind: cleanupKindImage, Target: img.Ref, Label: img.Ref, Bytes: img.Bytes})
+	}
+	return items, nil
+}
+
+// parseDockerImageList parses "ref\tid\tsize" lines.
+func parseDockerImageList(out string) []dockerImage {
+	var images []dockerImage
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		size, err := parseDockerSize(fields[2])
+		if err != nil {
+			continue
+		}
+		images = append(images, dockerImage{Ref: fields[0], ID: fields[1], Bytes: size})
+	}
+	return images
+}
+
+// selectUnusedImages returns product-science images whose ref is not in use.
+// Untagged entries are left to the dangling category.
+func selectUnusedImages(images []dockerImage, used map[string]bool) []dockerImage {
+	var unused []dockerImage
+	for _, img := range images {
+		if !strings.HasPrefix(img.Ref, cleanupImageRepo) || strings.HasSuffix(img.Ref, ":<none>") {
+			continue
+		}
+		if used[img.Ref] {
+			continue
+		}
+		unused = append(unused, img)
+	}
+	return unused
+}
+
+// dockerSizeRe matches sizes printed by docker, e.g. "1.23GB", "512MB", "12.3kB".
+var dockerSizeRe = regexp.MustCompile(`^([0-9.]+)\s*([kKMGT]?B)$`)
+
+// parseDockerSize converts a docker size string to bytes (docker uses SI units).
+func parseDockerSize(s string) (int64, error) {
+	m := dockerSizeRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("unrecognized size %q", s)
+	}
+	val, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse size %q: %w", s, err)
+	}
+	mult := map[string]float64{
+		"B": 1, "kB": 1e3, "KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12,
+	}[m[2]]
+	return int64(val * mult), nil
+}
+
+// composeImageRe matches "image: <ref>" lines in compose files.
+var composeImageRe = regexp.MustCompile(`(?m)^\s*image:\s*["']?([^\s"'#]+)`)
+
+// composeImageRefs returns the image references used by the deployment's compose files.
+func composeImageRefs(state *config.State) (map[string]bool, error) {
+	files := state.ComposeFiles
+	if len(files) == 0 {
+		files = []string{"docker-compose.yml", "docker-compose.mlnode.yml"}
+	}
+
+	used := make(map[string]bool)
+	found := false
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(state.OutputDir, f)) // #nosec G304 - path from trusted state
+		if err != nil {
+			continue
+		}
+		found = true
+		for _, ref := range parseComposeImages(string(data)) {
+			used[ref] = true
+		}
+	}
+	if !found {
+		// Without compose files every image would look unused.
+		return nil, fmt.Errorf("no compose files in %s", state.OutputDir)
+	}
+	return used, nil
+}
+
+// parseComposeImages extracts image references from compose file content.
+func parseComposeImages(content string) []string {
+	var refs []string
+	for _, m := range composeImageRe.FindAllStringSubmatch(content, -1) {
+		refs = append(refs, m[1])
+	}
+	return refs
+}
+
+// --- Cosmovisor upgrades ---
+
+// upgradeEntry is one directory under cosmovisor/upgrades.
+type upgradeEntry struct {
+	Name    string
+	ModTime time.Time
+}
+
+// collectStaleUpgrades lists Cosmovisor upgrade directories that are neither
+// current nor among the newest cleanupKeepUpgrades, for both node and API.
+func collectStaleUpgrades(ctx context.Context, state *config.State) []CleanupItem {
+	var items []CleanupItem
+	for _, svcDir := range []string{".inference", ".dapi"} {
+		cosmoDir := filepath.Join(state.OutputDir, svcDir, cleanupCosmovisorDir)
+		entries, err := os.ReadDir(filepath.Join(cosmoDir, "upgrades"))
+		if err != nil {
+			continue
+		}
+
+		var upgrades []upgradeEntry
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			info, infoErr := e.Info()
+			if infoErr != nil {
+				continue
+			}
+			upgrades = append(upgrades, upgradeEntry{Name: e.Name(), ModTime: info.ModTime()})
+		}
+
+		current := currentUpgradeName(filepath.Join(cosmoDir, cleanupCurrentLink))
+		for _, name := range selectStaleUpgrades(upgrades, current, cleanupKeepUpgrades) {
+			path := filepath.Join(cosmoDir, "upgrades", name)
+			items = append(items, CleanupItem{
+				Kind:   cleanupKindPath,
+				Target: path,
+				Label:  filepath.Join(svcDir, cleanupCosmovisorDir, "upgrades", name),
+				Bytes:  pathSizeBytes(ctx, state.UseSudo, path),
+			})
+		}
+	}
+	return items
+}
+
+// currentUpgradeName returns the upgrade the Cosmovisor "current" symlink points at,
+// or "" when it points at genesis or cannot be read.
+func currentUpgradeName(symlinkPath string) string {
+	target, err := os.Readlink(symlinkPath)
+	if err != nil {
+		return ""
+	}
+	target = filepath.Clean(target)
+	if filepath.Base(filepath.Dir(target)) != "upgrades" {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+// selectStaleUpgrades returns upgrades that can be removed: everything except
+// the current one and the newest `keep` by modification time.
+func selectStaleUpgrades(upgrades []upgradeEntry, current string, keep int) []string {
+	sorted := make([]upgradeEntry, len(upgrades))
+	copy(sorted, upgrades)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ModTime.After(sorted[j].ModTime)
+	})
+
+	var stale []string
+	for i, u := range sorted {
+		if u.Name == current || i < keep {
+			continue
+		}
+		stale = append(stale, u.Name)
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// --- Chain snapshots ---
+
+// collectOldSnapshots lists snapshot height directories beyond the newest cleanupKeepSnapshots.
+func collectOldSnapshots(ctx context.Context, state *config.State) []CleanupItem {
+	snapDir := filepath.Join(state.OutputDir, ".inference", "data", "snapshots")
+	entries, err := os.ReadDir(snapDir)
+	if err != nil {
+		return nil
+	}
+
+	var heights []string
+	for _, e := range entries {
+		if e.IsDir() {
+			heights = append(heights, e.Name())
+		}
+	}
+
+	var items []CleanupItem
+	for _, h := range selectOldSnapshots(heights, cleanupKeepSnapshots) {
+		path := filepath.Join(snapDir, h)
+		items = append(items, CleanupItem{
+			Kind:   cleanupKindPath,
+			Target: path,
+			Label:  "snapshot at height " + h,
+			Bytes:  pathSizeBytes(ctx, state.UseSudo, path),
+		})
+	}
+	return items
+}
+
+// selectOldSnapshots returns numeric snapshot directories other than the newest `keep`.
+// Non-numeric entries (e.g. metadata.db) are never selected.
+func selectOldSnapshots(names []string, keep int) []string {
+	heights := make([]int64, 0, len(names))
+	for _, n := range names {
+		h, err := strconv.ParseInt(n, 10, 64)
+		if err != nil {
+			continue
+		}
+		heights = append(heights, h)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] > heights[j] })
+
+	if len(heights) <= keep {
+		return nil
+	}
+	old := make([]string, 0, len(heights)-keep)
+	for _, h := range heights[keep:] {
+		old = append(old, strconv.FormatInt(h, 10))
+	}
+	return old
+}
+
+// --- HuggingFace cache ---
+
+// collectUnusedModels lists HF hub cache entries for models other than state.SelectedModel.
+func collectUnusedModels(ctx context.Context, state *config.State) []CleanupItem {
+	if state.SelectedModel == "" {
+		// Without a selected model every cached model would look unused.
+		return nil
+	}
+	hfHome := state.HFHome
+	if hfHome == "" {
+		hfHome = phases.DefaultHFHome
+	}
+	hubDir := filepath.Join(hfHome, "hub")
+	entries, err := os.ReadDir(hubDir)
+	if err != nil {
+		return nil
+	}
+
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir() {
+			dirs = append(dirs, e.Name())
+		}
+	}
+
+	var items []CleanupItem
+	for _, d := range selectUnusedModelDirs(dirs, state.SelectedModel) {
+		path := filepath.Join(hubDir, d)
+		items = append(items, CleanupItem{
+			Kind:   cleanupKindPath,
+			Target: path,
+			Label:  hfCacheDirToModel(d),
+			Bytes:  pathSizeBytes(ctx, state.UseSudo, path),
+		})
+	}
+	return items
+}
+
+// selectUnusedModelDirs returns "models--*" cache dirs not belonging to the selected model.
+func selectUnusedModelDirs(dirs []string, selectedModel string) []string {
+	keep := hfModelCacheDir(selectedModel)
+	var unused []string
+	for _, d := range dirs {
+		if !strings.HasPrefix(d, "models--") || d == keep {
+			continue
+		}
+		unused = append(unused, d)
+	}
+	return unused
+}
+
+// hfModelCacheDir returns the HF hub cache directory name for a model ID.
+// "Qwen/QwQ-32B" -> "models--Qwen--QwQ-32B".
+func hfModelCacheDir(model string) string {
+	return "models--" + strings.ReplaceAll(model, "/", "--")
+}
+
+// hfCacheDirToModel is the inverse of hfModelCacheDir.
+func hfCacheDirToModel(dir string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(dir, "models--"), "--", "/")
+}
+
+// --- Helpers ---
+
+// pathSizeBytes returns the on-disk size of a path. Falls back to sudo du
+// when the tree is not readable (container-owned files).
+func pathSizeBytes(ctx context.Context, useSudo bool, path string) int64 {
+	var total int64
+	walkErr := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, infoErr := d.Info()
+			if infoErr == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	if walkErr == nil || !useSudo {
+		return total
+	}
+
+	duCtx, cancel := context.WithTimeout(ctx, cleanupCmdTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(duCtx, "sudo", "du", "-sb", path).Output() // #nosec G204
+	if err != nil {
+		return total
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return total
+	}
+	size, parseErr := strconv.ParseInt(fields[0], 10, 64)
+	if parseErr != nil {
+		return total
+	}
+	return size
+}
+
+// dockerOutput runs a docker CLI command and returns stdout.
+func dockerOutput(ctx context.Context, useSudo bool, args ...string) (string, error) {
+	var cmd *exec.Cmd
+	if useSudo {
+		cmd = exec.CommandContext(ctx, "sudo", append([]string{"docker"}, args...)...) // #nosec G204 - args are constructed internally
+	} else {
+		cmd = exec.CommandContext(ctx, "docker", args...) // #nosec G204 - args are constructed internally
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("docker %s: %w\n%s", strings.Join(args, " "), err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("docker %s: %w", strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
+
+// diskFreeGB returns free space on the filesystem holding path, or 0 if unknown.
+func diskFreeGB(ctx context.Context, path string) int {
+	dfCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(dfCtx, "df", "--output=avail", "-BG", path).Output() // #nosec G204
+	if err != nil {
+		return 0
+	}
+	gb, err := phases.ParseDiskFreeGB(string(out))
+	if err != nil {
+		return 0
+	}
+	return gb
+}
+
+// formatGB formats a byte count as GB with two decimals.
+func formatGB(b int64) string {
+	return fmt.Sprintf("%.2f", float64(b)/cleanupBytesPerGB)
+}
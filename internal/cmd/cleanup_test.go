@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inc4/gonka-nop/internal/config"
+)
+
+const testQwenModel = "Qwen/QwQ-32B"
+
+func TestParseDockerSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{input: "1.5GB", want: 1500000000},
+		{input: "512MB", want: 512000000},
+		{input: "12.3kB", want: 12300},
+		{input: "0B", want: 0},
+		{input: "2TB", want: 2000000000000},
+		{input: "huge", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseDockerSize(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseDockerSize(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDockerImageList(t *testing.T) {
+	out := "ghcr.io/product-science/inferenced:0.2.9\tabc123\t1.2GB\n" +
+		"<none>:<none>\tdef456\t300MB\n" +
+		"malformed line\n"
+	images := parseDockerImageList(out)
+	if len(images) != 2 {
+		t.Fatalf("expected 2 images, got %d: %+v", len(images), images)
+	}
+	if images[0].Ref != "ghcr.io/product-science/inferenced:0.2.9" || images[0].ID != "abc123" || images[0].Bytes != 1200000000 {
+		t.Errorf("images[0] = %+v", images[0])
+	}
+}
+
+func TestSelectUnusedImages(t *testing.T) {
+	images := []dockerImage{
+		{Ref: "ghcr.io/product-science/inferenced:0.2.9", Bytes: 1},
+		{Ref: "ghcr.io/product-science/inferenced:0.2.10", Bytes: 2},
+		{Ref: "ghcr.io/product-science/mlnode:<none>", Bytes: 3},
+		{Ref: "nginx:1.28.0", Bytes: 4},
+	}
+	used := map[string]bool{"ghcr.io/product-science/inferenced:0.2.10": true}
+
+	got := selectUnusedImages(images, used)
+	if len(got) != 1 || got[0].Ref != "ghcr.io/product-science/inferenced:0.2.9" {
+		t.Errorf("selectUnusedImages = %+v", got)
+	}
+}
+
+func TestParseComposeImages(t *testing.T) {
+	content := `services:
+  node:
+    image: ghcr.io/product-science/inferenced:0.2.10
+  # image: ghcr.io/product-science/old:1
+  proxy:
+    image: "ghcr.io/product-science/proxy:0.2.10"
+`
+	got := parseComposeImages(content)
+	want := "ghcr.io/product-science/inferenced:0.2.10,ghcr.io/product-science/proxy:0.2.10"
+	if strings.Join(got, ",") != want {
+		t.Errorf("parseComposeImages = %v, want %s", got, want)
+	}
+}
+
+func TestComposeImageRefs_NoComposeFiles(t *testing.T) {
+	state := &config.State{OutputDir: t.TempDir()}
+	if _, err := composeImageRefs(state); err == nil {
+		t.Error("expected error when no compose files exist")
+	}
+}
+
+func TestSelectStaleUpgrades(t *testing.T) {
+	now := time.Now()
+	upgrades := []upgradeEntry{
+		{Name: "v0.2.7", ModTime: now.Add(-4 * time.Hour)},
+		{Name: "v0.2.8", ModTime: now.Add(-3 * time.Hour)},
+		{Name: "v0.2.9", ModTime: now.Add(-2 * time.Hour)},
+		{Name: "v0.2.10", ModTime: now.Add(-1 * time.Hour)},
+	}
+
+	tests := []struct {
+		name    string
+		current string
+		keep    int
+		want    string
+	}{
+		{name: "keep newest two", current: "v0.2.10", keep: 2, want: "v0.2.7,v0.2.8"},
+		{name: "current is old", current: "v0.2.7", keep: 2, want: "v0.2.8"},
+		{name: "keep all", current: "", keep: 4, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(selectStaleUpgrades(upgrades, tt.current, tt.keep), ",")
+			if got != tt.want {
+				t.Errorf("selectStaleUpgrades = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCurrentUpgradeName(t *testing.T) {
+	dir := t.TempDir()
+
+	upgradeLink := filepath.Join(dir, "current")
+	if err := os.Symlink("upgrades/v0.2.10", upgradeLink); err != nil {
+		t.Fatal(err)
+	}
+	if got := currentUpgradeName(upgradeLink); got != "v0.2.10" {
+		t.Errorf("currentUpgradeName = %q, want v0.2.10", got)
+	}
+
+	genesisLink := filepath.Join(dir, "current-genesis")
+	if err := os.Symlink("genesis", genesisLink); err != nil {
+		t.Fatal(err)
+	}
+	if got := currentUpgradeName(genesisLink); got != "" {
+		t.Errorf("currentUpgradeName(genesis) = %q, want empty", got)
+	}
+}
+
+func TestSelectOldSnapshots(t *testing.T) {
+	names := []string{"1000", "3000", "metadata.db", "2000", "4000"}
+	got := strings.Join(selectOldSnapshots(names, 2), ",")
+	if got != "2000,1000" {
+		t.Errorf("selectOldSnapshots = %q, want 2000,1000", got)
+	}
+	if old := selectOldSnapshots([]string{"1000"}, 2); len(old) != 0 {
+		t.Errorf("expected nothing to remove, got %v", old)
+	}
+}
+
+func TestSelectUnusedModelDirs(t *testing.T) {
+	dirs := []string{"models--Qwen--QwQ-32B", "models--Qwen--Qwen3-235B-A22B-Instruct-2507-FP8", "datasets--foo", ".locks"}
+	got := selectUnusedModelDirs(dirs, testQwenModel)
+	if len(got) != 1 || got[0] != "models--Qwen--Qwen3-235B-A22B-Instruct-2507-FP8" {
+		t.Errorf("selectUnusedModelDirs = %v", got)
+	}
+	if hfCacheDirToModel(hfModelCacheDir(testQwenModel)) != testQwenModel {
+		t.Error("hfCacheDirToModel should invert hfModelCacheDir")
+	}
+}
+
+func TestCollectUnusedModels(t *testing.T) {
+	hfHome := t.TempDir()
+	for _, m := range []string{"models--Qwen--QwQ-32B", "models--Old--Model"} {
+		dir := filepath.Join(hfHome, "hub", m, "blobs")
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "weights"), make([]byte, 1024), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	state := &config.State{OutputDir: t.TempDir(), HFHome: hfHome, SelectedModel: testQwenModel}
+	items := collectUnusedModels(t.Context(), state)
+	if len(items) != 1 {
+		t.Fatalf("expected 1 item, got %+v", items)
+	}
+	if items[0].Label != "Old/Model" || items[0].Bytes != 1024 || items[0].Kind != cleanupKindPath {
+		t.Errorf("item = %+v", items[0])
+	}
+
+	// No selected model: nothing is considered unused
+	state.SelectedModel = ""
+	if items := collectUnusedModels(t.Context(), state); len(items) != 0 {
+		t.Errorf("expected no items without selected model, got %+v", items)
+	}
+}
+
+func TestCleanupTotalBytes(t *testing.T) {
+	categories := []CleanupCategory{
+		{Name: "a", Items: []CleanupItem{{Bytes: 1 << 30}, {Bytes: 1 << 29}}},
+		{Name: "b", Items: []CleanupItem{{Bytes: 1 << 29}}},
+	}
+	if got := formatGB(cleanupTotalBytes(categories)); got != "2.00" {
+		t.Errorf("total = %s GB, want 2.00", got)
+	}
+}
@@ -0,0 +1,575 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/inc4/gonka-nop/internal/config"
+	"github.com/inc4/gonka-nop/internal/phases"
+	"github.com/inc4/gonka-nop/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var configYes bool
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and reconcile the generated configuration files",
+	Long: `Compare the files setup generates from state.json with the ones on disk.
+
+The generated files are config.env, node-config.json, docker-compose.yml,
+and on nodes with an ML node nginx.conf and docker-compose.mlnode.yml
+(plus docker-compose.env-override.yml on testnet). The keyring password
+env file is not compared: it holds a secret and lives outside the output dir.
+
+Subcommands:
+  diff        - Show a unified diff between disk and what state.json generates
+  regenerate  - Rewrite the files that differ, after a backup
+  get         - Show editable settings, or one value
+  set         - Change a setting and rewrite the files it affects
+  unset       - Restore a setting's default and rewrite the files it affects`,
+}
+
+var configDiffCmd = &cobra.Command{
+	Use:   "diff [file...]",
+	Short: "Show how the files on disk differ from what state.json generates",
+	Long: `Render every generated file in memory and show a unified diff against
+the file on disk ("-" is on disk, "+" is generated). Hand edits and tag
+bumps from 'gonka-nop update' show up here.
+
+Exit code is 0 when everything matches and 1 when a file differs.`,
+	RunE: runConfigDiff,
+}
+
+var configRegenerateCmd = &cobra.Command{
+	Use:   "regenerate [file...]",
+	Short: "Rewrite the generated files that differ from state.json",
+	Long: `Rewrite only the generated files that differ from what state.json
+produces. The current versions are copied to <output>/backups/config-<time>/
+first. Containers are not touched: the services that must be recreated for
+the new files to take effect are listed instead.`,
+	RunE: runConfigRegenerate,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "Show editable settings, or the value of one",
+	Long: `Without a key, list every setting 'config set' accepts with its current
+value. With a key, print only the value (empty when unset), for scripts.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runConfigGet,
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a setting and rewrite the generated files it affects",
+	Long: `Validate and store a setting in state.json, then rewrite only the
+generated files whose content changes (the previous versions go to
+<output>/backups/config-<time>/) and list the services to recreate.
+
+Examples:
+  gonka-nop config set public_ip 203.0.113.10
+  gonka-nop config set gpu_memory_util 0.92
+  gonka-nop config set attention_backend FLASH_ATTN`,
+	Args: cobra.ExactArgs(2),
+	RunE: runConfigSet,
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Restore a setting's default and rewrite the generated files it affects",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runConfigUnset,
+}
+
+// settingsNeedingFirewall change the port rules planned by 'firewall apply'.
+var settingsNeedingFirewall = map[string]bool{
+	"network_node_ip": true, "poc_port": true, "inference_port": true, "tls_domain": true,
+}
+
+// settingsInPublicURL make up the URL registered on-chain.
+var settingsInPublicURL = map[string]bool{"public_ip": true, "api_port": true, "tls_domain": true}
+
+func init() {
+	configCmd.AddCommand(configDiffCmd)
+	configCmd.AddCommand(configRegenerateCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	configRegenerateCmd.Flags().BoolVarP(&configYes, "yes", "y", false, "Skip the confirmation prompt")
+}
+
+func loadConfigState() (*config.State, error) {
+	state, err := config.Load(outputDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	if state.OutputDir == "" {
+		return nil, fmt.Errorf("no deployment found in %s — run 'gonka-nop setup' first", outputDir)
+	}
+	return state, nil
+}
+
+// loadConfigDrift loads state and compares the generated files with disk,
+// limited to the named files when any are given.
+func loadConfigDrift(files []string) (*config.State, *phases.ConfigDrift, error) {
+	state, err := loadConfigState()
+	if err != nil {
+		return nil, nil, err
+	}
+	drift, err := phases.DetectConfigDrift(state)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(files) == 0 {
+		return state, drift, nil
+	}
+
+	known := map[string]bool{}
+	for _, f := range drift.Files {
+		known[f.Name] = true
+	}
+	keep := map[string]bool{}
+	for _, f := range files {
+		name := filepath.Base(f)
+		if !known[name] {
+			return nil, nil, fmt.Errorf("%s is not a generated file for this node", f)
+		}
+		keep[name] = true
+	}
+	var changes []phases.ConfigChange
+	for _, c := range drift.Changes {
+		if keep[c.Name] {
+			changes = append(changes, c)
+		}
+	}
+	drift.Changes = changes
+	return state, drift, nil
+}
+
+func runConfigDiff(cmd *cobra.Command, args []string) error {
+	_, drift, err := loadConfigDrift(args)
+	if err != nil {
+		return err
+	}
+	if drift.InSync() {
+		ui.Success("Generated files match state.json")
+		return nil
+	}
+
+	for _, c := range drift.Changes {
+		printDiff(unifiedDiff(c.Name, c.Current, c.Desired, c.Missing()))
+	}
+	ui.Warn("%d file(s) differ from state.json — run 'gonka-nop config regenerate' to rewrite them", len(drift.Changes))
+	if services := drift.Services(); len(services) > 0 {
+		ui.Detail("Services to recreate after regenerating: %s", strings.Join(services, ", "))
+	}
+
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	return &ExitCodeError{Code: 1, Err: fmt.Errorf("configuration drift in %d file(s)", len(drift.Changes))}
+}
+
+func runConfigRegenerate(_ *cobra.Command, args []string) error {
+	state, drift, err := loadConfigDrift(args)
+	if err != nil {
+		return err
+	}
+	if drift.InSync() {
+		ui.Success("Generated files already match state.json")
+		return nil
+	}
+
+	ui.Header("Files to Rewrite")
+	for _, c := range drift.Changes {
+		if c.Missing() {
+			ui.Detail("%s (missing)", c.Name)
+			continue
+		}
+		added, removed := diffStat(c.Current, c.Desired)
+		ui.Detail("%s (+%d -%d)", c.Name, added, removed)
+	}
+	services := drift.Services()
+
+	if !configYes {
+		proceed, confirmErr := ui.Confirm("Rewrite these files? Current versions are backed up first.", false)
+		if confirmErr != nil {
+			return confirmErr
+		}
+		if !proceed {
+			ui.Info("Canceled.")
+			return nil
+		}
+	}
+
+	if err := writeConfigChanges(state.OutputDir, drift.Changes); err != nil {
+		return err
+	}
+	printRecreateHint(state, services)
+	return nil
+}
+
+// writeConfigChanges backs up the current files, then writes the desired
+// content. Current must hold what is on disk.
+func writeConfigChanges(dir string, changes []phases.ConfigChange) error {
+	backupDir, err := backupConfigFiles(dir, changes)
+	if err != nil {
+		return fmt.Errorf("backup failed, nothing was rewritten: %w", err)
+	}
+	if backupDir != "" {
+		ui.Success("Backup written: %s", backupDir)
+	}
+	for _, c := range changes {
+		if err := os.WriteFile(filepath.Join(dir, c.Name), c.Desired, 0600); err != nil {
+			return fmt.Errorf("write %s: %w", c.Name, err)
+		}
+		ui.Success("Rewrote %s", c.Name)
+	}
+	return nil
+}
+
+func printRecreateHint(state *config.State, services []string) {
+	if len(services) == 0 {
+		ui.Info("No running service reads the changed files; nothing to recreate")
+		return
+	}
+	ui.Info("Recreate these services to apply the changes: %s", strings.Join(services, ", "))
+	ui.Detail("cd %s && docker compose %s up -d --force-recreate %s",
+		state.OutputDir, composeFileArgs(state), strings.Join(services, " "))
+	if slices.Contains(services, "api") {
+		printKeyringEnvHint(state)
+	}
+}
+
+// printKeyringEnvHint reminds that a hand-run compose up must pass the
+// keyring password when no file holds it.
+func printKeyringEnvHint(state *config.State) {
+	if state.KeyringPassthrough() {
+		ui.Detail("Export KEYRING_PASSWORD first: the api service reads it from the environment")
+	}
+}
+
+func runConfigGet(_ *cobra.Command, args []string) error {
+	state, err := loadConfigState()
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		st, err := config.LookupSetting(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(st.Get(state))
+		return nil
+	}
+
+	boldC := color.New(color.Bold)
+	faintC := color.New(color.Faint)
+	_, _ = boldC.Println("\nSettings")
+	fmt.Println(strings.Repeat("─", 60))
+	fmt.Printf("  %-20s %-24s %s\n", "KEY", "VALUE", "DESCRIPTION")
+	for _, st := range config.Settings() {
+		fmt.Printf("  %-20s ", st.Key)
+		if v := st.Get(state); v != "" {
+			fmt.Printf("%-24s", v)
+		} else {
+			_, _ = faintC.Printf("%-24s", "(default)")
+		}
+		fmt.Printf(" %s\n", st.Help)
+	}
+	fmt.Println()
+	return nil
+}
+
+func runConfigSet(_ *cobra.Command, args []string) error {
+	return changeSetting(args[0], func(st config.Setting, state *config.State) error {
+		return st.Set(state, args[1])
+	})
+}
+
+func runConfigUnset(_ *cobra.Command, args []string) error {
+	return changeSetting(args[0], func(st config.Setting, state *config.State) error {
+		return st.Unset(state)
+	})
+}
+
+// changeSetting applies one setting change, saves state, rewrites the
+// generated files whose rendering changes and reports what to recreate. Files
+// the change does not affect are left alone, hand edits included.
+func changeSetting(key string, change func(config.Setting, *config.State) error) error {
+	state, err := loadConfigState()
+	if err != nil {
+		return err
+	}
+	st, err := config.LookupSetting(key)
+	if err != nil {
+		return err
+	}
+
+	before, err := phases.RenderConfigFiles(state)
+	if err != nil {
+		return err
+	}
+	old := st.Get(state)
+	if err := change(st, state); err != nil {
+		return err
+	}
+	value := st.Get(state)
+	if value == old {
+		ui.Info("%s is already %s", key, settingLabel(value))
+		return nil
+	}
+
+	after, err := phases.RenderConfigFiles(state)
+	if err != nil {
+		return err
+	}
+	drift := phases.CompareConfigFiles(before, after)
+	changes, err := withDiskContent(state.OutputDir, drift.Changes)
+	if err != nil {
+		return err
+	}
+	// State first: files rewritten for a value that never reached state.json
+	// would show as drift, and the next regenerate would revert them. The
+	// other way round, regenerate finishes the job.
+	if err := state.Save(); err != nil {
+		return fmt.Errorf("save state, no file was rewritten: %w", err)
+	}
+	if err := writeConfigChanges(state.OutputDir, changes); err != nil {
+		return fmt.Errorf("%s saved, but %w; run 'gonka-nop config regenerate' to finish", key, err)
+	}
+	ui.Success("%s: %s → %s", key, settingLabel(old), settingLabel(value))
+
+	if len(changes) == 0 {
+		ui.Info("No generated file depends on %s", key)
+	} else {
+		printRecreateHint(state, drift.Services())
+	}
+	if settingsInPublicURL[key] && state.NodeRegistered {
+		ui.Warn("The node is registered on-chain with %s; update the registered URL to match", state.PublicURL)
+	}
+	if settingsNeedingFirewall[key] && state.FirewallConfigured {
+		ui.Detail("Run 'gonka-nop firewall apply' to update the port rules")
+	}
+	return nil
+}
+
+// withDiskContent replaces Current with the file on disk, which may carry
+// hand edits the earlier rendering does not have.
+func withDiskContent(dir string, changes []phases.ConfigChange) ([]phases.ConfigChange, error) {
+	out := make([]phases.ConfigChange, 0, len(changes))
+	for _, c := range changes {
+		current, err := os.ReadFile(filepath.Join(dir, c.Name)) // #nosec G304 - generated file in the output dir
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("read %s: %w", c.Name, err)
+		}
+		c.Current = current
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func settingLabel(v string) string {
+	if v == "" {
+		return "(default)"
+	}
+	return v
+}
+
+// backupConfigFiles copies the existing versions of the changed files to
+// <output>/backups/config-<timestamp>/. Returns "" when none exist yet.
+func backupConfigFiles(dir string, changes []phases.ConfigChange) (string, error) {
+	backupDir := filepath.Join(dir, resetBackupDir, "config-"+time.Now().UTC().Format("20060102-150405"))
+	written := false
+	for _, c := range changes {
+		if c.Missing() {
+			continue
+		}
+		if !written {
+			if err := os.MkdirAll(backupDir, 0750); err != nil {
+				return "", fmt.Errorf("create backup dir: %w", err)
+			}
+			written = true
+		}
+		if err := os.WriteFile(filepath.Join(backupDir, c.Name), c.Current, 0600); err != nil {
+			return "", fmt.Errorf("backup %s: %w", c.Name, err)
+		}
+	}
+	if !written {
+		return "", nil
+	}
+	return backupDir, nil
+}
+
+// composeFileArgs returns the -f flags for the node's compose files.
+func composeFileArgs(state *config.State) string {
+	args := make([]string, 0, len(state.ComposeFiles))
+	for _, f := range state.ComposeFiles {
+		args = append(args, "-f "+f)
+	}
+	return strings.Join(args, " ")
+}
+
+// printDiff prints a unified diff, colored when stdout is a terminal.
+func printDiff(diff string) {
+	redC := color.New(color.FgRed)
+	greenC := color.New(color.FgGreen)
+	cyanC := color.New(color.FgCyan)
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+			_, _ = color.New(color.Bold).Print(line)
+		case strings.HasPrefix(line, "@@"):
+			_, _ = cyanC.Print(line)
+		case strings.HasPrefix(line, "-"):
+			_, _ = redC.Print(line)
+		case strings.HasPrefix(line, "+"):
+			_, _ = greenC.Print(line)
+		default:
+			fmt.Print(line)
+		}
+	}
+}
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// unifiedDiff returns a unified diff from the file on disk (a) to the
+// generated one (b), or "" when they are equal.
+func unifiedDiff(name string, a, b []byte, missing bool) string {
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	// Line numbers in a and b before each op, for the hunk headers
+	aLine := make([]int, len(ops)+1)
+	bLine := make([]int, len(ops)+1)
+	for k, op := range ops {
+		aLine[k+1], bLine[k+1] = aLine[k], bLine[k]
+		if op.kind != '+' {
+			aLine[k+1]++
+		}
+		if op.kind != '-' {
+			bLine[k+1]++
+		}
+	}
+
+	var sb strings.Builder
+	for start := 0; start < len(ops); {
+		c := start
+		for c < len(ops) && ops[c].kind == ' ' {
+			c++
+		}
+		if c == len(ops) {
+			break
+		}
+		// Extend the hunk while changes are close enough to share context
+		last := c
+		for k := c; k < len(ops) && k-last <= 2*diffContext; k++ {
+			if ops[k].kind != ' ' {
+				last = k
+			}
+		}
+		lo := max(c-diffContext, start)
+		hi := min(last+diffContext+1, len(ops))
+
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aLine[lo], aLine[hi]-aLine[lo]), hunkRange(bLine[lo], bLine[hi]-bLine[lo]))
+		for _, op := range ops[lo:hi] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.line)
+			sb.WriteByte('\n')
+		}
+		start = hi
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+
+	from := "a/" + name
+	if missing {
+		from = "/dev/null"
+	}
+	return fmt.Sprintf("--- %s\n+++ b/%s\n%s", from, name, sb.String())
+}
+
+// hunkRange formats "start,count"; start is 1-based, or the line before an
+// empty range.
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// diffLines computes a line edit script with a longest common subsequence.
+// Generated files are a few hundred lines, so the quadratic table is fine.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// diffStat counts added and removed lines.
+func diffStat(a, b []byte) (added, removed int) {
+	for _, op := range diffLines(splitLines(a), splitLines(b)) {
+		switch op.kind {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	return added, removed
+}
+
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
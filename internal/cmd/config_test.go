@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/inc4/gonka-nop/internal/config"
+	"github.com/inc4/gonka-nop/internal/phases"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n"
+	tests := []struct {
+		name    string
+		a, b    string
+		missing bool
+		want    string
+	}{
+		{"equal", a, a, false, ""},
+		{
+			name: "one change",
+			a:    a,
+			b:    strings.Replace(a, "\n8\n", "\neight\n", 1),
+			want: "--- a/f\n+++ b/f\n@@ -5,7 +5,7 @@\n 5\n 6\n 7\n-8\n+eight\n 9\n 10\n 11\n",
+		},
+		{
+			name: "two hunks",
+			a:    a,
+			b:    strings.Replace(strings.Replace(a, "1\n", "0\n1\n", 1), "\n15\n", "\n", 1),
+			want: "--- a/f\n+++ b/f\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -12,4 +13,3 @@\n 12\n 13\n 14\n-15\n",
+		},
+		{
+			name:    "new file",
+			b:       "x\ny\n",
+			missing: true,
+			want:    "--- /dev/null\n+++ b/f\n@@ -0,0 +1,2 @@\n+x\n+y\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("f", []byte(tt.a), []byte(tt.b), tt.missing); got != tt.want {
+				t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// driftedState writes a full node's generated files, then hand-edits
+// docker-compose.yml and removes nginx.conf.
+func driftedState(t *testing.T) *config.State {
+	t.Helper()
+	dir := t.TempDir()
+	outputDir = dir
+	state := config.NewState(dir)
+	state.PublicIP = "203.0.113.5"
+	state.ComposeFiles = []string{"docker-compose.yml", "docker-compose.mlnode.yml"}
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save state: %v", err)
+	}
+	files, err := phases.RenderConfigFiles(state)
+	if err != nil {
+		t.Fatalf("RenderConfigFiles: %v", err)
+	}
+	for _, f := range files {
+		content := f.Content
+		if f.Name == "nginx.conf" {
+			continue
+		}
+		if f.Name == "docker-compose.yml" {
+			content = []byte(strings.Replace(string(content), "restart: always", "restart: unless-stopped", 1))
+		}
+		if err := os.WriteFile(filepath.Join(dir, f.Name), content, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return state
+}
+
+func TestRunConfigDiff(t *testing.T) {
+	driftedState(t)
+
+	err := runConfigDiff(configDiffCmd, nil)
+	var exitErr *ExitCodeError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("runConfigDiff() = %v, want exit code 1", err)
+	}
+
+	if err := runConfigDiff(configDiffCmd, []string{"config.env"}); err != nil {
+		t.Errorf("runConfigDiff(config.env) = %v, want in sync", err)
+	}
+	if err := runConfigDiff(configDiffCmd, []string{"keyring.env"}); err == nil {
+		t.Error("expected an error for a file that is not generated")
+	}
+}
+
+func TestRunConfigRegenerate(t *testing.T) {
+	state := driftedState(t)
+	edited, err := os.ReadFile(filepath.Join(state.OutputDir, "docker-compose.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	configYes = true
+	t.Cleanup(func() { configYes = false })
+
+	if err := runConfigRegenerate(configRegenerateCmd, nil); err != nil {
+		t.Fatalf("runConfigRegenerate() error: %v", err)
+	}
+
+	drift, err := phases.DetectConfigDrift(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !drift.InSync() {
+		t.Errorf("files still differ after regenerate: %d", len(drift.Changes))
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(state.OutputDir, resetBackupDir, "config-*", "*"))
+	if len(backups) != 1 || filepath.Base(backups[0]) != "docker-compose.yml" {
+		t.Fatalf("backups = %v, want only the edited docker-compose.yml", backups)
+	}
+	if saved, _ := os.ReadFile(backups[0]); string(saved) != string(edited) {
+		t.Error("backup does not hold the hand-edited file")
+	}
+}
+
+func TestRunConfigSet(t *testing.T) {
+	state := driftedState(t)
+	composeBefore, err := os.ReadFile(filepath.Join(state.OutputDir, "docker-compose.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runConfigSet(configSetCmd, []string{"gpu_memory_util", "1.5"}); err == nil {
+		t.Fatal("expected an out-of-range error")
+	}
+	if err := runConfigSet(configSetCmd, []string{"gpu_memory_util", "0.85"}); err != nil {
+		t.Fatalf("runConfigSet() error: %v", err)
+	}
+
+	loaded, err := config.Load(state.OutputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.GPUMemoryUtil != 0.85 {
+		t.Errorf("saved GPUMemoryUtil = %v, want 0.85", loaded.GPUMemoryUtil)
+	}
+	nodeConfig, err := os.ReadFile(filepath.Join(state.OutputDir, "node-config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(nodeConfig), "0.85") {
+		t.Error("node-config.json was not rewritten with the new value")
+	}
+	// Unaffected files keep their hand edits, and missing ones stay missing.
+	if composeAfter, _ := os.ReadFile(filepath.Join(state.OutputDir, "docker-compose.yml")); string(composeAfter) != string(composeBefore) {
+		t.Error("docker-compose.yml was rewritten although the setting does not affect it")
+	}
+	if _, err := os.Stat(filepath.Join(state.OutputDir, "nginx.conf")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("nginx.conf was written although the setting does not affect it")
+	}
+	backups, _ := filepath.Glob(filepath.Join(state.OutputDir, resetBackupDir, "config-*", "*"))
+	if len(backups) != 1 || filepath.Base(backups[0]) != "node-config.json" {
+		t.Errorf("backups = %v, want only node-config.json", backups)
+	}
+
+	if err := runConfigUnset(configUnsetCmd, []string{"gpu_memory_util"}); err != nil {
+		t.Fatalf("runConfigUnset() error: %v", err)
+	}
+	if loaded, _ = config.Load(state.OutputDir); loaded.GPUMemoryUtil != 0 {
+		t.Errorf("GPUMemoryUtil after unset = %v, want 0", loaded.GPUMemoryUtil)
+	}
+	if err := runConfigSet(configSetCmd, []string{"no_such_key", "1"}); err == nil {
+		t.Error("expected an error for an unknown setting")
+	}
+}
+
+// TestRunConfigSet_SaveFails checks that no file is rewritten for a value
+// state.json could not record.
+func TestRunConfigSet_SaveFails(t *testing.T) {
+	state := driftedState(t)
+	state.StateHistory = 5
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+	// A file where the history directory belongs makes the next save fail.
+	history := filepath.Join(state.OutputDir, config.StateHistoryDir)
+	if err := os.RemoveAll(history); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(history, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	nodeConfig := filepath.Join(state.OutputDir, "node-config.json")
+	before, err := os.ReadFile(nodeConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = runConfigSet(configSetCmd, []string{"gpu_memory_util", "0.85"})
+	if err == nil || !strings.Contains(err.Error(), "save state") {
+		t.Fatalf("runConfigSet() error = %v, want a save error", err)
+	}
+	if after, _ := os.ReadFile(nodeConfig); string(after) != string(before) {
+		t.Error("node-config.json was rewritten although state was not saved")
+	}
+	if backups, _ := filepath.Glob(filepath.Join(state.OutputDir, resetBackupDir, "config-*")); len(backups) != 0 {
+		t.Errorf("backups = %v, want none", backups)
+	}
+}
@@ -138,13 +138,11 @@ func resolveDownloadParams(args []string) (*downloadParams, error) {
 		params.HFHome = phases.DefaultHFHome
 	}
 
-	// 3. Image: flag > state.Versions.MLNode > state.MLNodeImageTag > default
+	// 3. Image: flag > state.Versions.MLNode > default
 	if dlImage != "" {
 		params.Image = dlImage
 	} else if state != nil && state.Versions.MLNode != "" {
 		params.Image = phases.DefaultMLNodeImage + ":" + state.Versions.MLNode
-	} else if state != nil && state.MLNodeImageTag != "" {
-		params.Image = phases.DefaultMLNodeImage + ":" + state.MLNodeImageTag
 	} else {
 		params.Image = phases.DefaultMLNodeImage + ":" + phases.DefaultMLNodeImageTag
 	}
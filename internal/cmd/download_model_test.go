@@ -151,7 +151,7 @@ func TestResolveDownloadParams_ImageFromStateTag(t *testing.T) {
 	outputDir = dir
 	state := config.NewState(dir)
 	state.SelectedModel = testModelQwQ
-	state.MLNodeImageTag = "3.0.12-blackwell"
+	state.Versions.MLNode = "3.0.12-blackwell"
 	if err := state.Save(); err != nil {
 		t.Fatalf("Save state: %v", err)
 	}
@@ -336,33 +336,6 @@ func TestResolveDownloadParams_ArgOverridesState(t *testing.T) {
 	}
 }
 
-func TestResolveDownloadParams_VersionsPriorityOverTag(t *testing.T) {
-	dlHFHome = ""
-	dlImage = ""
-	dlHFToken = ""
-
-	dir := t.TempDir()
-	outputDir = dir
-	state := config.NewState(dir)
-	state.SelectedModel = testModelQwQ
-	state.Versions.MLNode = "3.0.13"
-	state.MLNodeImageTag = "3.0.12-blackwell"
-	if err := state.Save(); err != nil {
-		t.Fatalf("Save state: %v", err)
-	}
-
-	params, err := resolveDownloadParams(nil)
-	if err != nil {
-		t.Fatalf("resolveDownloadParams() error: %v", err)
-	}
-
-	// Versions.MLNode should take priority over MLNodeImageTag
-	want := phases.DefaultMLNodeImage + ":3.0.13"
-	if params.Image != want {
-		t.Errorf("Image = %q, want %q (Versions.MLNode should take priority)", params.Image, want)
-	}
-}
-
 func TestResolveDownloadParams_HFTokenEmpty(t *testing.T) {
 	dlHFHome = ""
 	dlImage = ""
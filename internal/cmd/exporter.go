@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/inc4/gonka-nop/internal/config"
+	"github.com/inc4/gonka-nop/internal/exporter"
+	"github.com/inc4/gonka-nop/internal/status"
+	"github.com/inc4/gonka-nop/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultExporterListen   = ":9101"
+	defaultExporterInterval = 15 * time.Second
+	exporterShutdownTimeout = 5 * time.Second
+)
+
+var exporterCmd = &cobra.Command{
+	Use:   "exporter",
+	Short: "Serve node metrics for Prometheus",
+	Long: `Run a Prometheus exporter that polls the same sources as 'gonka-nop status'
+(Admin API setup report, config and nodes; Tendermint status, net_info and
+validators) and exposes them on /metrics.
+
+Metrics include block height and lag, peer count, miss rate, PoC weight,
+timeslot allocation, per-GPU memory and temperature, ML node current and
+intended status, and an overall health level.
+
+Examples:
+  gonka-nop exporter                          # Listen on :9101, poll every 15s
+  gonka-nop exporter --listen 127.0.0.1:9101  # Loopback only
+  gonka-nop exporter --interval 30s`,
+	RunE: runExporter,
+}
+
+var (
+	exporterListen   string
+	exporterInterval time.Duration
+	exporterAdminURL string
+	exporterRPCURL   string
+	exporterNodeType string
+)
+
+func init() {
+	exporterCmd.Flags().StringVar(&exporterListen, "listen", defaultExporterListen, "Address to serve /metrics on")
+	exporterCmd.Flags().DurationVar(&exporterInterval, "interval", defaultExporterInterval, "Status polling interval")
+	exporterCmd.Flags().StringVar(&exporterAdminURL, "admin-url", "", "Admin API URL (default from state or http://localhost:9200)")
+	exporterCmd.Flags().StringVar(&exporterRPCURL, "rpc-url", "", "Tendermint RPC URL (default from state or http://localhost:26657)")
+	exporterCmd.Flags().StringVar(&exporterNodeType, "node-type", "", "Node type: full, network or mlnode (default from state)")
+}
+
+func runExporter(cmd *cobra.Command, _ []string) error {
+	if exporterInterval < time.Second {
+		return fmt.Errorf("--interval must be at least 1s, got %s", exporterInterval)
+	}
+
+	cfg, err := resolveStatusConfig(exporterAdminURL, exporterRPCURL, exporterNodeType)
+	if err != nil {
+		return err
+	}
+
+	exp := exporter.New(func() (*status.NodeStatus, error) {
+		return status.FetchStatusWithConfig(outputDir, cfg)
+	}, exporterInterval)
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return serveUntilDone(ctx, exporterListen, exp.Handler(), func(ctx context.Context) {
+		ui.Info("Polling %s and %s every %s", cfg.AdminURL, cfg.TendermintURL, exporterInterval)
+		exp.Run(ctx)
+	})
+}
+
+// resolveStatusConfig builds the status config from state with optional
+// overrides from flags. A state.json that exists but cannot be read is an
+// error unless the flags give every endpoint and the node type: silently
+// polling the defaults would report a healthy-looking but wrong node.
+func resolveStatusConfig(adminURL, rpcURL, nodeType string) (*status.StatusConfig, error) {
+	var cfg *status.StatusConfig
+	state, err := config.Load(outputDir)
+	switch {
+	case err == nil:
+		cfg = statusConfigFor(state)
+	case adminURL != "" && rpcURL != "" && nodeType != "":
+		ui.Warn("Cannot read state (%v); using --admin-url, --rpc-url and --node-type", err)
+		cfg = status.DefaultConfig()
+	default:
+		return nil, fmt.Errorf("read state: %w (pass --admin-url, --rpc-url and --node-type to run without it)", err)
+	}
+	if adminURL != "" {
+		cfg.AdminURL = adminURL
+	}
+	if rpcURL != "" {
+		cfg.TendermintURL = rpcURL
+	}
+	if nodeType != "" {
+		if nodeType != config.NodeTypeFull && nodeType != config.NodeTypeNetwork && nodeType != config.NodeTypeMLNode {
+			return nil, fmt.Errorf("invalid --node-type %q (valid: full, network, mlnode)", nodeType)
+		}
+		cfg.NodeType = nodeType
+	}
+	return cfg, nil
+}
+
+// serveUntilDone serves handler on addr and runs background until ctx is done,
+// then shuts the server down gracefully.
+func serveUntilDone(ctx context.Context, addr string, handler http.Handler, background func(context.Context)) error {
+	return serveServerUntilDone(ctx, &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}, background)
+}
+
+// serveServerUntilDone is serveUntilDone for a preconfigured server. It
+// serves HTTPS when srv.TLSConfig is set.
+func serveServerUntilDone(ctx context.Context, srv *http.Server, background func(context.Context)) error {
+	go background(ctx)
+
+	errCh := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			ui.Success("Listening on %s (TLS)", srv.Addr)
+			errCh <- srv.ListenAndServeTLS("", "")
+			return
+		}
+		ui.Success("Listening on %s", srv.Addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("listen %s: %w", srv.Addr, err)
+		}
+		return nil
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), exporterShutdownTimeout)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveStatusConfig_UnreadableState(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "state.json"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	outputDir = dir
+
+	if _, err := resolveStatusConfig("", "", ""); err == nil || !strings.Contains(err.Error(), "--node-type") {
+		t.Errorf("resolveStatusConfig() error = %v, want a read error naming the flags", err)
+	}
+	if _, err := resolveStatusConfig("http://10.0.0.2:9200", "", "network"); err == nil {
+		t.Error("a partial set of flags should not hide the read error")
+	}
+
+	cfg, err := resolveStatusConfig("http://10.0.0.2:9200", "http://10.0.0.2:26657", "network")
+	if err != nil {
+		t.Fatalf("resolveStatusConfig() with every flag: %v", err)
+	}
+	if cfg.AdminURL != "http://10.0.0.2:9200" || cfg.TendermintURL != "http://10.0.0.2:26657" || cfg.NodeType != "network" {
+		t.Errorf("cfg = %+v", cfg)
+	}
+}
+
+func TestResolveStatusConfig_FromState(t *testing.T) {
+	dir := t.TempDir()
+	state := `{"schema_version": 3, "output_dir": "` + dir + `", "node_type": "mlnode", "admin_url": "http://10.0.0.1:9200"}`
+	if err := os.WriteFile(filepath.Join(dir, "state.json"), []byte(state), 0600); err != nil {
+		t.Fatal(err)
+	}
+	outputDir = dir
+
+	cfg, err := resolveStatusConfig("", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AdminURL != "http://10.0.0.1:9200" || cfg.NodeType != "mlnode" {
+		t.Errorf("cfg = %+v, want the state's admin URL and node type", cfg)
+	}
+	if _, err := resolveStatusConfig("", "", "gpu"); err == nil {
+		t.Error("expected an error for an invalid --node-type")
+	}
+}
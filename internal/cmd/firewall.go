@@ -0,0 +1,308 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/inc4/gonka-nop/internal/config"
+	"github.com/inc4/gonka-nop/internal/firewall"
+	"github.com/inc4/gonka-nop/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var firewallYes bool
+
+// firewallRunner is replaced in tests.
+var firewallRunner firewall.Runner
+
+var firewallCmd = &cobra.Command{
+	Use:   "firewall",
+	Short: "Show and manage the node's port restriction rules",
+	Long: `Manage the firewall rules gonka-nop installs to restrict internal ports.
+
+The desired rules follow from the node's state:
+  ML node      PoC port (poc_port) and inference port (inference_port, matched
+               as container port 5000) restricted to network_node_ip
+  Network/full Only P2P and the public API open; RPC 26657, ML callback
+               9100 and Admin API 9200 restricted to the Docker bridge (and
+               private networks for 9100 on a network-only node); new API
+               connections rate-limited and P2P connections capped per
+               source IP
+
+Rules are installed with ufw or firewalld when active, nftables otherwise,
+and tagged with a gonka-nop comment so they can be found and replaced.
+Untagged DROP rules left in DOCKER-USER by earlier versions are reported as
+legacy and cleaned up by apply and remove.
+
+Subcommands:
+  show    - Compare the installed rules with the desired set
+  apply   - Install the desired set, replacing stale and duplicate rules
+  remove  - Delete every gonka-nop rule`,
+}
+
+var firewallShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Compare installed rules with the desired set",
+	RunE:  runFirewallShow,
+}
+
+var firewallApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Reconcile installed rules with the desired set",
+	RunE:  runFirewallApply,
+}
+
+var firewallRemoveCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Delete every gonka-nop firewall rule",
+	RunE:  runFirewallRemove,
+}
+
+func init() {
+	firewallCmd.AddCommand(firewallShowCmd)
+	firewallCmd.AddCommand(firewallApplyCmd)
+	firewallCmd.AddCommand(firewallRemoveCmd)
+	firewallApplyCmd.Flags().BoolVarP(&firewallYes, "yes", "y", false, "Skip the confirmation prompt")
+	firewallRemoveCmd.Flags().BoolVarP(&firewallYes, "yes", "y", false, "Skip the confirmation prompt")
+}
+
+// firewallReport is the installed rule set compared with the desired one.
+type firewallReport struct {
+	Backend firewall.Backend
+	Desired *firewall.Plan
+	Missing *firewall.Plan
+	Extra   *firewall.Plan
+	Legacy  [][]string // untagged rules from earlier versions
+}
+
+// InSync reports whether nothing needs to change.
+func (r *firewallReport) InSync() bool {
+	return r.Missing.Empty() && r.Extra.Empty() && len(r.Legacy) == 0
+}
+
+func loadFirewallState() (*config.State, firewall.Runner, error) {
+	state, err := config.Load(outputDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	if state.OutputDir == "" {
+		return nil, nil, fmt.Errorf("no deployment found in %s", outputDir)
+	}
+	if firewallRunner != nil {
+		return state, firewallRunner, nil
+	}
+	return state, firewall.ExecRunner{UseSudo: state.UseSudo}, nil
+}
+
+// inspectFirewall reads the installed rules and diffs them with the plan
+// for this node.
+func inspectFirewall(ctx context.Context, state *config.State, r firewall.Runner) (*firewallReport, error) {
+	plan, err := firewall.PlanFor(state)
+	if err != nil {
+		return nil, err
+	}
+	backend, err := firewall.Detect(ctx, r, state.Distro.Family)
+	if err != nil {
+		return nil, err
+	}
+	current, err := backend.Current(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	missing, extra := firewall.Diff(plan, current)
+	return &firewallReport{
+		Backend: backend,
+		Desired: plan,
+		Missing: missing,
+		Extra:   extra,
+		Legacy:  firewall.FindLegacy(ctx, r, plan.Ports()),
+	}, nil
+}
+
+func runFirewallShow(cmd *cobra.Command, _ []string) error {
+	state, r, err := loadFirewallState()
+	if err != nil {
+		return err
+	}
+	rep, err := inspectFirewall(cmd.Context(), state, r)
+	if err != nil {
+		return err
+	}
+	displayFirewallReport(rep)
+	if rep.InSync() {
+		ui.Success("Firewall rules are in sync")
+	} else {
+		ui.Warn("Firewall rules differ from the desired set — run 'gonka-nop firewall apply'")
+	}
+	return nil
+}
+
+func displayFirewallReport(rep *firewallReport) {
+	greenC := color.New(color.FgGreen)
+	yellowC := color.New(color.FgYellow)
+	boldC := color.New(color.Bold)
+
+	_, _ = boldC.Printf("\nFirewall Rules (%s)\n", rep.Backend.Name())
+	fmt.Println(strings.Repeat("─", 60))
+	fmt.Printf("  %-9s %-16s %-12s %s\n", "STATE", "PORT", "RULE", "DETAIL")
+
+	row := func(state, port, name, detail string) {
+		fmt.Print("  ")
+		if state == "ok" {
+			_, _ = greenC.Printf("%-9s", state)
+		} else {
+			_, _ = yellowC.Printf("%-9s", state)
+		}
+		fmt.Printf(" %-16s %-12s %s\n", port, name, detail)
+	}
+	state := func(missing bool) string {
+		if missing {
+			return "missing"
+		}
+		return "ok"
+	}
+
+	for _, r := range rep.Desired.Rules {
+		missing := slices.ContainsFunc(rep.Missing.Rules, func(m firewall.Rule) bool { return m.Name == r.Name && m.Port == r.Port })
+		row(state(missing), firewallPortLabel(r), r.Name, "allow "+strings.Join(r.Allow, ", "))
+	}
+	for _, l := range rep.Desired.Limits {
+		row(state(slices.Contains(rep.Missing.Limits, l)), fmt.Sprintf("%d", l.Port), l.Name, firewallLimitLabel(l))
+	}
+	if len(rep.Desired.Open) > 0 {
+		row(state(len(rep.Missing.Open) > 0), firewallPortsLabel(rep.Desired.Open), "open", "other published ports dropped")
+	}
+
+	for _, r := range rep.Extra.Rules {
+		row("extra", firewallPortLabel(r), r.Name, "allow "+strings.Join(r.Allow, ", "))
+	}
+	for _, l := range rep.Extra.Limits {
+		row("extra", fmt.Sprintf("%d", l.Port), l.Name, firewallLimitLabel(l))
+	}
+	if len(rep.Extra.Open) > 0 {
+		row("extra", firewallPortsLabel(rep.Extra.Open), "open", "other published ports dropped")
+	}
+	for _, spec := range rep.Legacy {
+		row("legacy", "", "", strings.Join(spec, " "))
+	}
+	fmt.Println()
+}
+
+func firewallPortLabel(r firewall.Rule) string {
+	if r.HostPort != 0 && r.HostPort != r.Port {
+		return fmt.Sprintf("%d (host %d)", r.Port, r.HostPort)
+	}
+	return fmt.Sprintf("%d", r.Port)
+}
+
+func firewallLimitLabel(l firewall.Limit) string {
+	if l.Rate > 0 {
+		return fmt.Sprintf("%d new conn/s per IP (burst %d)", l.Rate, l.Burst)
+	}
+	return fmt.Sprintf("max %d conns per IP", l.MaxConns)
+}
+
+func firewallPortsLabel(ports []int) string {
+	s := make([]string, len(ports))
+	for i, p := range ports {
+		s[i] = fmt.Sprintf("%d", p)
+	}
+	return strings.Join(s, ",")
+}
+
+func runFirewallApply(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	state, r, err := loadFirewallState()
+	if err != nil {
+		return err
+	}
+	rep, err := inspectFirewall(ctx, state, r)
+	if err != nil {
+		return err
+	}
+	displayFirewallReport(rep)
+
+	if rep.InSync() {
+		ui.Success("Firewall rules are already in sync")
+	} else {
+		if !firewallYes {
+			proceed, confirmErr := ui.Confirm(fmt.Sprintf("Apply the desired rules with %s?", rep.Backend.Name()), true)
+			if confirmErr != nil {
+				return confirmErr
+			}
+			if !proceed {
+				ui.Info("Canceled.")
+				return nil
+			}
+		}
+		if err := reconcileFirewall(ctx, r, rep); err != nil {
+			return err
+		}
+		ui.Success("Firewall rules applied (%s)", rep.Backend.Name())
+	}
+
+	ddos := len(rep.Desired.Limits) > 0
+	if !state.FirewallConfigured || state.DDoSProtection != ddos {
+		state.FirewallConfigured, state.DDoSProtection = true, ddos
+		if err := state.Save(); err != nil {
+			return fmt.Errorf("save state: %w", err)
+		}
+	}
+	return nil
+}
+
+// reconcileFirewall installs the desired rules, which replaces stale and
+// duplicate tagged rules, then deletes legacy untagged ones.
+func reconcileFirewall(ctx context.Context, r firewall.Runner, rep *firewallReport) error {
+	if err := rep.Backend.Apply(ctx, r, rep.Desired); err != nil {
+		return fmt.Errorf("apply firewall rules (%s): %w", rep.Backend.Name(), err)
+	}
+	if err := firewall.RemoveLegacy(ctx, r, rep.Legacy); err != nil {
+		return err
+	}
+	if len(rep.Legacy) > 0 {
+		ui.Success("Removed %d legacy iptables rule(s)", len(rep.Legacy))
+	}
+	return nil
+}
+
+func runFirewallRemove(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	state, r, err := loadFirewallState()
+	if err != nil {
+		return err
+	}
+	rep, err := inspectFirewall(ctx, state, r)
+	if err != nil {
+		return err
+	}
+	displayFirewallReport(rep)
+
+	if !firewallYes {
+		proceed, confirmErr := ui.Confirm("Remove all gonka-nop firewall rules? Internal ports will be reachable again.", false)
+		if confirmErr != nil {
+			return confirmErr
+		}
+		if !proceed {
+			ui.Info("Canceled.")
+			return nil
+		}
+	}
+
+	if err := rep.Backend.Remove(ctx, r); err != nil {
+		return fmt.Errorf("remove firewall rules (%s): %w", rep.Backend.Name(), err)
+	}
+	if err := firewall.RemoveLegacy(ctx, r, rep.Legacy); err != nil {
+		return err
+	}
+	ui.Success("Firewall rules removed")
+
+	state.FirewallConfigured, state.DDoSProtection = false, false
+	if err := state.Save(); err != nil {
+		return fmt.Errorf("save state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/inc4/gonka-nop/internal/config"
+)
+
+// scriptedRunner answers host commands from canned output and records them.
+type scriptedRunner struct {
+	calls  []string
+	output map[string]string // command prefix -> stdout
+}
+
+func (s *scriptedRunner) Run(_ context.Context, _, name string, args ...string) (string, error) {
+	call := name + " " + strings.Join(args, " ")
+	s.calls = append(s.calls, call)
+	for prefix, out := range s.output {
+		if strings.HasPrefix(call, prefix) {
+			return out, nil
+		}
+	}
+	if name == "ufw" || name == "firewall-cmd" {
+		return "", fmt.Errorf("%s: not found", name)
+	}
+	return "", nil
+}
+
+// mlNodeFirewallRunner simulates an nftables host with the poc rule
+// installed three times, no inference rule, and one legacy iptables rule.
+func mlNodeFirewallRunner() *scriptedRunner {
+	return &scriptedRunner{output: map[string]string{
+		"nft list table": `table inet gonka_nop {
+	chain forward {
+		tcp dport 8080 ip saddr != 198.51.100.7 drop comment "gonka-nop:poc"
+		tcp dport 8080 meta nfproto ipv6 drop comment "gonka-nop:poc"
+		tcp dport 8080 ip saddr != 198.51.100.7 drop comment "gonka-nop:poc"
+		tcp dport 8080 meta nfproto ipv6 drop comment "gonka-nop:poc"
+		tcp dport 8080 ip saddr != 198.51.100.7 drop comment "gonka-nop:poc"
+		tcp dport 8080 meta nfproto ipv6 drop comment "gonka-nop:poc"
+	}
+}`,
+		"iptables -S DOCKER-USER": "-A DOCKER-USER ! -s 198.51.100.7/32 -p tcp -m tcp --dport 5000 -j DROP\n",
+	}}
+}
+
+func mlNodeFirewallState(t *testing.T) *config.State {
+	t.Helper()
+	dir := t.TempDir()
+	outputDir = dir
+	state := config.NewState(dir)
+	state.NodeType = config.NodeTypeMLNode
+	state.PublicIP = "203.0.113.5"
+	state.NetworkNodeIP = "198.51.100.7"
+	state.InferencePort = 5050
+	state.Distro = config.Distro{ID: "ubuntu", Family: "debian"}
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save state: %v", err)
+	}
+	return state
+}
+
+func TestInspectFirewall(t *testing.T) {
+	state := mlNodeFirewallState(t)
+
+	rep, err := inspectFirewall(context.Background(), state, mlNodeFirewallRunner())
+	if err != nil {
+		t.Fatalf("inspectFirewall() error: %v", err)
+	}
+	if rep.Backend.Name() != "nftables" {
+		t.Errorf("backend = %s, want nftables", rep.Backend.Name())
+	}
+	if len(rep.Missing.Rules) != 1 || rep.Missing.Rules[0].Name != "inference" {
+		t.Errorf("missing = %+v, want the inference rule", rep.Missing)
+	}
+	if len(rep.Extra.Rules) != 2 {
+		t.Errorf("extra = %+v, want the two duplicate poc rules", rep.Extra)
+	}
+	if len(rep.Legacy) != 1 || rep.InSync() {
+		t.Errorf("legacy = %q, want one legacy rule", rep.Legacy)
+	}
+	if got := firewallPortLabel(rep.Missing.Rules[0]); got != "5000 (host 5050)" {
+		t.Errorf("port label = %q", got)
+	}
+}
+
+func TestRunFirewallApply(t *testing.T) {
+	mlNodeFirewallState(t)
+	r := mlNodeFirewallRunner()
+	oldRunner, oldYes := firewallRunner, firewallYes
+	firewallRunner, firewallYes = r, true
+	t.Cleanup(func() { firewallRunner, firewallYes = oldRunner, oldYes })
+
+	firewallApplyCmd.SetContext(context.Background())
+	if err := runFirewallApply(firewallApplyCmd, nil); err != nil {
+		t.Fatalf("runFirewallApply() error: %v", err)
+	}
+	joined := strings.Join(r.calls, "\n")
+	for _, want := range []string{
+		"nft -f /etc/gonka-nop/firewall.nft",
+		"iptables -D DOCKER-USER ! -s 198.51.100.7/32 -p tcp -m tcp --dport 5000 -j DROP",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("calls missing %q:\n%s", want, joined)
+		}
+	}
+
+	state, err := config.Load(outputDir)
+	if err != nil {
+		t.Fatalf("Load state: %v", err)
+	}
+	if !state.FirewallConfigured {
+		t.Error("FirewallConfigured should be set after apply")
+	}
+}
@@ -0,0 +1,446 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/inc4/gonka-nop/internal/docker"
+	"github.com/inc4/gonka-nop/internal/fleet"
+	"github.com/inc4/gonka-nop/internal/status"
+	"github.com/inc4/gonka-nop/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fleetInventory  string
+	fleetHosts      []string
+	fleetForce      bool
+	fleetYes        bool
+	fleetTimeout    time.Duration
+	fleetSSHOptions []string
+)
+
+// Replaced in tests.
+var (
+	fleetTransport    fleet.Transport
+	fleetPollInterval = 10 * time.Second
+)
+
+// ML node states reported by the Admin API.
+const (
+	mlStatusInference = "INFERENCE"
+	mlStatusPoC       = "POC"
+	mlStatusFailed    = "FAILED"
+)
+
+// errTimeslotsAllocated marks an ML node skipped because it holds timeslots.
+var errTimeslotsAllocated = errors.New("timeslots allocated")
+
+var fleetCmd = &cobra.Command{
+	Use:   "fleet",
+	Short: "Manage several nodes from one inventory file",
+	Long: `Manage a network node and the GPU servers registered with it from one
+inventory file (default: <output>/fleet.yaml):
+
+  version: 1
+  hosts:
+    - name: network-1
+      type: network                    # full, network or mlnode
+      admin_url: http://10.0.1.100:9200
+      rpc_url: http://10.0.1.100:26657
+    - name: gpu-1
+      type: mlnode
+      ssh: root@10.0.1.11              # empty = this machine
+      dir: /opt/gonka-node             # gonka-nop output dir on the host
+      node_id: gpu1                    # ID used with 'ml-node add'
+      network_node: network-1          # optional with one network host
+
+Commands run on hosts over ssh in batch mode, so key-based login must work.
+
+Subcommands:
+  status  - Query every host concurrently and print one table
+  update  - Rolling ML node update, one host at a time`,
+}
+
+var fleetStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the status of every host in the fleet",
+	RunE:  runFleetStatus,
+}
+
+var fleetUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update ML nodes one at a time",
+	Long: `Update the ML nodes of the fleet one host at a time. For each host:
+
+  1. Check its timeslot allocation on the network node (skipped while
+     timeslots are allocated, unless --force)
+  2. Disable it via the network node's Admin API
+  3. Run 'gonka-nop update --service mlnode --no-admin' on the host
+  4. Wait for the model to load
+  5. Re-enable it
+
+The rollout stops at the first failure; the failed node is left disabled
+and the remaining hosts are not touched.
+
+Examples:
+  gonka-nop fleet update
+  gonka-nop fleet update --host gpu-1 --host gpu-2
+  gonka-nop fleet update --force -y`,
+	RunE: runFleetUpdate,
+}
+
+func init() {
+	fleetCmd.PersistentFlags().StringVar(&fleetInventory, "inventory", "", "Fleet inventory file (default: <output>/fleet.yaml)")
+	fleetCmd.PersistentFlags().StringArrayVar(&fleetSSHOptions, "ssh-option", nil, "Extra ssh argument (repeatable), e.g. --ssh-option=-oStrictHostKeyChecking=accept-new")
+	fleetCmd.AddCommand(fleetStatusCmd)
+	fleetCmd.AddCommand(fleetUpdateCmd)
+
+	fleetUpdateCmd.Flags().StringArrayVar(&fleetHosts, "host", nil, "Only update these hosts (repeatable)")
+	fleetUpdateCmd.Flags().BoolVar(&fleetForce, "force", false, "Update nodes even while they hold timeslots")
+	fleetUpdateCmd.Flags().DurationVar(&fleetTimeout, "timeout", 15*time.Minute, "Per-host wait for the model to load")
+	fleetUpdateCmd.Flags().BoolVarP(&fleetYes, "yes", "y", false, "Skip the confirmation prompt")
+}
+
+func loadFleetInventory() (*fleet.Inventory, error) {
+	path := fleetInventory
+	if path == "" {
+		path = filepath.Join(outputDir, fleet.DefaultInventoryFile)
+	}
+	return fleet.LoadInventory(path)
+}
+
+func fleetTransportOrDefault() fleet.Transport {
+	if fleetTransport != nil {
+		return fleetTransport
+	}
+	return fleet.SSHTransport{Options: fleetSSHOptions}
+}
+
+// --- fleet status ---
+
+// fleetRow is one line of the fleet status table.
+type fleetRow struct {
+	Host   string
+	Role   string
+	State  string
+	Detail string
+	OK     bool
+}
+
+// networkProbe is what a network host reported.
+type networkProbe struct {
+	sync     *docker.SyncStatus
+	syncErr  error
+	nodes    []status.AdminNodesEntry
+	nodesErr error
+}
+
+func runFleetStatus(cmd *cobra.Command, _ []string) error {
+	inv, err := loadFleetInventory()
+	if err != nil {
+		return err
+	}
+	rows := collectFleetStatus(cmd.Context(), inv)
+	displayFleetStatus(rows)
+	return nil
+}
+
+// collectFleetStatus queries every network host concurrently, then derives
+// the ML node rows from the Admin API of the network node they belong to.
+func collectFleetStatus(ctx context.Context, inv *fleet.Inventory) []fleetRow {
+	probes := make(map[string]*networkProbe)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := range inv.Hosts {
+		h := &inv.Hosts[i]
+		if !h.IsNetwork() {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := probeNetworkHost(ctx, h)
+			mu.Lock()
+			probes[h.Name] = p
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	rows := make([]fleetRow, 0, len(inv.Hosts))
+	for i := range inv.Hosts {
+		h := &inv.Hosts[i]
+		if h.IsNetwork() {
+			rows = append(rows, networkRow(h, probes[h.Name]))
+		}
+		if h.IsMLNode() {
+			network, _ := inv.NetworkFor(h) // checked by Validate
+			rows = append(rows, mlNodeRow(h, probes[network.Name]))
+		}
+	}
+	return rows
+}
+
+func probeNetworkHost(ctx context.Context, h *fleet.Host) *networkProbe {
+	rpcCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	p := &networkProbe{}
+	p.sync, p.syncErr = docker.FetchSyncStatus(rpcCtx, h.RPCURL)
+	p.nodes, p.nodesErr = fetchAdminNodes(h.AdminURL)
+	return p
+}
+
+func networkRow(h *fleet.Host, p *networkProbe) fleetRow {
+	row := fleetRow{Host: h.Name, Role: "network"}
+	switch {
+	case p.syncErr != nil:
+		row.State = "unreachable"
+		row.Detail = fmt.Sprintf("RPC: %v", p.syncErr)
+		return row
+	case p.sync.CatchingUp:
+		row.State = "catching up"
+	default:
+		row.State = "synced"
+		row.OK = true
+	}
+	row.Detail = fmt.Sprintf("height %d", p.sync.LatestBlockHeight)
+	if p.nodesErr != nil {
+		row.Detail += ", Admin API unreachable"
+		row.OK = false
+	} else {
+		row.Detail += fmt.Sprintf(", %d ML node(s)", len(p.nodes))
+	}
+	return row
+}
+
+func mlNodeRow(h *fleet.Host, p *networkProbe) fleetRow {
+	row := fleetRow{Host: h.Name, Role: "mlnode", State: "unknown"}
+	if p.nodesErr != nil {
+		row.Detail = "network node Admin API unreachable"
+		return row
+	}
+	e := findAdminNode(p.nodes, h.NodeID)
+	if e == nil {
+		row.State = "not registered"
+		row.Detail = fmt.Sprintf("node %q not found — run 'gonka-nop ml-node add'", h.NodeID)
+		return row
+	}
+
+	row.State = e.State.CurrentStatus
+	row.OK = row.State == mlStatusInference || row.State == mlStatusPoC
+	enabled := "disabled"
+	if e.State.AdminState.Enabled {
+		enabled = "enabled"
+	} else {
+		row.OK = false
+	}
+	allocated, total := allocatedTimeslots(e)
+	row.Detail = fmt.Sprintf("%s, %s, timeslots %d/%d", h.NodeID, enabled, allocated, total)
+	if e.State.FailureReason != "" {
+		row.Detail += ", " + e.State.FailureReason
+	}
+	return row
+}
+
+func displayFleetStatus(rows []fleetRow) {
+	greenC := color.New(color.FgGreen)
+	yellowC := color.New(color.FgYellow)
+	boldC := color.New(color.Bold)
+
+	_, _ = boldC.Println("\nFleet Status")
+	fmt.Println(strings.Repeat("─", 60))
+	fmt.Printf("  %-16s %-8s %-14s %s\n", "HOST", "ROLE", "STATE", "DETAIL")
+	for _, r := range rows {
+		fmt.Printf("  %-16s %-8s ", r.Host, r.Role)
+		if r.OK {
+			_, _ = greenC.Printf("%-14s", r.State)
+		} else {
+			_, _ = yellowC.Printf("%-14s", r.State)
+		}
+		fmt.Printf(" %s\n", r.Detail)
+	}
+	fmt.Println()
+}
+
+// --- fleet update ---
+
+func runFleetUpdate(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+
+	inv, err := loadFleetInventory()
+	if err != nil {
+		return err
+	}
+	hosts, err := selectFleetMLNodes(inv, fleetHosts)
+	if err != nil {
+		return err
+	}
+
+	ui.Info("Rolling ML node update, one host at a time:")
+	for i, h := range hosts {
+		ui.Detail("%d. %s (%s)", i+1, h.Name, h.NodeID)
+	}
+	if !fleetYes {
+		proceed, confirmErr := ui.Confirm(fmt.Sprintf("Update %d ML node(s)?", len(hosts)), true)
+		if confirmErr != nil {
+			return confirmErr
+		}
+		if !proceed {
+			ui.Info("Update canceled.")
+			return nil
+		}
+	}
+
+	return rollingMLNodeUpdate(ctx, inv, hosts, fleetTransportOrDefault())
+}
+
+// selectFleetMLNodes returns the named ML hosts, or all of them.
+func selectFleetMLNodes(inv *fleet.Inventory, names []string) ([]*fleet.Host, error) {
+	if len(names) == 0 {
+		hosts := inv.MLNodes()
+		if len(hosts) == 0 {
+			return nil, fmt.Errorf("the inventory has no full or mlnode hosts")
+		}
+		return hosts, nil
+	}
+	hosts := make([]*fleet.Host, 0, len(names))
+	for _, name := range names {
+		h, ok := inv.Host(name)
+		if !ok {
+			return nil, fmt.Errorf("host %q is not in the inventory", name)
+		}
+		if !h.IsMLNode() {
+			return nil, fmt.Errorf("host %q does not run an ML node", name)
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+// rollingMLNodeUpdate updates hosts in order and stops at the first failure.
+// Hosts holding timeslots are skipped (unless --force) and reported.
+func rollingMLNodeUpdate(ctx context.Context, inv *fleet.Inventory, hosts []*fleet.Host, tr fleet.Transport) error {
+	var skipped []string
+	for i, h := range hosts {
+		ui.Header(fmt.Sprintf("[%d/%d] %s", i+1, len(hosts), h.Name))
+		err := updateFleetMLNode(ctx, inv, h, tr)
+		if errors.Is(err, errTimeslotsAllocated) {
+			ui.Warn("Skipping %s: %v (use --force to update anyway)", h.Name, err)
+			skipped = append(skipped, h.Name)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w — rollout stopped, %d host(s) not updated", h.Name, err, len(hosts)-i-1)
+		}
+		ui.Success("%s updated", h.Name)
+	}
+
+	if len(skipped) > 0 {
+		ui.Warn("Skipped (timeslots allocated): %s", strings.Join(skipped, ", "))
+		ui.Detail("Rerun later with --host to update them")
+		return nil
+	}
+	ui.Success("Fleet update complete.")
+	return nil
+}
+
+// updateFleetMLNode performs safeMLNodeUpdate for one host, driving the
+// Admin API steps from the network node and the container update over tr.
+func updateFleetMLNode(ctx context.Context, inv *fleet.Inventory, h *fleet.Host, tr fleet.Transport) error {
+	network, err := inv.NetworkFor(h)
+	if err != nil {
+		return err
+	}
+	nodes, err := fetchAdminNodes(network.AdminURL)
+	if err != nil {
+		return fmt.Errorf("query %s: %w", network.Name, err)
+	}
+	e := findAdminNode(nodes, h.NodeID)
+	if e == nil {
+		return fmt.Errorf("node %q is not registered with %s", h.NodeID, network.Name)
+	}
+	allocated, total := allocatedTimeslots(e)
+	ui.Detail("Timeslots: %d/%d allocated", allocated, total)
+	if allocated > 0 && !fleetForce {
+		return fmt.Errorf("%w (%d/%d)", errTimeslotsAllocated, allocated, total)
+	}
+
+	ui.Info("Disabling ML node %q...", h.NodeID)
+	if err := postAdminAction(network.AdminURL, h.NodeID, "disable"); err != nil {
+		return fmt.Errorf("disable: %w", err)
+	}
+
+	argv := []string{"gonka-nop", "update", "--service", "mlnode", "--yes", "--no-admin", "-o", h.Dir}
+	ui.Info("Updating containers on %s...", h.Name)
+	if _, err := tr.Run(ctx, h, argv); err != nil {
+		return fmt.Errorf("update (node left disabled): %w", err)
+	}
+
+	ui.Info("Waiting for model to load...")
+	if err := waitForFleetNode(ctx, network.AdminURL, h.NodeID); err != nil {
+		return fmt.Errorf("model load (node left disabled): %w", err)
+	}
+
+	ui.Info("Re-enabling ML node %q...", h.NodeID)
+	if err := postAdminAction(network.AdminURL, h.NodeID, "enable"); err != nil {
+		return fmt.Errorf("enable: %w", err)
+	}
+	return nil
+}
+
+// waitForFleetNode polls the Admin API until nodeID serves its model.
+func waitForFleetNode(ctx context.Context, adminAPI, nodeID string) error {
+	waitCtx, cancel := context.WithTimeout(ctx, fleetTimeout)
+	defer cancel()
+
+	for {
+		nodes, err := fetchAdminNodes(adminAPI)
+		if err == nil {
+			if e := findAdminNode(nodes, nodeID); e != nil {
+				switch e.State.CurrentStatus {
+				case mlStatusInference, mlStatusPoC:
+					return nil
+				case mlStatusFailed:
+					return fmt.Errorf("ml node failed: %s", e.State.FailureReason)
+				}
+				ui.Detail("ML node status: %s", e.State.CurrentStatus)
+			}
+		}
+
+		select {
+		case <-waitCtx.Done():
+			return waitCtx.Err()
+		case <-time.After(fleetPollInterval):
+		}
+	}
+}
+
+func findAdminNode(entries []status.AdminNodesEntry, nodeID string) *status.AdminNodesEntry {
+	for i := range entries {
+		if entries[i].Node.ID == nodeID {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+// allocatedTimeslots counts the node's allocated timeslots across models.
+func allocatedTimeslots(e *status.AdminNodesEntry) (allocated, total int) {
+	for _, info := range e.State.EpochMLNodes {
+		for _, s := range info.TimeslotAllocation {
+			if s {
+				allocated++
+			}
+		}
+		total += len(info.TimeslotAllocation)
+	}
+	return allocated, total
+}
@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/inc4/gonka-nop/internal/fleet"
+	"github.com/inc4/gonka-nop/internal/status"
+)
+
+// fakeTransport records commands instead of running them.
+type fakeTransport struct {
+	mu    sync.Mutex
+	calls []string
+	fail  map[string]bool // host name -> fail
+	log   *[]string
+}
+
+func (f *fakeTransport) Run(_ context.Context, h *fleet.Host, argv []string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, h.Name+": "+strings.Join(argv, " "))
+	if f.log != nil {
+		*f.log = append(*f.log, "run "+h.Name)
+	}
+	if f.fail[h.Name] {
+		return "", fmt.Errorf("ssh: connect to host %s: connection refused", h.SSH)
+	}
+	return "", nil
+}
+
+// fleetAdminServer serves /admin/v1/nodes for the given entries and logs
+// enable/disable calls.
+func fleetAdminServer(t *testing.T, entries []status.AdminNodesEntry, log *[]string) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/admin/v1/nodes" {
+			_ = json.NewEncoder(w).Encode(entries)
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/admin/v1/nodes/"), "/")
+		if len(parts) != 2 || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		*log = append(*log, parts[1]+" "+parts[0])
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func fleetEntry(id, currentStatus string, timeslots ...bool) status.AdminNodesEntry {
+	e := status.AdminNodesEntry{Node: status.AdminNodesNodeInfo{ID: id}}
+	e.State.CurrentStatus = currentStatus
+	e.State.AdminState.Enabled = true
+	if len(timeslots) > 0 {
+		e.State.EpochMLNodes = map[string]status.EpochMLNodeInfo{
+			"Qwen/Qwen3-4B-Instruct-2507": {NodeID: id, TimeslotAllocation: timeslots},
+		}
+	}
+	return e
+}
+
+func testFleet(adminURL, rpcURL string) *fleet.Inventory {
+	return &fleet.Inventory{
+		Version: fleet.InventoryVersion,
+		Hosts: []fleet.Host{
+			{Name: "network-1", Type: "network", AdminURL: adminURL, RPCURL: rpcURL},
+			{Name: "gpu-1", Type: "mlnode", SSH: "root@10.0.1.11", Dir: "/opt/gonka-node", NodeID: "gpu1"},
+			{Name: "gpu-2", Type: "mlnode", SSH: "root@10.0.1.12", Dir: "/opt/gonka-node", NodeID: "gpu2"},
+			{Name: "gpu-3", Type: "mlnode", SSH: "root@10.0.1.13", Dir: "/opt/gonka-node", NodeID: "gpu3"},
+		},
+	}
+}
+
+func setFleetTestFlags(t *testing.T, force bool) {
+	t.Helper()
+	oldForce, oldTimeout, oldInterval := fleetForce, fleetTimeout, fleetPollInterval
+	fleetForce, fleetTimeout, fleetPollInterval = force, 2*time.Second, 10*time.Millisecond
+	t.Cleanup(func() { fleetForce, fleetTimeout, fleetPollInterval = oldForce, oldTimeout, oldInterval })
+}
+
+func TestRollingMLNodeUpdate(t *testing.T) {
+	setFleetTestFlags(t, false)
+	var log []string
+	srv := fleetAdminServer(t, []status.AdminNodesEntry{
+		fleetEntry("gpu1", mlStatusInference, false, false),
+		fleetEntry("gpu2", mlStatusInference, true, false), // holds a timeslot
+		fleetEntry("gpu3", mlStatusPoC),
+	}, &log)
+	inv := testFleet(srv.URL, "")
+	tr := &fakeTransport{log: &log}
+
+	if err := rollingMLNodeUpdate(context.Background(), inv, inv.MLNodes(), tr); err != nil {
+		t.Fatalf("rollingMLNodeUpdate() error: %v", err)
+	}
+
+	// One host at a time, gpu-2 skipped for its timeslot.
+	wantLog := []string{
+		"disable gpu1", "run gpu-1", "enable gpu1",
+		"disable gpu3", "run gpu-3", "enable gpu3",
+	}
+	if strings.Join(log, ", ") != strings.Join(wantLog, ", ") {
+		t.Errorf("steps = %v, want %v", log, wantLog)
+	}
+	wantCall := "gpu-1: gonka-nop update --service mlnode --yes --no-admin -o /opt/gonka-node"
+	if len(tr.calls) == 0 || tr.calls[0] != wantCall {
+		t.Errorf("first call = %v, want %q", tr.calls, wantCall)
+	}
+}
+
+func TestRollingMLNodeUpdate_Force(t *testing.T) {
+	setFleetTestFlags(t, true)
+	var log []string
+	srv := fleetAdminServer(t, []status.AdminNodesEntry{fleetEntry("gpu2", mlStatusInference, true)}, &log)
+	inv := testFleet(srv.URL, "")
+	h, _ := inv.Host("gpu-2")
+
+	if err := rollingMLNodeUpdate(context.Background(), inv, []*fleet.Host{h}, &fakeTransport{log: &log}); err != nil {
+		t.Fatalf("rollingMLNodeUpdate() error: %v", err)
+	}
+	if strings.Join(log, ", ") != "disable gpu2, run gpu-2, enable gpu2" {
+		t.Errorf("steps = %v, want gpu-2 updated despite its timeslot", log)
+	}
+}
+
+func TestRollingMLNodeUpdate_StopsOnFailure(t *testing.T) {
+	setFleetTestFlags(t, false)
+	var log []string
+	srv := fleetAdminServer(t, []status.AdminNodesEntry{
+		fleetEntry("gpu1", mlStatusInference),
+		fleetEntry("gpu2", mlStatusInference),
+		fleetEntry("gpu3", mlStatusInference),
+	}, &log)
+	inv := testFleet(srv.URL, "")
+	tr := &fakeTransport{log: &log, fail: map[string]bool{"gpu-2": true}}
+
+	err := rollingMLNodeUpdate(context.Background(), inv, inv.MLNodes(), tr)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), "gpu-2") || !strings.Contains(err.Error(), "1 host(s) not updated") {
+		t.Errorf("error = %v, want gpu-2 and the remaining count", err)
+	}
+	// gpu-2 stays disabled; gpu-3 is never touched.
+	want := "disable gpu1, run gpu-1, enable gpu1, disable gpu2, run gpu-2"
+	if strings.Join(log, ", ") != want {
+		t.Errorf("steps = %v, want %s", log, want)
+	}
+}
+
+func TestRollingMLNodeUpdate_ModelFailed(t *testing.T) {
+	setFleetTestFlags(t, false)
+	var log []string
+	failed := fleetEntry("gpu1", mlStatusFailed)
+	failed.State.FailureReason = "OOM during model load"
+	srv := fleetAdminServer(t, []status.AdminNodesEntry{failed}, &log)
+	inv := testFleet(srv.URL, "")
+	h, _ := inv.Host("gpu-1")
+
+	err := rollingMLNodeUpdate(context.Background(), inv, []*fleet.Host{h}, &fakeTransport{log: &log})
+	if err == nil || !strings.Contains(err.Error(), "OOM during model load") {
+		t.Errorf("error = %v, want the failure reason", err)
+	}
+}
+
+func TestSelectFleetMLNodes(t *testing.T) {
+	inv := testFleet("http://a:9200", "http://a:26657")
+
+	hosts, err := selectFleetMLNodes(inv, []string{"gpu-3", "gpu-1"})
+	if err != nil || len(hosts) != 2 || hosts[0].Name != "gpu-3" {
+		t.Errorf("selectFleetMLNodes() = %v, %v; want gpu-3, gpu-1", hosts, err)
+	}
+	if _, err := selectFleetMLNodes(inv, []string{"network-1"}); err == nil {
+		t.Error("a network host should be rejected")
+	}
+	if _, err := selectFleetMLNodes(inv, []string{"gpu-9"}); err == nil {
+		t.Error("an unknown host should be rejected")
+	}
+}
+
+func TestCollectFleetStatus(t *testing.T) {
+	var log []string
+	admin := fleetAdminServer(t, []status.AdminNodesEntry{
+		fleetEntry("gpu1", mlStatusInference, true, false),
+		fleetEntry("gpu2", mlStatusFailed),
+	}, &log)
+	rpc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"result":{"sync_info":{"latest_block_height":"4200","catching_up":false}}}`))
+	}))
+	defer rpc.Close()
+
+	rows := collectFleetStatus(context.Background(), testFleet(admin.URL, rpc.URL))
+	if len(rows) != 4 {
+		t.Fatalf("rows = %d, want 4", len(rows))
+	}
+
+	tests := []struct {
+		host, state, detail string
+		ok                  bool
+	}{
+		{"network-1", "synced", "height 4200, 2 ML node(s)", true},
+		{"gpu-1", mlStatusInference, "gpu1, enabled, timeslots 1/2", true},
+		{"gpu-2", mlStatusFailed, "gpu2, enabled, timeslots 0/0", false},
+		{"gpu-3", "not registered", `node "gpu3" not found`, false},
+	}
+	for i, tt := range tests {
+		r := rows[i]
+		if r.Host != tt.host || r.State != tt.state || r.OK != tt.ok || !strings.Contains(r.Detail, tt.detail) {
+			t.Errorf("row %d = %+v, want %s %s %q ok=%v", i, r, tt.host, tt.state, tt.detail, tt.ok)
+		}
+	}
+}
+
+func TestCollectFleetStatus_NetworkDown(t *testing.T) {
+	inv := testFleet("http://127.0.0.1:1", "http://127.0.0.1:1")
+	rows := collectFleetStatus(context.Background(), inv)
+	if rows[0].State != "unreachable" {
+		t.Errorf("network row state = %q, want unreachable", rows[0].State)
+	}
+	if rows[1].State != "unknown" {
+		t.Errorf("ml row state = %q, want unknown", rows[1].State)
+	}
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/inc4/gonka-nop/internal/config"
+	"github.com/inc4/gonka-nop/internal/docker"
+	"github.com/inc4/gonka-nop/internal/phases"
+	"github.com/inc4/gonka-nop/internal/secret"
+	"github.com/inc4/gonka-nop/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	accountKeyName      string
+	accountKeyringDir   string
+	accountImageVersion string
+	initAccountBundle   string
+)
+
+var initAccountCmd = &cobra.Command{
+	Use:   "init-account",
+	Short: "Create the account (cold) key on an offline machine",
+	Long: `Create the account (cold) key for the secure key workflow. Run this on a
+separate, ideally air-gapped machine — not on the node server.
+
+The key is created with inferenced in a Docker container without network
+access, in a file keyring on this machine. The inferenced image must already
+be present; on an air-gapped machine load it with:
+
+  docker pull ghcr.io/product-science/inferenced:<version>    # connected machine
+  docker save ghcr.io/product-science/inferenced:<version> > inferenced.tar
+  docker load < inferenced.tar                                # offline machine
+
+The public key and address are written to a bundle (gonka-account.json) that
+contains no private material. It is signed with the account key, so setup can
+check that the bundle came from the key's holder and was not edited. Copy it
+to the server and pass it to setup:
+
+  gonka-nop setup --key-workflow secure --account-pubkey gonka-account.json
+
+Examples:
+  gonka-nop init-account
+  gonka-nop init-account --name my-account --keyring-dir /media/usb/.inference`,
+	RunE: runInitAccount,
+}
+
+func init() {
+	addAccountKeyFlags(initAccountCmd)
+	initAccountCmd.Flags().StringVar(&initAccountBundle, "bundle", "gonka-account.json", "Where to write the public account bundle")
+}
+
+// addAccountKeyFlags registers the flags locating the account key on the
+// cold machine, shared by init-account and sign.
+func addAccountKeyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&accountKeyName, "name", "gonka-account-key", "Account key name in the keyring")
+	cmd.Flags().StringVar(&accountKeyringDir, "keyring-dir", "", "Keyring directory (default: ~/.inference)")
+	cmd.Flags().StringVar(&accountImageVersion, "image-version", config.DefaultImageVersion, "inferenced image version")
+	addKeyringSourceFlags(cmd)
+}
+
+func runInitAccount(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+
+	keyringDir, err := accountKeyringPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(initAccountBundle); err == nil {
+		return fmt.Errorf("%s already exists — move it away or pass --bundle", initAccountBundle)
+	}
+
+	useSudo := docker.DetectSudo(ctx)
+	imageRef := "ghcr.io/product-science/inferenced:" + accountImageVersion
+	if err := requireLocalImage(ctx, imageRef, useSudo); err != nil {
+		return err
+	}
+
+	password, err := accountKeyringPassword(true)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(keyringDir, 0700); err != nil {
+		return fmt.Errorf("create keyring dir: %w", err)
+	}
+	ui.Info("Keyring: %s", keyringDir)
+
+	var key *phases.KeyOutput
+	var mnemonic string
+	err = ui.WithSpinner("Generating Account Key", func() error {
+		var keyErr error
+		key, mnemonic, keyErr = phases.CreateKeyViaDocker(ctx, imageRef, accountKeyName, password, keyringDir, useSudo)
+		return keyErr
+	})
+	if err != nil {
+		return fmt.Errorf("create account key: %w", err)
+	}
+
+	bundle, err := phases.NewAccountBundle(accountKeyName, key, time.Now())
+	if err != nil {
+		return fmt.Errorf("account key output: %w", err)
+	}
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode account bundle: %w", err)
+	}
+	if err := writeNewFile(initAccountBundle, append(data, '\n')); err != nil {
+		return err
+	}
+
+	ui.Success("Account key created")
+	ui.Detail("Name:    %s", bundle.Name)
+	ui.Detail("Address: %s", bundle.Address)
+	ui.Detail("Pubkey:  %s", bundle.PubKey)
+	if mnemonic != "" {
+		ui.Warn("SAVE YOUR MNEMONIC (account key) — write it down, never store it on the server:")
+		ui.Detail("%s", mnemonic)
+	}
+
+	fmt.Println()
+	ui.Info("Bundle written: %s", initAccountBundle)
+	ui.Detail("Copy it to the server and run:")
+	ui.Detail("  gonka-nop setup --key-workflow secure --account-pubkey %s", filepath.Base(initAccountBundle))
+	return nil
+}
+
+// accountKeyringPath returns --keyring-dir or ~/.inference, absolute
+// since it is bind-mounted into the container.
+func accountKeyringPath() (string, error) {
+	dir := accountKeyringDir
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("resolve home directory: %w", err)
+		}
+		dir = filepath.Join(home, ".inference")
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("resolve keyring dir: %w", err)
+	}
+	return abs, nil
+}
+
+// requireLocalImage fails with loading instructions when the image is not
+// present locally. init-account never pulls: the machine may be offline.
+func requireLocalImage(ctx context.Context, imageRef string, useSudo bool) error {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return fmt.Errorf("docker is required to create the account key with inferenced")
+	}
+	args := []string{"image", "inspect", imageRef}
+	name := "docker"
+	if useSudo {
+		args = append([]string{"docker"}, args...)
+		name = "sudo"
+	}
+	if err := exec.CommandContext(ctx, name, args...).Run(); err != nil { // #nosec G204 - args are constructed internally
+		return fmt.Errorf("image %s is not available locally — on a connected machine run "+
+			"'docker pull %s && docker save %s > inferenced.tar', then 'docker load < inferenced.tar' here",
+			imageRef, imageRef, imageRef)
+	}
+	return nil
+}
+
+// accountKeyringPassword reads the account keyring password from
+// --keyring-password-file/-env, or prompts (twice when confirm is set).
+func accountKeyringPassword(confirm bool) (string, error) {
+	provider, err := secret.Parse(keyringSourceFlag(), config.KeyringPasswordVar)
+	if err != nil {
+		return "", err
+	}
+	prompt, isPrompt := provider.(secret.Prompt)
+	if isPrompt {
+		prompt.Message = "Keyring password for the account key:"
+		prompt.Hint = "use --keyring-password-file or --keyring-password-env"
+		provider = prompt
+	}
+
+	password, err := provider.Secret()
+	if err != nil {
+		return "", fmt.Errorf("keyring password: %w", err)
+	}
+	if len(password) < 8 {
+		return "", fmt.Errorf("keyring password must be at least 8 characters")
+	}
+	if isPrompt && confirm {
+		again, err := secret.Prompt{Message: "Repeat password:"}.Secret()
+		if err != nil {
+			return "", fmt.Errorf("keyring password: %w", err)
+		}
+		if again != password {
+			return "", fmt.Errorf("passwords do not match")
+		}
+	}
+	return password, nil
+}
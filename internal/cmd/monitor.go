@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/inc4/gonka-nop/internal/monitor"
+	"github.com/inc4/gonka-nop/internal/status"
+	"github.com/inc4/gonka-nop/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultMonitorInterval = 30 * time.Second
+	telegramTokenEnv       = "GONKA_TELEGRAM_TOKEN"
+)
+
+var monitorCmd = &cobra.Command{
+	Use:   "monitor",
+	Short: "Alert on node health regressions",
+	Long: `Run a long-lived monitor that polls node status and notifies webhooks,
+Slack or Telegram when a rule starts firing and again when it recovers.
+
+Rules:
+  fell_behind          Node was synced, then fell behind or started catching up
+  low_peers            Connected peers below --min-peers
+  miss_rate            Epoch miss rate above --max-miss-rate
+  poc_status_mismatch  ML node current != intended status for --poc-mismatch-for
+  reward_unclaimed     Previous epoch reward unclaimed after --claim-grace
+  upgrade_approaching  Scheduled upgrade within --upgrade-warn-blocks
+  node_unreachable     Core services unreachable on consecutive polls
+
+Each alert is sent once when it fires (and when its severity changes), then
+suppressed until it resolves. Use --repeat to re-send long-running alerts.
+
+The Telegram bot token can be passed via $GONKA_TELEGRAM_TOKEN to keep it
+out of the process list.
+
+Examples:
+  gonka-nop monitor --slack-webhook https://hooks.slack.com/services/...
+  gonka-nop monitor --telegram-chat-id -100123 --repeat 1h
+  gonka-nop monitor --webhook https://ops.example.com/gonka --min-peers 8
+  gonka-nop monitor --slack-webhook ... --test   # Send a test message and exit`,
+	RunE: runMonitor,
+}
+
+var (
+	monitorInterval      time.Duration
+	monitorRepeat        time.Duration
+	monitorNodeName      string
+	monitorWebhooks      []string
+	monitorSlackWebhook  string
+	monitorTelegramToken string
+	monitorTelegramChat  string
+	monitorAdminURL      string
+	monitorRPCURL        string
+	monitorNodeType      string
+	monitorTest          bool
+	monitorThresholds    = monitor.DefaultThresholds()
+)
+
+func init() {
+	f := monitorCmd.Flags()
+	f.DurationVar(&monitorInterval, "interval", defaultMonitorInterval, "Status polling interval")
+	f.DurationVar(&monitorRepeat, "repeat", 0, "Re-send firing alerts after this long (0 = only on change)")
+	f.StringVar(&monitorNodeName, "node-name", "", "Node name used in messages (default: hostname)")
+	f.StringArrayVar(&monitorWebhooks, "webhook", nil, "Generic JSON webhook URL (repeatable)")
+	f.StringVar(&monitorSlackWebhook, "slack-webhook", "", "Slack incoming webhook URL")
+	f.StringVar(&monitorTelegramToken, "telegram-token", "", "Telegram bot token (or $"+telegramTokenEnv+")")
+	f.StringVar(&monitorTelegramChat, "telegram-chat-id", "", "Telegram chat ID")
+	f.StringVar(&monitorAdminURL, "admin-url", "", "Admin API URL (default from state or http://localhost:9200)")
+	f.StringVar(&monitorRPCURL, "rpc-url", "", "Tendermint RPC URL (default from state or http://localhost:26657)")
+	f.StringVar(&monitorNodeType, "node-type", "", "Node type: full, network or mlnode (default from state)")
+	f.BoolVar(&monitorTest, "test", false, "Send a test notification to all channels and exit")
+
+	f.Int64Var(&monitorThresholds.MaxBlockLag, "max-block-lag", monitorThresholds.MaxBlockLag, "Blocks behind before a synced node counts as fallen behind")
+	f.IntVar(&monitorThresholds.MinPeers, "min-peers", monitorThresholds.MinPeers, "Minimum connected peers")
+	f.Float64Var(&monitorThresholds.MaxMissPercent, "max-miss-rate", monitorThresholds.MaxMissPercent, "Maximum epoch miss rate (percent)")
+	f.DurationVar(&monitorThresholds.PoCMismatchFor, "poc-mismatch-for", monitorThresholds.PoCMismatchFor, "How long ML node status may differ from intended")
+	f.DurationVar(&monitorThresholds.ClaimGrace, "claim-grace", monitorThresholds.ClaimGrace, "How long the previous epoch reward may stay unclaimed")
+	f.Int64Var(&monitorThresholds.UpgradeWarnBlocks, "upgrade-warn-blocks", monitorThresholds.UpgradeWarnBlocks, "Warn when a scheduled upgrade is this many blocks away")
+}
+
+func runMonitor(cmd *cobra.Command, _ []string) error {
+	if monitorInterval < time.Second {
+		return fmt.Errorf("--interval must be at least 1s, got %s", monitorInterval)
+	}
+
+	notifiers, err := buildNotifiers()
+	if err != nil {
+		return err
+	}
+	node := monitorNodeName
+	if node == "" {
+		node, _ = os.Hostname()
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if monitorTest {
+		return sendTestNotification(ctx, node, notifiers)
+	}
+	if len(notifiers) == 0 {
+		ui.Warn("No notifiers configured — alerts are only logged (use --webhook, --slack-webhook or --telegram-chat-id)")
+	}
+
+	cfg, err := resolveStatusConfig(monitorAdminURL, monitorRPCURL, monitorNodeType)
+	if err != nil {
+		return err
+	}
+	mon := monitor.New(node, monitorThresholds)
+	mon.RepeatInterval = monitorRepeat
+
+	ui.Info("Monitoring %s every %s (%d notifier(s))", node, monitorInterval, len(notifiers))
+	ticker := time.NewTicker(monitorInterval)
+	defer ticker.Stop()
+	for {
+		s, err := status.FetchStatusWithConfig(outputDir, cfg)
+		if err != nil {
+			ui.Warn("Status poll failed: %v", err)
+		} else {
+			for _, n := range mon.Observe(s, time.Now()) {
+				deliverNotification(ctx, notifiers, n)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			ui.Info("Monitor stopped")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// buildNotifiers creates a notifier per configured channel.
+func buildNotifiers() ([]monitor.Notifier, error) {
+	notifiers := make([]monitor.Notifier, 0, len(monitorWebhooks)+2)
+	for _, url := range monitorWebhooks {
+		notifiers = append(notifiers, &monitor.WebhookNotifier{URL: url})
+	}
+	if monitorSlackWebhook != "" {
+		notifiers = append(notifiers, &monitor.SlackNotifier{WebhookURL: monitorSlackWebhook})
+	}
+
+	token := monitorTelegramToken
+	if token == "" {
+		token = os.Getenv(telegramTokenEnv)
+	}
+	switch {
+	case monitorTelegramChat != "" && token == "":
+		return nil, fmt.Errorf("--telegram-chat-id requires --telegram-token or $%s", telegramTokenEnv)
+	case monitorTelegramChat == "" && monitorTelegramToken != "":
+		return nil, fmt.Errorf("--telegram-token requires --telegram-chat-id")
+	case monitorTelegramChat != "":
+		notifiers = append(notifiers, &monitor.TelegramNotifier{Token: token, ChatID: monitorTelegramChat})
+	}
+	return notifiers, nil
+}
+
+func deliverNotification(ctx context.Context, notifiers []monitor.Notifier, n monitor.Notification) {
+	text := monitor.FormatText(n)
+	if n.State == monitor.StateResolved {
+		ui.Success("%s", text)
+	} else {
+		ui.Warn("%s", text)
+	}
+	if err := monitor.Dispatch(ctx, notifiers, n); err != nil {
+		ui.Warn("Notification delivery failed: %v", err)
+	}
+}
+
+func sendTestNotification(ctx context.Context, node string, notifiers []monitor.Notifier) error {
+	if len(notifiers) == 0 {
+		return fmt.Errorf("no notifiers configured")
+	}
+	now := time.Now()
+	n := monitor.Notification{
+		Node:     node,
+		State:    monitor.StateFiring,
+		Rule:     "test",
+		Severity: monitor.SeverityWarning,
+		Message:  "Test notification from gonka-nop monitor",
+		Since:    now,
+		Time:     now,
+	}
+	if err := monitor.Dispatch(ctx, notifiers, n); err != nil {
+		return fmt.Errorf("send test notification: %w", err)
+	}
+	ui.Success("Test notification sent to %d notifier(s)", len(notifiers))
+	return nil
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/inc4/gonka-nop/internal/config"
+	"github.com/inc4/gonka-nop/internal/phases"
+	"github.com/inc4/gonka-nop/internal/status"
+	"github.com/spf13/cobra"
+)
+
+var (
+	preflightFormat    string
+	preflightNodeType  string
+	preflightAllowPull bool
+)
+
+var preflightCmd = &cobra.Command{
+	Use:   "preflight",
+	Short: "Check whether this host is ready for a node, without changing it",
+	Long: `Run the prerequisite checks from setup without installing, starting,
+mounting or pulling anything, and report each one as pass, warn, fail or
+skip.
+
+Checks: Linux distribution, Docker and Compose versions, NVIDIA driver and
+its consistency with the kernel module and Fabric Manager, the container
+toolkit, CUDA inside Docker, Fabric Manager on multi-GPU hosts, Secure Boot,
+kernel headers, unattended-upgrades, required ports and the disk layout of
+the output directory. GPU checks are skipped for --type network.
+
+The CUDA check uses a local nvidia/cuda image; with --allow-pull it pulls
+the image when missing, otherwise the check is skipped.
+
+Exit codes: 0 all checks pass, 2 at least one warning, 3 at least one
+failure.
+
+Examples:
+  gonka-nop preflight
+  gonka-nop preflight --type network
+  gonka-nop preflight --format json --allow-pull > host-report.json`,
+	RunE: runPreflight,
+}
+
+func init() {
+	preflightCmd.Flags().StringVar(&preflightFormat, "format", status.FormatText, "Output format: text or json")
+	preflightCmd.Flags().StringVar(&preflightNodeType, "type", "", "Node type to check for: full, network or mlnode (default: from state, else full)")
+	preflightCmd.Flags().BoolVar(&preflightAllowPull, "allow-pull", false, "Pull the CUDA test image if it is not present")
+}
+
+func runPreflight(cmd *cobra.Command, _ []string) error {
+	format, err := status.ParseFormat(preflightFormat)
+	if err != nil {
+		return err
+	}
+	if format == status.FormatYAML {
+		return fmt.Errorf("preflight supports text and json output")
+	}
+
+	// State is read for ports and the node type, never saved. Load is
+	// read-only: migrating an older state.json waits for the next Save.
+	state, err := config.Load(outputDir)
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	if state.OutputDir == "" {
+		state.OutputDir = outputDir
+	}
+	switch preflightNodeType {
+	case "":
+	case config.NodeTypeFull, config.NodeTypeNetwork, config.NodeTypeMLNode:
+		state.NodeType = preflightNodeType
+	default:
+		return fmt.Errorf("invalid --type value %q (must be full, network, or mlnode)", preflightNodeType)
+	}
+
+	p := phases.NewPreflight()
+	p.AllowPull = preflightAllowPull
+	rep := p.Run(cmd.Context(), state)
+
+	if format == status.FormatJSON {
+		if err := writePreflightJSON(os.Stdout, rep); err != nil {
+			return err
+		}
+	} else {
+		displayPreflightReport(os.Stdout, rep)
+	}
+	return preflightExitError(cmd, rep)
+}
+
+func writePreflightJSON(w io.Writer, rep *phases.PreflightReport) error {
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal preflight report: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func displayPreflightReport(w io.Writer, rep *phases.PreflightReport) {
+	colors := map[string]*color.Color{
+		phases.PreflightPass: color.New(color.FgGreen),
+		phases.PreflightWarn: color.New(color.FgYellow),
+		phases.PreflightFail: color.New(color.FgRed),
+		phases.PreflightSkip: color.New(color.Faint),
+	}
+	boldC := color.New(color.Bold)
+
+	_, _ = boldC.Fprintf(w, "\nPreflight (%s node)\n", rep.NodeType)
+	_, _ = fmt.Fprintln(w, strings.Repeat("─", 60))
+	_, _ = fmt.Fprintf(w, "  %-6s %-26s %s\n", "RESULT", "CHECK", "DETAIL")
+	for _, c := range rep.Checks {
+		_, _ = fmt.Fprint(w, "  ")
+		_, _ = colors[c.Result].Fprintf(w, "%-6s", strings.ToUpper(c.Result))
+		_, _ = fmt.Fprintf(w, " %-26s %s\n", c.Name, c.Message)
+		if c.Fix != "" && c.Result != phases.PreflightPass {
+			_, _ = fmt.Fprintf(w, "  %-6s %-26s → %s\n", "", "", c.Fix)
+		}
+	}
+	_, _ = fmt.Fprintf(w, "\n  %d pass, %d warn, %d fail, %d skip\n\n",
+		rep.Count(phases.PreflightPass), rep.Count(phases.PreflightWarn),
+		rep.Count(phases.PreflightFail), rep.Count(phases.PreflightSkip))
+}
+
+// preflightExitError maps the overall result to the status exit codes.
+func preflightExitError(cmd *cobra.Command, rep *phases.PreflightReport) error {
+	var code int
+	switch rep.Result {
+	case phases.PreflightFail:
+		code = exitHealthCritical
+	case phases.PreflightWarn:
+		code = exitHealthWarning
+	default:
+		return nil
+	}
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	return &ExitCodeError{Code: code, Err: fmt.Errorf("preflight: %s", rep.Result)}
+}
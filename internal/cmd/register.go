@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/inc4/gonka-nop/internal/config"
 	"github.com/inc4/gonka-nop/internal/phases"
@@ -9,7 +10,12 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var forceRegister bool
+var (
+	forceRegister   bool
+	registerSigned  string
+	registerRegen   bool
+	registerAccount string
+)
 
 var registerCmd = &cobra.Command{
 	Use:   "register",
@@ -21,17 +27,28 @@ scenarios (e.g., IP changed, key rotated).
 
 The registration workflow depends on your setup:
   - Quick (mainnet): Automated registration using on-server keys
-  - Secure (mainnet): Shows manual commands for local machine
+  - Secure (mainnet): Offline signing — the account key never touches the server
   - Testnet: Automated attempt, falls back to manual if account not funded
 
+Secure workflow, three steps (progress is kept in state.json):
+  1. Server:       gonka-nop register                 # writes offline-tx/unsigned-tx.json
+  2. Cold machine: gonka-nop sign unsigned-tx.json    # writes signed-tx.json
+  3. Server:       gonka-nop register --signed signed-tx.json
+
 Examples:
   gonka-nop register           # Register based on saved state
-  gonka-nop register --force   # Re-register even if already registered`,
+  gonka-nop register --force   # Re-register even if already registered
+  gonka-nop register --signed signed-tx.json`,
 	RunE: runRegister,
 }
 
 func init() {
 	registerCmd.Flags().BoolVar(&forceRegister, "force", false, "Force re-registration even if already registered")
+	registerCmd.Flags().StringVar(&registerSigned, "signed", "", "Broadcast transactions signed with 'gonka-nop sign' (secure workflow)")
+	registerCmd.Flags().BoolVar(&registerRegen, "regenerate", false, "Discard previously generated unsigned transactions (secure workflow)")
+	registerCmd.Flags().StringVar(&registerAccount, "account-address", "", "Account (cold) key address, if setup did not record it (secure workflow)")
+	registerCmd.MarkFlagsMutuallyExclusive("signed", "regenerate")
+	addKeyringSourceFlags(registerCmd)
 }
 
 func runRegister(cmd *cobra.Command, _ []string) error {
@@ -47,6 +64,13 @@ func runRegister(cmd *cobra.Command, _ []string) error {
 		return fmt.Errorf("node not configured — run 'gonka-nop setup' first")
 	}
 
+	if registerAccount != "" {
+		if !strings.HasPrefix(registerAccount, "gonka1") {
+			return fmt.Errorf("--account-address %q is not a gonka address", registerAccount)
+		}
+		state.ColdKeyAddress = registerAccount
+	}
+
 	// Check if already registered (unless --force)
 	if state.NodeRegistered && !forceRegister {
 		ui.Success("Node is already registered")
@@ -54,18 +78,21 @@ func runRegister(cmd *cobra.Command, _ []string) error {
 		return nil
 	}
 
-	// Prompt for keyring password (not persisted to disk for security)
-	if state.KeyringPassword == "" && state.ColdKeyName != "" {
-		password, promptErr := ui.Password("Enter keyring password:")
-		if promptErr != nil {
-			return fmt.Errorf("password prompt: %w", promptErr)
+	// The keyring password is never persisted; resolve it from its source
+	if src := keyringSourceFlag(); src != "" {
+		state.KeyringPasswordSource = src
+	}
+	if state.ColdKeyName != "" {
+		if _, err := state.ResolveKeyringPassword(); err != nil {
+			return err
 		}
-		state.KeyringPassword = password
 	}
 
 	ui.Header("Node Registration")
 
 	phase := phases.NewRegistration()
+	phase.SignedTxFile = registerSigned
+	phase.Regenerate = registerRegen
 	if err := phase.Run(ctx, state); err != nil {
 		return err
 	}
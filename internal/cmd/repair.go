@@ -79,27 +79,27 @@ func init() {
 
 // Diagnosis represents a detected problem.
 type Diagnosis struct {
-	ID          string // "missing_upgrade_handler", "stale_upgrade_info", "broken_symlink"
-	Severity    string // "critical", "warning"
-	Description string
-	FixAction   string
-	UpgradeName string
+	ID          string `json:"id"`       // "missing_upgrade_handler", "stale_upgrade_info", "broken_symlink"
+	Severity    string `json:"severity"` // "critical", "warning"
+	Description string `json:"description"`
+	FixAction   string `json:"fix_action"`
+	UpgradeName string `json:"upgrade_name,omitempty"`
 }
 
 // RepairPlan holds the diagnosis results and repair strategy.
 type RepairPlan struct {
-	Diagnoses         []Diagnosis
-	UpgradeName       string         // from logs or admin API
-	NeedsBinary       bool           // whether binary download is required
-	UpgradeInfoAssets []ReleaseAsset // from upgrade-info.json info field (on-chain source of truth)
+	Diagnoses         []Diagnosis    `json:"diagnoses"`
+	UpgradeName       string         `json:"upgrade_name,omitempty"`        // from logs or admin API
+	NeedsBinary       bool           `json:"needs_binary"`                  // whether binary download is required
+	UpgradeInfoAssets []ReleaseAsset `json:"upgrade_info_assets,omitempty"` // from upgrade-info.json info field (on-chain source of truth)
 }
 
 // ReleaseAsset holds a downloadable binary from a GitHub release.
 type ReleaseAsset struct {
-	Name        string
-	DownloadURL string
-	SHA256      string
-	Size        int64
+	Name        string `json:"name"`
+	DownloadURL string `json:"download_url"`
+	SHA256      string `json:"sha256,omitempty"`
+	Size        int64  `json:"size,omitempty"`
 }
 
 // upgradeHandlerMissingRe matches the Cosmovisor upgrade handler panic message.
@@ -524,8 +524,7 @@ func downloadAndPlaceBinaries(ctx context.Context, state *config.State, plan *Re
 
 		// Create directory and extract binary
 		destDir := filepath.Join(state.OutputDir, bin.destDir)
-		if err := runHostCmd(ctx, state.UseSudo, state.OutputDir,
-			fmt.Sprintf("mkdir -p %s", shellQuote(destDir))); err != nil {
+		if err := runHostArgs(ctx, state.UseSudo, state.OutputDir, "mkdir", "-p", destDir); err != nil {
 			return fmt.Errorf("create dir %s: %w", destDir, err)
 		}
 
@@ -535,8 +534,7 @@ func downloadAndPlaceBinaries(ctx context.Context, state *config.State, plan *Re
 		}
 
 		// chmod +x
-		if err := runHostCmd(ctx, state.UseSudo, state.OutputDir,
-			fmt.Sprintf("chmod +x %s", shellQuote(destPath))); err != nil {
+		if err := runHostArgs(ctx, state.UseSudo, state.OutputDir, "chmod", "+x", destPath); err != nil {
 			return fmt.Errorf("chmod %s: %w", destPath, err)
 		}
 
@@ -901,8 +899,7 @@ func copyFile(src, dst string) error {
 // removeUpgradeInfo removes the stale upgrade-info.json file.
 func removeUpgradeInfo(ctx context.Context, state *config.State) error {
 	infoPath := filepath.Join(state.OutputDir, ".inference", "data", "upgrade-info.json")
-	return runHostCmd(ctx, state.UseSudo, state.OutputDir,
-		fmt.Sprintf("rm -f %s", shellQuote(infoPath)))
+	return runHostArgs(ctx, state.UseSudo, state.OutputDir, "rm", "-f", infoPath)
 }
 
 // fixSymlinks fixes broken Cosmovisor current symlinks for both node and api.
@@ -943,8 +940,7 @@ func fixCosmovisorSymlink(ctx context.Context, state *config.State, symlinkPath,
 		relTarget = target
 	}
 
-	return runHostCmd(ctx, state.UseSudo, state.OutputDir,
-		fmt.Sprintf("ln -snf %s %s", shellQuote(relTarget), shellQuote(symlinkPath)))
+	return runHostArgs(ctx, state.UseSudo, state.OutputDir, "ln", "-snf", relTarget, symlinkPath)
 }
 
 // stopRepairNode stops the node container using only the first compose file.
@@ -961,51 +957,7 @@ func stopRepairNode(ctx context.Context, state *config.State) error {
 	stopCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	// Use run() to execute "stop node" — but ComposeClient doesn't have Stop.
-	// Use the underlying run method by constructing args.
-	// Actually, let's just use exec directly.
-	return composeStop(stopCtx, state, "node")
-}
-
-// composeStop runs docker compose stop <service>.
-func composeStop(ctx context.Context, state *config.State, service string) error {
-	files := state.ComposeFiles
-	if len(files) == 0 {
-		files = []string{"docker-compose.yml"}
-	}
-	// Use only first file for node operations
-	if len(files) > 1 {
-		files = files[:1]
-	}
-
-	cmdArgs := make([]string, 0, 1+2*len(files)+2)
-	cmdArgs = append(cmdArgs, "compose")
-	for _, f := range files {
-		cmdArgs = append(cmdArgs, "-f", f)
-	}
-	cmdArgs = append(cmdArgs, "stop", service)
-
-	var cmd *exec.Cmd
-	if state.UseSudo {
-		sudoArgs := append([]string{"-E", "docker"}, cmdArgs...)
-		cmd = exec.CommandContext(ctx, "sudo", sudoArgs...) // #nosec G204
-	} else {
-		cmd = exec.CommandContext(ctx, "docker", cmdArgs...) // #nosec G204
-	}
-	cmd.Dir = state.OutputDir
-
-	// Load env for compose
-	envFile := state.OutputDir + "/config.env"
-	fileEnv, envErr := docker.ParseEnvFile(envFile)
-	if envErr == nil && len(fileEnv) > 0 {
-		cmd.Env = docker.MergeEnv(fileEnv)
-	}
-
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("docker compose stop %s: %w\n%s", service, err, string(out))
-	}
-	return nil
+	return cc.Stop(stopCtx, "node")
 }
 
 // startRepairNode starts the node container.
@@ -1074,6 +1026,23 @@ func runHostCmd(ctx context.Context, useSudo bool, dir, shellCmd string) error {
 	return nil
 }
 
+// runHostArgs executes a command on the host without a shell, with sudo if needed.
+func runHostArgs(ctx context.Context, useSudo bool, dir, name string, args ...string) error {
+	var cmd *exec.Cmd
+	if useSudo {
+		cmd = exec.CommandContext(ctx, "sudo", append([]string{name}, args...)...) // #nosec G204
+	} else {
+		cmd = exec.CommandContext(ctx, name, args...) // #nosec G204
+	}
+	cmd.Dir = dir
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w\n%s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
 // readFileOptionalSudo reads a file, falling back to sudo cat if needed.
 func readFileOptionalSudo(ctx context.Context, state *config.State, path string) ([]byte, error) {
 	data, err := os.ReadFile(path) // #nosec G304 - path from trusted state
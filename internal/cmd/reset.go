@@ -0,0 +1,347 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/inc4/gonka-nop/internal/config"
+	"github.com/inc4/gonka-nop/internal/docker"
+	"github.com/inc4/gonka-nop/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// Reset scopes, from least to most destructive.
+const (
+	resetScopeChain = "chain" // blockchain data only
+	resetScopeDAPI  = "dapi"  // blockchain data + API .dapi cache
+	resetScopeAll   = "all"   // everything, including keys (backup first)
+)
+
+const (
+	resetBackupDir   = "backups"
+	resetStopTimeout = 2 * time.Minute
+)
+
+var resetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Reset blockchain data while preserving keys",
+	Long: `Stop all containers and reset blockchain data.
+Preserves keys and configuration files by default.
+
+This is useful when:
+  - Your node is stuck or corrupted
+  - You need to re-sync from scratch
+  - You want to clear old blockchain data
+
+Scopes:
+  chain   Run unsafe-reset-all on .inference (default)
+  dapi    Same as chain, plus the API state cache in .dapi
+  all     Wipe .inference, .dapi and .tmkms, including keys.
+          A backup archive is written to <output>/backups first.
+
+Always preserved (except with --all): .tmkms, the keyring, state.json.
+
+Examples:
+  gonka-nop reset                 # Reset blockchain data, keep keys
+  gonka-nop reset --scope dapi    # Also clear the API cache
+  gonka-nop reset --all           # Reset everything (WARNING: deletes keys)`,
+	RunE: runReset,
+}
+
+var (
+	resetScope   string
+	resetAll     bool
+	resetForce   bool
+	resetNoStart bool
+)
+
+func init() {
+	resetCmd.Flags().StringVar(&resetScope, "scope", resetScopeChain, "Reset scope: chain, dapi or all")
+	resetCmd.Flags().BoolVar(&resetAll, "all", false, "Reset everything including keys (same as --scope all)")
+	resetCmd.Flags().BoolVar(&resetForce, "force", false, "Skip the confirmation prompt (--all still asks before deleting keys)")
+	resetCmd.Flags().BoolVar(&resetNoStart, "no-start", false, "Do not start containers after the reset")
+}
+
+// ResetPlan describes what a reset will touch.
+type ResetPlan struct {
+	Scope      string
+	ChainReset bool     // run inferenced tendermint unsafe-reset-all
+	Remove     []string // paths relative to the output dir to delete
+	Preserve   []string // paths relative to the output dir that are kept
+	Backup     []string // paths relative to the output dir to archive before deleting
+}
+
+func runReset(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+
+	scope, err := parseResetScope(resetScope, resetAll)
+	if err != nil {
+		return err
+	}
+
+	state, err := config.Load(outputDir)
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(state.OutputDir, "docker-compose.yml")); statErr != nil {
+		return fmt.Errorf("no deployment found in %s — run 'gonka-nop setup' first", outputDir)
+	}
+	if state.IsMLNodeOnly() {
+		return fmt.Errorf("reset is not applicable to ML node-only deployments (no chain data)")
+	}
+
+	keyringDir, err := keyringRelDir(state)
+	if err != nil {
+		return err
+	}
+	plan := buildResetPlan(scope, keyringDir)
+	displayResetPlan(plan)
+
+	if !resetForce {
+		confirm, confirmErr := ui.Confirm("Stop containers and reset?", false)
+		if confirmErr != nil {
+			return confirmErr
+		}
+		if !confirm {
+			ui.Info("Reset canceled.")
+			return nil
+		}
+	}
+	if plan.Scope == resetScopeAll {
+		confirm, confirmErr := ui.Confirm("This deletes consensus and account keys. Continue?", false)
+		if confirmErr != nil {
+			return confirmErr
+		}
+		if !confirm {
+			ui.Info("Reset canceled.")
+			return nil
+		}
+	}
+
+	return executeReset(ctx, state, plan)
+}
+
+// parseResetScope resolves the --scope and --all flags into a single scope.
+func parseResetScope(scope string, all bool) (string, error) {
+	if all {
+		return resetScopeAll, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(scope)) {
+	case "", resetScopeChain:
+		return resetScopeChain, nil
+	case resetScopeDAPI, "chain+dapi":
+		return resetScopeDAPI, nil
+	case resetScopeAll:
+		return resetScopeAll, nil
+	default:
+		return "", fmt.Errorf("unknown reset scope %q (valid: chain, dapi, all)", scope)
+	}
+}
+
+// buildResetPlan returns the actions for a scope. Paths are relative to the
+// output dir, like keyringDir (state.KeyringDir, see keyringRelDir).
+func buildResetPlan(scope, keyringDir string) *ResetPlan {
+	plan := &ResetPlan{Scope: scope}
+	keyring := filepath.Join(keyringDir, "keyring-file")
+
+	switch scope {
+	case resetScopeAll:
+		plan.Backup = []string{".tmkms", keyring,
+			filepath.Join(".inference", "config"), "state.json", "config.env", "node-config.json"}
+		plan.Remove = []string{".inference", ".dapi", ".tmkms"}
+		if !insideRel(".inference", keyring) {
+			plan.Remove = append(plan.Remove, keyring)
+		}
+		plan.Preserve = []string{"docker-compose.yml", "config.env", resetBackupDir}
+	case resetScopeDAPI:
+		plan.ChainReset = true
+		plan.Remove = []string{filepath.Join(".dapi", "*")}
+		plan.Preserve = []string{".tmkms", keyring,
+			filepath.Join(".inference", "config"), filepath.Join(".dapi", "cosmovisor"), "state.json"}
+	default:
+		plan.ChainReset = true
+		plan.Preserve = []string{".tmkms", keyring,
+			filepath.Join(".inference", "config"), ".dapi", "state.json"}
+	}
+	return plan
+}
+
+// insideRel reports whether the relative path p is dir or below it.
+func insideRel(dir, p string) bool {
+	return p == dir || strings.HasPrefix(p, dir+string(filepath.Separator))
+}
+
+// displayResetPlan shows what the reset will do.
+func displayResetPlan(plan *ResetPlan) {
+	boldC := color.New(color.Bold)
+	redC := color.New(color.FgRed)
+	greenC := color.New(color.FgGreen)
+
+	_, _ = boldC.Printf("\nReset Plan (scope: %s)\n", plan.Scope)
+	fmt.Println(strings.Repeat("─", 60))
+
+	fmt.Println("  - Stop all containers")
+	if plan.ChainReset {
+		fmt.Println("  - inferenced tendermint unsafe-reset-all (.inference/data)")
+	}
+	if len(plan.Backup) > 0 {
+		fmt.Printf("  - Write backup archive to %s/\n", resetBackupDir)
+	}
+	for _, p := range plan.Remove {
+		_, _ = redC.Printf("  - Delete %s\n", p)
+	}
+	for _, p := range plan.Preserve {
+		_, _ = greenC.Printf("  - Keep   %s\n", p)
+	}
+	fmt.Println()
+}
+
+// executeReset stops containers and applies the reset plan.
+func executeReset(ctx context.Context, state *config.State, plan *ResetPlan) error {
+	cc, err := docker.NewComposeClient(state)
+	if err != nil {
+		return err
+	}
+
+	ui.Info("Stopping containers...")
+	stopCtx, cancel := context.WithTimeout(ctx, resetStopTimeout)
+	err = cc.Down(stopCtx)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("stop containers: %w", err)
+	}
+	ui.Success("Containers stopped")
+
+	if len(plan.Backup) > 0 {
+		archive, backupErr := writeResetBackup(ctx, state, plan.Backup)
+		if backupErr != nil {
+			return fmt.Errorf("backup failed, nothing was deleted: %w", backupErr)
+		}
+		ui.Success("Backup written: %s", archive)
+	}
+
+	if plan.ChainReset {
+		ui.Info("Resetting blockchain data...")
+		if err := resetChainData(ctx, cc); err != nil {
+			return fmt.Errorf("unsafe-reset-all: %w", err)
+		}
+		ui.Success("Blockchain data reset")
+	}
+
+	for _, rel := range plan.Remove {
+		target := filepath.Join(state.OutputDir, rel)
+		if err := removeResetPath(ctx, state, target); err != nil {
+			return fmt.Errorf("remove %s: %w", rel, err)
+		}
+		ui.Success("Removed %s", rel)
+	}
+
+	if plan.Scope == resetScopeAll {
+		state.Reset()
+		if err := state.Save(); err != nil {
+			return fmt.Errorf("save state: %w", err)
+		}
+		ui.Success("State cleared. Run 'gonka-nop setup' to set up the node again.")
+		return nil
+	}
+
+	if resetNoStart {
+		ui.Info("Containers left stopped. Start with: docker compose up -d")
+		printKeyringEnvHint(state)
+		return nil
+	}
+
+	ui.Info("Starting containers...")
+	if err := cc.Up(ctx); err != nil {
+		return fmt.Errorf("failed to start containers: %w", err)
+	}
+	ui.Success("Reset complete. Node will re-sync from snapshot. Monitor with: gonka-nop status")
+	return nil
+}
+
+// resetChainData runs unsafe-reset-all in a one-off node container.
+// The address book is kept so the node reconnects to peers quickly.
+func resetChainData(ctx context.Context, cc *docker.ComposeClient) error {
+	nodeCC := *cc
+	// Node is in first compose file only
+	if len(nodeCC.Files) > 1 {
+		nodeCC.Files = nodeCC.Files[:1]
+	}
+	return nodeCC.Run(ctx, "node", resetChainCommand()...)
+}
+
+// resetChainCommand returns the argv for the in-container chain reset.
+func resetChainCommand() []string {
+	return []string{"inferenced", "tendermint", "unsafe-reset-all",
+		"--home", "/root/.inference", "--keep-addr-book"}
+}
+
+// removeResetPath deletes a path under the output dir. A trailing "*" clears
+// the directory's contents while keeping its Cosmovisor binaries.
+func removeResetPath(ctx context.Context, state *config.State, target string) error {
+	if !strings.HasPrefix(target, state.OutputDir+string(filepath.Separator)) {
+		return fmt.Errorf("refusing to remove path outside output dir: %s", target)
+	}
+
+	if filepath.Base(target) == "*" {
+		dir := filepath.Dir(target)
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			return nil
+		}
+		shellCmd := fmt.Sprintf("find %s -mindepth 1 -maxdepth 1 ! -name cosmovisor -exec rm -rf {} +",
+			shellQuote(dir))
+		return runHostCmd(ctx, state.UseSudo, state.OutputDir, shellCmd)
+	}
+
+	return runHostCmd(ctx, state.UseSudo, state.OutputDir, fmt.Sprintf("rm -rf %s", shellQuote(target)))
+}
+
+// writeResetBackup archives the given paths (those that exist) into
+// <output>/backups/gonka-reset-<timestamp>.tar.gz. Uses tar on the host so
+// root-owned container files can be read with sudo.
+func writeResetBackup(ctx context.Context, state *config.State, paths []string) (string, error) {
+	backupDir := filepath.Join(state.OutputDir, resetBackupDir)
+	if err := os.MkdirAll(backupDir, 0750); err != nil {
+		return "", fmt.Errorf("create backup dir: %w", err)
+	}
+
+	existing := existingPaths(state.OutputDir, paths)
+	if len(existing) == 0 {
+		return "", fmt.Errorf("nothing to back up in %s", state.OutputDir)
+	}
+
+	archive := filepath.Join(backupDir, fmt.Sprintf("gonka-reset-%s.tar.gz", time.Now().UTC().Format("20060102-150405")))
+	if err := runHostCmd(ctx, state.UseSudo, state.OutputDir, resetBackupCommand(archive, state.OutputDir, existing)); err != nil {
+		return "", err
+	}
+	if state.UseSudo {
+		_ = runHostCmd(ctx, true, state.OutputDir, fmt.Sprintf("chmod 600 %s", shellQuote(archive)))
+	}
+	return archive, nil
+}
+
+// resetBackupCommand builds the tar command for the backup archive.
+func resetBackupCommand(archive, baseDir string, paths []string) string {
+	quoted := make([]string, 0, len(paths))
+	for _, p := range paths {
+		quoted = append(quoted, shellQuote(p))
+	}
+	return fmt.Sprintf("umask 077 && tar -czf %s -C %s %s",
+		shellQuote(archive), shellQuote(baseDir), strings.Join(quoted, " "))
+}
+
+// existingPaths filters relative paths to those present under baseDir.
+func existingPaths(baseDir string, paths []string) []string {
+	var out []string
+	for _, p := range paths {
+		if _, err := os.Lstat(filepath.Join(baseDir, p)); err == nil {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/inc4/gonka-nop/internal/config"
+)
+
+func TestParseResetScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		scope   string
+		all     bool
+		want    string
+		wantErr bool
+	}{
+		{name: "default", scope: "", want: resetScopeChain},
+		{name: "chain", scope: "chain", want: resetScopeChain},
+		{name: "dapi", scope: "dapi", want: resetScopeDAPI},
+		{name: "chain+dapi alias", scope: "chain+dapi", want: resetScopeDAPI},
+		{name: "case insensitive", scope: "ALL", want: resetScopeAll},
+		{name: "all flag wins", scope: "chain", all: true, want: resetScopeAll},
+		{name: "unknown", scope: "everything", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseResetScope(tt.scope, tt.all)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("scope = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildResetPlan(t *testing.T) {
+	keyring := filepath.Join(".inference", "keyring-file")
+
+	tests := []struct {
+		name         string
+		scope        string
+		chainReset   bool
+		wantRemove   []string
+		wantBackup   bool
+		mustPreserve []string
+	}{
+		{
+			name:         "chain keeps keys and dapi",
+			scope:        resetScopeChain,
+			chainReset:   true,
+			mustPreserve: []string{".tmkms", keyring, ".dapi", "state.json"},
+		},
+		{
+			name:         "dapi clears cache but keeps binaries",
+			scope:        resetScopeDAPI,
+			chainReset:   true,
+			wantRemove:   []string{filepath.Join(".dapi", "*")},
+			mustPreserve: []string{".tmkms", keyring, filepath.Join(".dapi", "cosmovisor"), "state.json"},
+		},
+		{
+			name:       "all wipes keys after backup",
+			scope:      resetScopeAll,
+			wantRemove: []string{".inference", ".dapi", ".tmkms"},
+			wantBackup: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := buildResetPlan(tt.scope, ".inference")
+			if plan.ChainReset != tt.chainReset {
+				t.Errorf("ChainReset = %v, want %v", plan.ChainReset, tt.chainReset)
+			}
+			if strings.Join(plan.Remove, ",") != strings.Join(tt.wantRemove, ",") {
+				t.Errorf("Remove = %v, want %v", plan.Remove, tt.wantRemove)
+			}
+			if (len(plan.Backup) > 0) != tt.wantBackup {
+				t.Errorf("Backup = %v, wantBackup %v", plan.Backup, tt.wantBackup)
+			}
+			for _, p := range tt.mustPreserve {
+				if !slices.Contains(plan.Preserve, p) {
+					t.Errorf("Preserve %v missing %q", plan.Preserve, p)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildResetPlan_AllBacksUpKeys(t *testing.T) {
+	plan := buildResetPlan(resetScopeAll, ".inference")
+	for _, p := range []string{".tmkms", filepath.Join(".inference", "keyring-file"), "state.json"} {
+		if !slices.Contains(plan.Backup, p) {
+			t.Errorf("Backup %v missing %q", plan.Backup, p)
+		}
+	}
+}
+
+func TestBuildResetPlan_KeyringDir(t *testing.T) {
+	keyring := filepath.Join("keys", "keyring-file")
+
+	plan := buildResetPlan(resetScopeAll, "keys")
+	if !slices.Contains(plan.Backup, keyring) || slices.Contains(plan.Backup, filepath.Join(".inference", "keyring-file")) {
+		t.Errorf("Backup = %v, want the state's keyring dir", plan.Backup)
+	}
+	if !slices.Contains(plan.Remove, keyring) {
+		t.Errorf("Remove = %v, want %s", plan.Remove, keyring)
+	}
+	if plan := buildResetPlan(resetScopeChain, "keys"); !slices.Contains(plan.Preserve, keyring) {
+		t.Errorf("Preserve = %v, want %s", plan.Preserve, keyring)
+	}
+}
+
+func TestResetChainCommand(t *testing.T) {
+	got := strings.Join(resetChainCommand(), " ")
+	want := "inferenced tendermint unsafe-reset-all --home /root/.inference --keep-addr-book"
+	if got != want {
+		t.Errorf("command = %q, want %q", got, want)
+	}
+}
+
+func TestResetBackupCommand(t *testing.T) {
+	cmd := resetBackupCommand("/out/backups/b.tar.gz", "/out", []string{".tmkms", "state.json"})
+	want := "umask 077 && tar -czf '/out/backups/b.tar.gz' -C '/out' '.tmkms' 'state.json'"
+	if cmd != want {
+		t.Errorf("command = %q, want %q", cmd, want)
+	}
+}
+
+func TestExistingPaths(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".tmkms"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "state.json"), []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got := existingPaths(dir, []string{".tmkms", ".inference/keyring-file", "state.json"})
+	if strings.Join(got, ",") != ".tmkms,state.json" {
+		t.Errorf("existingPaths = %v", got)
+	}
+}
+
+func TestRemoveResetPath_RefusesOutsideOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	state := &config.State{OutputDir: dir}
+	if err := removeResetPath(t.Context(), state, filepath.Dir(dir)); err == nil {
+		t.Error("expected error for path outside output dir")
+	}
+}
+
+func TestRemoveResetPath_ClearsDirKeepingCosmovisor(t *testing.T) {
+	dir := t.TempDir()
+	dapi := filepath.Join(dir, ".dapi")
+	for _, sub := range []string{"cosmovisor/genesis", "gonka.db"} {
+		if err := os.MkdirAll(filepath.Join(dapi, sub), 0o750); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dapi, "api-config.yaml"), []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	state := &config.State{OutputDir: dir}
+	if err := removeResetPath(t.Context(), state, filepath.Join(dapi, "*")); err != nil {
+		t.Fatalf("removeResetPath: %v", err)
+	}
+
+	entries, err := os.ReadDir(dapi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "cosmovisor" {
+		t.Errorf("remaining entries = %v, want only cosmovisor", entries)
+	}
+}
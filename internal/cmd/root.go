@@ -1,12 +1,19 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/inc4/gonka-nop/internal/config"
 	"github.com/inc4/gonka-nop/internal/status"
+	"github.com/inc4/gonka-nop/internal/ui"
 	"github.com/spf13/cobra"
 )
 
@@ -18,8 +25,22 @@ var (
 	// Global flags
 	outputDir string
 	verbose   bool
+
+	// outputLock is held by commands annotated with annotationLocksOutput
+	// until Execute returns.
+	outputLock *config.Lock
 )
 
+// annotationLocksOutput marks commands that change state.json or the files
+// in the output directory. They take the output directory lock, so two such
+// commands cannot interleave their writes. The value is lockCreatesDir for
+// commands that may start from an empty output directory.
+const annotationLocksOutput = "gonka-nop/locks-output"
+
+// lockCreatesDir is the annotationLocksOutput value of commands that create
+// the output directory when it is missing.
+const lockCreatesDir = "create"
+
 // SetVersionInfo sets the version information for the CLI
 func SetVersionInfo(v, c, d string) {
 	version = v
@@ -36,17 +57,63 @@ for the Gonka decentralized AI network.
 It automatically detects your GPU hardware, configures the NVIDIA container
 runtime, generates optimal configurations, and deploys all required services.`,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		printLogo()
+		if !machineReadableOutput(cmd) {
+			printLogo()
+		}
 		// Resolve outputDir to absolute path — Docker bind mounts require absolute paths
 		abs, err := filepath.Abs(outputDir)
 		if err != nil {
 			return fmt.Errorf("resolve output directory: %w", err)
 		}
 		outputDir = abs
+
+		if mode := cmd.Annotations[annotationLocksOutput]; mode != "" && !planOnly(cmd) {
+			if mode == lockCreatesDir {
+				if err := os.MkdirAll(outputDir, 0750); err != nil {
+					return fmt.Errorf("create output directory: %w", err)
+				}
+			}
+			lock, err := config.LockOutputDir(outputDir)
+			if err != nil {
+				return err
+			}
+			outputLock = lock
+			return upgradeOutputDir(outputDir)
+		}
 		return nil
 	},
 }
 
+// planOnly reports whether cmd only prints what it would do (setup --plan).
+// Such a run changes nothing: no output directory, no lock, no state
+// upgrade; state is migrated in memory by config.Load only.
+func planOnly(cmd *cobra.Command) bool {
+	plan, err := cmd.Flags().GetBool("plan")
+	return err == nil && plan
+}
+
+// upgradeOutputDir writes what config.Load migrated in memory, including
+// moving a plaintext keyring password out of the output directory. Only
+// commands holding the output lock call it; the others leave an older
+// output directory as it is.
+func upgradeOutputDir(dir string) error {
+	state, err := config.Load(dir)
+	if err != nil {
+		return fmt.Errorf("load state: %w", err)
+	}
+	if !state.MigrationPending() {
+		return nil
+	}
+	if err := state.MigrateKeyringPassword(); err != nil {
+		return fmt.Errorf("upgrade output directory: %w", err)
+	}
+	if err := state.Save(); err != nil {
+		return fmt.Errorf("upgrade output directory: %w", err)
+	}
+	ui.Info("Upgraded %s from an older gonka-nop version", dir)
+	return nil
+}
+
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&outputDir, "output", "o", "./gonka-node", "Output directory for configuration files")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
@@ -54,6 +121,8 @@ func init() {
 	rootCmd.AddCommand(setupCmd)
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(registerCmd)
+	rootCmd.AddCommand(initAccountCmd)
+	rootCmd.AddCommand(signCmd)
 	rootCmd.AddCommand(resetCmd)
 	rootCmd.AddCommand(gpuInfoCmd)
 	rootCmd.AddCommand(versionCmd)
@@ -62,13 +131,70 @@ func init() {
 	rootCmd.AddCommand(mlNodeCmd)
 	rootCmd.AddCommand(repairCmd)
 	rootCmd.AddCommand(downloadModelCmd)
+	rootCmd.AddCommand(exporterCmd)
+	rootCmd.AddCommand(monitorCmd)
+	rootCmd.AddCommand(serviceCmd)
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(fleetCmd)
+	rootCmd.AddCommand(agentCmd)
+	rootCmd.AddCommand(firewallCmd)
+	rootCmd.AddCommand(preflightCmd)
+	rootCmd.AddCommand(configCmd)
+
+	for _, c := range []*cobra.Command{
+		setupCmd, registerCmd, resetCmd, updateCmd, cleanupCmd, repairCmd, restoreCmd,
+		mlNodeSetImageCmd, firewallApplyCmd, firewallRemoveCmd,
+		configRegenerateCmd, configSetCmd, configUnsetCmd,
+	} {
+		if c.Annotations == nil {
+			c.Annotations = map[string]string{}
+		}
+		c.Annotations[annotationLocksOutput] = "true"
+	}
+	setupCmd.Annotations[annotationLocksOutput] = lockCreatesDir
+	restoreCmd.Annotations[annotationLocksOutput] = lockCreatesDir
 }
 
 // Execute runs the root command
 func Execute() error {
+	defer func() { _ = outputLock.Release() }()
 	return rootCmd.Execute()
 }
 
+// ExitCodeError carries a specific process exit code out of a command.
+type ExitCodeError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitCodeError) Error() string { return e.Err.Error() }
+
+func (e *ExitCodeError) Unwrap() error { return e.Err }
+
+// ExitCode returns the process exit code for an error returned by Execute.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *ExitCodeError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return 1
+}
+
+// machineReadableOutput reports whether the command writes JSON/YAML to stdout,
+// in which case the logo must not be printed.
+func machineReadableOutput(cmd *cobra.Command) bool {
+	f := cmd.Flags().Lookup("format")
+	if f == nil {
+		return false
+	}
+	format, err := status.ParseFormat(f.Value.String())
+	return err == nil && format != status.FormatText
+}
+
 func printLogo() {
 	cyan := color.New(color.FgCyan, color.Bold)
 	_, _ = cyan.Println(`
@@ -94,7 +220,21 @@ var versionCmd = &cobra.Command{
 
 // --- Status Command ---
 
-var statusMocked bool
+// Exit codes for `status`, reflecting overall node health.
+// Exit code 1 remains reserved for command errors.
+const (
+	exitHealthWarning  = 2
+	exitHealthCritical = 3
+)
+
+// defaultWatchInterval is used when --watch is given without a value.
+const defaultWatchInterval = 5 * time.Second
+
+var (
+	statusMocked bool
+	statusFormat string
+	statusWatch  time.Duration
+)
 
 var statusCmd = &cobra.Command{
 	Use:   "status",
@@ -106,72 +246,179 @@ var statusCmd = &cobra.Command{
   - ML Node status, model loaded, PoC participation
   - Security configuration (firewall, DDoS, ports)
 
+Use --output json or --output yaml (or --format) for machine-readable output
+with stable field names and a schema_version. Any other --output/-o value is
+taken as the output directory, as for every other command.
+
+Exit codes:
+  0  healthy
+  1  command error
+  2  warnings (e.g. catching up, low peers, unclaimed reward)
+  3  critical (e.g. services unreachable, not synced, miss rate >= 20%)
+
+Use --watch to redraw the dashboard in place, highlighting growing block lag,
+PoC status changes and new missed inferences between refreshes.
+
 Examples:
-  gonka-nop status           # Check real node status
-  gonka-nop status --mocked  # Show mocked demo status`,
+  gonka-nop status                      # Check real node status
+  gonka-nop status --mocked             # Show mocked demo status
+  gonka-nop status --output json | jq .blockchain.block_lag
+  gonka-nop status -o /opt/gonka -o yaml  # Output dir and format together
+  gonka-nop status --watch              # Live dashboard, refresh every 5s
+  gonka-nop status --watch=10s          # Live dashboard, refresh every 10s`,
 	RunE: runStatus,
 }
 
 func init() {
 	statusCmd.Flags().BoolVar(&statusMocked, "mocked", false, "Show mocked demo status")
+	statusCmd.Flags().StringVar(&statusFormat, "format", status.FormatText, "Output format: text, json or yaml")
+	// Shadows the global --output/-o for this command only; see statusOutputValue.
+	statusCmd.Flags().VarP(statusOutputValue{}, "output", "o",
+		"Output format (text, json, yaml) or output directory")
+	statusCmd.Flags().DurationVar(&statusWatch, "watch", 0, "Refresh continuously at the given interval (e.g. --watch=10s)")
+	statusCmd.Flags().Lookup("watch").NoOptDefVal = defaultWatchInterval.String()
 }
 
-func runStatus(_ *cobra.Command, _ []string) error {
-	var nodeStatus *status.NodeStatus
+// statusOutputValue backs `status --output`. The request-facing format names
+// (text, json, yaml, yml) select the report format; anything else is the
+// output directory, so `status -o ./gonka-node` keeps working.
+type statusOutputValue struct{}
 
-	if statusMocked {
-		nodeStatus = status.FetchMockedStatus()
-	} else {
-		// Load state to determine node topology (non-fatal if missing)
-		var cfg *status.StatusConfig
-		state, loadErr := config.Load(outputDir)
-		if loadErr == nil && state != nil {
-			cfg = status.DefaultConfig()
-			cfg.NodeType = state.EffectiveNodeType()
-			if state.AdminURL != "" {
-				cfg.AdminURL = state.AdminURL
-			}
+func (statusOutputValue) String() string { return outputDir }
+
+func (statusOutputValue) Set(v string) error {
+	if format, err := status.ParseFormat(v); err == nil && v != "" {
+		statusFormat = format
+		return nil
+	}
+	outputDir = v
+	return nil
+}
+
+func (statusOutputValue) Type() string { return "string" }
+
+func runStatus(cmd *cobra.Command, _ []string) error {
+	format, err := status.ParseFormat(statusFormat)
+	if err != nil {
+		return err
+	}
+
+	if statusWatch != 0 {
+		if format != status.FormatText {
+			return fmt.Errorf("--watch only supports text output")
 		}
+		return watchStatus(cmd.Context(), statusWatch)
+	}
+
+	nodeStatus, err := loadNodeStatus()
+	if err != nil {
+		return err
+	}
+
+	report := status.NewReport(nodeStatus)
+	if format == status.FormatText {
+		status.Display(nodeStatus)
+	} else if err := status.WriteReport(os.Stdout, report, format); err != nil {
+		return err
+	}
 
-		var err error
-		nodeStatus, err = status.FetchStatusWithConfig(outputDir, cfg)
+	return healthExitError(cmd, report.Health)
+}
+
+// watchStatus redraws the status dashboard every interval until interrupted.
+func watchStatus(ctx context.Context, interval time.Duration) error {
+	if interval < time.Second {
+		return fmt.Errorf("--watch interval must be at least 1s, got %s", interval)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	w := &status.Watcher{}
+	for {
+		nodeStatus, err := loadNodeStatus()
 		if err != nil {
-			return fmt.Errorf("failed to fetch status: %w", err)
+			return err
+		}
+		now := time.Now()
+		w.Update(nodeStatus, now)
+		status.DisplayWatch(nodeStatus, w, interval, now)
+
+		select {
+		case <-ctx.Done():
+			fmt.Println()
+			return nil
+		case <-ticker.C:
 		}
 	}
-
-	status.Display(nodeStatus)
-	return nil
 }
 
-// --- Reset Command ---
+// loadNodeStatus fetches live (or mocked) status using the topology from state.
+func loadNodeStatus() (*status.NodeStatus, error) {
+	if statusMocked {
+		return status.FetchMockedStatus(), nil
+	}
 
-var resetCmd = &cobra.Command{
-	Use:   "reset",
-	Short: "Reset blockchain data while preserving keys",
-	Long: `Stop all containers and reset blockchain data.
-Preserves keys and configuration files by default.
+	nodeStatus, err := status.FetchStatusWithConfig(outputDir, statusConfigFromState())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch status: %w", err)
+	}
+	return nodeStatus, nil
+}
 
-This is useful when:
-  - Your node is stuck or corrupted
-  - You need to re-sync from scratch
-  - You want to clear old blockchain data
+// statusConfigFromState builds the status endpoints and topology from state.
+// Returns nil (defaults) when no state can be loaded.
+func statusConfigFromState() *status.StatusConfig {
+	state, err := config.Load(outputDir)
+	if err != nil || state == nil {
+		return nil
+	}
+	return statusConfigFor(state)
+}
 
-Examples:
-  gonka-nop reset             # Reset blockchain data, keep keys
-  gonka-nop reset --all       # Reset everything (WARNING: deletes keys)`,
-	RunE: runReset,
+// statusConfigFor builds the status endpoints and topology from a loaded state.
+func statusConfigFor(state *config.State) *status.StatusConfig {
+	cfg := status.DefaultConfig()
+	cfg.NodeType = state.EffectiveNodeType()
+	if state.OutputDir != "" {
+		cfg.Host = &status.HostSecurity{
+			FirewallConfigured: state.FirewallConfigured,
+			DDoSProtection:     state.DDoSProtection,
+			InternalPortsBound: state.InternalPortsBound,
+		}
+	}
+	if state.TLSEnabled() {
+		cfg.TLSDomain = state.TLSDomain
+		cfg.TLSAddr = fmt.Sprintf("127.0.0.1:%d", config.TLSHTTPSPort)
+	}
+	if state.AdminURL != "" {
+		cfg.AdminURL = state.AdminURL
+	}
+	if state.RPCURL != "" {
+		cfg.TendermintURL = state.RPCURL
+	}
+	return cfg
 }
 
-func runReset(_ *cobra.Command, _ []string) error {
-	fmt.Println("Reset command — planned for M6 (Operations)")
-	fmt.Println()
-	fmt.Println("Will implement:")
-	fmt.Println("  - Stop all containers")
-	fmt.Println("  - Run unsafe-reset-all on blockchain data")
-	fmt.Println("  - Preserve keys and config files")
-	fmt.Println("  - Option to delete everything with --all")
-	return nil
+// healthExitError maps an unhealthy result to an ExitCodeError. Usage and
+// error printing are silenced since the status output already explains why.
+func healthExitError(cmd *cobra.Command, h status.Health) error {
+	code := 0
+	switch h.Level {
+	case status.HealthWarning:
+		code = exitHealthWarning
+	case status.HealthCritical:
+		code = exitHealthCritical
+	}
+	if code == 0 {
+		return nil
+	}
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	return &ExitCodeError{Code: code, Err: fmt.Errorf("node health: %s", h.Level)}
 }
 
 // --- GPU Info Command ---
@@ -266,36 +513,6 @@ func displayRealGPUInfo() {
 	fmt.Println("  Run with --mocked to see demo output")
 }
 
-// --- Cleanup Command ---
-
-var cleanupCmd = &cobra.Command{
-	Use:   "cleanup",
-	Short: "Recover disk space",
-	Long: `Clean up old data to recover disk space.
-
-Actions:
-  - Prune old Cosmovisor backups
-  - Remove old Docker images
-  - Clean blockchain WAL files
-  - Report disk space recovered
-
-Examples:
-  gonka-nop cleanup              # Interactive cleanup
-  gonka-nop cleanup --dry-run    # Show what would be cleaned`,
-	RunE: runCleanup,
-}
-
-func runCleanup(_ *cobra.Command, _ []string) error {
-	fmt.Println("Cleanup command — planned for M6 (Operations)")
-	fmt.Println()
-	fmt.Println("Will implement:")
-	fmt.Println("  - Prune Cosmovisor upgrade backups (keep latest 2)")
-	fmt.Println("  - Remove unused Docker images")
-	fmt.Println("  - Clean blockchain WAL/tmp files")
-	fmt.Println("  - Report disk space before/after")
-	return nil
-}
-
 // --- ML Node Command ---
 
 var mlNodeCmd = &cobra.Command{
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/inc4/gonka-nop/internal/config"
+	"github.com/inc4/gonka-nop/internal/status"
+	"github.com/spf13/cobra"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "nil", err: nil, want: 0},
+		{name: "plain error", err: errors.New("boom"), want: 1},
+		{name: "exit code error", err: &ExitCodeError{Code: 3, Err: errors.New("critical")}, want: 3},
+		{name: "wrapped", err: fmt.Errorf("wrap: %w", &ExitCodeError{Code: 2, Err: errors.New("warn")}), want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHealthExitError(t *testing.T) {
+	tests := []struct {
+		level string
+		want  int
+	}{
+		{level: status.HealthOK, want: 0},
+		{level: status.HealthWarning, want: exitHealthWarning},
+		{level: status.HealthCritical, want: exitHealthCritical},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			err := healthExitError(&cobra.Command{}, status.Health{Level: tt.level})
+			if got := ExitCode(err); got != tt.want {
+				t.Errorf("exit code = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMutatingCommandsLockOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	held, err := config.LockOutputDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = held.Release() }()
+	outputDir = dir
+
+	err = rootCmd.PersistentPreRunE(configSetCmd, nil)
+	var locked *config.LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("config set while locked = %v, want LockedError", err)
+	}
+	if err := rootCmd.PersistentPreRunE(configDiffCmd, nil); err != nil {
+		t.Errorf("read-only command took the lock: %v", err)
+	}
+}
+
+func TestOnlyMutatingCommandsUpgradeOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	legacy := `{"output_dir": "` + dir + `", "network": "mainnet"}`
+	statePath := filepath.Join(dir, "state.json")
+	if err := os.WriteFile(statePath, []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	outputDir = dir
+
+	if err := rootCmd.PersistentPreRunE(configDiffCmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(statePath); string(data) != legacy { // #nosec G304 - test temp dir
+		t.Fatalf("read-only command rewrote state.json: %s", data)
+	}
+
+	err := rootCmd.PersistentPreRunE(configSetCmd, nil)
+	_ = outputLock.Release()
+	outputLock = nil
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := config.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.MigrationPending() {
+		t.Error("mutating command did not save the migrated state")
+	}
+}
+
+func TestSetupPlanChangesNothing(t *testing.T) {
+	resetSetupFlags(t)
+	parent := t.TempDir()
+	legacy := `{"output_dir": "` + parent + `", "network": "mainnet"}`
+	statePath := filepath.Join(parent, "state.json")
+	if err := os.WriteFile(statePath, []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	flagPlan = true
+
+	for _, dir := range []string{parent, filepath.Join(parent, "missing")} {
+		outputDir = dir
+		if err := rootCmd.PersistentPreRunE(setupCmd, nil); err != nil {
+			t.Fatalf("setup --plan in %s: %v", dir, err)
+		}
+		if outputLock != nil {
+			_ = outputLock.Release()
+			outputLock = nil
+			t.Errorf("setup --plan took the lock in %s", dir)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(parent, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("setup --plan created the output directory")
+	}
+	if data, _ := os.ReadFile(statePath); string(data) != legacy { // #nosec G304 - test temp dir
+		t.Errorf("setup --plan rewrote state.json: %s", data)
+	}
+	if entries, _ := os.ReadDir(parent); len(entries) != 1 {
+		t.Errorf("setup --plan left files in the output dir: %v", entries)
+	}
+}
+
+func TestStatusOutputFlag(t *testing.T) {
+	tests := []struct {
+		arg        string
+		wantFormat string
+		wantDir    string
+	}{
+		{"json", status.FormatJSON, "./gonka-node"},
+		{"YAML", status.FormatYAML, "./gonka-node"},
+		{"text", status.FormatText, "./gonka-node"},
+		{"/opt/gonka", status.FormatText, "/opt/gonka"},
+		{"./json", status.FormatText, "./json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			oldFormat, oldDir := statusFormat, outputDir
+			t.Cleanup(func() { statusFormat, outputDir = oldFormat, oldDir })
+			statusFormat, outputDir = status.FormatText, "./gonka-node"
+
+			if err := statusCmd.Flags().Set("output", tt.arg); err != nil {
+				t.Fatalf("Set(%q): %v", tt.arg, err)
+			}
+			if statusFormat != tt.wantFormat || outputDir != tt.wantDir {
+				t.Errorf("format=%q dir=%q, want %q %q", statusFormat, outputDir, tt.wantFormat, tt.wantDir)
+			}
+		})
+	}
+}
@@ -0,0 +1,420 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/inc4/gonka-nop/internal/config"
+	"github.com/inc4/gonka-nop/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+const (
+	serviceUnitDir     = "etc/systemd/system"
+	serviceEnvDir      = "etc/default"
+	defaultServiceUser = "gonka-nop"
+	defaultDockerPath  = "/usr/bin/docker"
+
+	serviceUnitCompose  = "compose"
+	serviceUnitExporter = "exporter"
+	serviceUnitMonitor  = "monitor"
+
+	// gpuWaitTimeout bounds how long the compose unit waits for the NVIDIA driver.
+	gpuWaitTimeout = "15min"
+)
+
+var serviceUnitNames = map[string]string{
+	serviceUnitCompose:  "gonka-node.service",
+	serviceUnitExporter: "gonka-exporter.service",
+	serviceUnitMonitor:  "gonka-monitor.service",
+}
+
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Manage systemd units for the node and gonka-nop daemons",
+}
+
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install systemd units for boot-time start, exporter and monitor",
+	Long: `Write systemd units so the node survives reboots unattended:
+
+  gonka-node.service      Oneshot 'docker compose up -d' for the generated compose
+                          files, ordered after Docker and (on GPU hosts) gated on
+                          the NVIDIA driver answering nvidia-smi
+  gonka-exporter.service  'gonka-nop exporter' as a dedicated user
+  gonka-monitor.service   'gonka-nop monitor' as a dedicated user
+
+The dedicated user cannot read state.json, so the exporter and monitor units
+get the Admin API URL, RPC URL and node type on the command line. Run
+'service install' again after changing them.
+
+Extra arguments for the exporter and monitor (notifier URLs, thresholds,
+GONKA_TELEGRAM_TOKEN) go in /etc/default/gonka-nop-exporter and
+/etc/default/gonka-nop-monitor. Existing files there are never overwritten.
+
+With --root other than /, files are only written under that directory
+(useful for images and review); no user is created and systemctl is not run.
+
+Examples:
+  sudo gonka-nop service install
+  sudo gonka-nop service install --units compose     # Boot gate only
+  gonka-nop service install --root ./staging --yes   # Generate only`,
+	RunE: runServiceInstall,
+}
+
+var (
+	serviceRoot     string
+	serviceUser     string
+	serviceUnits    []string
+	serviceNoEnable bool
+	serviceYes      bool
+)
+
+func init() {
+	serviceCmd.AddCommand(serviceInstallCmd)
+
+	f := serviceInstallCmd.Flags()
+	f.StringVar(&serviceRoot, "root", "/", "Filesystem root to install units under")
+	f.StringVar(&serviceUser, "user", defaultServiceUser, "System user for the exporter and monitor")
+	f.StringSliceVar(&serviceUnits, "units", []string{serviceUnitCompose, serviceUnitExporter, serviceUnitMonitor},
+		"Units to install: compose, exporter, monitor")
+	f.BoolVar(&serviceNoEnable, "no-enable", false, "Write units without enabling them")
+	f.BoolVarP(&serviceYes, "yes", "y", false, "Skip confirmation prompt")
+}
+
+// serviceOptions holds everything unit generation depends on.
+type serviceOptions struct {
+	User         string
+	Binary       string
+	Docker       string
+	OutputDir    string
+	ComposeFiles []string
+	GPUGate      bool
+	// NoRecreate keeps existing containers at boot: the api container got
+	// the keyring password from the environment of the command that created
+	// it, which the unit does not have.
+	NoRecreate bool
+	// AdminURL, RPCURL and NodeType are passed to the exporter and monitor:
+	// they run as opts.User, which cannot read the root-owned state.json.
+	AdminURL string
+	RPCURL   string
+	NodeType string
+	Units    []string
+}
+
+// serviceFile is one file to write, relative to the install root.
+type serviceFile struct {
+	Path      string
+	Content   string
+	Mode      os.FileMode
+	Overwrite bool
+}
+
+func runServiceInstall(cmd *cobra.Command, _ []string) error {
+	systemRoot := filepath.Clean(serviceRoot) == "/"
+	if systemRoot && os.Geteuid() != 0 {
+		return fmt.Errorf("installing into / requires root (run with sudo, or use --root for a staging directory)")
+	}
+
+	state, err := config.Load(outputDir)
+	if err != nil {
+		return fmt.Errorf("load state: %w", err)
+	}
+	opts, err := serviceOptionsFromState(state)
+	if err != nil {
+		return err
+	}
+
+	files, err := generateServiceFiles(opts)
+	if err != nil {
+		return err
+	}
+	displayServicePlan(serviceRoot, files)
+
+	if !serviceYes {
+		proceed, err := ui.Confirm("Write these files?", true)
+		if err != nil {
+			return err
+		}
+		if !proceed {
+			ui.Info("Service install canceled")
+			return nil
+		}
+	}
+
+	for _, f := range files {
+		written, err := writeServiceFile(serviceRoot, f)
+		if err != nil {
+			return err
+		}
+		if written {
+			ui.Success("Wrote %s", filepath.Join(serviceRoot, f.Path))
+		} else {
+			ui.Detail("Kept existing %s", filepath.Join(serviceRoot, f.Path))
+		}
+	}
+
+	if !systemRoot {
+		ui.Info("Files written under %s — copy them to the target host and run 'systemctl daemon-reload'", serviceRoot)
+		return nil
+	}
+	return activateServices(cmd, opts)
+}
+
+// serviceOptionsFromState derives unit options from state.json and the host.
+func serviceOptionsFromState(state *config.State) (serviceOptions, error) {
+	for _, u := range serviceUnits {
+		if _, ok := serviceUnitNames[u]; !ok {
+			return serviceOptions{}, fmt.Errorf("unknown unit %q (valid: compose, exporter, monitor)", u)
+		}
+	}
+
+	binary, err := os.Executable()
+	if err != nil {
+		return serviceOptions{}, fmt.Errorf("resolve gonka-nop binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(binary); err == nil {
+		binary = resolved
+	}
+	dockerPath := defaultDockerPath
+	if p, err := exec.LookPath("docker"); err == nil {
+		dockerPath = p
+	}
+
+	endpoints := statusConfigFor(state)
+
+	files := state.ComposeFiles
+	if len(files) == 0 {
+		files = []string{"docker-compose.yml", "docker-compose.mlnode.yml"}
+	}
+
+	return serviceOptions{
+		User:         serviceUser,
+		Binary:       binary,
+		Docker:       dockerPath,
+		OutputDir:    state.OutputDir,
+		ComposeFiles: files,
+		GPUGate:      !state.IsNetworkOnly(),
+		NoRecreate:   state.KeyringPassthrough(),
+		AdminURL:     endpoints.AdminURL,
+		RPCURL:       endpoints.TendermintURL,
+		NodeType:     state.EffectiveNodeType(),
+		Units:        serviceUnits,
+	}, nil
+}
+
+// generateServiceFiles renders the unit and environment files. It has no side
+// effects so the output can be tested directly.
+func generateServiceFiles(opts serviceOptions) ([]serviceFile, error) {
+	if opts.OutputDir == "" || !filepath.IsAbs(opts.OutputDir) {
+		return nil, fmt.Errorf("output directory must be an absolute path, got %q", opts.OutputDir)
+	}
+	if opts.User == "" {
+		return nil, fmt.Errorf("service user must not be empty")
+	}
+	if opts.AdminURL == "" || opts.RPCURL == "" || opts.NodeType == "" {
+		return nil, fmt.Errorf("admin URL, RPC URL and node type must be set")
+	}
+
+	var files []serviceFile
+	if slices.Contains(opts.Units, serviceUnitCompose) {
+		files = append(files, serviceFile{
+			Path:      filepath.Join(serviceUnitDir, serviceUnitNames[serviceUnitCompose]),
+			Content:   composeUnit(opts),
+			Mode:      0644,
+			Overwrite: true,
+		})
+	}
+	for _, mode := range []string{serviceUnitExporter, serviceUnitMonitor} {
+		if !slices.Contains(opts.Units, mode) {
+			continue
+		}
+		files = append(files,
+			serviceFile{
+				Path:      filepath.Join(serviceUnitDir, serviceUnitNames[mode]),
+				Content:   daemonUnit(opts, mode),
+				Mode:      0644,
+				Overwrite: true,
+			},
+			serviceFile{
+				Path:    filepath.Join(serviceEnvDir, "gonka-nop-"+mode),
+				Content: daemonEnvFile(mode),
+				Mode:    0600,
+			},
+		)
+	}
+	return files, nil
+}
+
+func composeUnit(opts serviceOptions) string {
+	var b strings.Builder
+	b.WriteString("# Generated by gonka-nop service install\n")
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=Gonka node containers (docker compose)\n")
+	b.WriteString("Requires=docker.service\n")
+	b.WriteString("Wants=network-online.target\n")
+	after := "After=docker.service network-online.target"
+	if opts.GPUGate {
+		// Harmless if either unit does not exist on this host
+		after += " nvidia-persistenced.service nvidia-fabricmanager.service"
+	}
+	b.WriteString(after + "\n\n")
+
+	b.WriteString("[Service]\n")
+	b.WriteString("Type=oneshot\n")
+	b.WriteString("RemainAfterExit=yes\n")
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", systemdArg(opts.OutputDir))
+	if opts.GPUGate {
+		// The ML node fails to start if the driver is not loaded yet
+		fmt.Fprintf(&b, "TimeoutStartSec=%s\n", gpuWaitTimeout)
+		b.WriteString("ExecStartPre=/bin/sh -c 'until nvidia-smi -L >/dev/null 2>&1; do echo \"waiting for NVIDIA driver\"; sleep 5; done'\n")
+	}
+	compose := composeCommandLine(opts)
+	up := "up -d"
+	if opts.NoRecreate {
+		up += " --no-recreate"
+	}
+	fmt.Fprintf(&b, "ExecStart=%s %s\n", compose, up)
+	fmt.Fprintf(&b, "ExecStop=%s stop\n\n", compose)
+
+	b.WriteString("[Install]\n")
+	b.WriteString("WantedBy=multi-user.target\n")
+	return b.String()
+}
+
+func composeCommandLine(opts serviceOptions) string {
+	args := []string{systemdArg(opts.Docker), "compose", "--env-file", "config.env"}
+	for _, f := range opts.ComposeFiles {
+		args = append(args, "-f", systemdArg(f))
+	}
+	return strings.Join(args, " ")
+}
+
+func daemonUnit(opts serviceOptions, mode string) string {
+	envVar := "GONKA_" + strings.ToUpper(mode) + "_ARGS"
+
+	var b strings.Builder
+	b.WriteString("# Generated by gonka-nop service install\n")
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=gonka-nop %s\n", mode)
+	b.WriteString("Wants=network-online.target\n")
+	fmt.Fprintf(&b, "After=network-online.target %s\n\n", serviceUnitNames[serviceUnitCompose])
+
+	b.WriteString("[Service]\n")
+	fmt.Fprintf(&b, "User=%s\n", opts.User)
+	fmt.Fprintf(&b, "Group=%s\n", opts.User)
+	fmt.Fprintf(&b, "EnvironmentFile=-/%s/gonka-nop-%s\n", serviceEnvDir, mode)
+	// Arguments from the environment file come last and win.
+	fmt.Fprintf(&b, "ExecStart=%s --output %s %s --admin-url %s --rpc-url %s --node-type %s $%s\n",
+		systemdArg(opts.Binary), systemdArg(opts.OutputDir), mode,
+		systemdArg(opts.AdminURL), systemdArg(opts.RPCURL), systemdArg(opts.NodeType), envVar)
+	b.WriteString("Restart=on-failure\n")
+	b.WriteString("RestartSec=10\n")
+	b.WriteString("NoNewPrivileges=yes\n")
+	b.WriteString("ProtectSystem=strict\n")
+	b.WriteString("ProtectHome=read-only\n")
+	b.WriteString("PrivateTmp=yes\n\n")
+
+	b.WriteString("[Install]\n")
+	b.WriteString("WantedBy=multi-user.target\n")
+	return b.String()
+}
+
+func daemonEnvFile(mode string) string {
+	envVar := "GONKA_" + strings.ToUpper(mode) + "_ARGS"
+	if mode == serviceUnitMonitor {
+		return fmt.Sprintf(`# Extra arguments for 'gonka-nop monitor' (see gonka-nop monitor --help)
+# Example: %[1]s="--slack-webhook https://hooks.slack.com/services/... --min-peers 8"
+%[1]s=""
+# GONKA_TELEGRAM_TOKEN=
+`, envVar)
+	}
+	return fmt.Sprintf(`# Extra arguments for 'gonka-nop exporter' (see gonka-nop exporter --help)
+%s="--listen 127.0.0.1:9101"
+`, envVar)
+}
+
+// systemdArg quotes a value for an Exec*= line and escapes specifiers.
+func systemdArg(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if strings.ContainsAny(s, " \t\"'\\") {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+	}
+	return s
+}
+
+func displayServicePlan(root string, files []serviceFile) {
+	bold := color.New(color.Bold)
+	fmt.Println()
+	_, _ = bold.Println("Service Install Plan")
+	fmt.Println(strings.Repeat("─", 60))
+	for _, f := range files {
+		action := "write"
+		if !f.Overwrite {
+			action = "write if missing"
+		}
+		fmt.Printf("  %-50s %s\n", filepath.Join(root, f.Path), action)
+	}
+	fmt.Println()
+}
+
+// writeServiceFile writes f under root. It returns false if the file exists
+// and must not be overwritten.
+func writeServiceFile(root string, f serviceFile) (bool, error) {
+	path := filepath.Join(root, f.Path)
+	if !f.Overwrite {
+		if _, err := os.Stat(path); err == nil {
+			return false, nil
+		}
+	}
+	// systemd directories must stay world-readable
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil { // #nosec G301
+		return false, fmt.Errorf("create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(f.Content), f.Mode); err != nil {
+		return false, fmt.Errorf("write %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// activateServices creates the service user and enables the installed units.
+func activateServices(cmd *cobra.Command, opts serviceOptions) error {
+	ctx := cmd.Context()
+	needsUser := slices.Contains(opts.Units, serviceUnitExporter) || slices.Contains(opts.Units, serviceUnitMonitor)
+	if needsUser {
+		if err := runHostCmd(ctx, false, "", "id -u "+shellQuote(opts.User)+" >/dev/null 2>&1"); err != nil {
+			ui.Info("Creating system user %s", opts.User)
+			if err := runHostCmd(ctx, false, "", "useradd --system --no-create-home --shell /usr/sbin/nologin "+shellQuote(opts.User)); err != nil {
+				return fmt.Errorf("create user %s: %w", opts.User, err)
+			}
+		}
+	}
+
+	if err := runHostCmd(ctx, false, "", "systemctl daemon-reload"); err != nil {
+		return err
+	}
+	if serviceNoEnable {
+		ui.Info("Units written but not enabled (--no-enable)")
+		return nil
+	}
+
+	for _, u := range opts.Units {
+		name := serviceUnitNames[u]
+		enable := "systemctl enable " + name
+		if u != serviceUnitCompose {
+			// The compose unit is only needed at next boot; containers are already up
+			enable = "systemctl enable --now " + name
+		}
+		if err := runHostCmd(ctx, false, "", enable); err != nil {
+			return err
+		}
+		ui.Success("Enabled %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testServiceOptions() serviceOptions {
+	return serviceOptions{
+		User:         defaultServiceUser,
+		Binary:       "/usr/local/bin/gonka-nop",
+		Docker:       defaultDockerPath,
+		OutputDir:    "/opt/gonka-node",
+		ComposeFiles: []string{"docker-compose.yml", "docker-compose.mlnode.yml"},
+		GPUGate:      true,
+		AdminURL:     "http://localhost:9200",
+		RPCURL:       "http://localhost:26657",
+		NodeType:     "full",
+		Units:        []string{serviceUnitCompose, serviceUnitExporter, serviceUnitMonitor},
+	}
+}
+
+func serviceFileByPath(files []serviceFile, path string) *serviceFile {
+	for i := range files {
+		if files[i].Path == path {
+			return &files[i]
+		}
+	}
+	return nil
+}
+
+func TestGenerateServiceFiles_All(t *testing.T) {
+	files, err := generateServiceFiles(testServiceOptions())
+	if err != nil {
+		t.Fatalf("generateServiceFiles() error: %v", err)
+	}
+	if len(files) != 5 {
+		t.Fatalf("expected 5 files (3 units + 2 env files), got %d", len(files))
+	}
+
+	compose := serviceFileByPath(files, "etc/systemd/system/gonka-node.service")
+	if compose == nil {
+		t.Fatal("missing gonka-node.service")
+	}
+	for _, want := range []string{
+		"Type=oneshot",
+		"RemainAfterExit=yes",
+		"Requires=docker.service",
+		"After=docker.service network-online.target nvidia-persistenced.service nvidia-fabricmanager.service",
+		"WorkingDirectory=/opt/gonka-node",
+		"ExecStartPre=/bin/sh -c 'until nvidia-smi -L",
+		"ExecStart=/usr/bin/docker compose --env-file config.env -f docker-compose.yml -f docker-compose.mlnode.yml up -d",
+		"WantedBy=multi-user.target",
+	} {
+		if !strings.Contains(compose.Content, want) {
+			t.Errorf("gonka-node.service missing %q\n%s", want, compose.Content)
+		}
+	}
+
+	monitor := serviceFileByPath(files, "etc/systemd/system/gonka-monitor.service")
+	if monitor == nil {
+		t.Fatal("missing gonka-monitor.service")
+	}
+	for _, want := range []string{
+		"User=gonka-nop",
+		"EnvironmentFile=-/etc/default/gonka-nop-monitor",
+		"ExecStart=/usr/local/bin/gonka-nop --output /opt/gonka-node monitor --admin-url http://localhost:9200 --rpc-url http://localhost:26657 --node-type full $GONKA_MONITOR_ARGS",
+		"After=network-online.target gonka-node.service",
+		"Restart=on-failure",
+	} {
+		if !strings.Contains(monitor.Content, want) {
+			t.Errorf("gonka-monitor.service missing %q\n%s", want, monitor.Content)
+		}
+	}
+
+	env := serviceFileByPath(files, "etc/default/gonka-nop-exporter")
+	if env == nil || env.Overwrite || env.Mode != 0600 {
+		t.Errorf("exporter env file = %+v, want mode 0600 and no overwrite", env)
+	}
+}
+
+func TestGenerateServiceFiles_NetworkOnlyNoGPUGate(t *testing.T) {
+	opts := testServiceOptions()
+	opts.GPUGate = false
+	opts.ComposeFiles = []string{"docker-compose.yml"}
+	opts.Units = []string{serviceUnitCompose}
+
+	files, err := generateServiceFiles(opts)
+	if err != nil {
+		t.Fatalf("generateServiceFiles() error: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected only the compose unit, got %d files", len(files))
+	}
+	if strings.Contains(files[0].Content, "nvidia") {
+		t.Errorf("network-only unit should not wait for the NVIDIA driver:\n%s", files[0].Content)
+	}
+}
+
+func TestGenerateServiceFiles_NoRecreate(t *testing.T) {
+	opts := testServiceOptions()
+	opts.NoRecreate = true
+	opts.Units = []string{serviceUnitCompose}
+
+	files, err := generateServiceFiles(opts)
+	if err != nil {
+		t.Fatalf("generateServiceFiles() error: %v", err)
+	}
+	if !strings.Contains(files[0].Content, " up -d --no-recreate\n") {
+		t.Errorf("compose unit should not recreate containers:\n%s", files[0].Content)
+	}
+}
+
+func TestGenerateServiceFiles_Invalid(t *testing.T) {
+	opts := testServiceOptions()
+	opts.OutputDir = "gonka-node"
+	if _, err := generateServiceFiles(opts); err == nil {
+		t.Error("expected error for relative output directory")
+	}
+
+	opts = testServiceOptions()
+	opts.User = ""
+	if _, err := generateServiceFiles(opts); err == nil {
+		t.Error("expected error for empty user")
+	}
+
+	opts = testServiceOptions()
+	opts.NodeType = ""
+	if _, err := generateServiceFiles(opts); err == nil {
+		t.Error("expected error without a node type")
+	}
+}
+
+func TestSystemdArg(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "/opt/gonka-node", want: "/opt/gonka-node"},
+		{input: "/opt/gonka node", want: `"/opt/gonka node"`},
+		{input: "/opt/100%", want: "/opt/100%%"},
+		{input: `/opt/a"b`, want: `"/opt/a\"b"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := systemdArg(tt.input); got != tt.want {
+				t.Errorf("systemdArg(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteServiceFile_KeepsExistingEnv(t *testing.T) {
+	root := t.TempDir()
+	f := serviceFile{Path: "etc/default/gonka-nop-monitor", Content: "new", Mode: 0600}
+
+	written, err := writeServiceFile(root, f)
+	if err != nil || !written {
+		t.Fatalf("first write: written=%v err=%v", written, err)
+	}
+	path := filepath.Join(root, f.Path)
+	if err := os.WriteFile(path, []byte("edited"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err = writeServiceFile(root, f)
+	if err != nil || written {
+		t.Fatalf("second write: written=%v err=%v, want kept", written, err)
+	}
+	data, _ := os.ReadFile(path) // #nosec G304 - test temp dir
+	if string(data) != "edited" {
+		t.Errorf("existing env file was overwritten: %q", data)
+	}
+}
@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
+	"github.com/fatih/color"
 	"github.com/inc4/gonka-nop/internal/config"
 	"github.com/inc4/gonka-nop/internal/phases"
 	"github.com/inc4/gonka-nop/internal/ui"
@@ -20,6 +22,8 @@ var (
 	flagKeyWorkflow      string
 	flagKeyName          string
 	flagKeyringPass      string
+	flagKeyringPassFile  string
+	flagKeyringPassEnv   string
 	flagPublicIP         string
 	flagHFHome           string
 	flagPorts            string
@@ -31,6 +35,15 @@ var (
 	flagNetworkNodeURL   string
 	flagMLNodeImage      string
 	flagAttentionBackend string
+	flagConfigFile       string
+	flagTLSDomain        string
+	flagACMEEmail        string
+	flagACMEDirectory    string
+
+	// Phase selection
+	flagPlan      bool
+	flagFromPhase string
+	flagOnlyPhase []string
 )
 
 var setupCmd = &cobra.Command{
@@ -49,11 +62,12 @@ Examples:
   gonka-nop setup                    # Interactive setup
   gonka-nop setup -o /opt/gonka      # Custom output directory
   gonka-nop setup --account-pubkey=<key>  # Provide account key
+  gonka-nop setup --account-pubkey=gonka-account.json  # Bundle from init-account
   gonka-nop setup --mocked           # Demo mode with mocked data
 
   # Non-interactive setup (for scripting / SSH):
   gonka-nop setup -y --network testnet --key-workflow quick \
-    --key-name mynode --keyring-password pass123 \
+    --key-name mynode --keyring-password-file /etc/gonka/keyring-password \
     --public-ip 1.2.3.4 --ports custom \
     --ext-p2p-port 19245 --ext-api-port 19246
 
@@ -61,12 +75,25 @@ Examples:
   gonka-nop setup --type network
 
   # ML node only (GPU inference, connects to remote network node):
-  gonka-nop setup --type mlnode --network-node-url http://10.0.1.100:9200`,
+  gonka-nop setup --type mlnode --network-node-url http://10.0.1.100:9200
+
+  # Serve the public API over HTTPS (proxy-ssl, ACME certificate):
+  gonka-nop setup --tls node.example.com --acme-email ops@example.com
+
+  # Declarative setup from a spec file (see README, Setup Spec):
+  gonka-nop setup --config node.yaml
+
+  # Show which phases would run and what they would do, then exit:
+  gonka-nop setup --plan
+
+  # Re-run selected phases even if complete (e.g. after 'config set'):
+  gonka-nop setup --only configuration
+  gonka-nop setup --from deployment`,
 	RunE: runSetup,
 }
 
 func init() {
-	setupCmd.Flags().StringVar(&accountPubKey, "account-pubkey", "", "Account public key (for secure setup)")
+	setupCmd.Flags().StringVar(&accountPubKey, "account-pubkey", "", "Account public key or init-account bundle file (for secure setup)")
 	setupCmd.Flags().BoolVar(&mockedSetup, "mocked", false, "Use mocked data (demo mode)")
 
 	// Non-interactive flags
@@ -74,7 +101,9 @@ func init() {
 	setupCmd.Flags().StringVar(&flagNetwork, "network", "", "Network selection (mainnet or testnet)")
 	setupCmd.Flags().StringVar(&flagKeyWorkflow, "key-workflow", "", "Key management workflow (quick or secure)")
 	setupCmd.Flags().StringVar(&flagKeyName, "key-name", "", "Base name for keys")
-	setupCmd.Flags().StringVar(&flagKeyringPass, "keyring-password", "", "Keyring password")
+	setupCmd.Flags().StringVar(&flagKeyringPass, "keyring-password", "", "Keyring password (used for this run only, never saved)")
+	addKeyringSourceFlags(setupCmd)
+	setupCmd.MarkFlagsMutuallyExclusive("keyring-password", "keyring-password-file", "keyring-password-env")
 	setupCmd.Flags().StringVar(&flagPublicIP, "public-ip", "", "Server public IP or hostname")
 	setupCmd.Flags().StringVar(&flagHFHome, "hf-home", "", "HuggingFace cache directory")
 	setupCmd.Flags().StringVar(&flagPorts, "ports", "", "Port configuration mode (default or custom)")
@@ -85,59 +114,165 @@ func init() {
 	setupCmd.Flags().StringVar(&flagNodeType, "type", "", "Node topology: full (default), network, or mlnode")
 	setupCmd.Flags().StringVar(&flagNetworkNodeURL, "network-node-url", "", "Network node Admin API URL (for mlnode-only)")
 	setupCmd.Flags().StringVar(&flagMLNodeImage, "mlnode-image", "", "Custom MLNode Docker image (e.g., ghcr.io/segovchik/gonka-b300-image:3.0.13-b300-tp1)")
+	setupCmd.Flags().StringVar(&flagConfigFile, "config", "", "Setup spec file (YAML or JSON); implies --yes, flags override file values")
 	setupCmd.Flags().StringVar(&flagAttentionBackend, "attention-backend", "", "vLLM attention backend (FLASHINFER or FLASH_ATTN)")
+	setupCmd.Flags().StringVar(&flagTLSDomain, "tls", "", "Serve the public API over HTTPS for this domain (ACME certificate via proxy-ssl)")
+	setupCmd.Flags().StringVar(&flagACMEEmail, "acme-email", "", "ACME account email for --tls")
+	setupCmd.Flags().StringVar(&flagACMEDirectory, "acme-directory", "", "ACME directory URL for --tls (default: Let's Encrypt)")
+	setupCmd.Flags().BoolVar(&flagPlan, "plan", false, "List the phases that would run, skip or re-run and what they would do, without changing anything")
+	setupCmd.Flags().StringVar(&flagFromPhase, "from", "", "Run this phase and every later one, even if complete")
+	setupCmd.Flags().StringSliceVar(&flagOnlyPhase, "only", nil, "Run only these phases, even if complete (comma-separated)")
+	setupCmd.MarkFlagsMutuallyExclusive("from", "only")
 }
 
-// setupOverrides maps CLI flag values to ui prompt overrides.
-func setupOverrides() {
-	ui.SetNonInteractive(true)
+// setupSpec loads the --config spec (if any) and layers CLI flags on top,
+// so flags win over the file. The result is validated as a whole.
+func setupSpec() (*config.SetupSpec, error) {
+	spec := &config.SetupSpec{Version: config.SetupSpecVersion}
+	if flagConfigFile != "" {
+		loaded, err := config.LoadSetupSpec(flagConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		spec = loaded
+	}
 
-	overrides := []struct {
-		flag, prompt string
-	}{
-		{flagNodeType, "node topology"},
-		{flagNetworkNodeURL, "Admin API URL"},
-		{flagNetwork, "Select network"},
-		{flagKeyWorkflow, "key management workflow"},
-		{flagKeyName, "base name"},
-		{flagKeyringPass, "keyring password"},
-		{flagPublicIP, "public IP"},
-		{flagHFHome, "HuggingFace"},
-		{flagPorts, "port configuration"},
-		{flagExtP2PPort, "External P2P"},
-		{flagExtAPIPort, "External API"},
-		{flagIntP2PPort, "Internal P2P"},
-		{flagIntAPIPort, "Internal API"},
-		{flagAttentionBackend, "Attention backend"},
-	}
-	for _, o := range overrides {
-		if o.flag != "" {
-			ui.SetOverride(o.prompt, o.flag)
+	setIfFlag(&spec.Network, strings.ToLower(flagNetwork))
+	setIfFlag(&spec.NodeType, strings.ToLower(flagNodeType))
+	setIfFlag(&spec.Keys.Workflow, strings.ToLower(flagKeyWorkflow))
+	setIfFlag(&spec.Keys.Name, flagKeyName)
+	if flagKeyringPass != "" || flagKeyringPassFile != "" || flagKeyringPassEnv != "" {
+		spec.Keys.KeyringPassword = flagKeyringPass
+		spec.Keys.KeyringPasswordFile = flagKeyringPassFile
+		spec.Keys.KeyringPasswordEnv = flagKeyringPassEnv
+	}
+	setIfFlag(&spec.Keys.AccountPubKey, accountPubKey)
+	setIfFlag(&spec.PublicIP, flagPublicIP)
+	setIfFlag(&spec.HFHome, flagHFHome)
+	setIfFlag(&spec.MLNodeImage, flagMLNodeImage)
+	setIfFlag(&spec.AttentionBackend, strings.ToUpper(flagAttentionBackend))
+	if flagNetworkNodeURL != "" {
+		if spec.NetworkNode == nil {
+			spec.NetworkNode = &config.SpecNetworkNode{}
 		}
+		spec.NetworkNode.URL = flagNetworkNodeURL
 	}
+	if err := applyPortFlags(spec); err != nil {
+		return nil, err
+	}
+	applyTLSFlags(spec)
 
-	// key-name also matches "name for your server" prompt (secure workflow)
-	if flagKeyName != "" {
-		ui.SetOverride("name for your server", flagKeyName)
+	if err := spec.Validate(); err != nil {
+		return nil, err
 	}
+	return spec, nil
+}
+
+// addKeyringSourceFlags registers the flags selecting where the keyring
+// password is read from.
+func addKeyringSourceFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&flagKeyringPassFile, "keyring-password-file", "",
+		"Read the keyring password from this file (absolute path, mode 0400)")
+	cmd.Flags().StringVar(&flagKeyringPassEnv, "keyring-password-env", "",
+		"Read the keyring password from this environment variable")
+	cmd.MarkFlagsMutuallyExclusive("keyring-password-file", "keyring-password-env")
+}
 
-	// keyring-password also matches generic "password" prompt
-	if flagKeyringPass != "" {
-		ui.SetOverride("password", flagKeyringPass)
+// keyringSourceFlag returns the password source selected by the flags, or "".
+func keyringSourceFlag() string {
+	return config.SpecKeys{KeyringPasswordFile: flagKeyringPassFile, KeyringPasswordEnv: flagKeyringPassEnv}.PasswordSource()
+}
+
+// applyAccountPubKey sets the account pubkey from a raw key or an
+// init-account bundle (inline JSON or file path).
+func applyAccountPubKey(state *config.State, value string) error {
+	if value == "" {
+		return nil
 	}
+	pubkey, address, err := phases.ResolveAccountPubKey(value)
+	if err != nil {
+		return err
+	}
+	state.AccountPubKey = pubkey
+	if address != "" {
+		state.ColdKeyAddress = address
+	}
+	return nil
+}
 
-	// public-ip also matches "ML node's IP" prompt (mlnode-only topology)
-	if flagPublicIP != "" {
-		ui.SetOverride("ML node's IP", flagPublicIP)
+func setIfFlag(field *string, value string) {
+	if value != "" {
+		*field = value
 	}
 }
 
+// applyPortFlags maps --ports and the four port flags onto spec.Ports.
+// "--ports default" pins the default ports; port flags imply custom ports.
+func applyPortFlags(spec *config.SetupSpec) error {
+	portFlags := []struct {
+		name  string
+		value string
+		field func(p *config.SpecPorts) *int
+	}{
+		{"ext-p2p-port", flagExtP2PPort, func(p *config.SpecPorts) *int { return &p.P2P }},
+		{"ext-api-port", flagExtAPIPort, func(p *config.SpecPorts) *int { return &p.API }},
+		{"int-p2p-port", flagIntP2PPort, func(p *config.SpecPorts) *int { return &p.InternalP2P }},
+		{"int-api-port", flagIntAPIPort, func(p *config.SpecPorts) *int { return &p.InternalAPI }},
+	}
+
+	switch strings.ToLower(flagPorts) {
+	case "":
+	case "default":
+		spec.Ports = &config.SpecPorts{}
+		return nil
+	case "custom":
+		if spec.Ports == nil {
+			spec.Ports = &config.SpecPorts{}
+		}
+	default:
+		return fmt.Errorf("invalid --ports value %q (must be default or custom)", flagPorts)
+	}
+
+	for _, pf := range portFlags {
+		if pf.value == "" {
+			continue
+		}
+		port, err := strconv.Atoi(pf.value)
+		if err != nil {
+			return fmt.Errorf("invalid --%s value %q", pf.name, pf.value)
+		}
+		if spec.Ports == nil {
+			spec.Ports = &config.SpecPorts{}
+		}
+		*pf.field(spec.Ports) = port
+	}
+	return nil
+}
+
+// applyTLSFlags maps --tls, --acme-email and --acme-directory onto spec.TLS.
+func applyTLSFlags(spec *config.SetupSpec) {
+	if flagTLSDomain == "" && flagACMEEmail == "" && flagACMEDirectory == "" {
+		return
+	}
+	if spec.TLS == nil {
+		spec.TLS = &config.SpecTLS{}
+	}
+	setIfFlag(&spec.TLS.Domain, strings.ToLower(flagTLSDomain))
+	setIfFlag(&spec.TLS.Email, flagACMEEmail)
+	setIfFlag(&spec.TLS.ACMEDirectory, flagACMEDirectory)
+}
+
 func runSetup(cmd *cobra.Command, _ []string) error {
 	ctx := cmd.Context()
 
-	// Enable non-interactive mode if --yes flag is set
-	if yesFlag {
-		setupOverrides()
+	spec, err := setupSpec()
+	if err != nil {
+		return err
+	}
+
+	// A config file is meant for unattended provisioning; a plan never prompts
+	if yesFlag || flagConfigFile != "" || flagPlan {
+		ui.SetNonInteractive(true)
 	}
 
 	// Load or create state
@@ -145,20 +280,17 @@ func runSetup(cmd *cobra.Command, _ []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to load state: %w", err)
 	}
+	state.SetSpec(spec)
 
-	// Set account pubkey if provided
-	if accountPubKey != "" {
-		state.AccountPubKey = accountPubKey
+	if src := spec.Keys.PasswordSource(); src != "" {
+		state.KeyringPasswordSource = src
 	}
 
-	// Set custom MLNode image if provided (overrides auto-detection)
-	if flagMLNodeImage != "" {
-		state.CustomMLNodeImage = flagMLNodeImage
+	if err := applyAccountPubKey(state, spec.Keys.AccountPubKey); err != nil {
+		return err
 	}
-
-	// Set attention backend if provided (overrides auto-detection)
-	if flagAttentionBackend != "" {
-		state.AttentionBackend = flagAttentionBackend
+	if t := spec.TLS; t != nil {
+		state.TLSDomain, state.ACMEEmail, state.ACMEDirectory = t.Domain, t.Email, t.ACMEDirectory
 	}
 
 	ui.Header("Gonka Node Setup")
@@ -166,7 +298,9 @@ func runSetup(cmd *cobra.Command, _ []string) error {
 	if mockedSetup {
 		ui.Info("Running in demo mode (--mocked)")
 	}
-	if yesFlag {
+	if flagConfigFile != "" {
+		ui.Info("Using setup spec: %s", flagConfigFile)
+	} else if yesFlag {
 		ui.Info("Running in non-interactive mode (--yes)")
 	}
 
@@ -185,6 +319,13 @@ func runSetup(cmd *cobra.Command, _ []string) error {
 
 	// Create and run phase runner
 	runner := phases.NewRunner(phaseList, state)
+	if err := runner.Select(phases.Selection{From: flagFromPhase, Only: flagOnlyPhase}); err != nil {
+		return err
+	}
+	if flagPlan {
+		printSetupPlan(runner.Plan(), state)
+		return nil
+	}
 
 	if err := runner.Run(ctx); err != nil {
 		return err
@@ -208,22 +349,54 @@ func runSetup(cmd *cobra.Command, _ []string) error {
 	return nil
 }
 
+// printSetupPlan prints each phase's decision and, for phases that would
+// run, the actions their DryRun reports.
+func printSetupPlan(steps []phases.PlanStep, state *config.State) {
+	boldC := color.New(color.Bold)
+	dimC := color.New(color.Faint)
+	decisionC := map[string]*color.Color{
+		phases.PlanRun:   color.New(color.FgGreen),
+		phases.PlanRerun: color.New(color.FgYellow),
+		phases.PlanSkip:  dimC,
+	}
+
+	_, _ = boldC.Println("\nSetup Plan")
+	fmt.Println(strings.Repeat("─", 60))
+	for i, step := range steps {
+		fmt.Printf("  %2d. %-22s ", i+1, step.Phase.Name())
+		_, _ = decisionC[step.Decision].Printf("%-7s", step.Decision)
+		_, _ = dimC.Printf(" %s\n", step.Reason)
+		if step.Decision == phases.PlanSkip {
+			continue
+		}
+		for _, a := range step.Phase.DryRun(state) {
+			fmt.Printf("        %-8s %s\n", a.Kind, a.Detail)
+		}
+	}
+	fmt.Println(strings.Repeat("─", 60))
+	ui.Info("Nothing was changed. Run without --plan to apply.")
+}
+
 // resolveNodeType determines the node topology from flag, saved state, or prompt.
 func resolveNodeType(state *config.State) error {
-	// Priority: --type flag > saved state > prompt
-	if flagNodeType != "" {
-		switch flagNodeType {
+	// Priority: --type flag / setup spec > saved state > prompt
+	spec := state.Spec()
+	if spec.NodeType != "" {
+		switch spec.NodeType {
 		case config.NodeTypeFull, config.NodeTypeNetwork, config.NodeTypeMLNode:
-			state.NodeType = flagNodeType
+			state.NodeType = spec.NodeType
 		default:
-			return fmt.Errorf("invalid --type value %q (must be full, network, or mlnode)", flagNodeType)
+			return fmt.Errorf("invalid --type value %q (must be full, network, or mlnode)", spec.NodeType)
 		}
 		// For mlnode, pre-populate network node fields from URL
-		if flagNodeType == config.NodeTypeMLNode && flagNetworkNodeURL != "" {
-			state.NetworkNodeURL = flagNetworkNodeURL
+		if nn := spec.NetworkNode; spec.NodeType == config.NodeTypeMLNode && nn != nil && nn.URL != "" {
+			state.NetworkNodeURL = nn.URL
 			// Extract IP from URL for PoC callback (e.g., "http://10.0.1.100:9200" → "10.0.1.100")
 			if state.NetworkNodeIP == "" {
-				state.NetworkNodeIP = extractIPFromURL(flagNetworkNodeURL)
+				state.NetworkNodeIP = nn.IP
+			}
+			if state.NetworkNodeIP == "" {
+				state.NetworkNodeIP = extractIPFromURL(nn.URL)
 			}
 		}
 		return nil
@@ -235,7 +408,7 @@ func resolveNodeType(state *config.State) error {
 	}
 
 	// In --yes mode, default to full
-	if yesFlag {
+	if ui.IsNonInteractive() {
 		state.NodeType = config.NodeTypeFull
 		return nil
 	}
@@ -290,6 +463,7 @@ func buildPhaseList(state *config.State) []phases.Phase {
 			phases.NewKeyManagement(state.KeyWorkflow, mockedSetup),
 			phases.NewConfigGeneration(),
 			phases.NewDeploy(),
+			phases.NewNetworkFirewall(),
 			phases.NewRegistration(),
 		}
 	case config.NodeTypeMLNode:
@@ -311,6 +485,7 @@ func buildPhaseList(state *config.State) []phases.Phase {
 			phases.NewKeyManagement(state.KeyWorkflow, mockedSetup),
 			phases.NewConfigGeneration(),
 			phases.NewDeploy(),
+			phases.NewNetworkFirewall(),
 			phases.NewRegistration(),
 		}
 	}
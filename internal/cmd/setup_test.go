@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/inc4/gonka-nop/internal/config"
+)
+
+// resetSetupFlags clears the setup flag globals after a test.
+func resetSetupFlags(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		flagConfigFile, flagNetwork, flagNodeType, flagKeyWorkflow = "", "", "", ""
+		flagKeyName, flagKeyringPass, accountPubKey, flagPublicIP = "", "", "", ""
+		flagKeyringPassFile, flagKeyringPassEnv = "", ""
+		flagHFHome, flagMLNodeImage, flagAttentionBackend, flagNetworkNodeURL = "", "", "", ""
+		flagPorts, flagExtP2PPort, flagExtAPIPort, flagIntP2PPort, flagIntAPIPort = "", "", "", "", ""
+		flagTLSDomain, flagACMEEmail, flagACMEDirectory = "", "", ""
+		flagPlan, flagFromPhase, flagOnlyPhase = false, "", nil
+	})
+}
+
+func TestSetupSpec_FlagsOverrideFile(t *testing.T) {
+	resetSetupFlags(t)
+	path := filepath.Join(t.TempDir(), "node.yaml")
+	content := "version: 1\nnetwork: mainnet\npublic_ip: 203.0.113.10\nkeys:\n  name: from-file\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	flagConfigFile = path
+	flagNetwork = "Testnet"
+	flagAttentionBackend = "flash_attn"
+	spec, err := setupSpec()
+	if err != nil {
+		t.Fatalf("setupSpec() error: %v", err)
+	}
+	if spec.Network != "testnet" {
+		t.Errorf("Network = %q, want flag value testnet", spec.Network)
+	}
+	if spec.PublicIP != "203.0.113.10" || spec.Keys.Name != "from-file" {
+		t.Errorf("file values lost: public_ip=%q keys.name=%q", spec.PublicIP, spec.Keys.Name)
+	}
+	if spec.AttentionBackend != config.AttentionFlashAttn {
+		t.Errorf("AttentionBackend = %q", spec.AttentionBackend)
+	}
+}
+
+func TestSetupSpec_KeyringSourceFlagReplacesFilePassword(t *testing.T) {
+	resetSetupFlags(t)
+	path := filepath.Join(t.TempDir(), "node.yaml")
+	content := "version: 1\nkeys:\n  keyring_password: from-the-file\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	flagConfigFile = path
+	flagKeyringPassEnv = "GONKA_KEYRING_PASSWORD"
+	spec, err := setupSpec()
+	if err != nil {
+		t.Fatalf("setupSpec() error: %v", err)
+	}
+	if spec.Keys.KeyringPassword != "" {
+		t.Error("--keyring-password-env should replace keys.keyring_password from the file")
+	}
+	if got := spec.Keys.PasswordSource(); got != "env:GONKA_KEYRING_PASSWORD" {
+		t.Errorf("PasswordSource() = %q", got)
+	}
+}
+
+func TestSetupSpec_PortFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		ports   string
+		extP2P  string
+		want    *config.SpecPorts
+		wantErr string
+	}{
+		{name: "none", want: nil},
+		{name: "default", ports: "default", want: &config.SpecPorts{}},
+		{name: "custom", ports: "custom", extP2P: "19245", want: &config.SpecPorts{P2P: 19245}},
+		{name: "port flag implies custom", extP2P: "19245", want: &config.SpecPorts{P2P: 19245}},
+		{name: "bad mode", ports: "nat", wantErr: "--ports"},
+		{name: "bad port", extP2P: "abc", wantErr: "--ext-p2p-port"},
+		{name: "out of range", extP2P: "70000", wantErr: "ports.p2p"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetSetupFlags(t)
+			flagPorts, flagExtP2PPort = tt.ports, tt.extP2P
+
+			spec, err := setupSpec()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (spec.Ports == nil) != (tt.want == nil) || (spec.Ports != nil && *spec.Ports != *tt.want) {
+				t.Errorf("Ports = %+v, want %+v", spec.Ports, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetupSpec_TLSFlags(t *testing.T) {
+	resetSetupFlags(t)
+	path := filepath.Join(t.TempDir(), "node.yaml")
+	content := "version: 1\ntls:\n  domain: old.example.com\n  email: ops@example.com\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	flagConfigFile = path
+	flagTLSDomain = "Node.Example.com"
+	spec, err := setupSpec()
+	if err != nil {
+		t.Fatalf("setupSpec() error: %v", err)
+	}
+	want := config.SpecTLS{Domain: "node.example.com", Email: "ops@example.com"}
+	if spec.TLS == nil || *spec.TLS != want {
+		t.Errorf("TLS = %+v, want %+v", spec.TLS, want)
+	}
+
+	flagConfigFile, flagTLSDomain = "", "node.example.com"
+	if _, err := setupSpec(); err == nil || !strings.Contains(err.Error(), "tls.email") {
+		t.Errorf("error = %v, want tls.email required", err)
+	}
+}
+
+func TestResolveNodeType_FromSpec(t *testing.T) {
+	state := config.NewState(t.TempDir())
+	state.SetSpec(&config.SetupSpec{
+		Version:     config.SetupSpecVersion,
+		NodeType:    config.NodeTypeMLNode,
+		NetworkNode: &config.SpecNetworkNode{URL: "http://10.0.1.100:9200"},
+	})
+	if err := resolveNodeType(state); err != nil {
+		t.Fatalf("resolveNodeType() error: %v", err)
+	}
+	if state.NodeType != config.NodeTypeMLNode || state.NetworkNodeIP != "10.0.1.100" {
+		t.Errorf("NodeType/NetworkNodeIP = %q/%q", state.NodeType, state.NetworkNodeIP)
+	}
+}
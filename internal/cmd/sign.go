@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/inc4/gonka-nop/internal/docker"
+	"github.com/inc4/gonka-nop/internal/phases"
+	"github.com/inc4/gonka-nop/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// accountAddressPrefix starts every bech32 account address.
+const accountAddressPrefix = "gonka1"
+
+var (
+	signOut         string
+	signYes         bool
+	signWarmAddress string
+)
+
+var signCmd = &cobra.Command{
+	Use:   "sign <unsigned-tx.json>",
+	Short: "Sign registration transactions with the account key (offline)",
+	Long: `Sign the transactions generated by 'gonka-nop register' (secure workflow)
+with the account key, on the machine that holds it. Signing runs inferenced
+in a Docker container without network access; nothing is sent anywhere.
+
+Every message is printed field by field before the confirmation: the server
+that generated the transactions is not trusted with the account key, so check
+the warm key address, consensus key and URL. With --warm-address, signing
+refuses transactions that name any other address than the account's and that one.
+
+The signed bundle is written next to the input as signed-tx.json. Copy it
+back to the server and broadcast it with:
+
+  gonka-nop register --signed signed-tx.json
+
+Examples:
+  gonka-nop sign unsigned-tx.json
+  gonka-nop sign unsigned-tx.json --warm-address gonka1...
+  gonka-nop sign unsigned-tx.json --name my-account --keyring-dir /media/usb/.inference`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSign,
+}
+
+func init() {
+	addAccountKeyFlags(signCmd)
+	signCmd.Flags().StringVar(&signOut, "out", "", "Signed bundle path (default: signed-tx.json next to the input)")
+	signCmd.Flags().BoolVarP(&signYes, "yes", "y", false, "Skip the confirmation prompt")
+	signCmd.Flags().StringVar(&signWarmAddress, "warm-address", "", "Expected warm key address: refuse transactions naming any other address")
+}
+
+func runSign(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	bundle, err := phases.LoadOfflineTxBundle(args[0])
+	if err != nil {
+		return err
+	}
+	if bundle.IsSigned() {
+		return fmt.Errorf("%s is already signed", args[0])
+	}
+	out := signOut
+	if out == "" {
+		out = filepath.Join(filepath.Dir(args[0]), "signed-tx.json")
+	}
+	if _, err := os.Stat(out); err == nil {
+		return fmt.Errorf("%s already exists — move it away or pass --out", out)
+	}
+
+	displayTxBundle(bundle)
+	if signWarmAddress != "" {
+		if err := checkTxAddresses(bundle, signWarmAddress); err != nil {
+			return err
+		}
+		ui.Success("Transactions name only the account and warm key %s", signWarmAddress)
+	}
+	if !signYes {
+		proceed, confirmErr := ui.Confirm(fmt.Sprintf("Sign these transactions with %q?", accountKeyName), true)
+		if confirmErr != nil {
+			return confirmErr
+		}
+		if !proceed {
+			ui.Info("Signing canceled.")
+			return nil
+		}
+	}
+
+	keyringDir, err := accountKeyringPath()
+	if err != nil {
+		return err
+	}
+	useSudo := docker.DetectSudo(ctx)
+	imageRef := "ghcr.io/product-science/inferenced:" + accountImageVersion
+	if err := requireLocalImage(ctx, imageRef, useSudo); err != nil {
+		return err
+	}
+	password, err := accountKeyringPassword(false)
+	if err != nil {
+		return err
+	}
+
+	err = ui.WithSpinner("Signing transactions", func() error {
+		return phases.SignOfflineTxs(ctx, bundle, imageRef, accountKeyName, password, keyringDir, useSudo)
+	})
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode transaction bundle: %w", err)
+	}
+	if err := writeNewFile(out, append(data, '\n')); err != nil {
+		return err
+	}
+
+	ui.Success("Signed %d transactions: %s", len(bundle.Txs), out)
+	ui.Detail("Copy it to the server and run: gonka-nop register --signed %s", filepath.Base(out))
+	return nil
+}
+
+// displayTxBundle prints what is about to be signed, with every field of
+// every message.
+func displayTxBundle(b *phases.OfflineTxBundle) {
+	boldC := color.New(color.Bold)
+	_, _ = boldC.Println("\nTransactions to Sign")
+	fmt.Println(strings.Repeat("─", 60))
+	fmt.Printf("  %-16s %s\n", "Chain:", b.ChainID)
+	fmt.Printf("  %-16s %s\n", "Signer:", b.Signer)
+	fmt.Printf("  %-16s %d\n", "Account number:", b.AccountNumber)
+	for i, tx := range b.Txs {
+		fmt.Printf("  %-16s %s (sequence %d)\n", fmt.Sprintf("Tx %d:", i+1), tx.Name, b.Sequence+uint64(i)) // #nosec G115 - small index
+		for _, msg := range txMessages(tx.Unsigned) {
+			msgType, _ := msg["@type"].(string)
+			fmt.Printf("  %-16s   %s\n", "", msgType)
+			for _, f := range messageFields(msg) {
+				fmt.Printf("  %-16s     %-22s %s\n", "", f.path+":", f.value)
+			}
+		}
+	}
+	fmt.Println()
+}
+
+// txMessages decodes the messages of an unsigned tx.
+func txMessages(unsigned json.RawMessage) []map[string]any {
+	var tx struct {
+		Body struct {
+			Messages []map[string]any `json:"messages"`
+		} `json:"body"`
+	}
+	if json.Unmarshal(unsigned, &tx) != nil {
+		return nil
+	}
+	return tx.Body.Messages
+}
+
+// messageField is one leaf value of a message, at a dotted path.
+type messageField struct {
+	path  string
+	value string
+}
+
+// messageFields flattens a message to its leaf values, sorted by path,
+// without @type.
+func messageFields(msg map[string]any) []messageField {
+	var fields []messageField
+	var walk func(path string, v any)
+	walk = func(path string, v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if path == "" && k == "@type" {
+					continue
+				}
+				walk(joinFieldPath(path, k), v[k])
+			}
+		case []any:
+			if len(v) == 0 {
+				fields = append(fields, messageField{path, "[]"})
+			}
+			for i, item := range v {
+				walk(fmt.Sprintf("%s[%d]", path, i), item)
+			}
+		case nil:
+			fields = append(fields, messageField{path, "null"})
+		default:
+			fields = append(fields, messageField{path, fmt.Sprint(v)})
+		}
+	}
+	walk("", msg)
+	return fields
+}
+
+func joinFieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// checkTxAddresses fails when a message names an account address other
+// than the signer's and the expected warm key, or when no grant names the
+// warm key at all.
+func checkTxAddresses(b *phases.OfflineTxBundle, warm string) error {
+	granted := false
+	for _, tx := range b.Txs {
+		for _, msg := range txMessages(tx.Unsigned) {
+			for _, f := range messageFields(msg) {
+				if !strings.HasPrefix(f.value, accountAddressPrefix) {
+					continue
+				}
+				switch f.value {
+				case b.Signer:
+				case warm:
+					granted = granted || tx.Name == phases.OfflineTxGrant
+				default:
+					return fmt.Errorf("transaction %s names %s in %s, neither the account %s nor the warm key %s — do not sign",
+						tx.Name, f.value, f.path, b.Signer, warm)
+				}
+			}
+		}
+	}
+	if !granted {
+		return fmt.Errorf("no %s transaction grants the warm key %s — do not sign", phases.OfflineTxGrant, warm)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/inc4/gonka-nop/internal/phases"
+)
+
+const (
+	testSigner = "gonka1account"
+	testWarm   = "gonka1warm"
+)
+
+func testTxBundle(grantee string) *phases.OfflineTxBundle {
+	tx := func(msg string) json.RawMessage {
+		return json.RawMessage(`{"body":{"messages":[` + msg + `],"memo":""}}`)
+	}
+	return &phases.OfflineTxBundle{
+		Signer: testSigner,
+		Txs: []phases.OfflineTx{
+			{Name: phases.OfflineTxRegister, Unsigned: tx(`{"@type":"/inference.inference.MsgSubmitNewParticipant",` +
+				`"creator":"` + testSigner + `","url":"http://203.0.113.5:8000","validator_key":"Q29uc2Vuc3Vz"}`)},
+			{Name: phases.OfflineTxGrant, Unsigned: tx(`{"@type":"/inference.inference.MsgGrantMLOpsPermissions",` +
+				`"granter":"` + testSigner + `","grantee":"` + grantee + `","permissions":["a","b"]}`)},
+		},
+	}
+}
+
+func TestMessageFields(t *testing.T) {
+	msgs := txMessages(testTxBundle(testWarm).Txs[1].Unsigned)
+	if len(msgs) != 1 {
+		t.Fatalf("txMessages() = %v, want one message", msgs)
+	}
+	var got []string
+	for _, f := range messageFields(msgs[0]) {
+		got = append(got, f.path+"="+f.value)
+	}
+	want := "grantee=gonka1warm granter=gonka1account permissions[0]=a permissions[1]=b"
+	if strings.Join(got, " ") != want {
+		t.Errorf("messageFields() = %v, want %s", got, want)
+	}
+}
+
+func TestCheckTxAddresses(t *testing.T) {
+	tests := []struct {
+		name    string
+		grantee string
+		warm    string
+		wantErr string
+	}{
+		{"expected warm key", testWarm, testWarm, ""},
+		{"grant to another address", "gonka1attacker", testWarm, "gonka1attacker"},
+		{"warm key not granted", testSigner, testWarm, "no grant-ml-ops-permissions"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTxAddresses(testTxBundle(tt.grantee), tt.warm)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("checkTxAddresses() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -45,6 +46,7 @@ var (
 	updateService  string
 	updateAdminURL string
 	updateYes      bool
+	updateNoAdmin  bool
 )
 
 func init() {
@@ -54,15 +56,27 @@ func init() {
 	// update command uses --admin-url as its own local flag.
 	updateCmd.Flags().StringVar(&updateAdminURL, "admin-url", defaultAdminURL, "Admin API URL")
 	updateCmd.Flags().BoolVarP(&updateYes, "yes", "y", false, "Skip confirmation prompts")
+	updateCmd.Flags().BoolVar(&updateNoAdmin, "no-admin", false,
+		"Skip the Admin API disable/wait/enable steps (used by 'fleet update', which drives them from the network node)")
 }
 
 // VersionDiff represents a version change for a single service.
 type VersionDiff struct {
-	Service    string
-	Current    string
-	Latest     string
-	HasUpdate  bool
-	AutoUpdate bool // true for Cosmovisor-managed (node, api)
+	Service    string `json:"service"`
+	Current    string `json:"current"`
+	Latest     string `json:"latest"`
+	HasUpdate  bool   `json:"has_update"`
+	AutoUpdate bool   `json:"auto_update"` // true for Cosmovisor-managed (node, api)
+}
+
+// updatePlan compares the local compose tags with the latest release.
+type updatePlan struct {
+	State     *config.State        `json:"-"`
+	Network   string               `json:"network"`
+	Source    string               `json:"source"`
+	Diffs     []VersionDiff        `json:"diffs"`
+	Updatable []VersionDiff        `json:"updatable"`
+	Latest    config.ImageVersions `json:"-"`
 }
 
 func runUpdate(cmd *cobra.Command, _ []string) error {
@@ -72,13 +86,48 @@ func runUpdate(cmd *cobra.Command, _ []string) error {
 		ui.SetNonInteractive(true)
 	}
 
-	state, err := config.Load(outputDir)
+	plan, err := planUpdate(ctx, outputDir, updateService)
+	if err != nil {
+		return err
+	}
+
+	// 4. Display version comparison
+	displayVersionDiffs(os.Stdout, plan.Diffs)
+
+	if len(plan.Updatable) == 0 {
+		ui.Success("All services are up to date.")
+		return nil
+	}
+
+	// --check mode: just show and exit
+	if updateCheck {
+		return nil
+	}
+
+	// 5. Confirm before applying
+	confirm, err := ui.Confirm(fmt.Sprintf("Apply %d update(s)?", len(plan.Updatable)), true)
 	if err != nil {
-		return fmt.Errorf("failed to load state: %w", err)
+		return err
+	}
+	if !confirm {
+		ui.Info("Update canceled.")
+		return nil
+	}
+
+	// 6. Apply updates
+	return applyUpdates(ctx, plan.State, plan.Updatable, plan.Latest, resolveUpdateAdminURL(plan.State))
+}
+
+// planUpdate loads the deployment in dir and computes the version diffs,
+// optionally limited to one service. Shared by update and the agent API.
+func planUpdate(ctx context.Context, dir, service string) (*updatePlan, error) {
+	state, err := config.Load(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
 	}
 
 	if state.OutputDir == "" {
-		return fmt.Errorf("no deployment found in %s — run 'gonka-nop setup' first", outputDir)
+		return nil, fmt.Errorf("no deployment found in %s — run 'gonka-nop setup' first", dir)
 	}
 
 	// Determine network type for fetching correct versions
@@ -87,7 +136,7 @@ func runUpdate(cmd *cobra.Command, _ []string) error {
 	// 1. Read current versions from local compose files
 	currentVersions, err := readLocalComposeVersions(state.OutputDir)
 	if err != nil {
-		return fmt.Errorf("failed to read current versions: %w", err)
+		return nil, fmt.Errorf("failed to read current versions: %w", err)
 	}
 
 	// 2. Fetch latest versions from GitHub
@@ -107,39 +156,21 @@ func runUpdate(cmd *cobra.Command, _ []string) error {
 	diffs := computeVersionDiffs(currentVersions, latestVersions)
 
 	// Filter by service if specified
-	if updateService != "" {
-		diffs = filterDiffs(diffs, updateService)
+	if service != "" {
+		diffs = filterDiffs(diffs, service)
 		if len(diffs) == 0 {
-			return fmt.Errorf("unknown service %q", updateService)
+			return nil, fmt.Errorf("unknown service %q", service)
 		}
 	}
 
-	// 4. Display version comparison
-	displayVersionDiffs(diffs)
-
-	updatable := filterUpdatable(diffs)
-	if len(updatable) == 0 {
-		ui.Success("All services are up to date.")
-		return nil
-	}
-
-	// --check mode: just show and exit
-	if updateCheck {
-		return nil
-	}
-
-	// 5. Confirm before applying
-	confirm, err := ui.Confirm(fmt.Sprintf("Apply %d update(s)?", len(updatable)), true)
-	if err != nil {
-		return err
-	}
-	if !confirm {
-		ui.Info("Update canceled.")
-		return nil
-	}
-
-	// 6. Apply updates
-	return applyUpdates(ctx, state, updatable, latestVersions)
+	return &updatePlan{
+		State:     state,
+		Network:   network,
+		Source:    latestVersions.Source,
+		Diffs:     diffs,
+		Updatable: filterUpdatable(diffs),
+		Latest:    latestVersions,
+	}, nil
 }
 
 // readLocalComposeVersions reads image tags from local compose files.
@@ -212,17 +243,17 @@ func filterUpdatable(diffs []VersionDiff) []VersionDiff {
 	return result
 }
 
-// displayVersionDiffs shows a table of current vs latest versions.
-func displayVersionDiffs(diffs []VersionDiff) {
+// displayVersionDiffs writes a table of current vs latest versions to w.
+func displayVersionDiffs(w io.Writer, diffs []VersionDiff) {
 	boldC := color.New(color.Bold)
 	greenC := color.New(color.FgGreen)
 	yellowC := color.New(color.FgYellow)
 	dimC := color.New(color.Faint)
 
-	_, _ = boldC.Println("\nVersion Comparison")
-	fmt.Println(strings.Repeat("─", 65))
-	fmt.Printf("  %-12s %-22s %-22s %s\n", "Service", "Current", "Latest", "Status")
-	fmt.Println(strings.Repeat("─", 65))
+	_, _ = boldC.Fprintln(w, "\nVersion Comparison")
+	_, _ = fmt.Fprintln(w, strings.Repeat("─", 65))
+	_, _ = fmt.Fprintf(w, "  %-12s %-22s %-22s %s\n", "Service", "Current", "Latest", "Status")
+	_, _ = fmt.Fprintln(w, strings.Repeat("─", 65))
 
 	for _, d := range diffs {
 		if d.Current == "" && d.Latest == "" {
@@ -238,22 +269,23 @@ func displayVersionDiffs(diffs []VersionDiff) {
 			latest = "(unknown)"
 		}
 
-		fmt.Printf("  %-12s %-22s %-22s ", d.Service, current, latest)
+		_, _ = fmt.Fprintf(w, "  %-12s %-22s %-22s ", d.Service, current, latest)
 
 		switch {
 		case d.HasUpdate && d.AutoUpdate:
-			_, _ = dimC.Println("auto-update (Cosmovisor)")
+			_, _ = dimC.Fprintln(w, "auto-update (Cosmovisor)")
 		case d.HasUpdate:
-			_, _ = yellowC.Println("UPDATE AVAILABLE")
+			_, _ = yellowC.Fprintln(w, "UPDATE AVAILABLE")
 		default:
-			_, _ = greenC.Println("up to date")
+			_, _ = greenC.Fprintln(w, "up to date")
 		}
 	}
-	fmt.Println()
+	_, _ = fmt.Fprintln(w)
 }
 
-// applyUpdates performs the actual update for each service.
-func applyUpdates(ctx context.Context, state *config.State, diffs []VersionDiff, latest config.ImageVersions) error {
+// applyUpdates performs the actual update for each service. adminURL is the
+// Admin API used to disable the ML node while it is replaced.
+func applyUpdates(ctx context.Context, state *config.State, diffs []VersionDiff, latest config.ImageVersions, adminURL string) error {
 	hasMLNode := false
 	for _, d := range diffs {
 		if d.Service == "mlnode" || d.Service == "nginx" {
@@ -264,7 +296,7 @@ func applyUpdates(ctx context.Context, state *config.State, diffs []VersionDiff,
 
 	// Safe MLNode rollout: disable → update compose → pull → recreate → wait → enable
 	if hasMLNode {
-		if err := safeMLNodeUpdate(ctx, state, latest); err != nil {
+		if err := safeMLNodeUpdate(ctx, state, latest, adminURL); err != nil {
 			return fmt.Errorf("ml node update failed: %w", err)
 		}
 	}
@@ -302,12 +334,16 @@ func filterNonMLNode(diffs []VersionDiff) []VersionDiff {
 
 // safeMLNodeUpdate performs a safe rolling update for the ML node:
 // disable → update compose → pull → recreate → wait model load → enable
-func safeMLNodeUpdate(ctx context.Context, state *config.State, latest config.ImageVersions) error {
-	boldC := color.New(color.Bold)
-	_, _ = boldC.Println("\nSafe ML Node Update")
-	fmt.Println(strings.Repeat("─", 40))
+func safeMLNodeUpdate(ctx context.Context, state *config.State, latest config.ImageVersions, adminAPI string) error {
+	ui.Header("Safe ML Node Update")
+
+	if updateNoAdmin {
+		if err := updateMLNodeComposeTags(state.OutputDir, latest); err != nil {
+			return fmt.Errorf("update compose tags: %w", err)
+		}
+		return pullAndRecreateMLNode(ctx, state)
+	}
 
-	adminAPI := resolveUpdateAdminURL(state)
 	nodeID := state.MLNodeID
 	if nodeID == "" {
 		nodeID = defaultNodeID
@@ -437,9 +473,7 @@ func updateMLNodeComposeTags(dir string, latest config.ImageVersions) error {
 
 // updateComposeServices updates non-MLNode services in docker-compose.yml.
 func updateComposeServices(ctx context.Context, state *config.State, diffs []VersionDiff) error {
-	boldC := color.New(color.Bold)
-	_, _ = boldC.Println("\nUpdating Services")
-	fmt.Println(strings.Repeat("─", 40))
+	ui.Header("Updating Services")
 
 	// Update image tags in docker-compose.yml
 	path := filepath.Join(state.OutputDir, "docker-compose.yml")
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 
@@ -305,6 +306,14 @@ func TestResolveUpdateAdminURL(t *testing.T) {
 	}
 }
 
+func TestDisplayVersionDiffs_WritesToWriter(t *testing.T) {
+	var buf bytes.Buffer
+	displayVersionDiffs(&buf, []VersionDiff{diffEntry(svcMLNode, "3.0.11", testMLTag, false)})
+	if out := buf.String(); !strings.Contains(out, "Version Comparison") || !strings.Contains(out, testMLTag) {
+		t.Errorf("table not written to the writer:\n%s", out)
+	}
+}
+
 func TestDiffEntry(t *testing.T) {
 	d := diffEntry(svcMLNode, "3.0.11", testMLTag, false)
 	if !d.HasUpdate {
@@ -0,0 +1,271 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/inc4/gonka-nop/internal/secret"
+)
+
+// KeyringPasswordVar is the environment variable inferenced and the api
+// container read the keyring password from.
+const KeyringPasswordVar = "KEYRING_PASSWORD"
+
+// KeyringEnvPath returns the env file compose hands to the api container:
+// the password source file with ".env" appended. It is "" unless the
+// password comes from a file; otherwise nothing is written and the api
+// container gets the password from the compose process environment.
+func (s *State) KeyringEnvPath() string {
+	path, ok := strings.CutPrefix(s.KeyringPasswordSource, "file:")
+	if !ok || path == "" {
+		return ""
+	}
+	return filepath.Clean(path) + ".env"
+}
+
+// KeyringPassthrough reports whether the api container gets the keyring
+// password from the compose process environment instead of an env file.
+func (s *State) KeyringPassthrough() bool {
+	return !s.IsMLNodeOnly() && s.KeyringEnvPath() == ""
+}
+
+// KeyringPasswordProvider returns the provider for KeyringPasswordSource.
+// An empty source prompts when the password is needed.
+func (s *State) KeyringPasswordProvider() (secret.Provider, error) {
+	p, err := secret.Parse(s.KeyringPasswordSource, KeyringPasswordVar)
+	if err != nil {
+		return nil, fmt.Errorf("keyring password source: %w", err)
+	}
+	if _, ok := p.(secret.Prompt); ok {
+		return secret.Prompt{
+			Message: "Enter keyring password:",
+			Hint:    "use --keyring-password-file or --keyring-password-env",
+		}, nil
+	}
+	return p, nil
+}
+
+// ResolveKeyringPassword returns the keyring password for this run: the value
+// already resolved, then the setup spec, then KeyringPasswordSource. The
+// result is cached in memory only.
+func (s *State) ResolveKeyringPassword() (string, error) {
+	if s.KeyringPassword != "" {
+		return s.KeyringPassword, nil
+	}
+	password := s.Spec().Keys.KeyringPassword
+	if password == "" {
+		p, err := s.KeyringPasswordProvider()
+		if err != nil {
+			return "", err
+		}
+		if password, err = p.Secret(); err != nil {
+			return "", fmt.Errorf("keyring password: %w", err)
+		}
+	}
+	s.KeyringPassword = password
+	return password, nil
+}
+
+// WriteKeyringEnv writes the env file at KeyringEnvPath with mode 0600. The
+// file must live outside the output directory, which is shared with the
+// containers and copied around by operators.
+func (s *State) WriteKeyringEnv(password string) error {
+	path := s.KeyringEnvPath()
+	if path == "" {
+		return fmt.Errorf("keyring password source is not a file")
+	}
+	if insideDir(s.OutputDir, path) {
+		return fmt.Errorf("keyring password file %s must be outside the output directory %s",
+			strings.TrimSuffix(path, ".env"), s.OutputDir)
+	}
+	return secret.WriteFile(path, KeyringPasswordVar, password, 0600)
+}
+
+// migratedKeyringPath is where migration moves a password found in the
+// output directory: the user's config directory, one subdirectory per
+// output directory.
+func (s *State) migratedKeyringPath() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("locate config directory: %w", err)
+	}
+	dir, err := filepath.Abs(s.OutputDir)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(dir))
+	return filepath.Join(base, "gonka-nop", "keyring", hex.EncodeToString(sum[:6]), "keyring-password"), nil
+}
+
+// migrateKeyringPassword moves a plaintext password left in the output
+// directory by older versions (state.json or config.env) to a new file under
+// the user's config directory, and points the compose file at it.
+func (s *State) migrateKeyringPassword(legacy string) error {
+	envPath := filepath.Join(s.OutputDir, "config.env")
+	envPassword, inEnv := readEnvValue(envPath, KeyringPasswordVar)
+	password := legacy
+	if password == "" {
+		password = envPassword
+	}
+	if password == "" {
+		return nil
+	}
+
+	path, err := s.migratedKeyringPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("create keyring password dir: %w", err)
+	}
+	if err := secret.WriteFile(path, "", password, secret.RequiredFileMode); err != nil {
+		return err
+	}
+	s.KeyringPasswordSource = secret.FileRef(path)
+	if err := s.WriteKeyringEnv(password); err != nil {
+		return err
+	}
+
+	// Only drop the password from config.env once the compose file reads it
+	// from the new place, otherwise the api container would lose it.
+	patched, err := patchComposeKeyring(filepath.Join(s.OutputDir, "docker-compose.yml"), s.KeyringEnvPath())
+	if err != nil {
+		return err
+	}
+	if patched && inEnv {
+		return removeEnvLine(envPath, KeyringPasswordVar)
+	}
+	return nil
+}
+
+// keyringInOutputDir reports whether an older version left the keyring
+// password in config.env, without changing anything.
+func (s *State) keyringInOutputDir() bool {
+	_, inEnv := readEnvValue(filepath.Join(s.OutputDir, "config.env"), KeyringPasswordVar)
+	return inEnv
+}
+
+// insideDir reports whether path is dir or below it.
+func insideDir(dir, path string) bool {
+	absDir, errDir := filepath.Abs(dir)
+	absPath, errPath := filepath.Abs(path)
+	if errDir != nil || errPath != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// readEnvValue returns the value of key in a KEY=value env file.
+func readEnvValue(path, key string) (string, bool) {
+	data, err := os.ReadFile(filepath.Clean(path)) // #nosec G304 - path inside output dir
+	if err != nil {
+		return "", false
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, key+"=") {
+			return strings.Trim(strings.TrimPrefix(line, key+"="), `"'`), true
+		}
+	}
+	return "", false
+}
+
+// removeEnvLine rewrites an env file without any KEY= lines.
+func removeEnvLine(path, key string) error {
+	data, err := os.ReadFile(filepath.Clean(path)) // #nosec G304 - path inside output dir
+	if err != nil {
+		return fmt.Errorf("read %s: %w", filepath.Base(path), err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), key+"=") {
+			kept = append(kept, line)
+		}
+	}
+	return os.WriteFile(path, []byte(strings.Join(kept, "")), 0600)
+}
+
+// patchComposeKeyring rewrites the api service of a compose file generated
+// by older versions, which interpolated ${KEYRING_PASSWORD}, to load envFile
+// instead. It reports true when the compose file no longer reads the
+// password from the output dir (including when the file does not exist).
+func patchComposeKeyring(path, envFile string) (bool, error) {
+	data, err := os.ReadFile(filepath.Clean(path)) // #nosec G304 - path inside output dir
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("read compose file: %w", err)
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	start, end := serviceBlock(lines, "api")
+	if start < 0 {
+		return true, nil
+	}
+	item := "- " + envFile
+	out := make([]string, 0, len(lines)+1)
+	last, found := -1, false // index in out of the last env_file item
+	list := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if i <= start || i >= end {
+			out = append(out, line)
+			continue
+		}
+		if trimmed == "- KEYRING_PASSWORD=${KEYRING_PASSWORD}" {
+			continue
+		}
+		if strings.HasSuffix(trimmed, ":") && !strings.HasPrefix(trimmed, "- ") {
+			list = trimmed
+		}
+		found = found || trimmed == item
+		out = append(out, line)
+		if list == "env_file:" && strings.HasPrefix(trimmed, "- ") {
+			last = len(out) - 1
+		}
+	}
+	if !found {
+		if last < 0 {
+			return false, nil
+		}
+		indent := out[last][:len(out[last])-len(strings.TrimLeft(out[last], " "))]
+		out = slices.Insert(out, last+1, indent+item+"\n")
+	}
+
+	patched := strings.Join(out, "")
+	if patched == string(data) {
+		return true, nil
+	}
+	if err := os.WriteFile(path, []byte(patched), 0600); err != nil {
+		return false, fmt.Errorf("write compose file: %w", err)
+	}
+	return true, nil
+}
+
+// serviceBlock returns the line range (header, next service) of a compose
+// service; start is -1 when it is not defined.
+func serviceBlock(lines []string, name string) (start, end int) {
+	start = -1
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if start >= 0 && trimmed != "" && !strings.HasPrefix(trimmed, "#") && indent <= 2 {
+			return start, i
+		}
+		if strings.TrimRight(line, "\r\n") == "  "+name+":" {
+			start = i
+		}
+	}
+	return start, len(lines)
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/inc4/gonka-nop/internal/ui"
+)
+
+const legacyCompose = `services:
+  api:
+    container_name: api
+    environment:
+      - KEY_NAME=${KEY_NAME}
+      - KEYRING_BACKEND=file
+      - KEYRING_PASSWORD=${KEYRING_PASSWORD}
+      - NODE_CONFIG_PATH=/root/node_config.json
+    restart: always
+    env_file:
+      - config.env
+
+  bridge:
+    container_name: bridge
+    env_file:
+      - config.env
+`
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path) // #nosec G304 - test temp dir
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// assertNoPassword fails if any file in dir still holds the password.
+func assertNoPassword(t *testing.T, dir, password string) {
+	t.Helper()
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if strings.Contains(readTestFile(t, path), password) {
+			t.Errorf("%s still contains the password", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad_MigratesLegacyKeyringPassword(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "state.json"),
+		`{"output_dir": "`+dir+`", "key_workflow": "quick", "keyring_password": "legacy-pass"}`)
+	writeTestFile(t, filepath.Join(dir, "config.env"),
+		"KEY_NAME=node\nKEYRING_PASSWORD=legacy-pass\nKEYRING_BACKEND=file\n")
+	writeTestFile(t, filepath.Join(dir, "docker-compose.yml"), legacyCompose)
+
+	before := readTestFile(t, filepath.Join(dir, "docker-compose.yml"))
+	state, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if !state.MigrationPending() {
+		t.Fatal("MigrationPending() = false")
+	}
+	if readTestFile(t, filepath.Join(dir, "docker-compose.yml")) != before ||
+		!strings.Contains(readTestFile(t, filepath.Join(dir, "config.env")), "legacy-pass") {
+		t.Fatal("Load() changed files in the output dir")
+	}
+	if err := state.Save(); err == nil {
+		t.Fatal("Save() before MigrateKeyringPassword() should fail")
+	}
+	if readTestFile(t, filepath.Join(dir, "docker-compose.yml")) != before || !strings.Contains(
+		readTestFile(t, filepath.Join(dir, "state.json")), "legacy-pass") {
+		t.Fatal("Save() changed files in the output dir")
+	}
+	if err := state.MigrateKeyringPassword(); err != nil {
+		t.Fatalf("MigrateKeyringPassword() error: %v", err)
+	}
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	assertNoPassword(t, dir, "legacy-pass")
+	if got := readTestFile(t, filepath.Join(dir, "config.env")); got != "KEY_NAME=node\nKEYRING_BACKEND=file\n" {
+		t.Errorf("config.env = %q", got)
+	}
+
+	source := strings.TrimPrefix(state.KeyringPasswordSource, "file:")
+	if !strings.HasPrefix(source, filepath.Join(configHome, "gonka-nop", "keyring")+string(filepath.Separator)) {
+		t.Fatalf("KeyringPasswordSource = %q, want a file under the user config dir", state.KeyringPasswordSource)
+	}
+	for path, mode := range map[string]os.FileMode{source: 0400, state.KeyringEnvPath(): 0600} {
+		info, err := os.Stat(path)
+		if err != nil || info.Mode().Perm() != mode {
+			t.Errorf("%s: %v, mode %v, want %04o", path, err, info, mode)
+		}
+	}
+	got, err := state.ResolveKeyringPassword()
+	if err != nil || got != "legacy-pass" {
+		t.Errorf("ResolveKeyringPassword() = %q, %v", got, err)
+	}
+
+	compose := readTestFile(t, filepath.Join(dir, "docker-compose.yml"))
+	if strings.Contains(compose, "${KEYRING_PASSWORD}") {
+		t.Error("compose still interpolates KEYRING_PASSWORD")
+	}
+	if !strings.Contains(compose, "      - config.env\n      - "+state.KeyringEnvPath()+"\n\n  bridge:") {
+		t.Errorf("keyring env file not added to the api service:\n%s", compose)
+	}
+
+	// Nothing is left to migrate
+	again, err := Load(dir)
+	if err != nil {
+		t.Fatalf("second Load() error: %v", err)
+	}
+	if again.MigrationPending() {
+		t.Error("second Load() found a migration")
+	}
+}
+
+func TestLoad_KeepsConfigEnvWhenComposeUnpatchable(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "state.json"), `{"output_dir": "`+dir+`"}`)
+	writeTestFile(t, filepath.Join(dir, "config.env"), "KEYRING_PASSWORD=legacy-pass\n")
+	// Hand-edited compose without an env_file for api
+	writeTestFile(t, filepath.Join(dir, "docker-compose.yml"),
+		"services:\n  api:\n    environment:\n      - KEYRING_PASSWORD=${KEYRING_PASSWORD}\n")
+
+	state, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if err := state.MigrateKeyringPassword(); err != nil {
+		t.Fatalf("MigrateKeyringPassword() error: %v", err)
+	}
+	if !strings.Contains(readTestFile(t, filepath.Join(dir, "config.env")), "KEYRING_PASSWORD=legacy-pass") {
+		t.Error("config.env was scrubbed although compose still needs the variable")
+	}
+	if _, err := os.Stat(state.KeyringEnvPath()); err != nil {
+		t.Errorf("keyring env file should still be written: %v", err)
+	}
+}
+
+func TestWriteKeyringEnv_RejectsOutputDir(t *testing.T) {
+	state := NewState(t.TempDir())
+	state.KeyringPasswordSource = "file:" + filepath.Join(state.OutputDir, "keyring-password")
+	if err := state.WriteKeyringEnv("pw"); err == nil {
+		t.Error("expected an error for a password file inside the output dir")
+	}
+	state.KeyringPasswordSource = "env:GONKA_KEYRING"
+	if err := state.WriteKeyringEnv("pw"); err == nil {
+		t.Error("expected an error without a file source")
+	}
+}
+
+func TestSave_NeverWritesKeyringPassword(t *testing.T) {
+	state := NewState(t.TempDir())
+	state.KeyringPassword = "in-memory-only"
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(readTestFile(t, filepath.Join(state.OutputDir, "state.json")), "in-memory-only") {
+		t.Error("state.json contains the keyring password")
+	}
+}
+
+func TestResolveKeyringPassword_Order(t *testing.T) {
+	t.Setenv("GONKA_TEST_KEYRING", "from-env")
+
+	state := NewState(t.TempDir())
+	state.KeyringPasswordSource = "env:GONKA_TEST_KEYRING"
+	if got, err := state.ResolveKeyringPassword(); err != nil || got != "from-env" {
+		t.Errorf("env source: %q, %v", got, err)
+	}
+
+	// The spec value wins over the source for the current run
+	state = NewState(t.TempDir())
+	state.KeyringPasswordSource = "env:GONKA_TEST_KEYRING"
+	state.SetSpec(&SetupSpec{Keys: SpecKeys{KeyringPassword: "from-spec"}})
+	if got, _ := state.ResolveKeyringPassword(); got != "from-spec" {
+		t.Errorf("spec value: got %q", got)
+	}
+
+	// Default source prompts, which fails without a terminal
+	ui.SetNonInteractive(true)
+	t.Cleanup(func() { ui.SetNonInteractive(false) })
+	state = NewState(t.TempDir())
+	if _, err := state.ResolveKeyringPassword(); err == nil || !strings.Contains(err.Error(), "--keyring-password-file") {
+		t.Errorf("expected prompt error with hint, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// LockFile is the advisory lock commands that change the deployment hold in
+// the output directory. It records the holder's pid.
+const LockFile = ".gonka-nop.lock"
+
+// LockedError is returned by LockOutputDir when another process holds the lock.
+type LockedError struct {
+	Dir string
+	PID int // 0 when the holder's pid could not be read
+}
+
+func (e *LockedError) Error() string {
+	if e.PID > 0 {
+		return fmt.Sprintf("another gonka-nop is running (pid %d) against %s", e.PID, e.Dir)
+	}
+	return fmt.Sprintf("another gonka-nop is running against %s", e.Dir)
+}
+
+// Lock is a held output directory lock.
+type Lock struct {
+	f *os.File
+}
+
+// LockOutputDir takes the advisory lock for dir without waiting. The
+// directory must exist: a mistyped --output should fail, not leave an empty
+// directory behind. The kernel drops the lock if the process dies, so a
+// stale lock file never blocks later runs.
+func LockOutputDir(dir string) (*Lock, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("output directory %s does not exist", dir)
+		}
+		return nil, fmt.Errorf("output directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("output directory %s is not a directory", dir)
+	}
+	path := filepath.Join(dir, LockFile)
+	f, err := os.OpenFile(filepath.Clean(path), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+	fd := int(f.Fd()) // #nosec G115 - file descriptors fit in an int
+	if err := syscall.Flock(fd, syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		holder, _ := io.ReadAll(f)
+		_ = f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			pid, _ := strconv.Atoi(strings.TrimSpace(string(holder)))
+			return nil, &LockedError{Dir: dir, PID: pid}
+		}
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &Lock{f: f}, nil
+}
+
+// Release drops the lock. It is safe to call on a nil Lock.
+func (l *Lock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	_ = l.f.Truncate(0)
+	fd := int(l.f.Fd()) // #nosec G115 - file descriptors fit in an int
+	unlockErr := syscall.Flock(fd, syscall.LOCK_UN)
+	closeErr := l.f.Close()
+	l.f = nil
+	if unlockErr != nil {
+		return fmt.Errorf("unlock: %w", unlockErr)
+	}
+	return closeErr
+}
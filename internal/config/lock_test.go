@@ -0,0 +1,57 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLockOutputDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "node")
+
+	if _, err := LockOutputDir(dir); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("LockOutputDir() on a missing dir = %v, want a does-not-exist error", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatal("LockOutputDir() created the directory")
+	}
+	if err := os.Mkdir(dir, 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := LockOutputDir(dir)
+	if err != nil {
+		t.Fatalf("LockOutputDir() error: %v", err)
+	}
+	if got := strings.TrimSpace(readTestFile(t, filepath.Join(dir, LockFile))); got != strconv.Itoa(os.Getpid()) {
+		t.Errorf("lock file holds %q, want our pid", got)
+	}
+
+	// flock locks belong to the open file, so a second open conflicts even
+	// within one process
+	_, err = LockOutputDir(dir)
+	var locked *LockedError
+	if !errors.As(err, &locked) || locked.PID != os.Getpid() {
+		t.Fatalf("second LockOutputDir() = %v, want LockedError with our pid", err)
+	}
+	if !strings.Contains(err.Error(), "another gonka-nop is running (pid ") {
+		t.Errorf("error = %q", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release() error: %v", err)
+	}
+	again, err := LockOutputDir(dir)
+	if err != nil {
+		t.Fatalf("LockOutputDir() after release: %v", err)
+	}
+	_ = again.Release()
+
+	var none *Lock
+	if err := none.Release(); err != nil {
+		t.Errorf("nil Release() = %v", err)
+	}
+}
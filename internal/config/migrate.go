@@ -0,0 +1,199 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CurrentSchemaVersion is the state.json layout this build reads and
+// writes. State files without schema_version are version 0.
+const CurrentSchemaVersion = 3
+
+// StateBackupDir is where Save keeps a copy of state.json before each
+// migration Load applied, relative to the output directory.
+const StateBackupDir = "backups"
+
+// stateMigration upgrades a state.json document from version from to from+1.
+type stateMigration struct {
+	from int
+	desc string
+	up   func(m *migrationRun) error
+}
+
+// migrationRun is the document being migrated, decoded with json.Number so
+// untouched values round-trip unchanged.
+type migrationRun struct {
+	doc map[string]any
+	// keyringPassword is the plaintext password dropped from state.json;
+	// Load moves it out of the output dir once the state is decoded.
+	keyringPassword string
+}
+
+// stateMigrations are applied in order. Append only: a released migration
+// must keep working on the files it was written for.
+var stateMigrations = []stateMigration{
+	{
+		from: 0,
+		desc: "fill node_type, ports and mlnode_id missing from early state files",
+		up: func(m *migrationRun) error {
+			setDefault(m.doc, "node_type", NodeTypeFull)
+			setDefault(m.doc, "mlnode_id", "node1")
+			for key, port := range map[string]int{
+				"p2p_port": 5000, "api_port": 8000,
+				"internal_p2p_port": 5000, "internal_api_port": 8000,
+				"inference_port": 5050, "poc_port": 8080,
+			} {
+				setDefault(m.doc, key, json.Number(fmt.Sprint(port)))
+			}
+			return nil
+		},
+	},
+	{
+		from: 1,
+		desc: "drop the plaintext keyring_password (moved out of the output dir)",
+		up: func(m *migrationRun) error {
+			if v, ok := m.doc["keyring_password"]; ok {
+				password, isString := v.(string)
+				if !isString {
+					return fmt.Errorf("keyring_password is not a string")
+				}
+				m.keyringPassword = password
+				delete(m.doc, "keyring_password")
+			}
+			return nil
+		},
+	},
+	{
+		from: 2,
+		desc: "move mlnode_image_tag to versions.mlnode",
+		up: func(m *migrationRun) error {
+			v, ok := m.doc["mlnode_image_tag"]
+			if !ok {
+				return nil
+			}
+			delete(m.doc, "mlnode_image_tag")
+			tag, isString := v.(string)
+			if !isString {
+				return fmt.Errorf("mlnode_image_tag is not a string")
+			}
+			if tag == "" {
+				return nil
+			}
+			// The GPU-specific tag took precedence over the fetched one
+			versions, _ := m.doc["versions"].(map[string]any)
+			if versions == nil {
+				versions = map[string]any{}
+				m.doc["versions"] = versions
+			}
+			versions["mlnode"] = tag
+			return nil
+		},
+	},
+}
+
+// stateBackup is a copy of state.json as it was before a migration step.
+type stateBackup struct {
+	name string
+	data []byte
+}
+
+// migrateState upgrades raw state.json content to CurrentSchemaVersion in
+// memory. It returns the migrated content, a copy of the document before each
+// step (written by Save) and any plaintext keyring password it removed.
+func migrateState(data []byte) ([]byte, []stateBackup, string, error) {
+	m := &migrationRun{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&m.doc); err != nil {
+		return nil, nil, "", err
+	}
+	version, err := schemaVersion(m.doc)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if version > CurrentSchemaVersion {
+		return nil, nil, "", fmt.Errorf("state.json has schema version %d, newer than this gonka-nop supports (%d): upgrade gonka-nop",
+			version, CurrentSchemaVersion)
+	}
+	if version == CurrentSchemaVersion {
+		return data, nil, "", nil
+	}
+
+	stamp := time.Now().Format("20060102-150405")
+	backups := make([]stateBackup, 0, CurrentSchemaVersion-version)
+	for _, step := range stateMigrations[version:] {
+		backup, err := stateDocBackup(stamp, step.from, m.doc)
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("backup before migration %d: %w", step.from, err)
+		}
+		backups = append(backups, backup)
+		if err := step.up(m); err != nil {
+			return nil, nil, "", fmt.Errorf("migrate state from version %d (%s): %w", step.from, step.desc, err)
+		}
+		m.doc["schema_version"] = step.from + 1
+	}
+	out, err := json.Marshal(m.doc)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return out, backups, m.keyringPassword, nil
+}
+
+func schemaVersion(doc map[string]any) (int, error) {
+	v, ok := doc["schema_version"]
+	if !ok {
+		return 0, nil
+	}
+	n, isNumber := v.(json.Number)
+	if !isNumber {
+		return 0, fmt.Errorf("state.json: schema_version is not a number")
+	}
+	version, err := n.Int64()
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("state.json: invalid schema_version %s", n)
+	}
+	return int(version), nil
+}
+
+func setDefault(doc map[string]any, key string, value any) {
+	if v, ok := doc[key]; !ok || v == nil || v == "" || v == json.Number("0") {
+		doc[key] = value
+	}
+}
+
+// stateDocBackup renders the document as it is before a migration step, to
+// be saved as state-v<version>-<stamp>.json. A plaintext keyring password is
+// redacted: it is moved out of the output dir, not kept in a backup.
+func stateDocBackup(stamp string, version int, doc map[string]any) (stateBackup, error) {
+	backup := doc
+	if _, ok := doc["keyring_password"]; ok {
+		backup = maps.Clone(doc)
+		backup["keyring_password"] = "(redacted)"
+	}
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return stateBackup{}, err
+	}
+	return stateBackup{name: fmt.Sprintf("state-v%d-%s.json", version, stamp), data: data}, nil
+}
+
+// writeStateBackups writes the backups of a migration to dir.
+func writeStateBackups(dir string, backups []stateBackup) error {
+	if len(backups) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+	for _, b := range backups {
+		if err := os.WriteFile(filepath.Join(dir, b.name), b.data, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStateMigrations_Contiguous(t *testing.T) {
+	for i, m := range stateMigrations {
+		if m.from != i {
+			t.Errorf("stateMigrations[%d].from = %d, want %d", i, m.from, i)
+		}
+	}
+	if len(stateMigrations) != CurrentSchemaVersion {
+		t.Errorf("%d migrations, CurrentSchemaVersion = %d", len(stateMigrations), CurrentSchemaVersion)
+	}
+}
+
+func TestLoad_MigratesUnversionedState(t *testing.T) {
+	dir := t.TempDir()
+	original := `{"output_dir": "` + dir + `", "network": "mainnet", "public_ip": "203.0.113.5", "api_port": 8443, "gpu_memory_util": 0.9}`
+	writeTestFile(t, filepath.Join(dir, "state.json"), original)
+
+	state, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if state.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", state.SchemaVersion, CurrentSchemaVersion)
+	}
+	if state.NodeType != NodeTypeFull || state.MLNodeID != "node1" || state.P2PPort != 5000 || state.PoCPort != 8080 {
+		t.Errorf("defaults not filled: node_type %q, mlnode_id %q, p2p %d, poc %d",
+			state.NodeType, state.MLNodeID, state.P2PPort, state.PoCPort)
+	}
+	if state.APIPort != 8443 || state.GPUMemoryUtil != 0.9 || state.PublicIP != "203.0.113.5" {
+		t.Errorf("existing values changed: api_port %d, gpu_memory_util %v, public_ip %q",
+			state.APIPort, state.GPUMemoryUtil, state.PublicIP)
+	}
+
+	if readTestFile(t, filepath.Join(dir, "state.json")) != original {
+		t.Error("Load() rewrote state.json")
+	}
+	if _, err := os.Stat(filepath.Join(dir, StateBackupDir)); !os.IsNotExist(err) {
+		t.Error("Load() wrote backups")
+	}
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(readTestFile(t, filepath.Join(dir, "state.json")), `"schema_version": 3`) {
+		t.Error("migrated state was not saved")
+	}
+	backups, _ := filepath.Glob(filepath.Join(dir, StateBackupDir, "state-v*.json"))
+	if len(backups) != CurrentSchemaVersion {
+		t.Fatalf("backups = %v, want one per migration", backups)
+	}
+	if !strings.Contains(readTestFile(t, backups[0]), `"api_port": 8443`) {
+		t.Error("backup does not hold the original values")
+	}
+
+	// Loading a current file migrates nothing
+	if _, err := Load(dir); err != nil {
+		t.Fatalf("second Load() error: %v", err)
+	}
+	if again, _ := filepath.Glob(filepath.Join(dir, StateBackupDir, "state-v*.json")); len(again) != len(backups) {
+		t.Errorf("second Load() wrote backups: %v", again)
+	}
+}
+
+func TestLoad_MigrationBackupRedactsKeyringPassword(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "state.json"), `{"output_dir": "`+dir+`", "keyring_password": "legacy-pass"}`)
+
+	state, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if err := state.MigrateKeyringPassword(); err != nil {
+		t.Fatalf("MigrateKeyringPassword() error: %v", err)
+	}
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	backups, _ := filepath.Glob(filepath.Join(dir, StateBackupDir, "state-v*.json"))
+	if len(backups) == 0 {
+		t.Fatal("no backups written")
+	}
+	for _, b := range backups {
+		if strings.Contains(readTestFile(t, b), "legacy-pass") {
+			t.Errorf("%s contains the plaintext password", filepath.Base(b))
+		}
+	}
+}
+
+func TestLoad_MovesMLNodeImageTagToVersions(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"tag overrides fetched version", `"mlnode_image_tag": "3.0.12-blackwell", "versions": {"mlnode": "3.0.12", "node": "0.2.9"}`, "3.0.12-blackwell"},
+		{"no versions yet", `"mlnode_image_tag": "3.0.12"`, "3.0.12"},
+		{"empty tag keeps fetched version", `"mlnode_image_tag": "", "versions": {"mlnode": "3.0.11"}`, "3.0.11"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFile(t, filepath.Join(dir, "state.json"),
+				`{"schema_version": 2, "output_dir": "`+dir+`", `+tt.doc+`}`)
+
+			state, err := Load(dir)
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if state.Versions.MLNode != tt.want {
+				t.Errorf("Versions.MLNode = %q, want %q", state.Versions.MLNode, tt.want)
+			}
+			if err := state.Save(); err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(readTestFile(t, filepath.Join(dir, "state.json")), "mlnode_image_tag") {
+				t.Error("mlnode_image_tag is still saved")
+			}
+		})
+	}
+}
+
+func TestLoad_RejectsNewerSchema(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "state.json"), `{"schema_version": 99, "output_dir": "`+dir+`"}`)
+
+	if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("Load() error = %v, want a newer-schema error", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, StateBackupDir)); !os.IsNotExist(err) {
+		t.Error("a backup was written for a state that was not migrated")
+	}
+}
+
+// TestReset_ClearsEveryField sets every field, so a field added to State
+// without a default in NewState cannot survive Reset. Only the StateHistory
+// setting is kept.
+func TestReset_ClearsEveryField(t *testing.T) {
+	state := NewState("/tmp/test")
+	fillNonZero(reflect.ValueOf(state).Elem())
+	state.OutputDir = "/tmp/test"
+
+	state.Reset()
+
+	want := NewState("/tmp/test")
+	want.StateHistory = 7
+	if !reflect.DeepEqual(state, want) {
+		t.Errorf("Reset() left values behind:\n got %+v\nwant %+v", *state, *want)
+	}
+}
+
+// fillNonZero sets every settable field of v to a non-zero value.
+func fillNonZero(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int64:
+		v.SetInt(7)
+	case reflect.Float64:
+		v.SetFloat(0.7)
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fillNonZero(v.Index(0))
+	case reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+		v.SetMapIndex(reflect.ValueOf("x"), reflect.ValueOf("x"))
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+		fillNonZero(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).CanSet() {
+				fillNonZero(v.Field(i))
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// StateHistoryDir holds previous versions of state.json, relative to the
+// output directory.
+const StateHistoryDir = StateBackupDir + "/state-history"
+
+// writeFileAtomic writes data to a temporary file next to path, syncs it and
+// renames it over path.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer func() { _ = os.Remove(tmpPath) }() // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// archiveState copies the current state.json into the history directory
+// before it is replaced by next, keeping the newest keep copies. Nothing is
+// archived when the content does not change.
+func archiveState(statePath string, next []byte, keep int) error {
+	current, err := os.ReadFile(filepath.Clean(statePath))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(current, next) {
+		return nil
+	}
+
+	dir := filepath.Join(filepath.Dir(statePath), StateHistoryDir)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+	name := "state-" + time.Now().Format("20060102-150405.000000") + ".json"
+	if err := os.WriteFile(filepath.Join(dir, name), current, 0600); err != nil {
+		return err
+	}
+
+	// Timestamped names sort oldest first
+	old, err := filepath.Glob(filepath.Join(dir, "state-*.json"))
+	if err != nil {
+		return err
+	}
+	slices.Sort(old)
+	for len(old) > keep {
+		if err := os.Remove(old[0]); err != nil {
+			return fmt.Errorf("prune %s: %w", filepath.Base(old[0]), err)
+		}
+		old = old[1:]
+	}
+	return nil
+}
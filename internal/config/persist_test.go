@@ -0,0 +1,68 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSave_Atomic(t *testing.T) {
+	dir := t.TempDir()
+	state := NewState(dir)
+	state.Network = testNetworkMainnet
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "state.json" {
+		t.Errorf("output dir holds %v, want only state.json", entries)
+	}
+	info, err := os.Stat(filepath.Join(dir, "state.json"))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("state.json: %v, mode %v", err, info)
+	}
+}
+
+func TestSave_History(t *testing.T) {
+	dir := t.TempDir()
+	state := NewState(dir)
+	state.StateHistory = 2
+	history := filepath.Join(dir, StateHistoryDir)
+
+	for _, network := range []string{"mainnet", "testnet", "testnet", "devnet", "mainnet"} {
+		state.Network = network
+		if err := state.Save(); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(history, "state-*.json"))
+	if len(files) != 2 {
+		t.Fatalf("history = %v, want the 2 newest versions", files)
+	}
+	// Saves: mainnet, testnet, testnet (unchanged), devnet, mainnet. The
+	// archived versions are mainnet, testnet and devnet; the newest two stay.
+	if !strings.Contains(readTestFile(t, files[0]), `"network": "testnet"`) ||
+		!strings.Contains(readTestFile(t, files[1]), `"network": "devnet"`) {
+		t.Errorf("history holds the wrong versions:\n%s\n%s", readTestFile(t, files[0]), readTestFile(t, files[1]))
+	}
+}
+
+func TestSave_NoHistoryByDefault(t *testing.T) {
+	dir := t.TempDir()
+	state := NewState(dir)
+	for range 2 {
+		state.Network += "x"
+		if err := state.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, StateHistoryDir)); !os.IsNotExist(err) {
+		t.Errorf("history dir created without StateHistory: %v", err)
+	}
+}
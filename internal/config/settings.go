@@ -0,0 +1,307 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Setting is a State field that can be read and changed after setup with
+// 'gonka-nop config get/set/unset'. Set validates the value; Unset restores
+// the default the generators fall back to.
+type Setting struct {
+	Key  string // state.json field name
+	Help string
+
+	get   func(s *State) string
+	set   func(s *State, value string) error
+	unset func(s *State)
+	// needed, when set, explains why the value cannot be unset right now.
+	needed func(s *State) error
+}
+
+// Get returns the current value, "" when unset.
+func (st Setting) Get(s *State) string {
+	return st.get(s)
+}
+
+// Set validates value and stores it in s.
+func (st Setting) Set(s *State, value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fmt.Errorf("%s: empty value (use unset to restore the default)", st.Key)
+	}
+	return st.set(s, value)
+}
+
+// Unset restores the default value.
+func (st Setting) Unset(s *State) error {
+	if st.needed != nil {
+		if err := st.needed(s); err != nil {
+			return err
+		}
+	}
+	st.unset(s)
+	return nil
+}
+
+// Limits accepted by config set.
+const (
+	MinGPUMemoryUtil = 0.5
+	MaxGPUMemoryUtil = 0.99
+	minMaxModelLen   = 1024
+	maxMaxModelLen   = 1 << 20
+	maxParallelSize  = 16
+	maxStateHistory  = 1000
+)
+
+// KV cache dtypes accepted by config set.
+const (
+	KVCacheDtypeAuto = "auto"
+	KVCacheDtypeFP8  = "fp8"
+)
+
+var settings = []Setting{
+	stringSetting("public_ip", "IP address or hostname advertised in PUBLIC_URL",
+		func(s *State) *string { return &s.PublicIP }, checkHost),
+	portSetting("p2p_port", "External P2P port advertised to peers", func(s *State) *int { return &s.P2PPort }, 5000),
+	portSetting("api_port", "External public API port", func(s *State) *int { return &s.APIPort }, 8000),
+	portSetting("internal_p2p_port", "Host port Docker binds for P2P (behind NAT)", func(s *State) *int { return &s.InternalP2PPort }, 5000),
+	portSetting("internal_api_port", "Host port Docker binds for the API (behind NAT)", func(s *State) *int { return &s.InternalAPIPort }, 8000),
+	portSetting("inference_port", "Host port of the ML node inference proxy", func(s *State) *int { return &s.InferencePort }, 5050),
+	portSetting("poc_port", "Host port of the ML node PoC proxy", func(s *State) *int { return &s.PoCPort }, 8080),
+	stringSetting("hf_home", "HuggingFace cache directory on the host",
+		func(s *State) *string { return &s.HFHome }, checkAbsPath),
+	stringSetting("selected_model", "Model served by the ML node",
+		func(s *State) *string { return &s.SelectedModel }, checkNoSpace),
+	intSetting("max_model_len", "vLLM --max-model-len", func(s *State) *int { return &s.MaxModelLen }, 0, minMaxModelLen, maxMaxModelLen),
+	{
+		Key:  "gpu_memory_util",
+		Help: fmt.Sprintf("vLLM --gpu-memory-utilization (%.2f-%.2f)", MinGPUMemoryUtil, MaxGPUMemoryUtil),
+		get: func(s *State) string {
+			if s.GPUMemoryUtil == 0 {
+				return ""
+			}
+			return strconv.FormatFloat(s.GPUMemoryUtil, 'f', -1, 64)
+		},
+		set: func(s *State, value string) error {
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("gpu_memory_util: %q is not a number", value)
+			}
+			if f < MinGPUMemoryUtil || f > MaxGPUMemoryUtil {
+				return fmt.Errorf("gpu_memory_util: %v is out of range (%.2f-%.2f)", f, MinGPUMemoryUtil, MaxGPUMemoryUtil)
+			}
+			s.GPUMemoryUtil = f
+			return nil
+		},
+		unset: func(s *State) { s.GPUMemoryUtil = 0 },
+	},
+	intSetting("tp_size", "vLLM --tensor-parallel-size", func(s *State) *int { return &s.TPSize }, 0, 1, maxParallelSize),
+	intSetting("pp_size", "vLLM --pipeline-parallel-size", func(s *State) *int { return &s.PPSize }, 0, 1, maxParallelSize),
+	stringSetting("attention_backend", "vLLM attention backend ("+AttentionFlashInfer+" or "+AttentionFlashAttn+")",
+		func(s *State) *string { return &s.AttentionBackend }, oneOf(AttentionFlashInfer, AttentionFlashAttn)),
+	stringSetting("kv_cache_dtype", "vLLM KV cache dtype (auto or fp8)",
+		func(s *State) *string { return &s.KVCacheDtype }, oneOf(KVCacheDtypeAuto, KVCacheDtypeFP8)),
+	stringSetting("mlnode_image_tag", "ML node image tag (e.g. 3.0.12-blackwell)",
+		func(s *State) *string { return &s.Versions.MLNode }, checkNoSpace),
+	stringSetting("custom_mlnode_image", "Full ML node image, overrides mlnode_image_tag",
+		func(s *State) *string { return &s.CustomMLNodeImage }, checkNoSpace),
+	{
+		Key:   "mlnode_id",
+		Help:  "ML node ID registered with the network node",
+		get:   func(s *State) string { return s.MLNodeID },
+		set:   func(s *State, value string) error { return setKeyName(&s.MLNodeID, "mlnode_id", value) },
+		unset: func(s *State) { s.MLNodeID = "node1" },
+	},
+	stringSetting("network_node_ip", "Private IP of the network node (ML node topology)",
+		func(s *State) *string { return &s.NetworkNodeIP }, checkIPAddr),
+	stringSetting("network_node_url", "Admin API URL of the network node (ML node topology)",
+		func(s *State) *string { return &s.NetworkNodeURL }, checkURL("http", "https")),
+	{
+		Key:  "persistent_peers",
+		Help: "Comma-separated id@host:port peers (unset = mainnet defaults)",
+		get:  func(s *State) string { return strings.Join(s.PersistentPeers, ",") },
+		set: func(s *State, value string) error {
+			var peers []string
+			for _, p := range strings.Split(value, ",") {
+				p = strings.TrimSpace(p)
+				id, addr, ok := strings.Cut(p, "@")
+				if _, _, err := net.SplitHostPort(addr); !ok || id == "" || err != nil {
+					return fmt.Errorf("persistent_peers: %q is not id@host:port", p)
+				}
+				peers = append(peers, p)
+			}
+			s.PersistentPeers = peers
+			return nil
+		},
+		unset: func(s *State) { s.PersistentPeers = nil },
+	},
+	{
+		Key:  "tls_domain",
+		Help: "Serve the public API over HTTPS for this domain (full and network nodes)",
+		get:  func(s *State) string { return s.TLSDomain },
+		set: func(s *State, value string) error {
+			if s.IsMLNodeOnly() {
+				return fmt.Errorf("tls_domain: only used when node_type is full or network")
+			}
+			if s.ACMEEmail == "" {
+				return fmt.Errorf("tls_domain: set acme_email first (the ACME account contact)")
+			}
+			value = strings.ToLower(value)
+			if net.ParseIP(value) != nil || !strings.Contains(value, ".") || !hostnamePattern.MatchString(value) {
+				return fmt.Errorf("tls_domain: %q is not a domain name", value)
+			}
+			s.TLSDomain = value
+			return nil
+		},
+		unset: func(s *State) { s.TLSDomain = "" },
+	},
+	withNeeded(stringSetting("acme_email", "ACME account contact for the TLS certificate",
+		func(s *State) *string { return &s.ACMEEmail }, checkEmail),
+		func(s *State) error {
+			if s.TLSDomain != "" {
+				return fmt.Errorf("acme_email: required while tls_domain is set (unset tls_domain first)")
+			}
+			return nil
+		}),
+	intSetting("state_history", "Previous state.json versions kept in "+StateHistoryDir+" (unset = none)",
+		func(s *State) *int { return &s.StateHistory }, 0, 1, maxStateHistory),
+	stringSetting("acme_directory", "ACME directory URL (default: Let's Encrypt production)",
+		func(s *State) *string { return &s.ACMEDirectory }, checkURL("https")),
+}
+
+// Settings returns every editable setting, sorted by key.
+func Settings() []Setting {
+	out := slices.Clone(settings)
+	slices.SortFunc(out, func(a, b Setting) int { return strings.Compare(a.Key, b.Key) })
+	return out
+}
+
+// LookupSetting returns the setting for a state.json field name.
+func LookupSetting(key string) (Setting, error) {
+	for _, st := range settings {
+		if st.Key == key {
+			return st, nil
+		}
+	}
+	return Setting{}, fmt.Errorf("unknown setting %q (run 'gonka-nop config get' for the list)", key)
+}
+
+func withNeeded(st Setting, needed func(s *State) error) Setting {
+	st.needed = needed
+	return st
+}
+
+func stringSetting(key, help string, field func(*State) *string, check func(key, value string) error) Setting {
+	return Setting{
+		Key:  key,
+		Help: help,
+		get:  func(s *State) string { return *field(s) },
+		set: func(s *State, value string) error {
+			if err := check(key, value); err != nil {
+				return err
+			}
+			*field(s) = value
+			return nil
+		},
+		unset: func(s *State) { *field(s) = "" },
+	}
+}
+
+// intSetting accepts integers in [lo, hi]; def is restored by unset and
+// shown as unset when it is 0.
+func intSetting(key, help string, field func(*State) *int, def, lo, hi int) Setting {
+	return Setting{
+		Key:  key,
+		Help: help,
+		get: func(s *State) string {
+			if *field(s) == 0 {
+				return ""
+			}
+			return strconv.Itoa(*field(s))
+		},
+		set: func(s *State, value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s: %q is not an integer", key, value)
+			}
+			if n < lo || n > hi {
+				return fmt.Errorf("%s: %d is out of range (%d-%d)", key, n, lo, hi)
+			}
+			*field(s) = n
+			return nil
+		},
+		unset: func(s *State) { *field(s) = def },
+	}
+}
+
+func portSetting(key, help string, field func(*State) *int, def int) Setting {
+	return intSetting(key, fmt.Sprintf("%s (default %d)", help, def), field, def, 1, 65535)
+}
+
+func setKeyName(field *string, key, value string) error {
+	if !keyNamePattern.MatchString(value) {
+		return fmt.Errorf("%s: %q may only contain letters, digits, '-' and '_' (max 64)", key, value)
+	}
+	*field = value
+	return nil
+}
+
+func oneOf(allowed ...string) func(key, value string) error {
+	return func(key, value string) error {
+		if slices.Contains(allowed, value) {
+			return nil
+		}
+		return fmt.Errorf("%s: %q is not one of %s", key, value, strings.Join(allowed, ", "))
+	}
+}
+
+func checkHost(key, value string) error {
+	if net.ParseIP(value) == nil && !hostnamePattern.MatchString(value) {
+		return fmt.Errorf("%s: %q is not an IP address or hostname", key, value)
+	}
+	return nil
+}
+
+func checkIPAddr(key, value string) error {
+	if net.ParseIP(value) == nil {
+		return fmt.Errorf("%s: %q is not an IP address", key, value)
+	}
+	return nil
+}
+
+func checkAbsPath(key, value string) error {
+	if !filepath.IsAbs(value) {
+		return fmt.Errorf("%s: must be an absolute path, got %q", key, value)
+	}
+	return nil
+}
+
+func checkNoSpace(key, value string) error {
+	if strings.ContainsAny(value, " \t\n") {
+		return fmt.Errorf("%s: must not contain whitespace", key)
+	}
+	return nil
+}
+
+func checkEmail(key, value string) error {
+	if !strings.Contains(value, "@") || strings.ContainsAny(value, " \t\n") {
+		return fmt.Errorf("%s: %q is not an email address", key, value)
+	}
+	return nil
+}
+
+func checkURL(schemes ...string) func(key, value string) error {
+	return func(key, value string) error {
+		u, err := url.Parse(value)
+		if err != nil || !slices.Contains(schemes, u.Scheme) || u.Host == "" {
+			return fmt.Errorf("%s: %q is not an %s URL", key, value, strings.Join(schemes, "/"))
+		}
+		return nil
+	}
+}
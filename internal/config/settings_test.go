@@ -0,0 +1,124 @@
+package config
+
+import "testing"
+
+func TestSettingSet(t *testing.T) {
+	tests := []struct {
+		key     string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"public_ip", "203.0.113.5", "203.0.113.5", false},
+		{"public_ip", "node.example.com", "node.example.com", false},
+		{"public_ip", "not a host", "", true},
+		{"api_port", "8443", "8443", false},
+		{"api_port", "0", "", true},
+		{"api_port", "70000", "", true},
+		{"api_port", "80a", "", true},
+		{"gpu_memory_util", "0.9", "0.9", false},
+		{"gpu_memory_util", "0.99", "0.99", false},
+		{"gpu_memory_util", "0.4", "", true},
+		{"gpu_memory_util", "1", "", true},
+		{"gpu_memory_util", "high", "", true},
+		{"attention_backend", AttentionFlashAttn, AttentionFlashAttn, false},
+		{"attention_backend", "XFORMERS", "", true},
+		{"kv_cache_dtype", "fp8", "fp8", false},
+		{"kv_cache_dtype", "fp16", "", true},
+		{"max_model_len", "32768", "32768", false},
+		{"max_model_len", "100", "", true},
+		{"tp_size", "4", "4", false},
+		{"tp_size", "17", "", true},
+		{"hf_home", "/mnt/hf", "/mnt/hf", false},
+		{"hf_home", "hf", "", true},
+		{"mlnode_id", "gpu-box_2", "gpu-box_2", false},
+		{"mlnode_id", "gpu box", "", true},
+		{"network_node_ip", testNetworkNodeIP, testNetworkNodeIP, false},
+		{"network_node_ip", "network.local", "", true},
+		{"network_node_url", testNetworkNodeURL, testNetworkNodeURL, false},
+		{"network_node_url", "10.0.1.100:9200", "", true},
+		{"persistent_peers", "abc@1.2.3.4:5000, def@peer.example.com:5000", "abc@1.2.3.4:5000,def@peer.example.com:5000", false},
+		{"persistent_peers", "1.2.3.4:5000", "", true},
+		{"acme_directory", "http://acme.example.com/dir", "", true},
+		{"public_ip", "   ", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			st, err := LookupSetting(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := NewState(t.TempDir())
+			err = st.Set(s, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && st.Get(s) != tt.want {
+				t.Errorf("Get() = %q, want %q", st.Get(s), tt.want)
+			}
+		})
+	}
+}
+
+func TestSettingUnset(t *testing.T) {
+	s := NewState(t.TempDir())
+	s.APIPort = 9000
+	s.GPUMemoryUtil = 0.8
+	s.MLNodeID = "node7"
+
+	for key, want := range map[string]string{"api_port": "8000", "gpu_memory_util": "", "mlnode_id": "node1"} {
+		st, err := LookupSetting(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := st.Unset(s); err != nil {
+			t.Fatalf("Unset(%s) error: %v", key, err)
+		}
+		if got := st.Get(s); got != want {
+			t.Errorf("%s after unset = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestSettingTLS(t *testing.T) {
+	domain, _ := LookupSetting("tls_domain")
+	email, _ := LookupSetting("acme_email")
+	s := NewState(t.TempDir())
+
+	if err := domain.Set(s, "node.example.com"); err == nil {
+		t.Error("tls_domain accepted without acme_email")
+	}
+	if err := email.Set(s, "ops@example.com"); err != nil {
+		t.Fatalf("acme_email: %v", err)
+	}
+	if err := domain.Set(s, "Node.Example.com"); err != nil {
+		t.Fatalf("tls_domain: %v", err)
+	}
+	if s.TLSDomain != "node.example.com" {
+		t.Errorf("TLSDomain = %q, want lowercase", s.TLSDomain)
+	}
+	if err := domain.Set(s, "203.0.113.5"); err == nil {
+		t.Error("tls_domain accepted an IP address")
+	}
+	if err := email.Unset(s); err == nil {
+		t.Error("acme_email unset while tls_domain is set")
+	}
+	if err := domain.Unset(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := email.Unset(s); err != nil {
+		t.Errorf("acme_email unset after tls_domain: %v", err)
+	}
+
+	s.NodeType = NodeTypeMLNode
+	s.ACMEEmail = "ops@example.com"
+	if err := domain.Set(s, "node.example.com"); err == nil {
+		t.Error("tls_domain accepted on an ML node")
+	}
+}
+
+func TestLookupSetting_Unknown(t *testing.T) {
+	if _, err := LookupSetting("key_password"); err == nil {
+		t.Error("expected an error for an unknown setting")
+	}
+}
@@ -0,0 +1,335 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/inc4/gonka-nop/internal/secret"
+	"gopkg.in/yaml.v3"
+)
+
+// SetupSpecVersion is the setup spec schema version this build understands.
+const SetupSpecVersion = 1
+
+// Key workflows accepted by the setup spec.
+const (
+	KeyWorkflowQuick  = "quick"
+	KeyWorkflowSecure = "secure"
+)
+
+// Attention backends accepted by the setup spec.
+const (
+	AttentionFlashInfer = "FLASHINFER"
+	AttentionFlashAttn  = "FLASH_ATTN"
+)
+
+var (
+	keyNamePattern  = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+	hostnamePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?)*$`)
+)
+
+// SetupSpec is a declarative answer file for `gonka-nop setup --config`.
+// Every field is optional: unset fields fall back to detection, defaults or
+// prompts exactly as in interactive setup.
+type SetupSpec struct {
+	Version          int              `json:"version" yaml:"version"`
+	Network          string           `json:"network,omitempty" yaml:"network,omitempty"`     // "mainnet" or "testnet"
+	NodeType         string           `json:"node_type,omitempty" yaml:"node_type,omitempty"` // "full", "network" or "mlnode"
+	Keys             SpecKeys         `json:"keys,omitempty" yaml:"keys,omitempty"`
+	PublicIP         string           `json:"public_ip,omitempty" yaml:"public_ip,omitempty"`
+	PrivateIP        string           `json:"private_ip,omitempty" yaml:"private_ip,omitempty"` // network-only: IP ML nodes use for PoC callbacks
+	Ports            *SpecPorts       `json:"ports,omitempty" yaml:"ports,omitempty"`
+	HFHome           string           `json:"hf_home,omitempty" yaml:"hf_home,omitempty"`
+	Model            *SpecModel       `json:"model,omitempty" yaml:"model,omitempty"`
+	AttentionBackend string           `json:"attention_backend,omitempty" yaml:"attention_backend,omitempty"`
+	MLNodeImage      string           `json:"mlnode_image,omitempty" yaml:"mlnode_image,omitempty"`
+	NetworkNode      *SpecNetworkNode `json:"network_node,omitempty" yaml:"network_node,omitempty"` // mlnode-only
+	TLS              *SpecTLS         `json:"tls,omitempty" yaml:"tls,omitempty"`                   // network and full nodes
+}
+
+// SpecKeys configures key management.
+type SpecKeys struct {
+	Workflow        string `json:"workflow,omitempty" yaml:"workflow,omitempty"` // "quick" or "secure"
+	Name            string `json:"name,omitempty" yaml:"name,omitempty"`
+	KeyringPassword string `json:"keyring_password,omitempty" yaml:"keyring_password,omitempty"` // used for this run only, never saved
+	// Where gonka-nop reads the password later (saved in state.json).
+	// At most one of keyring_password, keyring_password_file, keyring_password_env.
+	KeyringPasswordFile string `json:"keyring_password_file,omitempty" yaml:"keyring_password_file,omitempty"` // absolute path, mode 0400
+	KeyringPasswordEnv  string `json:"keyring_password_env,omitempty" yaml:"keyring_password_env,omitempty"`   // environment variable name
+	AccountPubKey       string `json:"account_pubkey,omitempty" yaml:"account_pubkey,omitempty"`
+}
+
+// PasswordSource returns the secret source reference for the keyring
+// password file or variable, or "" when neither is set.
+func (k SpecKeys) PasswordSource() string {
+	switch {
+	case k.KeyringPasswordFile != "":
+		return secret.FileRef(k.KeyringPasswordFile)
+	case k.KeyringPasswordEnv != "":
+		return secret.EnvRef(k.KeyringPasswordEnv)
+	}
+	return ""
+}
+
+// SpecPorts configures external (advertised) and internal (Docker binding)
+// ports. Unset internal ports default to 5000 (P2P) and 8000 (API).
+type SpecPorts struct {
+	P2P         int `json:"p2p,omitempty" yaml:"p2p,omitempty"`
+	API         int `json:"api,omitempty" yaml:"api,omitempty"`
+	InternalP2P int `json:"internal_p2p,omitempty" yaml:"internal_p2p,omitempty"`
+	InternalAPI int `json:"internal_api,omitempty" yaml:"internal_api,omitempty"`
+}
+
+// SpecModel overrides the model and parallelism recommended from the GPUs.
+// When Name differs from the recommendation, memory utilization and max model
+// length default to the model's own values unless set here.
+type SpecModel struct {
+	Name          string  `json:"name,omitempty" yaml:"name,omitempty"`
+	TP            int     `json:"tp,omitempty" yaml:"tp,omitempty"`
+	PP            int     `json:"pp,omitempty" yaml:"pp,omitempty"`
+	MaxModelLen   int     `json:"max_model_len,omitempty" yaml:"max_model_len,omitempty"`
+	GPUMemoryUtil float64 `json:"gpu_memory_util,omitempty" yaml:"gpu_memory_util,omitempty"`
+}
+
+// SpecNetworkNode points an ML-node-only host at its network node.
+type SpecNetworkNode struct {
+	URL string `json:"url,omitempty" yaml:"url,omitempty"` // Admin API, e.g. http://10.0.1.100:9200
+	IP  string `json:"ip,omitempty" yaml:"ip,omitempty"`   // private IP for PoC callbacks
+}
+
+// SpecTLS serves the public API over HTTPS with an ACME certificate.
+type SpecTLS struct {
+	Domain        string `json:"domain,omitempty" yaml:"domain,omitempty"`                 // must resolve to this server
+	Email         string `json:"email,omitempty" yaml:"email,omitempty"`                   // ACME account contact
+	ACMEDirectory string `json:"acme_directory,omitempty" yaml:"acme_directory,omitempty"` // default: Let's Encrypt production
+}
+
+// FieldError is a validation error for one spec field.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// SpecErrors collects every field error found in a spec.
+type SpecErrors []FieldError
+
+func (e SpecErrors) Error() string {
+	lines := make([]string, 0, len(e)+1)
+	lines = append(lines, fmt.Sprintf("invalid setup spec (%d error(s)):", len(e)))
+	for _, fe := range e {
+		lines = append(lines, fmt.Sprintf("  %s: %s", fe.Field, fe.Message))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LoadSetupSpec reads and validates a spec. Files ending in .json are parsed
+// as JSON, everything else as YAML. Unknown fields are rejected so typos do
+// not silently fall back to defaults.
+func LoadSetupSpec(path string) (*SetupSpec, error) {
+	data, err := os.ReadFile(filepath.Clean(path)) // #nosec G304 - path from CLI flag
+	if err != nil {
+		return nil, fmt.Errorf("read setup spec: %w", err)
+	}
+	spec, err := ParseSetupSpec(data, strings.EqualFold(filepath.Ext(path), ".json"))
+	if err != nil {
+		return nil, err
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// ParseSetupSpec decodes a spec without validating it.
+func ParseSetupSpec(data []byte, isJSON bool) (*SetupSpec, error) {
+	var spec SetupSpec
+	if isJSON {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&spec); err != nil {
+			return nil, fmt.Errorf("parse setup spec: %w", err)
+		}
+		return &spec, nil
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("parse setup spec: %w", err)
+	}
+	return &spec, nil
+}
+
+// Validate checks every field and returns SpecErrors listing all problems.
+func (s *SetupSpec) Validate() error {
+	var errs SpecErrors
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case s.Version == 0:
+		add("version", "required (current version is %d)", SetupSpecVersion)
+	case s.Version != SetupSpecVersion:
+		add("version", "unsupported version %d (this gonka-nop supports %d)", s.Version, SetupSpecVersion)
+	}
+	checkOneOf(add, "network", s.Network, "mainnet", "testnet")
+	checkOneOf(add, "node_type", s.NodeType, NodeTypeFull, NodeTypeNetwork, NodeTypeMLNode)
+
+	s.validateKeys(add)
+
+	if s.PublicIP != "" && net.ParseIP(s.PublicIP) == nil && !hostnamePattern.MatchString(s.PublicIP) {
+		add("public_ip", "%q is not an IP address or hostname", s.PublicIP)
+	}
+	checkIP(add, "private_ip", s.PrivateIP)
+	if s.PrivateIP != "" && s.NodeType != "" && s.NodeType != NodeTypeNetwork {
+		add("private_ip", "only used when node_type is network")
+	}
+
+	if s.Ports != nil {
+		checkPort(add, "ports.p2p", s.Ports.P2P)
+		checkPort(add, "ports.api", s.Ports.API)
+		checkPort(add, "ports.internal_p2p", s.Ports.InternalP2P)
+		checkPort(add, "ports.internal_api", s.Ports.InternalAPI)
+	}
+	if s.HFHome != "" && !filepath.IsAbs(s.HFHome) {
+		add("hf_home", "must be an absolute path, got %q", s.HFHome)
+	}
+
+	if s.Model != nil {
+		if s.Model.TP < 0 {
+			add("model.tp", "must be positive, got %d", s.Model.TP)
+		}
+		if s.Model.PP < 0 {
+			add("model.pp", "must be positive, got %d", s.Model.PP)
+		}
+		if s.Model.MaxModelLen < 0 {
+			add("model.max_model_len", "must be positive, got %d", s.Model.MaxModelLen)
+		}
+		if u := s.Model.GPUMemoryUtil; u != 0 && (u < MinGPUMemoryUtil || u > MaxGPUMemoryUtil) {
+			add("model.gpu_memory_util", "must be between %.2f and %.2f, got %g", MinGPUMemoryUtil, MaxGPUMemoryUtil, u)
+		}
+	}
+	checkOneOf(add, "attention_backend", s.AttentionBackend, AttentionFlashInfer, AttentionFlashAttn)
+	if strings.ContainsAny(s.MLNodeImage, " \t\n") {
+		add("mlnode_image", "must not contain whitespace")
+	}
+
+	s.validateNetworkNode(add)
+	s.validateTLS(add)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+type addFieldError func(field, format string, args ...interface{})
+
+func (s *SetupSpec) validateKeys(add addFieldError) {
+	k := s.Keys
+	checkOneOf(add, "keys.workflow", k.Workflow, KeyWorkflowQuick, KeyWorkflowSecure)
+	if k.Name != "" && !keyNamePattern.MatchString(k.Name) {
+		add("keys.name", "%q may only contain letters, digits, '-' and '_' (max 64)", k.Name)
+	}
+	if k.KeyringPassword != "" && len(k.KeyringPassword) < 8 {
+		add("keys.keyring_password", "must be at least 8 characters")
+	}
+	set := 0
+	for _, v := range []string{k.KeyringPassword, k.KeyringPasswordFile, k.KeyringPasswordEnv} {
+		if v != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		add("keys", "set only one of keyring_password, keyring_password_file, keyring_password_env")
+	}
+	if src := k.PasswordSource(); src != "" {
+		if _, err := secret.Parse(src, ""); err != nil {
+			field := "keys.keyring_password_file"
+			if k.KeyringPasswordFile == "" {
+				field = "keys.keyring_password_env"
+			}
+			add(field, "%v", err)
+		}
+	}
+	if k.AccountPubKey != "" && k.Workflow == KeyWorkflowQuick {
+		add("keys.account_pubkey", "only used with the secure workflow (quick generates the account key)")
+	}
+}
+
+func (s *SetupSpec) validateNetworkNode(add addFieldError) {
+	nn := s.NetworkNode
+	if nn == nil {
+		return
+	}
+	if s.NodeType != "" && s.NodeType != NodeTypeMLNode {
+		add("network_node", "only used when node_type is mlnode")
+	}
+	if nn.URL != "" {
+		u, err := url.Parse(nn.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("network_node.url", "%q is not an http(s) URL", nn.URL)
+		}
+	}
+	checkIP(add, "network_node.ip", nn.IP)
+}
+
+func (s *SetupSpec) validateTLS(add addFieldError) {
+	t := s.TLS
+	if t == nil {
+		return
+	}
+	if s.NodeType == NodeTypeMLNode {
+		add("tls", "only used when node_type is full or network")
+	}
+	switch {
+	case t.Domain == "":
+		add("tls.domain", "required")
+	case net.ParseIP(t.Domain) != nil || !strings.Contains(t.Domain, ".") || !hostnamePattern.MatchString(t.Domain):
+		add("tls.domain", "%q is not a domain name", t.Domain)
+	}
+	switch {
+	case t.Email == "":
+		add("tls.email", "required")
+	case !strings.Contains(t.Email, "@") || strings.ContainsAny(t.Email, " \t\n"):
+		add("tls.email", "%q is not an email address", t.Email)
+	}
+	if t.ACMEDirectory != "" {
+		u, err := url.Parse(t.ACMEDirectory)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			add("tls.acme_directory", "%q is not an https URL", t.ACMEDirectory)
+		}
+	}
+}
+
+func checkOneOf(add addFieldError, field, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	add(field, "%q is not one of %s", value, strings.Join(allowed, ", "))
+}
+
+func checkIP(add addFieldError, field, value string) {
+	if value != "" && net.ParseIP(value) == nil {
+		add(field, "%q is not an IP address", value)
+	}
+}
+
+func checkPort(add addFieldError, field string, port int) {
+	if port != 0 && (port < 1 || port > 65535) {
+		add(field, "%d is not a valid port (1-65535)", port)
+	}
+}
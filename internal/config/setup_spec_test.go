@@ -0,0 +1,211 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSpecYAML = `version: 1
+network: mainnet
+node_type: full
+keys:
+  workflow: quick
+  name: gonka-node
+  keyring_password: change-me-please
+public_ip: 203.0.113.10
+ports:
+  p2p: 19245
+  api: 19246
+hf_home: /mnt/shared/huggingface
+model:
+  name: Qwen/QwQ-32B
+  tp: 2
+attention_backend: FLASH_ATTN
+`
+
+func writeSpec(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSetupSpec_YAML(t *testing.T) {
+	spec, err := LoadSetupSpec(writeSpec(t, "node.yaml", testSpecYAML))
+	if err != nil {
+		t.Fatalf("LoadSetupSpec() error: %v", err)
+	}
+	if spec.Network != testNetworkMainnet || spec.Keys.Workflow != testKeyWorkflowQuick {
+		t.Errorf("network/workflow = %q/%q", spec.Network, spec.Keys.Workflow)
+	}
+	if spec.Ports == nil || spec.Ports.P2P != 19245 || spec.Ports.InternalP2P != 0 {
+		t.Errorf("ports = %+v", spec.Ports)
+	}
+	if spec.Model == nil || spec.Model.TP != 2 || spec.Model.PP != 0 {
+		t.Errorf("model = %+v", spec.Model)
+	}
+}
+
+func TestLoadSetupSpec_JSON(t *testing.T) {
+	content := `{"version": 1, "node_type": "mlnode", "network_node": {"url": "` + testNetworkNodeURL + `", "ip": "` + testNetworkNodeIP + `"}}`
+	spec, err := LoadSetupSpec(writeSpec(t, "node.json", content))
+	if err != nil {
+		t.Fatalf("LoadSetupSpec() error: %v", err)
+	}
+	if spec.NetworkNode == nil || spec.NetworkNode.URL != testNetworkNodeURL {
+		t.Errorf("network_node = %+v", spec.NetworkNode)
+	}
+}
+
+func TestLoadSetupSpec_UnknownField(t *testing.T) {
+	for name, content := range map[string]string{
+		"node.yaml": "version: 1\nnetwrok: mainnet\n",
+		"node.json": `{"version": 1, "netwrok": "mainnet"}`,
+	} {
+		if _, err := LoadSetupSpec(writeSpec(t, name, content)); err == nil || !strings.Contains(err.Error(), "netwrok") {
+			t.Errorf("%s: expected unknown field error, got %v", name, err)
+		}
+	}
+}
+
+func TestSetupSpecValidate_FieldErrors(t *testing.T) {
+	spec := &SetupSpec{
+		Version:  2,
+		Network:  "devnet",
+		NodeType: NodeTypeNetwork,
+		Keys: SpecKeys{
+			Workflow:        KeyWorkflowQuick,
+			Name:            "bad name",
+			KeyringPassword: "short",
+			AccountPubKey:   "Apub",
+		},
+		PublicIP:         "not a host!",
+		PrivateIP:        "10.0.1",
+		Ports:            &SpecPorts{P2P: 70000},
+		HFHome:           "relative/hf",
+		Model:            &SpecModel{TP: -1, GPUMemoryUtil: 1.5},
+		AttentionBackend: "flashinfer",
+		NetworkNode:      &SpecNetworkNode{URL: "10.0.1.100:9200"},
+	}
+
+	err := spec.Validate()
+	var specErrs SpecErrors
+	if !errors.As(err, &specErrs) {
+		t.Fatalf("expected SpecErrors, got %v", err)
+	}
+
+	got := make(map[string]bool)
+	for _, fe := range specErrs {
+		got[fe.Field] = true
+	}
+	for _, field := range []string{
+		"version", "network", "keys.name", "keys.keyring_password", "keys.account_pubkey",
+		"public_ip", "private_ip", "ports.p2p", "hf_home", "model.tp", "model.gpu_memory_util", "attention_backend",
+		"network_node", "network_node.url",
+	} {
+		if !got[field] {
+			t.Errorf("missing error for %s\n%v", field, err)
+		}
+	}
+}
+
+func TestSetupSpecValidate_KeyringSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    SpecKeys
+		wantErr string
+	}{
+		{name: "file", keys: SpecKeys{KeyringPasswordFile: "/etc/gonka/keyring-password"}},
+		{name: "env", keys: SpecKeys{KeyringPasswordEnv: "GONKA_KEYRING_PASSWORD"}},
+		{name: "relative file", keys: SpecKeys{KeyringPasswordFile: "keyring-password"}, wantErr: "keys.keyring_password_file"},
+		{name: "bad env name", keys: SpecKeys{KeyringPasswordEnv: "GONKA-PASS"}, wantErr: "keys.keyring_password_env"},
+		{
+			name:    "two sources",
+			keys:    SpecKeys{KeyringPassword: "long-enough", KeyringPasswordEnv: "GONKA_KEYRING_PASSWORD"},
+			wantErr: "set only one",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&SetupSpec{Version: SetupSpecVersion, Keys: tt.keys}).Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSetupSpecValidate_TLS(t *testing.T) {
+	valid := SpecTLS{Domain: "node.example.com", Email: "ops@example.com"}
+	tests := []struct {
+		name     string
+		nodeType string
+		tls      SpecTLS
+		wantErr  string
+	}{
+		{name: "valid", tls: valid},
+		{name: "local ACME directory", tls: SpecTLS{Domain: valid.Domain, Email: valid.Email, ACMEDirectory: "https://localhost:14000/dir"}},
+		{name: "ML node", nodeType: NodeTypeMLNode, tls: valid, wantErr: "tls: only used"},
+		{name: "IP address", tls: SpecTLS{Domain: "203.0.113.10", Email: valid.Email}, wantErr: "tls.domain"},
+		{name: "missing email", tls: SpecTLS{Domain: valid.Domain}, wantErr: "tls.email: required"},
+		{name: "plain http directory", tls: SpecTLS{Domain: valid.Domain, Email: valid.Email, ACMEDirectory: "http://localhost:14000/dir"}, wantErr: "tls.acme_directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tls := tt.tls
+			err := (&SetupSpec{Version: SetupSpecVersion, NodeType: tt.nodeType, TLS: &tls}).Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSetupSpecValidate_Minimal(t *testing.T) {
+	if err := (&SetupSpec{Version: SetupSpecVersion}).Validate(); err != nil {
+		t.Errorf("minimal spec should be valid: %v", err)
+	}
+	if err := (&SetupSpec{}).Validate(); err == nil || !strings.Contains(err.Error(), "version: required") {
+		t.Errorf("expected missing version error, got %v", err)
+	}
+}
+
+func TestStateSpec(t *testing.T) {
+	state := NewState(t.TempDir())
+	if state.Spec() == nil || state.Spec().Network != "" {
+		t.Fatal("Spec() should return an empty spec when none is set")
+	}
+	state.SetSpec(&SetupSpec{Version: 1, Network: "testnet"})
+	if state.Spec().Network != "testnet" {
+		t.Errorf("Spec().Network = %q", state.Spec().Network)
+	}
+
+	// The spec is never persisted (it may hold the keyring password)
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(state.OutputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Spec().Network != "" {
+		t.Error("spec should not survive Save/Load")
+	}
+}
@@ -2,8 +2,10 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 )
 
 // GPUInfo holds information about a detected GPU
@@ -46,8 +48,19 @@ const (
 	NodeTypeMLNode  = "mlnode"  // ML node only (GPU inference, connects to remote network node)
 )
 
+// TLS constants. With TLS the proxy publishes fixed host ports: 80 for ACME
+// HTTP-01 challenges and the HTTPS redirect, 443 for the public API.
+const (
+	TLSHTTPPort          = 80
+	TLSHTTPSPort         = 443
+	DefaultACMEDirectory = "https://acme-v02.api.letsencrypt.org/directory"
+)
+
 // State holds the persistent state of the setup process
 type State struct {
+	// SchemaVersion is the layout of state.json; Load migrates older files.
+	SchemaVersion int `json:"schema_version"`
+
 	// Setup progress
 	CurrentPhase    string   `json:"current_phase"`
 	CompletedPhases []string `json:"completed_phases"`
@@ -69,14 +82,19 @@ type State struct {
 	Versions        ImageVersions `json:"versions,omitempty"` // per-service image versions from GitHub
 
 	// Keys
-	KeyWorkflow     string `json:"key_workflow"` // "quick" or "secure"
-	AccountPubKey   string `json:"account_pubkey,omitempty"`
-	KeyName         string `json:"key_name,omitempty"`
-	ColdKeyName     string `json:"cold_key_name,omitempty"`
-	ColdKeyAddress  string `json:"cold_key_address,omitempty"`
-	WarmKeyAddress  string `json:"warm_key_address,omitempty"`
-	KeyringPassword string `json:"keyring_password,omitempty"`
-	KeyringDir      string `json:"keyring_dir,omitempty"`
+	KeyWorkflow    string `json:"key_workflow"` // "quick" or "secure"
+	AccountPubKey  string `json:"account_pubkey,omitempty"`
+	KeyName        string `json:"key_name,omitempty"`
+	ColdKeyName    string `json:"cold_key_name,omitempty"`
+	ColdKeyAddress string `json:"cold_key_address,omitempty"`
+	WarmKeyAddress string `json:"warm_key_address,omitempty"`
+	KeyringDir     string `json:"keyring_dir,omitempty"`
+
+	// KeyringPassword is resolved at use time and never persisted; see
+	// ResolveKeyringPassword. KeyringPasswordSource records where it comes
+	// from: "prompt" (default), "file:/path" or "env:NAME".
+	KeyringPassword       string `json:"-"`
+	KeyringPasswordSource string `json:"keyring_password_source,omitempty"`
 
 	// GPU Configuration
 	GPUs              []GPUInfo   `json:"gpus,omitempty"`
@@ -88,7 +106,6 @@ type State struct {
 	GPUMemoryUtil     float64     `json:"gpu_memory_util,omitempty"`     // 0.88-0.94 recommended
 	MaxModelLen       int         `json:"max_model_len,omitempty"`       // calculated from VRAM
 	KVCacheDtype      string      `json:"kv_cache_dtype,omitempty"`      // "auto" or "fp8"
-	MLNodeImageTag    string      `json:"mlnode_image_tag,omitempty"`    // "3.0.12", "3.0.12-blackwell", etc.
 	AttentionBackend  string      `json:"attention_backend,omitempty"`   // "FLASH_ATTN" or "FLASHINFER"
 	CustomMLNodeImage string      `json:"custom_mlnode_image,omitempty"` // full image override (e.g., "ghcr.io/segovchik/gonka-b300-image:3.0.13-b300-tp1")
 
@@ -119,11 +136,22 @@ type State struct {
 	ConsensusKey   string `json:"consensus_key,omitempty"` // ed25519 base64 from TMKMS
 	NodeRegistered bool   `json:"node_registered,omitempty"`
 	PermGranted    bool   `json:"perm_granted,omitempty"`
-	PublicURL      string `json:"public_url,omitempty"` // full URL: http://IP:port
+	PublicURL      string `json:"public_url,omitempty"` // full URL: http://IP:port, or https://domain with TLS
+
+	// TLS: the public API is served by proxy-ssl over HTTPS with an ACME
+	// certificate for TLSDomain. Empty TLSDomain = plain HTTP proxy.
+	TLSDomain     string `json:"tls_domain,omitempty"`
+	ACMEEmail     string `json:"acme_email,omitempty"`
+	ACMEDirectory string `json:"acme_directory,omitempty"` // default: Let's Encrypt production
+
+	// OfflineRegistration tracks the air-gapped registration flow (secure
+	// workflow) so `gonka-nop register` can resume between its steps.
+	OfflineRegistration *OfflineRegistration `json:"offline_registration,omitempty"`
 
 	// Security
 	FirewallConfigured bool `json:"firewall_configured,omitempty"`
-	DDoSProtection     bool `json:"ddos_protection,omitempty"`
+	DDoSProtection     bool `json:"ddos_protection,omitempty"`      // per-IP connection limits on the public ports
+	InternalPortsBound bool `json:"internal_ports_bound,omitempty"` // 26657/9100/9200 verified bound to loopback
 
 	// Topology
 	NodeType       string `json:"node_type,omitempty"`        // "full", "network", "mlnode" (empty = "full")
@@ -135,13 +163,37 @@ type State struct {
 	DiskFreeGB    int    `json:"disk_free_gb,omitempty"`
 	AutoUpdateOff bool   `json:"auto_update_off,omitempty"` // unattended-upgrades disabled
 
+	// StateHistory is how many previous versions of state.json Save keeps in
+	// backups/state-history/ (0 = none).
+	StateHistory int `json:"state_history,omitempty"`
+
 	// Internal
-	statePath string `json:"-"`
+	statePath string            `json:"-"`
+	spec      *SetupSpec        `json:"-"` // answers for this run; never persisted
+	pending   *pendingMigration `json:"-"` // found by Load, written by Save
+}
+
+// pendingMigration is what Load found to upgrade but did not write: Load
+// never changes files, so read-only commands leave an old output directory
+// as it is. MigrateKeyringPassword and Save apply it.
+type pendingMigration struct {
+	backups         []stateBackup // state.json before each schema migration
+	keyring         bool          // a keyring password is left in the output dir, not yet moved
+	keyringPassword string        // plaintext password dropped from state.json
+}
+
+// OfflineRegistration records the unsigned transactions generated for the
+// cold machine and which of them have been broadcast.
+type OfflineRegistration struct {
+	UnsignedFile string            `json:"unsigned_file"`
+	Fingerprint  string            `json:"fingerprint"`         // identifies the generated batch
+	Broadcast    map[string]string `json:"broadcast,omitempty"` // tx name -> tx hash
 }
 
 // NewState creates a new state with defaults
 func NewState(outputDir string) *State {
 	return &State{
+		SchemaVersion:   CurrentSchemaVersion,
 		OutputDir:       outputDir,
 		CompletedPhases: []string{},
 		P2PPort:         5000,
@@ -170,29 +222,86 @@ func Load(outputDir string) (*State, error) {
 		return nil, err
 	}
 
+	migrated, backups, legacyPassword, err := migrateState(data)
+	if err != nil {
+		return nil, err
+	}
+
 	var state State
-	if err := json.Unmarshal(data, &state); err != nil {
+	if err := json.Unmarshal(migrated, &state); err != nil {
 		return nil, err
 	}
 
 	state.statePath = statePath
 	state.OutputDir = outputDir
+
+	// Older versions stored the keyring password in plaintext, in state.json
+	// (dropped by the migration above) and config.env
+	pending := &pendingMigration{
+		backups:         backups,
+		keyring:         legacyPassword != "" || state.keyringInOutputDir(),
+		keyringPassword: legacyPassword,
+	}
+	if len(pending.backups) > 0 || pending.keyring {
+		state.pending = pending
+	}
 	return &state, nil
 }
 
-// Save persists the state to disk
+// MigrationPending reports whether Load upgraded an older output directory
+// in memory only. Callers holding the output lock (see LockOutputDir) write
+// the upgrade with MigrateKeyringPassword, then Save.
+func (s *State) MigrationPending() bool {
+	return s.pending != nil
+}
+
+// MigrateKeyringPassword moves a plaintext keyring password Load found in
+// the output directory to a file under the user's config directory, and
+// rewrites docker-compose.yml and config.env to match. Save refuses to drop
+// the password from state.json before this ran.
+func (s *State) MigrateKeyringPassword() error {
+	if s.pending == nil || !s.pending.keyring {
+		return nil
+	}
+	if err := s.migrateKeyringPassword(s.pending.keyringPassword); err != nil {
+		return fmt.Errorf("migrate keyring password: %w", err)
+	}
+	s.pending.keyring, s.pending.keyringPassword = false, ""
+	return nil
+}
+
+// Save persists the state to disk. state.json is replaced atomically, so a
+// crash leaves either the old or the new version; with StateHistory set the
+// old version is also kept in the history directory.
 func (s *State) Save() error {
 	// Ensure output directory exists
 	if err := os.MkdirAll(s.OutputDir, 0750); err != nil {
 		return err
 	}
+	if s.pending != nil {
+		if s.pending.keyring {
+			return fmt.Errorf("keyring password in %s is not migrated yet", s.OutputDir)
+		}
+		if err := writeStateBackups(filepath.Join(s.OutputDir, StateBackupDir), s.pending.backups); err != nil {
+			return fmt.Errorf("back up state before migration: %w", err)
+		}
+	}
 
 	data, err := json.MarshalIndent(s, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(s.statePath, data, 0600)
+	if s.StateHistory > 0 {
+		if err := archiveState(s.statePath, data, s.StateHistory); err != nil {
+			return fmt.Errorf("state history: %w", err)
+		}
+	}
+	if err := writeFileAtomic(s.statePath, data, 0600); err != nil {
+		return err
+	}
+	s.pending = nil
+	return nil
 }
 
 // MarkPhaseComplete marks a phase as completed
@@ -201,6 +310,11 @@ func (s *State) MarkPhaseComplete(phaseName string) {
 	s.CurrentPhase = ""
 }
 
+// UnmarkPhaseComplete forgets that a phase was completed, so it runs again
+func (s *State) UnmarkPhaseComplete(phaseName string) {
+	s.CompletedPhases = slices.DeleteFunc(s.CompletedPhases, func(p string) bool { return p == phaseName })
+}
+
 // IsPhaseComplete checks if a phase has been completed
 func (s *State) IsPhaseComplete(phaseName string) bool {
 	for _, p := range s.CompletedPhases {
@@ -211,65 +325,33 @@ func (s *State) IsPhaseComplete(phaseName string) bool {
 	return false
 }
 
-// Reset clears all state
+// Reset clears all state back to NewState defaults, keeping the output
+// directory, the StateHistory setting and the setup answers for this run.
 func (s *State) Reset() {
-	s.CurrentPhase = ""
-	s.CompletedPhases = []string{}
-	s.Network = ""
-	s.ChainID = ""
-	s.IsTestNet = false
-	s.ImageVersion = ""
-	s.SeedAPIURL = ""
-	s.SeedRPCURL = ""
-	s.SeedP2PURL = ""
-	s.EnforcedModelID = ""
-	s.EthereumNetwork = ""
-	s.BeaconStateURL = ""
-	s.BridgeImageTag = ""
-	s.Versions = ImageVersions{}
-	s.KeyWorkflow = ""
-	s.AccountPubKey = ""
-	s.KeyName = ""
-	s.ColdKeyName = ""
-	s.ColdKeyAddress = ""
-	s.WarmKeyAddress = ""
-	s.KeyringPassword = ""
-	s.KeyringDir = ""
-	s.GPUs = nil
-	s.GPUTopology = GPUTopology{}
-	s.DriverInfo = DriverInfo{}
-	s.SelectedModel = ""
-	s.TPSize = 0
-	s.PPSize = 0
-	s.GPUMemoryUtil = 0
-	s.MaxModelLen = 0
-	s.KVCacheDtype = ""
-	s.MLNodeImageTag = ""
-	s.AttentionBackend = ""
-	s.HFHome = ""
-	s.PublicIP = ""
-	s.InternalP2PPort = 0
-	s.InternalAPIPort = 0
-	s.PersistentPeers = nil
-	s.InferencePort = 0
-	s.PoCPort = 0
-	s.MLNodeID = ""
-	s.UseSudo = false
-	s.ComposeFiles = nil
-	s.AdminURL = ""
-	s.RPCURL = ""
-	s.ConsensusKey = ""
-	s.NodeRegistered = false
-	s.PermGranted = false
-	s.PublicURL = ""
-	s.FirewallConfigured = false
-	s.DDoSProtection = false
-	s.Distro = Distro{}
-	s.DiskFreeGB = 0
-	s.AutoUpdateOff = false
-	s.NodeType = ""
-	s.NetworkNodeURL = ""
-	s.NetworkNodeIP = ""
+	fresh := NewState(s.OutputDir)
+	fresh.statePath = s.statePath
+	fresh.spec = s.spec
+	fresh.StateHistory = s.StateHistory
+	*s = *fresh
+}
+
+// SetSpec attaches setup answers for the current run. Phases consult them
+// before prompting.
+func (s *State) SetSpec(spec *SetupSpec) {
+	s.spec = spec
+}
+
+// Spec returns the setup answers for the current run, or an empty spec.
+func (s *State) Spec() *SetupSpec {
+	if s.spec == nil {
+		return &SetupSpec{}
+	}
+	return s.spec
+}
+
+// TLSEnabled reports whether the public API is served over HTTPS.
+func (s *State) TLSEnabled() bool {
+	return s.TLSDomain != ""
 }
 
 // EffectiveNodeType returns the node topology type, defaulting to "full"
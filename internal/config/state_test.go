@@ -330,7 +330,7 @@ func TestSaveAndLoadPreservesGPUFields(t *testing.T) {
 	state.GPUMemoryUtil = 0.90
 	state.MaxModelLen = 240000
 	state.KVCacheDtype = "fp8"
-	state.MLNodeImageTag = "3.0.12"
+	state.Versions.MLNode = "3.0.12"
 	state.AttentionBackend = "FLASH_ATTN"
 
 	if err := state.Save(); err != nil {
@@ -24,7 +24,7 @@ type ImageVersions struct {
 	Explorer string `json:"explorer"`  // explorer image tag
 
 	// From docker-compose.mlnode.yml
-	MLNode string `json:"mlnode"` // mlnode image tag (e.g. "3.0.12-post2")
+	MLNode string `json:"mlnode"` // mlnode image tag (e.g. "3.0.12-post2", "3.0.12-blackwell")
 	Nginx  string `json:"nginx"`  // nginx image tag
 
 	// Metadata
@@ -5,8 +5,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/inc4/gonka-nop/internal/config"
@@ -38,6 +40,10 @@ type ComposeClient struct {
 	UseSudo bool     // prefix commands with sudo -E
 	Stdout  io.Writer
 	Stderr  io.Writer
+	// Secrets, if set, returns NAME=value pairs Up passes to the api
+	// container through the compose process environment, so they are never
+	// written to disk.
+	Secrets func() ([]string, error)
 }
 
 // NewComposeClient creates a client from state.
@@ -53,12 +59,22 @@ func NewComposeClient(state *config.State) (*ComposeClient, error) {
 
 	envFile := state.OutputDir + "/config.env"
 
-	return &ComposeClient{
+	cc := &ComposeClient{
 		WorkDir: state.OutputDir,
 		Files:   files,
 		EnvFile: envFile,
 		UseSudo: state.UseSudo,
-	}, nil
+	}
+	if state.KeyringPassthrough() {
+		cc.Secrets = func() ([]string, error) {
+			password, err := state.ResolveKeyringPassword()
+			if err != nil {
+				return nil, err
+			}
+			return []string{config.KeyringPasswordVar + "=" + password}, nil
+		}
+	}
+	return cc, nil
 }
 
 // baseArgs returns ["-f", "file1", "-f", "file2"].
@@ -72,6 +88,12 @@ func (c *ComposeClient) baseArgs() []string {
 
 // run executes a docker compose command.
 func (c *ComposeClient) run(ctx context.Context, args ...string) error {
+	return c.runEnv(ctx, nil, args...)
+}
+
+// runEnv executes a docker compose command with extra NAME=value pairs in
+// its environment.
+func (c *ComposeClient) runEnv(ctx context.Context, extraEnv []string, args ...string) error {
 	cmdArgs := append([]string{"compose"}, c.baseArgs()...)
 	cmdArgs = append(cmdArgs, args...)
 
@@ -90,6 +112,12 @@ func (c *ComposeClient) run(ctx context.Context, args ...string) error {
 	if err == nil && len(fileEnv) > 0 {
 		cmd.Env = MergeEnv(fileEnv)
 	}
+	if len(extraEnv) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, extraEnv...)
+	}
 
 	var stderr bytes.Buffer
 	if c.Stdout != nil {
@@ -121,7 +149,15 @@ func (c *ComposeClient) Up(ctx context.Context, services ...string) error {
 	args := make([]string, 0, 2+len(services))
 	args = append(args, "up", "-d")
 	args = append(args, services...)
-	return c.run(ctx, args...)
+
+	var env []string
+	if c.Secrets != nil && (len(services) == 0 || slices.Contains(services, "api")) {
+		var err error
+		if env, err = c.Secrets(); err != nil {
+			return fmt.Errorf("docker compose up: %w", err)
+		}
+	}
+	return c.runEnv(ctx, env, args...)
 }
 
 // Down stops services.
@@ -190,6 +226,85 @@ func (c *ComposeClient) Run(ctx context.Context, service string, command ...stri
 	return c.run(ctx, args...)
 }
 
+// Stop stops services without removing them.
+func (c *ComposeClient) Stop(ctx context.Context, services ...string) error {
+	args := make([]string, 0, 1+len(services))
+	args = append(args, "stop")
+	args = append(args, services...)
+
+	var env []string
+	if c.Secrets != nil && (len(services) == 0 || slices.Contains(services, "api")) {
+		var err error
+		if env, err = c.Secrets(); err != nil {
+			return fmt.Errorf("docker compose up: %w", err)
+		}
+	}
+	return c.runEnv(ctx, env, args...)
+}
+
+// ExecOptions describes a command in a service container. Cmd is passed as
+// an argv array — never through sh -c — so arguments need no quoting.
+type ExecOptions struct {
+	Service string
+	Cmd     []string
+	// Stdin, if set, is streamed to the command (e.g. a keyring password).
+	Stdin io.Reader
+}
+
+// RunCmd runs a one-off container and returns its stdout.
+// Equivalent to: docker compose run --rm --no-deps -T <service> <cmd...>
+func (c *ComposeClient) RunCmd(ctx context.Context, opts ExecOptions) (string, error) {
+	return c.output(ctx, opts, "run", "--rm", "--no-deps", "-T")
+}
+
+// Exec runs a command in a running service container and returns its stdout.
+// Equivalent to: docker compose exec -T <service> <cmd...>
+func (c *ComposeClient) Exec(ctx context.Context, opts ExecOptions) (string, error) {
+	return c.output(ctx, opts, "exec", "-T")
+}
+
+// execArgs builds the compose arguments for RunCmd and Exec.
+func (c *ComposeClient) execArgs(opts ExecOptions, sub ...string) []string {
+	args := make([]string, 0, 1+2*len(c.Files)+len(sub)+1+len(opts.Cmd))
+	args = append(args, "compose")
+	args = append(args, c.baseArgs()...)
+	args = append(args, sub...)
+	args = append(args, opts.Service)
+	return append(args, opts.Cmd...)
+}
+
+func (c *ComposeClient) output(ctx context.Context, opts ExecOptions, sub ...string) (string, error) {
+	if opts.Service == "" || len(opts.Cmd) == 0 {
+		return "", fmt.Errorf("docker compose %s: service and command are required", sub[0])
+	}
+	cmdArgs := c.execArgs(opts, sub...)
+
+	var cmd *exec.Cmd
+	if c.UseSudo {
+		sudoArgs := append([]string{"-E", "docker"}, cmdArgs...)
+		cmd = exec.CommandContext(ctx, "sudo", sudoArgs...) // #nosec G204 - argv, no shell
+	} else {
+		cmd = exec.CommandContext(ctx, "docker", cmdArgs...) // #nosec G204 - argv, no shell
+	}
+	cmd.Dir = c.WorkDir
+	cmd.Stdin = opts.Stdin
+
+	// Load config.env to suppress compose "variable is not set" warnings
+	fileEnv, err := ParseEnvFile(c.EnvFile)
+	if err == nil && len(fileEnv) > 0 {
+		cmd.Env = MergeEnv(fileEnv)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		combined := strings.TrimSpace(stdout.String() + "\n" + stderr.String())
+		return combined, fmt.Errorf("docker compose %s %s: %w\n%s", sub[0], opts.Service, err, combined)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
 // DetectSudo checks if docker needs sudo by running `docker info`.
 func DetectSudo(ctx context.Context) bool {
 	cmd := exec.CommandContext(ctx, "docker", "info")
@@ -1,6 +1,8 @@
 package docker
 
 import (
+	"context"
+	"strings"
 	"testing"
 
 	"github.com/inc4/gonka-nop/internal/config"
@@ -146,3 +148,28 @@ func TestUpgradeHandlerRe(t *testing.T) {
 		})
 	}
 }
+
+func TestExecArgs(t *testing.T) {
+	c := &ComposeClient{Files: []string{"docker-compose.yml"}}
+	opts := ExecOptions{
+		Service: "api",
+		Cmd:     []string{"inferenced", "keys", "list", "--output", "json; rm -rf /"},
+	}
+
+	got := strings.Join(c.execArgs(opts, "run", "--rm", "--no-deps", "-T"), "|")
+	want := "compose|-f|docker-compose.yml|run|--rm|--no-deps|-T|api|" +
+		"inferenced|keys|list|--output|json; rm -rf /"
+	if got != want {
+		t.Errorf("execArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestExecOutput_RequiresServiceAndCmd(t *testing.T) {
+	c := &ComposeClient{Files: []string{"docker-compose.yml"}, WorkDir: t.TempDir()}
+	if _, err := c.Exec(context.Background(), ExecOptions{Service: "node"}); err == nil {
+		t.Error("Exec() without a command should fail")
+	}
+	if _, err := c.RunCmd(context.Background(), ExecOptions{Cmd: []string{"true"}}); err == nil {
+		t.Error("RunCmd() without a service should fail")
+	}
+}
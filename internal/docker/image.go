@@ -0,0 +1,68 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// ImageRunOptions describes a throwaway container started from an image,
+// for work that happens before (or without) a compose project, such as key
+// creation and offline signing.
+type ImageRunOptions struct {
+	Image   string
+	Cmd     []string // argv, never run through a shell
+	Volumes []string // host:container bind mounts
+	// NoNetwork runs the container with --network none.
+	NoNetwork bool
+	// Stdin, if set, is streamed to the command (e.g. a keyring password).
+	Stdin   io.Reader
+	UseSudo bool
+}
+
+// imageRunArgs builds: docker run --rm [-i] [--network none] [-v ...] <image> <cmd...>
+func imageRunArgs(opts ImageRunOptions) []string {
+	args := make([]string, 0, 5+2*len(opts.Volumes)+1+len(opts.Cmd))
+	args = append(args, "run", "--rm")
+	if opts.Stdin != nil {
+		args = append(args, "-i")
+	}
+	if opts.NoNetwork {
+		args = append(args, "--network", "none")
+	}
+	for _, v := range opts.Volumes {
+		args = append(args, "-v", v)
+	}
+	args = append(args, opts.Image)
+	return append(args, opts.Cmd...)
+}
+
+// RunImage runs a command in a throwaway container and returns its stdout
+// and stderr separately; inferenced prints some results (e.g. mnemonics) on
+// stderr.
+func RunImage(ctx context.Context, opts ImageRunOptions) (stdout, stderr string, err error) {
+	if opts.Image == "" || len(opts.Cmd) == 0 {
+		return "", "", fmt.Errorf("docker run: image and command are required")
+	}
+	args := imageRunArgs(opts)
+
+	var cmd *exec.Cmd
+	if opts.UseSudo {
+		cmd = exec.CommandContext(ctx, "sudo", append([]string{"-E", "docker"}, args...)...) // #nosec G204 - argv, no shell
+	} else {
+		cmd = exec.CommandContext(ctx, "docker", args...) // #nosec G204 - argv, no shell
+	}
+	cmd.Stdin = opts.Stdin
+
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	if err := cmd.Run(); err != nil {
+		return outBuf.String(), errBuf.String(), fmt.Errorf("docker run %s: %w\n%s",
+			opts.Cmd[0], err, strings.TrimSpace(errBuf.String()))
+	}
+	return outBuf.String(), errBuf.String(), nil
+}
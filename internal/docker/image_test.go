@@ -0,0 +1,43 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImageRunArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ImageRunOptions
+		want string
+	}{
+		{
+			name: "network and volume",
+			opts: ImageRunOptions{
+				Image:     "inferenced:v1",
+				Cmd:       []string{"inferenced", "keys", "list"},
+				Volumes:   []string{"/home/u/.inference:/root/.inference"},
+				NoNetwork: true,
+			},
+			want: "run|--rm|--network|none|-v|/home/u/.inference:/root/.inference|inferenced:v1|inferenced|keys|list",
+		},
+		{
+			name: "stdin adds -i",
+			opts: ImageRunOptions{
+				Image: "inferenced:v1",
+				Cmd:   []string{"inferenced", "keys", "add", "k"},
+				Stdin: strings.NewReader("pw\n"),
+			},
+			want: "run|--rm|-i|inferenced:v1|inferenced|keys|add|k",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(imageRunArgs(tt.opts), "|")
+			if got != tt.want {
+				t.Errorf("imageRunArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+// Package exporter exposes node status as Prometheus metrics.
+//
+// Metrics are rendered in the Prometheus text exposition format from
+// status.NodeStatus snapshots, so the exporter reports exactly what
+// `gonka-nop status` shows.
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inc4/gonka-nop/internal/status"
+)
+
+const (
+	metricPrefix = "gonka_"
+	contentType  = "text/plain; version=0.0.4; charset=utf-8"
+	bytesPerGB   = 1 << 30
+
+	mlnodeOnlyStatus = "MLNODE-ONLY"
+)
+
+// FetchFunc returns a fresh status snapshot.
+type FetchFunc func() (*status.NodeStatus, error)
+
+// Exporter polls node status in the background and serves the latest
+// snapshot on /metrics. Scrapes never block on slow node endpoints.
+type Exporter struct {
+	fetch    FetchFunc
+	interval time.Duration
+
+	mu       sync.RWMutex
+	last     *status.NodeStatus
+	lastErr  error
+	lastPoll time.Time
+	polls    int64
+	failures int64
+}
+
+// New creates an exporter that calls fetch every interval.
+func New(fetch FetchFunc, interval time.Duration) *Exporter {
+	return &Exporter{fetch: fetch, interval: interval}
+}
+
+// Poll fetches one snapshot and stores it.
+func (e *Exporter) Poll() {
+	s, err := e.fetch()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.polls++
+	e.lastPoll = time.Now()
+	e.lastErr = err
+	if err != nil {
+		e.failures++
+		return
+	}
+	e.last = s
+}
+
+// Run polls until ctx is canceled. The first poll happens immediately.
+func (e *Exporter) Run(ctx context.Context) {
+	e.Poll()
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.Poll()
+		}
+	}
+}
+
+// Handler returns an http.Handler serving /metrics and a simple index page.
+func (e *Exporter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		_ = e.WriteMetrics(w)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `<html><body><h1>gonka-nop exporter</h1><a href="/metrics">/metrics</a></body></html>`)
+	})
+	return mux
+}
+
+// WriteMetrics renders the latest snapshot plus exporter self-metrics.
+func (e *Exporter) WriteMetrics(w io.Writer) error {
+	e.mu.RLock()
+	s, lastErr, lastPoll, polls, failures := e.last, e.lastErr, e.lastPoll, e.polls, e.failures
+	e.mu.RUnlock()
+
+	m := newMetricWriter()
+	up := lastErr == nil && s != nil && nodeReached(s)
+	m.gauge("up", "Whether the last poll reached the node's Admin API or RPC (1) or not (0).", nil, boolValue(up))
+	m.counter("exporter_polls_total", "Total status polls.", nil, float64(polls))
+	m.counter("exporter_poll_failures_total", "Total failed status polls.", nil, float64(failures))
+	if !lastPoll.IsZero() {
+		m.gauge("exporter_last_poll_timestamp_seconds", "Unix time of the last status poll.", nil, float64(lastPoll.Unix()))
+	}
+
+	if s != nil {
+		writeNodeMetrics(m, s)
+	}
+	return m.flush(w)
+}
+
+// nodeReached reports whether a snapshot holds data from the node itself.
+// FetchStatusWithConfig returns an empty snapshot rather than an error when
+// every endpoint is down. ML-node-only hosts have nothing to query.
+func nodeReached(s *status.NodeStatus) bool {
+	if s.Overview.OverallStatus == mlnodeOnlyStatus {
+		return true
+	}
+	return s.SetupReport != nil || s.Blockchain.PeerCountKnown || s.Blockchain.BlockHeight > 0
+}
+
+// writeNodeMetrics converts a status snapshot into metric samples.
+func writeNodeMetrics(m *metricWriter, s *status.NodeStatus) {
+	health := status.EvaluateHealth(s)
+	m.gauge("health_level", "Overall health: 0=ok, 1=warning, 2=critical.", nil, healthValue(health.Level))
+	if s.Overview.ChecksTotal > 0 {
+		m.gauge("setup_checks_passed", "Passed checks in /admin/v1/setup/report.", nil, float64(s.Overview.ChecksPassed))
+		m.gauge("setup_checks_total", "Total checks in /admin/v1/setup/report.", nil, float64(s.Overview.ChecksTotal))
+	}
+	m.gauge("node_registered", "Whether the participant is registered on-chain.", nil, boolValue(s.Overview.NodeRegistered))
+
+	writeBlockchainMetrics(m, s)
+	writeEpochMetrics(m, s)
+	writeMLNodeMetrics(m, s)
+
+	if s.NodeConfig.UpgradeHeight > 0 {
+		m.gauge("upgrade_height", "Height of the pending chain upgrade.", labels{"name": s.NodeConfig.UpgradeName},
+			float64(s.NodeConfig.UpgradeHeight))
+	}
+	m.gauge("api_height_lag", "Blocks the API is behind the chain (current - last processed).", nil,
+		float64(s.NodeConfig.HeightLag))
+}
+
+func writeBlockchainMetrics(m *metricWriter, s *status.NodeStatus) {
+	b := s.Blockchain
+	m.gauge("block_height", "Latest block height of the local node.", nil, float64(b.BlockHeight))
+	if b.NetworkHeight > 0 {
+		m.gauge("network_height", "Highest known block height on the network.", nil, float64(b.NetworkHeight))
+	}
+	m.gauge("block_lag", "Blocks behind the network.", nil, float64(b.BlockLag))
+	m.gauge("synced", "Whether the node is synced.", nil, boolValue(b.Synced))
+	m.gauge("catching_up", "Whether the node is catching up.", nil, boolValue(b.CatchingUp))
+	if b.PeerCountKnown {
+		m.gauge("peers", "Connected P2P peers.", nil, float64(b.PeerCount))
+	}
+	m.gauge("validator_in_set", "Whether the validator is in the active set.", nil, boolValue(b.IsValidator))
+	m.gauge("voting_power", "Validator voting power.", nil, float64(b.VotingPower))
+}
+
+func writeEpochMetrics(m *metricWriter, s *status.NodeStatus) {
+	e := s.Epoch
+	m.gauge("epoch_number", "Current epoch index.", nil, float64(e.EpochNumber))
+	m.gauge("epoch_active", "Whether the participant is active in the current epoch.", nil, boolValue(e.Active))
+	m.gauge("epoch_weight", "Participant weight in the current epoch.", nil, float64(e.Weight))
+	m.gauge("poc_weight", "PoC weight assigned to the ML node.", nil, float64(e.PoCWeight))
+	m.gauge("miss_rate_percent", "Missed inference requests in the current epoch (percent).", nil, e.MissPercentage)
+	m.gauge("missed_requests", "Missed inference requests in the current epoch.", nil, float64(e.MissedCount))
+	m.gauge("total_requests", "Inference requests in the current epoch.", nil, float64(e.TotalCount))
+	m.gauge("inferences_served", "Inferences served in the current epoch.", nil, float64(e.InferenceCount))
+
+	allocated := 0
+	for _, slot := range e.TimeslotAllocation {
+		if slot {
+			allocated++
+		}
+	}
+	m.gauge("timeslots_allocated", "Allocated inference timeslots.", nil, float64(allocated))
+	m.gauge("timeslots_total", "Total inference timeslots.", nil, float64(len(e.TimeslotAllocation)))
+
+	if e.PrevEpochIndex > 0 {
+		m.gauge("prev_epoch_reward_claimed", "Whether the previous epoch reward was claimed.",
+			labels{"epoch": strconv.Itoa(e.PrevEpochIndex)}, boolValue(e.PrevEpochClaimed))
+	}
+}
+
+func writeMLNodeMetrics(m *metricWriter, s *status.NodeStatus) {
+	ml := s.MLNode
+	m.gauge("mlnode_enabled", "Whether the ML node is enabled.", nil, boolValue(ml.Enabled))
+	if ml.ModelName != "" {
+		m.gauge("mlnode_model_loaded", "Whether the configured model is loaded.", labels{"model": ml.ModelName},
+			boolValue(ml.ModelLoaded))
+	}
+	if ml.PoCStatus != "" {
+		m.gauge("mlnode_current_status", "ML node current status (1 for the active status).",
+			labels{"status": ml.PoCStatus}, 1)
+	}
+	if ml.IntendedStatus != "" {
+		m.gauge("mlnode_intended_status", "ML node intended status (1 for the active status).",
+			labels{"status": ml.IntendedStatus}, 1)
+	}
+	if ml.IntendedStatus != "" && ml.PoCStatus != "" {
+		m.gauge("mlnode_status_mismatch", "Whether current and intended ML node status differ.", nil,
+			boolValue(ml.IntendedStatus != ml.PoCStatus))
+	}
+	if !ml.StatusUpdated.IsZero() {
+		m.gauge("mlnode_status_updated_timestamp_seconds", "Unix time of the last ML node status update.", nil,
+			float64(ml.StatusUpdated.Unix()))
+	}
+
+	for i, gpu := range ml.GPUs {
+		l := labels{"gpu": strconv.Itoa(i), "name": gpu.Name}
+		m.gauge("gpu_memory_total_bytes", "GPU memory total.", l, gpu.TotalMemoryGB*bytesPerGB)
+		m.gauge("gpu_memory_used_bytes", "GPU memory used.", l, gpu.UsedMemoryGB*bytesPerGB)
+		m.gauge("gpu_utilization_percent", "GPU utilization.", l, float64(gpu.UtilizationPct))
+		m.gauge("gpu_temperature_celsius", "GPU temperature.", l, float64(gpu.TemperatureC))
+		m.gauge("gpu_available", "Whether the GPU is available to the ML node.", l, boolValue(gpu.Available))
+	}
+}
+
+// --- Text exposition format ---
+
+type labels map[string]string
+
+type sample struct {
+	labels labels
+	value  float64
+}
+
+type family struct {
+	help    string
+	kind    string
+	samples []sample
+}
+
+// metricWriter collects samples grouped by family, preserving first-seen order.
+type metricWriter struct {
+	order    []string
+	families map[string]*family
+}
+
+func newMetricWriter() *metricWriter {
+	return &metricWriter{families: make(map[string]*family)}
+}
+
+func (m *metricWriter) gauge(name, help string, l labels, v float64) {
+	m.add(name, help, "gauge", l, v)
+}
+
+func (m *metricWriter) counter(name, help string, l labels, v float64) {
+	m.add(name, help, "counter", l, v)
+}
+
+func (m *metricWriter) add(name, help, kind string, l labels, v float64) {
+	full := metricPrefix + name
+	f, ok := m.families[full]
+	if !ok {
+		f = &family{help: help, kind: kind}
+		m.families[full] = f
+		m.order = append(m.order, full)
+	}
+	f.samples = append(f.samples, sample{labels: l, value: v})
+}
+
+func (m *metricWriter) flush(w io.Writer) error {
+	var b strings.Builder
+	for _, name := range m.order {
+		f := m.families[name]
+		fmt.Fprintf(&b, "# HELP %s %s\n", name, f.help)
+		fmt.Fprintf(&b, "# TYPE %s %s\n", name, f.kind)
+		for _, s := range f.samples {
+			fmt.Fprintf(&b, "%s%s %s\n", name, formatLabels(s.labels), formatValue(s.value))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// formatValue renders integral values without an exponent so heights and
+// byte counts stay readable.
+func formatValue(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// formatLabels renders {k="v",...} with sorted keys and escaped values.
+func formatLabels(l labels) string {
+	if len(l) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+`="`+escapeLabel(l[k])+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// labelEscaper escapes a label value for the text exposition format, which
+// only knows \\, \" and \n. Everything else, including UTF-8, is literal.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func healthValue(level string) float64 {
+	switch level {
+	case status.HealthWarning:
+		return 1
+	case status.HealthCritical:
+		return 2
+	default:
+		return 0
+	}
+}
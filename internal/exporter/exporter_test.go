@@ -0,0 +1,275 @@
+package exporter
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inc4/gonka-nop/internal/status"
+)
+
+func sampleStatus() *status.NodeStatus {
+	s := &status.NodeStatus{}
+	s.Overview.ContainersRunning = 3
+	s.Overview.ChecksPassed = 9
+	s.Overview.ChecksTotal = 9
+	s.Overview.NodeRegistered = true
+	s.Blockchain.BlockHeight = 1250000
+	s.Blockchain.NetworkHeight = 1250002
+	s.Blockchain.BlockLag = 2
+	s.Blockchain.Synced = true
+	s.Blockchain.PeerCount = 12
+	s.Blockchain.PeerCountKnown = true
+	s.Epoch.EpochNumber = 62
+	s.Epoch.TotalCount = 200
+	s.Epoch.MissedCount = 3
+	s.Epoch.MissPercentage = 1.5
+	s.Epoch.TimeslotAllocation = []bool{true, false}
+	s.Epoch.PrevEpochIndex = 61
+	s.Epoch.PrevEpochClaimed = true
+	s.MLNode.Enabled = true
+	s.MLNode.ModelName = "Qwen/QwQ-32B"
+	s.MLNode.ModelLoaded = true
+	s.MLNode.PoCStatus = "INFERENCE"
+	s.MLNode.IntendedStatus = "INFERENCE"
+	s.MLNode.GPUs = []status.GPUDetail{
+		{Name: "NVIDIA H100", TotalMemoryGB: 80, UsedMemoryGB: 40, UtilizationPct: 95, TemperatureC: 61, Available: true},
+	}
+	return s
+}
+
+func scrape(t *testing.T, e *Exporter) string {
+	t.Helper()
+	ts := httptest.NewServer(e.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestMetrics_Snapshot(t *testing.T) {
+	e := New(func() (*status.NodeStatus, error) { return sampleStatus(), nil }, time.Second)
+	e.Poll()
+	out := scrape(t, e)
+
+	want := []string{
+		"# TYPE gonka_up gauge",
+		"gonka_up 1",
+		"gonka_exporter_polls_total 1",
+		"gonka_exporter_poll_failures_total 0",
+		"gonka_health_level 0",
+		"gonka_setup_checks_passed 9",
+		"gonka_block_height 1250000",
+		"gonka_network_height 1250002",
+		"gonka_block_lag 2",
+		"gonka_synced 1",
+		"gonka_peers 12",
+		"gonka_epoch_number 62",
+		"gonka_miss_rate_percent 1.5",
+		"gonka_timeslots_allocated 1",
+		"gonka_timeslots_total 2",
+		`gonka_prev_epoch_reward_claimed{epoch="61"} 1`,
+		`gonka_mlnode_model_loaded{model="Qwen/QwQ-32B"} 1`,
+		`gonka_mlnode_current_status{status="INFERENCE"} 1`,
+		"gonka_mlnode_status_mismatch 0",
+		`gonka_gpu_memory_total_bytes{gpu="0",name="NVIDIA H100"} 85899345920`,
+		`gonka_gpu_utilization_percent{gpu="0",name="NVIDIA H100"} 95`,
+	}
+	for _, line := range want {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("metrics missing %q", line)
+		}
+	}
+}
+
+func TestMetrics_UnknownPeersOmitted(t *testing.T) {
+	s := sampleStatus()
+	s.Blockchain.PeerCountKnown = false
+	e := New(func() (*status.NodeStatus, error) { return s, nil }, time.Second)
+	e.Poll()
+
+	if out := scrape(t, e); strings.Contains(out, "gonka_peers ") {
+		t.Error("gonka_peers should be omitted when the peer count is unknown")
+	}
+}
+
+func TestMetrics_FailedPoll(t *testing.T) {
+	calls := 0
+	e := New(func() (*status.NodeStatus, error) {
+		calls++
+		if calls > 1 {
+			return nil, errors.New("admin API unreachable")
+		}
+		return sampleStatus(), nil
+	}, time.Second)
+
+	e.Poll()
+	e.Poll()
+	out := scrape(t, e)
+
+	for _, line := range []string{"gonka_up 0", "gonka_exporter_polls_total 2", "gonka_exporter_poll_failures_total 1"} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("metrics missing %q", line)
+		}
+	}
+	// The last good snapshot is still exported
+	if !strings.Contains(out, "gonka_block_height 1250000\n") {
+		t.Error("expected last good snapshot to be exported after a failed poll")
+	}
+}
+
+func TestMetrics_NoPollYet(t *testing.T) {
+	e := New(func() (*status.NodeStatus, error) { return sampleStatus(), nil }, time.Second)
+	out := scrape(t, e)
+	if !strings.Contains(out, "gonka_up 0\n") {
+		t.Error("expected gonka_up 0 before the first poll")
+	}
+	if strings.Contains(out, "gonka_block_height") {
+		t.Error("node metrics should be absent before the first poll")
+	}
+}
+
+func TestMetrics_NodeUnreachable(t *testing.T) {
+	tests := []struct {
+		name string
+		s    *status.NodeStatus
+		want string
+	}{
+		{name: "empty snapshot", s: &status.NodeStatus{}, want: "gonka_up 0\n"},
+		{name: "rpc only", s: &status.NodeStatus{Blockchain: status.BlockchainStatus{PeerCountKnown: true}}, want: "gonka_up 1\n"},
+		{name: "mlnode only", s: &status.NodeStatus{Overview: status.OverviewStatus{OverallStatus: "MLNODE-ONLY"}}, want: "gonka_up 1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := New(func() (*status.NodeStatus, error) { return tt.s, nil }, time.Second)
+			e.Poll()
+			if out := scrape(t, e); !strings.Contains(out, tt.want) {
+				t.Errorf("metrics missing %q", tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_UnknownPath(t *testing.T) {
+	e := New(func() (*status.NodeStatus, error) { return sampleStatus(), nil }, time.Second)
+	rec := httptest.NewRecorder()
+	e.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/nope", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	tests := []struct {
+		name string
+		in   labels
+		want string
+	}{
+		{name: "empty", in: nil, want: ""},
+		{name: "sorted", in: labels{"name": "H100", "gpu": "0"}, want: `{gpu="0",name="H100"}`},
+		{name: "escaped", in: labels{"model": `a"b\c` + "\n"}, want: `{model="a\"b\\c\n"}`},
+		{name: "other bytes literal", in: labels{"gpu": "H100\t80GB ü"}, want: "{gpu=\"H100\t80GB ü\"}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatLabels(tt.in); got != tt.want {
+				t.Errorf("formatLabels() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHealthValue(t *testing.T) {
+	tests := map[string]float64{
+		status.HealthOK:       0,
+		status.HealthWarning:  1,
+		status.HealthCritical: 2,
+	}
+	for level, want := range tests {
+		if got := healthValue(level); got != want {
+			t.Errorf("healthValue(%q) = %v, want %v", level, got, want)
+		}
+	}
+}
+
+// nodeStandIn serves the Admin API and Tendermint RPC endpoints that
+// status.FetchStatusWithConfig polls.
+func nodeStandIn(t *testing.T) *status.StatusConfig {
+	t.Helper()
+	mux := http.NewServeMux()
+	writeJSON := func(path, body string) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(body))
+		})
+	}
+	writeJSON("/admin/v1/setup/report", `{
+		"overall_status": "PASS",
+		"checks": [
+			{"id": "active_in_epoch", "status": "PASS", "message": "Active in epoch 62", "details": {"epoch": 62, "weight": 9120}},
+			{"id": "missed_requests_threshold", "status": "PASS", "message": "ok", "details": {"missed_percentage": 2.5, "missed_requests": 5, "total_requests": 200}},
+			{"id": "mlnode_node1", "status": "PASS", "message": "MLNode healthy", "details": {"gpus": [{"name": "NVIDIA A100", "total_memory_gb": 80, "used_memory_gb": 72, "utilization_percent": 95, "temperature_c": 65, "available": true}], "models": ["Qwen/QwQ-32B"]}}
+		],
+		"summary": {"total_checks": 3, "passed_checks": 3, "failed_checks": 0}
+	}`)
+	writeJSON("/admin/v1/config", `{"current_seed": {"epoch_index": 62}, "current_height": 22300}`)
+	writeJSON("/admin/v1/nodes", `[{
+		"node": {"id": "node1", "host": "inference", "inference_port": 5000, "poc_port": 8080},
+		"state": {"current_status": "INFERENCE", "intended_status": "INFERENCE", "admin_state": {"enabled": true, "epoch": 62}}
+	}]`)
+	writeJSON("/status", `{"result": {"sync_info": {"latest_block_height": "22250", "catching_up": false}, "validator_info": {"address": "VAL1"}}}`)
+	writeJSON("/net_info", `{"result": {"n_peers": "8"}}`)
+	writeJSON("/validators", `{"result": {"validators": [{"address": "VAL1", "voting_power": "9120"}]}}`)
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return &status.StatusConfig{AdminURL: ts.URL, TendermintURL: ts.URL, VLLMHealthURL: ts.URL}
+}
+
+func TestMetrics_FromNodeEndpoints(t *testing.T) {
+	cfg := nodeStandIn(t)
+	e := New(func() (*status.NodeStatus, error) {
+		return status.FetchStatusWithConfig("", cfg)
+	}, time.Second)
+	e.Poll()
+	out := scrape(t, e)
+
+	want := []string{
+		"gonka_up 1",
+		"gonka_block_height 22250",
+		"gonka_network_height 22300",
+		"gonka_peers 8",
+		"gonka_validator_in_set 1",
+		"gonka_voting_power 9120",
+		"gonka_epoch_number 62",
+		"gonka_miss_rate_percent 2.5",
+		"gonka_missed_requests 5",
+		`gonka_mlnode_current_status{status="INFERENCE"} 1`,
+		`gonka_gpu_temperature_celsius{gpu="0",name="NVIDIA A100"} 65`,
+		`gonka_gpu_memory_used_bytes{gpu="0",name="NVIDIA A100"} 77309411328`,
+	}
+	for _, line := range want {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("metrics missing %q\n%s", line, out)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package firewall
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var testPlan = &Plan{Rules: []Rule{
+	{Name: "poc", Port: 8080, Allow: []string{"198.51.100.7"}},
+	{Name: "inference", Port: 5000, Allow: []string{"10.0.0.0/8", "192.168.0.0/16"}},
+}}
+
+// limitPlan is testPlan with connection limits and an open-ports rule.
+var limitPlan = &Plan{
+	Rules: testPlan.Rules,
+	Limits: []Limit{
+		{Name: "api-rate", Port: 80, Rate: 20, Burst: 40},
+		{Name: "p2p-conns", Port: 26656, MaxConns: 16},
+	},
+	Open: []int{80, 26656},
+}
+
+func TestNFTablesRender(t *testing.T) {
+	got := NFTables{}.Render(testPlan)
+	for _, want := range []string{
+		"table inet gonka_nop\ndelete table inet gonka_nop\n",
+		"type filter hook forward priority filter - 1; policy accept;",
+		`tcp dport 8080 ip saddr != { 198.51.100.7 } drop comment "gonka-nop:poc"`,
+		`tcp dport 5000 ip saddr != { 10.0.0.0/8, 192.168.0.0/16 } drop comment "gonka-nop:inference"`,
+		`tcp dport 8080 meta nfproto ipv6 drop comment "gonka-nop:poc"`,
+		`tcp dport 5000 meta nfproto ipv6 drop comment "gonka-nop:inference"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Render() missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestNFTablesRender_IPv6(t *testing.T) {
+	got := NFTables{}.Render(&Plan{Rules: []Rule{
+		{Name: "poc", Port: 8080, Allow: []string{"198.51.100.7", "2001:db8::7"}},
+		{Name: "inference", Port: 5000, Allow: []string{"2001:db8::/32"}},
+	}})
+	for _, want := range []string{
+		`tcp dport 8080 ip saddr != { 198.51.100.7 } drop comment "gonka-nop:poc"`,
+		`tcp dport 8080 ip6 saddr != { 2001:db8::7 } drop comment "gonka-nop:poc"`,
+		`tcp dport 5000 meta nfproto ipv4 drop comment "gonka-nop:inference"`,
+		`tcp dport 5000 ip6 saddr != { 2001:db8::/32 } drop comment "gonka-nop:inference"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Render() missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestNFTablesRender_Limits(t *testing.T) {
+	got := NFTables{}.Render(limitPlan)
+	for _, want := range []string{
+		"set api_rate {\n\t\ttype ipv4_addr\n\t\tflags dynamic\n\t\ttimeout 1m\n\t}",
+		"set api_rate_v6 {\n\t\ttype ipv6_addr\n\t\tflags dynamic\n\t\ttimeout 1m\n\t}",
+		"set p2p_conns {\n\t\ttype ipv4_addr\n\t\tflags dynamic\n\t}",
+		"set p2p_conns_v6 {\n\t\ttype ipv6_addr\n\t\tflags dynamic\n\t}",
+		`tcp dport 80 ct state new update @api_rate { ip saddr limit rate over 20/second burst 40 packets } drop comment "gonka-nop:api-rate"`,
+		`tcp dport 80 ct state new update @api_rate_v6 { ip6 saddr limit rate over 20/second burst 40 packets } drop comment "gonka-nop:api-rate"`,
+		`tcp dport 26656 ct state new add @p2p_conns { ip saddr ct count over 16 } drop comment "gonka-nop:p2p-conns"`,
+		`tcp dport 26656 ct state new add @p2p_conns_v6 { ip6 saddr ct count over 16 } drop comment "gonka-nop:p2p-conns"`,
+		`ct state new ct status dnat tcp dport != { 80, 26656 } drop comment "gonka-nop:open"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Render() missing %q:\n%s", want, got)
+		}
+	}
+	// Source restrictions come before limits, the open-ports rule last.
+	if strings.Index(got, "gonka-nop:inference") > strings.Index(got, "gonka-nop:api-rate") ||
+		strings.Index(got, "gonka-nop:p2p-conns") > strings.Index(got, "gonka-nop:open") {
+		t.Errorf("Render() rule order is wrong:\n%s", got)
+	}
+}
+
+func TestUFWRender_Limits(t *testing.T) {
+	got := UFW{}.Render(limitPlan)
+	for _, want := range []string{
+		"-A gonka-nop -p tcp --dport 80 -m conntrack --ctstate NEW -m hashlimit --hashlimit-above 20/sec --hashlimit-burst 40 --hashlimit-mode srcip --hashlimit-name gonka-nop-api-rate -m comment --comment gonka-nop:api-rate -j DROP",
+		"-A gonka-nop -p tcp --dport 26656 --syn -m connlimit --connlimit-above 16 --connlimit-mask 32 -m comment --comment gonka-nop:p2p-conns -j DROP",
+		"-A gonka-nop -p tcp --syn -m conntrack --ctstate DNAT -m multiport ! --dports 80,26656 -m comment --comment gonka-nop:open -j DROP",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Render() missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestNFTablesApply(t *testing.T) {
+	r := newFakeRunner()
+	if err := (NFTables{}).Apply(context.Background(), r, testPlan); err != nil {
+		t.Fatalf("Apply() error: %v", err)
+	}
+	want := []string{
+		"mkdir -p /etc/gonka-nop",
+		"tee /etc/gonka-nop/firewall.nft",
+		"nft -f /etc/gonka-nop/firewall.nft",
+		"tee /etc/systemd/system/gonka-nop-firewall.service",
+		"systemctl daemon-reload",
+		"systemctl enable gonka-nop-firewall.service",
+	}
+	if strings.Join(r.calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("calls = %q, want %q", r.calls, want)
+	}
+	if r.stdin["tee /etc/gonka-nop/firewall.nft"] != (NFTables{}).Render(testPlan) {
+		t.Error("rule file content differs from Render()")
+	}
+}
+
+func TestUFWApply_ReplacesBlock(t *testing.T) {
+	r := newFakeRunner()
+	stale := ufwBlockBegin + "\n*filter\n:gonka-nop - [0:0]\n" +
+		"-A gonka-nop -p tcp --dport 9999 -m comment --comment gonka-nop:old -j DROP\nCOMMIT\n" + ufwBlockEnd + "\n"
+	r.output["cat /etc/ufw/after.rules"] = "*filter\nCOMMIT\n" + stale
+	r.fail["iptables -C DOCKER-USER"] = true
+
+	if err := (UFW{}).Apply(context.Background(), r, testPlan); err != nil {
+		t.Fatalf("Apply() error: %v", err)
+	}
+	written := r.stdin["tee /etc/ufw/after.rules"]
+	if strings.Count(written, ufwBlockBegin) != 1 || strings.Contains(written, "9999") {
+		t.Errorf("after.rules should hold exactly one fresh block:\n%s", written)
+	}
+	if strings.Contains(written, "DOCKER-USER") {
+		t.Errorf("after.rules must not declare or append to DOCKER-USER:\n%s", written)
+	}
+	for _, want := range []string{
+		":gonka-nop - [0:0]\n",
+		"-A gonka-nop -p tcp --dport 8080 -s 198.51.100.7 -m comment --comment gonka-nop:poc -j RETURN\n" +
+			"-A gonka-nop -p tcp --dport 8080 -m comment --comment gonka-nop:poc -j DROP\n",
+		"--dport 5000 -s 192.168.0.0/16 -m comment --comment gonka-nop:inference -j RETURN",
+	} {
+		if !strings.Contains(written, want) {
+			t.Errorf("after.rules missing %q:\n%s", want, written)
+		}
+	}
+	if !strings.Contains(r.stdin["tee "+ufwDockerDropIn], "ExecStartPost=-/bin/sh -c 'iptables -C DOCKER-USER") {
+		t.Errorf("docker drop-in = %q", r.stdin["tee "+ufwDockerDropIn])
+	}
+
+	// The jump is inserted once, after the reload that creates the chain.
+	for _, want := range []string{
+		"ufw reload",
+		"iptables -I DOCKER-USER 1 -m comment --comment gonka-nop:jump -j gonka-nop",
+	} {
+		if !slices.Contains(r.calls, want) {
+			t.Errorf("calls missing %q: %q", want, r.calls)
+		}
+	}
+	if slices.Index(r.calls, "ufw reload") > slices.Index(r.calls, "iptables -I DOCKER-USER 1 -m comment --comment gonka-nop:jump -j gonka-nop") {
+		t.Errorf("jump inserted before the chain exists: %q", r.calls)
+	}
+}
+
+func TestUFWApply_KeepsExistingJump(t *testing.T) {
+	r := newFakeRunner()
+	if err := (UFW{}).Apply(context.Background(), r, testPlan); err != nil {
+		t.Fatalf("Apply() error: %v", err)
+	}
+	for _, c := range r.calls {
+		if strings.HasPrefix(c, "iptables -I") || strings.HasPrefix(c, "iptables -F DOCKER-USER") {
+			t.Errorf("unexpected call %q", c)
+		}
+	}
+}
+
+func TestUFWRemove(t *testing.T) {
+	r := newFakeRunner()
+	r.output["cat /etc/ufw/after.rules"] = "*filter\nCOMMIT\n" + (UFW{}).Render(testPlan)
+
+	if err := (UFW{}).Remove(context.Background(), r); err != nil {
+		t.Fatalf("Remove() error: %v", err)
+	}
+	for _, want := range []string{
+		"iptables -D DOCKER-USER -m comment --comment gonka-nop:jump -j gonka-nop",
+		"iptables -F gonka-nop",
+		"iptables -X gonka-nop",
+		"rm -f " + ufwDockerDropIn,
+	} {
+		if !slices.Contains(r.calls, want) {
+			t.Errorf("calls missing %q: %q", want, r.calls)
+		}
+	}
+	for _, c := range r.calls {
+		if strings.HasPrefix(c, "iptables -F DOCKER-USER") {
+			t.Errorf("DOCKER-USER must not be flushed: %q", c)
+		}
+	}
+	if got := r.stdin["tee /etc/ufw/after.rules"]; got != "*filter\nCOMMIT\n" {
+		t.Errorf("after.rules = %q, want the block removed", got)
+	}
+}
+
+func TestUFWCurrent(t *testing.T) {
+	r := newFakeRunner()
+	r.output["iptables -S DOCKER-USER"] = "-N DOCKER-USER\n-A DOCKER-USER -j RETURN\n"
+	r.output["iptables -S gonka-nop"] = "-A gonka-nop -p tcp -m tcp --dport 8080 -m comment --comment gonka-nop:poc -j DROP\n"
+	got, err := (UFW{}).Current(context.Background(), r)
+	if err != nil || !got.Empty() {
+		t.Errorf("without the jump Current() = %+v, %v; want empty", got, err)
+	}
+
+	r.output["iptables -S DOCKER-USER"] = "-N DOCKER-USER\n-A DOCKER-USER -m comment --comment gonka-nop:jump -j gonka-nop\n"
+	got, err = (UFW{}).Current(context.Background(), r)
+	if err != nil || len(got.Rules) != 1 || got.Rules[0].Port != 8080 {
+		t.Errorf("Current() = %+v, %v", got, err)
+	}
+}
+
+func TestFirewalldApply(t *testing.T) {
+	r := newFakeRunner()
+	r.output["firewall-cmd --permanent --direct --get-rules"] =
+		"0 -p tcp --dport 8080 -s 203.0.113.1 -m comment --comment gonka-nop:poc -j RETURN\n" +
+			"0 -p tcp --dport 22 -j ACCEPT\n"
+
+	if err := (Firewalld{}).Apply(context.Background(), r, testPlan); err != nil {
+		t.Fatalf("Apply() error: %v", err)
+	}
+	joined := strings.Join(r.calls, "\n")
+	for _, want := range []string{
+		"firewall-cmd --permanent --direct --remove-rule ipv4 filter DOCKER-USER 0 -p tcp --dport 8080 -s 203.0.113.1",
+		"firewall-cmd --permanent --direct --add-chain ipv4 filter DOCKER-USER",
+		"--add-rule ipv4 filter DOCKER-USER 0 -p tcp --dport 8080 -s 198.51.100.7 -m comment --comment gonka-nop:poc -j RETURN",
+		"--add-rule ipv4 filter DOCKER-USER 1 -p tcp --dport 8080 -m comment --comment gonka-nop:poc -j DROP",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("calls missing %q:\n%s", want, joined)
+		}
+	}
+	if strings.Contains(joined, "--remove-rule ipv4 filter DOCKER-USER 0 -p tcp --dport 22") {
+		t.Error("untagged rules must not be removed")
+	}
+	if r.calls[len(r.calls)-1] != "firewall-cmd --reload" {
+		t.Errorf("last call = %q, want a reload", r.calls[len(r.calls)-1])
+	}
+}
+
+func TestFirewalldAddArgs_Priorities(t *testing.T) {
+	prio := map[string]string{}
+	for _, args := range firewalldAddArgs(limitPlan)[1:] {
+		// --permanent --direct --add-rule ipv4 filter DOCKER-USER <prio> <spec...>
+		spec := strings.Join(args[7:], " ")
+		switch {
+		case strings.Contains(spec, "gonka-nop:open"):
+			prio["open"] = args[6]
+		case strings.Contains(spec, "hashlimit"), strings.Contains(spec, "connlimit"):
+			prio["limit"] = args[6]
+		case strings.HasSuffix(spec, "-j RETURN"):
+			prio["return"] = args[6]
+		default:
+			prio["drop"] = args[6]
+		}
+	}
+	want := map[string]string{"return": "0", "drop": "1", "limit": "2", "open": "3"}
+	for k, v := range want {
+		if prio[k] != v {
+			t.Errorf("priority of %s rules = %q, want %s", k, prio[k], v)
+		}
+	}
+}
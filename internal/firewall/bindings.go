@@ -0,0 +1,49 @@
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+)
+
+// ExposedBindings returns the Docker port bindings of the given host ports
+// that listen beyond loopback, e.g. "0.0.0.0:9200". Firewall rules guard
+// these ports too, but a loopback binding is the first line of defense.
+func ExposedBindings(ctx context.Context, r Runner, ports []int) ([]string, error) {
+	out, err := r.Run(ctx, "", "docker", "ps", "--format", "{{.Ports}}")
+	if err != nil {
+		return nil, fmt.Errorf("list container ports: %w", err)
+	}
+	return parseExposedBindings(out, ports), nil
+}
+
+// parseExposedBindings reads "docker ps" port lists such as
+// "127.0.0.1:26657->26657/tcp, 0.0.0.0:9100->9100/tcp, :::9100->9100/tcp".
+func parseExposedBindings(out string, ports []int) []string {
+	var exposed []string
+	for _, line := range strings.Split(out, "\n") {
+		for _, mapping := range strings.Split(line, ",") {
+			binding, _, ok := strings.Cut(strings.TrimSpace(mapping), "->")
+			if !ok {
+				continue // exposed but not published
+			}
+			i := strings.LastIndex(binding, ":")
+			if i < 0 {
+				continue
+			}
+			host, port := strings.Trim(binding[:i], "[]"), atoi(binding[i+1:])
+			if !slices.Contains(ports, port) {
+				continue
+			}
+			if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+				continue
+			}
+			if !slices.Contains(exposed, binding) {
+				exposed = append(exposed, binding)
+			}
+		}
+	}
+	return exposed
+}
@@ -0,0 +1,201 @@
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// legacyRulesFile is where earlier versions saved iptables rules.
+const legacyRulesFile = "/etc/iptables/rules.v4"
+
+// Diff compares the desired plan with the rules on the host. Missing holds
+// what the plan wants but the host lacks; extra holds what the host has but
+// the plan does not want, which includes every duplicate of a wanted rule.
+func Diff(desired, current *Plan) (missing, extra *Plan) {
+	missing, extra = &Plan{}, &Plan{}
+
+	rules := slices.Clone(current.Rules)
+	for _, want := range desired.Rules {
+		i := slices.IndexFunc(rules, func(r Rule) bool { return sameRule(r, want) })
+		if i < 0 {
+			missing.Rules = append(missing.Rules, want)
+			continue
+		}
+		rules = slices.Delete(rules, i, i+1)
+	}
+	extra.Rules = rules
+
+	limits := slices.Clone(current.Limits)
+	for _, want := range desired.Limits {
+		i := slices.Index(limits, want)
+		if i < 0 {
+			missing.Limits = append(missing.Limits, want)
+			continue
+		}
+		limits = slices.Delete(limits, i, i+1)
+	}
+	extra.Limits = limits
+
+	if !slices.Equal(sortedPorts(desired.Open), sortedPorts(current.Open)) {
+		missing.Open, extra.Open = desired.Open, current.Open
+	}
+	return missing, extra
+}
+
+func sortedPorts(ports []int) []int {
+	s := slices.Clone(ports)
+	slices.Sort(s)
+	return s
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// splitPorts parses "80, 26656" or "80,26656".
+func splitPorts(s string) []int {
+	var ports []int
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		ports = append(ports, atoi(f))
+	}
+	return ports
+}
+
+// sameRule compares what a rule enforces; the host port is display only.
+func sameRule(a, b Rule) bool {
+	if a.Name != b.Name || a.Port != b.Port || len(a.Allow) != len(b.Allow) {
+		return false
+	}
+	x, y := normalizeSources(a.Allow), normalizeSources(b.Allow)
+	return slices.Equal(x, y)
+}
+
+// normalizeSources sorts sources and drops the /32 iptables adds to
+// single addresses.
+func normalizeSources(srcs []string) []string {
+	out := make([]string, len(srcs))
+	for i, s := range srcs {
+		out[i] = strings.TrimSuffix(s, "/32")
+	}
+	slices.Sort(out)
+	return out
+}
+
+// iptablesCurrent lists the tagged DOCKER-USER rules, which is where
+// firewalld direct rules end up.
+func iptablesCurrent(ctx context.Context, r Runner) (*Plan, error) {
+	out, err := r.Run(ctx, "", "iptables", "-S", "DOCKER-USER")
+	if err != nil {
+		if strings.Contains(err.Error(), "No chain") {
+			return &Plan{}, nil
+		}
+		return nil, fmt.Errorf("list DOCKER-USER rules: %w", err)
+	}
+	return parseIPTablesPlan(out), nil
+}
+
+// parseIPTablesPlan rebuilds a plan from "iptables -S" output. RETURN
+// lines collect allowed sources until the DROP that closes the rule;
+// hashlimit and connlimit DROPs are limits.
+func parseIPTablesPlan(out string) *Plan {
+	p := &Plan{}
+	pending := map[string][]string{}
+	for _, line := range strings.Split(out, "\n") {
+		f := iptablesFields(line)
+		if f["--comment"] == openComment {
+			p.Open = splitPorts(f["!--dports"])
+			continue
+		}
+		name, ok := strings.CutPrefix(f["--comment"], Tag+":")
+		if !ok {
+			continue
+		}
+		port := atoi(f["--dport"])
+		switch {
+		case f["-j"] == "RETURN":
+			pending[name] = append(pending[name], f["-s"])
+		case f["--hashlimit-above"] != "":
+			rate, _, _ := strings.Cut(f["--hashlimit-above"], "/")
+			p.Limits = append(p.Limits, Limit{Name: name, Port: port, Rate: atoi(rate), Burst: atoi(f["--hashlimit-burst"])})
+		case f["--connlimit-above"] != "":
+			p.Limits = append(p.Limits, Limit{Name: name, Port: port, MaxConns: atoi(f["--connlimit-above"])})
+		case f["-j"] == "DROP":
+			p.Rules = append(p.Rules, Rule{Name: name, Port: port, Allow: pending[name]})
+			delete(pending, name)
+		}
+	}
+	return p
+}
+
+// iptablesFields maps the options of an "iptables -S" line to their values.
+// A negated source ("! -s x") is stored under "!-s".
+func iptablesFields(line string) map[string]string {
+	fields := strings.Fields(line)
+	m := map[string]string{}
+	for i := 0; i < len(fields)-1; i++ {
+		key := fields[i]
+		if !strings.HasPrefix(key, "-") {
+			continue
+		}
+		if i > 0 && fields[i-1] == "!" {
+			key = "!" + key
+		}
+		m[key] = strings.Trim(fields[i+1], `"`)
+	}
+	return m
+}
+
+// FindLegacy returns DOCKER-USER rules left by gonka-nop versions that
+// inserted untagged "! -s <src> -j DROP" rules for ports, one more on
+// every run. Each entry is a rule spec for "iptables -D DOCKER-USER".
+// Hosts without iptables have none.
+func FindLegacy(ctx context.Context, r Runner, ports []int) [][]string {
+	out, err := r.Run(ctx, "", "iptables", "-S", "DOCKER-USER")
+	if err != nil {
+		return nil
+	}
+	var specs [][]string
+	for _, line := range strings.Split(out, "\n") {
+		spec, ok := strings.CutPrefix(strings.TrimSpace(line), "-A DOCKER-USER ")
+		if !ok || strings.Contains(spec, Tag) {
+			continue
+		}
+		f := iptablesFields(line)
+		if f["-j"] == "DROP" && f["!-s"] != "" && slices.Contains(ports, atoi(f["--dport"])) {
+			specs = append(specs, strings.Fields(spec))
+		}
+	}
+	return specs
+}
+
+// RemoveLegacy deletes the rules FindLegacy returned, and drops them from
+// /etc/iptables/rules.v4, where those versions saved them, so they do not
+// come back on reboot.
+func RemoveLegacy(ctx context.Context, r Runner, specs [][]string) error {
+	if len(specs) == 0 {
+		return nil
+	}
+	stale := map[string]bool{}
+	for _, spec := range specs {
+		args := append([]string{"-D", "DOCKER-USER"}, spec...)
+		if _, err := r.Run(ctx, "", "iptables", args...); err != nil {
+			return fmt.Errorf("delete legacy rule %q: %w", strings.Join(spec, " "), err)
+		}
+		stale["-A DOCKER-USER "+strings.Join(spec, " ")] = true
+	}
+
+	saved, err := r.Run(ctx, "", "cat", legacyRulesFile)
+	if err != nil {
+		return nil // never persisted
+	}
+	lines := strings.SplitAfter(saved, "\n")
+	kept := slices.DeleteFunc(lines, func(l string) bool { return stale[strings.TrimSpace(l)] })
+	if len(kept) == len(strings.SplitAfter(saved, "\n")) {
+		return nil
+	}
+	return writeFile(ctx, r, legacyRulesFile, strings.Join(kept, ""))
+}
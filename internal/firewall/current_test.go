@@ -0,0 +1,159 @@
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	poc := Rule{Name: "poc", Port: 8080, Allow: []string{"198.51.100.7"}}
+	inference := Rule{Name: "inference", Port: 5000, HostPort: 5050, Allow: []string{"198.51.100.7"}}
+	desired := &Plan{Rules: []Rule{poc, inference}}
+
+	tests := []struct {
+		name        string
+		current     []Rule
+		wantMissing int
+		wantExtra   int
+	}{
+		{"in sync", []Rule{{Name: "poc", Port: 8080, Allow: []string{"198.51.100.7/32"}}, {Name: "inference", Port: 5000, Allow: []string{"198.51.100.7"}}}, 0, 0},
+		{"nothing installed", nil, 2, 0},
+		{"duplicates", []Rule{poc, poc, poc, inference}, 0, 2},
+		{"stale source", []Rule{{Name: "poc", Port: 8080, Allow: []string{"203.0.113.1"}}, inference}, 1, 1},
+		{"changed port", []Rule{{Name: "poc", Port: 8081, Allow: poc.Allow}, inference}, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missing, extra := Diff(desired, &Plan{Rules: tt.current})
+			if len(missing.Rules) != tt.wantMissing || len(extra.Rules) != tt.wantExtra {
+				t.Errorf("Diff() = %d missing, %d extra; want %d, %d", len(missing.Rules), len(extra.Rules), tt.wantMissing, tt.wantExtra)
+			}
+		})
+	}
+}
+
+func TestDiff_LimitsAndOpen(t *testing.T) {
+	rate := Limit{Name: "api-rate", Port: 80, Rate: 20, Burst: 40}
+	conns := Limit{Name: "p2p-conns", Port: 26656, MaxConns: 16}
+	desired := &Plan{Limits: []Limit{rate, conns}, Open: []int{80, 26656}}
+
+	missing, extra := Diff(desired, &Plan{Limits: []Limit{conns, rate}, Open: []int{26656, 80}})
+	if !missing.Empty() || !extra.Empty() {
+		t.Errorf("Diff() = %+v, %+v; want in sync regardless of order", missing, extra)
+	}
+
+	stale := Limit{Name: "api-rate", Port: 80, Rate: 5, Burst: 10}
+	missing, extra = Diff(desired, &Plan{Limits: []Limit{stale, conns}, Open: []int{80}})
+	if len(missing.Limits) != 1 || missing.Limits[0] != rate || len(extra.Limits) != 1 || extra.Limits[0] != stale {
+		t.Errorf("limits: missing %+v, extra %+v; want the rate limit replaced", missing.Limits, extra.Limits)
+	}
+	if len(missing.Open) != 2 || len(extra.Open) != 1 {
+		t.Errorf("open: missing %v, extra %v", missing.Open, extra.Open)
+	}
+}
+
+func TestParseIPTablesPlan(t *testing.T) {
+	out := `-N DOCKER-USER
+-A DOCKER-USER -s 10.0.0.0/8 -p tcp -m tcp --dport 9100 -m comment --comment gonka-nop:ml-callback -j RETURN
+-A DOCKER-USER -s 192.168.0.0/16 -p tcp -m tcp --dport 9100 -m comment --comment gonka-nop:ml-callback -j RETURN
+-A DOCKER-USER -p tcp -m tcp --dport 9100 -m comment --comment gonka-nop:ml-callback -j DROP
+-A DOCKER-USER -s 172.16.0.0/12 -p tcp -m tcp --dport 9200 -m comment --comment "gonka-nop:admin" -j RETURN
+-A DOCKER-USER -p tcp -m tcp --dport 9200 -m comment --comment "gonka-nop:admin" -j DROP
+-A DOCKER-USER -p tcp -m tcp --dport 80 -m conntrack --ctstate NEW -m hashlimit --hashlimit-above 20/sec --hashlimit-burst 40 --hashlimit-mode srcip --hashlimit-name gonka-nop-api-rate -m comment --comment gonka-nop:api-rate -j DROP
+-A DOCKER-USER -p tcp -m tcp --dport 26656 --tcp-flags FIN,SYN,RST,ACK SYN -m connlimit --connlimit-above 16 --connlimit-mask 32 -m comment --comment gonka-nop:p2p-conns -j DROP
+-A DOCKER-USER -p tcp -m tcp --tcp-flags FIN,SYN,RST,ACK SYN -m conntrack --ctstate DNAT -m multiport ! --dports 80,26656 -m comment --comment gonka-nop:open -j DROP
+-A DOCKER-USER ! -s 172.16.0.0/12 -p tcp -m tcp --dport 8080 -j DROP
+-A DOCKER-USER -j RETURN
+`
+	p := parseIPTablesPlan(out)
+	rules := p.Rules
+	if len(rules) != 2 {
+		t.Fatalf("rules = %+v, want 2", rules)
+	}
+	if r := rules[0]; r.Name != "ml-callback" || r.Port != 9100 || strings.Join(r.Allow, ",") != "10.0.0.0/8,192.168.0.0/16" {
+		t.Errorf("rule 0 = %+v", r)
+	}
+	if r := rules[1]; r.Name != "admin" || r.Port != 9200 || strings.Join(r.Allow, ",") != DockerBridgeCIDR {
+		t.Errorf("rule 1 = %+v", r)
+	}
+	wantLimits := []Limit{
+		{Name: "api-rate", Port: 80, Rate: 20, Burst: 40},
+		{Name: "p2p-conns", Port: 26656, MaxConns: 16},
+	}
+	if len(p.Limits) != 2 || p.Limits[0] != wantLimits[0] || p.Limits[1] != wantLimits[1] {
+		t.Errorf("limits = %+v, want %+v", p.Limits, wantLimits)
+	}
+	if len(p.Open) != 2 || p.Open[0] != 80 || p.Open[1] != 26656 {
+		t.Errorf("open = %v, want [80 26656]", p.Open)
+	}
+}
+
+func TestNFTablesCurrent(t *testing.T) {
+	r := newFakeRunner()
+	r.output["nft list table inet gonka_nop"] = `table inet gonka_nop {
+	chain forward {
+		type filter hook forward priority filter - 1; policy accept;
+		tcp dport 8080 ip saddr != 198.51.100.7 drop comment "gonka-nop:poc"
+		tcp dport 8080 meta nfproto ipv6 drop comment "gonka-nop:poc"
+		tcp dport 5000 ip saddr != { 10.0.0.0/8, 192.168.0.0/16 } drop comment "gonka-nop:inference"
+		tcp dport 5000 meta nfproto ipv6 drop comment "gonka-nop:inference"
+		tcp dport 80 ct state new update @api_rate { ip saddr limit rate over 20/second burst 40 packets } drop comment "gonka-nop:api-rate"
+		tcp dport 80 ct state new update @api_rate_v6 { ip6 saddr limit rate over 20/second burst 40 packets } drop comment "gonka-nop:api-rate"
+		tcp dport 26656 ct state new add @p2p_conns { ip saddr ct count over 16 } drop comment "gonka-nop:p2p-conns"
+		tcp dport 26656 ct state new add @p2p_conns_v6 { ip6 saddr ct count over 16 } drop comment "gonka-nop:p2p-conns"
+		ct state new ct status dnat tcp dport != { 80, 26656 } drop comment "gonka-nop:open"
+	}
+}
+`
+	current, err := NFTables{}.Current(context.Background(), r)
+	if err != nil {
+		t.Fatalf("Current() error: %v", err)
+	}
+	if missing, extra := Diff(limitPlan, current); !missing.Empty() || !extra.Empty() {
+		t.Errorf("Current() = %+v, want %+v", current, limitPlan)
+	}
+
+	none := &errRunner{err: fmt.Errorf("nft: exit status 1: Error: No such file or directory")}
+	if current, err := (NFTables{}).Current(context.Background(), none); err != nil || !current.Empty() {
+		t.Errorf("Current() without table = %v, %v; want none", current, err)
+	}
+}
+
+func TestLegacyRules(t *testing.T) {
+	legacy := "-A DOCKER-USER ! -s 198.51.100.7/32 -p tcp -m tcp --dport 8080 -j DROP"
+	r := newFakeRunner()
+	r.output["iptables -S DOCKER-USER"] = "-N DOCKER-USER\n" +
+		legacy + "\n" + legacy + "\n" +
+		"-A DOCKER-USER ! -s 10.1.0.0/16 -p tcp -m tcp --dport 22 -j DROP\n" +
+		"-A DOCKER-USER -p tcp -m tcp --dport 8080 -m comment --comment gonka-nop:poc -j DROP\n"
+	r.output["cat /etc/iptables/rules.v4"] = "*filter\n" + legacy + "\n" + legacy + "\nCOMMIT\n"
+
+	specs := FindLegacy(context.Background(), r, []int{8080, 5000})
+	if len(specs) != 2 {
+		t.Fatalf("FindLegacy() = %q, want the two duplicate 8080 rules", specs)
+	}
+	if err := RemoveLegacy(context.Background(), r, specs); err != nil {
+		t.Fatalf("RemoveLegacy() error: %v", err)
+	}
+	var deletes int
+	for _, c := range r.calls {
+		if c == "iptables -D DOCKER-USER ! -s 198.51.100.7/32 -p tcp -m tcp --dport 8080 -j DROP" {
+			deletes++
+		}
+	}
+	if deletes != 2 {
+		t.Errorf("deletes = %d, want 2 (%q)", deletes, r.calls)
+	}
+	if got := r.stdin["tee /etc/iptables/rules.v4"]; got != "*filter\nCOMMIT\n" {
+		t.Errorf("rules.v4 = %q, want legacy rules dropped", got)
+	}
+}
+
+// errRunner fails every command with err.
+type errRunner struct{ err error }
+
+func (e *errRunner) Run(context.Context, string, string, ...string) (string, error) {
+	return "", e.err
+}
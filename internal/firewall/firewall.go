@@ -0,0 +1,365 @@
+// Package firewall restricts which sources may reach the node's service ports.
+//
+// A Plan lists the rules; a Backend (nftables, ufw or firewalld) installs
+// them persistently. Plans are pure data and backends render them without
+// touching the host, so both are testable without applying anything.
+//
+// Docker publishes ports with DNAT, which bypasses the INPUT chain. Rules
+// therefore match forwarded traffic after DNAT, i.e. the container port.
+package firewall
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/inc4/gonka-nop/internal/config"
+)
+
+// Tag marks every rule gonka-nop installs, so they can be found and replaced.
+const Tag = "gonka-nop"
+
+// DockerBridgeCIDR covers Docker's default bridge and compose networks.
+const DockerBridgeCIDR = "172.16.0.0/12"
+
+// InferenceContainerPort is the port uvicorn listens on inside the ML node
+// container. The host maps state.InferencePort (default 5050) to it.
+const InferenceContainerPort = 5000
+
+// Network node ports that must never be reachable from the internet.
+const (
+	RPCPort        = 26657
+	MLCallbackPort = 9100
+	AdminPort      = 9200
+)
+
+// Public network node ports, as container ports: the proxy serves the API
+// on 80 (and on 443 with TLS) and the chain node listens for peers on 26656.
+const (
+	APIContainerPort    = 80
+	APITLSContainerPort = 443
+	P2PContainerPort    = 26656
+)
+
+// Connection limits for the public ports, per source IP. Peers keep a few
+// long-lived P2P connections; API clients that open more than a handful of
+// connections per second are scrapers.
+const (
+	APIRateLimit = 20
+	APIRateBurst = 40
+	P2PMaxConns  = 16
+)
+
+// privateCIDRs are the RFC1918 ranges ML nodes use to reach a network node
+// over a private network.
+var privateCIDRs = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"}
+
+// Rule drops TCP traffic to Port unless it comes from one of Allow.
+type Rule struct {
+	Name     string   // short label, e.g. "poc"; part of the rule comment
+	Port     int      // container port (post-DNAT)
+	HostPort int      // published host port, when it differs from Port
+	Allow    []string // source IPs or CIDRs
+}
+
+// Comment is the tag written next to the rule on the host.
+func (r Rule) Comment() string {
+	return Tag + ":" + r.Name
+}
+
+// Limit drops a source IP's connections to Port beyond a rate or a count.
+// Set either Rate or MaxConns.
+type Limit struct {
+	Name     string // short label; part of the rule comment
+	Port     int    // container port (post-DNAT)
+	Rate     int    // new connections per second per source
+	Burst    int    // connections allowed above Rate in a burst
+	MaxConns int    // concurrent connections per source
+}
+
+// Comment is the tag written next to the rule on the host.
+func (l Limit) Comment() string {
+	return Tag + ":" + l.Name
+}
+
+// openComment tags the rule that enforces Plan.Open.
+const openComment = Tag + ":open"
+
+// Plan is the desired rule set for one node.
+type Plan struct {
+	Rules  []Rule
+	Limits []Limit
+	// Open, when set, lists the only container ports that accept new
+	// connections through published ports; Rules may still narrow who can
+	// reach them. Connections to any other published port are dropped.
+	Open []int
+}
+
+// Empty reports whether the plan has no rules at all.
+func (p *Plan) Empty() bool {
+	return len(p.Rules) == 0 && len(p.Limits) == 0 && len(p.Open) == 0
+}
+
+// Ports returns the container ports the plan restricts.
+func (p *Plan) Ports() []int {
+	ports := make([]int, 0, len(p.Rules))
+	for _, r := range p.Rules {
+		ports = append(ports, r.Port)
+	}
+	return ports
+}
+
+// PlanMLNode restricts the PoC and inference ports of an ML node to its
+// network node. When both run on the same server, the network node reaches
+// them over the Docker bridge instead.
+func PlanMLNode(state *config.State) (*Plan, error) {
+	if state.NetworkNodeIP == "" {
+		return nil, fmt.Errorf("network node IP is not set")
+	}
+	allow := state.NetworkNodeIP
+	if state.PublicIP == state.NetworkNodeIP {
+		allow = DockerBridgeCIDR
+	}
+	pocPort := state.PoCPort
+	if pocPort == 0 {
+		pocPort = 8080
+	}
+	inferencePort := state.InferencePort
+	if inferencePort == 0 {
+		inferencePort = 5050
+	}
+	return &Plan{Rules: []Rule{
+		{Name: "poc", Port: pocPort, Allow: []string{allow}},
+		{Name: "inference", Port: InferenceContainerPort, HostPort: inferencePort, Allow: []string{allow}},
+	}}, nil
+}
+
+// PlanNetworkNode opens only P2P and the public API to the internet,
+// rate-limits new API connections and caps P2P connections per source IP.
+// RPC, the ML callback and the Admin API are restricted to the Docker
+// bridge. On a network-only node, ML nodes call back on 9100 over the
+// private network, so private ranges are allowed there too; when the
+// network node has no private IP the callback port stays open (see
+// CallbackRestricted). With TLS the HTTPS port is opened and limited too.
+func PlanNetworkNode(state *config.State) *Plan {
+	bridge := []string{DockerBridgeCIDR}
+	p := &Plan{
+		Rules: []Rule{
+			{Name: "rpc", Port: RPCPort, Allow: bridge},
+			{Name: "admin", Port: AdminPort, Allow: bridge},
+		},
+		Limits: []Limit{
+			{Name: "api-rate", Port: APIContainerPort, Rate: APIRateLimit, Burst: APIRateBurst},
+			{Name: "p2p-conns", Port: P2PContainerPort, MaxConns: P2PMaxConns},
+		},
+		Open: []int{APIContainerPort, P2PContainerPort},
+	}
+	switch {
+	case !state.IsNetworkOnly():
+		p.Rules = append(p.Rules, Rule{Name: "ml-callback", Port: MLCallbackPort, Allow: bridge})
+	case CallbackRestricted(state):
+		p.Rules = append(p.Rules, Rule{Name: "ml-callback", Port: MLCallbackPort, Allow: privateCIDRs})
+		p.Open = append(p.Open, MLCallbackPort)
+	default:
+		p.Open = append(p.Open, MLCallbackPort)
+	}
+	if state.TLSEnabled() {
+		// proxy-ssl serves the API on 443; 80 stays open for ACME challenges
+		p.Limits = append(p.Limits, Limit{Name: "api-tls-rate", Port: APITLSContainerPort, Rate: APIRateLimit, Burst: APIRateBurst})
+		p.Open = append(p.Open, APITLSContainerPort)
+	}
+	return p
+}
+
+// CallbackRestricted reports whether PlanNetworkNode restricts port 9100.
+// A network-only node reached by its ML nodes over public IPs cannot.
+func CallbackRestricted(state *config.State) bool {
+	if !state.IsNetworkOnly() {
+		return true
+	}
+	ip := net.ParseIP(state.NetworkNodeIP)
+	return ip != nil && ip.IsPrivate()
+}
+
+// PlanFor returns the plan for the node's topology: ML node ports on an
+// ML-node-only server, network node ports otherwise.
+func PlanFor(state *config.State) (*Plan, error) {
+	if state.IsMLNodeOnly() {
+		return PlanMLNode(state)
+	}
+	return PlanNetworkNode(state), nil
+}
+
+// Runner runs a host command, feeding it stdin when non-empty.
+type Runner interface {
+	Run(ctx context.Context, stdin, name string, args ...string) (string, error)
+}
+
+// ExecRunner runs commands on this machine, with sudo when UseSudo is set.
+type ExecRunner struct {
+	UseSudo bool
+}
+
+// Run implements Runner.
+func (e ExecRunner) Run(ctx context.Context, stdin, name string, args ...string) (string, error) {
+	if e.UseSudo {
+		args = append([]string{name}, args...)
+		name = "sudo"
+	}
+	cmd := exec.CommandContext(ctx, name, args...) // #nosec G204 - fixed tool names, no shell
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return out.String(), fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(out.String()))
+	}
+	return out.String(), nil
+}
+
+// Backend installs a Plan with one of the host's firewall tools. Apply
+// replaces every rule gonka-nop installed before, so re-runs never
+// duplicate rules; Remove deletes them all.
+type Backend interface {
+	Name() string
+	// Render returns the rules as the backend would install them.
+	Render(p *Plan) string
+	// Current returns the tagged rules active on the host as a plan,
+	// duplicates included.
+	Current(ctx context.Context, r Runner) (*Plan, error)
+	Apply(ctx context.Context, r Runner, p *Plan) error
+	Remove(ctx context.Context, r Runner) error
+}
+
+// Detect picks the backend for this host. A firewall manager the operator
+// already runs (ufw on Debian, firewalld on RHEL) takes precedence, since
+// it would otherwise overwrite or ignore rules added behind its back;
+// without one, nftables is used directly.
+func Detect(ctx context.Context, r Runner, family string) (Backend, error) {
+	ufwActive := func() bool {
+		out, err := r.Run(ctx, "", "ufw", "status")
+		return err == nil && strings.Contains(out, "Status: active")
+	}
+	firewalldRunning := func() bool {
+		out, err := r.Run(ctx, "", "firewall-cmd", "--state")
+		return err == nil && strings.TrimSpace(out) == "running"
+	}
+
+	switch family {
+	case "debian":
+		if ufwActive() {
+			return UFW{}, nil
+		}
+	case "rhel":
+		if firewalldRunning() {
+			return Firewalld{}, nil
+		}
+	default:
+		if ufwActive() {
+			return UFW{}, nil
+		}
+		if firewalldRunning() {
+			return Firewalld{}, nil
+		}
+	}
+	if _, err := r.Run(ctx, "", "nft", "--version"); err != nil {
+		return nil, fmt.Errorf("no supported firewall found (ufw, firewalld or nftables): %w", err)
+	}
+	return NFTables{}, nil
+}
+
+// writeFile writes content to a root-owned path through tee, so it works
+// with sudo.
+func writeFile(ctx context.Context, r Runner, path, content string) error {
+	if _, err := r.Run(ctx, content, "tee", path); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
+
+// iptablesArgs renders a rule as iptables rule specs for the DOCKER-USER
+// chain: one RETURN per allowed source, then a DROP, all commented with
+// the rule's tag.
+func iptablesArgs(r Rule) [][]string {
+	port := fmt.Sprintf("%d", r.Port)
+	comment := []string{"-m", "comment", "--comment", r.Comment()}
+	specs := make([][]string, 0, len(r.Allow)+1)
+	for _, src := range r.Allow {
+		spec := append([]string{"-p", "tcp", "--dport", port, "-s", src}, comment...)
+		specs = append(specs, append(spec, "-j", "RETURN"))
+	}
+	spec := append([]string{"-p", "tcp", "--dport", port}, comment...)
+	return append(specs, append(spec, "-j", "DROP"))
+}
+
+// iptablesLimitArgs renders a limit as an iptables rule spec: hashlimit for
+// a connection rate, connlimit for a connection count.
+func iptablesLimitArgs(l Limit) []string {
+	spec := []string{"-p", "tcp", "--dport", fmt.Sprintf("%d", l.Port)}
+	if l.Rate > 0 {
+		spec = append(spec, "-m", "conntrack", "--ctstate", "NEW",
+			"-m", "hashlimit", "--hashlimit-above", fmt.Sprintf("%d/sec", l.Rate),
+			"--hashlimit-burst", fmt.Sprintf("%d", l.Burst),
+			"--hashlimit-mode", "srcip", "--hashlimit-name", Tag+"-"+l.Name)
+	} else {
+		spec = append(spec, "--syn", "-m", "connlimit",
+			"--connlimit-above", fmt.Sprintf("%d", l.MaxConns), "--connlimit-mask", "32")
+	}
+	return append(spec, "-m", "comment", "--comment", l.Comment(), "-j", "DROP")
+}
+
+// iptablesOpenArgs renders Plan.Open: new DNATed connections (i.e. through
+// a published port) to any other port are dropped.
+func iptablesOpenArgs(open []int) []string {
+	return []string{"-p", "tcp", "--syn", "-m", "conntrack", "--ctstate", "DNAT",
+		"-m", "multiport", "!", "--dports", joinPorts(open, ","),
+		"-m", "comment", "--comment", openComment, "-j", "DROP"}
+}
+
+// iptablesPlanArgs renders a whole plan in evaluation order: source
+// restrictions, limits, then the open-ports rule.
+func iptablesPlanArgs(p *Plan) [][]string {
+	var specs [][]string
+	for _, r := range p.Rules {
+		specs = append(specs, iptablesArgs(r)...)
+	}
+	for _, l := range p.Limits {
+		specs = append(specs, iptablesLimitArgs(l))
+	}
+	if len(p.Open) > 0 {
+		specs = append(specs, iptablesOpenArgs(p.Open))
+	}
+	return specs
+}
+
+func joinPorts(ports []int, sep string) string {
+	s := make([]string, len(ports))
+	for i, p := range ports {
+		s[i] = fmt.Sprintf("%d", p)
+	}
+	return strings.Join(s, sep)
+}
+
+// replaceBlock swaps the text between begin and end markers (inclusive) in
+// content for block, or appends block when the markers are missing. An
+// empty block removes the markers and their content.
+func replaceBlock(content, begin, end, block string) string {
+	start := strings.Index(content, begin)
+	stop := strings.Index(content, end)
+	if start >= 0 && stop > start {
+		rest := strings.TrimPrefix(content[stop+len(end):], "\n")
+		return content[:start] + block + rest
+	}
+	if block == "" {
+		return content
+	}
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return content + block
+}
@@ -0,0 +1,208 @@
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/inc4/gonka-nop/internal/config"
+)
+
+// fakeRunner records commands and answers them from canned output.
+type fakeRunner struct {
+	calls  []string
+	stdin  map[string]string // command -> stdin it received
+	output map[string]string // command prefix -> stdout
+	fail   map[string]bool   // command prefix -> error
+}
+
+func newFakeRunner() *fakeRunner {
+	return &fakeRunner{stdin: map[string]string{}, output: map[string]string{}, fail: map[string]bool{}}
+}
+
+func (f *fakeRunner) Run(_ context.Context, stdin, name string, args ...string) (string, error) {
+	call := strings.TrimSpace(name + " " + strings.Join(args, " "))
+	f.calls = append(f.calls, call)
+	if stdin != "" {
+		f.stdin[call] = stdin
+	}
+	for prefix := range f.fail {
+		if strings.HasPrefix(call, prefix) {
+			return "", fmt.Errorf("%s: exit status 1", name)
+		}
+	}
+	for prefix, out := range f.output {
+		if strings.HasPrefix(call, prefix) {
+			return out, nil
+		}
+	}
+	return "", nil
+}
+
+func TestPlanMLNode(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     config.State
+		wantAllow string
+		wantPorts []int
+	}{
+		{
+			name:      "separate server",
+			state:     config.State{PublicIP: "203.0.113.5", NetworkNodeIP: "198.51.100.7"},
+			wantAllow: "198.51.100.7",
+			wantPorts: []int{8080, 5000},
+		},
+		{
+			name:      "same server",
+			state:     config.State{PublicIP: "203.0.113.5", NetworkNodeIP: "203.0.113.5", PoCPort: 8081},
+			wantAllow: DockerBridgeCIDR,
+			wantPorts: []int{8081, 5000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := PlanMLNode(&tt.state)
+			if err != nil {
+				t.Fatalf("PlanMLNode() error: %v", err)
+			}
+			if len(plan.Rules) != len(tt.wantPorts) {
+				t.Fatalf("rules = %+v, want ports %v", plan.Rules, tt.wantPorts)
+			}
+			for i, r := range plan.Rules {
+				if r.Port != tt.wantPorts[i] || len(r.Allow) != 1 || r.Allow[0] != tt.wantAllow {
+					t.Errorf("rule %d = %+v, want port %d from %s", i, r, tt.wantPorts[i], tt.wantAllow)
+				}
+			}
+		})
+	}
+
+	if _, err := PlanMLNode(&config.State{PublicIP: "203.0.113.5"}); err == nil {
+		t.Error("expected an error without a network node IP")
+	}
+}
+
+func TestPlanNetworkNode(t *testing.T) {
+	tests := []struct {
+		name         string
+		state        config.State
+		wantCallback string // allowed sources of the 9100 rule; "" = no rule
+	}{
+		{"full", config.State{NodeType: config.NodeTypeFull}, DockerBridgeCIDR},
+		{"network, private callback", config.State{NodeType: config.NodeTypeNetwork, NetworkNodeIP: "10.0.1.5"},
+			strings.Join(privateCIDRs, ",")},
+		{"network, public callback", config.State{NodeType: config.NodeTypeNetwork, NetworkNodeIP: "203.0.113.5"}, ""},
+		{"full with TLS", config.State{NodeType: config.NodeTypeFull, TLSDomain: "node.example.com"}, DockerBridgeCIDR},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := PlanNetworkNode(&tt.state)
+			var callback string
+			ports := map[int]bool{}
+			for _, r := range plan.Rules {
+				ports[r.Port] = true
+				if r.Port == MLCallbackPort {
+					callback = strings.Join(r.Allow, ",")
+				}
+			}
+			if !ports[RPCPort] || !ports[AdminPort] {
+				t.Errorf("rules = %+v, want RPC and Admin API restricted", plan.Rules)
+			}
+			if callback != tt.wantCallback {
+				t.Errorf("callback allow = %q, want %q", callback, tt.wantCallback)
+			}
+			if got := CallbackRestricted(&tt.state); got != (tt.wantCallback != "") {
+				t.Errorf("CallbackRestricted() = %v", got)
+			}
+			wantOpen := []int{APIContainerPort, P2PContainerPort}
+			wantLimits := 2
+			if tt.state.IsNetworkOnly() {
+				wantOpen = append(wantOpen, MLCallbackPort)
+			}
+			if tt.state.TLSEnabled() {
+				wantOpen = append(wantOpen, APITLSContainerPort)
+				wantLimits++
+			}
+			if fmt.Sprint(plan.Open) != fmt.Sprint(wantOpen) {
+				t.Errorf("open = %v, want %v", plan.Open, wantOpen)
+			}
+			if len(plan.Limits) != wantLimits {
+				t.Errorf("limits = %+v, want %d (API rate, P2P connections, HTTPS rate with TLS)", plan.Limits, wantLimits)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name   string
+		family string
+		output map[string]string
+		fail   []string
+		want   string
+	}{
+		{"debian with ufw", "debian", map[string]string{"ufw status": "Status: active\n"}, nil, "ufw"},
+		{"debian, ufw inactive", "debian", map[string]string{"ufw status": "Status: inactive\n"}, nil, "nftables"},
+		{"rhel with firewalld", "rhel", map[string]string{"firewall-cmd --state": "running\n"}, nil, "firewalld"},
+		{"rhel without firewalld", "rhel", nil, []string{"firewall-cmd"}, "nftables"},
+		{"debian ignores firewalld", "debian", map[string]string{"firewall-cmd --state": "running\n"}, nil, "nftables"},
+		{"unknown family", "", map[string]string{"firewall-cmd --state": "running\n"}, nil, "firewalld"},
+		{"nothing installed", "debian", nil, []string{"ufw", "nft"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newFakeRunner()
+			for k, v := range tt.output {
+				r.output[k] = v
+			}
+			for _, f := range tt.fail {
+				r.fail[f] = true
+			}
+			b, err := Detect(context.Background(), r, tt.family)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("Detect() = %s, want an error", b.Name())
+				}
+				return
+			}
+			if err != nil || b.Name() != tt.want {
+				t.Errorf("Detect() = %v, %v; want %s", b, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaceBlock(t *testing.T) {
+	const begin, end = "# BEGIN x", "# END x"
+	block := begin + "\nnew\n" + end + "\n"
+	tests := []struct {
+		name, content, block, want string
+	}{
+		{"append", "a\nb", block, "a\nb\n" + block},
+		{"replace", "a\n" + begin + "\nold\n" + end + "\nb\n", block, "a\n" + block + "b\n"},
+		{"remove", "a\n" + begin + "\nold\n" + end + "\nb\n", "", "a\nb\n"},
+		{"remove missing", "a\n", "", "a\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := replaceBlock(tt.content, begin, end, tt.block); got != tt.want {
+				t.Errorf("replaceBlock() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExposedBindings(t *testing.T) {
+	out := "127.0.0.1:26657->26657/tcp, 0.0.0.0:5000->26656/tcp\n" +
+		"0.0.0.0:9100->9100/tcp, :::9100->9100/tcp, 127.0.0.1:9200->9200/tcp\n" +
+		"8080/tcp\n" +
+		"[::1]:9200->9200/tcp\n"
+	got := parseExposedBindings(out, []int{RPCPort, MLCallbackPort, AdminPort})
+	want := []string{"0.0.0.0:9100", ":::9100"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("parseExposedBindings() = %v, want %v", got, want)
+	}
+	if got := parseExposedBindings(out, []int{RPCPort, AdminPort}); len(got) != 0 {
+		t.Errorf("parseExposedBindings() = %v, want none", got)
+	}
+}
@@ -0,0 +1,112 @@
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Firewalld installs the rules as permanent direct rules on DOCKER-USER, so
+// firewalld keeps them across its reloads and reboots. Before adding, every
+// tagged rule is removed, so a changed plan leaves nothing stale behind.
+type Firewalld struct{}
+
+// firewalldChain is the direct-rule address of the DOCKER-USER chain.
+var firewalldChain = []string{"ipv4", "filter", "DOCKER-USER"}
+
+// Name implements Backend.
+func (Firewalld) Name() string { return "firewalld" }
+
+// Render implements Backend: the firewall-cmd calls Apply makes.
+func (Firewalld) Render(p *Plan) string {
+	var b strings.Builder
+	for _, args := range firewalldAddArgs(p) {
+		b.WriteString("firewall-cmd " + strings.Join(args, " ") + "\n")
+	}
+	b.WriteString("firewall-cmd --reload\n")
+	return b.String()
+}
+
+// Current implements Backend.
+func (Firewalld) Current(ctx context.Context, r Runner) (*Plan, error) {
+	return iptablesCurrent(ctx, r)
+}
+
+// Apply implements Backend.
+func (f Firewalld) Apply(ctx context.Context, r Runner, p *Plan) error {
+	if err := f.removeTagged(ctx, r); err != nil {
+		return err
+	}
+	for _, args := range firewalldAddArgs(p) {
+		if _, err := r.Run(ctx, "", "firewall-cmd", args...); err != nil {
+			return fmt.Errorf("firewall-cmd %s: %w", strings.Join(args, " "), err)
+		}
+	}
+	return firewalldReload(ctx, r)
+}
+
+// Remove implements Backend.
+func (f Firewalld) Remove(ctx context.Context, r Runner) error {
+	if err := f.removeTagged(ctx, r); err != nil {
+		return err
+	}
+	return firewalldReload(ctx, r)
+}
+
+// removeTagged deletes every permanent DOCKER-USER direct rule carrying Tag.
+func (Firewalld) removeTagged(ctx context.Context, r Runner) error {
+	list := append([]string{"--permanent", "--direct", "--get-rules"}, firewalldChain...)
+	out, err := r.Run(ctx, "", "firewall-cmd", list...)
+	if err != nil {
+		return fmt.Errorf("list firewalld direct rules: %w", err)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		// Each line is "<priority> <rule args>".
+		if !strings.Contains(line, Tag) {
+			continue
+		}
+		args := append([]string{"--permanent", "--direct", "--remove-rule"}, firewalldChain...)
+		args = append(args, strings.Fields(line)...)
+		if _, err := r.Run(ctx, "", "firewall-cmd", args...); err != nil {
+			return fmt.Errorf("remove firewalld rule %q: %w", line, err)
+		}
+	}
+	return nil
+}
+
+// firewalldAddArgs lists the firewall-cmd arguments that install p.
+// Direct rules are ordered by priority, so the plan's evaluation order is
+// encoded in it: allowed sources (0) before their DROP (1), then limits (2)
+// and the open-ports rule (3).
+func firewalldAddArgs(p *Plan) [][]string {
+	add := append([]string{"--permanent", "--direct", "--add-chain"}, firewalldChain...)
+	calls := [][]string{add}
+	rule := func(prio string, spec []string) {
+		args := append([]string{"--permanent", "--direct", "--add-rule"}, firewalldChain...)
+		args = append(args, prio)
+		calls = append(calls, append(args, spec...))
+	}
+	for _, r := range p.Rules {
+		for _, spec := range iptablesArgs(r) {
+			prio := "0"
+			if spec[len(spec)-1] == "DROP" {
+				prio = "1"
+			}
+			rule(prio, spec)
+		}
+	}
+	for _, l := range p.Limits {
+		rule("2", iptablesLimitArgs(l))
+	}
+	if len(p.Open) > 0 {
+		rule("3", iptablesOpenArgs(p.Open))
+	}
+	return calls
+}
+
+func firewalldReload(ctx context.Context, r Runner) error {
+	if _, err := r.Run(ctx, "", "firewall-cmd", "--reload"); err != nil {
+		return fmt.Errorf("firewall-cmd --reload: %w", err)
+	}
+	return nil
+}
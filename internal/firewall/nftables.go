@@ -0,0 +1,249 @@
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	nftTable    = "inet gonka_nop"
+	nftRuleFile = "/etc/gonka-nop/firewall.nft"
+	nftUnitName = "gonka-nop-firewall.service"
+	nftUnitFile = "/etc/systemd/system/" + nftUnitName
+)
+
+// nftUnit loads the rule file at boot, before Docker starts forwarding.
+const nftUnit = `[Unit]
+Description=gonka-nop firewall rules
+Wants=network-pre.target
+Before=network-pre.target docker.service
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+ExecStart=/usr/sbin/nft -f ` + nftRuleFile + `
+ExecStop=/usr/sbin/nft delete table ` + nftTable + `
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// NFTables keeps the rules in a table of its own, so nothing else on the
+// host (Docker, other tools) is touched. The table sits on the forward hook
+// just before the default filter priority; a drop in any base chain is
+// final, whatever other tables decide.
+type NFTables struct{}
+
+// Name implements Backend.
+func (NFTables) Name() string { return "nftables" }
+
+// Render implements Backend. The script recreates the table atomically:
+// declaring it first makes the delete safe when it does not exist yet.
+// Each rule is written once per address family, since "ip saddr" never
+// matches IPv6: a family without allowed sources is dropped outright.
+// Each limit tracks sources in a dynamic set of its own per family.
+func (NFTables) Render(p *Plan) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "table %s\ndelete table %s\n\n", nftTable, nftTable)
+	fmt.Fprintf(&b, "table %s {\n", nftTable)
+	for _, l := range p.Limits {
+		for _, f := range nftFamilies {
+			fmt.Fprintf(&b, "\tset %s {\n\t\ttype %s\n\t\tflags dynamic\n", nftSetName(l, f), f.setType)
+			if l.Rate > 0 {
+				b.WriteString("\t\ttimeout 1m\n")
+			}
+			b.WriteString("\t}\n\n")
+		}
+	}
+	b.WriteString("\tchain forward {\n")
+	b.WriteString("\t\ttype filter hook forward priority filter - 1; policy accept;\n")
+	for _, r := range p.Rules {
+		v4, v6 := splitFamilies(r.Allow)
+		nftFamilyRule(&b, r, "ip", "ipv4", v4)
+		nftFamilyRule(&b, r, "ip6", "ipv6", v6)
+	}
+	for _, l := range p.Limits {
+		for _, f := range nftFamilies {
+			if l.Rate > 0 {
+				fmt.Fprintf(&b, "\t\ttcp dport %d ct state new update @%s { %s saddr limit rate over %d/second burst %d packets } drop comment %q\n",
+					l.Port, nftSetName(l, f), f.addr, l.Rate, l.Burst, l.Comment())
+			} else {
+				fmt.Fprintf(&b, "\t\ttcp dport %d ct state new add @%s { %s saddr ct count over %d } drop comment %q\n",
+					l.Port, nftSetName(l, f), f.addr, l.MaxConns, l.Comment())
+			}
+		}
+	}
+	if len(p.Open) > 0 {
+		fmt.Fprintf(&b, "\t\tct state new ct status dnat tcp dport != { %s } drop comment %q\n",
+			joinPorts(p.Open, ", "), openComment)
+	}
+	b.WriteString("\t}\n}\n")
+	return b.String()
+}
+
+// nftFamilyRule writes r for one address family: addr is the nft payload
+// keyword ("ip", "ip6"), proto the matching nfproto name.
+func nftFamilyRule(b *strings.Builder, r Rule, addr, proto string, allow []string) {
+	if len(allow) == 0 {
+		fmt.Fprintf(b, "\t\ttcp dport %d meta nfproto %s drop comment %q\n", r.Port, proto, r.Comment())
+		return
+	}
+	fmt.Fprintf(b, "\t\ttcp dport %d %s saddr != { %s } drop comment %q\n",
+		r.Port, addr, strings.Join(allow, ", "), r.Comment())
+}
+
+// splitFamilies separates IPv4 from IPv6 sources.
+func splitFamilies(allow []string) (v4, v6 []string) {
+	for _, src := range allow {
+		if strings.Contains(src, ":") {
+			v6 = append(v6, src)
+		} else {
+			v4 = append(v4, src)
+		}
+	}
+	return v4, v6
+}
+
+// nftFamily is an address family a limit is written for.
+type nftFamily struct {
+	addr    string // payload keyword
+	setType string
+	suffix  string // of the set name
+}
+
+var nftFamilies = []nftFamily{
+	{addr: "ip", setType: "ipv4_addr"},
+	{addr: "ip6", setType: "ipv6_addr", suffix: "_v6"},
+}
+
+func nftSetName(l Limit, f nftFamily) string {
+	return strings.ReplaceAll(l.Name, "-", "_") + f.suffix
+}
+
+// Rules as "nft list" prints them; a single element is printed without
+// braces.
+var (
+	nftRuleRe = regexp.MustCompile(`tcp dport (\d+) (?:(ip6?) saddr != (?:\{ ([^}]*) \}|(\S+))|meta nfproto (ipv[46])) drop comment "` + Tag + `:([^"]+)"`)
+	nftRateRe = regexp.MustCompile(`tcp dport (\d+) ct state new (?:add|update) @\w+ \{ (ip6?) saddr limit rate over (\d+)/second burst (\d+) packets \} drop comment "` + Tag + `:([^"]+)"`)
+	nftConnRe = regexp.MustCompile(`tcp dport (\d+) ct state new add @\w+ \{ (ip6?) saddr ct count over (\d+) \} drop comment "` + Tag + `:([^"]+)"`)
+	nftOpenRe = regexp.MustCompile(`ct status dnat tcp dport != (?:\{ ([^}]*) \}|(\d+)) drop comment "` + openComment + `"`)
+)
+
+// Current implements Backend.
+func (NFTables) Current(ctx context.Context, r Runner) (*Plan, error) {
+	out, err := r.Run(ctx, "", "nft", "list", "table", "inet", "gonka_nop")
+	if err != nil {
+		if strings.Contains(err.Error(), "No such file") {
+			return &Plan{}, nil
+		}
+		return nil, fmt.Errorf("list nftables rules: %w", err)
+	}
+	p := &Plan{Rules: parseNFTRules(out)}
+	var rate, conns nftLimits
+	for _, m := range nftRateRe.FindAllStringSubmatch(out, -1) {
+		rate.add(m[2], Limit{Name: m[5], Port: atoi(m[1]), Rate: atoi(m[3]), Burst: atoi(m[4])})
+	}
+	for _, m := range nftConnRe.FindAllStringSubmatch(out, -1) {
+		conns.add(m[2], Limit{Name: m[4], Port: atoi(m[1]), MaxConns: atoi(m[3])})
+	}
+	p.Limits = append(rate.limits, conns.limits...)
+	if m := nftOpenRe.FindStringSubmatch(out); m != nil {
+		p.Open = splitPorts(m[1] + m[2])
+	}
+	return p, nil
+}
+
+// nftLimits merges the per-family lines of each limit into one Limit; a
+// line for a family the limit already has starts a duplicate.
+type nftLimits struct {
+	limits   []Limit
+	families []map[string]bool
+	last     map[string]int
+}
+
+func (n *nftLimits) add(family string, l Limit) {
+	if n.last == nil {
+		n.last = map[string]int{}
+	}
+	i, ok := n.last[l.Name]
+	if !ok || n.families[i][family] || n.limits[i] != l {
+		i = len(n.limits)
+		n.last[l.Name] = i
+		n.limits = append(n.limits, l)
+		n.families = append(n.families, map[string]bool{})
+	}
+	n.families[i][family] = true
+}
+
+// parseNFTRules merges the per-family lines of each rule; a line for a
+// family the rule already has starts a duplicate rule.
+func parseNFTRules(out string) []Rule {
+	var rules []Rule
+	var families []map[string]bool // payload keywords seen per rule
+	last := map[string]int{}
+	for _, m := range nftRuleRe.FindAllStringSubmatch(out, -1) {
+		name, family := m[6], m[2]
+		switch m[5] {
+		case "ipv4":
+			family = "ip"
+		case "ipv6":
+			family = "ip6"
+		}
+		i, ok := last[name]
+		if !ok || families[i][family] {
+			i = len(rules)
+			last[name] = i
+			rules = append(rules, Rule{Name: name, Port: atoi(m[1])})
+			families = append(families, map[string]bool{})
+		}
+		families[i][family] = true
+		switch {
+		case m[3] != "":
+			rules[i].Allow = append(rules[i].Allow, strings.Split(m[3], ", ")...)
+		case m[4] != "":
+			rules[i].Allow = append(rules[i].Allow, m[4])
+		}
+	}
+	return rules
+}
+
+// Apply implements Backend: it loads the rules now and installs a systemd
+// unit that reloads them at boot.
+func (n NFTables) Apply(ctx context.Context, r Runner, p *Plan) error {
+	script := n.Render(p)
+	if _, err := r.Run(ctx, "", "mkdir", "-p", "/etc/gonka-nop"); err != nil {
+		return fmt.Errorf("create /etc/gonka-nop: %w", err)
+	}
+	if err := writeFile(ctx, r, nftRuleFile, script); err != nil {
+		return err
+	}
+	if _, err := r.Run(ctx, "", "nft", "-f", nftRuleFile); err != nil {
+		return fmt.Errorf("load nftables rules: %w", err)
+	}
+	if err := writeFile(ctx, r, nftUnitFile, nftUnit); err != nil {
+		return err
+	}
+	if _, err := r.Run(ctx, "", "systemctl", "daemon-reload"); err != nil {
+		return fmt.Errorf("systemctl daemon-reload: %w", err)
+	}
+	if _, err := r.Run(ctx, "", "systemctl", "enable", nftUnitName); err != nil {
+		return fmt.Errorf("enable %s: %w", nftUnitName, err)
+	}
+	return nil
+}
+
+// Remove implements Backend.
+func (NFTables) Remove(ctx context.Context, r Runner) error {
+	drop := fmt.Sprintf("table %s\ndelete table %s\n", nftTable, nftTable)
+	if _, err := r.Run(ctx, drop, "nft", "-f", "-"); err != nil {
+		return fmt.Errorf("delete nftables table: %w", err)
+	}
+	// The unit may never have been installed; disabling it is best effort.
+	_, _ = r.Run(ctx, "", "systemctl", "disable", nftUnitName)
+	if _, err := r.Run(ctx, "", "rm", "-f", nftUnitFile, nftRuleFile); err != nil {
+		return fmt.Errorf("remove rule files: %w", err)
+	}
+	return nil
+}
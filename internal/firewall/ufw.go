@@ -0,0 +1,141 @@
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ufwAfterRules = "/etc/ufw/after.rules"
+	ufwBlockBegin = "# BEGIN " + Tag
+	ufwBlockEnd   = "# END " + Tag
+
+	// ufwChain holds the rules. It belongs to gonka-nop, so the block may
+	// declare it and every ufw reload rebuilds it without duplicates.
+	ufwChain = Tag
+	// ufwJumpComment tags the one rule gonka-nop adds to DOCKER-USER.
+	ufwJumpComment = Tag + ":jump"
+	// ufwDockerDropIn re-adds the jump when Docker starts, since DOCKER-USER
+	// only exists from then on and does not survive a reboot.
+	ufwDockerDropIn = "/etc/systemd/system/docker.service.d/" + Tag + "-firewall.conf"
+)
+
+// ufwJumpSpec is the DOCKER-USER rule that sends traffic to ufwChain.
+var ufwJumpSpec = []string{"-m", "comment", "--comment", ufwJumpComment, "-j", ufwChain}
+
+// UFW keeps the rules in a chain of its own, declared in a marked block of
+// /etc/ufw/after.rules, and jumps to it from DOCKER-USER. Docker-published
+// ports bypass ufw's own chains, so "ufw deny" would not help. DOCKER-USER
+// itself is never declared in after.rules, which would flush it on every
+// reload along with the rules of Docker and the operator; gonka-nop only
+// inserts and deletes its tagged jump there.
+type UFW struct{}
+
+// Name implements Backend.
+func (UFW) Name() string { return "ufw" }
+
+// Render implements Backend: the block written to after.rules.
+func (UFW) Render(p *Plan) string {
+	var b strings.Builder
+	b.WriteString(ufwBlockBegin + "\n*filter\n:" + ufwChain + " - [0:0]\n")
+	for _, spec := range iptablesPlanArgs(p) {
+		b.WriteString("-A " + ufwChain + " " + strings.Join(spec, " ") + "\n")
+	}
+	b.WriteString("COMMIT\n" + ufwBlockEnd + "\n")
+	return b.String()
+}
+
+// Current implements Backend. Rules are only in effect while DOCKER-USER
+// jumps to them.
+func (UFW) Current(ctx context.Context, r Runner) (*Plan, error) {
+	out, err := r.Run(ctx, "", "iptables", "-S", "DOCKER-USER")
+	if err != nil {
+		if strings.Contains(err.Error(), "No chain") {
+			return &Plan{}, nil
+		}
+		return nil, fmt.Errorf("list DOCKER-USER rules: %w", err)
+	}
+	if !strings.Contains(out, ufwJumpComment) {
+		return &Plan{}, nil
+	}
+	rules, err := r.Run(ctx, "", "iptables", "-S", ufwChain)
+	if err != nil {
+		return nil, fmt.Errorf("list %s rules: %w", ufwChain, err)
+	}
+	return parseIPTablesPlan(rules), nil
+}
+
+// Apply implements Backend.
+func (u UFW) Apply(ctx context.Context, r Runner, p *Plan) error {
+	current, err := r.Run(ctx, "", "cat", ufwAfterRules)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", ufwAfterRules, err)
+	}
+	if err := u.rewrite(ctx, r, current, u.Render(p)); err != nil {
+		return err
+	}
+	if _, err := r.Run(ctx, "", "mkdir", "-p", filepath.Dir(ufwDockerDropIn)); err != nil {
+		return fmt.Errorf("create %s: %w", filepath.Dir(ufwDockerDropIn), err)
+	}
+	if err := writeFile(ctx, r, ufwDockerDropIn, ufwDropInContent()); err != nil {
+		return err
+	}
+	if _, err := r.Run(ctx, "", "systemctl", "daemon-reload"); err != nil {
+		return fmt.Errorf("systemctl daemon-reload: %w", err)
+	}
+	if _, err := r.Run(ctx, "", "iptables", append([]string{"-C", "DOCKER-USER"}, ufwJumpSpec...)...); err == nil {
+		return nil
+	}
+	if _, err := r.Run(ctx, "", "iptables", append([]string{"-I", "DOCKER-USER", "1"}, ufwJumpSpec...)...); err != nil {
+		return fmt.Errorf("add %s jump to DOCKER-USER: %w", ufwChain, err)
+	}
+	return nil
+}
+
+// Remove implements Backend. The chain outlives the block until it is
+// deleted, so the jump goes first, then the chain.
+func (u UFW) Remove(ctx context.Context, r Runner) error {
+	current, err := r.Run(ctx, "", "cat", ufwAfterRules)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", ufwAfterRules, err)
+	}
+	deleteDockerUserRules(ctx, r, [][]string{ufwJumpSpec})
+	// Rules and chains already gone are fine.
+	_, _ = r.Run(ctx, "", "iptables", "-F", ufwChain)
+	_, _ = r.Run(ctx, "", "iptables", "-X", ufwChain)
+	_, _ = r.Run(ctx, "", "rm", "-f", ufwDockerDropIn)
+	_, _ = r.Run(ctx, "", "systemctl", "daemon-reload")
+	return u.rewrite(ctx, r, current, "")
+}
+
+// rewrite replaces the managed block in after.rules and reloads ufw.
+func (UFW) rewrite(ctx context.Context, r Runner, current, block string) error {
+	if err := writeFile(ctx, r, ufwAfterRules, replaceBlock(current, ufwBlockBegin, ufwBlockEnd, block)); err != nil {
+		return err
+	}
+	if _, err := r.Run(ctx, "", "ufw", "reload"); err != nil {
+		return fmt.Errorf("ufw reload: %w", err)
+	}
+	return nil
+}
+
+// ufwDropInContent is the docker.service drop-in that adds the jump once
+// Docker has created DOCKER-USER. Failures are ignored ("-") so a missing
+// chain (ufw disabled) never keeps Docker from starting.
+func ufwDropInContent() string {
+	spec := strings.Join(ufwJumpSpec, " ")
+	return "# Managed by gonka-nop: jump from DOCKER-USER to the " + ufwChain + " chain in " + ufwAfterRules + ".\n" +
+		"[Service]\n" +
+		"ExecStartPost=-/bin/sh -c 'iptables -C DOCKER-USER " + spec + " 2>/dev/null || " +
+		"iptables -I DOCKER-USER 1 " + spec + "'\n"
+}
+
+// deleteDockerUserRules deletes live DOCKER-USER rules. Rules already gone
+// from the chain are fine.
+func deleteDockerUserRules(ctx context.Context, r Runner, specs [][]string) {
+	for _, spec := range specs {
+		_, _ = r.Run(ctx, "", "iptables", append([]string{"-D", "DOCKER-USER"}, spec...)...)
+	}
+}
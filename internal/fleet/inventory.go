@@ -0,0 +1,216 @@
+// Package fleet describes a set of gonka-nop hosts — one or more network
+// nodes and the GPU servers registered with them — and how to reach them.
+package fleet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/inc4/gonka-nop/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// InventoryVersion is the inventory schema version this build understands.
+const InventoryVersion = 1
+
+// DefaultInventoryFile is the inventory name looked up in the output directory.
+const DefaultInventoryFile = "fleet.yaml"
+
+// Inventory lists the hosts of a fleet.
+type Inventory struct {
+	Version int    `json:"version" yaml:"version"`
+	Hosts   []Host `json:"hosts" yaml:"hosts"`
+}
+
+// Host is one server in the fleet.
+type Host struct {
+	Name string `json:"name" yaml:"name"`
+	Type string `json:"type" yaml:"type"` // "full", "network" or "mlnode"
+
+	// SSH is the [user@]host used to run commands on the server; empty
+	// means this machine. Dir is the gonka-nop output directory there.
+	SSH     string `json:"ssh,omitempty" yaml:"ssh,omitempty"`
+	SSHPort int    `json:"ssh_port,omitempty" yaml:"ssh_port,omitempty"`
+	Dir     string `json:"dir,omitempty" yaml:"dir,omitempty"`
+
+	// Network and full hosts: where their APIs are reachable from here.
+	AdminURL string `json:"admin_url,omitempty" yaml:"admin_url,omitempty"`
+	RPCURL   string `json:"rpc_url,omitempty" yaml:"rpc_url,omitempty"`
+
+	// ML and full hosts: the node ID registered with 'ml-node add' and, for
+	// mlnode hosts, the name of the network host it is registered with
+	// (optional when the fleet has exactly one).
+	NodeID      string `json:"node_id,omitempty" yaml:"node_id,omitempty"`
+	NetworkNode string `json:"network_node,omitempty" yaml:"network_node,omitempty"`
+}
+
+// IsNetwork reports whether the host runs chain services and the Admin API.
+func (h *Host) IsNetwork() bool {
+	return h.Type == config.NodeTypeNetwork || h.Type == config.NodeTypeFull
+}
+
+// IsMLNode reports whether the host runs an ML node.
+func (h *Host) IsMLNode() bool {
+	return h.Type == config.NodeTypeMLNode || h.Type == config.NodeTypeFull
+}
+
+// LoadInventory reads and validates an inventory. Files ending in .json are
+// parsed as JSON, everything else as YAML. Unknown fields are rejected.
+func LoadInventory(path string) (*Inventory, error) {
+	data, err := os.ReadFile(filepath.Clean(path)) // #nosec G304 - path from CLI flag
+	if err != nil {
+		return nil, fmt.Errorf("read fleet inventory: %w", err)
+	}
+
+	var inv Inventory
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&inv)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&inv)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse fleet inventory: %w", err)
+	}
+
+	inv.applyDefaults()
+	if err := inv.Validate(); err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// applyDefaults fills in the node ID and output directory most hosts use.
+func (inv *Inventory) applyDefaults() {
+	for i := range inv.Hosts {
+		h := &inv.Hosts[i]
+		if h.Type == config.NodeTypeFull && h.NodeID == "" {
+			h.NodeID = "node1"
+		}
+		if h.Dir == "" {
+			h.Dir = "./gonka-node"
+		}
+	}
+}
+
+// Validate checks every host and reports all problems at once.
+func (inv *Inventory) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if inv.Version != InventoryVersion {
+		add("version: unsupported version %d (this gonka-nop supports %d)", inv.Version, InventoryVersion)
+	}
+	if len(inv.Hosts) == 0 {
+		add("hosts: at least one host is required")
+	}
+
+	seen := make(map[string]bool, len(inv.Hosts))
+	for i := range inv.Hosts {
+		inv.validateHost(add, fmt.Sprintf("hosts[%d]", i), &inv.Hosts[i], seen)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid fleet inventory (%d error(s)):\n  %s", len(problems), strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func (inv *Inventory) validateHost(add func(string, ...interface{}), field string, h *Host, seen map[string]bool) {
+	if h.Name == "" {
+		add("%s.name: required", field)
+	} else if seen[h.Name] {
+		add("%s.name: duplicate host %q", field, h.Name)
+	}
+	seen[h.Name] = true
+
+	if !h.IsNetwork() && !h.IsMLNode() {
+		add("%s.type: %q is not one of full, network, mlnode", field, h.Type)
+		return
+	}
+	if h.IsNetwork() {
+		checkURL(add, field+".admin_url", h.AdminURL)
+		checkURL(add, field+".rpc_url", h.RPCURL)
+	}
+	if h.Type == config.NodeTypeMLNode {
+		if h.NodeID == "" {
+			add("%s.node_id: required for mlnode hosts", field)
+		}
+		if _, err := inv.NetworkFor(h); err != nil {
+			add("%s.network_node: %v", field, err)
+		}
+	}
+	if h.SSHPort < 0 || h.SSHPort > 65535 {
+		add("%s.ssh_port: %d is not a valid port", field, h.SSHPort)
+	}
+}
+
+// NetworkFor returns the network host an ML node is registered with. A full
+// host is its own network node.
+func (inv *Inventory) NetworkFor(h *Host) (*Host, error) {
+	if h.IsNetwork() {
+		return h, nil
+	}
+	var candidates []*Host
+	for i := range inv.Hosts {
+		n := &inv.Hosts[i]
+		if !n.IsNetwork() {
+			continue
+		}
+		if h.NetworkNode == "" || n.Name == h.NetworkNode {
+			candidates = append(candidates, n)
+		}
+	}
+	switch {
+	case len(candidates) == 1:
+		return candidates[0], nil
+	case h.NetworkNode != "":
+		return nil, fmt.Errorf("network host %q not found", h.NetworkNode)
+	case len(candidates) == 0:
+		return nil, fmt.Errorf("no network or full host in the inventory")
+	default:
+		return nil, fmt.Errorf("several network hosts — set network_node")
+	}
+}
+
+// MLNodes returns the hosts running an ML node, in inventory order.
+func (inv *Inventory) MLNodes() []*Host {
+	var nodes []*Host
+	for i := range inv.Hosts {
+		if inv.Hosts[i].IsMLNode() {
+			nodes = append(nodes, &inv.Hosts[i])
+		}
+	}
+	return nodes
+}
+
+// Host returns the host with the given name.
+func (inv *Inventory) Host(name string) (*Host, bool) {
+	for i := range inv.Hosts {
+		if inv.Hosts[i].Name == name {
+			return &inv.Hosts[i], true
+		}
+	}
+	return nil, false
+}
+
+func checkURL(add func(string, ...interface{}), field, value string) {
+	if value == "" {
+		add("%s: required for network and full hosts", field)
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("%s: %q is not an http(s) URL", field, value)
+	}
+}
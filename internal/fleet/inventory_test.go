@@ -0,0 +1,135 @@
+package fleet
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testInventory = `version: 1
+hosts:
+  - name: network-1
+    type: network
+    admin_url: http://10.0.1.100:9200
+    rpc_url: http://10.0.1.100:26657
+  - name: gpu-1
+    type: mlnode
+    ssh: root@10.0.1.11
+    dir: /opt/gonka-node
+    node_id: gpu1
+  - name: gpu-2
+    type: mlnode
+    ssh: root@10.0.1.12
+    node_id: gpu2
+    network_node: network-1
+`
+
+func writeInventory(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("write inventory: %v", err)
+	}
+	return path
+}
+
+func TestLoadInventory(t *testing.T) {
+	inv, err := LoadInventory(writeInventory(t, "fleet.yaml", testInventory))
+	if err != nil {
+		t.Fatalf("LoadInventory() error: %v", err)
+	}
+	if len(inv.Hosts) != 3 {
+		t.Fatalf("hosts = %d, want 3", len(inv.Hosts))
+	}
+
+	ml := inv.MLNodes()
+	if len(ml) != 2 || ml[0].Name != "gpu-1" || ml[1].Name != "gpu-2" {
+		t.Errorf("MLNodes() = %v, want gpu-1, gpu-2", ml)
+	}
+	if ml[1].Dir != "./gonka-node" {
+		t.Errorf("default dir = %q, want ./gonka-node", ml[1].Dir)
+	}
+	network, err := inv.NetworkFor(ml[0])
+	if err != nil || network.Name != "network-1" {
+		t.Errorf("NetworkFor(gpu-1) = %v, %v; want network-1", network, err)
+	}
+}
+
+func TestLoadInventory_FullHostDefaults(t *testing.T) {
+	path := writeInventory(t, "fleet.json", `{"version": 1, "hosts": [
+		{"name": "box", "type": "full", "admin_url": "http://127.0.0.1:9200", "rpc_url": "http://127.0.0.1:26657"}]}`)
+	inv, err := LoadInventory(path)
+	if err != nil {
+		t.Fatalf("LoadInventory() error: %v", err)
+	}
+	h := &inv.Hosts[0]
+	if h.NodeID != "node1" {
+		t.Errorf("full host node_id = %q, want node1", h.NodeID)
+	}
+	if n, _ := inv.NetworkFor(h); n != h {
+		t.Error("a full host should be its own network node")
+	}
+}
+
+func TestLoadInventory_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "unknown field",
+			content: "version: 1\nhosts:\n  - name: a\n    type: network\n    adminurl: x\n",
+			want:    []string{"parse fleet inventory"},
+		},
+		{
+			name:    "version and empty",
+			content: "version: 2\n",
+			want:    []string{"unsupported version 2", "at least one host"},
+		},
+		{
+			name: "host errors",
+			content: `version: 1
+hosts:
+  - name: n
+    type: network
+    admin_url: localhost:9200
+  - name: n
+    type: gpu
+  - name: g
+    type: mlnode
+    network_node: other
+`,
+			want: []string{
+				"hosts[0].admin_url", "hosts[0].rpc_url: required",
+				"hosts[1].name: duplicate", "hosts[1].type",
+				"hosts[2].node_id: required", `network host "other" not found`,
+			},
+		},
+		{
+			name: "ambiguous network",
+			content: `version: 1
+hosts:
+  - {name: n1, type: network, admin_url: "http://a:9200", rpc_url: "http://a:26657"}
+  - {name: n2, type: network, admin_url: "http://b:9200", rpc_url: "http://b:26657"}
+  - {name: g, type: mlnode, node_id: g}
+`,
+			want: []string{"several network hosts"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadInventory(writeInventory(t, "fleet.yaml", tt.content))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("error %q does not mention %q", err, w)
+				}
+			}
+		})
+	}
+}
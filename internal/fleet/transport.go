@@ -0,0 +1,64 @@
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Transport runs a command on a fleet host and returns its stdout.
+type Transport interface {
+	Run(ctx context.Context, h *Host, argv []string) (string, error)
+}
+
+// SSHTransport runs commands over ssh in batch mode (key-based auth only,
+// never a password prompt). Hosts without an SSH target run locally.
+type SSHTransport struct {
+	Options []string // extra ssh options, e.g. "-i", "~/.ssh/fleet"
+}
+
+// Run implements Transport.
+func (t SSHTransport) Run(ctx context.Context, h *Host, argv []string) (string, error) {
+	if len(argv) == 0 {
+		return "", fmt.Errorf("%s: empty command", h.Name)
+	}
+	var cmd *exec.Cmd
+	if h.SSH == "" {
+		cmd = exec.CommandContext(ctx, argv[0], argv[1:]...) // #nosec G204 - argv, no shell
+	} else {
+		cmd = exec.CommandContext(ctx, "ssh", t.sshArgs(h, argv)...) // #nosec G204 - remote argv is quoted
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.String(), fmt.Errorf("%s: %s: %w\n%s", h.Name, argv[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// sshArgs builds: ssh -o BatchMode=yes [-p port] [options] <target> -- <quoted argv>.
+// ssh hands the remote command to the login shell as one string, so every
+// argument is single-quoted.
+func (t SSHTransport) sshArgs(h *Host, argv []string) []string {
+	args := make([]string, 0, 6+len(t.Options)+len(argv))
+	args = append(args, "-o", "BatchMode=yes")
+	if h.SSHPort != 0 {
+		args = append(args, "-p", strconv.Itoa(h.SSHPort))
+	}
+	args = append(args, t.Options...)
+	args = append(args, h.SSH, "--")
+	for _, a := range argv {
+		args = append(args, quoteArg(a))
+	}
+	return args
+}
+
+// quoteArg wraps s in single quotes for a POSIX shell.
+func quoteArg(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "'\\''") + "'"
+}
@@ -0,0 +1,32 @@
+package fleet
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSSHArgs(t *testing.T) {
+	tr := SSHTransport{Options: []string{"-i", "/root/.ssh/fleet"}}
+	h := &Host{Name: "gpu-1", SSH: "root@10.0.1.11", SSHPort: 2222}
+
+	got := strings.Join(tr.sshArgs(h, []string{"gonka-nop", "update", "-o", "/opt/my node", "it's"}), " ")
+	want := `-o BatchMode=yes -p 2222 -i /root/.ssh/fleet root@10.0.1.11 -- 'gonka-nop' 'update' '-o' '/opt/my node' 'it'\''s'`
+	if got != want {
+		t.Errorf("sshArgs() =\n  %s\nwant\n  %s", got, want)
+	}
+}
+
+func TestSSHTransport_Local(t *testing.T) {
+	out, err := SSHTransport{}.Run(context.Background(), &Host{Name: "local"}, []string{"echo", "hello; world"})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if strings.TrimSpace(out) != "hello; world" {
+		t.Errorf("Run() = %q, want the argument untouched", out)
+	}
+
+	if _, err := (SSHTransport{}).Run(context.Background(), &Host{Name: "local"}, nil); err == nil {
+		t.Error("Run() with an empty command should fail")
+	}
+}
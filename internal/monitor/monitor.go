@@ -0,0 +1,119 @@
+package monitor
+
+import (
+	"sort"
+	"time"
+
+	"github.com/inc4/gonka-nop/internal/status"
+)
+
+// Notification states.
+const (
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+// Notification is one message delivered to the notifiers.
+type Notification struct {
+	Node     string    `json:"node"`
+	State    string    `json:"state"`
+	Rule     string    `json:"rule"`
+	Severity string    `json:"severity"`
+	Message  string    `json:"message"`
+	Since    time.Time `json:"since"`
+	Time     time.Time `json:"time"`
+}
+
+// activeAlert is an alert that fired and has not resolved yet.
+type activeAlert struct {
+	Alert
+	since    time.Time
+	notified time.Time
+}
+
+// Monitor evaluates rules on each snapshot and de-duplicates alerts: a rule
+// notifies once when it starts firing, again only after RepeatInterval (if
+// set), and once more when it resolves.
+type Monitor struct {
+	Node           string
+	Thresholds     Thresholds
+	RepeatInterval time.Duration
+
+	rules  ruleState
+	active map[string]*activeAlert
+}
+
+// New creates a monitor for the named node.
+func New(node string, t Thresholds) *Monitor {
+	return &Monitor{Node: node, Thresholds: t, active: make(map[string]*activeAlert)}
+}
+
+// Observe evaluates a snapshot and returns the notifications to send.
+func (m *Monitor) Observe(s *status.NodeStatus, now time.Time) []Notification {
+	firing := make(map[string]Alert)
+	for _, a := range m.rules.evaluate(s, m.Thresholds, now) {
+		firing[a.Rule] = a
+	}
+
+	var out []Notification
+	for _, rule := range sortedKeys(firing) {
+		a := firing[rule]
+		cur, ok := m.active[rule]
+		switch {
+		case !ok:
+			cur = &activeAlert{Alert: a, since: now, notified: now}
+			m.active[rule] = cur
+			out = append(out, m.notification(StateFiring, cur, now))
+		case a.Severity != cur.Severity:
+			// Escalation or de-escalation is news; message churn is not
+			cur.Alert = a
+			cur.notified = now
+			out = append(out, m.notification(StateFiring, cur, now))
+		default:
+			cur.Alert = a
+			if m.RepeatInterval > 0 && now.Sub(cur.notified) >= m.RepeatInterval {
+				cur.notified = now
+				out = append(out, m.notification(StateFiring, cur, now))
+			}
+		}
+	}
+
+	for _, rule := range sortedKeys(m.active) {
+		if _, ok := firing[rule]; ok {
+			continue
+		}
+		out = append(out, m.notification(StateResolved, m.active[rule], now))
+		delete(m.active, rule)
+	}
+	return out
+}
+
+// Active returns the currently firing alerts, sorted by rule.
+func (m *Monitor) Active() []Alert {
+	alerts := make([]Alert, 0, len(m.active))
+	for _, rule := range sortedKeys(m.active) {
+		alerts = append(alerts, m.active[rule].Alert)
+	}
+	return alerts
+}
+
+func (m *Monitor) notification(state string, a *activeAlert, now time.Time) Notification {
+	return Notification{
+		Node:     m.Node,
+		State:    state,
+		Rule:     a.Rule,
+		Severity: a.Severity,
+		Message:  a.Message,
+		Since:    a.since,
+		Time:     now,
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,237 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/inc4/gonka-nop/internal/status"
+)
+
+func healthyStatus() *status.NodeStatus {
+	s := &status.NodeStatus{}
+	s.Overview.ContainersRunning = 3
+	s.Blockchain.BlockHeight = 22250
+	s.Blockchain.Synced = true
+	s.Blockchain.PeerCount = 12
+	s.Blockchain.PeerCountKnown = true
+	s.Epoch.EpochNumber = 62
+	s.Epoch.TotalCount = 200
+	s.Epoch.MissedCount = 2
+	s.Epoch.MissPercentage = 1.0
+	s.Epoch.PrevEpochIndex = 61
+	s.Epoch.PrevEpochClaimed = true
+	s.MLNode.PoCStatus = "INFERENCE"
+	s.MLNode.IntendedStatus = "INFERENCE"
+	return s
+}
+
+func rules(alerts []Alert) map[string]Alert {
+	m := make(map[string]Alert, len(alerts))
+	for _, a := range alerts {
+		m[a.Rule] = a
+	}
+	return m
+}
+
+func TestEvaluate_Healthy(t *testing.T) {
+	var r ruleState
+	if alerts := r.evaluate(healthyStatus(), DefaultThresholds(), time.Now()); len(alerts) != 0 {
+		t.Errorf("expected no alerts, got %+v", alerts)
+	}
+}
+
+func TestEvaluate_FellBehindOnlyAfterCatchingUp(t *testing.T) {
+	th := DefaultThresholds()
+	now := time.Now()
+	behind := healthyStatus()
+	behind.Blockchain.Synced = false
+	behind.Blockchain.CatchingUp = true
+	behind.Blockchain.BlockLag = 500
+
+	var r ruleState
+	if _, ok := rules(r.evaluate(behind, th, now))[RuleFellBehind]; ok {
+		t.Error("initial sync should not fire fell_behind")
+	}
+	r.evaluate(healthyStatus(), th, now)
+	if _, ok := rules(r.evaluate(behind, th, now))[RuleFellBehind]; !ok {
+		t.Error("expected fell_behind after the node had caught up")
+	}
+}
+
+func TestEvaluate_Thresholds(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutate   func(s *status.NodeStatus)
+		rule     string
+		severity string
+	}{
+		{
+			name:     "low peers",
+			mutate:   func(s *status.NodeStatus) { s.Blockchain.PeerCount = 3 },
+			rule:     RuleLowPeers,
+			severity: SeverityWarning,
+		},
+		{
+			name:     "no peers",
+			mutate:   func(s *status.NodeStatus) { s.Blockchain.PeerCount = 0 },
+			rule:     RuleLowPeers,
+			severity: SeverityCritical,
+		},
+		{
+			name:     "miss rate",
+			mutate:   func(s *status.NodeStatus) { s.Epoch.MissPercentage = 7.5 },
+			rule:     RuleMissRate,
+			severity: SeverityCritical,
+		},
+		{
+			name: "upgrade approaching",
+			mutate: func(s *status.NodeStatus) {
+				s.NodeConfig.UpgradeName = "v0.2.11"
+				s.NodeConfig.UpgradeHeight = 22500
+			},
+			rule:     RuleUpgradePending,
+			severity: SeverityWarning,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := healthyStatus()
+			tt.mutate(s)
+			var r ruleState
+			a, ok := rules(r.evaluate(s, DefaultThresholds(), time.Now()))[tt.rule]
+			if !ok {
+				t.Fatalf("expected %s to fire", tt.rule)
+			}
+			if a.Severity != tt.severity {
+				t.Errorf("severity = %s, want %s", a.Severity, tt.severity)
+			}
+		})
+	}
+}
+
+func TestEvaluate_UpgradeFarAwayOrPassed(t *testing.T) {
+	for _, height := range []int64{50000, 22000} {
+		s := healthyStatus()
+		s.NodeConfig.UpgradeHeight = height
+		var r ruleState
+		if _, ok := rules(r.evaluate(s, DefaultThresholds(), time.Now()))[RuleUpgradePending]; ok {
+			t.Errorf("upgrade at %d should not fire at height %d", height, s.Blockchain.BlockHeight)
+		}
+	}
+}
+
+func TestEvaluate_PoCMismatchDuration(t *testing.T) {
+	th := DefaultThresholds()
+	start := time.Now()
+	s := healthyStatus()
+	s.MLNode.PoCStatus = "POC"
+
+	var r ruleState
+	if _, ok := rules(r.evaluate(s, th, start))[RulePoCMismatch]; ok {
+		t.Error("mismatch should not fire immediately")
+	}
+	if _, ok := rules(r.evaluate(s, th, start.Add(th.PoCMismatchFor)))[RulePoCMismatch]; !ok {
+		t.Error("expected mismatch to fire after PoCMismatchFor")
+	}
+
+	// Clearing resets the timer
+	r.evaluate(healthyStatus(), th, start.Add(th.PoCMismatchFor+time.Minute))
+	if _, ok := rules(r.evaluate(s, th, start.Add(th.PoCMismatchFor+2*time.Minute)))[RulePoCMismatch]; ok {
+		t.Error("mismatch timer should restart after recovery")
+	}
+}
+
+func TestEvaluate_RewardUnclaimed(t *testing.T) {
+	th := DefaultThresholds()
+	start := time.Now()
+	s := healthyStatus()
+	s.Epoch.PrevEpochClaimed = false
+
+	var r ruleState
+	if _, ok := rules(r.evaluate(s, th, start))[RuleRewardUnclaimed]; ok {
+		t.Error("unclaimed reward should get a grace period")
+	}
+	if _, ok := rules(r.evaluate(s, th, start.Add(th.ClaimGrace)))[RuleRewardUnclaimed]; !ok {
+		t.Error("expected reward_unclaimed after the grace period")
+	}
+
+	// A new epoch ending restarts the grace period
+	s.Epoch.PrevEpochIndex = 62
+	if _, ok := rules(r.evaluate(s, th, start.Add(th.ClaimGrace+time.Minute)))[RuleRewardUnclaimed]; ok {
+		t.Error("grace period should restart for a new epoch")
+	}
+}
+
+func TestEvaluate_Unreachable(t *testing.T) {
+	th := DefaultThresholds()
+	down := &status.NodeStatus{}
+
+	var r ruleState
+	if alerts := r.evaluate(down, th, time.Now()); len(alerts) != 0 {
+		t.Errorf("first unreachable poll should not fire, got %+v", alerts)
+	}
+	alerts := r.evaluate(down, th, time.Now())
+	if len(alerts) != 1 || alerts[0].Rule != RuleUnreachable {
+		t.Errorf("expected only node_unreachable, got %+v", alerts)
+	}
+}
+
+func TestEvaluate_MLNodeOnly(t *testing.T) {
+	s := &status.NodeStatus{}
+	s.Overview.OverallStatus = mlnodeOnlyStatus
+	var r ruleState
+	if alerts := r.evaluate(s, DefaultThresholds(), time.Now()); len(alerts) != 0 {
+		t.Errorf("ML-node-only hosts should not alert, got %+v", alerts)
+	}
+}
+
+func TestMonitor_DedupAndRecovery(t *testing.T) {
+	m := New("node-1", DefaultThresholds())
+	start := time.Now()
+	low := healthyStatus()
+	low.Blockchain.PeerCount = 3
+
+	n := m.Observe(low, start)
+	if len(n) != 1 || n[0].State != StateFiring || n[0].Rule != RuleLowPeers || n[0].Node != "node-1" {
+		t.Fatalf("first observation = %+v", n)
+	}
+
+	// Still firing with a different message: suppressed
+	low.Blockchain.PeerCount = 4
+	if n := m.Observe(low, start.Add(time.Minute)); len(n) != 0 {
+		t.Errorf("expected duplicate to be suppressed, got %+v", n)
+	}
+	if active := m.Active(); len(active) != 1 || active[0].Rule != RuleLowPeers {
+		t.Errorf("Active() = %+v", active)
+	}
+
+	// Severity change is re-sent
+	low.Blockchain.PeerCount = 0
+	if n := m.Observe(low, start.Add(2*time.Minute)); len(n) != 1 || n[0].Severity != SeverityCritical {
+		t.Errorf("expected escalation, got %+v", n)
+	}
+
+	n = m.Observe(healthyStatus(), start.Add(5*time.Minute))
+	if len(n) != 1 || n[0].State != StateResolved || !n[0].Since.Equal(start) {
+		t.Fatalf("expected recovery, got %+v", n)
+	}
+	if len(m.Active()) != 0 {
+		t.Error("no alerts should be active after recovery")
+	}
+}
+
+func TestMonitor_RepeatInterval(t *testing.T) {
+	m := New("node-1", DefaultThresholds())
+	m.RepeatInterval = time.Hour
+	start := time.Now()
+	low := healthyStatus()
+	low.Blockchain.PeerCount = 3
+
+	m.Observe(low, start)
+	if n := m.Observe(low, start.Add(30*time.Minute)); len(n) != 0 {
+		t.Errorf("expected no repeat before the interval, got %+v", n)
+	}
+	if n := m.Observe(low, start.Add(time.Hour)); len(n) != 1 || n[0].State != StateFiring {
+		t.Errorf("expected repeat after the interval, got %+v", n)
+	}
+}
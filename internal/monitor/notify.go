@@ -0,0 +1,126 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	notifyTimeout      = 10 * time.Second
+	defaultTelegramAPI = "https://api.telegram.org"
+)
+
+// Notifier delivers notifications to an external channel.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, n Notification) error
+}
+
+// WebhookNotifier POSTs the notification as JSON to an arbitrary URL.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// Name implements Notifier.
+func (w *WebhookNotifier) Name() string { return "webhook" }
+
+// Notify implements Notifier.
+func (w *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	return postJSON(ctx, w.Client, w.URL, n)
+}
+
+// SlackNotifier posts to a Slack incoming webhook.
+type SlackNotifier struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+// Name implements Notifier.
+func (s *SlackNotifier) Name() string { return "slack" }
+
+// Notify implements Notifier.
+func (s *SlackNotifier) Notify(ctx context.Context, n Notification) error {
+	return postJSON(ctx, s.Client, s.WebhookURL, map[string]string{"text": FormatText(n)})
+}
+
+// TelegramNotifier sends messages through the Telegram Bot API.
+type TelegramNotifier struct {
+	Token  string
+	ChatID string
+	APIURL string // defaults to https://api.telegram.org
+	Client *http.Client
+}
+
+// Name implements Notifier.
+func (t *TelegramNotifier) Name() string { return "telegram" }
+
+// Notify implements Notifier.
+func (t *TelegramNotifier) Notify(ctx context.Context, n Notification) error {
+	api := t.APIURL
+	if api == "" {
+		api = defaultTelegramAPI
+	}
+	url := strings.TrimRight(api, "/") + "/bot" + t.Token + "/sendMessage"
+	err := postJSON(ctx, t.Client, url, map[string]string{"chat_id": t.ChatID, "text": FormatText(n)})
+	if err != nil {
+		// Never leak the bot token through error messages
+		return errors.New(strings.ReplaceAll(err.Error(), t.Token, "<token>"))
+	}
+	return nil
+}
+
+// FormatText renders a notification as a short human-readable message.
+func FormatText(n Notification) string {
+	if n.State == StateResolved {
+		return fmt.Sprintf("RESOLVED [%s] %s: %s (firing for %s)",
+			n.Node, n.Rule, n.Message, n.Time.Sub(n.Since).Round(time.Second))
+	}
+	return fmt.Sprintf("%s [%s] %s: %s", strings.ToUpper(n.Severity), n.Node, n.Rule, n.Message)
+}
+
+// Dispatch sends n to every notifier and joins the delivery errors.
+func Dispatch(ctx context.Context, notifiers []Notifier, n Notification) error {
+	var errs []error
+	for _, nt := range notifiers {
+		if err := nt.Notify(ctx, n); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", nt.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: notifyTimeout}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package monitor
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testNotification() Notification {
+	now := time.Now()
+	return Notification{
+		Node:     "node-1",
+		State:    StateFiring,
+		Rule:     RuleLowPeers,
+		Severity: SeverityWarning,
+		Message:  "Only 3 peers connected (minimum 5)",
+		Since:    now,
+		Time:     now,
+	}
+}
+
+// captureServer records the last request path and JSON body.
+func captureServer(t *testing.T, status int) (*httptest.Server, *string, *map[string]interface{}) {
+	t.Helper()
+	var path string
+	body := map[string]interface{}{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &body)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &path, &body
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	ts, _, body := captureServer(t, http.StatusOK)
+	w := &WebhookNotifier{URL: ts.URL}
+	if err := w.Notify(t.Context(), testNotification()); err != nil {
+		t.Fatalf("Notify() error: %v", err)
+	}
+	if (*body)["rule"] != RuleLowPeers || (*body)["state"] != StateFiring || (*body)["node"] != "node-1" {
+		t.Errorf("payload = %v", *body)
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	ts, _, body := captureServer(t, http.StatusOK)
+	s := &SlackNotifier{WebhookURL: ts.URL}
+	if err := s.Notify(t.Context(), testNotification()); err != nil {
+		t.Fatalf("Notify() error: %v", err)
+	}
+	text, _ := (*body)["text"].(string)
+	if !strings.Contains(text, "WARNING [node-1] low_peers") {
+		t.Errorf("text = %q", text)
+	}
+}
+
+func TestTelegramNotifier(t *testing.T) {
+	ts, path, body := captureServer(t, http.StatusOK)
+	tg := &TelegramNotifier{Token: "123:secret", ChatID: "-100", APIURL: ts.URL}
+	if err := tg.Notify(t.Context(), testNotification()); err != nil {
+		t.Fatalf("Notify() error: %v", err)
+	}
+	if *path != "/bot123:secret/sendMessage" {
+		t.Errorf("path = %q", *path)
+	}
+	if (*body)["chat_id"] != "-100" {
+		t.Errorf("chat_id = %v", (*body)["chat_id"])
+	}
+}
+
+func TestTelegramNotifier_ErrorHidesToken(t *testing.T) {
+	tg := &TelegramNotifier{Token: "123:secret", ChatID: "-100", APIURL: "http://127.0.0.1:1"}
+	err := tg.Notify(t.Context(), testNotification())
+	if err == nil {
+		t.Fatal("expected error for unreachable API")
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("error leaks token: %v", err)
+	}
+}
+
+func TestDispatch_JoinsErrors(t *testing.T) {
+	ok, _, _ := captureServer(t, http.StatusOK)
+	bad, _, _ := captureServer(t, http.StatusInternalServerError)
+	notifiers := []Notifier{
+		&WebhookNotifier{URL: ok.URL},
+		&SlackNotifier{WebhookURL: bad.URL},
+	}
+	err := Dispatch(t.Context(), notifiers, testNotification())
+	if err == nil || !strings.Contains(err.Error(), "slack: HTTP 500") {
+		t.Errorf("Dispatch() error = %v, want slack HTTP 500", err)
+	}
+}
+
+func TestFormatText_Resolved(t *testing.T) {
+	n := testNotification()
+	n.State = StateResolved
+	n.Time = n.Since.Add(90 * time.Second)
+	got := FormatText(n)
+	if !strings.HasPrefix(got, "RESOLVED [node-1] low_peers") || !strings.Contains(got, "1m30s") {
+		t.Errorf("FormatText() = %q", got)
+	}
+}
@@ -0,0 +1,164 @@
+// Package monitor evaluates alert rules against successive node status
+// snapshots and delivers firing and recovery notifications.
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/inc4/gonka-nop/internal/status"
+)
+
+// Alert severities.
+const (
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Rule keys. A key identifies an alert across snapshots for de-duplication.
+const (
+	RuleUnreachable     = "node_unreachable"
+	RuleFellBehind      = "fell_behind"
+	RuleLowPeers        = "low_peers"
+	RuleMissRate        = "miss_rate"
+	RulePoCMismatch     = "poc_status_mismatch"
+	RuleRewardUnclaimed = "reward_unclaimed"
+	RuleUpgradePending  = "upgrade_approaching"
+)
+
+// mlnodeOnlyStatus is reported by FetchStatusWithConfig on ML-node-only hosts.
+const mlnodeOnlyStatus = "MLNODE-ONLY"
+
+// Thresholds configures when rules fire.
+type Thresholds struct {
+	MaxBlockLag        int64         // blocks behind before a synced node counts as fallen behind
+	MinPeers           int           // fire when connected peers drop below this
+	MaxMissPercent     float64       // fire when the epoch miss rate exceeds this
+	PoCMismatchFor     time.Duration // how long current != intended status may last
+	ClaimGrace         time.Duration // how long the previous epoch may stay unclaimed
+	UpgradeWarnBlocks  int64         // fire when a scheduled upgrade is this close
+	UnreachableRetries int           // consecutive unreachable polls before firing
+}
+
+// DefaultThresholds returns thresholds matching the status display highlights.
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		MaxBlockLag:        10,
+		MinPeers:           5,
+		MaxMissPercent:     5.0,
+		PoCMismatchFor:     10 * time.Minute,
+		ClaimGrace:         30 * time.Minute,
+		UpgradeWarnBlocks:  1000,
+		UnreachableRetries: 2,
+	}
+}
+
+// Alert is a rule that currently fires.
+type Alert struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// ruleState carries what rules need to remember between snapshots.
+type ruleState struct {
+	caughtUp         bool      // node has been seen synced since the monitor started
+	unreachableCount int       // consecutive polls with core services unreachable
+	mismatchSince    time.Time // when current != intended status was first seen
+	unclaimedEpoch   int       // previous epoch index seen unclaimed
+	unclaimedSince   time.Time // when unclaimedEpoch was first seen
+}
+
+// evaluate runs all rules against one snapshot and returns the firing alerts.
+func (r *ruleState) evaluate(s *status.NodeStatus, t Thresholds, now time.Time) []Alert {
+	var alerts []Alert
+	add := func(rule, severity, format string, args ...interface{}) {
+		alerts = append(alerts, Alert{Rule: rule, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.Overview.OverallStatus == mlnodeOnlyStatus {
+		// Chain rules belong to the network node's monitor
+		return nil
+	}
+
+	if s.Overview.ContainersRunning == 0 {
+		r.unreachableCount++
+		if r.unreachableCount >= t.UnreachableRetries {
+			add(RuleUnreachable, SeverityCritical, "Core services unreachable for %d consecutive polls", r.unreachableCount)
+		}
+		// Every other rule would fire on zero values
+		return alerts
+	}
+	r.unreachableCount = 0
+
+	r.evaluateChain(s, t, add)
+	r.evaluateEpoch(s, t, now, add)
+	r.evaluateMLNode(s, t, now, add)
+	return alerts
+}
+
+type addFunc func(rule, severity, format string, args ...interface{})
+
+func (r *ruleState) evaluateChain(s *status.NodeStatus, t Thresholds, add addFunc) {
+	b := s.Blockchain
+	behind := b.CatchingUp || !b.Synced || b.BlockLag > t.MaxBlockLag
+	if !behind {
+		r.caughtUp = true
+	} else if r.caughtUp {
+		// Initial sync is expected to be behind; only regressions alert
+		add(RuleFellBehind, SeverityCritical, "Node fell behind after catching up: %d blocks behind (height %d, catching up: %v)",
+			b.BlockLag, b.BlockHeight, b.CatchingUp)
+	}
+
+	if b.PeerCountKnown && b.PeerCount < t.MinPeers {
+		severity := SeverityWarning
+		if b.PeerCount == 0 {
+			severity = SeverityCritical
+		}
+		add(RuleLowPeers, severity, "Only %d peers connected (minimum %d)", b.PeerCount, t.MinPeers)
+	}
+
+	if up := s.NodeConfig.UpgradeHeight; up > 0 && b.BlockHeight > 0 && up > b.BlockHeight && up-b.BlockHeight <= t.UpgradeWarnBlocks {
+		name := s.NodeConfig.UpgradeName
+		if name == "" {
+			name = "chain upgrade"
+		}
+		add(RuleUpgradePending, SeverityWarning, "Upgrade %s at height %d is %d blocks away", name, up, up-b.BlockHeight)
+	}
+}
+
+func (r *ruleState) evaluateEpoch(s *status.NodeStatus, t Thresholds, now time.Time, add addFunc) {
+	e := s.Epoch
+	if e.TotalCount > 0 && e.MissPercentage > t.MaxMissPercent {
+		add(RuleMissRate, SeverityCritical, "Miss rate %.1f%% in epoch %d (%d/%d missed, threshold %.1f%%)",
+			e.MissPercentage, e.EpochNumber, e.MissedCount, e.TotalCount, t.MaxMissPercent)
+	}
+
+	if e.PrevEpochIndex <= 0 || e.PrevEpochClaimed {
+		r.unclaimedEpoch = 0
+		return
+	}
+	if r.unclaimedEpoch != e.PrevEpochIndex {
+		r.unclaimedEpoch = e.PrevEpochIndex
+		r.unclaimedSince = now
+	}
+	if unclaimed := now.Sub(r.unclaimedSince); unclaimed >= t.ClaimGrace {
+		add(RuleRewardUnclaimed, SeverityCritical, "Reward for epoch %d still unclaimed after %s",
+			e.PrevEpochIndex, unclaimed.Round(time.Minute))
+	}
+}
+
+func (r *ruleState) evaluateMLNode(s *status.NodeStatus, t Thresholds, now time.Time, add addFunc) {
+	ml := s.MLNode
+	if ml.IntendedStatus == "" || ml.PoCStatus == "" || ml.IntendedStatus == ml.PoCStatus {
+		r.mismatchSince = time.Time{}
+		return
+	}
+	if r.mismatchSince.IsZero() {
+		r.mismatchSince = now
+	}
+	if stuck := now.Sub(r.mismatchSince); stuck >= t.PoCMismatchFor {
+		add(RulePoCMismatch, SeverityCritical, "ML node stuck in %s (intended %s) for %s",
+			ml.PoCStatus, ml.IntendedStatus, stuck.Round(time.Minute))
+	}
+}
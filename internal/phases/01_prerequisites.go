@@ -3,7 +3,6 @@ package phases
 import (
 	"context"
 	"fmt"
-	"net"
 	"os"
 	"os/exec"
 	"strings"
@@ -19,12 +18,13 @@ const cmdTimeout = 15 * time.Second
 // Prerequisites checks Docker, NVIDIA, disk, and system requirements.
 type Prerequisites struct {
 	mocked bool
+	host   *Preflight // detection, shared with the preflight command
 }
 
 // NewPrerequisites creates a new Prerequisites phase.
 // When mocked is true, all checks use hardcoded demo values.
 func NewPrerequisites(mocked bool) *Prerequisites {
-	return &Prerequisites{mocked: mocked}
+	return &Prerequisites{mocked: mocked, host: &Preflight{promptSudo: true, run: runCmd, listen: listenPort, readFile: os.ReadFile}}
 }
 
 func (p *Prerequisites) Name() string {
@@ -39,444 +39,280 @@ func (p *Prerequisites) ShouldRun(state *config.State) bool {
 	return !state.IsPhaseComplete(p.Name())
 }
 
-func (p *Prerequisites) Run(ctx context.Context, state *config.State) error {
-	// 1. Detect distro
-	if err := p.detectDistro(state); err != nil {
-		ui.Warn("Could not detect Linux distro: %v", err)
+func (p *Prerequisites) DryRun(state *config.State) []Action {
+	if p.mocked {
+		return nil
 	}
-
-	// 2. Detect sudo early — other phases (deploy, installs) need this
-	if !p.mocked {
-		if docker.DetectSudo(ctx) {
-			state.UseSudo = true
-			ui.Info("Docker requires sudo — commands will use 'sudo -E'")
-		}
+	actions := []Action{
+		{ActionRun, "docker --version (offers to install Docker Engine if missing)"},
+		{ActionRun, "docker compose version"},
 	}
+	if !state.IsNetworkOnly() {
+		actions = append(actions,
+			Action{ActionRun, "nvidia-smi --query-gpu=driver_version --format=csv,noheader (offers to install " + nvidiaDriver + " if missing)"},
+			Action{ActionRun, "nvidia-ctk --version (offers to install the NVIDIA Container Toolkit if missing)"},
+			Action{ActionRun, dockerCommand(state, "run", "--rm", "--gpus", "all", cudaTestImage, "nvidia-smi")},
+			Action{ActionRun, "nvidia-smi -L, systemctl is-active nvidia-fabricmanager (multi-GPU; offers to install Fabric Manager)"},
+		)
+	}
+	return append(actions,
+		Action{ActionRun, "df, lsblk (interactive: offers to format and mount an unused drive on " + state.OutputDir + ")"})
+}
 
-	// 3. Check Docker → offer install if missing
-	if err := p.checkDocker(ctx, state); err != nil {
-		return err
+// Run detects the host with the same checks as the preflight command, then
+// offers to install what is missing and checks again: Docker, the NVIDIA
+// driver and container toolkit first, then Fabric Manager and the CUDA test
+// image, which need the driver to be detected.
+func (p *Prerequisites) Run(ctx context.Context, state *config.State) error {
+	if p.mocked {
+		return p.runMocked(state)
 	}
-
-	// 4. Check Docker Compose (comes with docker-ce install)
-	if err := p.checkDockerCompose(ctx); err != nil {
-		return err
+	if err := p.detectDistro(state); err != nil {
+		ui.Warn("Could not detect Linux distro: %v", err)
 	}
 
-	// 5-9: NVIDIA checks — skip for network-only topology (no GPU needed)
-	if !state.IsNetworkOnly() {
-		// 5. Check NVIDIA driver → offer install if missing
-		if err := p.checkNVIDIADriver(ctx, state); err != nil {
-			return err
-		}
-
-		// 6. Check driver consistency (userspace vs kernel module vs FM)
-		if !p.mocked {
-			p.checkDriverConsistency(ctx, state)
-		}
-
-		// 7. Check Container Toolkit → offer install if missing
-		if err := p.checkContainerToolkit(ctx, state); err != nil {
-			return err
-		}
+	// Detect sudo early — other phases (deploy, installs) need this
+	if docker.DetectSudo(ctx) {
+		state.UseSudo = true
+		ui.Info("Docker requires sudo — commands will use 'sudo -E'")
+	}
 
-		// 8. Check CUDA in Docker
-		if err := p.checkCUDAInDocker(ctx, state); err != nil {
+	rep, facts := p.host.detect(ctx, state)
+	for _, install := range []func(context.Context, *config.State, *hostFacts) (bool, error){
+		p.installBase, p.installGPUExtras,
+	} {
+		changed, err := install(ctx, state, facts)
+		if err != nil {
 			return err
 		}
-
-		// 9. Check Fabric Manager if multi-GPU
-		if !p.mocked {
-			p.checkFabricManager(ctx, state)
+		if changed {
+			rep, facts = p.host.detect(ctx, state)
 		}
+	}
 
-		// Check auto-updates (NVIDIA driver risk)
-		p.checkAutoUpdates(ctx, state)
-	} else {
+	if state.IsNetworkOnly() {
 		ui.Info("Skipping NVIDIA checks (network-only topology — no GPU required)")
+	} else {
+		state.DriverInfo = facts.Driver
+		state.AutoUpdateOff = !facts.AutoUpgrades
 	}
-
-	// 10-12. System checks
-	p.checkStorageLayout(ctx, state)
-	p.checkPorts(state)
+	if err := reportPrerequisites(rep); err != nil {
+		return err
+	}
+	p.checkStorageLayout(ctx, state, facts)
 
 	ui.Success("All prerequisites satisfied")
 	return nil
 }
 
-func (p *Prerequisites) detectDistro(state *config.State) error {
-	if p.mocked {
-		state.Distro = config.Distro{ID: "ubuntu", Version: "22.04", Family: "debian"}
-		return nil
-	}
-	data, err := os.ReadFile("/etc/os-release")
-	if err != nil {
-		return fmt.Errorf("read /etc/os-release: %w", err)
-	}
-	distro, err := ParseOSRelease(string(data))
-	if err != nil {
-		return err
-	}
-	state.Distro = distro
-	ui.Detail("Linux distro: %s %s (%s family)", distro.ID, distro.Version, distro.Family)
-	return nil
+// fatalChecks stop setup when they fail. Other failures are reported as
+// warnings: the node still runs, if with a risk or degraded GPUs.
+var fatalChecks = map[string]bool{
+	"docker": true, "docker_compose": true, "nvidia_driver": true, "cuda_in_docker": true,
 }
 
-func (p *Prerequisites) checkDocker(ctx context.Context, state *config.State) error {
-	if p.mocked {
-		return p.mockedCheck("Checking Docker installation", "Docker version: 27.4.1 (mocked)")
-	}
-
-	var version string
-	err := ui.WithSpinner("Checking Docker installation", func() error {
-		out, cmdErr := runCmd(ctx, "docker", "--version")
-		if cmdErr != nil {
-			return cmdErr
-		}
-		ver, parseErr := ParseDockerVersion(out)
-		if parseErr != nil {
-			return parseErr
+// reportPrerequisites prints the detection results. The disk check is left
+// to checkStorageLayout, which may offer to mount a drive.
+func reportPrerequisites(rep *PreflightReport) error {
+	for _, c := range rep.Checks {
+		if c.ID == "disk" {
+			continue
+		}
+		switch c.Result {
+		case PreflightPass:
+			ui.Detail("%s: %s", c.Name, c.Message)
+		case PreflightWarn, PreflightFail:
+			if c.Result == PreflightFail && fatalChecks[c.ID] {
+				return fmt.Errorf("%s: %s", strings.ToLower(c.Name), c.Message)
+			}
+			ui.Warn("%s: %s", c.Name, c.Message)
+			if c.Fix != "" {
+				ui.Detail("Fix: %s", c.Fix)
+			}
 		}
-		version = ver
-		return nil
-	})
+	}
+	return nil
+}
 
-	if err != nil {
-		// Docker not found — offer to install
+// installBase offers to install Docker and, for GPU nodes, the NVIDIA
+// driver and container toolkit. It reports whether anything was installed.
+func (p *Prerequisites) installBase(ctx context.Context, state *config.State, f *hostFacts) (bool, error) {
+	changed := false
+	if !f.DockerOK {
 		ui.Warn("Docker is not installed")
-		install, _ := ui.Confirm("Install Docker Engine?", true)
-		if !install {
-			return fmt.Errorf("docker is required but not installed")
+		if install, _ := ui.Confirm("Install Docker Engine?", true); !install {
+			return false, fmt.Errorf("docker is required but not installed")
 		}
-		if installErr := installDocker(ctx, state.Distro, state.UseSudo); installErr != nil {
-			return fmt.Errorf("docker installation failed: %w", installErr)
+		if err := installDocker(ctx, state.Distro, state.UseSudo); err != nil {
+			return false, fmt.Errorf("docker installation failed: %w", err)
 		}
 		// Re-detect sudo after Docker install
 		if docker.DetectSudo(ctx) {
 			state.UseSudo = true
 		}
-		return nil
-	}
-
-	ui.Detail("Docker version: %s", version)
-	return nil
-}
-
-func (p *Prerequisites) checkDockerCompose(ctx context.Context) error {
-	if p.mocked {
-		return p.mockedCheck("Checking Docker Compose", "Docker Compose version: v2.32.4 (mocked)")
+		changed = true
 	}
-	var version string
-	err := ui.WithSpinner("Checking Docker Compose", func() error {
-		out, cmdErr := runCmd(ctx, "docker", "compose", "version")
-		if cmdErr != nil {
-			return fmt.Errorf("docker compose not found: %w", cmdErr)
-		}
-		ver, parseErr := ParseDockerComposeVersion(out)
-		if parseErr != nil {
-			return parseErr
-		}
-		version = ver
-		return nil
-	})
-	if err != nil {
-		return err
+	if state.IsNetworkOnly() {
+		return changed, nil
 	}
-	ui.Detail("Docker Compose version: %s", version)
-	return nil
-}
 
-func (p *Prerequisites) checkNVIDIADriver(ctx context.Context, state *config.State) error {
-	if p.mocked {
-		state.DriverInfo = config.DriverInfo{
-			UserVersion:   "570.133.20",
-			KernelVersion: "570.133.20",
-			FMVersion:     "570.133.20",
-			Consistent:    true,
-		}
-		_ = p.mockedCheck("Checking NVIDIA driver", "NVIDIA driver: 570.133.20 (mocked)")
-		return nil
-	}
-
-	var driverVer string
-	err := ui.WithSpinner("Checking NVIDIA driver", func() error {
-		out, cmdErr := runCmd(ctx, "nvidia-smi", "--query-gpu=driver_version", "--format=csv,noheader")
-		if cmdErr != nil {
-			return cmdErr
-		}
-		driverVer = strings.TrimSpace(strings.Split(strings.TrimSpace(out), "\n")[0])
-		return nil
-	})
-
-	if err != nil {
-		// NVIDIA driver not found — offer to install
+	if f.Driver.UserVersion == "" {
 		ui.Warn("NVIDIA driver not detected (nvidia-smi not found)")
-		install, _ := ui.Confirm("Install "+nvidiaDriver+"?", true)
-		if !install {
-			return fmt.Errorf("nvidia driver is required but not installed")
+		if install, _ := ui.Confirm("Install "+nvidiaDriver+"?", true); !install {
+			return false, fmt.Errorf("nvidia driver is required but not installed")
 		}
-		if installErr := installNVIDIADriver(ctx, state.Distro, state.UseSudo); installErr != nil {
-			return installErr
+		if err := installNVIDIADriver(ctx, state.Distro, state.UseSudo); err != nil {
+			return false, err
 		}
-		// Re-read driver version after install
-		out, retryErr := runCmd(ctx, "nvidia-smi", "--query-gpu=driver_version", "--format=csv,noheader")
-		if retryErr != nil {
-			return fmt.Errorf("nvidia-smi still not available after install (reboot may be required): %w", retryErr)
+		changed = true
+	}
+	if !f.ToolkitOK {
+		ui.Warn("NVIDIA Container Toolkit not detected")
+		if install, _ := ui.Confirm("Install NVIDIA Container Toolkit?", true); !install {
+			ui.Warn("Without Container Toolkit, GPUs won't be available inside Docker containers")
+			return changed, nil
+		}
+		if err := installContainerToolkit(ctx, state.Distro, state.UseSudo); err != nil {
+			return false, fmt.Errorf("container toolkit installation failed: %w", err)
 		}
-		driverVer = strings.TrimSpace(strings.Split(strings.TrimSpace(out), "\n")[0])
+		changed = true
 	}
+	return changed, nil
+}
 
-	state.DriverInfo = config.DriverInfo{
-		UserVersion: driverVer,
-		Consistent:  true,
+// installGPUExtras starts or offers to install Fabric Manager on multi-GPU
+// hosts and pulls the CUDA test image. Both need a detected driver.
+func (p *Prerequisites) installGPUExtras(ctx context.Context, state *config.State, f *hostFacts) (bool, error) {
+	if state.IsNetworkOnly() || f.Driver.UserVersion == "" {
+		return false, nil
+	}
+	changed := false
+	if f.GPUs > 1 && !f.FMRunning {
+		changed = p.setupFabricManager(ctx, state, f)
+	}
+	if f.CUDAImageMissing {
+		err := ui.WithSpinner("Pulling "+cudaTestImage, func() error {
+			_, pullErr := runSudoCmd(ctx, state.UseSudo, "docker", "pull", cudaTestImage)
+			return pullErr
+		})
+		if err != nil {
+			return false, fmt.Errorf("pull %s: %w", cudaTestImage, err)
+		}
+		changed = true
 	}
-	ui.Detail("NVIDIA driver: %s", driverVer)
-	return nil
+	return changed, nil
 }
 
-func (p *Prerequisites) checkDriverConsistency(ctx context.Context, state *config.State) {
-	if state.DriverInfo.UserVersion == "" {
-		return
+// setupFabricManager starts an installed Fabric Manager or offers to
+// install it. Failures are warnings: PCIe-only boxes work without it.
+func (p *Prerequisites) setupFabricManager(ctx context.Context, state *config.State, f *hostFacts) bool {
+	if f.FMInstalled {
+		ui.Warn("Fabric Manager installed but not running (%d GPUs detected)", f.GPUs)
+		_, _ = runSudoCmd(ctx, state.UseSudo, "systemctl", "enable", "--now", "nvidia-fabricmanager")
+		return true
 	}
-
-	// Check kernel module version
-	out, err := runCmd(ctx, "modinfo", "nvidia")
-	if err == nil {
-		state.DriverInfo.KernelVersion = ParseModinfoVersion(out)
+	ui.Warn("Multiple GPUs detected (%d) but Fabric Manager is not installed", f.GPUs)
+	ui.Detail("Fabric Manager is required for NVLink multi-GPU communication")
+	if install, _ := ui.Confirm("Install Fabric Manager?", true); !install {
+		return false
 	}
-
-	// Check Fabric Manager version (only relevant if installed)
-	out, _ = runCmd(ctx, "dpkg", "-l", "nvidia-fabricmanager-*")
-	fmVer := ParseFabricManagerVersion(out)
-	if fmVer != "" {
-		state.DriverInfo.FMVersion = fmVer
+	if err := installFabricManager(ctx, f.Driver.UserVersion, state.UseSudo); err != nil {
+		ui.Warn("Fabric Manager installation failed: %v", err)
 	}
+	return true
+}
 
-	// Compare versions
-	userMajor := DriverMajorVersion(state.DriverInfo.UserVersion)
-	consistent := true
-
-	if state.DriverInfo.KernelVersion != "" && state.DriverInfo.KernelVersion != state.DriverInfo.UserVersion {
-		ui.Warn("Driver version mismatch: userspace=%s, kernel module=%s",
-			state.DriverInfo.UserVersion, state.DriverInfo.KernelVersion)
-		ui.Detail("This can cause GPU errors. Fix: sudo apt-get install --reinstall %s", nvidiaDriver)
-		consistent = false
+// runMocked walks through the checks with demo values.
+func (p *Prerequisites) runMocked(state *config.State) error {
+	_ = p.detectDistro(state)
+	steps := [][2]string{
+		{"Checking Docker installation", "Docker version: 27.4.1 (mocked)"},
+		{"Checking Docker Compose", "Docker Compose version: v2.32.4 (mocked)"},
 	}
-
-	if state.DriverInfo.FMVersion != "" {
-		fmMajor := DriverMajorVersion(state.DriverInfo.FMVersion)
-		if fmMajor != userMajor {
-			ui.Warn("Fabric Manager version mismatch: driver=%s, FM=%s",
-				state.DriverInfo.UserVersion, state.DriverInfo.FMVersion)
-			ui.Detail("Fix: sudo apt-get install nvidia-fabricmanager-%s", userMajor)
-			consistent = false
+	if !state.IsNetworkOnly() {
+		state.DriverInfo = config.DriverInfo{
+			UserVersion:   "570.133.20",
+			KernelVersion: "570.133.20",
+			FMVersion:     "570.133.20",
+			Consistent:    true,
 		}
-	}
-
-	state.DriverInfo.Consistent = consistent
-	if consistent {
-		if state.DriverInfo.KernelVersion != "" {
-			ui.Success("Driver versions consistent (user: %s, kernel: %s)",
-				state.DriverInfo.UserVersion, state.DriverInfo.KernelVersion)
+		state.AutoUpdateOff = true
+		steps = append(steps,
+			[2]string{"Checking NVIDIA driver", "NVIDIA driver: 570.133.20 (mocked)"},
+			[2]string{"Checking NVIDIA Container Toolkit", "nvidia-container-toolkit: 1.17.4 (mocked)"},
+			[2]string{"Verifying CUDA inside Docker container", "CUDA available inside Docker (nvidia-smi works in container)"},
+		)
+	}
+	for _, step := range steps {
+		if err := p.mockedCheck(step[0], step[1]); err != nil {
+			return err
 		}
 	}
-}
-
-func (p *Prerequisites) checkContainerToolkit(ctx context.Context, state *config.State) error {
-	if p.mocked {
-		return p.mockedCheck("Checking NVIDIA Container Toolkit", "nvidia-container-toolkit: 1.17.4 (mocked)")
+	if state.IsNetworkOnly() {
+		ui.Info("Skipping NVIDIA checks (network-only topology — no GPU required)")
 	}
 
-	var version string
-	err := ui.WithSpinner("Checking NVIDIA Container Toolkit", func() error {
-		out, cmdErr := runCmd(ctx, "nvidia-ctk", "--version")
-		if cmdErr != nil {
-			return cmdErr
-		}
-		version = strings.TrimSpace(out)
-		return nil
-	})
-
-	if err != nil {
-		// Container Toolkit not found — offer to install
-		ui.Warn("NVIDIA Container Toolkit not detected")
-		install, _ := ui.Confirm("Install NVIDIA Container Toolkit?", true)
-		if !install {
-			ui.Warn("Without Container Toolkit, GPUs won't be available inside Docker containers")
-			return nil
-		}
-		if installErr := installContainerToolkit(ctx, state.Distro, state.UseSudo); installErr != nil {
-			return fmt.Errorf("container toolkit installation failed: %w", installErr)
-		}
-		return nil
+	state.DiskFreeGB = 3500
+	ui.Success("Storage: /dev/nvme1n1 (3.5 TB) mounted at %s", state.OutputDir)
+	for _, pt := range []portCheck{
+		{5000, "P2P"}, {8000, "API"}, {26657, "RPC"},
+		{5050, "ML Inference"}, {8080, "PoC"},
+		{9100, "API ML Callback"}, {9200, "Admin API"},
+	} {
+		ui.Detail("Port %d (%s): available (mocked)", pt.port, pt.name)
 	}
-
-	ui.Detail("Container Toolkit: %s", version)
+	ui.Success("All prerequisites satisfied")
 	return nil
 }
 
-func (p *Prerequisites) checkCUDAInDocker(ctx context.Context, state *config.State) error {
+func (p *Prerequisites) detectDistro(state *config.State) error {
 	if p.mocked {
-		return p.mockedCheck("Verifying CUDA inside Docker container",
-			"CUDA available inside Docker (nvidia-smi works in container)")
-	}
-	err := ui.WithSpinner("Verifying CUDA inside Docker container", func() error {
-		args := []string{"run", "--rm", "--gpus", "all",
-			"nvidia/cuda:12.6.0-base-ubuntu22.04", "nvidia-smi"}
-		var cmd *exec.Cmd
-		if state.UseSudo {
-			sudoArgs := append([]string{"-E", "docker"}, args...)
-			cmd = exec.CommandContext(ctx, "sudo", sudoArgs...) // #nosec G204
-		} else {
-			cmd = exec.CommandContext(ctx, "docker", args...) // #nosec G204
-		}
-		out, cmdErr := cmd.CombinedOutput()
-		if cmdErr != nil {
-			return fmt.Errorf("cuda not available in Docker container: %w\n%s", cmdErr, string(out))
-		}
+		state.Distro = config.Distro{ID: "ubuntu", Version: "22.04", Family: "debian"}
 		return nil
-	})
-	if err != nil {
-		return err
 	}
-	ui.Success("CUDA available inside Docker (nvidia-smi works in container)")
-	return nil
-}
-
-func (p *Prerequisites) checkFabricManager(ctx context.Context, state *config.State) {
-	// Only relevant for multi-GPU setups
-	out, err := runCmd(ctx, "nvidia-smi", "-L")
+	data, err := os.ReadFile("/etc/os-release")
 	if err != nil {
-		return
-	}
-	gpuCount := 0
-	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
-		if strings.TrimSpace(line) != "" {
-			gpuCount++
-		}
-	}
-	if gpuCount <= 1 {
-		return
-	}
-
-	// Check if FM is already running
-	_, err = runCmd(ctx, "systemctl", "is-active", "nvidia-fabricmanager")
-	if err == nil {
-		ui.Detail("Fabric Manager: running (%d GPUs detected)", gpuCount)
-		return
-	}
-
-	// Check if FM is installed but not running
-	fmOut, _ := runCmd(ctx, "dpkg", "-l", "nvidia-fabricmanager-*")
-	fmVer := ParseFabricManagerVersion(fmOut)
-	if fmVer != "" {
-		// Installed but not running — start it
-		ui.Warn("Fabric Manager installed but not running (%d GPUs detected)", gpuCount)
-		_, _ = runSudoCmd(ctx, state.UseSudo, "systemctl", "enable", "--now", "nvidia-fabricmanager")
-		return
-	}
-
-	// Not installed — offer to install
-	ui.Warn("Multiple GPUs detected (%d) but Fabric Manager is not installed", gpuCount)
-	ui.Detail("Fabric Manager is required for NVLink multi-GPU communication")
-	install, _ := ui.Confirm("Install Fabric Manager?", true)
-	if !install {
-		return
-	}
-	if installErr := installFabricManager(ctx, state.DriverInfo.UserVersion, state.UseSudo); installErr != nil {
-		ui.Warn("Fabric Manager installation failed: %v", installErr)
-	}
-}
-
-func (p *Prerequisites) checkAutoUpdates(ctx context.Context, state *config.State) {
-	if p.mocked {
-		state.AutoUpdateOff = true
-		ui.Success("No auto-update packages detected that could break NVIDIA drivers")
-		return
+		return fmt.Errorf("read /etc/os-release: %w", err)
 	}
-	err := ui.WithSpinner("Checking for auto-update packages", func() error {
-		_, cmdErr := runCmd(ctx, "dpkg", "-l", "unattended-upgrades")
-		if cmdErr != nil {
-			// Not installed — good
-			return nil
-		}
-		return fmt.Errorf("unattended-upgrades detected")
-	})
+	distro, err := ParseOSRelease(string(data))
 	if err != nil {
-		state.AutoUpdateOff = false
-		ui.Warn("unattended-upgrades is installed — this can break NVIDIA drivers during auto-update")
-		ui.Detail("Consider: sudo apt-mark hold nvidia-driver-*")
-	} else {
-		state.AutoUpdateOff = true
-		ui.Success("No auto-update packages detected that could break NVIDIA drivers")
+		return err
 	}
+	state.Distro = distro
+	ui.Detail("Linux distro: %s %s (%s family)", distro.ID, distro.Version, distro.Family)
+	return nil
 }
 
 const minUnmountedDriveGB = 500
 
-func (p *Prerequisites) checkStorageLayout(ctx context.Context, state *config.State) {
-	if p.mocked {
-		state.DiskFreeGB = 3500
-		ui.Success("Storage: /dev/nvme1n1 (3.5 TB) mounted at %s", state.OutputDir)
-		return
-	}
-
+// checkStorageLayout reports the free space found by detection and, when
+// the deploy directory is on the root filesystem next to an unused drive,
+// offers to format and mount it there.
+func (p *Prerequisites) checkStorageLayout(ctx context.Context, state *config.State, f *hostFacts) {
 	ui.Header("Storage Validation")
-
-	// 1. Get disk free space on deploy dir
-	freeGB := p.getDiskFreeGB(ctx, state.OutputDir)
-	state.DiskFreeGB = freeGB
-
-	// 2. Check which device backs the deploy dir and root
-	deploySource := p.getDeviceForPath(ctx, state.OutputDir)
-	rootSource := p.getDeviceForPath(ctx, "/")
-	onRoot := deploySource != "" && rootSource != "" && deploySource == rootSource
-
-	// 3. Get block device layout
-	devices, lsblkErr := p.getLsblkDevices(ctx)
-	if lsblkErr != nil {
-		// Can't detect storage layout — fall back to simple disk space check
-		p.reportDiskSpace(state, freeGB)
+	state.DiskFreeGB = f.DiskFreeGB
+	if len(f.SpareDrives) == 0 {
+		p.reportDiskSpace(state, f.DiskFreeGB)
 		return
 	}
 
-	// 4. Find unmounted drives
-	unmounted := FindUnmountedDrives(devices, minUnmountedDriveGB)
-
-	// 5. Show storage summary
-	if deploySource != "" {
-		ui.Detail("Deploy directory: %s (on %s, %d GB free)", state.OutputDir, deploySource, freeGB)
+	ui.Warn("Deploy directory is on root filesystem (%d GB free). Blockchain data can grow to 500GB+.", f.DiskFreeGB)
+	ui.Info("Found %d unmounted drive(s) that could be used:", len(f.SpareDrives))
+	for i, d := range f.SpareDrives {
+		ui.Detail("  [%d] /dev/%s (%s, unmounted)", i+1, d.Name, FormatDriveSize(d.Size))
 	}
 
-	// 6. Decision logic
-	if onRoot && len(unmounted) > 0 {
-		ui.Warn("Deploy directory is on root filesystem (%d GB free). Blockchain data can grow to 500GB+.", freeGB)
-		ui.Info("Found %d unmounted drive(s) that could be used:", len(unmounted))
-		for i, d := range unmounted {
-			ui.Detail("  [%d] /dev/%s (%s, unmounted)", i+1, d.Name, FormatDriveSize(d.Size))
-		}
-
-		// In non-interactive mode, warn but don't offer to format
-		if ui.IsNonInteractive() {
-			ui.Warn("Deploy directory is on root filesystem. Use --output-dir on a dedicated mount for production.")
-			return
-		}
-
-		// Offer to mount
-		if err := p.offerMountDrive(ctx, state, unmounted); err != nil {
-			ui.Warn("Drive mount failed: %v", err)
-		} else {
-			// Re-check disk space after mount
-			freeGB = p.getDiskFreeGB(ctx, state.OutputDir)
-			state.DiskFreeGB = freeGB
-		}
-	} else if onRoot {
-		// On root, but no unmounted drives — just warn if space is low
-		p.reportDiskSpace(state, freeGB)
-	} else {
-		// Deploy dir is on a separate mount — good
-		p.reportDiskSpace(state, freeGB)
+	// In non-interactive mode, warn but don't offer to format
+	if ui.IsNonInteractive() {
+		ui.Warn("Deploy directory is on root filesystem. Use --output-dir on a dedicated mount for production.")
+		return
+	}
+	if err := p.offerMountDrive(ctx, state, f.SpareDrives); err != nil {
+		ui.Warn("Drive mount failed: %v", err)
+		return
 	}
+	// Re-check disk space after mount
+	state.DiskFreeGB = p.getDiskFreeGB(ctx, state.OutputDir)
 }
 
 func (p *Prerequisites) offerMountDrive(ctx context.Context, state *config.State, drives []BlockDevice) error {
@@ -574,31 +410,16 @@ func (p *Prerequisites) getDiskFreeGB(ctx context.Context, path string) int {
 	return gb
 }
 
-func (p *Prerequisites) getDeviceForPath(ctx context.Context, path string) string {
-	out, err := runCmd(ctx, "df", "--output=source", path)
-	if err != nil {
-		return ""
-	}
-	source, parseErr := ParseDfSource(out)
-	if parseErr != nil {
-		return ""
-	}
-	return source
-}
-
-func (p *Prerequisites) getLsblkDevices(ctx context.Context) ([]BlockDevice, error) {
-	out, err := runCmd(ctx, "lsblk", "-J", "-b", "-o", "NAME,SIZE,TYPE,MOUNTPOINT,FSTYPE")
-	if err != nil {
-		return nil, fmt.Errorf("lsblk: %w", err)
+// minDiskGB returns the free space a node needs at the deploy directory.
+func minDiskGB(state *config.State) int {
+	if state.IsTestNet {
+		return 133
 	}
-	return ParseLsblkJSON(out)
+	return 250
 }
 
 func (p *Prerequisites) reportDiskSpace(state *config.State, freeGB int) {
-	minDisk := 250
-	if state.IsTestNet {
-		minDisk = 133
-	}
+	minDisk := minDiskGB(state)
 	if freeGB >= minDisk {
 		ui.Success("Disk space: %d GB free (%d GB minimum)", freeGB, minDisk)
 	} else {
@@ -606,44 +427,39 @@ func (p *Prerequisites) reportDiskSpace(state *config.State, freeGB int) {
 	}
 }
 
-func (p *Prerequisites) checkPorts(state *config.State) {
-	if p.mocked {
-		ports := []struct {
-			port int
-			name string
-		}{
-			{5000, "P2P"}, {8000, "API"}, {26657, "RPC"},
-			{5050, "ML Inference"}, {8080, "PoC"},
-			{9100, "API ML Callback"}, {9200, "Admin API"},
-		}
-		for _, pt := range ports {
-			ui.Detail("Port %d (%s): available (mocked)", pt.port, pt.name)
+// countGPUs counts the GPUs listed by 'nvidia-smi -L'.
+func countGPUs(out string) int {
+	n := 0
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if strings.TrimSpace(line) != "" {
+			n++
 		}
-		return
 	}
+	return n
+}
+
+type portCheck struct {
+	port int
+	name string
+}
 
-	ports := []struct {
-		port int
-		name string
-	}{
-		{state.P2PPort, "P2P"},
-		{state.APIPort, "API"},
+// requiredPorts lists the host ports the deployment will listen on. With
+// TLS, proxy-ssl publishes 80 and 443 instead of the plain API port.
+func requiredPorts(state *config.State) []portCheck {
+	ports := []portCheck{{state.P2PPort, "P2P"}}
+	if state.TLSEnabled() {
+		ports = append(ports, portCheck{config.TLSHTTPPort, "HTTP (ACME)"}, portCheck{config.TLSHTTPSPort, "HTTPS API"})
+	} else {
+		ports = append(ports, portCheck{state.APIPort, "API"})
+	}
+	ports = append(ports, []portCheck{
 		{26657, "RPC"},
 		{5050, "ML Inference"},
 		{8080, "PoC"},
 		{9100, "API ML Callback"},
 		{9200, "Admin API"},
-	}
-	for _, pt := range ports {
-		addr := fmt.Sprintf(":%d", pt.port)
-		ln, err := net.Listen("tcp", addr)
-		if err != nil {
-			ui.Warn("Port %d (%s): in use — may conflict during deployment", pt.port, pt.name)
-		} else {
-			_ = ln.Close()
-			ui.Detail("Port %d (%s): available", pt.port, pt.name)
-		}
-	}
+	}...)
+	return ports
 }
 
 // mockedCheck simulates a check with a brief sleep and detail message.
@@ -655,7 +471,7 @@ func (p *Prerequisites) mockedCheck(spinnerMsg, detailMsg string) error {
 	if err != nil {
 		return err
 	}
-	ui.Detail(detailMsg)
+	ui.Detail("%s", detailMsg)
 	return nil
 }
 
@@ -40,6 +40,20 @@ func (p *GPUDetection) ShouldRun(state *config.State) bool {
 	return !state.IsPhaseComplete(p.Name())
 }
 
+func (p *GPUDetection) DryRun(state *config.State) []Action {
+	if p.mocked {
+		return nil
+	}
+	actions := []Action{
+		{ActionRun, "nvidia-smi --query-gpu=... and nvidia-smi topo -m"},
+		{ActionRequest, "GET ghcr.io tags for product-science/mlnode (Blackwell GPUs only)"},
+	}
+	if state.CustomMLNodeImage == "" {
+		actions = append(actions, askUnless(state.Spec().MLNodeImage, "Custom MLNode image")...)
+	}
+	return append(actions, askUnless(state.Spec().AttentionBackend, "Attention backend")...)
+}
+
 func (p *GPUDetection) Run(ctx context.Context, state *config.State) error {
 	gpus, err := p.detectGPUs(ctx)
 	if err != nil {
@@ -97,19 +111,23 @@ func (p *GPUDetection) Run(ctx context.Context, state *config.State) error {
 		}
 	}
 
-	state.MLNodeImageTag = selectMLNodeImage(gpus[0].Architecture, state.Versions.MLNode, registryBlackwellTag)
+	applyModelSpec(state)
+
+	state.Versions.MLNode = selectMLNodeImage(gpus[0].Architecture, state.Versions.MLNode, registryBlackwellTag)
 	state.AttentionBackend = selectAttentionBackend(gpus[0].Architecture)
 
 	ui.Header("Recommended Configuration")
-	ui.Detail("Model: %s", rec.Model)
-	ui.Detail("Tensor Parallel Size (TP): %d", rec.TP)
-	ui.Detail("Pipeline Parallel Size (PP): %d", rec.PP)
-	ui.Detail("GPU Memory Utilization: %.2f", rec.MemoryUtil)
-	ui.Detail("Max Model Length: %d", rec.MaxModelLen)
+	ui.Detail("Model: %s", state.SelectedModel)
+	ui.Detail("Tensor Parallel Size (TP): %d", state.TPSize)
+	ui.Detail("Pipeline Parallel Size (PP): %d", state.PPSize)
+	ui.Detail("GPU Memory Utilization: %.2f", state.GPUMemoryUtil)
+	if state.MaxModelLen > 0 {
+		ui.Detail("Max Model Length: %d", state.MaxModelLen)
+	}
 	if rec.KVCacheDtype == kvCacheDtypeFP8 {
 		ui.Detail("KV Cache Dtype: fp8 (tight VRAM — saves memory)")
 	}
-	defaultImage := "ghcr.io/product-science/mlnode:" + state.MLNodeImageTag
+	defaultImage := "ghcr.io/product-science/mlnode:" + state.Versions.MLNode
 	ui.Detail("MLNode Image: %s", defaultImage)
 	ui.Detail("Attention Backend: %s", state.AttentionBackend)
 
@@ -119,7 +137,10 @@ func (p *GPUDetection) Run(ctx context.Context, state *config.State) error {
 
 	ui.Success("Configuration optimized for %d GPUs", len(gpus))
 
-	// Prompt for custom MLNode image override (skip if already set via --mlnode-image flag)
+	// Prompt for custom MLNode image override (skip if already set via --mlnode-image or the spec)
+	if state.CustomMLNodeImage == "" {
+		state.CustomMLNodeImage = state.Spec().MLNodeImage
+	}
 	if state.CustomMLNodeImage == "" {
 		customImage, err := ui.Input(
 			"Custom MLNode image (leave empty for default)",
@@ -133,7 +154,12 @@ func (p *GPUDetection) Run(ctx context.Context, state *config.State) error {
 			ui.Info("Using custom MLNode image: %s", customImage)
 		}
 	} else {
-		ui.Info("Using custom MLNode image (from --mlnode-image): %s", state.CustomMLNodeImage)
+		ui.Info("Using custom MLNode image: %s", state.CustomMLNodeImage)
+	}
+
+	if backend := state.Spec().AttentionBackend; backend != "" {
+		state.AttentionBackend = backend
+		return nil
 	}
 
 	// Prompt for attention backend selection
@@ -154,6 +180,57 @@ func (p *GPUDetection) Run(ctx context.Context, state *config.State) error {
 	return nil
 }
 
+// modelLimit is the memory utilization and context length to start a model
+// with when it was not the one recommended for the GPUs.
+type modelLimit struct {
+	MemoryUtil  float64
+	MaxModelLen int
+}
+
+// modelLimits holds the tightest recommendConfig tier for each model, so a
+// model picked by the spec fits on the smallest setup it is offered for.
+var modelLimits = map[string]modelLimit{
+	"Qwen/Qwen3-235B-A22B-Instruct-2507-FP8": {MemoryUtil: 0.88, MaxModelLen: 16384},
+	"Qwen/QwQ-32B":                           {MemoryUtil: 0.90, MaxModelLen: 24576},
+	"Qwen/Qwen3-32B-FP8":                     {MemoryUtil: 0.92, MaxModelLen: 24576},
+}
+
+// unknownModelLimit leaves the context length to vLLM, which reads it from
+// the model config.
+var unknownModelLimit = modelLimit{MemoryUtil: 0.90}
+
+// applyModelSpec replaces the recommended model and parallelism with the
+// setup spec's values, where set. Memory utilization and max model length
+// were computed for the recommended model, so a different model gets its own
+// unless the spec sets them too.
+func applyModelSpec(state *config.State) {
+	m := state.Spec().Model
+	if m == nil {
+		return
+	}
+	if m.Name != "" && m.Name != state.SelectedModel {
+		state.SelectedModel = m.Name
+		limit, ok := modelLimits[m.Name]
+		if !ok {
+			limit = unknownModelLimit
+		}
+		state.GPUMemoryUtil = limit.MemoryUtil
+		state.MaxModelLen = limit.MaxModelLen
+	}
+	if m.GPUMemoryUtil > 0 {
+		state.GPUMemoryUtil = m.GPUMemoryUtil
+	}
+	if m.MaxModelLen > 0 {
+		state.MaxModelLen = m.MaxModelLen
+	}
+	if m.TP > 0 {
+		state.TPSize = m.TP
+	}
+	if m.PP > 0 {
+		state.PPSize = m.PP
+	}
+}
+
 func (p *GPUDetection) detectGPUs(ctx context.Context) ([]config.GPUInfo, error) {
 	var gpus []config.GPUInfo
 	err := ui.WithSpinner("Detecting NVIDIA GPUs", func() error {
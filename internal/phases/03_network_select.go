@@ -34,22 +34,31 @@ func (p *NetworkSelect) ShouldRun(state *config.State) bool {
 	return !state.IsPhaseComplete(p.Name())
 }
 
+func (p *NetworkSelect) DryRun(state *config.State) []Action {
+	return append(askUnless(state.Spec().Network, "Select network to join"),
+		Action{ActionRequest, "GET the upstream docker-compose files from GitHub for image versions"})
+}
+
 func (p *NetworkSelect) Run(ctx context.Context, state *config.State) error {
-	networks := []string{
-		"mainnet - Production network",
-		"testnet - Test network",
-	}
+	if network := state.Spec().Network; network != "" {
+		state.Network = network
+	} else {
+		networks := []string{
+			"mainnet - Production network",
+			"testnet - Test network",
+		}
 
-	selected, err := ui.Select("Select network to join:", networks)
-	if err != nil {
-		return err
-	}
+		selected, err := ui.Select("Select network to join:", networks)
+		if err != nil {
+			return err
+		}
 
-	// Parse selection
-	if selected == networks[0] {
-		state.Network = networkNameMainnet
-	} else {
-		state.Network = "testnet"
+		// Parse selection
+		if selected == networks[0] {
+			state.Network = networkNameMainnet
+		} else {
+			state.Network = "testnet"
+		}
 	}
 
 	ui.Success("Selected network: %s", state.Network)
@@ -92,6 +101,11 @@ func fetchImageVersions(ctx context.Context, state *config.State) {
 		ui.Detail("Using fallback versions")
 	}
 
+	// GPU Detection already picked the ML node tag for this GPU (e.g. a
+	// -blackwell build); keep it.
+	if state.Versions.MLNode != "" {
+		versions.MLNode = state.Versions.MLNode
+	}
 	state.Versions = versions
 
 	// Update legacy fields for backward compatibility
@@ -41,9 +41,37 @@ func (p *KeyManagement) ShouldRun(state *config.State) bool {
 	return !state.IsPhaseComplete(p.Name())
 }
 
+func (p *KeyManagement) DryRun(state *config.State) []Action {
+	workflow := p.workflow
+	if workflow == "" {
+		workflow = state.Spec().Keys.Workflow
+	}
+	actions := askUnless(workflow, "Select key management workflow")
+	keys := "cold and warm keys"
+	if workflow == workflowSecure {
+		keys = "the warm key"
+		actions = append(actions, askUnless(state.AccountPubKey, "Account Public Key")...)
+	}
+	actions = append(actions, askUnless(state.Spec().Keys.Name, "Name for the node keys")...)
+	if state.KeyringPasswordSource == "" && state.Spec().Keys.KeyringPassword == "" {
+		actions = append(actions, Action{ActionAsk, "Keyring password"})
+	}
+	if p.mocked {
+		return actions
+	}
+	imageRef := "ghcr.io/product-science/inferenced:" + inferenceImageTag(state)
+	return append(actions,
+		Action{ActionRun, dockerCommand(state, "pull", imageRef)},
+		Action{ActionRun, "inferenced keys add in " + imageRef + " for " + keys},
+		Action{ActionWrite, filepath.Join(state.OutputDir, ".inference") + "/ (keyring)"})
+}
+
 func (p *KeyManagement) Run(ctx context.Context, state *config.State) error {
 	// If workflow not set, ask user
 	workflow := p.workflow
+	if workflow == "" {
+		workflow = state.Spec().Keys.Workflow
+	}
 	if workflow == "" {
 		options := []string{
 			"Quick Setup - Generate all keys on this machine (less secure)",
@@ -72,20 +100,15 @@ func (p *KeyManagement) runQuickWorkflow(ctx context.Context, state *config.Stat
 	ui.Warn("For production, consider using secure setup with cold account key")
 
 	// Get base name
-	baseName, err := ui.Input("Enter a base name for your node keys:", "gonka-node")
+	baseName, err := inputOr(state.Spec().Keys.Name, "Enter a base name for your node keys:", "gonka-node")
 	if err != nil {
 		return err
 	}
 
-	// Get keyring password (inferenced requires >= 8 characters)
-	password, err := ui.Password("Enter keyring password (min 8 characters):")
+	password, err := keyringPassword(state)
 	if err != nil {
 		return err
 	}
-	if len(password) < 8 {
-		return fmt.Errorf("keyring password must be at least 8 characters")
-	}
-	state.KeyringPassword = password
 
 	if p.mocked {
 		return p.runQuickMocked(state, baseName)
@@ -94,6 +117,24 @@ func (p *KeyManagement) runQuickWorkflow(ctx context.Context, state *config.Stat
 	return p.runQuickReal(ctx, state, baseName, password)
 }
 
+// keyringPassword resolves the keyring password from the setup spec or the
+// configured source, prompting if none is set. inferenced requires at least
+// 8 characters.
+func keyringPassword(state *config.State) (string, error) {
+	if state.KeyringPasswordSource == "" && state.Spec().Keys.KeyringPassword == "" {
+		ui.Detail("The keyring password is not saved; use --keyring-password-file or --keyring-password-env to avoid prompts")
+	}
+	password, err := state.ResolveKeyringPassword()
+	if err != nil {
+		return "", err
+	}
+	if len(password) < 8 {
+		state.KeyringPassword = ""
+		return "", fmt.Errorf("keyring password must be at least 8 characters")
+	}
+	return password, nil
+}
+
 func (p *KeyManagement) runQuickMocked(state *config.State, baseName string) error {
 	// Generate cold key (mocked)
 	err := ui.WithSpinner("Generating Cold Key (Account)", func() error {
@@ -170,7 +211,7 @@ func (p *KeyManagement) runQuickReal(ctx context.Context, state *config.State, b
 		coldKey = key
 		if mnemonic != "" {
 			ui.Warn("SAVE YOUR MNEMONIC (cold key):")
-			ui.Detail(mnemonic)
+			ui.Detail("%s", mnemonic)
 		}
 		return nil
 	})
@@ -193,7 +234,7 @@ func (p *KeyManagement) runQuickReal(ctx context.Context, state *config.State, b
 		warmKey = key
 		if mnemonic != "" {
 			ui.Warn("SAVE YOUR MNEMONIC (warm key):")
-			ui.Detail(mnemonic)
+			ui.Detail("%s", mnemonic)
 		}
 		return nil
 	})
@@ -221,28 +262,30 @@ func (p *KeyManagement) runSecureWorkflow(ctx context.Context, state *config.Sta
 		ui.Info("You need to provide your Account Public Key.")
 		ui.Detail("Generate it on your local machine with: gonka-nop init-account")
 
-		pubkey, err := ui.Input("Enter your Account Public Key:", "")
+		value, err := ui.Input("Enter your Account Public Key (or path to gonka-account.json):", "")
+		if err != nil {
+			return err
+		}
+		pubkey, address, err := ResolveAccountPubKey(value)
 		if err != nil {
 			return err
 		}
 		state.AccountPubKey = pubkey
+		if address != "" {
+			state.ColdKeyAddress = address
+		}
 	}
 
 	// Get key name
-	keyName, err := ui.Input("Enter a name for your server keys:", "gonka-node")
+	keyName, err := inputOr(state.Spec().Keys.Name, "Enter a name for your server keys:", "gonka-node")
 	if err != nil {
 		return err
 	}
 
-	// Get keyring password (inferenced requires >= 8 characters)
-	password, err := ui.Password("Enter keyring password (min 8 characters):")
+	password, err := keyringPassword(state)
 	if err != nil {
 		return err
 	}
-	if len(password) < 8 {
-		return fmt.Errorf("keyring password must be at least 8 characters")
-	}
-	state.KeyringPassword = password
 
 	if p.mocked {
 		return p.runSecureMocked(state, keyName)
@@ -312,7 +355,7 @@ func (p *KeyManagement) runSecureReal(ctx context.Context, state *config.State,
 		warmKey = key
 		if mnemonic != "" {
 			ui.Warn("SAVE YOUR MNEMONIC (warm key):")
-			ui.Detail(mnemonic)
+			ui.Detail("%s", mnemonic)
 		}
 		return nil
 	})
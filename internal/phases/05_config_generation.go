@@ -54,6 +54,30 @@ func (p *ConfigGeneration) ShouldRun(state *config.State) bool {
 	return !state.IsPhaseComplete(p.Name())
 }
 
+func (p *ConfigGeneration) DryRun(state *config.State) []Action {
+	var actions []Action
+	if state.PublicIP == "" {
+		spec := state.Spec()
+		actions = append(actions, askUnless(spec.PublicIP, "Public IP or hostname")...)
+		if state.IsNetworkOnly() && state.NetworkNodeIP == "" {
+			actions = append(actions, askUnless(spec.PrivateIP, "Private IP for ML node connectivity")...)
+		}
+		if spec.Ports == nil {
+			actions = append(actions, Action{ActionAsk, "External port configuration"})
+		}
+		if !state.IsNetworkOnly() {
+			actions = append(actions, askUnless(spec.HFHome, "HuggingFace cache directory")...)
+		}
+	}
+	for _, r := range configRenderers(state) {
+		actions = append(actions, Action{ActionWrite, filepath.Join(state.OutputDir, r.name)})
+	}
+	if !state.IsMLNodeOnly() && state.KeyringEnvPath() != "" {
+		actions = append(actions, Action{ActionWrite, state.KeyringEnvPath() + " (mode 0600)"})
+	}
+	return actions
+}
+
 func (p *ConfigGeneration) Run(_ context.Context, state *config.State) error {
 	// Collect user inputs (public IP, private IP, ports, HF home)
 	if err := collectConfigInputs(state); err != nil {
@@ -75,6 +99,15 @@ func (p *ConfigGeneration) Run(_ context.Context, state *config.State) error {
 	}
 	ui.Detail("Created: %s/config.env", state.OutputDir)
 
+	// With a password file, the api container reads the password from an
+	// env file next to it
+	if !state.IsMLNodeOnly() && state.KeyringEnvPath() != "" {
+		if err := generateKeyringEnv(state); err != nil {
+			return err
+		}
+		ui.Detail("Created: %s (mode 0600)", state.KeyringEnvPath())
+	}
+
 	// Generate node-config.json
 	if err := ui.WithSpinner("Generating node-config.json", func() error {
 		return generateNodeConfig(state)
@@ -130,6 +163,11 @@ func (p *ConfigGeneration) Run(_ context.Context, state *config.State) error {
 	ui.Header("Security Configuration")
 	ui.Success("Internal ports bound to 127.0.0.1 (9100, 9200, 5050, 8080)")
 	ui.Success("DDoS protection defaults enabled (blocked chain API/RPC/GRPC)")
+	if state.TLSEnabled() {
+		ui.Success("Public API served over HTTPS by proxy-ssl (%s)", state.TLSDomain)
+		ui.Detail("ACME certificate is requested on first start; %s must resolve to this server and ports %d/%d be reachable",
+			state.TLSDomain, config.TLSHTTPPort, config.TLSHTTPSPort)
+	}
 	ui.Detail("GONKA_API_BLOCKED_ROUTES: poc-batches training")
 	ui.Detail("Pruning: custom (keep-recent=1000, interval=100)")
 	if len(state.PersistentPeers) > 0 {
@@ -143,8 +181,13 @@ func (p *ConfigGeneration) Run(_ context.Context, state *config.State) error {
 // collectConfigInputs prompts the user for public IP, optional private IP,
 // port configuration, and HuggingFace home directory.
 func collectConfigInputs(state *config.State) error {
+	spec := state.Spec()
+	if state.PublicIP != "" {
+		return reuseConfigInputs(state)
+	}
+
 	// Get public IP/hostname
-	publicIP, err := ui.Input("Enter your server's public IP or hostname:", "")
+	publicIP, err := inputOr(spec.PublicIP, "Enter your server's public IP or hostname:", "")
 	if err != nil {
 		return err
 	}
@@ -152,7 +195,8 @@ func collectConfigInputs(state *config.State) error {
 
 	// For network-only: ask for private IP that MLNodes will use to reach port 9100
 	if state.IsNetworkOnly() && state.NetworkNodeIP == "" {
-		privateIP, promptErr := ui.Input("Enter private IP for ML node connectivity (PoC callback on port 9100):", state.PublicIP)
+		privateIP, promptErr := inputOr(spec.PrivateIP,
+			"Enter private IP for ML node connectivity (PoC callback on port 9100):", state.PublicIP)
 		if promptErr != nil {
 			return promptErr
 		}
@@ -170,7 +214,7 @@ func collectConfigInputs(state *config.State) error {
 
 	// Get HuggingFace home directory (not needed for network-only)
 	if !state.IsNetworkOnly() {
-		hfHome, hfErr := ui.Input("HuggingFace cache directory:", defaultHFHome)
+		hfHome, hfErr := inputOr(spec.HFHome, "HuggingFace cache directory:", defaultHFHome)
 		if hfErr != nil {
 			return hfErr
 		}
@@ -180,6 +224,26 @@ func collectConfigInputs(state *config.State) error {
 	return nil
 }
 
+// reuseConfigInputs keeps the answers saved by an earlier run, so re-running
+// the phase (setup --only configuration) regenerates the files without asking
+// again. Values set in the setup spec still apply.
+func reuseConfigInputs(state *config.State) error {
+	spec := state.Spec()
+	if spec.PublicIP != "" {
+		state.PublicIP = spec.PublicIP
+	}
+	if spec.HFHome != "" && !state.IsNetworkOnly() {
+		state.HFHome = spec.HFHome
+	}
+	if spec.Ports != nil {
+		if err := configureExternalPorts(state); err != nil {
+			return err
+		}
+	}
+	ui.Info("Using saved inputs (public IP %s); change them with 'gonka-nop config set'", state.PublicIP)
+	return nil
+}
+
 // configureExternalPorts asks the user whether to use default ports or custom
 // (for NAT/port remapping scenarios like Vast.ai, cloud providers, etc.).
 //
@@ -191,6 +255,16 @@ func configureExternalPorts(state *config.State) error {
 	defaultP2P := 5000
 	defaultAPI := 8000
 
+	if ports := state.Spec().Ports; ports != nil {
+		state.P2PPort = portOr(ports.P2P, defaultP2P)
+		state.APIPort = portOr(ports.API, defaultAPI)
+		state.InternalP2PPort = portOr(ports.InternalP2P, defaultP2P)
+		state.InternalAPIPort = portOr(ports.InternalAPI, defaultAPI)
+		ui.Detail("External: P2P=%d, API=%d → Internal: P2P=%d, API=%d",
+			state.P2PPort, state.APIPort, state.InternalP2PPort, state.InternalAPIPort)
+		return nil
+	}
+
 	options := []string{
 		fmt.Sprintf("Default ports (P2P: %d, API: %d)", defaultP2P, defaultAPI),
 		"Custom ports (NAT / port remapping)",
@@ -245,6 +319,14 @@ func configureExternalPorts(state *config.State) error {
 	return nil
 }
 
+// portOr returns port, or defaultVal when port is unset.
+func portOr(port, defaultVal int) int {
+	if port == 0 {
+		return defaultVal
+	}
+	return port
+}
+
 // promptPort asks the user for a port number with validation.
 func promptPort(message string, defaultVal int) (int, error) {
 	str, err := ui.Input(message, fmt.Sprintf("%d", defaultVal))
@@ -258,6 +340,15 @@ func promptPort(message string, defaultVal int) (int, error) {
 	return port, nil
 }
 
+// publicURL returns the URL the node advertises for its public API:
+// https://<domain> with TLS, http://<public IP>:<external port> otherwise.
+func publicURL(state *config.State) string {
+	if state.TLSEnabled() {
+		return "https://" + state.TLSDomain
+	}
+	return fmt.Sprintf("http://%s:%d", state.PublicIP, state.APIPort)
+}
+
 // internalAPIPort returns the Docker binding port for the API/proxy service.
 // When behind NAT, this differs from APIPort (which is the external-facing port).
 func internalAPIPort(state *config.State) int {
@@ -277,6 +368,10 @@ func internalP2PPort(state *config.State) int {
 }
 
 func generateConfigEnv(state *config.State) error {
+	return writeConfigFile(state, "config.env", renderConfigEnv(state))
+}
+
+func renderConfigEnv(state *config.State) string {
 	// Build persistent peers string
 	persistentPeers := strings.Join(state.PersistentPeers, ",")
 	if persistentPeers == "" {
@@ -323,12 +418,6 @@ func generateConfigEnv(state *config.State) error {
 		chainID = "gonka-mainnet"
 	}
 
-	// Keyring password: use what the user entered, or default
-	keyringPassword := state.KeyringPassword
-	if keyringPassword == "" {
-		keyringPassword = "changeme"
-	}
-
 	// Snapshot interval
 	snapshotInterval := 1000
 	if state.IsTestNet {
@@ -361,8 +450,8 @@ func generateConfigEnv(state *config.State) error {
 # Generated by gonka-nop
 
 # Identity
+# KEYRING_PASSWORD is never written to this file (see the api service)
 KEY_NAME=%s
-KEYRING_PASSWORD=%s
 KEYRING_BACKEND=file
 ACCOUNT_PUBKEY=%s
 
@@ -371,7 +460,7 @@ CHAIN_ID=%s
 CREATE_KEY=false
 
 # Network
-PUBLIC_URL=http://%s:%d
+PUBLIC_URL=%s
 P2P_EXTERNAL_ADDRESS=tcp://%s:%d
 API_PORT=%d
 API_SSL_PORT=8443
@@ -419,11 +508,9 @@ RPC_SERVER_URL_1=%s
 RPC_SERVER_URL_2=%s
 `,
 		keyName,
-		keyringPassword,
 		state.AccountPubKey,
 		chainID,
-		state.PublicIP,
-		state.APIPort, // PUBLIC_URL: external port
+		publicURL(state), // PUBLIC_URL: external port, or the TLS domain
 		state.PublicIP,
 		state.P2PPort,          // P2P_EXTERNAL_ADDRESS: external port
 		internalAPIPort(state), // API_PORT: Docker binding (internal)
@@ -444,14 +531,69 @@ RPC_SERVER_URL_2=%s
 		seedRPCURL,
 	)
 
-	return os.WriteFile(filepath.Join(state.OutputDir, "config.env"), []byte(content), 0600)
+	content += tlsEnv(state)
+
+	return content
+}
+
+// writeConfigFile writes a rendered file into the output directory, merged
+// with the operator's file in overrides/, if any.
+func writeConfigFile(state *config.State, name, content string) error {
+	data, err := applyOverride(state.OutputDir, name, []byte(content))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(state.OutputDir, name), data, 0600)
+}
+
+// tlsEnv returns the config.env section proxy-ssl reads, or "" without TLS.
+func tlsEnv(state *config.State) string {
+	if !state.TLSEnabled() {
+		return ""
+	}
+	directory := state.ACMEDirectory
+	if directory == "" {
+		directory = config.DefaultACMEDirectory
+	}
+	return fmt.Sprintf(`
+# TLS (proxy-ssl, ACME certificate)
+NGINX_MODE=https
+SERVER_NAME=%s
+ACME_EMAIL=%s
+ACME_DIRECTORY_URL=%s
+`, state.TLSDomain, state.ACMEEmail, directory)
+}
+
+// generateKeyringEnv writes the api container's keyring env file next to
+// the password source file. Other sources write nothing: compose passes the
+// password through when it starts the containers.
+func generateKeyringEnv(state *config.State) error {
+	if state.KeyringEnvPath() == "" {
+		return nil
+	}
+	password, err := state.ResolveKeyringPassword()
+	if err != nil {
+		return err
+	}
+	if err := state.WriteKeyringEnv(password); err != nil {
+		return fmt.Errorf("write keyring env file: %w", err)
+	}
+	return nil
 }
 
 func generateNodeConfig(state *config.State) error {
+	content, err := renderNodeConfig(state)
+	if err != nil {
+		return err
+	}
+	return writeConfigFile(state, "node-config.json", content)
+}
+
+func renderNodeConfig(state *config.State) (string, error) {
 	// Network-only: generate empty node-config.json.
 	// ML nodes will be registered dynamically via Admin API (ml-node add).
 	if state.IsNetworkOnly() {
-		return os.WriteFile(filepath.Join(state.OutputDir, "node-config.json"), []byte("[]\n"), 0600)
+		return "[]\n", nil
 	}
 
 	modelName := state.SelectedModel
@@ -492,27 +634,16 @@ func generateNodeConfig(state *config.State) error {
 	}
 
 	// API expects a JSON array of node configs
-	content := fmt.Sprintf(`[{
-  "id": "%s",
-  "host": "%s",
-  "inference_port": 5000,
-  "poc_port": 8080,
-  "max_concurrent": %d,
-  "models": {
-    "%s": {
-      "args": [%s]
-    }
-  },
-  "hardware": [
-    {
-      "type": "%s | %dGB",
-      "count": %d
-    }
-  ]
-}]
-`, mlNodeID, host, maxConcurrent, modelName, formatJSONArgs(args), gpuName, gpuVRAM, gpuCount)
-
-	return os.WriteFile(filepath.Join(state.OutputDir, "node-config.json"), []byte(content), 0600)
+	return renderTemplate("node-config.json", []nodeConfigEntry{{
+		ID:            mlNodeID,
+		Host:          host,
+		InferencePort: 5000,
+		PoCPort:       8080,
+		MaxConcurrent: maxConcurrent,
+		Model:         modelName,
+		Args:          args,
+		Hardware:      []nodeHardware{{Type: fmt.Sprintf("%s | %dGB", gpuName, gpuVRAM), Count: gpuCount}},
+	}})
 }
 
 // buildVLLMArgs builds the vLLM command-line arguments from state.
@@ -548,16 +679,15 @@ func buildVLLMArgs(state *config.State) []string {
 	return args
 }
 
-// formatJSONArgs formats args as a JSON array string: "arg1", "arg2", ...
-func formatJSONArgs(args []string) string {
-	quoted := make([]string, len(args))
-	for i, arg := range args {
-		quoted[i] = fmt.Sprintf("%q", arg)
+func generateDockerCompose(state *config.State) error {
+	content, err := renderDockerCompose(state)
+	if err != nil {
+		return err
 	}
-	return strings.Join(quoted, ", ")
+	return writeConfigFile(state, "docker-compose.yml", content)
 }
 
-func generateDockerCompose(state *config.State) error {
+func renderDockerCompose(state *config.State) (string, error) {
 	// Build persistent peers for genesis seeds
 	persistentPeers := strings.Join(state.PersistentPeers, ",")
 
@@ -576,151 +706,51 @@ func generateDockerCompose(state *config.State) error {
 		ethereumNetwork = networkNameMainnet
 	}
 
-	content := fmt.Sprintf(`# Gonka Node Docker Compose
-# Generated by gonka-nop
-# Security: internal ports bound to 127.0.0.1
-
-services:
-  tmkms:
-    image: ghcr.io/product-science/tmkms-softsign-with-keygen:%s
-    container_name: tmkms
-    restart: unless-stopped
-    environment:
-      - VALIDATOR_LISTEN_ADDRESS=tcp://node:26658
-      - CHAIN_ID=${CHAIN_ID}
-    volumes:
-      - .tmkms:/root/.tmkms
-
-  node:
-    container_name: node
-    image: ghcr.io/product-science/inferenced:%s
-    command: ["sh", "./init-docker.sh"]
-    volumes:
-      - .inference:/root/.inference
-    environment:
-      - CHAIN_ID=${CHAIN_ID}
-      - SEED_NODE_RPC_URL=${SEED_NODE_RPC_URL}
-      - SEED_NODE_P2P_URL=${SEED_NODE_P2P_URL}
-      - SNAPSHOT_INTERVAL=${SNAPSHOT_INTERVAL:-1000}
-      - SNAPSHOT_KEEP_RECENT=5
-      - TRUSTED_BLOCK_PERIOD=${TRUSTED_BLOCK_PERIOD:-2000}
-      - KEY_NAME=${KEY_NAME}
-      - P2P_EXTERNAL_ADDRESS=${P2P_EXTERNAL_ADDRESS}
-      - CONFIG_p2p__allow_duplicate_ip=true
-      - CONFIG_p2p__handshake_timeout=30s
-      - CONFIG_p2p__dial_timeout=30s
-      - TMKMS_PORT=26658
-      - SYNC_WITH_SNAPSHOTS=${SYNC_WITH_SNAPSHOTS:-true}
-      - RPC_SERVER_URL_1=${RPC_SERVER_URL_1}
-      - RPC_SERVER_URL_2=${RPC_SERVER_URL_2}
-      - REST_API_ACTIVE=true
-      - INIT_ONLY=false
-      - IS_GENESIS=false
-      - CREATE_KEY=${CREATE_KEY:-false}
-      - GENESIS_SEEDS=%s
-      # Pruning configuration (prevents unbounded disk growth)
-      - PRUNING=custom
-      - PRUNING_KEEP_RECENT=1000
-      - PRUNING_INTERVAL=100
-    ports:
-      - "%d:26656"  # P2P (public, internal binding port)
-      - "127.0.0.1:26657:26657"  # RPC (internal, access via proxy /chain-rpc/)
-    expose:
-      - "26658"
-    depends_on:
-      - tmkms
-    restart: always
+	return renderTemplate("docker-compose.yml", composeModel{
+		Versions:          v,
+		GenesisSeeds:      persistentPeers,
+		P2PPort:           internalP2PPort(state),
+		APIEnvironment:    keyringEnvironment(state),
+		APIEnvFiles:       keyringEnvFiles(state),
+		MLCallbackBinding: apiPort9100Binding(state),
+		EthereumNetwork:   ethereumNetwork,
+		BeaconStateURL:    beaconStateURL,
+		Proxy:             proxyService(state, v),
+	})
+}
 
-  api:
-    container_name: api
-    image: ghcr.io/product-science/api:%s
-    volumes:
-      - .inference:/root/.inference
-      - .dapi:/root/.dapi
-      - ${NODE_CONFIG:-./node-config.json}:/root/node_config.json
-    depends_on:
-      - node
-    environment:
-      - KEY_NAME=${KEY_NAME}
-      - ACCOUNT_PUBKEY=${ACCOUNT_PUBKEY}
-      - KEYRING_BACKEND=file
-      - KEYRING_PASSWORD=${KEYRING_PASSWORD}
-      - DAPI_API__POC_CALLBACK_URL=${DAPI_API__POC_CALLBACK_URL}
-      - DAPI_API__PUBLIC_URL=${PUBLIC_URL}
-      - DAPI_CHAIN_NODE__SEED_API_URL=${SEED_API_URL}
-      - DAPI_CHAIN_NODE__URL=${DAPI_CHAIN_NODE__URL}
-      - DAPI_CHAIN_NODE__P2P_URL=${DAPI_CHAIN_NODE__P2P_URL}
-      - NODE_CONFIG_PATH=/root/node_config.json
-      - DAPI_API__PUBLIC_SERVER_PORT=9000
-      - DAPI_API__ML_SERVER_PORT=9100
-      - DAPI_API__ADMIN_SERVER_PORT=9200
-    ports:
-      # Port 9100: ML callback. Bound to localhost for same-server setups;
-      # exposed on all interfaces for network-only topology (remote MLNodes need access).
-      - "%s:9100"  # ML callback
-      - "127.0.0.1:9200:9200"  # Admin API (internal)
-    restart: always
-    env_file:
-      - config.env
-
-  bridge:
-    container_name: bridge
-    image: ghcr.io/product-science/bridge:%s
-    restart: unless-stopped
-    environment:
-      - GETH_DATA_DIR=/data/geth
-      - PRYSM_DATA_DIR=/data/prysm
-      - JWT_SECRET_PATH=/data/jwt/jwt.hex
-      - BRIDGE_POSTBLOCK=http://api:9200/admin/v1/bridge/block
-      - BRIDGE_GETADDRESSES=http://api:9000/v1/bridge/addresses
-      - ETHEREUM_NETWORK=%s
-      - BEACON_STATE_URL=%s
-      - PERSISTENT_DB_DIR=/persistent-db
-    volumes:
-      - .inference-eth/geth:/data/geth
-      - .inference-eth/prysm:/data/prysm
-      - .inference-eth/jwt:/data/jwt
-      - .inference-eth/logs:/var/log
-      - .inference-eth/persistent-db:/persistent-db
-    depends_on:
-      - api
+// keyringEnvironment passes KEYRING_PASSWORD from the compose process
+// environment to api when no env file holds it.
+func keyringEnvironment(state *config.State) []string {
+	if !state.KeyringPassthrough() {
+		return nil
+	}
+	return []string{config.KeyringPasswordVar}
+}
 
-  proxy:
-    container_name: proxy
-    image: ghcr.io/product-science/proxy:%s
-    ports:
-      - "${API_PORT:-8000}:80"    # Application service (public)
-    environment:
-      - NGINX_MODE=${NGINX_MODE:-http}
-      - SERVER_NAME=${SERVER_NAME:-}
-      - GONKA_API_PORT=9000
-      - CHAIN_RPC_PORT=26657
-      - CHAIN_API_PORT=1317
-      - CHAIN_GRPC_PORT=9090
-      - DASHBOARD_PORT=5173
-      # DDoS protection: block direct chain endpoints
-      - GONKA_API_BLOCKED_ROUTES=${GONKA_API_BLOCKED_ROUTES:-poc-batches training}
-      - GONKA_API_EXEMPT_ROUTES=${GONKA_API_EXEMPT_ROUTES:-chat inference}
-      - DISABLE_CHAIN_API=${DISABLE_CHAIN_API:-true}
-      - DISABLE_CHAIN_RPC=${DISABLE_CHAIN_RPC:-false}
-      - DISABLE_CHAIN_GRPC=${DISABLE_CHAIN_GRPC:-true}
-    depends_on:
-      - node
-      - api
-      - explorer
-    restart: unless-stopped
+// keyringEnvFiles lists the keyring env file outside the output dir, when
+// the password comes from a file.
+func keyringEnvFiles(state *config.State) []string {
+	if state.KeyringEnvPath() == "" {
+		return nil
+	}
+	return []string{state.KeyringEnvPath()}
+}
 
-  explorer:
-    container_name: explorer
-    image: ghcr.io/product-science/explorer:%s
-    expose:
-      - "5173"
-    restart: unless-stopped
-`, v.TMKMS, v.Node, persistentPeers, internalP2PPort(state), v.API,
-		apiPort9100Binding(state),
-		v.Bridge, ethereumNetwork, beaconStateURL, v.Proxy, v.Explorer)
-
-	return os.WriteFile(filepath.Join(state.OutputDir, "docker-compose.yml"), []byte(content), 0600)
+// proxyService describes the public proxy: the plain HTTP proxy image, or
+// proxy-ssl terminating TLS with an ACME certificate for state.TLSDomain.
+// proxy-ssl answers HTTP-01 challenges and redirects to HTTPS on port 80,
+// so both 80 and 443 are published on the host.
+func proxyService(state *config.State, v config.ImageVersions) proxyModel {
+	if !state.TLSEnabled() {
+		return proxyModel{Image: "proxy:" + v.Proxy}
+	}
+	return proxyModel{
+		Image:     "proxy-ssl:" + v.ProxySSL,
+		TLS:       true,
+		HTTPPort:  config.TLSHTTPPort,
+		HTTPSPort: config.TLSHTTPSPort,
+	}
 }
 
 // resolveVersions returns per-service image versions from state.Versions,
@@ -762,25 +792,29 @@ func resolveVersions(state *config.State) config.ImageVersions {
 }
 
 func generateMLNodeCompose(state *config.State) error {
+	content, err := renderMLNodeCompose(state)
+	if err != nil {
+		return err
+	}
+	return writeConfigFile(state, "docker-compose.mlnode.yml", content)
+}
+
+func renderMLNodeCompose(state *config.State) (string, error) {
 	modelName := state.SelectedModel
 	if modelName == "" {
 		modelName = defaultModel
 	}
 
 	// Select mlnode image: custom full image (--mlnode-image) takes highest priority,
-	// then GPU detection tag, then fetched version, then hardcoded default.
+	// then the GPU detection / fetched tag, then hardcoded default.
 	var mlnodeFullImage string
 	if state.CustomMLNodeImage != "" {
 		mlnodeFullImage = state.CustomMLNodeImage
 	}
 
-	imageTag := state.MLNodeImageTag
+	imageTag := state.Versions.MLNode
 	if imageTag == "" {
-		if state.Versions.MLNode != "" {
-			imageTag = state.Versions.MLNode
-		} else {
-			imageTag = defaultMLNodeImageTag
-		}
+		imageTag = defaultMLNodeImageTag
 	}
 
 	// Select attention backend
@@ -816,133 +850,48 @@ func generateMLNodeCompose(state *config.State) error {
 		mlnodeImage = DefaultMLNodeImage + ":" + imageTag
 	}
 
-	content := fmt.Sprintf(`# Gonka ML Node Docker Compose
-# Generated by gonka-nop
-# Security: ML ports bound to 127.0.0.1
-# mlnode-308: GPU inference container (no published ports)
-# inference: nginx proxy that routes to mlnode-308
+	return renderTemplate("docker-compose.mlnode.yml", mlnodeComposeModel{
+		Image:            mlnodeImage,
+		HFHome:           hfHome,
+		Model:            modelName,
+		AttentionBackend: attentionBackend,
+		NginxImage:       "nginx:" + nginxTag,
+		BindIP:           "127.0.0.1",
+		InferencePort:    inferencePort,
+		PoCPort:          pocPort,
+	})
+}
 
-services:
-  mlnode-308:
-    container_name: mlnode-308
-    hostname: mlnode-308
-    image: %s
-    restart: unless-stopped
-    ipc: host
-    command: uvicorn api.app:app --host=0.0.0.0 --port=8080
-    environment:
-      - HF_HOME=%s
-      - MODEL_NAME=%s
-      - VLLM_ATTENTION_BACKEND=%s
-    volumes:
-      - %s:%s
-      - ./node-config.json:/app/node-config.json
-    deploy:
-      resources:
-        reservations:
-          devices:
-            - driver: nvidia
-              count: all
-              capabilities: [gpu]
-    env_file:
-      - config.env
-
-  inference:
-    container_name: inference
-    hostname: inference
-    image: nginx:%s
-    restart: unless-stopped
-    volumes:
-      - ./nginx.conf:/etc/nginx/nginx.conf:ro
-    ports:
-      # SECURITY: Bind ML ports to localhost only
-      - "127.0.0.1:%d:5000"   # ML inference (internal)
-      - "127.0.0.1:%d:8080"   # PoC endpoint (internal)
-    depends_on:
-      - mlnode-308
-`, mlnodeImage, hfHome, modelName, attentionBackend, hfHome, hfHome,
-		nginxTag, inferencePort, pocPort)
-
-	return os.WriteFile(filepath.Join(state.OutputDir, "docker-compose.mlnode.yml"), []byte(content), 0600)
+func generateNginxConf(state *config.State) error {
+	content, err := renderNginxConf()
+	if err != nil {
+		return err
+	}
+	return writeConfigFile(state, "nginx.conf", content)
 }
 
-// generateNginxConf creates the nginx.conf that the "inference" service uses
+// renderNginxConf returns the nginx.conf that the "inference" service uses
 // to proxy requests to the mlnode-308 container.
 // Upstream targets are Docker service names and internal ports — architectural constants.
-func generateNginxConf(state *config.State) error {
-	content := `# Nginx proxy for mlnode
-# Generated by gonka-nop
-# Routes inference and PoC traffic to mlnode-308 container
-
-events {}
-
-http {
-    resolver 127.0.0.11 valid=10s;
-    resolver_timeout 5s;
-
-    upstream mlnode_v308 {
-        zone mlnode_v308 64k;
-        server mlnode-308:8080 resolve;
-    }
-
-    server {
-        listen 8080;
-
-        client_max_body_size      0;
-        proxy_connect_timeout     24h;
-        proxy_send_timeout        24h;
-        proxy_read_timeout        24h;
-
-        location /v3.0.8/ {
-            proxy_pass http://mlnode_v308/;
-            proxy_set_header Host $host;
-            proxy_set_header X-Real-IP $remote_addr;
-            proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
-        }
-
-        location / {
-            proxy_pass http://mlnode_v308/;
-            proxy_set_header Host $host;
-            proxy_set_header X-Real-IP $remote_addr;
-            proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
-        }
-    }
-
-    upstream mlnode_v308_port5000 {
-        zone mlnode_v308_port5000 64k;
-        server mlnode-308:5000 resolve;
-    }
-
-    server {
-        listen 5000;
-
-        client_max_body_size      0;
-        proxy_connect_timeout     24h;
-        proxy_send_timeout        24h;
-        proxy_read_timeout        24h;
-
-        location /v3.0.8/ {
-            proxy_pass http://mlnode_v308_port5000/;
-            proxy_set_header Host $host;
-            proxy_set_header X-Real-IP $remote_addr;
-            proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
-        }
-
-        location / {
-            proxy_pass http://mlnode_v308_port5000/;
-            proxy_set_header Host $host;
-            proxy_set_header X-Real-IP $remote_addr;
-            proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
-        }
-    }
+func renderNginxConf() (string, error) {
+	return renderTemplate("nginx.conf", mlnodeNginx)
 }
-`
 
-	return os.WriteFile(filepath.Join(state.OutputDir, "nginx.conf"), []byte(content), 0600)
+// mlnodeNginx routes the inference proxy ports to mlnode-308.
+var mlnodeNginx = nginxModel{
+	VersionPath: "/v3.0.8/",
+	Upstreams: []nginxUpstream{
+		{Name: "mlnode_v308", Server: "mlnode-308:8080", Listen: 8080},
+		{Name: "mlnode_v308_port5000", Server: "mlnode-308:5000", Listen: 5000},
+	},
 }
 
-// generateEnvOverride creates docker-compose.env-override.yml for testnet.
 func generateEnvOverride(state *config.State) error {
+	return writeConfigFile(state, "docker-compose.env-override.yml", renderEnvOverride(state))
+}
+
+// renderEnvOverride returns docker-compose.env-override.yml for testnet.
+func renderEnvOverride(state *config.State) string {
 	enforcedModelID := state.EnforcedModelID
 	enforcedModelArgs := buildEnforcedModelArgs(state)
 
@@ -977,7 +926,7 @@ services:
       - IS_TEST_NET=true
 `, enforcedModelID, enforcedModelArgs)
 
-	return os.WriteFile(filepath.Join(state.OutputDir, "docker-compose.env-override.yml"), []byte(content), 0600)
+	return content
 }
 
 // buildEnforcedModelArgs constructs ENFORCED_MODEL_ARGS from state values.
@@ -40,6 +40,31 @@ func (p *Deploy) ShouldRun(state *config.State) bool {
 	return !state.IsPhaseComplete(p.Name())
 }
 
+func (p *Deploy) DryRun(state *config.State) []Action {
+	actions := []Action{
+		{ActionAsk, "Ready to start containers. Proceed?"},
+		{ActionRun, composeCommand(state, nil, "pull")},
+	}
+	if !state.IsMLNodeOnly() {
+		actions = append(actions,
+			Action{ActionRun, composeCommand(state, []string{"docker-compose.yml"}, "up", "-d")},
+			Action{ActionRequest, "poll the node RPC for sync status"})
+	}
+	if !state.IsNetworkOnly() {
+		model := state.SelectedModel
+		if model == "" {
+			model = defaultModel
+		}
+		actions = append(actions,
+			Action{ActionRun, composeCommand(state, nil, "run", "--rm", "--no-deps", "mlnode-308", "huggingface-cli", "download", model)},
+			Action{ActionRun, composeCommand(state, nil, "up", "-d")})
+	}
+	if !state.IsMLNodeOnly() {
+		actions = append(actions, Action{ActionRequest, "GET the Admin API health report"})
+	}
+	return actions
+}
+
 func (p *Deploy) Run(ctx context.Context, state *config.State) error {
 	// Sudo was already detected in Phase 1 (Prerequisites).
 	// Re-detect only if prerequisites was skipped (e.g., resumed run).
@@ -75,15 +100,8 @@ func (p *Deploy) Run(ctx context.Context, state *config.State) error {
 }
 
 // deployByTopology runs the deployment steps appropriate for the node topology:
-// firewall, network node start/sync, model download, ML node start, and health checks.
+// network node start/sync, model download, ML node start, and health checks.
 func (p *Deploy) deployByTopology(ctx context.Context, state *config.State) error {
-	// Firewall (not for mlnode-only — no chain services to protect)
-	if !state.IsMLNodeOnly() {
-		if err := p.configureFirewall(ctx, state); err != nil {
-			return err
-		}
-	}
-
 	// Network node services (skip for mlnode-only)
 	if !state.IsMLNodeOnly() {
 		if err := p.startNetworkNode(ctx, state); err != nil {
@@ -114,19 +132,6 @@ func (p *Deploy) deployByTopology(ctx context.Context, state *config.State) erro
 	return nil
 }
 
-func (p *Deploy) configureFirewall(_ context.Context, state *config.State) error {
-	// Real iptables configuration is deferred to M5.2.
-	// For now, warn the user and verify port bindings in compose.
-	ui.Warn("Firewall configuration (DOCKER-USER iptables) is not yet automated")
-	ui.Detail("Ensure internal ports (5050, 8080, 9100, 9200) are bound to 127.0.0.1 in docker-compose.yml")
-	ui.Detail("Refer to: gonka-nop setup --help for manual iptables guidance")
-
-	state.FirewallConfigured = false
-	state.DDoSProtection = true
-	ui.Info("DDoS protection will be enabled via proxy configuration (GONKA_API_BLOCKED_ROUTES)")
-	return nil
-}
-
 func (p *Deploy) pullImages(ctx context.Context, state *config.State) error {
 	client, err := docker.NewComposeClient(state)
 	if err != nil {
@@ -404,7 +409,7 @@ func (p *Deploy) showSummary(state *config.State, nodeType string) {
 
 	switch nodeType {
 	case config.NodeTypeNetwork:
-		ui.Detail("Network Node API: http://%s:%d", state.PublicIP, state.APIPort)
+		ui.Detail("Network Node API: %s", publicURL(state))
 		ui.Detail("P2P Endpoint: tcp://%s:%d", state.PublicIP, state.P2PPort)
 		ui.Detail("Admin API: http://127.0.0.1:9200 (localhost only)")
 		ui.Detail("ML Callback: http://%s:9100 (accessible from private network)", state.PublicIP)
@@ -424,7 +429,7 @@ func (p *Deploy) showSummary(state *config.State, nodeType string) {
 		ui.Info("2. Check GPU status: gonka-nop gpu-info")
 
 	default:
-		ui.Detail("Network Node API: http://%s:%d", state.PublicIP, state.APIPort)
+		ui.Detail("Network Node API: %s", publicURL(state))
 		ui.Detail("P2P Endpoint: tcp://%s:%d", state.PublicIP, state.P2PPort)
 		ui.Detail("ML Node: http://127.0.0.1:8080 (localhost only)")
 		ui.Detail("Admin API: http://127.0.0.1:9200 (localhost only)")
@@ -437,9 +442,9 @@ func (p *Deploy) showSummary(state *config.State, nodeType string) {
 
 	ui.Header("Security")
 	if state.FirewallConfigured {
-		ui.Detail("Firewall: DOCKER-USER chain configured")
+		ui.Detail("Firewall: configured (gonka-nop firewall show)")
 	} else {
-		ui.Warn("Firewall: Not configured. Please set up DOCKER-USER iptables rules manually")
+		ui.Detail("Firewall: applied by the next phase (gonka-nop firewall show)")
 	}
 	ui.Detail("DDoS: Proxy route blocking enabled by default")
 }
@@ -1,12 +1,13 @@
 package phases
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
-	"os/exec"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -16,13 +17,24 @@ import (
 )
 
 const (
+	offlineTxDir         = "offline-tx"
 	statusPass           = "PASS"
 	registrationTimeout  = 2 * time.Minute
 	registrationPollTime = 10 * time.Second
 )
 
 // Registration handles on-chain node registration and ML permissions.
-type Registration struct{}
+type Registration struct {
+	// SignedTxFile, if set, broadcasts transactions signed on the cold
+	// machine (secure workflow) instead of generating new ones.
+	SignedTxFile string
+	// Regenerate discards previously generated unsigned transactions.
+	Regenerate bool
+
+	// decodeTx decodes signed tx bytes; nil means DecodeSignedTx. Tests
+	// replace it since decoding needs the api container.
+	decodeTx func(ctx context.Context, state *config.State, txBytes string) ([]byte, error)
+}
 
 // NewRegistration creates a new Registration phase.
 func NewRegistration() *Registration {
@@ -41,6 +53,51 @@ func (p *Registration) ShouldRun(state *config.State) bool {
 	return !state.IsPhaseComplete(p.Name())
 }
 
+func (p *Registration) DryRun(state *config.State) []Action {
+	if p.SignedTxFile != "" {
+		return []Action{{ActionRequest, "broadcast the transactions in " + p.SignedTxFile + " through the seed API"}}
+	}
+	adminURL := state.AdminURL
+	if adminURL == "" {
+		adminURL = defaultAdminURL
+	}
+	actions := []Action{{ActionRequest, "probe the Admin API at " + adminURL + " (stops here while the node syncs)"}}
+	switch {
+	case state.IsTestNet:
+		return append(actions, Action{ActionRequest, "register the node through the testnet seed API and grant ML permissions"})
+	case state.KeyWorkflow == workflowSecure:
+		return append(actions, Action{ActionWrite, filepath.Join(state.OutputDir, offlineTxDir, "unsigned-tx.json")})
+	default:
+		// Placeholders for values earlier phases fill in
+		s := *state
+		setDefaultString(&s.PublicURL, "<public-url>")
+		setDefaultString(&s.AccountPubKey, "<account-pubkey>")
+		setDefaultString(&s.ColdKeyName, "<cold-key>")
+		setDefaultString(&s.WarmKeyAddress, "<warm-address>")
+		seedURL := state.SeedAPIURL
+		setDefaultString(&seedURL, config.MainnetConfig().SeedAPIURL)
+		chainID := state.ChainID
+		setDefaultString(&chainID, config.MainnetConfig().ChainID)
+
+		files := state.ComposeFiles
+		if len(files) == 0 {
+			files = []string{"docker-compose.yml"}
+		}
+		exec := func(argv []string) Action {
+			return Action{ActionRun, composeCommand(state, files, append([]string{"exec", "api"}, argv...)...)}
+		}
+		return append(actions,
+			exec(registerParticipantArgs(&s, seedURL)),
+			exec(grantPermissionsArgs(&s, seedURL+"/chain-rpc/", chainID)))
+	}
+}
+
+func setDefaultString(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
 func (p *Registration) Run(ctx context.Context, state *config.State) error {
 	adminURL := state.AdminURL
 	if adminURL == "" {
@@ -48,7 +105,13 @@ func (p *Registration) Run(ctx context.Context, state *config.State) error {
 	}
 
 	// Build public URL early (needed for manual instructions even if API isn't ready)
-	state.PublicURL = fmt.Sprintf("http://%s:%d", state.PublicIP, state.APIPort)
+	state.PublicURL = publicURL(state)
+
+	// Broadcasting signed transactions only needs the seed API and the api
+	// container's inferenced to decode them
+	if p.SignedTxFile != "" {
+		return p.broadcastSigned(ctx, state)
+	}
 
 	// Quick check if Admin API is responsive — don't wait, just probe once.
 	// On fresh deploys the node syncs for hours; no point blocking setup.
@@ -98,19 +161,11 @@ func (p *Registration) runQuick(ctx context.Context, state *config.State) error
 		seedURL = config.MainnetConfig().SeedAPIURL
 	}
 
-	registerCmd := fmt.Sprintf(
-		`inferenced register-new-participant %s %s --node-address %s`,
-		state.PublicURL, state.AccountPubKey, seedURL,
-	)
-	// Always pass --consensus-key since docker compose run --no-deps cannot
-	// auto-fetch from DAPI_CHAIN_NODE__URL (node container is not linked).
-	if state.ConsensusKey != "" {
-		registerCmd += fmt.Sprintf(` --consensus-key %s`, state.ConsensusKey)
-	}
-	ui.Detail("Command: %s", registerCmd)
+	registerArgs := registerParticipantArgs(state, seedURL)
+	ui.Detail("Command: %s", strings.Join(registerArgs, " "))
 
 	err := ui.WithSpinner("Registering node on-chain", func() error {
-		_, execErr := RunComposeExec(ctx, state, "api", registerCmd)
+		_, execErr := RunComposeExec(ctx, state, "api", registerArgs, nil)
 		return execErr
 	})
 	if err != nil {
@@ -125,8 +180,12 @@ func (p *Registration) runQuick(ctx context.Context, state *config.State) error
 		state.NodeRegistered = true
 		return nil
 	}
-	if state.KeyringPassword == "" {
-		p.loadKeyringPassword(state)
+	password, err := state.ResolveKeyringPassword()
+	if err != nil {
+		ui.Warn("Keyring password not available — skipping grant-ml-ops-permissions: %v", err)
+		ui.Detail("You can retry with: gonka-nop register --force")
+		state.NodeRegistered = true
+		return nil
 	}
 
 	ui.Info("Granting ML operations permissions...")
@@ -136,14 +195,9 @@ func (p *Registration) runQuick(ctx context.Context, state *config.State) error
 		chainID = config.MainnetConfig().ChainID
 	}
 
-	grantCmd := fmt.Sprintf(
-		`printf '%%s\n' '%s' | inferenced tx inference grant-ml-ops-permissions %s %s --from %s --keyring-backend file --gas 2000000 --node %s --chain-id %s --yes`,
-		state.KeyringPassword, state.ColdKeyName, state.WarmKeyAddress,
-		state.ColdKeyName, nodeURL, chainID,
-	)
-
+	grantArgs := grantPermissionsArgs(state, nodeURL, chainID)
 	err = ui.WithSpinner("Granting ML permissions", func() error {
-		_, execErr := RunComposeExec(ctx, state, "api", grantCmd)
+		_, execErr := RunComposeExec(ctx, state, "api", grantArgs, keyringStdin(password))
 		return execErr
 	})
 	if err != nil {
@@ -175,21 +229,147 @@ func (p *Registration) runQuick(ctx context.Context, state *config.State) error
 	return nil
 }
 
-// runSecure shows manual instructions for mainnet + secure workflow.
-func (p *Registration) runSecure(_ context.Context, state *config.State) error {
-	ui.Header("Manual Registration Required")
-	ui.Info("Your account key is on a separate machine (secure workflow)")
-	ui.Info("Run the following commands from your LOCAL machine with the account key:")
+// runSecure generates unsigned registration and grant transactions for the
+// cold machine (mainnet + secure workflow). It resumes from state: once
+// generated, the same transactions are shown until they are broadcast.
+func (p *Registration) runSecure(ctx context.Context, state *config.State) error {
+	seedURL, chainID := mainnetSeed(state)
+
+	if off := state.OfflineRegistration; off != nil && !p.Regenerate {
+		if _, err := os.Stat(off.UnsignedFile); err == nil {
+			ui.Info("Unsigned transactions were already generated")
+			showOfflineSteps(off.UnsignedFile)
+			return nil
+		}
+	}
+
+	ui.Header("Offline Registration")
+	var bundle *OfflineTxBundle
+	err := ui.WithSpinner("Generating unsigned transactions", func() error {
+		var genErr error
+		bundle, genErr = GenerateOfflineTxs(ctx, state, seedURL, chainID)
+		return genErr
+	})
+	if err != nil {
+		ui.Warn("Could not generate unsigned transactions: %v", err)
+		return p.runSecureManual(state, seedURL, chainID)
+	}
+
+	path := filepath.Join(state.OutputDir, offlineTxDir, "unsigned-tx.json")
+	if err := WriteOfflineTxBundle(path, bundle); err != nil {
+		return err
+	}
+	state.OfflineRegistration = &config.OfflineRegistration{UnsignedFile: path, Fingerprint: bundle.Fingerprint()}
+	ui.Success("Unsigned transactions written: %s", path)
+	ui.Detail("Signer %s, account %d, sequence %d", bundle.Signer, bundle.AccountNumber, bundle.Sequence)
+	showOfflineSteps(path)
+	return nil
+}
+
+// broadcastSigned broadcasts the transactions signed by `gonka-nop sign`,
+// skipping those already broadcast by an earlier run. The signed bytes are
+// decoded and checked against the unsigned transactions first, since the
+// fingerprint only covers the unsigned bodies. The node counts as registered
+// once setup/report confirms it, not when the broadcast is accepted.
+func (p *Registration) broadcastSigned(ctx context.Context, state *config.State) error {
+	ui.Header("Broadcast Signed Transactions")
+	seedURL, _ := mainnetSeed(state)
+
+	bundle, err := LoadOfflineTxBundle(p.SignedTxFile)
+	if err != nil {
+		return err
+	}
+	if !bundle.IsSigned() {
+		return fmt.Errorf("%s is not signed — run 'gonka-nop sign' on the machine with the account key", p.SignedTxFile)
+	}
+	off := state.OfflineRegistration
+	if off == nil || off.Fingerprint != bundle.Fingerprint() {
+		return fmt.Errorf("%s does not match the unsigned transactions generated on this server — sign the current unsigned-tx.json", p.SignedTxFile)
+	}
+	if off.Broadcast == nil {
+		off.Broadcast = map[string]string{}
+	}
+
+	decode := p.decodeTx
+	if decode == nil {
+		decode = DecodeSignedTx
+	}
+	for _, tx := range bundle.Txs {
+		if _, ok := off.Broadcast[tx.Name]; ok {
+			continue
+		}
+		decoded, err := decode(ctx, state, tx.TxBytes)
+		if err != nil {
+			return fmt.Errorf("decode %s: %w", tx.Name, err)
+		}
+		if err := MatchTxMessages(tx.Unsigned, decoded); err != nil {
+			return fmt.Errorf("%s: %w — sign the current unsigned-tx.json again", tx.Name, err)
+		}
+	}
+
+	for _, tx := range bundle.Txs {
+		if hash, ok := off.Broadcast[tx.Name]; ok {
+			ui.Detail("%s already broadcast (%s)", tx.Name, hash)
+			continue
+		}
+		hash, err := BroadcastTx(ctx, seedURL, tx.TxBytes)
+		if err != nil {
+			return fmt.Errorf("broadcast %s: %w", tx.Name, err)
+		}
+		off.Broadcast[tx.Name] = hash
+		ui.Success("%s broadcast: %s", tx.Name, hash)
+		if saveErr := state.Save(); saveErr != nil {
+			ui.Warn("Could not save state: %v", saveErr)
+		}
+	}
 
+	adminURL := state.AdminURL
+	if adminURL == "" {
+		adminURL = defaultAdminURL
+	}
+	ui.Info("Verifying registration...")
+	if err := WaitForRegistration(ctx, adminURL, registrationTimeout); err != nil {
+		ui.Warn("Registration verification timed out: %v", err)
+		ui.Detail("Check status with: gonka-nop status")
+		ui.Detail("Re-run 'gonka-nop register --signed %s' to verify again; nothing is broadcast twice", p.SignedTxFile)
+		return nil
+	}
+	state.NodeRegistered = true
+	state.PermGranted = true
+	ui.Success("Registration verified — node is registered on-chain")
+	return nil
+}
+
+// showOfflineSteps prints the remaining steps of the offline flow.
+func showOfflineSteps(unsignedFile string) {
 	fmt.Println()
-	seedURL := state.SeedAPIURL
+	ui.Info("Next steps:")
+	ui.Detail("1. Copy %s to the machine with the account key", unsignedFile)
+	ui.Detail("2. There, run: gonka-nop sign %s", filepath.Base(unsignedFile))
+	ui.Detail("3. Copy signed-tx.json back and run: gonka-nop register --signed signed-tx.json")
+	ui.Detail("If the account was used in the meantime, start over with: gonka-nop register --regenerate")
+}
+
+// mainnetSeed returns the seed API URL and chain ID, defaulting to mainnet.
+func mainnetSeed(state *config.State) (seedURL, chainID string) {
+	seedURL = state.SeedAPIURL
 	if seedURL == "" {
 		seedURL = config.MainnetConfig().SeedAPIURL
 	}
-	chainID := state.ChainID
+	chainID = state.ChainID
 	if chainID == "" {
 		chainID = config.MainnetConfig().ChainID
 	}
+	return seedURL, chainID
+}
+
+// runSecureManual shows manual instructions for mainnet + secure workflow.
+func (p *Registration) runSecureManual(state *config.State, seedURL, chainID string) error {
+	ui.Header("Manual Registration Required")
+	ui.Info("Your account key is on a separate machine (secure workflow)")
+	ui.Info("Run the following commands from your LOCAL machine with the account key:")
+
+	fmt.Println()
 
 	// Registration command
 	ui.Header("Step 1: Register Node")
@@ -254,12 +434,12 @@ func (p *Registration) runTestnet(ctx context.Context, state *config.State) erro
 	registered := p.tryTestnetRegister(ctx, state, seedURL)
 
 	// Step 2: Grant ML permissions (uses /chain-rpc/ path for tx commands)
-	// Fall back to config.env if password is empty (old state files).
-	if state.KeyringPassword == "" {
-		p.loadKeyringPassword(state)
+	password, pwErr := state.ResolveKeyringPassword()
+	if pwErr != nil {
+		ui.Warn("Keyring password not available: %v", pwErr)
 	}
-	if state.ColdKeyName != "" && state.WarmKeyAddress != "" && state.KeyringPassword != "" {
-		p.tryGrantPermissions(ctx, state, rpcURL, chainID)
+	if state.ColdKeyName != "" && state.WarmKeyAddress != "" && pwErr == nil {
+		p.tryGrantPermissions(ctx, state, rpcURL, chainID, password)
 	} else {
 		ui.Warn("Cold key name, warm key address, or keyring password not available — skipping grant-ml-ops-permissions")
 		p.showGrantManual(state, seedURL, chainID)
@@ -303,19 +483,11 @@ func (p *Registration) tryTestnetRegister(ctx context.Context, state *config.Sta
 	ui.Info("Attempting automated registration...")
 
 	// Use register-new-participant (same as mainnet quick workflow)
-	registerCmd := fmt.Sprintf(
-		`inferenced register-new-participant %s %s --node-address %s`,
-		state.PublicURL, state.AccountPubKey, seedURL,
-	)
-	// Always pass --consensus-key since docker compose run --no-deps cannot
-	// auto-fetch from DAPI_CHAIN_NODE__URL (node container is not linked).
-	if state.ConsensusKey != "" {
-		registerCmd += fmt.Sprintf(` --consensus-key %s`, state.ConsensusKey)
-	}
-	ui.Detail("Command: %s", registerCmd)
+	registerArgs := registerParticipantArgs(state, seedURL)
+	ui.Detail("Command: %s", strings.Join(registerArgs, " "))
 
 	err := ui.WithSpinner("Registering node on-chain", func() error {
-		_, execErr := RunComposeExec(ctx, state, "api", registerCmd)
+		_, execErr := RunComposeExec(ctx, state, "api", registerArgs, nil)
 		return execErr
 	})
 	if err != nil {
@@ -330,17 +502,12 @@ func (p *Registration) tryTestnetRegister(ctx context.Context, state *config.Sta
 }
 
 // tryGrantPermissions attempts automated grant-ml-ops-permissions.
-func (p *Registration) tryGrantPermissions(ctx context.Context, state *config.State, nodeURL, chainID string) {
+func (p *Registration) tryGrantPermissions(ctx context.Context, state *config.State, nodeURL, chainID, password string) {
 	ui.Info("Granting ML operations permissions...")
 
-	grantCmd := fmt.Sprintf(
-		`printf '%%s\n' '%s' | inferenced tx inference grant-ml-ops-permissions %s %s --from %s --keyring-backend file --gas 2000000 --node %s --chain-id %s --yes`,
-		state.KeyringPassword, state.ColdKeyName, state.WarmKeyAddress,
-		state.ColdKeyName, nodeURL, chainID,
-	)
-
+	grantArgs := grantPermissionsArgs(state, nodeURL, chainID)
 	err := ui.WithSpinner("Granting ML permissions", func() error {
-		_, execErr := RunComposeExec(ctx, state, "api", grantCmd)
+		_, execErr := RunComposeExec(ctx, state, "api", grantArgs, keyringStdin(password))
 		return execErr
 	})
 	if err != nil {
@@ -380,22 +547,35 @@ func (p *Registration) waitForManualRegistration(state *config.State) bool {
 	return true
 }
 
-// loadKeyringPassword tries to recover the keyring password from config.env
-// as a fallback (e.g. for state files created before password was persisted).
-func (p *Registration) loadKeyringPassword(state *config.State) {
-	envFile := state.OutputDir + "/config.env"
-	envVars, err := docker.ParseEnvFile(envFile)
-	if err != nil {
-		return
+// registerParticipantArgs builds the register-new-participant command.
+// --consensus-key is always passed since docker compose run --no-deps cannot
+// auto-fetch it from DAPI_CHAIN_NODE__URL (node container is not linked).
+func registerParticipantArgs(state *config.State, seedURL string) []string {
+	args := []string{
+		"inferenced", "register-new-participant", state.PublicURL, state.AccountPubKey,
+		"--node-address", seedURL,
 	}
-	for _, kv := range envVars {
-		if strings.HasPrefix(kv, "KEYRING_PASSWORD=") {
-			state.KeyringPassword = strings.TrimPrefix(kv, "KEYRING_PASSWORD=")
-			return
-		}
+	if state.ConsensusKey != "" {
+		args = append(args, "--consensus-key", state.ConsensusKey)
+	}
+	return args
+}
+
+// grantPermissionsArgs builds the grant-ml-ops-permissions command. The
+// keyring password is supplied on stdin (see keyringStdin), never in argv.
+func grantPermissionsArgs(state *config.State, nodeURL, chainID string) []string {
+	return []string{
+		"inferenced", "tx", "inference", "grant-ml-ops-permissions", state.ColdKeyName, state.WarmKeyAddress,
+		"--from", state.ColdKeyName, "--keyring-backend", "file", "--gas", "2000000",
+		"--node", nodeURL, "--chain-id", chainID, "--yes",
 	}
 }
 
+// keyringStdin returns the stdin answering inferenced's keyring passphrase prompt.
+func keyringStdin(password string) io.Reader {
+	return strings.NewReader(password + "\n")
+}
+
 // isAPIReady does a single probe of the Admin API — returns true if responsive.
 func (p *Registration) isAPIReady(ctx context.Context, adminURL string) bool {
 	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
@@ -495,28 +675,18 @@ func fetchConsensusKeyFromReport(ctx context.Context, adminURL string) (string,
 // This is the most reliable method — works even on fresh unregistered nodes.
 // Parses: /status -> result.validator_info.pub_key.value
 func fetchConsensusKeyFromRPC(ctx context.Context, state *config.State) (string, error) {
-	// Use wget (available in node container) since curl may not be present
-	shellCmd := `wget -qO- http://localhost:26657/status 2>/dev/null`
-
-	execArgs := []string{"exec", "node", "sh", "-c", shellCmd}
-
-	var name string
-	var args []string
-	if state.UseSudo {
-		name = cmdSudo
-		args = append([]string{"-E", cmdDocker}, execArgs...)
-	} else {
-		name = cmdDocker
-		args = execArgs
+	cc, err := apiComposeClient(state)
+	if err != nil {
+		return "", err
 	}
-
 	cmdCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(cmdCtx, name, args...) // #nosec G204
-	cmd.Dir = state.OutputDir
-
-	out, err := cmd.Output()
+	// Use wget (available in node container) since curl may not be present
+	out, err := cc.Exec(cmdCtx, docker.ExecOptions{
+		Service: "node",
+		Cmd:     []string{"wget", "-qO-", "http://localhost:26657/status"},
+	})
 	if err != nil {
 		return "", fmt.Errorf("docker exec node RPC status: %w", err)
 	}
@@ -530,7 +700,7 @@ func fetchConsensusKeyFromRPC(ctx context.Context, state *config.State) (string,
 			} `json:"validator_info"`
 		} `json:"result"`
 	}
-	if err := json.Unmarshal(out, &rpcStatus); err != nil {
+	if err := json.Unmarshal([]byte(out), &rpcStatus); err != nil {
 		return "", fmt.Errorf("parse RPC status: %w", err)
 	}
 
@@ -541,47 +711,27 @@ func fetchConsensusKeyFromRPC(ctx context.Context, state *config.State) (string,
 	return key, nil
 }
 
-// RunComposeExec runs a shell command inside a container via docker compose run.
-// Returns combined stdout+stderr output.
-func RunComposeExec(ctx context.Context, state *config.State, service, shellCmd string) (string, error) {
-	files := state.ComposeFiles
-	if len(files) == 0 {
-		files = []string{"docker-compose.yml"}
-	}
-	// 1 (compose) + 2*len(files) (-f file) + 7 (run --rm --no-deps service sh -c cmd)
-	composeArgs := make([]string, 0, 1+2*len(files)+7)
-	composeArgs = append(composeArgs, "compose")
-	for _, f := range files {
-		composeArgs = append(composeArgs, "-f", f)
-	}
-	composeArgs = append(composeArgs, "run", "--rm", "--no-deps", service, "sh", "-c", shellCmd)
-
-	var cmd *exec.Cmd
-	if state.UseSudo {
-		sudoArgs := append([]string{"-E", cmdDocker}, composeArgs...)
-		cmd = exec.CommandContext(ctx, cmdSudo, sudoArgs...) // #nosec G204
-	} else {
-		cmd = exec.CommandContext(ctx, cmdDocker, composeArgs...) // #nosec G204
+// RunComposeExec runs argv in a one-off service container via docker compose
+// run, with optional stdin. Nothing goes through a shell. Returns stdout.
+func RunComposeExec(ctx context.Context, state *config.State, service string, argv []string, stdin io.Reader) (string, error) {
+	cc, err := apiComposeClient(state)
+	if err != nil {
+		return "", err
 	}
-	cmd.Dir = state.OutputDir
+	return cc.RunCmd(ctx, docker.ExecOptions{Service: service, Cmd: argv, Stdin: stdin})
+}
 
-	// Load config.env to suppress Docker Compose "variable is not set" warnings
-	envFile := state.OutputDir + "/config.env"
-	fileEnv, envErr := docker.ParseEnvFile(envFile)
-	if envErr == nil && len(fileEnv) > 0 {
-		cmd.Env = docker.MergeEnv(fileEnv)
+// apiComposeClient returns a compose client for the chain services, which
+// live in docker-compose.yml unless state lists the compose files.
+func apiComposeClient(state *config.State) (*docker.ComposeClient, error) {
+	cc, err := docker.NewComposeClient(state)
+	if err != nil {
+		return nil, err
 	}
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		combined := strings.TrimSpace(stdout.String() + "\n" + stderr.String())
-		return combined, fmt.Errorf("docker compose run %s: %w\n%s", service, err, combined)
+	if len(state.ComposeFiles) == 0 {
+		cc.Files = []string{"docker-compose.yml"}
 	}
-
-	return strings.TrimSpace(stdout.String()), nil
+	return cc, nil
 }
 
 // WaitForRegistration polls setup/report until consensus_key_match and
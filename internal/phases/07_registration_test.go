@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -256,7 +257,7 @@ func TestRunComposeExec_BuildsCorrectArgs(t *testing.T) {
 		UseSudo:      false,
 	}
 
-	_, err := RunComposeExec(context.Background(), state, "api", "echo hello")
+	_, err := RunComposeExec(context.Background(), state, "api", []string{"echo", "hello"}, nil)
 	if err == nil {
 		t.Fatal("expected error when output dir doesn't exist")
 	}
@@ -270,7 +271,7 @@ func TestRunComposeExec_DefaultComposeFiles(t *testing.T) {
 		UseSudo:      false,
 	}
 
-	_, err := RunComposeExec(context.Background(), state, "api", "echo hello")
+	_, err := RunComposeExec(context.Background(), state, "api", []string{"echo", "hello"}, nil)
 	if err == nil {
 		t.Fatal("expected error when output dir doesn't exist")
 	}
@@ -299,3 +300,31 @@ func waitForRegistrationWithInterval(ctx context.Context, adminURL string, timeo
 		}
 	}
 }
+
+func TestGrantPermissionsArgs(t *testing.T) {
+	state := &config.State{ColdKeyName: "gonka-account-key", WarmKeyAddress: "gonka1warm"}
+
+	got := strings.Join(grantPermissionsArgs(state, "http://node:26657", "gonka-mainnet"), " ")
+	want := "inferenced tx inference grant-ml-ops-permissions gonka-account-key gonka1warm " +
+		"--from gonka-account-key --keyring-backend file --gas 2000000 " +
+		"--node http://node:26657 --chain-id gonka-mainnet --yes"
+	if got != want {
+		t.Errorf("grantPermissionsArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestRegisterParticipantArgs(t *testing.T) {
+	state := &config.State{PublicURL: "http://1.2.3.4:8000", AccountPubKey: "A+pub"}
+
+	got := strings.Join(registerParticipantArgs(state, "http://seed:26657"), " ")
+	want := "inferenced register-new-participant http://1.2.3.4:8000 A+pub --node-address http://seed:26657"
+	if got != want {
+		t.Errorf("registerParticipantArgs() = %q, want %q", got, want)
+	}
+
+	state.ConsensusKey = "ck=="
+	got = strings.Join(registerParticipantArgs(state, "http://seed:26657"), " ")
+	if !strings.HasSuffix(got, " --consensus-key ck==") {
+		t.Errorf("registerParticipantArgs() = %q, want --consensus-key", got)
+	}
+}
@@ -33,6 +33,20 @@ func (p *MLNodeConfig) ShouldRun(state *config.State) bool {
 	return !state.IsPhaseComplete(p.Name()) && state.IsMLNodeOnly()
 }
 
+func (p *MLNodeConfig) DryRun(state *config.State) []Action {
+	s := *state
+	applyMLNodeSpec(&s)
+	var actions []Action
+	actions = append(actions, askUnless(s.NetworkNodeURL, "Network node Admin API URL")...)
+	actions = append(actions, askUnless(s.NetworkNodeIP, "Network node private IP")...)
+	actions = append(actions, askUnless(s.PublicIP, "This ML node's IP")...)
+	actions = append(actions, askUnless(s.HFHome, "HuggingFace cache directory")...)
+	for _, r := range configRenderers(&s) {
+		actions = append(actions, Action{ActionWrite, filepath.Join(s.OutputDir, r.name)})
+	}
+	return append(actions, Action{ActionWrite, filepath.Join(s.OutputDir, "mlnode-registration.json")})
+}
+
 func (p *MLNodeConfig) Run(_ context.Context, state *config.State) error {
 	ui.Header("ML Node Configuration")
 
@@ -54,6 +68,7 @@ func (p *MLNodeConfig) Run(_ context.Context, state *config.State) error {
 
 // collectMLNodeInputs prompts the user for all MLNode-specific inputs and sets defaults.
 func collectMLNodeInputs(state *config.State) error {
+	applyMLNodeSpec(state)
 	if err := promptNetworkNodeInfo(state); err != nil {
 		return err
 	}
@@ -64,6 +79,26 @@ func collectMLNodeInputs(state *config.State) error {
 	return nil
 }
 
+// applyMLNodeSpec fills unset ML node inputs from the setup spec so the
+// prompts below are skipped.
+func applyMLNodeSpec(state *config.State) {
+	spec := state.Spec()
+	if nn := spec.NetworkNode; nn != nil {
+		if state.NetworkNodeURL == "" {
+			state.NetworkNodeURL = nn.URL
+		}
+		if state.NetworkNodeIP == "" {
+			state.NetworkNodeIP = nn.IP
+		}
+	}
+	if state.PublicIP == "" {
+		state.PublicIP = spec.PublicIP
+	}
+	if state.HFHome == "" {
+		state.HFHome = spec.HFHome
+	}
+}
+
 // promptNetworkNodeInfo asks for the remote network node's Admin API URL and private IP.
 func promptNetworkNodeInfo(state *config.State) error {
 	if state.NetworkNodeURL == "" {
@@ -158,18 +193,28 @@ func (p *MLNodeConfig) generateMLNodeConfigs(state *config.State) error {
 
 // generateMLNodeCompose generates docker-compose.mlnode.yml for standalone ML node.
 func (p *MLNodeConfig) generateMLNodeCompose(state *config.State) error {
-	// Image priority: custom full image > GPU detection tag > GitHub version > hardcoded fallback
+	content, err := renderStandaloneMLNodeCompose(state)
+	if err != nil {
+		return err
+	}
+	if err := writeConfigFile(state, "docker-compose.mlnode.yml", content); err != nil {
+		return err
+	}
+	ui.Success("Generated %s", filepath.Join(state.OutputDir, "docker-compose.mlnode.yml"))
+	return nil
+}
+
+// renderStandaloneMLNodeCompose returns docker-compose.mlnode.yml for the
+// mlnode-only topology.
+func renderStandaloneMLNodeCompose(state *config.State) (string, error) {
+	// Image priority: custom full image > GPU detection / GitHub tag > hardcoded fallback
 	var mlnodeImage string
 	if state.CustomMLNodeImage != "" {
 		mlnodeImage = state.CustomMLNodeImage
 	} else {
-		mlnodeTag := state.MLNodeImageTag
+		mlnodeTag := state.Versions.MLNode
 		if mlnodeTag == "" {
-			if state.Versions.MLNode != "" {
-				mlnodeTag = state.Versions.MLNode
-			} else {
-				mlnodeTag = DefaultMLNodeImageTag
-			}
+			mlnodeTag = DefaultMLNodeImageTag
 		}
 		mlnodeImage = DefaultMLNodeImage + ":" + mlnodeTag
 	}
@@ -186,115 +231,48 @@ func (p *MLNodeConfig) generateMLNodeCompose(state *config.State) error {
 
 	// For MLNode-only: bind ports to this server's IP so only the private network
 	// can reach them. NEVER 0.0.0.0 (public exposure = hijack risk per validator chat).
-	bindIP := state.PublicIP
-	content := fmt.Sprintf(`services:
-  mlnode-308:
-    image: %s
-    hostname: mlnode-308
-    restart: always
-    ipc: host
-    command: uvicorn api.app:app --host=0.0.0.0 --port=8080
-    volumes:
-      - %s:/root/.cache
-    deploy:
-      resources:
-        reservations:
-          devices:
-            - driver: nvidia
-              count: all
-              capabilities: [gpu]
-    environment:
-      - HF_HOME=/root/.cache
-      - VLLM_ATTENTION_BACKEND=%s
-
-  inference:
-    image: %s
-    hostname: inference
-    restart: always
-    ports:
-      - "%s:%d:5000"
-      - "%s:%d:8080"
-    volumes:
-      - ./nginx.conf:/etc/nginx/nginx.conf:ro
-`, mlnodeImage, state.HFHome, backend, nginxImage, bindIP, state.InferencePort, bindIP, state.PoCPort)
-
-	outPath := filepath.Join(state.OutputDir, "docker-compose.mlnode.yml")
-	ui.Success("Generated %s", outPath)
-	return os.WriteFile(outPath, []byte(content), 0600)
+	return renderTemplate("docker-compose.mlnode-standalone.yml", mlnodeComposeModel{
+		Image:            mlnodeImage,
+		HFHome:           state.HFHome,
+		AttentionBackend: backend,
+		NginxImage:       nginxImage,
+		BindIP:           state.PublicIP,
+		InferencePort:    state.InferencePort,
+		PoCPort:          state.PoCPort,
+	})
 }
 
 // generateNginxConf generates nginx.conf for local routing to mlnode-308.
 // Uses the official Gonka nginx template with version-prefix stripping
 // (e.g., /v3.0.8/api/v1/state → /api/v1/state) and long timeouts.
 func (p *MLNodeConfig) generateNginxConf(state *config.State) error {
-	content := `events {}
-
-http {
-    resolver 127.0.0.11 valid=10s;
-    resolver_timeout 5s;
-
-    upstream mlnode_v308 {
-        zone mlnode_v308 64k;
-        server mlnode-308:8080 resolve;
-    }
-
-    server {
-        listen 8080;
-        client_max_body_size 0;
-        proxy_connect_timeout 24h;
-        proxy_send_timeout 24h;
-        proxy_read_timeout 24h;
-
-        location /v3.0.8/ {
-            proxy_pass http://mlnode_v308/;
-            proxy_set_header Host $host;
-            proxy_set_header X-Real-IP $remote_addr;
-            proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
-        }
-
-        location / {
-            proxy_pass http://mlnode_v308/;
-            proxy_set_header Host $host;
-            proxy_set_header X-Real-IP $remote_addr;
-            proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
-        }
-    }
-
-    upstream mlnode_v308_port5000 {
-        zone mlnode_v308_port5000 64k;
-        server mlnode-308:5000 resolve;
-    }
-
-    server {
-        listen 5000;
-        client_max_body_size 0;
-        proxy_connect_timeout 24h;
-        proxy_send_timeout 24h;
-        proxy_read_timeout 24h;
-
-        location /v3.0.8/ {
-            proxy_pass http://mlnode_v308_port5000/;
-            proxy_set_header Host $host;
-            proxy_set_header X-Real-IP $remote_addr;
-            proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
-        }
-
-        location / {
-            proxy_pass http://mlnode_v308_port5000/;
-            proxy_set_header Host $host;
-            proxy_set_header X-Real-IP $remote_addr;
-            proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
-        }
-    }
+	content, err := renderStandaloneNginxConf()
+	if err != nil {
+		return err
+	}
+	if err := writeConfigFile(state, "nginx.conf", content); err != nil {
+		return err
+	}
+	ui.Success("Generated %s", filepath.Join(state.OutputDir, "nginx.conf"))
+	return nil
 }
-`
-	outPath := filepath.Join(state.OutputDir, "nginx.conf")
-	ui.Success("Generated %s", outPath)
-	return os.WriteFile(outPath, []byte(content), 0600)
+
+// renderStandaloneNginxConf returns nginx.conf for the mlnode-only topology.
+func renderStandaloneNginxConf() (string, error) {
+	return renderTemplate("nginx-standalone.conf", mlnodeNginx)
 }
 
 // generateMLNodeEnv generates a minimal config.env for ML-related variables only.
 func (p *MLNodeConfig) generateMLNodeEnv(state *config.State) error {
+	if err := writeConfigFile(state, "config.env", renderMLNodeEnv(state)); err != nil {
+		return err
+	}
+	ui.Success("Generated %s", filepath.Join(state.OutputDir, "config.env"))
+	return nil
+}
+
+// renderMLNodeEnv returns the mlnode-only config.env.
+func renderMLNodeEnv(state *config.State) string {
 	model := state.SelectedModel
 	if model == "" {
 		model = DefaultModel
@@ -318,10 +296,7 @@ HF_HOME=%s
 PORT=%d
 INFERENCE_PORT=%d
 `, model, backend, state.HFHome, state.PoCPort, state.InferencePort)
-
-	outPath := filepath.Join(state.OutputDir, "config.env")
-	ui.Success("Generated %s", outPath)
-	return os.WriteFile(outPath, []byte(content), 0600)
+	return content
 }
 
 // mlnodeRegistration is the JSON structure for Admin API POST /admin/v1/nodes.
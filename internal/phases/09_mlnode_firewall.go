@@ -4,21 +4,16 @@ import (
 	"context"
 	"fmt"
 	"net"
-	"os"
-	"os/exec"
+	"strconv"
 	"strings"
 
 	"github.com/inc4/gonka-nop/internal/config"
+	"github.com/inc4/gonka-nop/internal/firewall"
 	"github.com/inc4/gonka-nop/internal/ui"
 )
 
-// inferenceContainerPort is the port uvicorn listens on inside the mlnode-308
-// container. The host maps state.InferencePort (default 5050) → 5000 via Docker,
-// so in the DOCKER-USER chain (post-DNAT) we must use the container port.
-const inferenceContainerPort = 5000
-
-// MLNodeFirewall configures iptables DOCKER-USER rules to restrict mlnode ports
-// to accept connections only from the network node IP.
+// MLNodeFirewall restricts mlnode ports to accept connections only from the
+// network node IP.
 type MLNodeFirewall struct{}
 
 func NewMLNodeFirewall() *MLNodeFirewall {
@@ -30,7 +25,7 @@ func (p *MLNodeFirewall) Name() string {
 }
 
 func (p *MLNodeFirewall) Description() string {
-	return "Restricting mlnode ports to network node IP only via DOCKER-USER iptables"
+	return "Restricting mlnode ports to network node IP only"
 }
 
 // ShouldRun returns true when:
@@ -41,8 +36,16 @@ func (p *MLNodeFirewall) ShouldRun(state *config.State) bool {
 	return state.IsMLNodeOnly() && isPublicIP(state.PublicIP) && !state.FirewallConfigured
 }
 
-// Run applies iptables DROP rules in the DOCKER-USER chain for ports 8080 (PoC)
-// and 5000 (inference container port), then persists the rules.
+func (p *MLNodeFirewall) DryRun(state *config.State) []Action {
+	plan, err := firewall.PlanMLNode(state)
+	if err != nil {
+		return nil // Run only prints a warning
+	}
+	return firewallActions(plan)
+}
+
+// Run restricts ports 8080 (PoC) and 5000 (inference container port) to the
+// network node, using the host's firewall backend (nftables, ufw or firewalld).
 //
 // Two cases:
 //   - Same server (public_ip == network_node_ip): Docker bridge traffic arrives
@@ -50,132 +53,100 @@ func (p *MLNodeFirewall) ShouldRun(state *config.State) bool {
 //   - Separate server: traffic arrives from the real network node IP, so we
 //     allow only that specific IP and drop the rest.
 //
-// Non-fatal: prints manual commands on iptables failure and continues.
-func (p *MLNodeFirewall) Run(_ context.Context, state *config.State) error {
-	networkNodeIP := state.NetworkNodeIP
-	if networkNodeIP == "" {
+// Applying replaces earlier gonka-nop rules, so re-runs are safe.
+// Non-fatal: prints the rules to apply by hand on failure and continues.
+func (p *MLNodeFirewall) Run(ctx context.Context, state *config.State) error {
+	plan, err := firewall.PlanMLNode(state)
+	if err != nil {
 		ui.Warn("Network node IP unknown — skipping port restriction")
-		ui.Detail("Restrict ports %d and %d manually via iptables DOCKER-USER", state.PoCPort, state.InferencePort)
+		ui.Detail("Restrict ports %d and %d manually to the network node IP", state.PoCPort, state.InferencePort)
 		return nil
 	}
 
-	pocPort := state.PoCPort
-	if pocPort == 0 {
-		pocPort = 8080
-	}
-
-	// Determine allowed source and topology label.
-	var allowedSrc, topoLabel string
-	if state.PublicIP == networkNodeIP {
-		// Same-server: network node containers use the Docker bridge network.
-		allowedSrc = "172.16.0.0/12"
+	topoLabel := "separate-server (" + state.NetworkNodeIP + ")"
+	if state.PublicIP == state.NetworkNodeIP {
 		topoLabel = "same-server (Docker bridge)"
-	} else {
-		// Separate-server: allow the real network node IP only.
-		allowedSrc = networkNodeIP
-		topoLabel = "separate-server (" + networkNodeIP + ")"
 	}
-
 	ui.Info("Restricting ports %d (PoC) and %d (inference) — %s",
-		pocPort, inferenceContainerPort, topoLabel)
-
-	// Container ports used in DOCKER-USER (post-DNAT):
-	//   PoCPort host == PoCPort container (both 8080 by default)
-	//   InferencePort host (5050) → container port 5000
-	ports := []int{pocPort, inferenceContainerPort}
-
-	var failed []int
-	for _, port := range ports {
-		portStr := fmt.Sprintf("%d", port)
-		if err := runIPTables(state.UseSudo, "-I", "DOCKER-USER",
-			"-p", "tcp", "--dport", portStr,
-			"!", "-s", allowedSrc, "-j", "DROP"); err != nil {
-			ui.Warn("iptables DROP port %d: %v", port, err)
-			failed = append(failed, port)
-		} else {
-			ui.Success("Port %d: blocked for all except %s", port, allowedSrc)
-		}
-	}
+		plan.Rules[0].Port, firewall.InferenceContainerPort, topoLabel)
 
-	if len(failed) > 0 {
-		ui.Warn("Firewall not fully configured — apply manually on this server:")
-		for _, port := range ports {
-			ui.Detail("  sudo iptables -I DOCKER-USER -p tcp --dport %d ! -s %s -j DROP",
-				port, allowedSrc)
-		}
-		ui.Detail("  sudo mkdir -p /etc/iptables && sudo iptables-save > /etc/iptables/rules.v4")
-		return nil
+	if applyFirewall(ctx, state, plan) {
+		state.FirewallConfigured = true
 	}
-
-	if err := persistIPTables(state.UseSudo); err != nil {
-		ui.Warn("Rules active but not persisted across reboots: %v", err)
-		ui.Detail("To persist manually: sudo mkdir -p /etc/iptables && sudo iptables-save > /etc/iptables/rules.v4")
-	} else {
-		ui.Success("Firewall rules saved (persistent across reboots)")
-	}
-
-	state.FirewallConfigured = true
 	return nil
 }
 
-// runIPTables runs an iptables command, optionally prefixed with sudo.
-func runIPTables(useSudo bool, args ...string) error {
-	var cmd *exec.Cmd
-	if useSudo {
-		cmd = exec.Command(cmdSudo, append([]string{"iptables"}, args...)...)
-	} else {
-		cmd = exec.Command("iptables", args...)
+// firewallActions lists the rules applyFirewall would install for plan.
+func firewallActions(plan *firewall.Plan) []Action {
+	actions := make([]Action, 0, len(plan.Rules)+len(plan.Limits)+1)
+	for _, r := range plan.Rules {
+		actions = append(actions, Action{ActionFirewall,
+			fmt.Sprintf("port %d: blocked for all except %s", r.Port, strings.Join(r.Allow, ", "))})
+	}
+	for _, l := range plan.Limits {
+		detail := fmt.Sprintf("port %d: at most %d connections per source IP", l.Port, l.MaxConns)
+		if l.Rate > 0 {
+			detail = fmt.Sprintf("port %d: new connections limited to %d/s per source IP", l.Port, l.Rate)
+		}
+		actions = append(actions, Action{ActionFirewall, detail})
 	}
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	if len(plan.Open) > 0 {
+		actions = append(actions, Action{ActionFirewall,
+			"published ports reachable from outside: " + portList(plan.Open) + " (container ports)"})
 	}
-	return nil
+	return actions
 }
 
-// persistIPTables saves iptables rules so they survive reboots.
-// Tries netfilter-persistent first, then writes rules.v4 and installs
-// an if-pre-up hook for auto-restore on network bring-up.
-func persistIPTables(useSudo bool) error {
-	// Try netfilter-persistent (Debian/Ubuntu with the package installed)
-	args := []string{"netfilter-persistent", "save"}
-	var cmd *exec.Cmd
-	if useSudo {
-		cmd = exec.Command(cmdSudo, args...)
-	} else {
-		cmd = exec.Command(args[0], args[1:]...)
-	}
-	if err := cmd.Run(); err == nil {
-		return nil
+func portList(ports []int) string {
+	list := make([]string, len(ports))
+	for i, port := range ports {
+		list[i] = strconv.Itoa(port)
 	}
+	return strings.Join(list, ", ")
+}
 
-	// Fallback: write rules.v4 + install if-pre-up.d restore hook
-	if err := os.MkdirAll("/etc/iptables", 0750); err != nil {
-		return fmt.Errorf("mkdir /etc/iptables: %w", err)
+// applyFirewall installs plan with the detected backend. On failure it
+// prints the rendered rules for manual application and returns false.
+func applyFirewall(ctx context.Context, state *config.State, plan *firewall.Plan) bool {
+	runner := firewall.ExecRunner{UseSudo: state.UseSudo}
+	backend, err := firewall.Detect(ctx, runner, state.Distro.Family)
+	if err != nil {
+		ui.Warn("Firewall not configured: %v", err)
+		return false
 	}
+	ui.Detail("Firewall backend: %s", backend.Name())
 
-	saveCmd := "iptables-save > /etc/iptables/rules.v4"
-	if useSudo {
-		cmd = exec.Command(cmdSudo, "sh", "-c", saveCmd)
-	} else {
-		cmd = exec.Command("sh", "-c", saveCmd)
+	if err := backend.Apply(ctx, runner, plan); err != nil {
+		ui.Warn("Firewall not configured (%s): %v", backend.Name(), err)
+		ui.Detail("Rules to apply manually:")
+		for _, line := range strings.Split(strings.TrimSpace(backend.Render(plan)), "\n") {
+			ui.Detail("  %s", line)
+		}
+		return false
 	}
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("iptables-save: %w", err)
+	for _, r := range plan.Rules {
+		ui.Success("Port %d: blocked for all except %s", r.Port, strings.Join(r.Allow, ", "))
 	}
-
-	// Install if-pre-up.d hook so rules reload on every network restart
-	hookPath := "/etc/network/if-pre-up.d/iptables"
-	writeCmd := fmt.Sprintf("printf '#!/bin/sh\\niptables-restore < /etc/iptables/rules.v4\\n' > %s && chmod +x %s",
-		hookPath, hookPath)
-	if useSudo {
-		cmd = exec.Command(cmdSudo, "sh", "-c", writeCmd)
-	} else {
-		cmd = exec.Command("sh", "-c", writeCmd)
+	for _, l := range plan.Limits {
+		if l.Rate > 0 {
+			ui.Success("Port %d: new connections limited to %d/s per source IP", l.Port, l.Rate)
+		} else {
+			ui.Success("Port %d: at most %d connections per source IP", l.Port, l.MaxConns)
+		}
 	}
-	_ = cmd.Run() // non-fatal if hook install fails
-
-	return nil
+	if len(plan.Open) > 0 {
+		ui.Success("Published ports reachable from outside: %s (container ports)", portList(plan.Open))
+	}
+	// Earlier versions inserted untagged rules on every run.
+	if legacy := firewall.FindLegacy(ctx, runner, plan.Ports()); len(legacy) > 0 {
+		if err := firewall.RemoveLegacy(ctx, runner, legacy); err != nil {
+			ui.Warn("Could not remove old iptables rules: %v", err)
+		} else {
+			ui.Success("Removed %d old untagged iptables rule(s)", len(legacy))
+		}
+	}
+	ui.Success("Firewall rules saved (persistent across reboots)")
+	return true
 }
 
 // isPublicIP returns true if ip is a routable public address (not RFC1918/loopback/link-local).
@@ -0,0 +1,85 @@
+package phases
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/inc4/gonka-nop/internal/config"
+	"github.com/inc4/gonka-nop/internal/firewall"
+	"github.com/inc4/gonka-nop/internal/ui"
+)
+
+// NetworkFirewall protects a network (or full) node: only P2P and the
+// public API are reachable from outside, new API connections are
+// rate-limited and P2P connections capped per source IP, and the internal
+// ports are checked to be bound to loopback.
+type NetworkFirewall struct{}
+
+func NewNetworkFirewall() *NetworkFirewall {
+	return &NetworkFirewall{}
+}
+
+func (p *NetworkFirewall) Name() string {
+	return "Network Node Firewall"
+}
+
+func (p *NetworkFirewall) Description() string {
+	return "Opening only P2P and the public API, with per-IP connection limits"
+}
+
+// ShouldRun returns true on network and full nodes until the firewall and
+// the connection limits are both in place.
+func (p *NetworkFirewall) ShouldRun(state *config.State) bool {
+	return !state.IsMLNodeOnly() && !(state.FirewallConfigured && state.DDoSProtection)
+}
+
+func (p *NetworkFirewall) DryRun(state *config.State) []Action {
+	return append(firewallActions(firewall.PlanNetworkNode(state)),
+		Action{ActionRun, dockerCommand(state, "ps", "--format", "{{.Ports}}") + " (check internal ports are bound to 127.0.0.1)"})
+}
+
+// Run applies the network node plan, then verifies the internal port
+// bindings. Each result is recorded in state so 'gonka-nop status' reports
+// what is actually in place. Non-fatal, like MLNodeFirewall.
+func (p *NetworkFirewall) Run(ctx context.Context, state *config.State) error {
+	plan := firewall.PlanNetworkNode(state)
+	api := strconv.Itoa(state.APIPort)
+	if state.TLSEnabled() {
+		api = fmt.Sprintf("%d/%d", config.TLSHTTPPort, config.TLSHTTPSPort)
+	}
+	ui.Info("Opening P2P (%d) and the public API (%s); restricting %d, %d and %d",
+		state.P2PPort, api, firewall.RPCPort, firewall.MLCallbackPort, firewall.AdminPort)
+	if !firewall.CallbackRestricted(state) {
+		ui.Warn("ML callback port %d stays open: ML nodes reach this server over a public IP", firewall.MLCallbackPort)
+	}
+
+	state.FirewallConfigured = applyFirewall(ctx, state, plan)
+	state.DDoSProtection = state.FirewallConfigured && len(plan.Limits) > 0
+
+	state.InternalPortsBound = verifyLoopbackBindings(ctx, state)
+	return nil
+}
+
+// verifyLoopbackBindings checks that RPC, the ML callback and the Admin API
+// are published on 127.0.0.1 only. A network-only node publishes 9100 on
+// purpose for its remote ML nodes.
+func verifyLoopbackBindings(ctx context.Context, state *config.State) bool {
+	ports := []int{firewall.RPCPort, firewall.AdminPort}
+	if !state.IsNetworkOnly() {
+		ports = append(ports, firewall.MLCallbackPort)
+	}
+	exposed, err := firewall.ExposedBindings(ctx, firewall.ExecRunner{UseSudo: state.UseSudo}, ports)
+	if err != nil {
+		ui.Warn("Could not verify port bindings: %v", err)
+		return false
+	}
+	if len(exposed) > 0 {
+		ui.Warn("Internal ports published beyond loopback: %s", strings.Join(exposed, ", "))
+		ui.Detail("Bind them to 127.0.0.1 in docker-compose.yml and recreate the containers")
+		return false
+	}
+	ui.Success("Internal ports bound to 127.0.0.1")
+	return true
+}
@@ -0,0 +1,30 @@
+package phases
+
+import (
+	"testing"
+
+	"github.com/inc4/gonka-nop/internal/config"
+)
+
+func TestNetworkFirewall_ShouldRun(t *testing.T) {
+	phase := NewNetworkFirewall()
+
+	tests := []struct {
+		name     string
+		state    *config.State
+		expected bool
+	}{
+		{"full node, nothing applied", &config.State{NodeType: config.NodeTypeFull}, true},
+		{"network node, firewall only", &config.State{NodeType: config.NodeTypeNetwork, FirewallConfigured: true}, true},
+		{"network node, fully protected", &config.State{NodeType: config.NodeTypeNetwork, FirewallConfigured: true, DDoSProtection: true}, false},
+		{"ML node only", &config.State{NodeType: config.NodeTypeMLNode}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := phase.ShouldRun(tt.state); got != tt.expected {
+				t.Errorf("ShouldRun() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
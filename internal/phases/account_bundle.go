@@ -0,0 +1,108 @@
+package phases
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ripemd160" // #nosec G507 - Cosmos SDK address derivation
+)
+
+// AccountBundleVersion is the bundle format written by `gonka-nop init-account`.
+const AccountBundleVersion = 1
+
+// accountAddressPrefix is the bech32 human-readable part of account addresses.
+const accountAddressPrefix = "gonka"
+
+// AccountBundle carries the public half of an account (cold) key from the
+// offline machine to the server. It never contains private material.
+type AccountBundle struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	Address   string    `json:"address"`
+	PubKey    string    `json:"pubkey"` // base64, as ExtractPubKeyBase64 returns
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewAccountBundle builds a bundle from `inferenced keys add` output.
+func NewAccountBundle(name string, key *KeyOutput, createdAt time.Time) (*AccountBundle, error) {
+	b := &AccountBundle{
+		Version:   AccountBundleVersion,
+		Name:      name,
+		Address:   key.Address,
+		PubKey:    ExtractPubKeyBase64(key.PubKey),
+		CreatedAt: createdAt.UTC(),
+	}
+	if err := b.Verify(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Verify checks the bundle version and key format, and that the address is
+// the pubkey's. A bundle edited on the way to the server fails the latter.
+func (b *AccountBundle) Verify() error {
+	if b.Version != AccountBundleVersion {
+		return fmt.Errorf("unsupported account bundle version %d", b.Version)
+	}
+	if err := ValidateAccountPubKey(b.PubKey); err != nil {
+		return err
+	}
+	raw, _ := base64.StdEncoding.DecodeString(b.PubKey)
+	if want := AccountAddress(raw); b.Address != want {
+		return fmt.Errorf("account bundle address %s does not belong to its pubkey (want %s)", b.Address, want)
+	}
+	return nil
+}
+
+// AccountAddress derives the bech32 account address of a compressed
+// secp256k1 pubkey, as the Cosmos SDK does: RIPEMD-160 of its SHA-256.
+func AccountAddress(pubkey []byte) string {
+	sha := sha256.Sum256(pubkey)
+	h := ripemd160.New()
+	_, _ = h.Write(sha[:])
+	return bech32Encode(accountAddressPrefix, h.Sum(nil))
+}
+
+// ValidateAccountPubKey checks that pubkey is a base64 compressed secp256k1 key.
+func ValidateAccountPubKey(pubkey string) error {
+	raw, err := base64.StdEncoding.DecodeString(pubkey)
+	if err != nil {
+		return fmt.Errorf("account pubkey is not base64: %w", err)
+	}
+	if len(raw) != 33 || (raw[0] != 0x02 && raw[0] != 0x03) {
+		return fmt.Errorf("account pubkey is not a compressed secp256k1 key (%d bytes)", len(raw))
+	}
+	return nil
+}
+
+// ResolveAccountPubKey accepts a raw pubkey, an account bundle JSON, or the
+// path to a bundle file, and returns the pubkey and (for bundles) the address.
+func ResolveAccountPubKey(value string) (pubkey, address string, err error) {
+	value = strings.TrimSpace(value)
+	data := []byte(value)
+	if !strings.HasPrefix(value, "{") {
+		info, statErr := os.Stat(value)
+		if statErr != nil || !info.Mode().IsRegular() {
+			return value, "", nil // raw pubkey
+		}
+		data, err = os.ReadFile(filepath.Clean(value)) // #nosec G304 - path from CLI flag
+		if err != nil {
+			return "", "", fmt.Errorf("read account bundle: %w", err)
+		}
+	}
+
+	var b AccountBundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return "", "", fmt.Errorf("parse account bundle: %w", err)
+	}
+	if err := b.Verify(); err != nil {
+		return "", "", err
+	}
+	return b.PubKey, b.Address, nil
+}
@@ -0,0 +1,135 @@
+package phases
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPubKey is the compressed pubkey of a fixed account key.
+func testPubKey() string {
+	return "AoS/dWImK71pQAhXSPO+avpSrjFxVRgezjG2Y1HM/6Sw"
+}
+
+func testKeyOutput() *KeyOutput {
+	raw, _ := base64.StdEncoding.DecodeString(testPubKey())
+	return &KeyOutput{
+		Name:    "gonka-account-key",
+		Address: AccountAddress(raw),
+		PubKey:  `{"@type":"/cosmos.crypto.secp256k1.PubKey","key":"` + testPubKey() + `"}`,
+	}
+}
+
+func TestValidateAccountPubKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		pubkey  string
+		wantErr bool
+	}{
+		{name: "compressed", pubkey: testPubKey()},
+		{name: "not base64", pubkey: "gonkapub1addwnpepq", wantErr: true},
+		{name: "too short", pubkey: base64.StdEncoding.EncodeToString([]byte{0x02, 1, 2}), wantErr: true},
+		{name: "uncompressed prefix", pubkey: base64.StdEncoding.EncodeToString(append([]byte{0x04}, make([]byte, 32)...)), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateAccountPubKey(tt.pubkey); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateAccountPubKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveAccountPubKey(t *testing.T) {
+	bundle, err := NewAccountBundle("gonka-account-key", testKeyOutput(), time.Now())
+	if err != nil {
+		t.Fatalf("NewAccountBundle() error: %v", err)
+	}
+	if bundle.PubKey != testPubKey() {
+		t.Errorf("PubKey = %q, want the base64 key from the pubkey JSON", bundle.PubKey)
+	}
+	data, _ := json.Marshal(bundle)
+	path := filepath.Join(t.TempDir(), "gonka-account.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, value := range []string{path, string(data)} {
+		pubkey, address, err := ResolveAccountPubKey(value)
+		if err != nil {
+			t.Fatalf("ResolveAccountPubKey() error: %v", err)
+		}
+		if pubkey != testPubKey() || address != testKeyOutput().Address {
+			t.Errorf("ResolveAccountPubKey() = %q, %q", pubkey, address)
+		}
+	}
+
+	// A raw key is passed through
+	if pubkey, address, err := ResolveAccountPubKey(" " + testPubKey() + "\n"); err != nil || pubkey != testPubKey() || address != "" {
+		t.Errorf("raw key: %q, %q, %v", pubkey, address, err)
+	}
+
+	// A bundle whose pubkey was swapped no longer matches its address
+	tampered := strings.Replace(string(data), testPubKey(), testOtherPubKey, 1)
+	if _, _, err := ResolveAccountPubKey(tampered); err == nil || !strings.Contains(err.Error(), "does not belong") {
+		t.Errorf("expected address mismatch error, got %v", err)
+	}
+}
+
+// testOtherPubKey is the compressed pubkey of a second account key.
+const testOtherPubKey = "Aly98GRuXbTqo5jzZfLqeg49QZt+AzDjnOkr3e3KxPm8"
+
+func TestAccountBundleVerify(t *testing.T) {
+	otherPub, _ := base64.StdEncoding.DecodeString(testOtherPubKey)
+
+	tests := []struct {
+		name    string
+		edit    func(b *AccountBundle)
+		wantErr string
+	}{
+		{name: "valid", edit: func(*AccountBundle) {}},
+		{name: "address of another key", edit: func(b *AccountBundle) { b.Address = AccountAddress(otherPub) }, wantErr: "does not belong"},
+		{name: "pubkey swapped", edit: func(b *AccountBundle) { b.PubKey = testOtherPubKey }, wantErr: "does not belong"},
+		{name: "unknown version", edit: func(b *AccountBundle) { b.Version = 2 }, wantErr: "version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewAccountBundle("gonka-account-key", testKeyOutput(), time.Now())
+			if err != nil {
+				t.Fatalf("NewAccountBundle() error: %v", err)
+			}
+			tt.edit(b)
+			err = b.Verify()
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Verify() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBech32Encode(t *testing.T) {
+	// BIP-173 valid strings
+	if got := bech32Encode("a", nil); got != "a12uel5l" {
+		t.Errorf("bech32Encode(a) = %s", got)
+	}
+	values := make([]byte, 32)
+	for i := range values {
+		values[i] = byte(i)
+	}
+	data := convertBits(values, 5, 8)
+	if got := bech32Encode("abcdef", data); got != "abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw" {
+		t.Errorf("bech32Encode(abcdef) = %s", got)
+	}
+}
+
+func TestNewAccountBundle_RejectsBadKey(t *testing.T) {
+	key := testKeyOutput()
+	key.PubKey = "gonkapub1addwnpepq"
+	if _, err := NewAccountBundle("k", key, time.Now()); err == nil {
+		t.Error("expected error for a malformed pubkey")
+	}
+}
@@ -0,0 +1,76 @@
+package phases
+
+import "strings"
+
+// bech32Charset is the BIP-173 data alphabet.
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// bech32Encode encodes data (8-bit bytes) as a BIP-173 bech32 string with
+// the given human-readable prefix.
+func bech32Encode(hrp string, data []byte) string {
+	values := convertBits(data, 8, 5)
+	checksum := bech32Checksum(hrp, values)
+
+	var b strings.Builder
+	b.WriteString(hrp)
+	b.WriteByte('1')
+	for _, v := range append(values, checksum...) {
+		b.WriteByte(bech32Charset[v])
+	}
+	return b.String()
+}
+
+// convertBits regroups data from fromBits-wide to toBits-wide values,
+// padding the last group with zeros.
+func convertBits(data []byte, fromBits, toBits uint) []byte {
+	var out []byte
+	acc, bits := uint(0), uint(0)
+	maxv := uint(1)<<toBits - 1
+	for _, d := range data {
+		acc = acc<<fromBits | uint(d)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+	if bits > 0 {
+		out = append(out, byte(acc<<(toBits-bits)&maxv))
+	}
+	return out
+}
+
+func bech32Checksum(hrp string, values []byte) []byte {
+	input := make([]byte, 0, 2*len(hrp)+1+len(values)+6)
+	for i := 0; i < len(hrp); i++ {
+		input = append(input, hrp[i]>>5)
+	}
+	input = append(input, 0)
+	for i := 0; i < len(hrp); i++ {
+		input = append(input, hrp[i]&31)
+	}
+	input = append(input, values...)
+	input = append(input, 0, 0, 0, 0, 0, 0)
+
+	mod := bech32Polymod(input) ^ 1
+	checksum := make([]byte, 6)
+	for i := range checksum {
+		checksum[i] = byte(mod >> (5 * (5 - i)) & 31)
+	}
+	return checksum
+}
+
+func bech32Polymod(values []byte) uint32 {
+	gen := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if top>>i&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/inc4/gonka-nop/internal/config"
+	"github.com/inc4/gonka-nop/internal/ui"
 )
 
 const (
@@ -171,36 +172,82 @@ func TestBuildVLLMArgs_DefaultMemoryUtil(t *testing.T) {
 	t.Error("--gpu-memory-utilization not found in args")
 }
 
-func TestFormatJSONArgs(t *testing.T) {
-	tests := []struct {
-		name string
-		args []string
-		want string
-	}{
-		{
-			name: "Empty args",
-			args: []string{},
-			want: "",
-		},
-		{
-			name: "Single arg",
-			args: []string{"--quantization"},
-			want: `"--quantization"`,
-		},
-		{
-			name: "Multiple args",
-			args: []string{"--gpu-memory-utilization", "0.90", "--tensor-parallel-size", "4"},
-			want: `"--gpu-memory-utilization", "0.90", "--tensor-parallel-size", "4"`,
-		},
+func TestRenderNodeConfig_EscapesJSON(t *testing.T) {
+	state := config.NewState(t.TempDir())
+	state.MLNodeID = `node "a"`
+	state.SelectedModel = `org/model\<v2>`
+	state.KVCacheDtype = "fp8_e4m3"
+	state.GPUs = []config.GPUInfo{{Name: "NVIDIA RTX 6000 Ada \"Generation\"", MemoryMB: 49152}}
+
+	var nodes []struct {
+		ID     string `json:"id"`
+		Models map[string]struct {
+			Args []string `json:"args"`
+		} `json:"models"`
+		Hardware []struct {
+			Type  string `json:"type"`
+			Count int    `json:"count"`
+		} `json:"hardware"`
+	}
+	content, err := renderNodeConfig(state)
+	if err != nil {
+		t.Fatalf("renderNodeConfig() error: %v", err)
+	}
+	if err := json.Unmarshal([]byte(content), &nodes); err != nil {
+		t.Fatalf("node-config.json is not valid JSON: %v\n%s", err, content)
+	}
+	if len(nodes) != 1 || nodes[0].ID != state.MLNodeID {
+		t.Fatalf("nodes = %+v, want one with id %q", nodes, state.MLNodeID)
+	}
+	model, ok := nodes[0].Models[state.SelectedModel]
+	if !ok {
+		t.Fatalf("models = %v, want %q", nodes[0].Models, state.SelectedModel)
+	}
+	if want := []string{"--gpu-memory-utilization", "0.90", "--kv-cache-dtype", "fp8_e4m3"}; strings.Join(model.Args, " ") != strings.Join(want, " ") {
+		t.Errorf("args = %q, want %q", model.Args, want)
+	}
+	if want := state.GPUs[0].Name + " | 48GB"; nodes[0].Hardware[0].Type != want {
+		t.Errorf("hardware type = %q, want %q", nodes[0].Hardware[0].Type, want)
 	}
+	if strings.Contains(content, `\u003c`) {
+		t.Errorf("node-config.json HTML-escapes <, want it readable:\n%s", content)
+	}
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := formatJSONArgs(tt.args)
-			if got != tt.want {
-				t.Errorf("formatJSONArgs() = %q, want %q", got, tt.want)
-			}
-		})
+func TestRenderTemplate_Error(t *testing.T) {
+	if _, err := renderTemplate("nginx.conf", struct{}{}); err == nil || !strings.Contains(err.Error(), "render nginx.conf") {
+		t.Errorf("renderTemplate() with the wrong model = %v, want a render error", err)
+	}
+}
+
+func TestRenderStandaloneMLNode(t *testing.T) {
+	state := config.NewState("/tmp/test")
+	state.NodeType = config.NodeTypeMLNode
+	state.PublicIP = testAltIP
+	state.HFHome = "/data/hf"
+
+	compose, err := renderStandaloneMLNodeCompose(state)
+	if err != nil {
+		t.Fatalf("renderStandaloneMLNodeCompose() error: %v", err)
+	}
+	for _, want := range []string{
+		"      - /data/hf:/root/.cache\n",
+		`      - "` + testAltIP + `:5050:5000"`,
+		`      - "` + testAltIP + `:8080:8080"`,
+	} {
+		if !strings.Contains(compose, want) {
+			t.Errorf("docker-compose.mlnode.yml missing %q:\n%s", want, compose)
+		}
+	}
+
+	nginx, err := renderStandaloneNginxConf()
+	if err != nil {
+		t.Fatalf("renderStandaloneNginxConf() error: %v", err)
+	}
+	for _, want := range []string{"listen 8080;\n", "listen 5000;\n", "server mlnode-308:5000 resolve;", "location /v3.0.8/ {"} {
+		if !strings.Contains(nginx, want) {
+			t.Errorf("nginx.conf missing %q:\n%s", want, nginx)
+		}
 	}
 }
 
@@ -237,6 +284,7 @@ func TestGenerateConfigEnv(t *testing.T) {
 	state.HFHome = "/mnt/hf"
 	state.SelectedModel = defaultModel
 	state.AttentionBackend = defaultAttentionBackend
+	state.KeyringPassword = "s3cret-keyring"
 
 	if err := generateConfigEnv(state); err != nil {
 		t.Fatalf("generateConfigEnv() error: %v", err)
@@ -248,6 +296,10 @@ func TestGenerateConfigEnv(t *testing.T) {
 	}
 	content := string(data)
 
+	if strings.Contains(content, "KEYRING_PASSWORD=") || strings.Contains(content, "s3cret-keyring") {
+		t.Error("config.env must not contain the keyring password")
+	}
+
 	checks := []struct {
 		label    string
 		contains string
@@ -537,6 +589,64 @@ func TestGenerateDockerCompose(t *testing.T) {
 	if strings.Contains(content, "bridge:0.2.5-post5") {
 		t.Error("docker-compose.yml still has hardcoded bridge:0.2.5-post5")
 	}
+
+	// Without a password file, api takes the keyring password from the
+	// compose process environment, never through interpolation
+	if strings.Contains(content, "${KEYRING_PASSWORD}") {
+		t.Error("docker-compose.yml should not interpolate KEYRING_PASSWORD")
+	}
+	if !strings.Contains(content, "      - DAPI_API__ADMIN_SERVER_PORT=9200\n      - KEYRING_PASSWORD\n") {
+		t.Error("api service should pass KEYRING_PASSWORD through")
+	}
+}
+
+func TestGenerateDockerCompose_KeyringEnvFile(t *testing.T) {
+	state := config.NewState(t.TempDir())
+	passwordFile := filepath.Join(t.TempDir(), "keyring-password")
+	state.KeyringPasswordSource = "file:" + passwordFile
+	if err := generateDockerCompose(state); err != nil {
+		t.Fatalf("generateDockerCompose() error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(state.OutputDir, "docker-compose.yml")) // #nosec G304 - test temp dir
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	if !strings.Contains(content, "      - config.env\n      - "+passwordFile+".env\n") {
+		t.Errorf("api service should load %s.env:\n%s", passwordFile, content)
+	}
+	if strings.Contains(content, "      - KEYRING_PASSWORD\n") {
+		t.Error("api service should not pass KEYRING_PASSWORD through when a file holds it")
+	}
+}
+
+func TestGenerateKeyringEnv(t *testing.T) {
+	state := config.NewState(t.TempDir())
+	state.SetSpec(&config.SetupSpec{Keys: config.SpecKeys{KeyringPassword: "s3cret-keyring"}})
+
+	// No file source: nothing is written
+	if err := generateKeyringEnv(state); err != nil {
+		t.Fatalf("generateKeyringEnv() error: %v", err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(state.OutputDir, "*")); len(matches) != 0 {
+		t.Errorf("unexpected files: %v", matches)
+	}
+
+	state.KeyringPasswordSource = "file:" + filepath.Join(t.TempDir(), "keyring-password")
+	if err := generateKeyringEnv(state); err != nil {
+		t.Fatalf("generateKeyringEnv() error: %v", err)
+	}
+	info, err := os.Stat(state.KeyringEnvPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("keyring env file mode = %04o, want 0600", info.Mode().Perm())
+	}
+	data, _ := os.ReadFile(state.KeyringEnvPath()) // #nosec G304 - test temp dir
+	if string(data) != "KEYRING_PASSWORD='s3cret-keyring'\n" {
+		t.Errorf("keyring env file = %q", data)
+	}
 }
 
 func TestGenerateDockerCompose_NATInternalP2PPort(t *testing.T) {
@@ -571,6 +681,63 @@ func TestGenerateDockerCompose_NATInternalP2PPort(t *testing.T) {
 	}
 }
 
+func TestGenerateConfigs_TLS(t *testing.T) {
+	state := config.NewState(t.TempDir())
+	state.PublicIP = testIP
+	state.PersistentPeers = []string{"abc123@node1.example.com:5000"}
+	state.TLSDomain = "node.example.com"
+	state.ACMEEmail = "ops@example.com"
+	state.Versions.ProxySSL = "0.2.9-post3"
+
+	if err := generateConfigEnv(state); err != nil {
+		t.Fatalf("generateConfigEnv() error: %v", err)
+	}
+	if err := generateDockerCompose(state); err != nil {
+		t.Fatalf("generateDockerCompose() error: %v", err)
+	}
+	env, err := os.ReadFile(filepath.Join(state.OutputDir, "config.env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compose, err := os.ReadFile(filepath.Join(state.OutputDir, "docker-compose.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"PUBLIC_URL=https://node.example.com\n",
+		"NGINX_MODE=https\n",
+		"SERVER_NAME=node.example.com\n",
+		"ACME_EMAIL=ops@example.com\n",
+		"ACME_DIRECTORY_URL=" + config.DefaultACMEDirectory + "\n",
+	} {
+		if !strings.Contains(string(env), want) {
+			t.Errorf("config.env missing %q", want)
+		}
+	}
+	for _, want := range []string{
+		"image: ghcr.io/product-science/proxy-ssl:0.2.9-post3",
+		`"80:80"`,
+		`"443:443"`,
+		"ACME_EMAIL=${ACME_EMAIL}",
+		".certs:/etc/letsencrypt",
+	} {
+		if !strings.Contains(string(compose), want) {
+			t.Errorf("docker-compose.yml missing %q", want)
+		}
+	}
+	if strings.Contains(string(compose), "product-science/proxy:") || strings.Contains(string(compose), "${API_PORT:-8000}:80") {
+		t.Error("docker-compose.yml should not run the plain HTTP proxy with TLS")
+	}
+	if got := publicURL(state); got != "https://node.example.com" {
+		t.Errorf("publicURL() = %q", got)
+	}
+	state.TLSDomain = ""
+	if got := publicURL(state); got != "http://"+testIP+":8000" {
+		t.Errorf("publicURL() without TLS = %q", got)
+	}
+}
+
 func TestGenerateMLNodeCompose(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "gonka-test-*")
 	if err != nil {
@@ -580,7 +747,7 @@ func TestGenerateMLNodeCompose(t *testing.T) {
 
 	state := config.NewState(tmpDir)
 	state.SelectedModel = "Qwen/Qwen3-235B-A22B-Instruct-2507-FP8"
-	state.MLNodeImageTag = mlnodeBlackwellDefault
+	state.Versions.MLNode = mlnodeBlackwellDefault
 	state.AttentionBackend = defaultAttentionBackend
 	state.HFHome = defaultHFHome
 
@@ -964,3 +1131,91 @@ func TestGenerateDockerComposeNetworkOnly(t *testing.T) {
 		t.Error("port 9200 should always be bound to 127.0.0.1")
 	}
 }
+
+func TestCollectConfigInputs_FromSpec(t *testing.T) {
+	// Non-interactive with no overrides: any prompt without a default would fail
+	ui.SetNonInteractive(true)
+	defer ui.ResetOverrides()
+
+	state := config.NewState(t.TempDir())
+	state.NodeType = config.NodeTypeNetwork
+	state.SetSpec(&config.SetupSpec{
+		Version:   config.SetupSpecVersion,
+		PublicIP:  testAltIP,
+		PrivateIP: testIP,
+		Ports:     &config.SpecPorts{P2P: 19245, API: 19246},
+	})
+
+	if err := collectConfigInputs(state); err != nil {
+		t.Fatalf("collectConfigInputs() error: %v", err)
+	}
+	if state.PublicIP != testAltIP || state.NetworkNodeIP != testIP {
+		t.Errorf("PublicIP/NetworkNodeIP = %q/%q", state.PublicIP, state.NetworkNodeIP)
+	}
+	if state.P2PPort != 19245 || state.APIPort != 19246 {
+		t.Errorf("external ports = %d/%d, want 19245/19246", state.P2PPort, state.APIPort)
+	}
+	if state.InternalP2PPort != 5000 || state.InternalAPIPort != 8000 {
+		t.Errorf("internal ports = %d/%d, want defaults 5000/8000", state.InternalP2PPort, state.InternalAPIPort)
+	}
+}
+
+func TestCollectConfigInputs_MissingPublicIP(t *testing.T) {
+	ui.SetNonInteractive(true)
+	defer ui.ResetOverrides()
+
+	state := config.NewState(t.TempDir())
+	state.SetSpec(&config.SetupSpec{Version: config.SetupSpecVersion})
+	if err := collectConfigInputs(state); err == nil {
+		t.Error("expected error when public IP is neither in the spec nor answerable")
+	}
+}
+
+func TestCollectConfigInputs_ReusesSavedInputs(t *testing.T) {
+	// A re-run (setup --only configuration) must not reset saved answers
+	ui.SetNonInteractive(true)
+	defer ui.ResetOverrides()
+
+	state := config.NewState(t.TempDir())
+	state.PublicIP = testAltIP
+	state.P2PPort, state.APIPort = 19245, 19246
+	state.HFHome = "/data/hf"
+	state.SetSpec(&config.SetupSpec{Version: config.SetupSpecVersion})
+
+	if err := collectConfigInputs(state); err != nil {
+		t.Fatalf("collectConfigInputs() error: %v", err)
+	}
+	if state.PublicIP != testAltIP || state.HFHome != "/data/hf" {
+		t.Errorf("PublicIP/HFHome = %q/%q, want the saved values", state.PublicIP, state.HFHome)
+	}
+	if state.P2PPort != 19245 || state.APIPort != 19246 {
+		t.Errorf("external ports = %d/%d, want the saved 19245/19246", state.P2PPort, state.APIPort)
+	}
+
+	state.SetSpec(&config.SetupSpec{Version: config.SetupSpecVersion, PublicIP: testIP})
+	if err := collectConfigInputs(state); err != nil {
+		t.Fatal(err)
+	}
+	if state.PublicIP != testIP {
+		t.Errorf("PublicIP = %q, want the spec value %q", state.PublicIP, testIP)
+	}
+}
+
+func TestApplyMLNodeSpec(t *testing.T) {
+	state := config.NewState(t.TempDir())
+	state.NetworkNodeIP = testAltIP // already known: must not be replaced
+	state.SetSpec(&config.SetupSpec{
+		Version:     config.SetupSpecVersion,
+		PublicIP:    testIP,
+		HFHome:      "/data/hf",
+		NetworkNode: &config.SpecNetworkNode{URL: "http://10.0.1.100:9200", IP: "10.0.1.100"},
+	})
+
+	applyMLNodeSpec(state)
+	if state.NetworkNodeURL != "http://10.0.1.100:9200" || state.NetworkNodeIP != testAltIP {
+		t.Errorf("network node = %q/%q", state.NetworkNodeURL, state.NetworkNodeIP)
+	}
+	if state.PublicIP != testIP || state.HFHome != "/data/hf" {
+		t.Errorf("PublicIP/HFHome = %q/%q", state.PublicIP, state.HFHome)
+	}
+}
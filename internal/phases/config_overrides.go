@@ -0,0 +1,176 @@
+package phases
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// overridesDir holds operator files, below the output directory, that are
+// merged into the generated file of the same name every time it is written.
+const overridesDir = "overrides"
+
+// applyOverride merges overrides/<name> into a generated file, if present:
+//   - compose files (*.yml): maps merge recursively, "environment" lists
+//     merge by variable name, any other value is replaced by the override;
+//   - env files (*.env): variables replace the generated ones in place, new
+//     ones are appended in override order;
+//   - any other file is replaced by the override.
+//
+// The result only depends on the two files, so every regeneration produces
+// the same output.
+func applyOverride(outputDir, name string, generated []byte) ([]byte, error) {
+	path := filepath.Join(outputDir, overridesDir, name)
+	override, err := os.ReadFile(path) // #nosec G304 - operator file in the output dir
+	if errors.Is(err, fs.ErrNotExist) {
+		return generated, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read override %s: %w", path, err)
+	}
+
+	switch {
+	case isComposeFile(name):
+		merged, err := mergeComposeYAML(generated, override)
+		if err != nil {
+			return nil, fmt.Errorf("merge override %s: %w", path, err)
+		}
+		return merged, nil
+	case filepath.Ext(name) == ".env":
+		return mergeEnvFile(generated, override), nil
+	default:
+		return override, nil
+	}
+}
+
+// mergeComposeYAML merges override into the generated compose file. The
+// generated key order and comments are kept; new keys follow in override order.
+func mergeComposeYAML(generated, override []byte) ([]byte, error) {
+	var base, over yaml.Node
+	if err := yaml.Unmarshal(generated, &base); err != nil {
+		return nil, fmt.Errorf("parse generated file: %w", err)
+	}
+	if err := yaml.Unmarshal(override, &over); err != nil {
+		return nil, fmt.Errorf("parse override: %w", err)
+	}
+	if len(over.Content) == 0 {
+		return generated, nil // empty override
+	}
+	if len(base.Content) == 0 {
+		base = over
+	} else {
+		mergeYAMLNode(base.Content[0], over.Content[0], "")
+	}
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&base); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// mergeYAMLNode merges src into dst; key is the mapping key dst sits under.
+func mergeYAMLNode(dst, src *yaml.Node, key string) {
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			k, v := src.Content[i], src.Content[i+1]
+			if j := mappingIndex(dst, k.Value); j >= 0 {
+				mergeYAMLNode(dst.Content[j+1], v, k.Value)
+			} else {
+				dst.Content = append(dst.Content, k, v)
+			}
+		}
+	case key == "environment" && dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode:
+		for _, item := range src.Content {
+			if j := envItemIndex(dst, envName(item.Value)); j >= 0 {
+				dst.Content[j] = item
+			} else {
+				dst.Content = append(dst.Content, item)
+			}
+		}
+	default:
+		*dst = *src
+	}
+}
+
+// mappingIndex returns the index of key in a mapping node, or -1.
+func mappingIndex(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// envItemIndex returns the index of the NAME or NAME=value entry in an
+// environment list, or -1.
+func envItemIndex(seq *yaml.Node, name string) int {
+	for i, item := range seq.Content {
+		if item.Kind == yaml.ScalarNode && envName(item.Value) == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// envName returns NAME of a NAME=value (or bare NAME) entry.
+func envName(entry string) string {
+	name, _, _ := strings.Cut(entry, "=")
+	return strings.TrimSpace(name)
+}
+
+// mergeEnvFile merges override variables into a generated env file.
+// Comments and blank lines of the override are dropped.
+func mergeEnvFile(generated, override []byte) []byte {
+	values := map[string]string{}
+	var order []string
+	for _, line := range strings.Split(string(override), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || !strings.Contains(trimmed, "=") {
+			continue
+		}
+		name := envName(trimmed)
+		if _, seen := values[name]; !seen {
+			order = append(order, name)
+		}
+		values[name] = trimmed
+	}
+
+	replaced := map[string]bool{}
+	lines := strings.Split(strings.TrimSuffix(string(generated), "\n"), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") || !strings.Contains(trimmed, "=") {
+			continue
+		}
+		name := envName(trimmed)
+		if v, ok := values[name]; ok {
+			lines[i] = v
+			replaced[name] = true
+		}
+	}
+	var added []string
+	for _, name := range order {
+		if !replaced[name] {
+			added = append(added, values[name])
+		}
+	}
+	if len(added) > 0 {
+		lines = append(lines, "", "# Added by "+overridesDir+"/")
+		lines = append(lines, added...)
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
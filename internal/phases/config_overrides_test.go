@@ -0,0 +1,161 @@
+package phases
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/inc4/gonka-nop/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMergeComposeYAML(t *testing.T) {
+	generated := `services:
+  api:
+    image: api:1.0
+    environment:
+      - KEY_NAME=${KEY_NAME}
+      - KEYRING_PASSWORD
+      - DAPI_API__PUBLIC_SERVER_PORT=9000
+    ports:
+      - "127.0.0.1:9200:9200"
+    restart: always
+`
+	override := `services:
+  api:
+    environment:
+      - KEYRING_PASSWORD=from-override
+      - EXTRA=1
+    ports:
+      - "9200:9200"
+    mem_limit: 8g
+  sidecar:
+    image: busybox
+`
+	merged, err := mergeComposeYAML([]byte(generated), []byte(override))
+	if err != nil {
+		t.Fatalf("mergeComposeYAML() error: %v", err)
+	}
+
+	var got struct {
+		Services map[string]struct {
+			Image       string   `yaml:"image"`
+			Environment []string `yaml:"environment"`
+			Ports       []string `yaml:"ports"`
+			Restart     string   `yaml:"restart"`
+			MemLimit    string   `yaml:"mem_limit"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(merged, &got); err != nil {
+		t.Fatalf("merged file is not YAML: %v\n%s", err, merged)
+	}
+	api := got.Services["api"]
+	wantEnv := "KEY_NAME=${KEY_NAME} KEYRING_PASSWORD=from-override DAPI_API__PUBLIC_SERVER_PORT=9000 EXTRA=1"
+	if env := strings.Join(api.Environment, " "); env != wantEnv {
+		t.Errorf("environment = %s, want %s", env, wantEnv)
+	}
+	if ports := strings.Join(api.Ports, " "); ports != "9200:9200" {
+		t.Errorf("ports = %s, want the override's list", ports)
+	}
+	if api.Image != "api:1.0" || api.Restart != "always" || api.MemLimit != "8g" {
+		t.Errorf("api = %+v, want generated keys kept and mem_limit added", api)
+	}
+	if got.Services["sidecar"].Image != "busybox" {
+		t.Errorf("sidecar service not added:\n%s", merged)
+	}
+	if i, j := strings.Index(string(merged), "api:"), strings.Index(string(merged), "sidecar:"); i > j {
+		t.Errorf("generated services should come first:\n%s", merged)
+	}
+}
+
+func TestMergeComposeYAML_InvalidOverride(t *testing.T) {
+	if _, err := mergeComposeYAML([]byte("services: {}\n"), []byte("services: [\n")); err == nil {
+		t.Error("mergeComposeYAML() error = nil, want parse error")
+	}
+}
+
+func TestMergeEnvFile(t *testing.T) {
+	generated := "# Node\nKEY_NAME=gonka\nPUBLIC_URL=http://a\n# PORT=1\n"
+	override := "# local tweaks\nPUBLIC_URL=https://b\n\nEXTRA=1\nPORT=2\n"
+
+	got := string(mergeEnvFile([]byte(generated), []byte(override)))
+	want := "# Node\nKEY_NAME=gonka\nPUBLIC_URL=https://b\n# PORT=1\n\n# Added by overrides/\nEXTRA=1\nPORT=2\n"
+	if got != want {
+		t.Errorf("mergeEnvFile() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestApplyOverride(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, overridesDir), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, overridesDir, "nginx.conf"), []byte("custom\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := applyOverride(dir, "nginx.conf", []byte("generated\n"))
+	if err != nil || string(got) != "custom\n" {
+		t.Errorf("applyOverride(nginx.conf) = %q, %v, want the override", got, err)
+	}
+	got, err = applyOverride(dir, "node-config.json", []byte("[]\n"))
+	if err != nil || string(got) != "[]\n" {
+		t.Errorf("applyOverride(node-config.json) = %q, %v, want the generated file", got, err)
+	}
+}
+
+// TestOverrides_SurviveRegeneration checks that the phase writes overrides
+// into the generated files, that rendering agrees, and that a second run
+// produces the same bytes.
+func TestOverrides_SurviveRegeneration(t *testing.T) {
+	dir := t.TempDir()
+	state := config.NewState(dir)
+	state.PublicIP = "203.0.113.5"
+	overrides := map[string]string{
+		"docker-compose.yml": "services:\n  api:\n    environment:\n      - GOMEMLIMIT=4GiB\n",
+		"config.env":         "PUBLIC_URL=https://node.example.com\n",
+	}
+	if err := os.Mkdir(filepath.Join(dir, overridesDir), 0750); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range overrides {
+		if err := os.WriteFile(filepath.Join(dir, overridesDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	generate := func() map[string]string {
+		t.Helper()
+		for _, gen := range []func(*config.State) error{generateConfigEnv, generateDockerCompose} {
+			if err := gen(state); err != nil {
+				t.Fatalf("generate: %v", err)
+			}
+		}
+		files := map[string]string{}
+		for name := range overrides {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[name] = string(data)
+		}
+		return files
+	}
+
+	first := generate()
+	if !strings.Contains(first["docker-compose.yml"], "GOMEMLIMIT=4GiB") {
+		t.Errorf("docker-compose.yml lacks the override:\n%s", first["docker-compose.yml"])
+	}
+	if !strings.Contains(first["config.env"], "PUBLIC_URL=https://node.example.com\n") {
+		t.Errorf("config.env lacks the override:\n%s", first["config.env"])
+	}
+	for _, f := range mustRenderConfigFiles(t, state) {
+		if want, ok := first[f.Name]; ok && string(f.Content) != want {
+			t.Errorf("RenderConfigFiles() %s differs from the written file", f.Name)
+		}
+	}
+	if second := generate(); second["docker-compose.yml"] != first["docker-compose.yml"] || second["config.env"] != first["config.env"] {
+		t.Error("regeneration changed the merged files")
+	}
+}
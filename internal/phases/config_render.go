@@ -0,0 +1,304 @@
+package phases
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/inc4/gonka-nop/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFile is a generated file rendered in memory.
+type ConfigFile struct {
+	Name    string // relative to the output directory
+	Content []byte
+}
+
+// ConfigChange is a generated file whose content differs from disk.
+type ConfigChange struct {
+	Name    string
+	Current []byte // nil when the file is missing
+	Desired []byte
+}
+
+// Missing reports whether the file does not exist on disk.
+func (c ConfigChange) Missing() bool {
+	return c.Current == nil
+}
+
+// ConfigDrift compares what state.json would generate with the files on disk.
+type ConfigDrift struct {
+	Files   []ConfigFile   // every file the generators produce for this topology
+	Changes []ConfigChange // files that differ from disk, in Files order
+}
+
+// RenderConfigFiles renders the files the Configuration (or ML Node
+// Configuration) phase writes for state, merged with the operator's
+// overrides/, without writing to disk. The keyring password env file is not
+// included: it holds a secret and is not derived from state alone.
+func RenderConfigFiles(state *config.State) ([]ConfigFile, error) {
+	files, err := renderConfigFiles(state)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range files {
+		content, err := applyOverride(state.OutputDir, f.Name, f.Content)
+		if err != nil {
+			return nil, err
+		}
+		files[i].Content = content
+	}
+	return files, nil
+}
+
+// renderConfigFiles renders the generated files before overrides.
+func renderConfigFiles(state *config.State) ([]ConfigFile, error) {
+	// Generators fill a few defaults (persistent peers); keep the caller's
+	// state unchanged.
+	s := *state
+	var files []ConfigFile
+	for _, r := range configRenderers(&s) {
+		content, err := r.render(&s)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, ConfigFile{Name: r.name, Content: []byte(content)})
+	}
+	return files, nil
+}
+
+// configRenderer renders one generated file from state.
+type configRenderer struct {
+	name   string // relative to the output directory
+	render func(*config.State) (string, error)
+}
+
+// configRenderers lists the files generated for the topology in state, in
+// the order the phases write them.
+func configRenderers(state *config.State) []configRenderer {
+	if state.IsMLNodeOnly() {
+		return []configRenderer{
+			{"config.env", infallible(renderMLNodeEnv)},
+			{"docker-compose.mlnode.yml", renderStandaloneMLNodeCompose},
+			{"nginx.conf", func(*config.State) (string, error) { return renderStandaloneNginxConf() }},
+		}
+	}
+
+	renderers := []configRenderer{
+		{"config.env", infallible(renderConfigEnv)},
+		{"node-config.json", renderNodeConfig},
+		{"docker-compose.yml", renderDockerCompose},
+	}
+	if !state.IsNetworkOnly() {
+		renderers = append(renderers,
+			configRenderer{"nginx.conf", func(*config.State) (string, error) { return renderNginxConf() }},
+			configRenderer{"docker-compose.mlnode.yml", renderMLNodeCompose})
+	}
+	if state.IsTestNet {
+		renderers = append(renderers, configRenderer{"docker-compose.env-override.yml", infallible(renderEnvOverride)})
+	}
+	return renderers
+}
+
+// infallible adapts a renderer built with fmt rather than a template.
+func infallible(render func(*config.State) string) func(*config.State) (string, error) {
+	return func(s *config.State) (string, error) {
+		return render(s), nil
+	}
+}
+
+// DetectConfigDrift renders the generated files and compares them with the
+// ones in state.OutputDir.
+func DetectConfigDrift(state *config.State) (*ConfigDrift, error) {
+	files, err := RenderConfigFiles(state)
+	if err != nil {
+		return nil, err
+	}
+	drift := &ConfigDrift{Files: files}
+	for _, f := range drift.Files {
+		current, err := os.ReadFile(filepath.Join(state.OutputDir, f.Name)) // #nosec G304 - generated file in the output dir
+		if errors.Is(err, fs.ErrNotExist) {
+			current = nil
+		} else if err != nil {
+			return nil, fmt.Errorf("read %s: %w", f.Name, err)
+		}
+		if current == nil || !bytes.Equal(current, f.Content) {
+			drift.Changes = append(drift.Changes, ConfigChange{Name: f.Name, Current: current, Desired: f.Content})
+		}
+	}
+	return drift, nil
+}
+
+// CompareConfigFiles returns the drift from one rendering to another, such
+// as before and after a state change. Current holds the earlier rendering.
+func CompareConfigFiles(before, after []ConfigFile) *ConfigDrift {
+	prev := map[string][]byte{}
+	for _, f := range before {
+		prev[f.Name] = f.Content
+	}
+	drift := &ConfigDrift{Files: after}
+	for _, f := range after {
+		if old, ok := prev[f.Name]; !ok || !bytes.Equal(old, f.Content) {
+			drift.Changes = append(drift.Changes, ConfigChange{Name: f.Name, Current: old, Desired: f.Content})
+		}
+	}
+	return drift
+}
+
+// InSync reports whether every generated file matches disk.
+func (d *ConfigDrift) InSync() bool {
+	return len(d.Changes) == 0
+}
+
+// Services returns the compose services that must be recreated for the
+// changes to take effect, sorted:
+//   - compose files: services whose definition changed or was added
+//   - config.env: services that load it as env_file or interpolate a
+//     changed variable
+//   - other files: services that mount them
+func (d *ConfigDrift) Services() []string {
+	services := map[string]string{} // name -> definition as YAML, from the desired files
+	for _, f := range d.Files {
+		if isComposeFile(f.Name) {
+			for name, def := range composeServices(f.Content) {
+				services[name] += def
+			}
+		}
+	}
+
+	affected := map[string]bool{}
+	for _, c := range d.Changes {
+		switch {
+		case isComposeFile(c.Name):
+			before := composeServiceValues(c.Current)
+			for name, after := range composeServiceValues(c.Desired) {
+				if !reflect.DeepEqual(before[name], after) {
+					affected[name] = true
+				}
+			}
+		case c.Name == "config.env":
+			keys := changedEnvKeys(c.Current, c.Desired)
+			for name, def := range services {
+				if strings.Contains(def, "config.env") || referencesAny(def, keys) {
+					affected[name] = true
+				}
+			}
+		default:
+			for name, def := range services {
+				if strings.Contains(def, c.Name) {
+					affected[name] = true
+				}
+			}
+		}
+	}
+
+	out := make([]string, 0, len(affected))
+	for name := range affected {
+		out = append(out, name)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func isComposeFile(name string) bool {
+	return strings.HasPrefix(name, "docker-compose") && strings.HasSuffix(name, ".yml")
+}
+
+// composeServiceValues decodes the services section of a compose file.
+// Unparsable content yields no services.
+func composeServiceValues(content []byte) map[string]any {
+	var doc struct {
+		Services map[string]any `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil
+	}
+	return doc.Services
+}
+
+// composeServices returns each service definition re-encoded as YAML text,
+// for searching file names and variable references.
+func composeServices(content []byte) map[string]string {
+	out := map[string]string{}
+	for name, def := range composeServiceValues(content) {
+		data, err := yaml.Marshal(def)
+		if err != nil {
+			continue
+		}
+		out[name] = string(data)
+	}
+	return out
+}
+
+// changedEnvKeys returns the KEY=value keys that were added, removed or
+// changed between two env files.
+func changedEnvKeys(before, after []byte) []string {
+	b, a := parseEnvFile(before), parseEnvFile(after)
+	var keys []string
+	for k, v := range a {
+		if old, ok := b[k]; !ok || old != v {
+			keys = append(keys, k)
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func parseEnvFile(content []byte) map[string]string {
+	env := map[string]string{}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		if k, v, ok := strings.Cut(line, "="); ok {
+			env[strings.TrimSpace(k)] = v
+		}
+	}
+	return env
+}
+
+// referencesAny reports whether a compose definition interpolates one of
+// the variables (${KEY}, ${KEY:-default} or $KEY).
+func referencesAny(def string, keys []string) bool {
+	for _, k := range keys {
+		if strings.Contains(def, "${"+k+"}") || strings.Contains(def, "${"+k+":") ||
+			strings.Contains(def, "${"+k+"-") || containsWord(def, "$"+k) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsWord reports whether s contains w not followed by an identifier
+// character, so $API matches "$API/" but not "$API_PORT".
+func containsWord(s, w string) bool {
+	for i := strings.Index(s, w); i >= 0; {
+		end := i + len(w)
+		if end == len(s) || !isIdentChar(s[end]) {
+			return true
+		}
+		next := strings.Index(s[end:], w)
+		if next < 0 {
+			return false
+		}
+		i = end + next
+	}
+	return false
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+}
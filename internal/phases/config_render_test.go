@@ -0,0 +1,168 @@
+package phases
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/inc4/gonka-nop/internal/config"
+)
+
+func TestRenderConfigFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		nodeType  string
+		testnet   bool
+		wantFiles string
+	}{
+		{"full", config.NodeTypeFull, false, "config.env node-config.json docker-compose.yml nginx.conf docker-compose.mlnode.yml"},
+		{"full testnet", config.NodeTypeFull, true, "config.env node-config.json docker-compose.yml nginx.conf docker-compose.mlnode.yml docker-compose.env-override.yml"},
+		{"network", config.NodeTypeNetwork, false, "config.env node-config.json docker-compose.yml"},
+		{"mlnode", config.NodeTypeMLNode, false, "config.env docker-compose.mlnode.yml nginx.conf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := config.NewState(t.TempDir())
+			state.NodeType = tt.nodeType
+			state.IsTestNet = tt.testnet
+
+			var names []string
+			for _, f := range mustRenderConfigFiles(t, state) {
+				names = append(names, f.Name)
+			}
+			if got := strings.Join(names, " "); got != tt.wantFiles {
+				t.Errorf("files = %s, want %s", got, tt.wantFiles)
+			}
+			if len(state.PersistentPeers) != 0 {
+				t.Errorf("RenderConfigFiles() modified state: peers = %v", state.PersistentPeers)
+			}
+		})
+	}
+}
+
+// TestRenderConfigFiles_MatchesGenerators checks that rendering produces
+// exactly what the Configuration phase writes.
+func TestRenderConfigFiles_MatchesGenerators(t *testing.T) {
+	dir := t.TempDir()
+	state := config.NewState(dir)
+	state.PublicIP = "203.0.113.5"
+	for _, gen := range []func(*config.State) error{generateConfigEnv, generateNodeConfig, generateDockerCompose, generateNginxConf, generateMLNodeCompose} {
+		if err := gen(state); err != nil {
+			t.Fatalf("generate: %v", err)
+		}
+	}
+
+	drift, err := DetectConfigDrift(state)
+	if err != nil {
+		t.Fatalf("DetectConfigDrift() error: %v", err)
+	}
+	if !drift.InSync() {
+		t.Errorf("changes = %v, want none", drift.Changes)
+	}
+}
+
+func TestConfigDrift_Services(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(t *testing.T, dir string)
+		want string
+	}{
+		{"in sync", func(*testing.T, string) {}, ""},
+		{"compose tag bumped", func(t *testing.T, dir string) {
+			replaceInFile(t, filepath.Join(dir, "docker-compose.yml"), "product-science/bridge:", "product-science/bridge:9.9.9-")
+		}, "bridge"},
+		{"config.env variable changed", func(t *testing.T, dir string) {
+			replaceInFile(t, filepath.Join(dir, "config.env"), "KEY_NAME=", "KEY_NAME=edited-")
+		}, "api mlnode-308 node"},
+		{"nginx.conf deleted", func(t *testing.T, dir string) {
+			if err := os.Remove(filepath.Join(dir, "nginx.conf")); err != nil {
+				t.Fatal(err)
+			}
+		}, "inference"},
+		{"node-config.json edited", func(t *testing.T, dir string) {
+			replaceInFile(t, filepath.Join(dir, "node-config.json"), `"node1"`, `"node2"`)
+		}, "api mlnode-308"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			state := config.NewState(dir)
+			state.PublicIP = "203.0.113.5"
+			state.KeyName = "gonka-key"
+			for _, f := range mustRenderConfigFiles(t, state) {
+				if err := os.WriteFile(filepath.Join(dir, f.Name), f.Content, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tt.edit(t, dir)
+
+			drift, err := DetectConfigDrift(state)
+			if err != nil {
+				t.Fatalf("DetectConfigDrift() error: %v", err)
+			}
+			if got := strings.Join(drift.Services(), " "); got != tt.want {
+				t.Errorf("Services() = %q, want %q", got, tt.want)
+			}
+			if drift.InSync() != (tt.want == "") {
+				t.Errorf("InSync() = %v with changes %d", drift.InSync(), len(drift.Changes))
+			}
+		})
+	}
+}
+
+func TestChangedEnvKeys(t *testing.T) {
+	before := []byte("# comment\nA=1\nB=2\nexport C=3\n")
+	after := []byte("A=1\nB=20\nD=4\n")
+	got := changedEnvKeys(before, after)
+	want := map[string]bool{"B": true, "C": true, "D": true}
+	if len(got) != len(want) {
+		t.Fatalf("changedEnvKeys() = %v, want B, C, D", got)
+	}
+	for _, k := range got {
+		if !want[k] {
+			t.Errorf("unexpected key %s", k)
+		}
+	}
+}
+
+func TestReferencesAny(t *testing.T) {
+	def := "ports:\n- ${API_PORT:-8000}:80\nenvironment:\n- HOST=$PUBLIC_IP/path\n"
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"API_PORT", true},
+		{"PUBLIC_IP", true},
+		{"API", false},
+		{"PUBLIC", false},
+	}
+	for _, tt := range tests {
+		if got := referencesAny(def, []string{tt.key}); got != tt.want {
+			t.Errorf("referencesAny(%s) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func replaceInFile(t *testing.T, path, old, replacement string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), old) {
+		t.Fatalf("%s does not contain %q", path, old)
+	}
+	if err := os.WriteFile(path, []byte(strings.Replace(string(data), old, replacement, 1)), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustRenderConfigFiles(t *testing.T, state *config.State) []ConfigFile {
+	t.Helper()
+	files, err := RenderConfigFiles(state)
+	if err != nil {
+		t.Fatalf("RenderConfigFiles() error: %v", err)
+	}
+	return files
+}
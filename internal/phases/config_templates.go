@@ -0,0 +1,108 @@
+package phases
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/inc4/gonka-nop/internal/config"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// configTemplates lay out the generated files; the models below carry every
+// value that depends on state.
+var configTemplates = template.Must(template.New("").
+	Funcs(template.FuncMap{"json": jsonValue}).
+	ParseFS(templateFS, "templates/*.tmpl"))
+
+// renderTemplate executes templates/<name>.tmpl.
+func renderTemplate(name string, data any) (string, error) {
+	var b bytes.Buffer
+	if err := configTemplates.ExecuteTemplate(&b, name+".tmpl", data); err != nil {
+		return "", fmt.Errorf("render %s: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+// jsonValue encodes v as a JSON value for the templates, leaving <, > and &
+// readable.
+func jsonValue(v any) (string, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// composeModel is docker-compose.yml.
+type composeModel struct {
+	Versions          config.ImageVersions
+	GenesisSeeds      string
+	P2PPort           int      // host port published for P2P
+	APIEnvironment    []string // extra api environment entries
+	APIEnvFiles       []string // extra api env files, after config.env
+	MLCallbackBinding string   // host binding of the api ML callback port
+	EthereumNetwork   string
+	BeaconStateURL    string
+	Proxy             proxyModel
+}
+
+// proxyModel is the public proxy service of docker-compose.yml.
+type proxyModel struct {
+	Image     string // under ghcr.io/product-science/
+	TLS       bool
+	HTTPPort  int // published ACME and redirect port, with TLS
+	HTTPSPort int // published HTTPS port, with TLS
+}
+
+// mlnodeComposeModel is docker-compose.mlnode.yml, next to a network node
+// or standalone.
+type mlnodeComposeModel struct {
+	Image            string
+	HFHome           string
+	Model            string // next to a network node only
+	AttentionBackend string
+	NginxImage       string
+	BindIP           string // host address the proxy ports are published on
+	InferencePort    int    // host port of the inference proxy
+	PoCPort          int    // host port of the PoC endpoint
+}
+
+// nginxModel is nginx.conf of the inference proxy, next to a network node
+// or standalone.
+type nginxModel struct {
+	VersionPath string // API version prefix stripped before proxying
+	Upstreams   []nginxUpstream
+}
+
+// nginxUpstream is one mlnode port the inference proxy forwards.
+type nginxUpstream struct {
+	Name   string
+	Server string // host:port of the mlnode container
+	Listen int
+}
+
+// nodeConfigEntry is one ML node in node-config.json.
+type nodeConfigEntry struct {
+	ID            string
+	Host          string
+	InferencePort int
+	PoCPort       int
+	MaxConcurrent int
+	Model         string
+	Args          []string
+	Hardware      []nodeHardware
+}
+
+// nodeHardware is one GPU kind of a node-config.json entry.
+type nodeHardware struct {
+	Type  string
+	Count int
+}
@@ -295,3 +295,45 @@ func TestFormatGPUSummary(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyModelSpec(t *testing.T) {
+	state := &config.State{SelectedModel: "Qwen/Qwen3-235B-A22B-Instruct-2507-FP8", TPSize: 8, PPSize: 1}
+	applyModelSpec(state)
+	if state.TPSize != 8 {
+		t.Fatal("no spec should keep the recommendation")
+	}
+
+	state.SetSpec(&config.SetupSpec{Version: config.SetupSpecVersion, Model: &config.SpecModel{Name: "Qwen/QwQ-32B", TP: 2}})
+	applyModelSpec(state)
+	if state.SelectedModel != "Qwen/QwQ-32B" || state.TPSize != 2 || state.PPSize != 1 {
+		t.Errorf("model/TP/PP = %s/%d/%d, want Qwen/QwQ-32B/2/1", state.SelectedModel, state.TPSize, state.PPSize)
+	}
+}
+
+func TestApplyModelSpec_RecomputesLimits(t *testing.T) {
+	rec := recommendConfig(8, 81559, "sm_90", true)
+	tests := []struct {
+		name     string
+		model    config.SpecModel
+		wantUtil float64
+		wantLen  int
+	}{
+		{name: "same model keeps recommendation", model: config.SpecModel{Name: rec.Model, TP: 8},
+			wantUtil: rec.MemoryUtil, wantLen: rec.MaxModelLen},
+		{name: "known model", model: config.SpecModel{Name: "Qwen/Qwen3-32B-FP8"}, wantUtil: 0.92, wantLen: 24576},
+		{name: "unknown model", model: config.SpecModel{Name: "org/Other-7B"}, wantUtil: 0.90, wantLen: 0},
+		{name: "spec values win", model: config.SpecModel{Name: "org/Other-7B", MaxModelLen: 8192, GPUMemoryUtil: 0.85},
+			wantUtil: 0.85, wantLen: 8192},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &config.State{SelectedModel: rec.Model, GPUMemoryUtil: rec.MemoryUtil, MaxModelLen: rec.MaxModelLen}
+			model := tt.model
+			state.SetSpec(&config.SetupSpec{Version: config.SetupSpecVersion, Model: &model})
+			applyModelSpec(state)
+			if state.GPUMemoryUtil != tt.wantUtil || state.MaxModelLen != tt.wantLen {
+				t.Errorf("util/len = %.2f/%d, want %.2f/%d", state.GPUMemoryUtil, state.MaxModelLen, tt.wantUtil, tt.wantLen)
+			}
+		})
+	}
+}
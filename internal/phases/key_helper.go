@@ -1,12 +1,12 @@
 package phases
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
 	"strings"
+
+	"github.com/inc4/gonka-nop/internal/docker"
 )
 
 // KeyOutput holds parsed output from `inferenced keys add --output json`.
@@ -69,48 +69,35 @@ func ExtractMnemonic(stderr string) string {
 // CreateKeyViaDocker creates a key by running inferenced inside a Docker container.
 // Returns the parsed key output and the mnemonic phrase.
 func CreateKeyViaDocker(ctx context.Context, imageRef, keyName, password, keyringDir string, useSudo bool) (*KeyOutput, string, error) {
-	// Build the shell command to pipe the password.
 	// inferenced keys add prompts for: passphrase (new/unlock), confirm, then unlock-to-display.
-	// We pipe password 3 times to cover all prompts.
+	// The password is fed 3 times on stdin so it never appears in the process list.
 	// The "y" override prompt only appears if the key already exists — we handle that
 	// via the --yes flag (available in newer SDK) or by pre-deleting if needed.
-	shellCmd := fmt.Sprintf(
-		`printf '%s\n%s\n%s\n' | inferenced keys add %s --keyring-backend file --keyring-dir /root/.inference --output json`,
-		password, password, password, keyName,
-	)
-
-	args := []string{
-		"run", "--rm",
-		"-v", keyringDir + ":/root/.inference",
-		imageRef,
-		"sh", "-c", shellCmd,
-	}
-
-	var cmd *exec.Cmd
-	if useSudo {
-		sudoArgs := append([]string{"-E", "docker"}, args...)
-		cmd = exec.CommandContext(ctx, "sudo", sudoArgs...) // #nosec G204
-	} else {
-		cmd = exec.CommandContext(ctx, "docker", args...) // #nosec G204
-	}
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		return nil, "", fmt.Errorf("docker run inferenced keys add: %w\nstderr: %s\nstdout: %s", err, stderr.String(), stdout.String())
+	// Key generation needs no network, so the container gets none.
+	stdout, stderr, err := docker.RunImage(ctx, docker.ImageRunOptions{
+		Image:     imageRef,
+		Volumes:   []string{keyringDir + ":/root/.inference"},
+		NoNetwork: true,
+		Stdin:     strings.NewReader(strings.Repeat(password+"\n", 3)),
+		UseSudo:   useSudo,
+		Cmd: []string{
+			"inferenced", "keys", "add", keyName,
+			"--keyring-backend", "file", "--keyring-dir", "/root/.inference", "--output", "json",
+		},
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("%w\nstdout: %s", err, stdout)
 	}
 
-	key, err := ParseKeyOutput(stdout.String())
+	key, err := ParseKeyOutput(stdout)
 	if err != nil {
-		return nil, "", fmt.Errorf("parse key output: %w\nraw stdout: %s\nstderr: %s", err, stdout.String(), stderr.String())
+		return nil, "", fmt.Errorf("parse key output: %w\nraw stdout: %s\nstderr: %s", err, stdout, stderr)
 	}
 
 	// v0.2.9+ includes mnemonic in JSON output; fall back to stderr extraction
 	mnemonic := key.Mnemonic
 	if mnemonic == "" {
-		mnemonic = ExtractMnemonic(stderr.String())
+		mnemonic = ExtractMnemonic(stderr)
 	}
 	return key, mnemonic, nil
 }
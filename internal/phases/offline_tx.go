@@ -0,0 +1,412 @@
+package phases
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/inc4/gonka-nop/internal/config"
+	"github.com/inc4/gonka-nop/internal/docker"
+)
+
+// Offline signing flow for the secure workflow:
+//
+//  1. `gonka-nop register` on the server generates unsigned transactions
+//     (--generate-only) into an OfflineTxBundle.
+//  2. `gonka-nop sign` on the cold machine signs them offline with the
+//     account key and adds the encoded tx bytes.
+//  3. `gonka-nop register --signed` on the server broadcasts them through
+//     the seed API.
+
+// OfflineTxVersion is the bundle format this build writes and understands.
+const OfflineTxVersion = 1
+
+// Transactions in an offline registration bundle, in signing order.
+const (
+	OfflineTxRegister = "submit-new-participant"
+	OfflineTxGrant    = "grant-ml-ops-permissions"
+)
+
+// Cosmos SDK tx codes treated as "already broadcast" on resume.
+const txCodeAlreadyInMempool = 19
+
+// OfflineTxBundle carries unsigned (and, after `gonka-nop sign`, signed)
+// transactions between the server and the cold machine.
+type OfflineTxBundle struct {
+	Version       int         `json:"version"`
+	ChainID       string      `json:"chain_id"`
+	Signer        string      `json:"signer"` // account (cold) key address
+	AccountNumber uint64      `json:"account_number,string"`
+	Sequence      uint64      `json:"sequence,string"` // sequence of the first tx
+	CreatedAt     time.Time   `json:"created_at"`
+	Txs           []OfflineTx `json:"txs"`
+}
+
+// OfflineTx is one transaction of a bundle.
+type OfflineTx struct {
+	Name     string          `json:"name"`
+	Unsigned json.RawMessage `json:"unsigned"`
+	TxBytes  string          `json:"tx_bytes,omitempty"` // base64 signed tx, set by `gonka-nop sign`
+}
+
+// Fingerprint identifies the batch independently of signatures, so a signed
+// bundle can be matched to the unsigned one recorded in state.
+func (b *OfflineTxBundle) Fingerprint() string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%d\n%s\n%s\n%d\n%d\n", b.Version, b.ChainID, b.Signer, b.AccountNumber, b.Sequence)
+	for _, tx := range b.Txs {
+		_, _ = fmt.Fprintf(h, "%s\n", tx.Name)
+		var compact bytes.Buffer
+		if json.Compact(&compact, tx.Unsigned) == nil {
+			h.Write(compact.Bytes())
+		} else {
+			h.Write(tx.Unsigned)
+		}
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// IsSigned reports whether every transaction carries signed tx bytes.
+func (b *OfflineTxBundle) IsSigned() bool {
+	for _, tx := range b.Txs {
+		if tx.TxBytes == "" {
+			return false
+		}
+	}
+	return len(b.Txs) > 0
+}
+
+// Validate checks the bundle structure.
+func (b *OfflineTxBundle) Validate() error {
+	if b.Version != OfflineTxVersion {
+		return fmt.Errorf("unsupported transaction bundle version %d", b.Version)
+	}
+	if b.ChainID == "" || b.Signer == "" {
+		return fmt.Errorf("transaction bundle is missing chain_id or signer")
+	}
+	if len(b.Txs) == 0 {
+		return fmt.Errorf("transaction bundle contains no transactions")
+	}
+	for _, tx := range b.Txs {
+		if !json.Valid(tx.Unsigned) {
+			return fmt.Errorf("transaction %s: unsigned tx is not valid JSON", tx.Name)
+		}
+		if tx.TxBytes != "" {
+			if _, err := base64.StdEncoding.DecodeString(tx.TxBytes); err != nil {
+				return fmt.Errorf("transaction %s: tx_bytes is not base64: %w", tx.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadOfflineTxBundle reads and validates a bundle file.
+func LoadOfflineTxBundle(path string) (*OfflineTxBundle, error) {
+	data, err := os.ReadFile(filepath.Clean(path)) // #nosec G304 - path from CLI flag or state
+	if err != nil {
+		return nil, fmt.Errorf("read transaction bundle: %w", err)
+	}
+	var b OfflineTxBundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("parse transaction bundle: %w", err)
+	}
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// WriteOfflineTxBundle writes a bundle with mode 0600.
+func WriteOfflineTxBundle(path string, b *OfflineTxBundle) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode transaction bundle: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("write transaction bundle: %w", err)
+	}
+	return nil
+}
+
+// GenerateOfflineTxs builds the unsigned registration and grant transactions
+// inside the api container. It needs no keys: --from is the cold key address.
+func GenerateOfflineTxs(ctx context.Context, state *config.State, seedURL, chainID string) (*OfflineTxBundle, error) {
+	var missing []string
+	if state.ColdKeyAddress == "" {
+		missing = append(missing, "account key address (pass the init-account bundle to setup --account-pubkey, or register --account-address)")
+	}
+	if state.WarmKeyAddress == "" {
+		missing = append(missing, "warm key address")
+	}
+	if state.ConsensusKey == "" {
+		missing = append(missing, "consensus key (is the node synced?)")
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("cannot generate transactions, missing: %s", strings.Join(missing, ", "))
+	}
+
+	accountNumber, sequence, err := FetchAccount(ctx, seedURL, state.ColdKeyAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeURL := seedURL + "/chain-rpc/"
+	commands := []struct {
+		name string
+		argv []string
+	}{
+		{OfflineTxRegister, []string{
+			"inferenced", "tx", "inference", "submit-new-participant", state.PublicURL,
+			"--validator-key", state.ConsensusKey, "--from", state.ColdKeyAddress,
+			"--generate-only", "--node", nodeURL, "--chain-id", chainID,
+		}},
+		{OfflineTxGrant, []string{
+			"inferenced", "tx", "inference", "grant-ml-ops-permissions", state.ColdKeyAddress, state.WarmKeyAddress,
+			"--from", state.ColdKeyAddress, "--gas", "2000000",
+			"--generate-only", "--node", nodeURL, "--chain-id", chainID,
+		}},
+	}
+
+	b := &OfflineTxBundle{
+		Version:       OfflineTxVersion,
+		ChainID:       chainID,
+		Signer:        state.ColdKeyAddress,
+		AccountNumber: accountNumber,
+		Sequence:      sequence,
+		CreatedAt:     time.Now().UTC(),
+	}
+	for _, c := range commands {
+		out, err := RunComposeExec(ctx, state, "api", c.argv, nil)
+		if err != nil {
+			return nil, fmt.Errorf("generate %s: %w", c.name, err)
+		}
+		unsigned := []byte(lastJSONLine(out))
+		if !json.Valid(unsigned) {
+			return nil, fmt.Errorf("generate %s: unexpected output:\n%s", c.name, out)
+		}
+		b.Txs = append(b.Txs, OfflineTx{Name: c.name, Unsigned: unsigned})
+	}
+	return b, nil
+}
+
+// lastJSONLine returns the last line that looks like a JSON object; inferenced
+// may print warnings before the transaction.
+func lastJSONLine(out string) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); strings.HasPrefix(line, "{") {
+			return line
+		}
+	}
+	return out
+}
+
+// SignOfflineTxs signs every transaction of the bundle with the account key
+// in an inferenced container without network access, using consecutive
+// sequences starting at b.Sequence.
+func SignOfflineTxs(ctx context.Context, b *OfflineTxBundle, imageRef, keyName, password, keyringDir string, useSudo bool) error {
+	workDir, err := os.MkdirTemp("", "gonka-sign-")
+	if err != nil {
+		return fmt.Errorf("create work dir: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(workDir) }()
+
+	for i := range b.Txs {
+		tx := &b.Txs[i]
+		unsignedPath := filepath.Join(workDir, tx.Name+".json")
+		if err := os.WriteFile(unsignedPath, tx.Unsigned, 0644); err != nil { // #nosec G306 - read by the container user
+			return fmt.Errorf("stage %s: %w", tx.Name, err)
+		}
+
+		_, _, err := docker.RunImage(ctx, docker.ImageRunOptions{
+			Image:     imageRef,
+			Volumes:   []string{keyringDir + ":/root/.inference", workDir + ":/tx"},
+			NoNetwork: true,
+			Stdin:     strings.NewReader(password + "\n"),
+			UseSudo:   useSudo,
+			Cmd: []string{
+				"inferenced", "tx", "sign", "/tx/" + tx.Name + ".json",
+				"--from", keyName, "--offline",
+				"--account-number", strconv.FormatUint(b.AccountNumber, 10),
+				"--sequence", strconv.FormatUint(b.Sequence+uint64(i), 10), // #nosec G115 - small index
+				"--chain-id", b.ChainID,
+				"--keyring-backend", "file", "--keyring-dir", "/root/.inference",
+				"--output-document", "/tx/" + tx.Name + ".signed.json",
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("sign %s: %w", tx.Name, err)
+		}
+
+		out, _, err := docker.RunImage(ctx, docker.ImageRunOptions{
+			Image:     imageRef,
+			Volumes:   []string{workDir + ":/tx"},
+			NoNetwork: true,
+			UseSudo:   useSudo,
+			Cmd:       []string{"inferenced", "tx", "encode", "/tx/" + tx.Name + ".signed.json"},
+		})
+		if err != nil {
+			return fmt.Errorf("encode %s: %w", tx.Name, err)
+		}
+		encoded := strings.TrimSpace(out)
+		if _, err := base64.StdEncoding.DecodeString(encoded); err != nil || encoded == "" {
+			return fmt.Errorf("encode %s: unexpected output %q", tx.Name, encoded)
+		}
+		tx.TxBytes = encoded
+	}
+	return nil
+}
+
+// DecodeSignedTx decodes base64 signed tx bytes to JSON with inferenced in
+// the api container. It needs no keys.
+func DecodeSignedTx(ctx context.Context, state *config.State, txBytes string) ([]byte, error) {
+	out, err := RunComposeExec(ctx, state, "api", []string{"inferenced", "tx", "decode", txBytes}, nil)
+	if err != nil {
+		return nil, err
+	}
+	decoded := []byte(lastJSONLine(out))
+	if !json.Valid(decoded) {
+		return nil, fmt.Errorf("unexpected output:\n%s", out)
+	}
+	return decoded, nil
+}
+
+// MatchTxMessages checks that a decoded signed tx carries exactly the
+// messages and memo of the unsigned tx it was generated from. Both are
+// proto JSON from inferenced, so they compare field by field.
+func MatchTxMessages(unsigned, signed []byte) error {
+	want, err := txBodyContent(unsigned)
+	if err != nil {
+		return fmt.Errorf("parse unsigned tx: %w", err)
+	}
+	got, err := txBodyContent(signed)
+	if err != nil {
+		return fmt.Errorf("parse signed tx: %w", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		return errors.New("signed tx messages differ from the unsigned tx")
+	}
+	return nil
+}
+
+// txBodyContent returns the messages and memo of a JSON tx.
+func txBodyContent(tx []byte) (any, error) {
+	var t struct {
+		Body struct {
+			Messages []any  `json:"messages"`
+			Memo     string `json:"memo"`
+		} `json:"body"`
+	}
+	if err := json.Unmarshal(tx, &t); err != nil {
+		return nil, err
+	}
+	if len(t.Body.Messages) == 0 {
+		return nil, errors.New("tx has no messages")
+	}
+	return []any{t.Body.Messages, t.Body.Memo}, nil
+}
+
+// FetchAccount returns the account number and sequence of address from the
+// seed node's REST API.
+func FetchAccount(ctx context.Context, seedURL, address string) (accountNumber, sequence uint64, err error) {
+	reqCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	url := seedURL + "/chain-api/cosmos/auth/v1beta1/accounts/" + address
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("create request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, 0, fmt.Errorf("fetch account %s: %w", address, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, 0, fmt.Errorf("account %s not found on chain — fund it before registering", address)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("fetch account %s: status %d", address, resp.StatusCode)
+	}
+
+	var body struct {
+		Account struct {
+			AccountNumber string `json:"account_number"`
+			Sequence      string `json:"sequence"`
+		} `json:"account"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, 0, fmt.Errorf("decode account: %w", err)
+	}
+	if accountNumber, err = strconv.ParseUint(body.Account.AccountNumber, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("parse account number %q: %w", body.Account.AccountNumber, err)
+	}
+	if sequence, err = strconv.ParseUint(body.Account.Sequence, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("parse sequence %q: %w", body.Account.Sequence, err)
+	}
+	return accountNumber, sequence, nil
+}
+
+// BroadcastTx submits signed tx bytes through the seed node's REST API and
+// returns the tx hash. A tx already in the mempool counts as broadcast.
+func BroadcastTx(ctx context.Context, seedURL, txBytes string) (string, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	payload, err := json.Marshal(map[string]string{"tx_bytes": txBytes, "mode": "BROADCAST_MODE_SYNC"})
+	if err != nil {
+		return "", fmt.Errorf("encode broadcast request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost,
+		seedURL+"/chain-api/cosmos/tx/v1beta1/txs", bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("broadcast: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("broadcast: status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	var body struct {
+		TxResponse struct {
+			TxHash string `json:"txhash"`
+			Code   int    `json:"code"`
+			RawLog string `json:"raw_log"`
+		} `json:"tx_response"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return "", fmt.Errorf("decode broadcast response: %w", err)
+	}
+	r := body.TxResponse
+	if r.Code != 0 && r.Code != txCodeAlreadyInMempool {
+		return r.TxHash, fmt.Errorf("broadcast rejected (code %d): %s", r.Code, r.RawLog)
+	}
+	if r.TxHash == "" {
+		return "", errors.New("broadcast response has no txhash")
+	}
+	return r.TxHash, nil
+}
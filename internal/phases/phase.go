@@ -21,12 +21,17 @@ type Phase interface {
 
 	// ShouldRun returns true if this phase should run given current state
 	ShouldRun(state *config.State) bool
+
+	// DryRun reports the commands, files, prompts and requests Run would
+	// make, without making them or changing state
+	DryRun(state *config.State) []Action
 }
 
 // Runner executes phases in sequence
 type Runner struct {
-	phases []Phase
-	state  *config.State
+	phases    []Phase
+	state     *config.State
+	selection Selection
 }
 
 // NewRunner creates a new phase runner
@@ -37,20 +42,25 @@ func NewRunner(phases []Phase, state *config.State) *Runner {
 	}
 }
 
-// Run executes all phases in order
+// Run executes the phases in order. Each phase is judged just before it
+// runs, so it sees what earlier phases wrote to state.
 func (r *Runner) Run(ctx context.Context) error {
 	total := len(r.phases)
+	reachedFrom := false
 
 	for i, phase := range r.phases {
-		// Check if phase should run
-		if !phase.ShouldRun(r.state) {
-			ui.Detail("Skipping %s (already complete)", phase.Name())
+		step := r.decide(phase, &reachedFrom)
+		if step.Decision == PlanSkip {
+			ui.Detail("Skipping %s (%s)", phase.Name(), step.Reason)
 			continue
 		}
+		if step.Decision == PlanRerun {
+			r.state.UnmarkPhaseComplete(phase.Name())
+		}
 
 		// Display phase header
 		ui.PhaseStart(i+1, phase.Name())
-		ui.Detail(phase.Description())
+		ui.Detail("%s", phase.Description())
 
 		// Update state
 		r.state.CurrentPhase = phase.Name()
@@ -83,3 +93,11 @@ func (r *Runner) Run(ctx context.Context) error {
 func (r *Runner) GetState() *config.State {
 	return r.state
 }
+
+// inputOr returns preset when set (from the setup spec), otherwise prompts.
+func inputOr(preset, message, defaultVal string) (string, error) {
+	if preset != "" {
+		return preset, nil
+	}
+	return ui.Input(message, defaultVal)
+}
@@ -27,6 +27,9 @@ func (m *mockPhase) Run(_ context.Context, _ *config.State) error {
 	m.ran = true
 	return m.runErr
 }
+func (m *mockPhase) DryRun(_ *config.State) []Action {
+	return []Action{{ActionRun, "echo " + m.name}}
+}
 
 func newMock(name string, shouldRun bool, runErr error) *mockPhase {
 	return &mockPhase{
@@ -0,0 +1,179 @@
+package phases
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/inc4/gonka-nop/internal/config"
+)
+
+// Action kinds reported by DryRun.
+const (
+	ActionRun      = "run"      // a command, as it would be executed
+	ActionWrite    = "write"    // a file created or replaced
+	ActionAsk      = "ask"      // a prompt the setup spec does not answer
+	ActionRequest  = "request"  // an HTTP request or on-chain transaction
+	ActionFirewall = "firewall" // a firewall rule
+)
+
+// Action is one thing a phase would do, as reported by DryRun.
+type Action struct {
+	Kind   string
+	Detail string
+}
+
+// Plan decisions.
+const (
+	PlanRun   = "run"
+	PlanRerun = "re-run"
+	PlanSkip  = "skip"
+)
+
+// PlanStep is the runner's decision for one phase.
+type PlanStep struct {
+	Phase    Phase
+	Decision string // PlanRun, PlanRerun or PlanSkip
+	Reason   string
+}
+
+// Selection forces phases to run whether or not they are complete. Phases
+// that do not apply to the node's state (ShouldRun false even when not
+// complete) are still skipped.
+type Selection struct {
+	From string   // this phase and every later one
+	Only []string // only these phases
+}
+
+func (s Selection) active() bool {
+	return s.From != "" || len(s.Only) > 0
+}
+
+// Select validates sel against the runner's phases and applies it to the
+// next Plan or Run. Names match case-insensitively, with '-' for spaces
+// ("configuration", "gpu-detection").
+func (r *Runner) Select(sel Selection) error {
+	if sel.From != "" && len(sel.Only) > 0 {
+		return fmt.Errorf("use --from or --only, not both")
+	}
+	names := sel.Only
+	if sel.From != "" {
+		names = []string{sel.From}
+	}
+	for _, name := range names {
+		if r.findPhase(name) == nil {
+			keys := make([]string, len(r.phases))
+			for i, p := range r.phases {
+				keys[i] = PhaseKey(p.Name())
+			}
+			return fmt.Errorf("unknown phase %q (phases: %s)", name, strings.Join(keys, ", "))
+		}
+	}
+	r.selection = sel
+	return nil
+}
+
+// PhaseKey returns the command-line form of a phase name.
+func PhaseKey(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "-")
+}
+
+func (r *Runner) findPhase(name string) Phase {
+	for _, p := range r.phases {
+		if PhaseKey(p.Name()) == PhaseKey(name) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Plan decides, without running anything, which phases Run would run,
+// re-run or skip, and why. It judges every phase against the current state;
+// Run decides each phase just before running it, after earlier phases have
+// updated the state, so a plan for a fresh node can differ from what runs.
+func (r *Runner) Plan() []PlanStep {
+	steps := make([]PlanStep, 0, len(r.phases))
+	reachedFrom := false
+	for _, p := range r.phases {
+		steps = append(steps, r.decide(p, &reachedFrom))
+	}
+	return steps
+}
+
+// decide returns the runner's decision for p against the current state.
+// reachedFrom tracks whether the --from phase has been passed; call decide
+// for each phase in order.
+func (r *Runner) decide(p Phase, reachedFrom *bool) PlanStep {
+	if !r.selection.active() {
+		return r.planDefault(p)
+	}
+	if r.selection.From != "" {
+		*reachedFrom = *reachedFrom || PhaseKey(p.Name()) == PhaseKey(r.selection.From)
+		return r.planSelected(p, *reachedFrom, "before --from "+r.selection.From)
+	}
+	selected := slices.ContainsFunc(r.selection.Only, func(n string) bool { return PhaseKey(n) == PhaseKey(p.Name()) })
+	return r.planSelected(p, selected, "not selected by --only")
+}
+
+func (r *Runner) planDefault(p Phase) PlanStep {
+	done := r.state.IsPhaseComplete(p.Name())
+	switch {
+	case p.ShouldRun(r.state) && done:
+		return PlanStep{p, PlanRerun, "complete, but the phase asks to run again"}
+	case p.ShouldRun(r.state):
+		return PlanStep{p, PlanRun, "not complete"}
+	case done:
+		return PlanStep{p, PlanSkip, "already complete"}
+	default:
+		return PlanStep{p, PlanSkip, "nothing to do for this node's state"}
+	}
+}
+
+func (r *Runner) planSelected(p Phase, selected bool, notSelected string) PlanStep {
+	if !selected {
+		return PlanStep{p, PlanSkip, notSelected}
+	}
+	// Judge the phase as if it had never completed
+	fresh := *r.state
+	fresh.CompletedPhases = slices.DeleteFunc(slices.Clone(r.state.CompletedPhases), func(n string) bool { return n == p.Name() })
+	if !p.ShouldRun(&fresh) {
+		return PlanStep{p, PlanSkip, "nothing to do for this node's state"}
+	}
+	if r.state.IsPhaseComplete(p.Name()) {
+		return PlanStep{p, PlanRerun, "complete, forced by selection"}
+	}
+	return PlanStep{p, PlanRun, "not complete"}
+}
+
+// dockerCommand formats a docker command the way the phases run it.
+func dockerCommand(state *config.State, args ...string) string {
+	cmd := "docker " + strings.Join(args, " ")
+	if state.UseSudo {
+		cmd = "sudo -E " + cmd
+	}
+	return cmd
+}
+
+// composeCommand formats a docker compose command over the node's compose
+// files, or the files the Configuration phase will select.
+func composeCommand(state *config.State, files []string, args ...string) string {
+	if len(files) == 0 {
+		files = state.ComposeFiles
+	}
+	if len(files) == 0 {
+		files = buildComposeFileList(state)
+	}
+	cmd := []string{"compose"}
+	for _, f := range files {
+		cmd = append(cmd, "-f", f)
+	}
+	return dockerCommand(state, append(cmd, args...)...)
+}
+
+// askUnless returns a prompt action unless preset answers it.
+func askUnless(preset, question string) []Action {
+	if preset != "" {
+		return nil
+	}
+	return []Action{{ActionAsk, question}}
+}
@@ -0,0 +1,155 @@
+package phases
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/inc4/gonka-nop/internal/config"
+)
+
+// setupPhase behaves like the real phases: it runs until it is complete.
+type setupPhase struct {
+	mockPhase
+	applies bool
+}
+
+func (p *setupPhase) ShouldRun(state *config.State) bool {
+	return p.applies && !state.IsPhaseComplete(p.name)
+}
+
+func newSetupRunner(t *testing.T, completed ...string) (*Runner, []*setupPhase) {
+	t.Helper()
+	phases := []*setupPhase{
+		{mockPhase: mockPhase{name: "Prerequisites"}, applies: true},
+		{mockPhase: mockPhase{name: "GPU Detection"}, applies: true},
+		{mockPhase: mockPhase{name: "ML Node Firewall"}, applies: false},
+		{mockPhase: mockPhase{name: "Configuration"}, applies: true},
+		{mockPhase: mockPhase{name: "Deploy"}, applies: true},
+	}
+	state := config.NewState(t.TempDir())
+	for _, name := range completed {
+		state.MarkPhaseComplete(name)
+	}
+	list := make([]Phase, len(phases))
+	for i, p := range phases {
+		list[i] = p
+	}
+	return NewRunner(list, state), phases
+}
+
+func planDecisions(steps []PlanStep) string {
+	out := make([]string, len(steps))
+	for i, s := range steps {
+		out[i] = PhaseKey(s.Phase.Name()) + "=" + s.Decision
+	}
+	return strings.Join(out, " ")
+}
+
+func TestRunnerPlan(t *testing.T) {
+	tests := []struct {
+		name string
+		sel  Selection
+		want string
+	}{
+		{
+			name: "default",
+			want: "prerequisites=skip gpu-detection=skip ml-node-firewall=skip configuration=skip deploy=run",
+		},
+		{
+			name: "only",
+			sel:  Selection{Only: []string{"configuration"}},
+			want: "prerequisites=skip gpu-detection=skip ml-node-firewall=skip configuration=re-run deploy=skip",
+		},
+		{
+			name: "only, case and spaces",
+			sel:  Selection{Only: []string{"GPU Detection", "deploy"}},
+			want: "prerequisites=skip gpu-detection=re-run ml-node-firewall=skip configuration=skip deploy=run",
+		},
+		{
+			name: "from",
+			sel:  Selection{From: "gpu-detection"},
+			want: "prerequisites=skip gpu-detection=re-run ml-node-firewall=skip configuration=re-run deploy=run",
+		},
+		{
+			name: "only a phase that does not apply",
+			sel:  Selection{Only: []string{"ml-node-firewall"}},
+			want: "prerequisites=skip gpu-detection=skip ml-node-firewall=skip configuration=skip deploy=skip",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner, _ := newSetupRunner(t, "Prerequisites", "GPU Detection", "Configuration")
+			if err := runner.Select(tt.sel); err != nil {
+				t.Fatalf("Select() error: %v", err)
+			}
+			if got := planDecisions(runner.Plan()); got != tt.want {
+				t.Errorf("Plan() = %s\nwant     %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunnerSelect_Errors(t *testing.T) {
+	runner, _ := newSetupRunner(t)
+	err := runner.Select(Selection{Only: []string{"config"}})
+	if err == nil || !strings.Contains(err.Error(), "gpu-detection") {
+		t.Errorf("unknown phase error = %v, want the list of phases", err)
+	}
+	if err := runner.Select(Selection{From: "deploy", Only: []string{"configuration"}}); err == nil {
+		t.Error("expected an error for --from with --only")
+	}
+}
+
+func TestRunnerRun_OnlyRerunsCompletedPhase(t *testing.T) {
+	runner, phases := newSetupRunner(t, "Prerequisites", "GPU Detection", "Configuration")
+	if err := runner.Select(Selection{Only: []string{"configuration"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	for _, p := range phases {
+		if want := p.name == "Configuration"; p.ran != want {
+			t.Errorf("%s ran = %v, want %v", p.name, p.ran, want)
+		}
+	}
+	want := []string{"Prerequisites", "GPU Detection", "Configuration"}
+	if got := runner.GetState().CompletedPhases; !slices.Equal(got, want) {
+		t.Errorf("CompletedPhases = %v, want %v", got, want)
+	}
+}
+
+// ipPhase fills state.PublicIP, like ML Node Configuration does.
+type ipPhase struct{ mockPhase }
+
+func (p *ipPhase) Run(_ context.Context, state *config.State) error {
+	p.ran = true
+	state.PublicIP = "203.0.113.7"
+	return nil
+}
+
+// needsIPPhase runs only once a public IP is known, like ML Node Firewall.
+type needsIPPhase struct{ mockPhase }
+
+func (p *needsIPPhase) ShouldRun(state *config.State) bool {
+	return state.PublicIP != "" && !state.IsPhaseComplete(p.name)
+}
+
+func TestRunnerRun_JudgesPhasesAfterEarlierOnes(t *testing.T) {
+	setIP := &ipPhase{mockPhase{name: "ML Node Configuration", shouldRun: true}}
+	firewall := &needsIPPhase{mockPhase{name: "ML Node Firewall"}}
+	runner := NewRunner([]Phase{setIP, firewall}, config.NewState(t.TempDir()))
+
+	if got := planDecisions(runner.Plan()); got != "ml-node-configuration=run ml-node-firewall=skip" {
+		t.Errorf("Plan() on a fresh state = %s", got)
+	}
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if !setIP.ran || !firewall.ran {
+		t.Errorf("ran = %v/%v, want both: the firewall must see the IP set by the earlier phase", setIP.ran, firewall.ran)
+	}
+}
@@ -0,0 +1,515 @@
+package phases
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/inc4/gonka-nop/internal/config"
+)
+
+// Preflight check results, from best to worst.
+const (
+	PreflightPass = "pass"
+	PreflightSkip = "skip"
+	PreflightWarn = "warn"
+	PreflightFail = "fail"
+)
+
+// Minimum versions preflight accepts.
+const (
+	minDockerMajor  = 24
+	minComposeMajor = 2
+)
+
+// cudaTestImage is the image used to check that GPUs are usable from Docker.
+const cudaTestImage = "nvidia/cuda:12.6.0-base-ubuntu22.04"
+
+// PreflightCheck is the result of one read-only host check.
+type PreflightCheck struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Result  string `json:"result"` // pass, warn, fail or skip
+	Message string `json:"message"`
+	Fix     string `json:"fix,omitempty"`
+}
+
+// PreflightReport is the outcome of all checks for one host.
+type PreflightReport struct {
+	GeneratedAt time.Time        `json:"generated_at"`
+	NodeType    string           `json:"node_type"`
+	Result      string           `json:"result"` // worst check result (skip counts as pass)
+	Checks      []PreflightCheck `json:"checks"`
+}
+
+// Count returns how many checks have the given result.
+func (r *PreflightReport) Count(result string) int {
+	n := 0
+	for _, c := range r.Checks {
+		if c.Result == result {
+			n++
+		}
+	}
+	return n
+}
+
+// Preflight is the detection half of the Prerequisites phase: nothing is
+// installed, started, mounted or pulled, and state is not modified. The
+// preflight command reports its checks; setup acts on them (see
+// Prerequisites.Run).
+type Preflight struct {
+	// AllowPull lets the CUDA-in-Docker check pull its test image when it
+	// is not present locally. Off by default, the check is then skipped.
+	AllowPull bool
+
+	// promptSudo lets sudo ask for a password (setup); preflight never
+	// prompts.
+	promptSudo bool
+
+	run      func(ctx context.Context, name string, args ...string) (string, error)
+	listen   func(port int) error
+	readFile func(name string) ([]byte, error)
+}
+
+// hostFacts is what the checks found, for setup to act on.
+type hostFacts struct {
+	Distro           config.Distro // Family is empty when /etc/os-release is unreadable
+	DockerOK         bool
+	Driver           config.DriverInfo // UserVersion is empty without a driver
+	ToolkitOK        bool
+	CUDAImageMissing bool // the CUDA check was skipped for lack of the image
+	GPUs             int
+	FMInstalled      bool
+	FMRunning        bool
+	AutoUpgrades     bool
+	DiskFreeGB       int
+	SpareDrives      []BlockDevice // unmounted drives while the output dir is on root
+}
+
+// NewPreflight creates a Preflight that runs host commands.
+func NewPreflight() *Preflight {
+	return &Preflight{run: runCmd, listen: listenPort, readFile: os.ReadFile}
+}
+
+// Run checks the host for the node type in state.
+func (p *Preflight) Run(ctx context.Context, state *config.State) *PreflightReport {
+	rep, _ := p.detect(ctx, state)
+	return rep
+}
+
+// detect runs every check and also returns the facts behind them.
+func (p *Preflight) detect(ctx context.Context, state *config.State) (*PreflightReport, *hostFacts) {
+	rep := &PreflightReport{GeneratedAt: time.Now().UTC(), NodeType: state.EffectiveNodeType()}
+	f := &hostFacts{}
+	add := func(c PreflightCheck) { rep.Checks = append(rep.Checks, c) }
+
+	add(p.checkDistro(f))
+	add(p.checkDocker(ctx, f))
+	add(p.checkCompose(ctx, f.DockerOK))
+
+	if state.IsNetworkOnly() {
+		for _, id := range []string{"nvidia_driver", "driver_consistency", "container_toolkit", "cuda_in_docker",
+			"fabric_manager", "secure_boot", "kernel_headers", "unattended_upgrades"} {
+			add(PreflightCheck{ID: id, Name: preflightNames[id], Result: PreflightSkip, Message: "not needed on a network node"})
+		}
+	} else {
+		add(p.checkDriver(ctx, f))
+		add(p.checkDriverConsistency(ctx, f))
+		add(p.checkContainerToolkit(ctx, f))
+		add(p.checkCUDAInDocker(ctx, f))
+		add(p.checkFabricManager(ctx, f))
+		add(p.checkSecureBoot(ctx))
+		add(p.checkKernelHeaders(ctx, f))
+		add(p.checkUnattendedUpgrades(ctx, f))
+	}
+
+	add(p.checkPorts(state))
+	add(p.checkDisk(ctx, state, f))
+
+	rep.Result = worstResult(rep.Checks)
+	return rep, f
+}
+
+// worstResult returns fail, warn or pass; skipped checks count as passed.
+func worstResult(checks []PreflightCheck) string {
+	worst := PreflightPass
+	for _, c := range checks {
+		if preflightRank[c.Result] > preflightRank[worst] {
+			worst = c.Result
+		}
+	}
+	return worst
+}
+
+var preflightRank = map[string]int{PreflightPass: 0, PreflightSkip: 0, PreflightWarn: 1, PreflightFail: 2}
+
+var preflightNames = map[string]string{
+	"distro":              "Linux distribution",
+	"docker":              "Docker Engine",
+	"docker_compose":      "Docker Compose",
+	"nvidia_driver":       "NVIDIA driver",
+	"driver_consistency":  "Driver consistency",
+	"container_toolkit":   "NVIDIA Container Toolkit",
+	"cuda_in_docker":      "CUDA in Docker",
+	"fabric_manager":      "Fabric Manager",
+	"secure_boot":         "Secure Boot",
+	"kernel_headers":      "Kernel headers",
+	"unattended_upgrades": "Unattended upgrades",
+	"ports":               "Ports",
+	"disk":                "Disk layout",
+}
+
+func check(id, result, msg, fix string) PreflightCheck {
+	return PreflightCheck{ID: id, Name: preflightNames[id], Result: result, Message: msg, Fix: fix}
+}
+
+func (p *Preflight) checkDistro(f *hostFacts) PreflightCheck {
+	data, err := p.readFile("/etc/os-release")
+	if err != nil {
+		return check("distro", PreflightWarn, "cannot read /etc/os-release", "")
+	}
+	d, err := ParseOSRelease(string(data))
+	if err != nil {
+		return check("distro", PreflightWarn, err.Error(), "")
+	}
+	f.Distro = d
+	msg := fmt.Sprintf("%s %s (%s family)", d.ID, d.Version, d.Family)
+	if d.Family != familyDebian {
+		// Installers assume apt
+		return check("distro", PreflightWarn, msg, "Ubuntu 22.04/24.04 is the tested platform")
+	}
+	return check("distro", PreflightPass, msg, "")
+}
+
+func (p *Preflight) checkDocker(ctx context.Context, f *hostFacts) PreflightCheck {
+	out, err := p.run(ctx, "docker", "--version")
+	if err != nil {
+		return check("docker", PreflightFail, "docker not found", "Install Docker Engine (gonka-nop setup offers it)")
+	}
+	f.DockerOK = true
+	ver, err := ParseDockerVersion(out)
+	if err != nil {
+		return check("docker", PreflightWarn, err.Error(), "")
+	}
+	if majorVersion(ver) < minDockerMajor {
+		return check("docker", PreflightWarn, "Docker "+ver,
+			fmt.Sprintf("Upgrade to Docker %d or newer", minDockerMajor))
+	}
+	return check("docker", PreflightPass, "Docker "+ver, "")
+}
+
+func (p *Preflight) checkCompose(ctx context.Context, dockerOK bool) PreflightCheck {
+	if !dockerOK {
+		return check("docker_compose", PreflightSkip, "Docker is not installed", "")
+	}
+	out, err := p.run(ctx, "docker", "compose", "version")
+	if err != nil {
+		return check("docker_compose", PreflightFail, "docker compose plugin not found", "Install docker-compose-plugin")
+	}
+	ver, err := ParseDockerComposeVersion(out)
+	if err != nil {
+		return check("docker_compose", PreflightWarn, err.Error(), "")
+	}
+	if majorVersion(ver) < minComposeMajor {
+		return check("docker_compose", PreflightFail, "Compose "+ver, "Compose v2 is required")
+	}
+	return check("docker_compose", PreflightPass, "Compose "+ver, "")
+}
+
+func (p *Preflight) checkDriver(ctx context.Context, f *hostFacts) PreflightCheck {
+	out, err := p.run(ctx, "nvidia-smi", "--query-gpu=driver_version", "--format=csv,noheader")
+	if err != nil {
+		return check("nvidia_driver", PreflightFail, "nvidia-smi not found or failing", "Install "+nvidiaDriver)
+	}
+	f.Driver.UserVersion = strings.TrimSpace(strings.Split(strings.TrimSpace(out), "\n")[0])
+	return check("nvidia_driver", PreflightPass, "driver "+f.Driver.UserVersion, "")
+}
+
+// checkDriverConsistency compares the userspace driver with the kernel
+// module and Fabric Manager.
+func (p *Preflight) checkDriverConsistency(ctx context.Context, f *hostFacts) PreflightCheck {
+	driver := f.Driver.UserVersion
+	if driver == "" {
+		return check("driver_consistency", PreflightSkip, "no NVIDIA driver", "")
+	}
+	var problems []string
+	fix := ""
+	if out, err := p.run(ctx, "modinfo", "nvidia"); err == nil {
+		f.Driver.KernelVersion = ParseModinfoVersion(out)
+		if kernel := f.Driver.KernelVersion; kernel != "" && kernel != driver {
+			problems = append(problems, fmt.Sprintf("kernel module %s", kernel))
+			fix = "sudo apt-get install --reinstall " + nvidiaDriver + " and reboot"
+			if f.rhel() {
+				fix = "sudo dnf reinstall nvidia-driver and reboot"
+			}
+		}
+	}
+	f.Driver.FMVersion = p.fabricManagerVersion(ctx, f)
+	if fm := f.Driver.FMVersion; fm != "" && DriverMajorVersion(fm) != DriverMajorVersion(driver) {
+		problems = append(problems, fmt.Sprintf("Fabric Manager %s", fm))
+		fix = installFix(f, fabricManagerPackage(f, driver))
+	}
+	f.Driver.Consistent = len(problems) == 0
+	if len(problems) > 0 {
+		return check("driver_consistency", PreflightFail,
+			fmt.Sprintf("userspace %s differs from %s", driver, strings.Join(problems, " and ")), fix)
+	}
+	return check("driver_consistency", PreflightPass, "userspace, kernel module and Fabric Manager agree", "")
+}
+
+func (p *Preflight) checkContainerToolkit(ctx context.Context, f *hostFacts) PreflightCheck {
+	out, err := p.run(ctx, "nvidia-ctk", "--version")
+	if err != nil {
+		return check("container_toolkit", PreflightFail, "nvidia-ctk not found", "Install nvidia-container-toolkit")
+	}
+	f.ToolkitOK = true
+	return check("container_toolkit", PreflightPass, strings.Split(strings.TrimSpace(out), "\n")[0], "")
+}
+
+// checkCUDAInDocker runs nvidia-smi in a CUDA container. The image is only
+// pulled with AllowPull. Docker access needing sudo is detected the same
+// way as setup does (docker info); sudo only prompts with promptSudo.
+func (p *Preflight) checkCUDAInDocker(ctx context.Context, f *hostFacts) PreflightCheck {
+	if !f.DockerOK || f.Driver.UserVersion == "" {
+		return check("cuda_in_docker", PreflightSkip, "Docker or the NVIDIA driver is missing", "")
+	}
+	sudo := "-n"
+	if p.promptSudo {
+		sudo = "-E"
+	}
+	docker := func(args ...string) (string, error) {
+		if _, err := p.run(ctx, "docker", "info"); err != nil {
+			return p.run(ctx, "sudo", append([]string{sudo, "docker"}, args...)...)
+		}
+		return p.run(ctx, "docker", args...)
+	}
+
+	pull := "--pull=never"
+	if _, err := docker("image", "inspect", cudaTestImage); err != nil {
+		if !p.AllowPull {
+			f.CUDAImageMissing = true
+			return check("cuda_in_docker", PreflightSkip, cudaTestImage+" not present locally",
+				"Re-run with --allow-pull to test GPU access from Docker")
+		}
+		pull = "--pull=missing"
+	}
+	if _, err := docker("run", "--rm", pull, "--gpus", "all", cudaTestImage, "nvidia-smi", "-L"); err != nil {
+		return check("cuda_in_docker", PreflightFail, "nvidia-smi fails inside a container",
+			"Run 'sudo nvidia-ctk runtime configure --runtime=docker' and restart Docker")
+	}
+	return check("cuda_in_docker", PreflightPass, "GPUs visible inside a container", "")
+}
+
+func (p *Preflight) checkFabricManager(ctx context.Context, f *hostFacts) PreflightCheck {
+	driver := f.Driver.UserVersion
+	if driver == "" {
+		return check("fabric_manager", PreflightSkip, "no NVIDIA driver", "")
+	}
+	out, err := p.run(ctx, "nvidia-smi", "-L")
+	if err != nil {
+		return check("fabric_manager", PreflightSkip, "cannot list GPUs", "")
+	}
+	gpus := countGPUs(out)
+	f.GPUs = gpus
+	if gpus <= 1 {
+		return check("fabric_manager", PreflightSkip, "single GPU", "")
+	}
+	if _, err := p.run(ctx, "systemctl", "is-active", "nvidia-fabricmanager"); err == nil {
+		f.FMRunning = true
+		return check("fabric_manager", PreflightPass, fmt.Sprintf("running (%d GPUs)", gpus), "")
+	}
+	if p.fabricManagerVersion(ctx, f) != "" {
+		f.FMInstalled = true
+		return check("fabric_manager", PreflightFail, fmt.Sprintf("installed but not running (%d GPUs)", gpus),
+			"sudo systemctl enable --now nvidia-fabricmanager")
+	}
+	// PCIe-only boxes work without it; NVLink systems do not
+	return check("fabric_manager", PreflightWarn, fmt.Sprintf("not installed (%d GPUs)", gpus),
+		"Required for NVLink systems: "+installFix(f, fabricManagerPackage(f, driver)))
+}
+
+func (p *Preflight) checkSecureBoot(ctx context.Context) PreflightCheck {
+	out, err := p.run(ctx, "mokutil", "--sb-state")
+	if err != nil {
+		return check("secure_boot", PreflightPass, "disabled or not an EFI system", "")
+	}
+	if strings.Contains(strings.ToLower(out), "secureboot enabled") {
+		return check("secure_boot", PreflightWarn, "enabled: unsigned NVIDIA kernel modules will not load",
+			"Disable Secure Boot or enroll a MOK for the driver")
+	}
+	return check("secure_boot", PreflightPass, "disabled", "")
+}
+
+func (p *Preflight) checkKernelHeaders(ctx context.Context, f *hostFacts) PreflightCheck {
+	out, err := p.run(ctx, "uname", "-r")
+	if err != nil {
+		return check("kernel_headers", PreflightWarn, "cannot detect the running kernel", "")
+	}
+	pkg := "linux-headers-" + strings.TrimSpace(out)
+	if f.rhel() {
+		pkg = "kernel-devel-" + strings.TrimSpace(out)
+	}
+	if !p.packageInstalled(ctx, f, pkg) {
+		return check("kernel_headers", PreflightWarn, pkg+" not installed (needed to build the driver)", installFix(f, pkg))
+	}
+	return check("kernel_headers", PreflightPass, pkg+" installed", "")
+}
+
+func (p *Preflight) checkUnattendedUpgrades(ctx context.Context, f *hostFacts) PreflightCheck {
+	pkg, fix := "unattended-upgrades", "sudo apt-mark hold 'nvidia-*' or remove unattended-upgrades"
+	if f.rhel() {
+		pkg, fix = "dnf-automatic", "add excludepkgs=nvidia-* to /etc/dnf/dnf.conf or remove dnf-automatic"
+	}
+	if p.packageInstalled(ctx, f, pkg) {
+		f.AutoUpgrades = true
+		return check("unattended_upgrades", PreflightWarn, pkg+" installed: can upgrade the NVIDIA driver under a running node", fix)
+	}
+	return check("unattended_upgrades", PreflightPass, pkg+" not installed", "")
+}
+
+// dpkgInstalled is the dpkg-query ${Status} of an installed package. dpkg -l
+// also lists removed packages whose config files are left ("rc").
+const dpkgInstalled = "install ok installed"
+
+func (f *hostFacts) rhel() bool {
+	return f.Distro.Family == familyRHEL
+}
+
+// packageInstalled reports whether pkg is installed, with rpm on the RHEL
+// family and dpkg-query otherwise.
+func (p *Preflight) packageInstalled(ctx context.Context, f *hostFacts, pkg string) bool {
+	if f.rhel() {
+		_, err := p.run(ctx, "rpm", "-q", pkg)
+		return err == nil
+	}
+	out, err := p.run(ctx, "dpkg-query", "-W", "-f=${Status}", pkg)
+	return err == nil && strings.TrimSpace(out) == dpkgInstalled
+}
+
+// fabricManagerVersion returns the installed Fabric Manager version, or "".
+func (p *Preflight) fabricManagerVersion(ctx context.Context, f *hostFacts) string {
+	if f.rhel() {
+		out, err := p.run(ctx, "rpm", "-q", "--qf", "%{VERSION}-%{RELEASE}\n", "nvidia-fabric-manager")
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(out)
+	}
+	out, _ := p.run(ctx, "dpkg-query", "-W", "-f=${Status} ${Version}\n", "nvidia-fabricmanager-*")
+	for _, line := range strings.Split(out, "\n") {
+		if version, ok := strings.CutPrefix(line, dpkgInstalled+" "); ok {
+			return strings.TrimSpace(version)
+		}
+	}
+	return ""
+}
+
+// fabricManagerPackage is the Fabric Manager package matching driver.
+func fabricManagerPackage(f *hostFacts, driver string) string {
+	if f.rhel() {
+		return "nvidia-fabric-manager"
+	}
+	return "nvidia-fabricmanager-" + DriverMajorVersion(driver)
+}
+
+// installFix is the command that installs pkg on the host's distro family.
+func installFix(f *hostFacts, pkg string) string {
+	if f.rhel() {
+		return "sudo dnf install " + pkg
+	}
+	return "sudo apt-get install " + pkg
+}
+
+func (p *Preflight) checkPorts(state *config.State) PreflightCheck {
+	var busy []string
+	for _, pt := range requiredPorts(state) {
+		if err := p.listen(pt.port); err != nil {
+			busy = append(busy, fmt.Sprintf("%d (%s)", pt.port, pt.name))
+		}
+	}
+	if len(busy) > 0 {
+		return check("ports", PreflightWarn, "in use: "+strings.Join(busy, ", "), "Stop the services holding these ports")
+	}
+	return check("ports", PreflightPass, fmt.Sprintf("%d required ports free", len(requiredPorts(state))), "")
+}
+
+// checkDisk reports free space where the node will be deployed and whether
+// a large unmounted drive would be a better place for it.
+func (p *Preflight) checkDisk(ctx context.Context, state *config.State, f *hostFacts) PreflightCheck {
+	dir := existingParent(state.OutputDir)
+	out, err := p.run(ctx, "df", "--output=avail", "-BG", dir)
+	if err != nil {
+		return check("disk", PreflightWarn, "cannot read free space for "+dir, "")
+	}
+	freeGB, err := ParseDiskFreeGB(out)
+	if err != nil {
+		return check("disk", PreflightWarn, err.Error(), "")
+	}
+	f.DiskFreeGB = freeGB
+	minGB := minDiskGB(state)
+	msg := fmt.Sprintf("%d GB free at %s (%d GB minimum)", freeGB, dir, minGB)
+
+	deploy := p.dfSource(ctx, dir)
+	lsblk, err := p.run(ctx, "lsblk", "-J", "-b", "-o", "NAME,SIZE,TYPE,MOUNTPOINT,FSTYPE")
+	if err == nil && deploy != "" && deploy == p.dfSource(ctx, "/") {
+		if devices, parseErr := ParseLsblkJSON(lsblk); parseErr == nil {
+			f.SpareDrives = FindUnmountedDrives(devices, minUnmountedDriveGB)
+		}
+	}
+
+	if freeGB < minGB {
+		return check("disk", PreflightFail, msg, "Use --output on a larger volume")
+	}
+	if len(f.SpareDrives) > 0 {
+		d := f.SpareDrives[0]
+		return check("disk", PreflightWarn,
+			fmt.Sprintf("%s, on the root filesystem; /dev/%s (%s) is unmounted", msg, d.Name, FormatDriveSize(d.Size)),
+			"Mount the drive and deploy there (gonka-nop setup offers to)")
+	}
+	return check("disk", PreflightPass, msg, "")
+}
+
+func (p *Preflight) dfSource(ctx context.Context, path string) string {
+	out, err := p.run(ctx, "df", "--output=source", path)
+	if err != nil {
+		return ""
+	}
+	source, _ := ParseDfSource(out)
+	return source
+}
+
+// existingParent returns dir or its closest existing ancestor, so df works
+// before the output directory is created.
+func existingParent(dir string) string {
+	dir = filepath.Clean(dir)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// majorVersion returns the leading number of "27.4.1" or "v2.32.4".
+func majorVersion(v string) int {
+	v = strings.TrimPrefix(v, "v")
+	major, _ := strconv.Atoi(strings.SplitN(v, ".", 2)[0])
+	return major
+}
+
+func listenPort(port int) error {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	return ln.Close()
+}
@@ -0,0 +1,329 @@
+package phases
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/inc4/gonka-nop/internal/config"
+)
+
+// fakeHost answers commands from canned output, longest matching prefix
+// first; commands not listed fail. Files are read from output by path.
+type fakeHost struct {
+	output map[string]string // command prefix or file path -> stdout or content
+	calls  []string
+}
+
+func (f *fakeHost) readFile(name string) ([]byte, error) {
+	content, ok := f.output[name]
+	if !ok {
+		return nil, fmt.Errorf("open %s: no such file or directory", name)
+	}
+	return []byte(content), nil
+}
+
+func (f *fakeHost) run(_ context.Context, name string, args ...string) (string, error) {
+	call := strings.TrimSpace(name + " " + strings.Join(args, " "))
+	f.calls = append(f.calls, call)
+	match := ""
+	for prefix := range f.output {
+		if strings.HasPrefix(call, prefix) && len(prefix) > len(match) {
+			match = prefix
+		}
+	}
+	if match == "" {
+		return "", fmt.Errorf("%s: exit status 1", name)
+	}
+	return f.output[match], nil
+}
+
+const (
+	ubuntuOSRelease = "ID=ubuntu\nVERSION_ID=\"22.04\"\n"
+	rockyOSRelease  = "ID=\"rocky\"\nID_LIKE=\"rhel centos fedora\"\nVERSION_ID=\"9.4\"\n"
+)
+
+// healthyGPUHost is a single-GPU Ubuntu host deploying to dir where every
+// check passes.
+func healthyGPUHost(dir string) map[string]string {
+	return map[string]string{
+		"/etc/os-release":        ubuntuOSRelease,
+		"docker --version":       "Docker version 27.4.1, build b9d17ea\n",
+		"docker compose version": "Docker Compose version v2.32.4\n",
+		"docker info":            "Server Version: 27.4.1\n",
+		"docker image inspect":   "[]\n",
+		"docker run":             "GPU 0: NVIDIA H100\n",
+		"nvidia-smi --query-gpu": "570.133.20\n",
+		"nvidia-smi -L":          "GPU 0: NVIDIA H100 80GB HBM3 (UUID: GPU-1)\n",
+		"modinfo nvidia":         "version:        570.133.20\n",
+		"nvidia-ctk --version":   "NVIDIA Container Toolkit CLI version 1.17.4\n",
+		"uname -r":               "6.8.0-51-generic\n",
+		"dpkg-query -W -f=${Status} linux-headers-6.8.0-51-generic": dpkgInstalled,
+		"df --output=avail":          "Avail\n 1800G\n",
+		"df --output=source " + dir:  "Filesystem\n/dev/nvme1n1\n",
+		"df --output=source /":       "Filesystem\n/dev/nvme0n1p2\n",
+		"lsblk":                      `{"blockdevices": []}`,
+		"mokutil --sb-state":         "SecureBoot disabled\n",
+		"systemctl is-active nvidia": "active\n",
+	}
+}
+
+func runPreflight(t *testing.T, state *config.State, output map[string]string, busyPort int) (*PreflightReport, *fakeHost) {
+	t.Helper()
+	host := &fakeHost{output: output}
+	p := &Preflight{run: host.run, readFile: host.readFile, listen: func(port int) error {
+		if port == busyPort {
+			return fmt.Errorf("address already in use")
+		}
+		return nil
+	}}
+	return p.Run(context.Background(), state), host
+}
+
+func checkResult(rep *PreflightReport, id string) PreflightCheck {
+	for _, c := range rep.Checks {
+		if c.ID == id {
+			return c
+		}
+	}
+	return PreflightCheck{}
+}
+
+func TestPreflight(t *testing.T) {
+	tests := []struct {
+		name     string
+		nodeType string
+		edit     func(o map[string]string, dir string)
+		busyPort int
+		want     map[string]string // check ID -> result
+	}{
+		{
+			name: "healthy GPU host",
+			want: map[string]string{"docker": PreflightPass, "docker_compose": PreflightPass, "nvidia_driver": PreflightPass,
+				"driver_consistency": PreflightPass, "cuda_in_docker": PreflightPass, "fabric_manager": PreflightSkip,
+				"secure_boot": PreflightPass, "kernel_headers": PreflightPass, "unattended_upgrades": PreflightPass,
+				"ports": PreflightPass, "disk": PreflightPass},
+		},
+		{
+			name:     "network node skips GPU checks",
+			nodeType: config.NodeTypeNetwork,
+			edit:     func(o map[string]string, _ string) { delete(o, "nvidia-smi --query-gpu") },
+			want:     map[string]string{"nvidia_driver": PreflightSkip, "cuda_in_docker": PreflightSkip, "docker": PreflightPass},
+		},
+		{
+			name: "no docker",
+			edit: func(o map[string]string, _ string) { delete(o, "docker --version") },
+			want: map[string]string{"docker": PreflightFail, "docker_compose": PreflightSkip, "cuda_in_docker": PreflightSkip},
+		},
+		{
+			name: "compose v1",
+			edit: func(o map[string]string, _ string) {
+				o["docker compose version"] = "docker-compose version 1.29.2, build 5becea4c\n"
+			},
+			want: map[string]string{"docker_compose": PreflightFail},
+		},
+		{
+			name: "kernel module mismatch",
+			edit: func(o map[string]string, _ string) { o["modinfo nvidia"] = "version:        560.35.03\n" },
+			want: map[string]string{"driver_consistency": PreflightFail},
+		},
+		{
+			name: "CUDA image not present",
+			edit: func(o map[string]string, _ string) { delete(o, "docker image inspect") },
+			want: map[string]string{"cuda_in_docker": PreflightSkip},
+		},
+		{
+			name: "multi-GPU without Fabric Manager",
+			edit: func(o map[string]string, _ string) {
+				o["nvidia-smi -L"] = "GPU 0: NVIDIA H100\nGPU 1: NVIDIA H100\n"
+				delete(o, "systemctl is-active nvidia")
+			},
+			want: map[string]string{"fabric_manager": PreflightWarn},
+		},
+		{
+			name: "secure boot, no headers, unattended-upgrades",
+			edit: func(o map[string]string, _ string) {
+				o["mokutil --sb-state"] = "SecureBoot enabled\n"
+				delete(o, "dpkg-query -W -f=${Status} linux-headers-6.8.0-51-generic")
+				o["dpkg-query -W -f=${Status} unattended-upgrades"] = dpkgInstalled
+			},
+			want: map[string]string{"secure_boot": PreflightWarn, "kernel_headers": PreflightWarn, "unattended_upgrades": PreflightWarn},
+		},
+		{
+			name: "removed packages with config files left",
+			edit: func(o map[string]string, _ string) {
+				o["dpkg-query -W -f=${Status} linux-headers-6.8.0-51-generic"] = "deinstall ok config-files"
+				o["dpkg-query -W -f=${Status} unattended-upgrades"] = "deinstall ok config-files"
+			},
+			want: map[string]string{"kernel_headers": PreflightWarn, "unattended_upgrades": PreflightPass},
+		},
+		{
+			name:     "port in use",
+			busyPort: 26657,
+			want:     map[string]string{"ports": PreflightWarn},
+		},
+		{
+			name: "low disk",
+			edit: func(o map[string]string, _ string) { o["df --output=avail"] = "Avail\n 90G\n" },
+			want: map[string]string{"disk": PreflightFail},
+		},
+		{
+			name: "on root with a spare drive",
+			edit: func(o map[string]string, dir string) {
+				delete(o, "df --output=source "+dir)
+				o["lsblk"] = `{"blockdevices": [{"name": "nvme1n1", "size": 3840755982336, "type": "disk", "mountpoint": null, "fstype": null}]}`
+			},
+			want: map[string]string{"disk": PreflightWarn},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			output := healthyGPUHost(dir)
+			if tt.edit != nil {
+				tt.edit(output, dir)
+			}
+			state := config.NewState(dir)
+			state.NodeType = tt.nodeType
+			rep, host := runPreflight(t, state, output, tt.busyPort)
+
+			for id, want := range tt.want {
+				if got := checkResult(rep, id); got.Result != want {
+					t.Errorf("%s = %s (%s), want %s", id, got.Result, got.Message, want)
+				}
+			}
+			for _, call := range host.calls {
+				if strings.HasPrefix(call, "sudo") || strings.Contains(call, "install") || strings.Contains(call, "--pull=missing") {
+					t.Errorf("preflight ran a mutating command: %q", call)
+				}
+			}
+		})
+	}
+}
+
+func TestPreflight_AllowPull(t *testing.T) {
+	dir := t.TempDir()
+	output := healthyGPUHost(dir)
+	delete(output, "docker image inspect")
+	host := &fakeHost{output: output}
+	p := &Preflight{AllowPull: true, run: host.run, readFile: host.readFile, listen: func(int) error { return nil }}
+
+	rep := p.Run(context.Background(), config.NewState(dir))
+	if got := checkResult(rep, "cuda_in_docker"); got.Result != PreflightPass {
+		t.Errorf("cuda_in_docker = %+v, want pass", got)
+	}
+	if !strings.Contains(strings.Join(host.calls, "\n"), "docker run --rm --pull=missing --gpus all") {
+		t.Errorf("calls = %q, want the image pulled", host.calls)
+	}
+}
+
+func TestPreflight_RHEL(t *testing.T) {
+	dir := t.TempDir()
+	output := healthyGPUHost(dir)
+	output["/etc/os-release"] = rockyOSRelease
+	output["uname -r"] = "5.14.0-427.13.1.el9_4.x86_64\n"
+	output["rpm -q dnf-automatic"] = "dnf-automatic-4.14.0-9.el9.noarch\n"
+	output["nvidia-smi -L"] = "GPU 0: NVIDIA H100\nGPU 1: NVIDIA H100\n"
+	delete(output, "systemctl is-active nvidia")
+
+	rep, host := runPreflight(t, config.NewState(dir), output, 0)
+	for id, want := range map[string]PreflightCheck{
+		"kernel_headers":      {Result: PreflightWarn, Fix: "sudo dnf install kernel-devel-5.14.0-427.13.1.el9_4.x86_64"},
+		"unattended_upgrades": {Result: PreflightWarn, Fix: "add excludepkgs=nvidia-* to /etc/dnf/dnf.conf or remove dnf-automatic"},
+		"fabric_manager":      {Result: PreflightWarn, Fix: "Required for NVLink systems: sudo dnf install nvidia-fabric-manager"},
+	} {
+		if got := checkResult(rep, id); got.Result != want.Result || got.Fix != want.Fix {
+			t.Errorf("%s = %s (%s), want %s (%s)", id, got.Result, got.Fix, want.Result, want.Fix)
+		}
+	}
+	for _, call := range host.calls {
+		if strings.HasPrefix(call, "dpkg") {
+			t.Errorf("dpkg queried on a RHEL host: %q", call)
+		}
+	}
+
+	output["rpm -q kernel-devel-5.14.0-427.13.1.el9_4.x86_64"] = "kernel-devel-5.14.0-427.13.1.el9_4.x86_64\n"
+	rep, _ = runPreflight(t, config.NewState(dir), output, 0)
+	if got := checkResult(rep, "kernel_headers"); got.Result != PreflightPass {
+		t.Errorf("kernel_headers = %+v, want pass", got)
+	}
+}
+
+func TestWorstResult(t *testing.T) {
+	tests := []struct {
+		results []string
+		want    string
+	}{
+		{[]string{PreflightPass, PreflightSkip}, PreflightPass},
+		{[]string{PreflightPass, PreflightWarn, PreflightSkip}, PreflightWarn},
+		{[]string{PreflightWarn, PreflightFail, PreflightPass}, PreflightFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			var checks []PreflightCheck
+			for _, r := range tt.results {
+				checks = append(checks, PreflightCheck{Result: r})
+			}
+			if got := worstResult(checks); got != tt.want {
+				t.Errorf("worstResult(%v) = %s, want %s", tt.results, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMajorVersion(t *testing.T) {
+	tests := map[string]int{"27.4.1": 27, "v2.32.4": 2, "1.29.2": 1, "": 0}
+	for in, want := range tests {
+		if got := majorVersion(in); got != want {
+			t.Errorf("majorVersion(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestPreflightDetectFacts(t *testing.T) {
+	dir := t.TempDir()
+	output := healthyGPUHost(dir)
+	output["nvidia-smi -L"] = "GPU 0: NVIDIA H100\nGPU 1: NVIDIA H100\n"
+	output["dpkg-query -W -f=${Status} ${Version}\n nvidia-fabricmanager-*"] = "deinstall ok config-files 560.35.03-1\n" +
+		dpkgInstalled + " 570.133.20-1\n"
+	delete(output, "systemctl is-active nvidia")
+	delete(output, "docker image inspect")
+	delete(output, "df --output=source "+dir)
+	output["lsblk"] = `{"blockdevices": [{"name": "nvme1n1", "size": 3840755982336, "type": "disk", "mountpoint": null, "fstype": null}]}`
+	host := &fakeHost{output: output}
+	p := &Preflight{run: host.run, readFile: host.readFile, listen: func(int) error { return nil }}
+
+	_, f := p.detect(context.Background(), config.NewState(dir))
+	want := config.DriverInfo{UserVersion: "570.133.20", KernelVersion: "570.133.20", FMVersion: "570.133.20-1", Consistent: true}
+	if f.Driver != want {
+		t.Errorf("Driver = %+v, want %+v", f.Driver, want)
+	}
+	if !f.DockerOK || !f.ToolkitOK || !f.CUDAImageMissing || f.GPUs != 2 || !f.FMInstalled || f.FMRunning {
+		t.Errorf("facts = %+v", f)
+	}
+	if f.DiskFreeGB != 1800 || len(f.SpareDrives) != 1 || f.SpareDrives[0].Name != "nvme1n1" {
+		t.Errorf("disk facts = %d GB, spare %+v", f.DiskFreeGB, f.SpareDrives)
+	}
+}
+
+func TestReportPrerequisites(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   PreflightCheck
+		wantErr bool
+	}{
+		{name: "warning", check: check("docker", PreflightWarn, "Docker 20.10.7", "Upgrade")},
+		{name: "non-fatal failure", check: check("driver_consistency", PreflightFail, "mismatch", "")},
+		{name: "fatal failure", check: check("docker_compose", PreflightFail, "Compose 1.29.2", ""), wantErr: true},
+		{name: "disk is left to the storage step", check: check("disk", PreflightFail, "90 GB free", "")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := reportPrerequisites(&PreflightReport{Checks: []PreflightCheck{tt.check}})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("reportPrerequisites() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
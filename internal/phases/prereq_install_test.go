@@ -203,3 +203,23 @@ func TestPrerequisites_MockedCheck(t *testing.T) {
 		t.Fatalf("mockedCheck failed: %v", err)
 	}
 }
+
+func TestRequiredPorts_TLS(t *testing.T) {
+	state := config.NewState(t.TempDir())
+	state.APIPort = 8000
+	has := func(port int) bool {
+		for _, pt := range requiredPorts(state) {
+			if pt.port == port {
+				return true
+			}
+		}
+		return false
+	}
+	if !has(8000) || has(config.TLSHTTPSPort) {
+		t.Errorf("without TLS: ports = %v, want the API port only", requiredPorts(state))
+	}
+	state.TLSDomain = "node.example.com"
+	if has(8000) || !has(config.TLSHTTPPort) || !has(config.TLSHTTPSPort) {
+		t.Errorf("with TLS: ports = %v, want 80 and 443 instead of the API port", requiredPorts(state))
+	}
+}
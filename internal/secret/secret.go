@@ -0,0 +1,204 @@
+// Package secret resolves secrets (such as the keyring password) from a
+// source reference instead of storing them in state.json or config.env.
+//
+// A source reference is one of:
+//
+//	prompt          ask interactively each time the secret is needed
+//	file:/abs/path  read from a file that only its owner can read (0400)
+//	env:NAME        read from environment variable NAME
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/inc4/gonka-nop/internal/ui"
+)
+
+// Source reference prefixes.
+const (
+	SourcePrompt = "prompt"
+	prefixFile   = "file:"
+	prefixEnv    = "env:"
+)
+
+// RequiredFileMode is the only permission set accepted for secret files.
+const RequiredFileMode os.FileMode = 0400
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ErrNotProvided is returned when a source yields no value.
+var ErrNotProvided = errors.New("secret not provided")
+
+// Provider supplies a secret on demand.
+type Provider interface {
+	// Secret returns the secret value.
+	Secret() (string, error)
+	// Ref returns the source reference that can be persisted in place of the value.
+	Ref() string
+}
+
+// Prompt asks for the secret interactively.
+type Prompt struct {
+	Message string
+	Hint    string // how to provide the secret non-interactively, shown on error
+}
+
+// Secret prompts for the value. It fails in non-interactive mode.
+func (p Prompt) Secret() (string, error) {
+	if ui.IsNonInteractive() {
+		if p.Hint != "" {
+			return "", fmt.Errorf("%w: cannot prompt in non-interactive mode (%s)", ErrNotProvided, p.Hint)
+		}
+		return "", fmt.Errorf("%w: cannot prompt in non-interactive mode", ErrNotProvided)
+	}
+	value, err := ui.Password(p.Message)
+	if err != nil {
+		return "", fmt.Errorf("password prompt: %w", err)
+	}
+	if value == "" {
+		return "", ErrNotProvided
+	}
+	return value, nil
+}
+
+// Ref returns "prompt".
+func (p Prompt) Ref() string { return SourcePrompt }
+
+// File reads the secret from a file with mode 0400. The file holds either the
+// bare value or, when Key is set, a single KEY='value' line so the same file
+// can be handed to docker compose as an env_file.
+type File struct {
+	Path string
+	Key  string
+}
+
+// Secret reads the file after checking its permissions.
+func (f File) Secret() (string, error) {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return "", fmt.Errorf("secret file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("secret file %s is not a regular file", f.Path)
+	}
+	if perm := info.Mode().Perm(); perm != RequiredFileMode {
+		return "", fmt.Errorf("secret file %s has mode %04o, want %04o (chmod 0400 %s)",
+			f.Path, perm, RequiredFileMode, f.Path)
+	}
+
+	data, err := os.ReadFile(f.Path) // #nosec G304 - path from operator config
+	if err != nil {
+		return "", fmt.Errorf("read secret file: %w", err)
+	}
+	value := strings.TrimRight(string(data), "\r\n")
+	if f.Key != "" && strings.HasPrefix(value, f.Key+"=") {
+		value = unquote(strings.TrimPrefix(value, f.Key+"="))
+	}
+	if value == "" {
+		return "", fmt.Errorf("%w: %s is empty", ErrNotProvided, f.Path)
+	}
+	return value, nil
+}
+
+// Ref returns "file:<path>".
+func (f File) Ref() string { return prefixFile + f.Path }
+
+// Env reads the secret from an environment variable.
+type Env struct {
+	Name string
+}
+
+// Secret returns the variable's value.
+func (e Env) Secret() (string, error) {
+	value := os.Getenv(e.Name)
+	if value == "" {
+		return "", fmt.Errorf("%w: $%s is not set", ErrNotProvided, e.Name)
+	}
+	return value, nil
+}
+
+// Ref returns "env:<name>".
+func (e Env) Ref() string { return prefixEnv + e.Name }
+
+// Parse turns a source reference into a Provider. An empty reference means
+// prompt. key is passed to File providers (see File.Key).
+func Parse(ref, key string) (Provider, error) {
+	switch {
+	case ref == "" || ref == SourcePrompt:
+		return Prompt{}, nil
+	case strings.HasPrefix(ref, prefixFile):
+		path := strings.TrimPrefix(ref, prefixFile)
+		if !filepath.IsAbs(path) {
+			return nil, fmt.Errorf("secret file path must be absolute, got %q", path)
+		}
+		return File{Path: filepath.Clean(path), Key: key}, nil
+	case strings.HasPrefix(ref, prefixEnv):
+		name := strings.TrimPrefix(ref, prefixEnv)
+		if !envNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid environment variable name %q", name)
+		}
+		return Env{Name: name}, nil
+	default:
+		return nil, fmt.Errorf("unknown secret source %q (want prompt, file:/path or env:NAME)", ref)
+	}
+}
+
+// FileRef returns the source reference for a secret file.
+func FileRef(path string) string { return prefixFile + path }
+
+// EnvRef returns the source reference for an environment variable.
+func EnvRef(name string) string { return prefixEnv + name }
+
+// WriteFile writes value to path with the given mode (owner-only, e.g. 0400
+// or 0600), replacing any existing file. When key is set the file holds a
+// single KEY='value' line (see File.Key); single quotes stop docker compose
+// from interpolating "$" in the value.
+func WriteFile(path, key, value string, mode os.FileMode) error {
+	if mode&0077 != 0 {
+		return fmt.Errorf("secret file mode %04o is readable by others", mode)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("secret must not contain newlines")
+	}
+	if key != "" && strings.Contains(value, "'") {
+		return fmt.Errorf("secret must not contain single quotes")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("create secret dir: %w", err)
+	}
+	// 0400 files cannot be opened for writing, so replace instead of truncate.
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("replace secret file: %w", err)
+	}
+
+	content := value + "\n"
+	if key != "" {
+		content = key + "='" + value + "'\n"
+	}
+	f, err := os.OpenFile(filepath.Clean(path), os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return fmt.Errorf("create secret file: %w", err)
+	}
+	if _, err := f.WriteString(content); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write secret file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write secret file: %w", err)
+	}
+	// OpenFile applies the umask; make sure the result is exactly mode.
+	return os.Chmod(path, mode)
+}
+
+// unquote strips one pair of surrounding single or double quotes.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
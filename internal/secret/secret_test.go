@@ -0,0 +1,141 @@
+package secret
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/inc4/gonka-nop/internal/ui"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    Provider
+		wantErr bool
+	}{
+		{ref: "", want: Prompt{}},
+		{ref: "prompt", want: Prompt{}},
+		{ref: "file:/etc/gonka/keyring-password", want: File{Path: "/etc/gonka/keyring-password", Key: "KEY"}},
+		{ref: "env:GONKA_KEYRING_PASSWORD", want: Env{Name: "GONKA_KEYRING_PASSWORD"}},
+		{ref: "file:relative/path", wantErr: true},
+		{ref: "env:1BAD", wantErr: true},
+		{ref: "vault:secret/gonka", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := Parse(tt.ref, "KEY")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) expected error", tt.ref)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.ref, err)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %#v, want %#v", tt.ref, got, tt.want)
+			}
+			if tt.ref != "" && got.Ref() != tt.ref {
+				t.Errorf("Ref() = %q, want %q", got.Ref(), tt.ref)
+			}
+		})
+	}
+}
+
+func TestWriteFile_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.env")
+	if err := WriteFile(path, "KEYRING_PASSWORD", "pa$$word", RequiredFileMode); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	// Replacing an existing 0400 file must work
+	if err := WriteFile(path, "KEYRING_PASSWORD", "pa$$word2", RequiredFileMode); err != nil {
+		t.Fatalf("WriteFile() replace error: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != RequiredFileMode {
+		t.Errorf("mode = %04o, want 0400", info.Mode().Perm())
+	}
+	data, _ := os.ReadFile(path) // #nosec G304 - test temp dir
+	if string(data) != "KEYRING_PASSWORD='pa$$word2'\n" {
+		t.Errorf("content = %q", data)
+	}
+
+	got, err := File{Path: path, Key: "KEYRING_PASSWORD"}.Secret()
+	if err != nil || got != "pa$$word2" {
+		t.Errorf("Secret() = %q, %v", got, err)
+	}
+}
+
+func TestWriteFile_Rejects(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteFile(filepath.Join(dir, "a"), "", "two\nlines", RequiredFileMode); err == nil {
+		t.Error("expected error for newline")
+	}
+	if err := WriteFile(filepath.Join(dir, "b"), "KEY", "it's", RequiredFileMode); err == nil {
+		t.Error("expected error for single quote in env file")
+	}
+	if err := WriteFile(filepath.Join(dir, "c"), "KEY", "value", 0640); err == nil {
+		t.Error("expected error for a group-readable mode")
+	}
+}
+
+func TestFile_Secret(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string, mode os.FileMode) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	raw := write("raw", "hunter22\n", 0400)
+	if got, err := (File{Path: raw}).Secret(); err != nil || got != "hunter22" {
+		t.Errorf("raw file: got %q, %v", got, err)
+	}
+
+	loose := write("loose", "hunter22\n", 0644)
+	if _, err := (File{Path: loose}).Secret(); err == nil || !strings.Contains(err.Error(), "chmod 0400") {
+		t.Errorf("expected permission error, got %v", err)
+	}
+
+	empty := write("empty", "\n", 0400)
+	if _, err := (File{Path: empty}).Secret(); !errors.Is(err, ErrNotProvided) {
+		t.Errorf("expected ErrNotProvided for empty file, got %v", err)
+	}
+
+	if _, err := (File{Path: filepath.Join(dir, "missing")}).Secret(); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestEnv_Secret(t *testing.T) {
+	t.Setenv("GONKA_TEST_SECRET", "from-env")
+	if got, err := (Env{Name: "GONKA_TEST_SECRET"}).Secret(); err != nil || got != "from-env" {
+		t.Errorf("Secret() = %q, %v", got, err)
+	}
+	if _, err := (Env{Name: "GONKA_TEST_SECRET_UNSET"}).Secret(); !errors.Is(err, ErrNotProvided) {
+		t.Errorf("expected ErrNotProvided, got %v", err)
+	}
+}
+
+func TestPrompt_NonInteractive(t *testing.T) {
+	ui.SetNonInteractive(true)
+	t.Cleanup(func() { ui.SetNonInteractive(false) })
+
+	_, err := Prompt{Message: "Password:", Hint: "use --password-file"}.Secret()
+	if !errors.Is(err, ErrNotProvided) || !strings.Contains(err.Error(), "--password-file") {
+		t.Errorf("expected ErrNotProvided with hint, got %v", err)
+	}
+}
@@ -329,17 +329,48 @@ func printSecurity(s *NodeStatus) {
 		printFail("ML permissions: Not granted")
 	}
 
-	// Infrastructure security (not yet detected from API — shown only when set)
-	if s.Security.FirewallConfigured {
-		printOK("Firewall: DOCKER-USER chain configured")
+	// Host security, as recorded by setup; without state only what is set
+	// is shown.
+	h := s.Security
+	switch {
+	case h.FirewallConfigured:
+		printOK("Firewall: Configured")
+	case h.HostChecked:
+		printWarn("Firewall: Not configured (gonka-nop firewall apply)")
 	}
 
-	if s.Security.DDoSProtection {
-		printOK("DDoS protection: Enabled")
+	switch {
+	case h.DDoSProtection:
+		printOK("DDoS protection: Per-IP connection limits")
+	case h.HostChecked:
+		printWarn("DDoS protection: No connection limits")
 	}
 
-	if s.Security.InternalPortsBound {
+	switch {
+	case h.InternalPortsBound:
 		printOK("Internal ports: Bound to 127.0.0.1")
+	case h.HostChecked:
+		printWarn("Internal ports: Not verified as bound to 127.0.0.1")
+	}
+
+	if t := h.TLS; t != nil {
+		printTLS(t)
+	}
+}
+
+func printTLS(t *TLSStatus) {
+	if t.Error != "" {
+		printWarn("TLS (%s): %s", t.Domain, t.Error)
+	}
+	expiry := t.NotAfter.Format("2006-01-02")
+	switch {
+	case t.NotAfter.IsZero():
+	case t.Expired():
+		printFail("TLS (%s): Certificate expired on %s", t.Domain, expiry)
+	case t.ExpiresSoon():
+		printWarn("TLS (%s): Certificate expires in %d days (%s) — check ACME renewal in the proxy logs", t.Domain, t.DaysLeft, expiry)
+	default:
+		printOK("TLS (%s): Valid until %s (%d days)", t.Domain, expiry, t.DaysLeft)
 	}
 }
 
@@ -0,0 +1,126 @@
+package status
+
+import "fmt"
+
+// Health levels, from best to worst.
+const (
+	HealthOK       = "ok"
+	HealthWarning  = "warning"
+	HealthCritical = "critical"
+)
+
+// Health thresholds shared with the text display.
+const (
+	healthMaxBlockLag      = 10
+	healthMinPeers         = 5
+	healthWarnMissPct      = 5.0
+	healthCriticalMissPct  = 20.0
+	healthMLNodeOnlyStatus = "MLNODE-ONLY"
+)
+
+// Health summarizes overall node health for machine consumption.
+type Health struct {
+	Level    string   `json:"level"` // "ok", "warning" or "critical"
+	Warnings []string `json:"warnings"`
+	Critical []string `json:"critical"`
+}
+
+// EvaluateHealth derives an overall health level from a status snapshot,
+// using the same thresholds the text display highlights.
+func EvaluateHealth(s *NodeStatus) Health {
+	h := Health{Level: HealthOK, Warnings: []string{}, Critical: []string{}}
+
+	if s.Overview.OverallStatus == healthMLNodeOnlyStatus {
+		// Chain health is reported by the network node
+		return h
+	}
+
+	evaluateServices(s, &h)
+	evaluateChain(s, &h)
+	evaluateEpoch(s, &h)
+	evaluateMLNode(s, &h)
+	evaluateTLS(s, &h)
+
+	switch {
+	case len(h.Critical) > 0:
+		h.Level = HealthCritical
+	case len(h.Warnings) > 0:
+		h.Level = HealthWarning
+	}
+	return h
+}
+
+func evaluateServices(s *NodeStatus, h *Health) {
+	if s.Overview.ContainersRunning == 0 {
+		h.Critical = append(h.Critical, "core services not reachable")
+	}
+	if s.Overview.OverallStatus == StatusFail {
+		h.Warnings = append(h.Warnings, fmt.Sprintf("setup report: %d/%d checks passed",
+			s.Overview.ChecksPassed, s.Overview.ChecksTotal))
+	}
+}
+
+func evaluateChain(s *NodeStatus, h *Health) {
+	b := s.Blockchain
+	switch {
+	case b.CatchingUp:
+		h.Warnings = append(h.Warnings, "node is catching up")
+	case !b.Synced && s.Overview.ContainersRunning > 0:
+		h.Critical = append(h.Critical, "node not synced")
+	}
+	if b.BlockLag > healthMaxBlockLag {
+		h.Warnings = append(h.Warnings, fmt.Sprintf("block lag %d blocks", b.BlockLag))
+	}
+	if b.PeerCountKnown && b.PeerCount == 0 {
+		h.Critical = append(h.Critical, "no peers connected")
+	} else if b.PeerCountKnown && b.PeerCount < healthMinPeers {
+		h.Warnings = append(h.Warnings, fmt.Sprintf("low peer count (%d)", b.PeerCount))
+	}
+}
+
+func evaluateEpoch(s *NodeStatus, h *Health) {
+	e := s.Epoch
+	if e.TotalCount > 0 {
+		switch {
+		case e.MissPercentage >= healthCriticalMissPct:
+			h.Critical = append(h.Critical, fmt.Sprintf("miss rate %.1f%%", e.MissPercentage))
+		case e.MissPercentage >= healthWarnMissPct:
+			h.Warnings = append(h.Warnings, fmt.Sprintf("miss rate %.1f%%", e.MissPercentage))
+		}
+	}
+	if e.PrevEpochIndex > 0 && !e.PrevEpochClaimed {
+		h.Warnings = append(h.Warnings, fmt.Sprintf("reward for epoch %d not claimed", e.PrevEpochIndex))
+	}
+}
+
+func evaluateMLNode(s *NodeStatus, h *Health) {
+	ml := s.MLNode
+	if ml.PoCStatus == "FAILED" {
+		h.Critical = append(h.Critical, "ML node status FAILED")
+	}
+	if ml.IntendedStatus != "" && ml.PoCStatus != "" && ml.IntendedStatus != ml.PoCStatus {
+		h.Warnings = append(h.Warnings, fmt.Sprintf("ML node transitioning: intended=%s current=%s",
+			ml.IntendedStatus, ml.PoCStatus))
+	}
+	if !ml.LastPoCTime.IsZero() && !ml.LastPoCOK {
+		h.Warnings = append(h.Warnings, "last PoC failed")
+	}
+}
+
+// evaluateTLS reports the handshake or name error and the expiry
+// separately: a certificate for the wrong name can be expired too.
+func evaluateTLS(s *NodeStatus, h *Health) {
+	t := s.Security.TLS
+	if t == nil {
+		return
+	}
+	if t.Error != "" {
+		h.Warnings = append(h.Warnings, "TLS: "+t.Error)
+	}
+	switch {
+	case t.Expired():
+		h.Critical = append(h.Critical, fmt.Sprintf("TLS certificate for %s expired", t.Domain))
+	case t.ExpiresSoon():
+		h.Warnings = append(h.Warnings, fmt.Sprintf("TLS certificate for %s expires in %d days", t.Domain, t.DaysLeft))
+	}
+}
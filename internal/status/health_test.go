@@ -0,0 +1,116 @@
+package status
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvaluateHealth(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(s *NodeStatus)
+		want   string
+	}{
+		{
+			name:   "mocked node is healthy",
+			mutate: func(_ *NodeStatus) {},
+			want:   HealthOK,
+		},
+		{
+			name:   "catching up is a warning",
+			mutate: func(s *NodeStatus) { s.Blockchain.Synced = false; s.Blockchain.CatchingUp = true },
+			want:   HealthWarning,
+		},
+		{
+			name:   "growing lag is a warning",
+			mutate: func(s *NodeStatus) { s.Blockchain.BlockLag = 50 },
+			want:   HealthWarning,
+		},
+		{
+			name:   "unclaimed reward is a warning",
+			mutate: func(s *NodeStatus) { s.Epoch.PrevEpochClaimed = false },
+			want:   HealthWarning,
+		},
+		{
+			name:   "high miss rate is critical",
+			mutate: func(s *NodeStatus) { s.Epoch.MissPercentage = 25 },
+			want:   HealthCritical,
+		},
+		{
+			name:   "no peers is critical",
+			mutate: func(s *NodeStatus) { s.Blockchain.PeerCount = 0 },
+			want:   HealthCritical,
+		},
+		{
+			name:   "failed ML node is critical",
+			mutate: func(s *NodeStatus) { s.MLNode.PoCStatus = "FAILED" },
+			want:   HealthCritical,
+		},
+		{
+			name:   "failed last PoC is a warning",
+			mutate: func(s *NodeStatus) { s.MLNode.LastPoCTime = time.Now(); s.MLNode.LastPoCOK = false },
+			want:   HealthWarning,
+		},
+		{
+			name: "valid TLS certificate is fine",
+			mutate: func(s *NodeStatus) {
+				s.Security.TLS = &TLSStatus{Domain: "node.example.com", NotAfter: time.Now().AddDate(0, 2, 0), DaysLeft: 60}
+			},
+			want: HealthOK,
+		},
+		{
+			name: "TLS certificate close to expiry is a warning",
+			mutate: func(s *NodeStatus) {
+				s.Security.TLS = &TLSStatus{Domain: "node.example.com", NotAfter: time.Now().AddDate(0, 0, 5), DaysLeft: 5}
+			},
+			want: HealthWarning,
+		},
+		{
+			name: "expired TLS certificate is critical",
+			mutate: func(s *NodeStatus) {
+				s.Security.TLS = &TLSStatus{Domain: "node.example.com", NotAfter: time.Now().AddDate(0, 0, -2), DaysLeft: -2}
+			},
+			want: HealthCritical,
+		},
+		{
+			name: "TLS certificate expired hours ago is critical",
+			mutate: func(s *NodeStatus) {
+				s.Security.TLS = &TLSStatus{Domain: "node.example.com", NotAfter: time.Now().Add(-time.Hour), DaysLeft: 0}
+			},
+			want: HealthCritical,
+		},
+		{
+			name: "expired TLS certificate for the wrong name is still critical",
+			mutate: func(s *NodeStatus) {
+				s.Security.TLS = &TLSStatus{Domain: "node.example.com", NotAfter: time.Now().AddDate(0, 0, -2), DaysLeft: -2,
+					Error: "certificate does not cover node.example.com"}
+			},
+			want: HealthCritical,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := FetchMockedStatus()
+			tt.mutate(s)
+			h := EvaluateHealth(s)
+			if h.Level != tt.want {
+				t.Errorf("Level = %q, want %q (warnings=%v critical=%v)", h.Level, tt.want, h.Warnings, h.Critical)
+			}
+		})
+	}
+}
+
+func TestEvaluateHealth_Unreachable(t *testing.T) {
+	h := EvaluateHealth(&NodeStatus{})
+	if h.Level != HealthCritical {
+		t.Errorf("Level = %q, want critical for empty status", h.Level)
+	}
+}
+
+func TestEvaluateHealth_MLNodeOnly(t *testing.T) {
+	s := &NodeStatus{}
+	s.Overview.OverallStatus = healthMLNodeOnlyStatus
+	if h := EvaluateHealth(s); h.Level != HealthOK {
+		t.Errorf("Level = %q, want ok for ML node-only", h.Level)
+	}
+}
@@ -0,0 +1,93 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReportSchemaVersion is bumped whenever a field in Report is renamed or removed.
+// Adding fields does not change the version.
+const ReportSchemaVersion = 1
+
+// Output formats for machine-readable status.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// Report is the machine-readable status document emitted by `status --output`.
+type Report struct {
+	SchemaVersion int       `json:"schema_version"`
+	GeneratedAt   time.Time `json:"generated_at"`
+	Health        Health    `json:"health"`
+	NodeStatus
+}
+
+// NewReport wraps a status snapshot with schema version and health.
+func NewReport(s *NodeStatus) *Report {
+	return &Report{
+		SchemaVersion: ReportSchemaVersion,
+		GeneratedAt:   time.Now().UTC(),
+		Health:        EvaluateHealth(s),
+		NodeStatus:    *s,
+	}
+}
+
+// ParseFormat validates a --output/--format value.
+func ParseFormat(format string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", FormatText:
+		return FormatText, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	case FormatYAML, "yml":
+		return FormatYAML, nil
+	default:
+		return "", fmt.Errorf("unknown output format %q (valid: text, json, yaml)", format)
+	}
+}
+
+// WriteReport serializes the report in the given format (json or yaml).
+// YAML uses the same field names as JSON.
+func WriteReport(w io.Writer, r *Report, format string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal status: %w", err)
+	}
+
+	switch format {
+	case FormatJSON:
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case FormatYAML:
+		// JSON is valid YAML: parsing it into a node tree keeps the json
+		// field names, field order and integer types.
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("convert status: %w", err)
+		}
+		clearYAMLStyle(&doc)
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(&doc); err != nil {
+			return fmt.Errorf("encode yaml: %w", err)
+		}
+		return enc.Close()
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// clearYAMLStyle resets the JSON flow/quoted styles so the output is block YAML.
+func clearYAMLStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		clearYAMLStyle(c)
+	}
+}
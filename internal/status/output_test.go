@@ -0,0 +1,85 @@
+package status
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "", want: FormatText},
+		{input: "text", want: FormatText},
+		{input: "JSON", want: FormatJSON},
+		{input: "yaml", want: FormatYAML},
+		{input: "yml", want: FormatYAML},
+		{input: "xml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseFormat(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFormat(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteReport_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, NewReport(FetchMockedStatus()), FormatJSON); err != nil {
+		t.Fatalf("WriteReport: %v", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+
+	// Stable top-level contract
+	for _, key := range []string{"schema_version", "generated_at", "health", "overview",
+		"blockchain", "epoch", "ml_node", "security", "node_config"} {
+		if _, ok := doc[key]; !ok {
+			t.Errorf("missing top-level key %q", key)
+		}
+	}
+	if v, _ := doc["schema_version"].(float64); int(v) != ReportSchemaVersion {
+		t.Errorf("schema_version = %v, want %d", doc["schema_version"], ReportSchemaVersion)
+	}
+
+	blockchain, _ := doc["blockchain"].(map[string]interface{})
+	if blockchain["block_height"] != float64(1250000) {
+		t.Errorf("blockchain.block_height = %v", blockchain["block_height"])
+	}
+}
+
+func TestWriteReport_YAML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, NewReport(FetchMockedStatus()), FormatYAML); err != nil {
+		t.Fatalf("WriteReport: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{"schema_version: 1\n", "health:\n  level: ok\n", "block_height: 1250000\n", "ml_node:\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("YAML output missing %q", want)
+		}
+	}
+	if strings.Contains(out, "{") {
+		t.Error("YAML output should use block style, not JSON flow style")
+	}
+}
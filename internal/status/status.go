@@ -16,105 +16,105 @@ const (
 
 // NodeStatus holds the complete status of a Gonka node
 type NodeStatus struct {
-	Overview   OverviewStatus
-	Blockchain BlockchainStatus
-	Epoch      EpochStatus
-	MLNode     MLNodeStatus
-	Security   SecurityStatus
-	NodeConfig NodeConfigStatus
+	Overview   OverviewStatus   `json:"overview"`
+	Blockchain BlockchainStatus `json:"blockchain"`
+	Epoch      EpochStatus      `json:"epoch"`
+	MLNode     MLNodeStatus     `json:"ml_node"`
+	Security   SecurityStatus   `json:"security"`
+	NodeConfig NodeConfigStatus `json:"node_config"`
 
 	// Raw data from setup/report for display
-	SetupReport *SetupReport
+	SetupReport *SetupReport `json:"setup_report,omitempty"`
 }
 
 // OverviewStatus holds general node status
 type OverviewStatus struct {
-	ContainersRunning int
-	ContainersTotal   int
-	NodeRegistered    bool
-	NodeAddress       string
-	EpochActive       bool
-	EpochNumber       int
-	EpochWeight       int
+	ContainersRunning int    `json:"containers_running"`
+	ContainersTotal   int    `json:"containers_total"`
+	NodeRegistered    bool   `json:"node_registered"`
+	NodeAddress       string `json:"node_address"`
+	EpochActive       bool   `json:"epoch_active"`
+	EpochNumber       int    `json:"epoch_number"`
+	EpochWeight       int    `json:"epoch_weight"`
 
 	// From setup/report
-	OverallStatus string // "PASS" or "FAIL"
-	ChecksPassed  int
-	ChecksTotal   int
-	Issues        []string // failed check messages
+	OverallStatus string   `json:"overall_status"` // "PASS" or "FAIL"
+	ChecksPassed  int      `json:"checks_passed"`
+	ChecksTotal   int      `json:"checks_total"`
+	Issues        []string `json:"issues"` // failed check messages
 }
 
 // BlockchainStatus holds blockchain-related metrics
 type BlockchainStatus struct {
-	BlockHeight     int64
-	NetworkHeight   int64 // highest known block on network
-	BlockLag        int64 // NetworkHeight - BlockHeight
-	Synced          bool
-	CatchingUp      bool
-	PeerCount       int
-	PeerCountKnown  bool // true if RPC was reachable and peer count is accurate
-	ValidatorAddr   string
-	IsValidator     bool
-	VotingPower     int64
-	MissRate        float64 // percentage of missed blocks in current epoch
-	MissedBlocks    int
-	TotalBlocks     int
-	LastBlockTime   time.Time
-	SecondsSinceBlk int // from setup/report block_sync details
+	BlockHeight     int64     `json:"block_height"`
+	NetworkHeight   int64     `json:"network_height"` // highest known block on network
+	BlockLag        int64     `json:"block_lag"`      // NetworkHeight - BlockHeight
+	Synced          bool      `json:"synced"`
+	CatchingUp      bool      `json:"catching_up"`
+	PeerCount       int       `json:"peer_count"`
+	PeerCountKnown  bool      `json:"peer_count_known"` // true if RPC was reachable and peer count is accurate
+	ValidatorAddr   string    `json:"validator_address"`
+	IsValidator     bool      `json:"is_validator"`
+	VotingPower     int64     `json:"voting_power"`
+	MissRate        float64   `json:"miss_rate"` // percentage of missed blocks in current epoch
+	MissedBlocks    int       `json:"missed_blocks"`
+	TotalBlocks     int       `json:"total_blocks"`
+	LastBlockTime   time.Time `json:"last_block_time"`
+	SecondsSinceBlk int       `json:"seconds_since_block"` // from setup/report block_sync details
 }
 
 // EpochStatus holds epoch participation details
 type EpochStatus struct {
-	EpochNumber     int
-	Active          bool
-	Weight          int
-	PoCWeight       int // from epoch_ml_nodes — actual PoC weight assigned
-	MissPercentage  float64
-	MissedCount     int
-	TotalCount      int
-	InferenceCount  int  // total inferences served (not just misses)
-	MissCheckPassed bool // true if the missed_requests_threshold check passed
+	EpochNumber     int     `json:"epoch_number"`
+	Active          bool    `json:"active"`
+	Weight          int     `json:"weight"`
+	PoCWeight       int     `json:"poc_weight"` // from epoch_ml_nodes — actual PoC weight assigned
+	MissPercentage  float64 `json:"miss_percentage"`
+	MissedCount     int     `json:"missed_count"`
+	TotalCount      int     `json:"total_count"`
+	InferenceCount  int     `json:"inference_count"`   // total inferences served (not just misses)
+	MissCheckPassed bool    `json:"miss_check_passed"` // true if the missed_requests_threshold check passed
 
 	// Timeslot allocation from epoch_ml_nodes
-	TimeslotAllocation []bool
+	TimeslotAllocation []bool `json:"timeslot_allocation"`
 
 	// Reward claim status from /admin/v1/config seeds
-	PrevEpochClaimed bool
-	PrevEpochIndex   int
-	UpcomingEpoch    int
+	PrevEpochClaimed bool `json:"prev_epoch_claimed"`
+	PrevEpochIndex   int  `json:"prev_epoch_index"`
+	UpcomingEpoch    int  `json:"upcoming_epoch"`
 }
 
 // MLNodeStatus holds ML node metrics
 type MLNodeStatus struct {
-	Enabled        bool
-	EnabledEpoch   int // epoch when enable took effect
-	ModelName      string
-	ModelLoaded    bool
-	GPUCount       int
-	GPUName        string
-	GPUs           []GPUDetail
-	TPSize         int
-	PPSize         int
-	MemoryUtil     float64
-	MaxModelLen    int
-	PoCStatus      string // current_status from admin API
-	IntendedStatus string // intended_status — mismatch with PoCStatus = transitioning
-	PoCNodeStatus  string // poc_current_status
-	LastPoCTime    time.Time
-	LastPoCOK      bool
-	Hardware       string    // formatted hardware string from report
-	StatusUpdated  time.Time // when state was last updated
+	Enabled        bool        `json:"enabled"`
+	EnabledEpoch   int         `json:"enabled_epoch"` // epoch when enable took effect
+	ModelName      string      `json:"model_name"`
+	ModelLoaded    bool        `json:"model_loaded"`
+	GPUCount       int         `json:"gpu_count"`
+	GPUName        string      `json:"gpu_name"`
+	GPUs           []GPUDetail `json:"gpus"`
+	TPSize         int         `json:"tp_size"`
+	PPSize         int         `json:"pp_size"`
+	MemoryUtil     float64     `json:"memory_util"`
+	MaxModelLen    int         `json:"max_model_len"`
+	PoCStatus      string      `json:"poc_status"`      // current_status from admin API
+	IntendedStatus string      `json:"intended_status"` // intended_status — mismatch with PoCStatus = transitioning
+	PoCNodeStatus  string      `json:"poc_node_status"` // poc_current_status
+	LastPoCTime    time.Time   `json:"last_poc_time"`
+	LastPoCOK      bool        `json:"last_poc_ok"`
+	Hardware       string      `json:"hardware"`       // formatted hardware string from report
+	StatusUpdated  time.Time   `json:"status_updated"` // when state was last updated
 }
 
 // NodeConfigStatus holds node configuration details from /admin/v1/config
 type NodeConfigStatus struct {
-	PublicURL      string
-	PoCCallbackURL string
-	APIVersion     string
-	SeedAPIURL     string
-	UpgradeName    string
-	UpgradeHeight  int64
-	HeightLag      int64 // current_height - last_processed_height
+	PublicURL      string `json:"public_url"`
+	PoCCallbackURL string `json:"poc_callback_url"`
+	APIVersion     string `json:"api_version"`
+	SeedAPIURL     string `json:"seed_api_url"`
+	UpgradeName    string `json:"upgrade_name"`
+	UpgradeHeight  int64  `json:"upgrade_height"`
+	HeightLag      int64  `json:"height_lag"` // current_height - last_processed_height
 }
 
 // GPUDetail holds individual GPU info from setup/report
@@ -130,15 +130,17 @@ type GPUDetail struct {
 
 // SecurityStatus holds security configuration status
 type SecurityStatus struct {
-	FirewallConfigured bool
-	DDoSProtection     bool
-	InternalPortsBound bool // ports bound to 127.0.0.1
-	DriverConsistent   bool
+	FirewallConfigured bool       `json:"firewall_configured"`
+	DDoSProtection     bool       `json:"ddos_protection"`
+	InternalPortsBound bool       `json:"internal_ports_bound"` // ports bound to 127.0.0.1
+	DriverConsistent   bool       `json:"driver_consistent"`
+	HostChecked        bool       `json:"host_checked"`  // the three fields above come from setup state
+	TLS                *TLSStatus `json:"tls,omitempty"` // HTTPS API certificate; nil when TLS is off
 
 	// From setup/report key checks
-	ColdKeyConfigured  bool
-	WarmKeyConfigured  bool
-	PermissionsGranted bool
+	ColdKeyConfigured  bool `json:"cold_key_configured"`
+	WarmKeyConfigured  bool `json:"warm_key_configured"`
+	PermissionsGranted bool `json:"permissions_granted"`
 }
 
 // --- Setup Report types (from /admin/v1/setup/report) ---
@@ -319,6 +321,21 @@ type StatusConfig struct {
 	AdminURL      string // default "http://localhost:9200"
 	VLLMHealthURL string // default "http://localhost:8080"
 	NodeType      string // "full", "network", "mlnode" (empty = "full")
+
+	// Host is the host-level protection setup recorded; nil when unknown.
+	Host *HostSecurity
+
+	// TLSDomain and TLSAddr locate the HTTPS API when TLS is enabled
+	// (e.g. "node.example.com", "127.0.0.1:443"); empty otherwise.
+	TLSDomain string
+	TLSAddr   string
+}
+
+// HostSecurity is what the setup firewall phases found and applied.
+type HostSecurity struct {
+	FirewallConfigured bool
+	DDoSProtection     bool
+	InternalPortsBound bool
 }
 
 // DefaultConfig returns a StatusConfig with default localhost URLs.
@@ -343,6 +360,12 @@ func FetchStatusWithConfig(_ string, cfg *StatusConfig) (*NodeStatus, error) {
 	}
 	nodeType := cfg.NodeType
 	status := &NodeStatus{}
+	if h := cfg.Host; h != nil {
+		status.Security.FirewallConfigured = h.FirewallConfigured
+		status.Security.DDoSProtection = h.DDoSProtection
+		status.Security.InternalPortsBound = h.InternalPortsBound
+		status.Security.HostChecked = true
+	}
 
 	// MLNode-only: no Admin API or chain services locally — skip all network queries
 	if nodeType == "mlnode" {
@@ -368,6 +391,9 @@ func FetchStatusWithConfig(_ string, cfg *StatusConfig) (*NodeStatus, error) {
 		fetchMLNodeStatus(status, cfg)
 	}
 
+	// Certificate of the HTTPS API
+	fetchTLSStatus(status, cfg)
+
 	// Compute overview from collected data
 	fetchOverviewStatus(status)
 
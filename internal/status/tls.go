@@ -0,0 +1,70 @@
+package status
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+)
+
+// tlsWarnDays is how close to expiry a certificate must be before status
+// warns. ACME clients renew 30 days ahead, so less than two weeks left
+// means renewal has been failing for a while.
+const tlsWarnDays = 14
+
+// TLSStatus describes the certificate the HTTPS API presents.
+type TLSStatus struct {
+	Domain   string    `json:"domain"`
+	Issuer   string    `json:"issuer,omitempty"`
+	NotAfter time.Time `json:"not_after,omitempty"`
+	DaysLeft int       `json:"days_left"`       // whole days, truncated
+	Error    string    `json:"error,omitempty"` // handshake failed or the domain is not covered
+}
+
+// Expired reports whether the certificate is past NotAfter. DaysLeft is
+// still 0 for the first day after expiry, so it is not used here.
+func (t *TLSStatus) Expired() bool {
+	return !t.NotAfter.IsZero() && time.Now().After(t.NotAfter)
+}
+
+// ExpiresSoon reports whether the certificate is expired or within
+// tlsWarnDays of expiry.
+func (t *TLSStatus) ExpiresSoon() bool {
+	return !t.NotAfter.IsZero() && (t.Expired() || t.DaysLeft < tlsWarnDays)
+}
+
+// fetchTLSStatus reads the certificate served on cfg.TLSAddr for
+// cfg.TLSDomain. Chain verification is skipped: only the expiry and the
+// name matter here, and a test setup may use a private ACME CA (Pebble).
+// A name mismatch is recorded in Error next to the expiry, not instead.
+func fetchTLSStatus(status *NodeStatus, cfg *StatusConfig) {
+	if cfg.TLSDomain == "" {
+		return
+	}
+	t := &TLSStatus{Domain: cfg.TLSDomain}
+	status.Security.TLS = t
+
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", cfg.TLSAddr, &tls.Config{
+		ServerName:         cfg.TLSDomain,
+		InsecureSkipVerify: true, // #nosec G402 - only the expiry is read
+	})
+	if err != nil {
+		t.Error = err.Error()
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		t.Error = "no certificate presented"
+		return
+	}
+	leaf := certs[0]
+	t.Issuer = leaf.Issuer.CommonName
+	t.NotAfter = leaf.NotAfter
+	t.DaysLeft = int(time.Until(leaf.NotAfter).Hours() / 24)
+	if err := leaf.VerifyHostname(cfg.TLSDomain); err != nil {
+		t.Error = fmt.Sprintf("certificate does not cover %s", cfg.TLSDomain)
+	}
+}
@@ -0,0 +1,55 @@
+package status
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchTLSStatus(t *testing.T) {
+	// httptest's certificate covers example.com and is valid for decades
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "https://")
+
+	tests := []struct {
+		name       string
+		domain     string
+		addr       string
+		wantError  string
+		wantExpiry bool // NotAfter read despite the error
+	}{
+		{name: "valid", domain: "example.com", addr: addr},
+		{name: "wrong domain", domain: "node.example.org", addr: addr, wantError: "does not cover", wantExpiry: true},
+		{name: "unreachable", domain: "example.com", addr: "127.0.0.1:1", wantError: "connect"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &NodeStatus{}
+			fetchTLSStatus(status, &StatusConfig{TLSDomain: tt.domain, TLSAddr: tt.addr})
+			got := status.Security.TLS
+			if got == nil {
+				t.Fatal("TLS status not set")
+			}
+			if tt.wantError == "" {
+				if got.Error != "" || got.NotAfter.IsZero() || got.ExpiresSoon() {
+					t.Errorf("TLS = %+v, want a valid long-lived certificate", got)
+				}
+				return
+			}
+			if !strings.Contains(got.Error, tt.wantError) {
+				t.Errorf("Error = %q, want %q", got.Error, tt.wantError)
+			}
+			if tt.wantExpiry && got.NotAfter.IsZero() {
+				t.Errorf("TLS = %+v, want the expiry read despite the error", got)
+			}
+		})
+	}
+
+	status := &NodeStatus{}
+	fetchTLSStatus(status, &StatusConfig{})
+	if status.Security.TLS != nil {
+		t.Error("TLS status should stay nil when TLS is off")
+	}
+}
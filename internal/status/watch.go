@@ -0,0 +1,147 @@
+package status
+
+import (
+	"fmt"
+	"time"
+)
+
+// Watch event severities.
+const (
+	EventInfo = "info"
+	EventWarn = "warn"
+	EventFail = "fail"
+)
+
+// maxWatchEvents bounds the change log shown above the dashboard.
+const maxWatchEvents = 8
+
+// clearScreen moves the cursor home and clears the terminal.
+const clearScreen = "\033[H\033[2J"
+
+// WatchEvent is a notable change between two consecutive status snapshots.
+type WatchEvent struct {
+	Time     time.Time
+	Severity string
+	Message  string
+}
+
+// Watcher tracks successive snapshots for `status --watch` and keeps a short
+// log of changes between refreshes.
+type Watcher struct {
+	prev   *NodeStatus
+	events []WatchEvent
+}
+
+// Update records a new snapshot and returns the events it produced.
+func (w *Watcher) Update(cur *NodeStatus, now time.Time) []WatchEvent {
+	var events []WatchEvent
+	if w.prev != nil {
+		for _, e := range DiffStatus(w.prev, cur) {
+			e.Time = now
+			events = append(events, e)
+		}
+	}
+	w.prev = cur
+
+	w.events = append(w.events, events...)
+	if len(w.events) > maxWatchEvents {
+		w.events = w.events[len(w.events)-maxWatchEvents:]
+	}
+	return events
+}
+
+// Events returns the recent change log, oldest first.
+func (w *Watcher) Events() []WatchEvent {
+	return w.events
+}
+
+// DiffStatus compares two snapshots and reports the changes worth highlighting:
+// growing block lag, sync and PoC status transitions, epoch changes and new
+// missed inferences.
+func DiffStatus(prev, cur *NodeStatus) []WatchEvent {
+	var events []WatchEvent
+	add := func(severity, format string, args ...interface{}) {
+		events = append(events, WatchEvent{Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	pb, cb := prev.Blockchain, cur.Blockchain
+	switch {
+	case cb.BlockLag > pb.BlockLag && cb.BlockLag > 0:
+		add(EventWarn, "Block lag growing: %d → %d blocks", pb.BlockLag, cb.BlockLag)
+	case pb.BlockLag > healthMaxBlockLag && cb.BlockLag <= healthMaxBlockLag:
+		add(EventInfo, "Block lag recovered: %d → %d blocks", pb.BlockLag, cb.BlockLag)
+	}
+	if pb.Synced && !cb.Synced {
+		add(EventFail, "Node lost sync (catching up: %v)", cb.CatchingUp)
+	} else if !pb.Synced && cb.Synced {
+		add(EventInfo, "Node synced at block %s", formatNumber(cb.BlockHeight))
+	}
+	if pb.PeerCountKnown && cb.PeerCountKnown && cb.PeerCount < pb.PeerCount && cb.PeerCount < healthMinPeers {
+		add(EventWarn, "Peers dropped: %d → %d", pb.PeerCount, cb.PeerCount)
+	}
+
+	pe, ce := prev.Epoch, cur.Epoch
+	if ce.EpochNumber != pe.EpochNumber && pe.EpochNumber > 0 {
+		add(EventInfo, "Epoch %d → %d", pe.EpochNumber, ce.EpochNumber)
+	} else if ce.MissedCount > pe.MissedCount {
+		add(EventFail, "%d new missed inference(s) (%d/%d missed)",
+			ce.MissedCount-pe.MissedCount, ce.MissedCount, ce.TotalCount)
+	}
+
+	pm, cm := prev.MLNode, cur.MLNode
+	if cm.PoCStatus != pm.PoCStatus && (cm.PoCStatus != "" || pm.PoCStatus != "") {
+		severity := EventInfo
+		if cm.PoCStatus == "FAILED" {
+			severity = EventFail
+		}
+		add(severity, "ML node status: %s → %s", orDash(pm.PoCStatus), orDash(cm.PoCStatus))
+	}
+	if cm.IntendedStatus != pm.IntendedStatus && cm.IntendedStatus != "" {
+		add(EventInfo, "ML node intended status: %s → %s", orDash(pm.IntendedStatus), cm.IntendedStatus)
+	}
+	if cm.PoCNodeStatus != pm.PoCNodeStatus && cm.PoCNodeStatus != "" {
+		add(EventInfo, "PoC subsystem: %s → %s", orDash(pm.PoCNodeStatus), cm.PoCNodeStatus)
+	}
+
+	return events
+}
+
+// DisplayWatch redraws the dashboard in place: change log first, then the
+// overview, blockchain, epoch and ML node sections.
+func DisplayWatch(s *NodeStatus, w *Watcher, interval time.Duration, updated time.Time) {
+	fmt.Print(clearScreen)
+	printHeader(fmt.Sprintf("Gonka Node Status — every %s (Ctrl+C to exit)", interval))
+	_, _ = dimmed.Printf("Updated %s\n", updated.Format("15:04:05"))
+
+	printChanges(w.Events())
+	printOverview(s)
+	printBlockchain(s)
+	printEpoch(s)
+	printMLNode(s)
+}
+
+func printChanges(events []WatchEvent) {
+	printSection("Changes")
+	if len(events) == 0 {
+		printInfo("Changes", "none since watch started")
+		return
+	}
+	for _, e := range events {
+		ts := e.Time.Format("15:04:05")
+		switch e.Severity {
+		case EventFail:
+			printFail("%s %s", ts, e.Message)
+		case EventWarn:
+			printWarn("%s %s", ts, e.Message)
+		default:
+			printOK("%s %s", ts, e.Message)
+		}
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,118 @@
+package status
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutate   func(prev, cur *NodeStatus)
+		want     string // substring of the single expected event ("" = no events)
+		severity string
+	}{
+		{
+			name:   "no change",
+			mutate: func(_, _ *NodeStatus) {},
+		},
+		{
+			name:     "block lag growing",
+			mutate:   func(_, cur *NodeStatus) { cur.Blockchain.BlockLag = 20 },
+			want:     "Block lag growing: 3 → 20",
+			severity: EventWarn,
+		},
+		{
+			name: "block lag recovered",
+			mutate: func(prev, cur *NodeStatus) {
+				prev.Blockchain.BlockLag = 40
+				cur.Blockchain.BlockLag = 2
+			},
+			want:     "Block lag recovered",
+			severity: EventInfo,
+		},
+		{
+			name:     "PoC status change",
+			mutate:   func(_, cur *NodeStatus) { cur.MLNode.PoCStatus = "POC" },
+			want:     "ML node status: INFERENCE → POC",
+			severity: EventInfo,
+		},
+		{
+			name:     "ML node failed",
+			mutate:   func(_, cur *NodeStatus) { cur.MLNode.PoCStatus = "FAILED" },
+			want:     "INFERENCE → FAILED",
+			severity: EventFail,
+		},
+		{
+			name:     "new missed inferences",
+			mutate:   func(_, cur *NodeStatus) { cur.Epoch.MissedCount = 5; cur.Epoch.TotalCount = 152 },
+			want:     "2 new missed inference(s)",
+			severity: EventFail,
+		},
+		{
+			name: "epoch rollover resets miss counter without alert",
+			mutate: func(_, cur *NodeStatus) {
+				cur.Epoch.EpochNumber = 428
+				cur.Epoch.MissedCount = 0
+			},
+			want:     "Epoch 427 → 428",
+			severity: EventInfo,
+		},
+		{
+			name:     "lost sync",
+			mutate:   func(_, cur *NodeStatus) { cur.Blockchain.Synced = false; cur.Blockchain.CatchingUp = true },
+			want:     "Node lost sync",
+			severity: EventFail,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev, cur := FetchMockedStatus(), FetchMockedStatus()
+			tt.mutate(prev, cur)
+			events := DiffStatus(prev, cur)
+
+			if tt.want == "" {
+				if len(events) != 0 {
+					t.Fatalf("expected no events, got %+v", events)
+				}
+				return
+			}
+			if len(events) != 1 {
+				t.Fatalf("expected 1 event, got %+v", events)
+			}
+			if !strings.Contains(events[0].Message, tt.want) {
+				t.Errorf("Message = %q, want substring %q", events[0].Message, tt.want)
+			}
+			if events[0].Severity != tt.severity {
+				t.Errorf("Severity = %q, want %q", events[0].Severity, tt.severity)
+			}
+		})
+	}
+}
+
+func TestWatcher_Update(t *testing.T) {
+	w := &Watcher{}
+	now := time.Now()
+
+	if events := w.Update(FetchMockedStatus(), now); len(events) != 0 {
+		t.Fatalf("first snapshot should produce no events, got %+v", events)
+	}
+
+	// Each refresh grows lag by one block
+	for i := 1; i <= maxWatchEvents+3; i++ {
+		s := FetchMockedStatus()
+		s.Blockchain.BlockLag = int64(3 + i)
+		if events := w.Update(s, now.Add(time.Duration(i)*time.Second)); len(events) != 1 {
+			t.Fatalf("refresh %d: expected 1 event, got %+v", i, events)
+		}
+	}
+
+	log := w.Events()
+	if len(log) != maxWatchEvents {
+		t.Fatalf("event log len = %d, want %d", len(log), maxWatchEvents)
+	}
+	if !strings.Contains(log[len(log)-1].Message, "→ 14 blocks") {
+		t.Errorf("latest event = %q, want lag 14", log[len(log)-1].Message)
+	}
+}
@@ -2,6 +2,7 @@ package ui
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/fatih/color"
 )
@@ -15,32 +16,71 @@ var (
 	dimmed = color.New(color.Faint)
 )
 
+// Event is one progress line, mirrored to the sink set with SetSink.
+type Event struct {
+	Level   string `json:"level"` // info, success, warn, error, detail, header
+	Message string `json:"message"`
+}
+
+var (
+	sinkMu sync.Mutex
+	sink   func(Event)
+)
+
+// SetSink mirrors every message printed by this package to fn, e.g. to
+// stream progress over HTTP. It returns a function that removes the sink.
+func SetSink(fn func(Event)) (restore func()) {
+	sinkMu.Lock()
+	prev := sink
+	sink = fn
+	sinkMu.Unlock()
+	return func() {
+		sinkMu.Lock()
+		sink = prev
+		sinkMu.Unlock()
+	}
+}
+
+func emit(level, format string, args ...interface{}) {
+	sinkMu.Lock()
+	fn := sink
+	sinkMu.Unlock()
+	if fn != nil {
+		fn(Event{Level: level, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
 // Info prints an informational message
 func Info(format string, args ...interface{}) {
+	emit("info", format, args...)
 	_, _ = cyan.Print("ℹ ")
 	fmt.Printf(format+"\n", args...)
 }
 
 // Success prints a success message
 func Success(format string, args ...interface{}) {
+	emit("success", format, args...)
 	_, _ = green.Print("✓ ")
 	fmt.Printf(format+"\n", args...)
 }
 
 // Warn prints a warning message
 func Warn(format string, args ...interface{}) {
+	emit("warn", format, args...)
 	_, _ = yellow.Print("⚠ ")
 	fmt.Printf(format+"\n", args...)
 }
 
 // Error prints an error message
 func Error(format string, args ...interface{}) {
+	emit("error", format, args...)
 	_, _ = red.Print("✗ ")
 	fmt.Printf(format+"\n", args...)
 }
 
 // Header prints a section header
 func Header(text string) {
+	emit("header", "%s", text)
 	fmt.Println()
 	_, _ = bold.Println(text)
 	_, _ = dimmed.Println(repeat("─", len(text)))
@@ -65,6 +105,7 @@ func PhaseFailed(name string, err error) {
 
 // Detail prints indented detail line
 func Detail(format string, args ...interface{}) {
+	emit("detail", format, args...)
 	_, _ = dimmed.Print("  → ")
 	fmt.Printf(format+"\n", args...)
 }
@@ -0,0 +1,28 @@
+package ui
+
+import "testing"
+
+func TestSetSink(t *testing.T) {
+	var got []Event
+	restore := SetSink(func(e Event) { got = append(got, e) })
+
+	Info("pulling %s", "mlnode")
+	Detail("step %d", 2)
+	Header("Safe ML Node Update")
+	restore()
+	Warn("not mirrored")
+
+	want := []Event{
+		{Level: "info", Message: "pulling mlnode"},
+		{Level: "detail", Message: "step 2"},
+		{Level: "header", Message: "Safe ML Node Update"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
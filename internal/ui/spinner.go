@@ -36,13 +36,13 @@ func (sp *Spinner) UpdateMessage(message string) {
 // StopWithSuccess stops spinner and shows success message
 func (sp *Spinner) StopWithSuccess(message string) {
 	sp.s.Stop()
-	Success(message)
+	Success("%s", message)
 }
 
 // StopWithError stops spinner and shows error message
 func (sp *Spinner) StopWithError(message string) {
 	sp.s.Stop()
-	Error(message)
+	Error("%s", message)
 }
 
 // WithSpinner runs a function with a spinner, handling success/failure